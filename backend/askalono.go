@@ -27,10 +27,13 @@ package backend
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"syscall"
 
@@ -47,6 +50,33 @@ const (
 	AskalonoConfidenceError = "Confidence threshold not high enough for any known license"
 )
 
+// AskalonoOptions are the settings that change how we run askalono. The zero
+// value of each field keeps the default behaviour.
+type AskalonoOptions struct {
+	// Dataset is the path of a custom license dataset to use instead of the
+	// one built into askalono. It is either a compiled askalono cache file,
+	// or a directory in the SPDX license-list-data json details format,
+	// which gets compiled into a cache file once when we start up.
+	Dataset string `json:"dataset"`
+
+	// Confidence is the minimum score from zero to one that a match must
+	// have to be reported. Askalono has its own built-in minimum, so this
+	// can only be used to make it stricter. If it is zero, then we report
+	// everything that askalono does.
+	Confidence float64 `json:"confidence"`
+}
+
+// Validate returns an error if these options are not valid.
+func (obj *AskalonoOptions) Validate() error {
+	if obj == nil {
+		return nil
+	}
+	if obj.Confidence < 0.0 || obj.Confidence > 1.0 {
+		return fmt.Errorf("askalono confidence must be between 0 and 1")
+	}
+	return nil
+}
+
 // Askalono is based on the rust askalono project. It uses the Sørensen–Dice
 // coefficient for license comparison. It would be pretty easy, and preferable
 // to use one of the many pre-existing golang Sørensen–Dice implementations and
@@ -60,8 +90,31 @@ type Askalono struct {
 	Logf   func(format string, v ...interface{})
 	Prefix safepath.AbsDir
 
+	// Perms is the permission policy for everything that we write. If it
+	// is nil, then the default policy is used.
+	Perms *interfaces.Perms
+
+	// Options change how we run askalono. If this is nil, then the
+	// defaults are used.
+	Options *AskalonoOptions
+
+	// Raw keeps the compressed json output of askalono in each result, so
+	// that it can be reviewed later on. This makes the results larger.
+	Raw bool
+
 	// binary is the path of the executable to run.
 	binary string
+
+	// version is the output of askalono --version.
+	version string
+
+	// dataset is the path of the compiled license cache file to use, if
+	// we're not using the built-in one.
+	dataset string
+
+	// datasetSum is the sha256 of the dataset, so that the version changes
+	// when the dataset does.
+	datasetSum string
 }
 
 func (obj *Askalono) String() string {
@@ -69,6 +122,10 @@ func (obj *Askalono) String() string {
 }
 
 func (obj *Askalono) Setup(ctx context.Context) error {
+	if err := obj.Options.Validate(); err != nil {
+		return err
+	}
+
 	// This runs --help to check this is in the path and running properly.
 	// It also unpacks the embedded askalono binary if we have one to use!
 
@@ -80,11 +137,11 @@ func (obj *Askalono) Setup(ctx context.Context) error {
 
 	relDir := safepath.UnsafeParseIntoRelDir("askalono/")
 	prefix := safepath.JoinToAbsDir(obj.Prefix, relDir)
-	if err := os.MkdirAll(prefix.Path(), interfaces.Umask); err != nil {
+	if err := os.MkdirAll(prefix.Path(), obj.Perms.DirMode()); err != nil {
 		return err
 	}
 
-	if size, absFile, err := askalono.InstallBinary(prefix); err != nil {
+	if size, absFile, err := askalono.InstallBinary(prefix, obj.Perms); err != nil {
 		// not a permanent error, we can fall back to anything built-in
 		obj.Logf("unpacking binary failed: %v", err)
 	} else {
@@ -119,9 +176,95 @@ func (obj *Askalono) Setup(ctx context.Context) error {
 		return errwrap.Wrapf(err, "error running: %s", prog)
 	}
 
+	args = []string{"--version"}
+	prog = fmt.Sprintf("%s %s", obj.binary, strings.Join(args, " "))
+	obj.Logf("running: %s", prog)
+	cmd = exec.CommandContext(ctx, obj.binary, args...)
+	cmd.Dir = ""
+	cmd.Env = []string{}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+		Pgid:    0,
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return errwrap.Wrapf(err, "error running: %s", prog)
+	}
+	obj.version = strings.TrimSpace(string(out))
+
+	if obj.Options != nil && obj.Options.Dataset != "" {
+		if err := obj.preload(ctx, prefix); err != nil {
+			return errwrap.Wrapf(err, "error loading askalono dataset %s", obj.Options.Dataset)
+		}
+	}
+
 	return nil
 }
 
+// preload gets the custom dataset ready to use. A directory of SPDX license
+// data is compiled into a cache file under the prefix, which happens once here
+// instead of for every file that we scan. The sum of the resulting cache file
+// is kept so that it can be included in the version.
+func (obj *Askalono) preload(ctx context.Context, prefix safepath.AbsDir) error {
+	dataset := obj.Options.Dataset
+	fileInfo, err := os.Stat(dataset)
+	if err != nil {
+		return err
+	}
+	obj.dataset = dataset
+
+	if fileInfo.IsDir() {
+		sum := sha256.Sum256([]byte(dataset))
+		obj.dataset = filepath.Join(prefix.Path(), fmt.Sprintf("%x.bin.zstd", sum))
+
+		args := []string{"--cache", obj.dataset, "cache", "load-spdx", dataset}
+		prog := fmt.Sprintf("%s %s", obj.binary, strings.Join(args, " "))
+		obj.Logf("running: %s", prog)
+		cmd := exec.CommandContext(ctx, obj.binary, args...)
+		cmd.Dir = ""
+		cmd.Env = []string{}
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Setpgid: true,
+			Pgid:    0,
+		}
+		if err := cmd.Run(); err != nil {
+			return errwrap.Wrapf(err, "error running: %s", prog)
+		}
+	}
+
+	f, err := os.Open(obj.dataset)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	obj.datasetSum = fmt.Sprintf("%x", h.Sum(nil))
+	obj.Logf("using dataset: %s", obj.dataset)
+
+	return nil
+}
+
+// Version returns the version of askalono, which has its license database built
+// in, along with the sum of any custom dataset and the confidence threshold,
+// since both of those change the results. This is only valid after Setup has
+// run.
+func (obj *Askalono) Version() string {
+	version := obj.version
+	if obj.datasetSum != "" {
+		version += "\ndataset: " + obj.datasetSum
+	}
+	if obj.Options != nil && obj.Options.Confidence > 0.0 {
+		version += fmt.Sprintf("\nconfidence: %v", obj.Options.Confidence)
+	}
+	if obj.Raw {
+		version += "\nraw" // cached results without it aren't enough
+	}
+	return version
+}
+
 func (obj *Askalono) ScanPath(ctx context.Context, path safepath.Path, info *interfaces.Info) (*interfaces.Result, error) {
 
 	if info.FileInfo.IsDir() { // path.IsDir() should be the same.
@@ -137,7 +280,11 @@ func (obj *Askalono) ScanPath(ctx context.Context, path safepath.Path, info *int
 	defer cancel()
 
 	// yes the args need to go in this order, nothing else works...
-	args := []string{"--format", "json", "identify", "--optimize", filename}
+	args := []string{}
+	if obj.dataset != "" {
+		args = append(args, "--cache", obj.dataset)
+	}
+	args = append(args, "--format", "json", "identify", "--optimize", filename)
 
 	prog := fmt.Sprintf("%s %s", obj.binary, strings.Join(args, " "))
 
@@ -212,7 +359,19 @@ func (obj *Askalono) ScanPath(ctx context.Context, path safepath.Path, info *int
 		return nil, nil // didn't find anything
 	}
 
-	return askalonoResultHelper(askalonoOutput.Result)
+	result, err := askalonoResultHelper(askalonoOutput.Result)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Options != nil && result.Confidence < obj.Options.Confidence {
+		return nil, nil // skip, not confident enough
+	}
+	if obj.Raw {
+		if result.Raw, err = compressRaw(out); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
 }
 
 // AskalonoOutput is modelled after the askalono output format.
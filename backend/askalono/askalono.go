@@ -33,6 +33,7 @@ import (
 	"os"
 	"runtime"
 
+	"github.com/awslabs/yesiscan/interfaces"
 	"github.com/awslabs/yesiscan/util/errwrap"
 	"github.com/awslabs/yesiscan/util/safepath"
 )
@@ -139,8 +140,8 @@ func GetZip() ([]byte, error) {
 
 // InstallBinary installs an askalono binary into this dir if it's not there
 // already or if it has the wrong hash. It then returns its extracted size, and
-// its complete path.
-func InstallBinary(absDir safepath.AbsDir) (int64, safepath.AbsFile, error) {
+// its complete path. The binary is written with the exec mode of the perms.
+func InstallBinary(absDir safepath.AbsDir, perms *interfaces.Perms) (int64, safepath.AbsFile, error) {
 	// NOTE: see this comment in the docs for this function. If the way the
 	// zip files is built changes, we might need to change this for a
 	// GetExpectedPath function call instead.
@@ -227,8 +228,7 @@ func InstallBinary(absDir safepath.AbsDir) (int64, safepath.AbsFile, error) {
 	// NOTE: On the difference between absDir and absFile.Dir()... If they
 	// differ, that's because the relfile has a parent relDir component.
 
-	// XXX: which mode method?
-	if err := os.MkdirAll(absFile.Dir().Path(), os.ModePerm); err != nil {
+	if err := os.MkdirAll(absFile.Dir().Path(), perms.DirMode()); err != nil {
 		return 0, safepath.AbsFile{}, err
 	}
 
@@ -255,8 +255,7 @@ func InstallBinary(absDir safepath.AbsDir) (int64, safepath.AbsFile, error) {
 	}
 
 	// At this point, we can write out the file...
-	// XXX: which mode method?
-	if err := os.WriteFile(absFile.Path(), data, os.ModePerm); err != nil {
+	if err := os.WriteFile(absFile.Path(), data, perms.ExecMode()); err != nil {
 		return 0, safepath.AbsFile{}, errwrap.Wrapf(err, "error writing our file to disk at %s", absFile.Path())
 	}
 
@@ -0,0 +1,136 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/backend"
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+// fakeAskalono compiles a fake cache, records the args it gets when it does
+// identify, and then always reports MIT with a score of 0.85. Askalono is run
+// with an empty environment, so this only uses shell builtins.
+const fakeAskalono = `#!/bin/sh
+dir="${0%/*}"
+if [ "$1" = "--version" ]; then
+	echo "askalono 0.0.0"
+	exit 0
+fi
+if [ "$4" = "load-spdx" ]; then
+	echo "compiled" > "$2"
+	exit 0
+fi
+echo "$@" > "$dir/args"
+for last; do :; done
+printf '{"path": "%s", "result": {"score": 0.85, "license": {"name": "MIT", "kind": "original", "aliases": []}, "containing": []}}' "$last"
+`
+
+func TestAskalonoOptions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "askalono"), []byte(fakeAskalono), 0700); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+
+	dataset := filepath.Join(dir, "dataset")
+	if err := os.Mkdir(dataset, 0700); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	input := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(input, []byte("hello\n"), 0600); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	absFile, err := safepath.ParseIntoAbsFile(input)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	fileInfo, err := os.Stat(input)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	prefix, err := safepath.ParseIntoAbsDir(filepath.Join(dir, "prefix") + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	for _, x := range []struct {
+		confidence float64
+		found      bool
+	}{
+		{0.0, true},
+		{0.9, false},
+	} {
+		askalono := &backend.Askalono{
+			Logf: func(format string, v ...interface{}) {
+				t.Logf(format, v...)
+			},
+			Prefix: prefix,
+			Options: &backend.AskalonoOptions{
+				Dataset:    dataset,
+				Confidence: x.confidence,
+			},
+		}
+		if err := askalono.Setup(context.Background()); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+		if v := askalono.Version(); !strings.Contains(v, "dataset: ") {
+			t.Errorf("expected the version to include the dataset, got: %s", v)
+		}
+
+		info := &interfaces.Info{FileInfo: fileInfo}
+		result, err := askalono.ScanPath(context.Background(), absFile, info)
+		if err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+		if found := result != nil; found != x.found {
+			t.Errorf("confidence %v: expected found: %t, got: %t", x.confidence, x.found, found)
+		}
+
+		b, err := os.ReadFile(filepath.Join(dir, "args"))
+		if err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+		if args := string(b); !strings.HasPrefix(args, "--cache "+filepath.Join(prefix.Path(), "askalono")+"/") {
+			t.Errorf("expected the compiled dataset to be used, got: %s", args)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// TODO: should this be a subpackage?
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+const (
+	// BinaryMinLength is the default shortest run of printable characters
+	// that we consider to be a string. This is the same default that the
+	// strings utility uses, plus a bit more to cut down on noise.
+	BinaryMinLength = 8
+
+	// BinaryConfidence is the confidence of every result, since a notice
+	// embedded in a binary is only a hint about what was linked into it.
+	BinaryConfidence = 0.5
+)
+
+// BinaryRules are the built-in patterns for the well-known license notices that
+// commonly get compiled into binaries, usually as part of a --version or about
+// text. Each pattern is matched against one embedded string at a time.
+var BinaryRules = []*RegexpLicenseRule{
+	{Pattern: `License GPLv3\+`, ID: "GPL-3.0-or-later"},
+	{Pattern: `License GPLv2\+`, ID: "GPL-2.0-or-later"},
+	{Pattern: `(?i)GNU General Public License,? v(ersion)? ?3`, ID: "GPL-3.0-only"},
+	{Pattern: `(?i)GNU General Public License,? v(ersion)? ?2`, ID: "GPL-2.0-only"},
+	{Pattern: `(?i)GNU Lesser General Public License,? v(ersion)? ?3`, ID: "LGPL-3.0-only"},
+	{Pattern: `(?i)GNU Lesser General Public License,? v(ersion)? ?2\.1`, ID: "LGPL-2.1-only"},
+	{Pattern: `(?i)Apache License,? Version 2\.0`, ID: "Apache-2.0"},
+	{Pattern: `(?i)Mozilla Public License,? v(ersion|\.)? ?2\.0`, ID: "MPL-2.0"},
+	{Pattern: `(?i)Eclipse Public License,? (- )?v(ersion|\.)? ?2\.0`, ID: "EPL-2.0"},
+	{Pattern: `(?i)Boost Software License,? (- )?Version 1\.0`, ID: "BSL-1.0"},
+	{Pattern: `Permission is hereby granted, free of charge, to any person obtaining`, ID: "MIT"},
+	{Pattern: `Permission to use, copy, modify, and(/or)? distribute this software for any`, ID: "ISC"},
+	{Pattern: `This product includes software developed by the OpenSSL Project`, ID: "OpenSSL"},
+}
+
+// Binary is a backend that looks for license notices in compiled binaries that
+// have no source code available, such as firmware or vendor-supplied programs.
+// It pulls out the printable strings like the strings utility does, and runs
+// the SPDX parser and a list of well-known notice patterns over them. Since a
+// notice only hints at what was linked in, it is a best-effort signal with a
+// low confidence. Anything that isn't an ELF, PE, Mach-O or java class file is
+// skipped, because the text backends handle those better.
+type Binary struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	// MinLength is the shortest run of printable characters that counts as
+	// a string. If it is zero, then BinaryMinLength is used.
+	MinLength int
+
+	// Rules are the notice patterns to look for. If this is nil, then the
+	// built-in BinaryRules are used.
+	Rules []*RegexpLicenseRule
+
+	spdx    *Spdx
+	regexps *RegexpCore
+}
+
+func (obj *Binary) String() string {
+	return "binary"
+}
+
+func (obj *Binary) Setup(ctx context.Context) error {
+	if obj.MinLength < 0 {
+		return fmt.Errorf("invalid min length: %d", obj.MinLength)
+	}
+	if obj.MinLength == 0 {
+		obj.MinLength = BinaryMinLength
+	}
+	rules := obj.Rules
+	if rules == nil {
+		rules = BinaryRules
+	}
+
+	obj.spdx = &Spdx{
+		Debug: obj.Debug,
+		Logf:  obj.Logf,
+	}
+	obj.regexps = &RegexpCore{
+		Debug: obj.Debug,
+		Logf:  obj.Logf,
+		Rules: rules,
+	}
+	return obj.regexps.Setup(ctx)
+}
+
+// Version returns a hash of the rules and settings, since the results only
+// change when they do.
+func (obj *Binary) Version() string {
+	return fmt.Sprintf("%s min-length=%d", obj.regexps.Version(), obj.MinLength)
+}
+
+func (obj *Binary) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
+	if info.FileInfo.IsDir() {
+		return nil, nil // skip
+	}
+	if !IsBinary(data) {
+		return nil, nil // skip
+	}
+
+	text := BinaryStrings(data, obj.MinLength)
+	if len(text) == 0 {
+		return nil, nil
+	}
+	select {
+	case <-ctx.Done():
+		return nil, errwrap.Wrapf(ctx.Err(), "scanner ended early")
+	default:
+	}
+
+	licenseMap := make(map[string]*licenses.License)
+	for _, b := range []interfaces.DataBackend{obj.spdx, obj.regexps} {
+		result, err := b.ScanData(ctx, text, info)
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "binary scanner error")
+		}
+		if result == nil {
+			continue
+		}
+		for _, license := range result.Licenses {
+			licenseMap[license.String()] = license
+		}
+	}
+	if len(licenseMap) == 0 {
+		return nil, nil
+	}
+
+	ids := []string{}
+	for id := range licenseMap {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids) // deterministic order
+
+	licenseList := []*licenses.License{}
+	for _, id := range ids {
+		licenseList = append(licenseList, licenseMap[id])
+	}
+	if obj.Debug {
+		obj.Logf("found %d license(s) in binary: %s", len(licenseList), info.UID)
+	}
+
+	return &interfaces.Result{
+		Licenses:   licenseList,
+		Confidence: BinaryConfidence,
+	}, nil
+}
+
+// IsBinary returns true if the data starts with the header of an ELF, PE or
+// Mach-O executable, or of a java class file. A universal Mach-O binary has the
+// same magic number as a class file, but we want both of them anyways.
+func IsBinary(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+	switch binary.BigEndian.Uint32(data) {
+	case 0x7f454c46: // ELF
+		return true
+	case 0xfeedface, 0xfeedfacf, 0xcefaedfe, 0xcffaedfe: // Mach-O
+		return true
+	case 0xcafebabe: // universal Mach-O or java class
+		return true
+	}
+
+	// A PE file starts with an MS-DOS stub, which points to the PE header.
+	if len(data) < 0x40 || data[0] != 'M' || data[1] != 'Z' {
+		return false
+	}
+	offset := int(binary.LittleEndian.Uint32(data[0x3c:]))
+	if offset < 0 || offset+4 > len(data) {
+		return false
+	}
+	return bytes.Equal(data[offset:offset+4], []byte("PE\x00\x00"))
+}
+
+// BinaryStrings returns every run of at least min printable characters in the
+// data, one per line, like the strings utility would. Runs of little-endian
+// UTF-16 are included too, since that's how windows binaries store the text of
+// their version resources, which is where the copyright notices usually are.
+func BinaryStrings(data []byte, min int) []byte {
+	out := &bytes.Buffer{}
+	add := func(s []byte) {
+		if len(s) >= min {
+			out.Write(s)
+			out.WriteByte('\n')
+		}
+	}
+
+	// single byte strings
+	start := -1
+	for i, c := range data {
+		if isPrintable(c) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			add(data[start:i])
+			start = -1
+		}
+	}
+	if start >= 0 {
+		add(data[start:])
+	}
+
+	// UTF-16LE strings, at both alignments
+	for align := 0; align < 2; align++ {
+		run := []byte{}
+		for i := align; i+1 < len(data); i += 2 {
+			if isPrintable(data[i]) && data[i+1] == 0 {
+				run = append(run, data[i])
+				continue
+			}
+			add(run)
+			run = run[:0]
+		}
+		add(run)
+	}
+
+	return out.Bytes()
+}
+
+// isPrintable returns true for the printable ascii characters, including tab.
+func isPrintable(c byte) bool {
+	return c == '\t' || (c >= 0x20 && c < 0x7f)
+}
@@ -0,0 +1,96 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/yesiscan/backend"
+	"github.com/awslabs/yesiscan/interfaces"
+)
+
+// utf16 encodes an ascii string as little-endian UTF-16.
+func utf16(s string) []byte {
+	b := []byte{}
+	for _, c := range []byte(s) {
+		b = append(b, c, 0)
+	}
+	return b
+}
+
+func TestBinary(t *testing.T) {
+	elf := []byte("\x7fELF\x02\x01\x01\x00\x00\x00")
+	elf = append(elf, []byte("\x00\x01SPDX-License-Identifier: MIT\x00\x02\x03")...)
+	elf = append(elf, utf16("Licensed under the Apache License, Version 2.0")...)
+	elf = append(elf, []byte("\x00\x00short\x00")...)
+
+	text := []byte("SPDX-License-Identifier: MIT\n")
+
+	b := &backend.Binary{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf("backend: "+format, v...)
+		},
+	}
+	if err := b.Setup(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	dir := t.TempDir()
+	scan := func(data []byte) *interfaces.Result {
+		filename := filepath.Join(dir, "file")
+		if err := os.WriteFile(filename, data, 0600); err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		fileInfo, err := os.Stat(filename)
+		if err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		info := &interfaces.Info{FileInfo: fileInfo, UID: "file://" + filename}
+		result, err := b.ScanData(context.Background(), data, info)
+		if err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		return result
+	}
+
+	result := scan(elf)
+	if result == nil || len(result.Licenses) != 2 {
+		t.Errorf("expected two licenses, got: %+v", result)
+		return
+	}
+	if s := result.Licenses[0].String() + " " + result.Licenses[1].String(); s != "Apache-2.0 MIT" {
+		t.Errorf("unexpected licenses: %s", s)
+	}
+	if result.Confidence != backend.BinaryConfidence {
+		t.Errorf("unexpected confidence: %f", result.Confidence)
+	}
+
+	if result := scan(text); result != nil {
+		t.Errorf("expected a text file to be skipped, got: %+v", result)
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// TODO: should this be a subpackage?
+package backend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/licenses"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	// CargoManifestFilename is the file name used by the rust crate
+	// manifests.
+	CargoManifestFilename = "Cargo.toml"
+
+	// CargoLockfileFilename is the file name used by the rust lockfiles.
+	CargoLockfileFilename = "Cargo.lock"
+
+	// CargoLicenseFileOrigin is the origin of the custom licenses that we
+	// return for the crates which only point to a license file.
+	CargoLicenseFileOrigin = "crates.io"
+
+	// cargoRegistrySource is the prefix of the source of the locked
+	// packages that came from a registry, and not from git or a path.
+	cargoRegistrySource = "registry+"
+)
+
+// Cargo is a backend for the Cargo.toml manifests and the Cargo.lock lockfiles
+// of rust crates. It finds the SPDX expression in the license field of the
+// manifest. A crate with a non-standard license points to its license file
+// with the license-file field instead, and it gets a custom license named after
+// that file, so that it's looked at. The lockfile doesn't record any licenses,
+// so the manifest of each locked dependency is looked up in the local cargo
+// registry cache, where cargo put it when it was built. The dependencies which
+// aren't in there are skipped, so build the project first for the best
+// results.
+type Cargo struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	// Registries is the list of cargo registry source directories that
+	// the manifests of the locked dependencies are looked up in. If it is
+	// nil, then the one in $CARGO_HOME, or in ~/.cargo is used.
+	Registries []string
+}
+
+// String method returns the name of the backend.
+func (obj *Cargo) String() string {
+	return "cargo"
+}
+
+// ScanData method is used to extract license ids from data and return licenses
+// based on the license ids.
+func (obj *Cargo) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
+	name := info.FileInfo.Name()
+	if name != CargoManifestFilename && name != CargoLockfileFilename {
+		return nil, nil // skip
+	}
+	if info.FileInfo.IsDir() {
+		return nil, nil // skip
+	}
+	if len(data) == 0 {
+		return nil, nil // skip
+	}
+
+	manifests := []*CargoManifest{}
+	if name == CargoManifestFilename {
+		var manifest CargoManifest
+		if err := toml.Unmarshal(data, &manifest); err != nil {
+			// There is a parse error with the file, so we can't
+			// properly examine it for licensing information.
+			result := &interfaces.Result{
+				Confidence: 1.0, // TODO: what should we put here?
+				Skip:       errwrap.Wrapf(err, "parse error"),
+			}
+			return result, nil
+		}
+		manifests = append(manifests, &manifest)
+	} else {
+		var lockfile CargoLockfile
+		if err := toml.Unmarshal(data, &lockfile); err != nil {
+			result := &interfaces.Result{
+				Confidence: 1.0, // TODO: what should we put here?
+				Skip:       errwrap.Wrapf(err, "parse error"),
+			}
+			return result, nil
+		}
+		missing := 0
+		for _, x := range lockfile.Packages {
+			if !strings.HasPrefix(x.Source, cargoRegistrySource) {
+				continue // the local crates are scanned on their own
+			}
+			manifest, err := obj.registryManifest(x.Name, x.Version)
+			if err != nil {
+				if obj.Debug {
+					obj.Logf("cargo: %s %s: %+v", x.Name, x.Version, err)
+				}
+				missing++
+				continue
+			}
+			manifests = append(manifests, manifest)
+		}
+		if missing > 0 {
+			obj.Logf("cargo: %d locked crates are not in the registry cache, build first to fetch them", missing)
+		}
+	}
+
+	licenseMap := make(map[string]struct{})
+	custom := make(map[string]struct{})
+	clauses := [][]string{}
+	for _, x := range manifests {
+		if s, ok := x.Package.License.(string); ok && strings.TrimSpace(s) != "" {
+			// the old crates use a slash instead of the OR operator
+			expression := strings.ReplaceAll(s, "/", " OR ")
+			for _, lid := range ExpressionIDs(expression) {
+				licenseMap[lid] = struct{}{}
+			}
+			clauses = append(clauses, ExpressionClauses(expression)...)
+			continue
+		}
+		if x.Package.LicenseFile != "" {
+			custom[x.Package.Name+"/"+x.Package.LicenseFile] = struct{}{}
+		}
+		// TODO: resolve license.workspace = true from the workspace
+	}
+
+	if len(licenseMap) == 0 && len(custom) == 0 {
+		// If we did not get any license names we return nil, nil.
+		return nil, nil
+	}
+
+	files := []string{}
+	for x := range custom {
+		files = append(files, x)
+	}
+	sort.Strings(files) // deterministic order
+
+	licenseList := idsToLicenses(licenseMap)
+	for _, x := range files {
+		license := &licenses.License{
+			Origin: CargoLicenseFileOrigin,
+			Custom: x,
+		}
+		licenseList = append(licenseList, license)
+	}
+
+	result := &interfaces.Result{
+		Licenses:   licenseList,
+		Confidence: 1.0, // TODO: what should we put here?
+		Choices:    clausesToChoices(clauses),
+	}
+
+	return result, nil
+}
+
+// registries returns the list of cargo registry source directories to look in.
+func (obj *Cargo) registries() []string {
+	if obj.Registries != nil {
+		return obj.Registries
+	}
+	home := os.Getenv("CARGO_HOME")
+	if home == "" {
+		h, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		home = filepath.Join(h, ".cargo")
+	}
+	return []string{filepath.Join(home, "registry", "src")}
+}
+
+// registryManifest finds and parses the manifest of this version of a crate in
+// the registry cache. Each registry has its own directory in there.
+func (obj *Cargo) registryManifest(name, version string) (*CargoManifest, error) {
+	for _, dir := range obj.registries() {
+		matches, err := filepath.Glob(filepath.Join(dir, "*", name+"-"+version, CargoManifestFilename))
+		if err != nil || len(matches) == 0 {
+			continue
+		}
+		data, err := os.ReadFile(matches[0])
+		if err != nil {
+			return nil, err
+		}
+		var manifest CargoManifest
+		if err := toml.Unmarshal(data, &manifest); err != nil {
+			return nil, errwrap.Wrapf(err, "parse error")
+		}
+		return &manifest, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+// CargoManifest is a struct that helps store the license fields of a Cargo.toml
+// file.
+type CargoManifest struct {
+	Package struct {
+		// Name is the name of the crate.
+		Name string `toml:"name"`
+
+		// License is the SPDX expression of the license of the crate.
+		// It is a table if it's inherited from the workspace instead.
+		License interface{} `toml:"license"`
+
+		// LicenseFile is the path to the license file of the crate, if
+		// it doesn't use a standard license.
+		LicenseFile string `toml:"license-file"`
+	} `toml:"package"`
+}
+
+// CargoLockfile is a struct that helps store the packages in a Cargo.lock file.
+type CargoLockfile struct {
+	// Packages is the list of every crate that is locked.
+	Packages []struct {
+		Name    string `toml:"name"`
+		Version string `toml:"version"`
+
+		// Source is where the crate came from. It's empty for the crates
+		// in the same workspace.
+		Source string `toml:"source"`
+	} `toml:"package"`
+}
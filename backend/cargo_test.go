@@ -0,0 +1,92 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/yesiscan/backend"
+)
+
+func TestCargo(t *testing.T) {
+	registry := t.TempDir()
+	crates := map[string]string{
+		"serde-1.0.0": "[package]\nname = \"serde\"\nversion = \"1.0.0\"\nlicense = \"MIT OR Apache-2.0\"\n",
+		"ring-0.17.0": "[package]\nname = \"ring\"\nversion = \"0.17.0\"\nlicense-file = \"LICENSE\"\n",
+	}
+	for name, data := range crates {
+		dir := filepath.Join(registry, "index.crates.io-6f17d22bba15001f", name)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+		if err := os.WriteFile(filepath.Join(dir, backend.CargoManifestFilename), []byte(data), 0600); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+	}
+
+	b := &backend.Cargo{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf("backend: "+format, v...)
+		},
+		Registries: []string{registry},
+	}
+
+	lockfile := `version = 3
+
+[[package]]
+name = "mine"
+version = "0.1.0"
+dependencies = ["serde", "ring", "missing"]
+
+[[package]]
+name = "serde"
+version = "1.0.0"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+
+[[package]]
+name = "ring"
+version = "0.17.0"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+
+[[package]]
+name = "missing"
+version = "9.9.9"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+`
+	testDataBackend(t, b, []dataTest{
+		{"Cargo.toml", "[package]\nname = \"x\"\nlicense = \"MIT/Apache-2.0\"\n", "Apache-2.0, MIT"},
+		{"Cargo.toml", "[package]\nname = \"x\"\nlicense = \"Apache-2.0 WITH LLVM-exception\"\n", "Apache-2.0"},
+		{"Cargo.toml", "[package]\nname = \"x\"\nlicense-file = \"COPYING\"\n", "x/COPYING(crates.io)"},
+		{"Cargo.toml", "[package]\nname = \"x\"\nlicense.workspace = true\n", ""},
+		{"Cargo.toml", "[workspace]\nmembers = [\"a\"]\n", ""},
+		{"Cargo.lock", lockfile, "Apache-2.0, MIT, ring/LICENSE(crates.io)"},
+		{"other.toml", "[package]\nlicense = \"MIT\"\n", ""},
+	})
+
+	testDataSkip(t, b, "Cargo.toml", "[package\n")
+}
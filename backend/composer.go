@@ -0,0 +1,188 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// TODO: should this be a subpackage?
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+const (
+	// ComposerManifestFilename is the file name used by the composer
+	// manifests.
+	ComposerManifestFilename = "composer.json"
+
+	// ComposerLockfileFilename is the file name used by the composer
+	// lockfiles.
+	ComposerLockfileFilename = "composer.lock"
+)
+
+// Composer is a backend for the composer.json manifests and the composer.lock
+// lockfiles of composer, the php package manager. It finds the licenses in the
+// license field, which is either an SPDX expression, or a list of them which
+// means that any one of them can be chosen. For a lockfile, it finds the
+// licenses of each of the packages that are locked in it, including the ones
+// which are only used for development.
+type Composer struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+}
+
+// String method returns the name of the backend.
+func (obj *Composer) String() string {
+	return "composer"
+}
+
+// ScanData method is used to extract license ids from data and return licenses
+// based on the license ids.
+func (obj *Composer) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
+	name := info.FileInfo.Name()
+	if name != ComposerManifestFilename && name != ComposerLockfileFilename {
+		return nil, nil // skip
+	}
+	if info.FileInfo.IsDir() {
+		return nil, nil // skip
+	}
+	if len(data) == 0 {
+		return nil, nil // skip
+	}
+
+	packages := []*ComposerPackage{}
+	if name == ComposerManifestFilename {
+		var manifest ComposerPackage
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			// There is a parse error with the file, so we can't
+			// properly examine it for licensing information.
+			result := &interfaces.Result{
+				Confidence: 1.0, // TODO: what should we put here?
+				Skip:       errwrap.Wrapf(err, "parse error"),
+			}
+			return result, nil
+		}
+		packages = append(packages, &manifest)
+	} else {
+		var lockfile ComposerLockfile
+		if err := json.Unmarshal(data, &lockfile); err != nil {
+			result := &interfaces.Result{
+				Confidence: 1.0, // TODO: what should we put here?
+				Skip:       errwrap.Wrapf(err, "parse error"),
+			}
+			return result, nil
+		}
+		packages = append(packages, lockfile.Packages...)
+		packages = append(packages, lockfile.PackagesDev...)
+	}
+
+	licenseMap := make(map[string]struct{})
+	clauses := [][]string{}
+	for _, x := range packages {
+		if x == nil {
+			continue
+		}
+		expression := x.LicenseExpression()
+		if expression == "" {
+			continue
+		}
+		ids := ExpressionIDs(expression)
+		for _, lid := range ids {
+			licenseMap[lid] = struct{}{}
+		}
+		clauses = append(clauses, ExpressionClauses(expression)...)
+		if obj.Debug && x.Name != "" {
+			obj.Logf("composer: %s: %s", x.Name, strings.Join(ids, ", "))
+		}
+	}
+
+	if len(licenseMap) == 0 {
+		// If we did not get any license names we return nil, nil.
+		return nil, nil
+	}
+
+	result := &interfaces.Result{
+		Licenses:   idsToLicenses(licenseMap),
+		Confidence: 1.0, // TODO: what should we put here?
+		Choices:    clausesToChoices(clauses),
+	}
+
+	return result, nil
+}
+
+// ComposerLockfile is a struct that helps store the packages in a composer.lock
+// file.
+type ComposerLockfile struct {
+	// Packages is the list of every package that is locked.
+	Packages []*ComposerPackage `json:"packages"`
+
+	// PackagesDev is the list of every package that is locked for the
+	// development of this one only.
+	PackagesDev []*ComposerPackage `json:"packages-dev"`
+}
+
+// ComposerPackage is a struct that helps store the license fields of a
+// composer.json file, or of a package in a lockfile.
+type ComposerPackage struct {
+	// Name is the name of the package, such as vendor/package.
+	Name string `json:"name"`
+
+	// License is the SPDX expression of the license of the package, or a
+	// list of them, of which any one can be chosen.
+	License json.RawMessage `json:"license"`
+}
+
+// LicenseExpression returns the license of this package as a single SPDX
+// expression. A list of licenses is joined with the OR operator, since that is
+// what it means. Invalid or unexpected values are ignored, since they can't
+// tell us anything, and if there are none, then this is empty.
+func (obj *ComposerPackage) LicenseExpression() string {
+	values := []string{}
+	if len(obj.License) > 0 {
+		var s string
+		var list []string
+		if err := json.Unmarshal(obj.License, &s); err == nil {
+			values = append(values, s)
+		} else if err := json.Unmarshal(obj.License, &list); err == nil {
+			values = append(values, list...)
+		}
+	}
+
+	expressions := []string{}
+	for _, x := range values {
+		if x = strings.TrimSpace(x); x == "" {
+			continue
+		}
+		expressions = append(expressions, x)
+	}
+	if len(expressions) == 1 {
+		return expressions[0]
+	}
+	for i, x := range expressions {
+		expressions[i] = "(" + x + ")"
+	}
+	return strings.Join(expressions, " OR ")
+}
@@ -0,0 +1,90 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend_test
+
+import (
+	"testing"
+
+	"github.com/awslabs/yesiscan/backend"
+	"github.com/awslabs/yesiscan/interfaces"
+)
+
+func TestComposer(t *testing.T) {
+	b := &backend.Composer{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf("backend: "+format, v...)
+		},
+	}
+
+	choices := func(result *interfaces.Result) [][]string {
+		if result == nil {
+			return nil
+		}
+		choices := [][]string{}
+		for _, x := range result.Choices {
+			ids := []string{}
+			for _, license := range x {
+				ids = append(ids, license.String())
+			}
+			choices = append(choices, ids)
+		}
+		return choices
+	}
+
+	lockfile := `{
+	"packages": [
+		{"name": "monolog/monolog", "license": ["MIT"]},
+		{"name": "a/dual", "license": ["LGPL-2.1-only", "GPL-3.0-or-later"]},
+		{"name": "b/none"}
+	],
+	"packages-dev": [
+		{"name": "phpunit/phpunit", "license": ["BSD-3-Clause"]}
+	]
+}`
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+		clauses  int // of the choices, if there are any
+	}{
+		{"composer.json", `{"license": "MIT"}`, "MIT", 0},
+		{"composer.json", `{"license": ["MIT", "GPL-2.0-only"]}`, "GPL-2.0-only, MIT", 1},
+		{"composer.json", `{"license": "(LGPL-2.1-only or GPL-3.0-or-later)"}`, "GPL-3.0-or-later, LGPL-2.1-only", 1},
+		{"composer.json", `{"license": "proprietary"}`, "proprietary(unknown)", 0},
+		{"composer.json", `{"name": "x/y"}`, "", 0},
+		{"composer.lock", lockfile, "BSD-3-Clause, GPL-3.0-or-later, LGPL-2.1-only, MIT", 3},
+		{"other.json", `{"license": "MIT"}`, "", 0},
+	}
+	for i, tc := range tests {
+		result := scanData(t, b, tc.name, tc.data)
+		if s := resultNames(result); s != tc.expected {
+			t.Errorf("test #%d (%s): expected %q, got: %q", i, tc.name, tc.expected, s)
+		}
+		if c := choices(result); len(c) != tc.clauses {
+			t.Errorf("test #%d (%s): expected %d clauses, got: %v", i, tc.name, tc.clauses, c)
+		}
+	}
+
+	testDataSkip(t, b, "composer.json", "{")
+}
@@ -0,0 +1,89 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+package backend_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+// dataTest is a single file which is passed to a data backend, and the joined
+// license names that we expect it to find in that file.
+type dataTest struct {
+	name     string
+	data     string
+	expected string
+}
+
+// scanData writes the data to a file with this name in a new temporary
+// directory, and then passes it to the data backend with a matching info.
+func scanData(t *testing.T, b interfaces.DataBackend, name, data string) *interfaces.Result {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, []byte(data), 0600); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	fileInfo, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	info := &interfaces.Info{FileInfo: fileInfo, UID: "file://" + filename}
+	result, err := b.ScanData(context.Background(), []byte(data), info)
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	return result
+}
+
+// resultNames returns the joined license names of a result, or the empty
+// string if there isn't one.
+func resultNames(result *interfaces.Result) string {
+	if result == nil {
+		return ""
+	}
+	return licenses.Join(result.Licenses)
+}
+
+// testDataBackend runs each of the tests against the data backend.
+func testDataBackend(t *testing.T, b interfaces.DataBackend, tests []dataTest) {
+	t.Helper()
+	for i, tc := range tests {
+		if s := resultNames(scanData(t, b, tc.name, tc.data)); s != tc.expected {
+			t.Errorf("test #%d (%s): expected %q, got: %q", i, tc.name, tc.expected, s)
+		}
+	}
+}
+
+// testDataSkip checks that the data backend skips a file it can't parse,
+// instead of erroring.
+func testDataSkip(t *testing.T, b interfaces.DataBackend, name, data string) {
+	t.Helper()
+	if result := scanData(t, b, name, data); result == nil || result.Skip == nil {
+		t.Errorf("expected a parse error to be skipped, got: %+v", result)
+	}
+}
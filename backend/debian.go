@@ -0,0 +1,502 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// TODO: should this be a subpackage?
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/util"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/licenses"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+const (
+	// DebianCopyrightPath is the path of the copyright file of a debian
+	// source package, relative to the top of the package.
+	DebianCopyrightPath = "debian/copyright"
+)
+
+var (
+	// debianLicenseIDs are the license short names from the debian copyright
+	// format spec which aren't the same as an SPDX ID. They are lower case,
+	// since the short names are case insensitive.
+	debianLicenseIDs = map[string]string{
+		"artistic": "Artistic-1.0",
+		"cc0":      "CC0-1.0",
+		"expat":    "MIT",
+		"perl":     "(Artistic-1.0-Perl OR GPL-1.0-or-later)",
+	}
+
+	// debianGNURegexp matches the short names of the GNU licenses, which
+	// have an optional version, and a plus for any later version.
+	debianGNURegexp = regexp.MustCompile(`(?i)^(AGPL|GPL|LGPL|GFDL)(?:-([0-9]+(?:\.[0-9]+)?))?(\+)?$`)
+
+	// debianGNUVersions are the first version of each of the GNU licenses,
+	// which is what a short name without a version starts from.
+	debianGNUVersions = map[string]string{
+		"AGPL": "1.0",
+		"GPL":  "1.0",
+		"LGPL": "2.0",
+		"GFDL": "1.1",
+	}
+)
+
+// Debian is a backend for the machine-readable debian/copyright files of debian
+// source packages, which are in the DEP-5 format. Each of the Files paragraphs
+// in there has a list of glob patterns and the license of the files that they
+// match, and the last paragraph that matches a file is the one that applies to
+// it. When the top of a scan is a source package, every file in it gets the
+// license of its paragraph. Otherwise, the copyright file is only scanned on its
+// own, and it gets every license that it mentions. The license short names are
+// converted to SPDX ID's, and the ones which don't have one, such as
+// public-domain, are returned as custom licenses.
+type Debian struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+}
+
+// String method returns the name of the backend.
+func (obj *Debian) String() string {
+	return "debian"
+}
+
+// Cacheable returns false, since the result for each file depends on where it
+// is in the package, and not on what is in it.
+func (obj *Debian) Cacheable() bool {
+	return false
+}
+
+// ScanData method is used to extract license ids from data and return licenses
+// based on the license ids.
+func (obj *Debian) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
+	if !strings.HasSuffix(info.UID, "/"+DebianCopyrightPath) {
+		return nil, nil // skip
+	}
+	if info.FileInfo.IsDir() {
+		return nil, nil // skip
+	}
+	if len(data) == 0 {
+		return nil, nil // skip
+	}
+
+	copyright, err := ParseDebianCopyright(data)
+	if err != nil {
+		// There is a parse error with the file, so we can't
+		// properly examine it for licensing information.
+		result := &interfaces.Result{
+			Confidence: 1.0, // TODO: what should we put here?
+			Skip:       errwrap.Wrapf(err, "parse error"),
+		}
+		return result, nil
+	}
+	if copyright == nil {
+		return nil, nil // not machine-readable
+	}
+
+	return copyright.Result(), nil
+}
+
+// ScanRoot finds the copyright file at the top of a debian source package, and
+// returns the license of the paragraph that matches each file in the package.
+// It declines if there isn't one, so that each path is scanned on its own.
+func (obj *Debian) ScanRoot(ctx context.Context, path safepath.Path, info *interfaces.Info) (interfaces.ResultSet, error) {
+	if !info.FileInfo.IsDir() {
+		return nil, nil // use ScanData
+	}
+	root := path.Path()
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(DebianCopyrightPath)))
+	if os.IsNotExist(err) {
+		return nil, nil // not a source package
+	}
+	if err != nil {
+		return nil, err
+	}
+	copyright, err := ParseDebianCopyright(data)
+	if err != nil || copyright == nil || len(copyright.Files) == 0 {
+		// ScanData reports the parse error on the copyright file.
+		return nil, nil
+	}
+	if obj.Debug {
+		obj.Logf("debian: %d files paragraphs in: %s", len(copyright.Files), root)
+	}
+
+	resultSet := make(interfaces.ResultSet)
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if d.IsDir() {
+			if util.StrInList(d.Name()+"/", iterator.SkipDirPaths) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		var result *interfaces.Result
+		if rel == DebianCopyrightPath {
+			result = copyright.Result()
+		} else if files := copyright.Match(rel); files != nil {
+			result = files.Result()
+		}
+		if result != nil {
+			resultSet[p] = map[interfaces.Backend]*interfaces.Result{
+				obj: result,
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resultSet, nil
+}
+
+// DebianCopyright is the licensing information in a machine-readable debian
+// copyright file.
+type DebianCopyright struct {
+	// License is the license of the whole package from the header
+	// paragraph, if there is one.
+	License string
+
+	// Files are the files paragraphs in the order that they appear.
+	Files []*DebianFiles
+}
+
+// Match returns the last files paragraph with a pattern that matches this path,
+// which is relative to the top of the package, or nil if none of them do.
+func (obj *DebianCopyright) Match(p string) *DebianFiles {
+	var found *DebianFiles
+	for _, x := range obj.Files {
+		if x.Match(p) {
+			found = x
+		}
+	}
+	return found
+}
+
+// Result returns a result with every license in the copyright file.
+func (obj *DebianCopyright) Result() *interfaces.Result {
+	expressions := []string{}
+	if obj.License != "" {
+		expressions = append(expressions, obj.License)
+	}
+	for _, x := range obj.Files {
+		expressions = append(expressions, x.License)
+	}
+	return debianResult(expressions)
+}
+
+// DebianFiles is one of the files paragraphs of a debian copyright file.
+type DebianFiles struct {
+	// Patterns are the glob patterns of the files that this is about. A *
+	// matches anything, including a slash, and a ? matches any one
+	// character. They are relative to the top of the package.
+	Patterns []string
+
+	// License is the license short name, or an expression of them, which
+	// is the first line of the license field.
+	License string
+
+	regexp *regexp.Regexp
+}
+
+// Init compiles the patterns before use.
+func (obj *DebianFiles) Init() error {
+	patterns := []string{}
+	for _, x := range obj.Patterns {
+		patterns = append(patterns, debianPatternRegexp(x))
+	}
+	r, err := regexp.Compile("^(?:" + strings.Join(patterns, "|") + ")$")
+	if err != nil {
+		return err
+	}
+	obj.regexp = r
+	return nil
+}
+
+// Match returns true if one of the patterns matches this path, which is relative
+// to the top of the package.
+func (obj *DebianFiles) Match(p string) bool {
+	return obj.regexp != nil && obj.regexp.MatchString(p)
+}
+
+// Result returns a result with the license of these files.
+func (obj *DebianFiles) Result() *interfaces.Result {
+	return debianResult([]string{obj.License})
+}
+
+// ParseDebianCopyright parses a machine-readable debian copyright file. It
+// returns nil if the file isn't machine-readable, since the older free-form ones
+// are very common, and those are left to the other backends.
+func ParseDebianCopyright(data []byte) (*DebianCopyright, error) {
+	// The free-form files aren't in the deb822 format at all, so check
+	// that the first field is the format before we try to parse them.
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(strings.ToLower(line), "format:") {
+			return nil, nil // not machine-readable
+		}
+		break
+	}
+
+	paragraphs, err := parseDebianParagraphs(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(paragraphs) == 0 {
+		return nil, nil
+	}
+
+	copyright := &DebianCopyright{
+		License: debianFirstLine(paragraphs[0]["license"]),
+	}
+	for _, x := range paragraphs[1:] {
+		files, exists := x["files"]
+		if !exists {
+			continue // a stand-alone license paragraph
+		}
+		paragraph := &DebianFiles{
+			Patterns: strings.Fields(files),
+			License:  debianFirstLine(x["license"]),
+		}
+		if len(paragraph.Patterns) == 0 || paragraph.License == "" {
+			return nil, fmt.Errorf("incomplete files paragraph: %s", files)
+		}
+		if err := paragraph.Init(); err != nil {
+			return nil, errwrap.Wrapf(err, "invalid files pattern: %s", files)
+		}
+		copyright.Files = append(copyright.Files, paragraph)
+	}
+	return copyright, nil
+}
+
+// parseDebianParagraphs splits a file in the deb822 format into its paragraphs.
+// Each one maps the lower case field names to their values. The continuation
+// lines of a field are joined with newlines.
+func parseDebianParagraphs(data []byte) ([]map[string]string, error) {
+	paragraphs := []map[string]string{}
+	var paragraph map[string]string
+	field := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.HasPrefix(line, "#") {
+			continue // comment
+		}
+		if line == "" {
+			paragraph = nil // the next one starts on the next field
+			field = ""
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if field == "" {
+				return nil, fmt.Errorf("unexpected continuation on line %d", i)
+			}
+			paragraph[field] += "\n" + strings.TrimSpace(line)
+			continue
+		}
+		ix := strings.Index(line, ":")
+		if ix <= 0 {
+			return nil, fmt.Errorf("invalid field on line %d", i)
+		}
+		if paragraph == nil {
+			paragraph = make(map[string]string)
+			paragraphs = append(paragraphs, paragraph)
+		}
+		field = strings.ToLower(line[:ix])
+		paragraph[field] = strings.TrimSpace(line[ix+1:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return paragraphs, nil
+}
+
+// debianFirstLine returns the first line of a field, which for the license field
+// is the short name, and the rest is the license text.
+func debianFirstLine(value string) string {
+	return strings.TrimSpace(strings.SplitN(value, "\n", 2)[0])
+}
+
+// debianPatternRegexp converts one of the glob patterns of a files paragraph to
+// a regular expression. A backslash escapes the next character.
+func debianPatternRegexp(pattern string) string {
+	s := ""
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			s += ".*"
+		case '?':
+			s += "."
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			s += regexp.QuoteMeta(string(pattern[i]))
+		default:
+			s += regexp.QuoteMeta(string(c))
+		}
+	}
+	return strings.TrimPrefix(s, `\./`) // some files start them with ./
+}
+
+// DebianExpression converts the license field of a debian copyright file to an
+// SPDX license expression. The short names are joined with a lower case "and"
+// or "or", and a comma separates the parts which bind the least, so that
+// "GPL-2+ or Artistic, and BSD-3-clause" is the same as "(GPL-2.0-or-later OR
+// Artistic-1.0) AND BSD-3-Clause". A "with X exception" is kept.
+func DebianExpression(license string) string {
+	expression := ""
+	for i, part := range strings.Split(license, ",") {
+		fields := strings.Fields(part)
+		op := "AND"
+		if i > 0 && len(fields) > 0 {
+			if s := strings.ToUpper(fields[0]); s == "AND" || s == "OR" {
+				op = s
+				fields = fields[1:]
+			}
+		}
+		terms := debianTerms(fields)
+		if terms == "" {
+			continue
+		}
+		if expression == "" {
+			expression = terms
+			continue
+		}
+		expression = "(" + expression + ") " + op + " (" + terms + ")"
+	}
+	return expression
+}
+
+// debianTerms converts the fields of a license field without any commas to an
+// SPDX license expression.
+func debianTerms(fields []string) string {
+	terms := []string{}
+	for i := 0; i < len(fields); i++ {
+		switch s := strings.ToLower(fields[i]); s {
+		case "and", "or":
+			terms = append(terms, strings.ToUpper(s))
+		case "with":
+			// the exception name runs until the word exception
+			name := []string{}
+			for i++; i < len(fields) && !strings.EqualFold(fields[i], "exception"); i++ {
+				name = append(name, fields[i])
+			}
+			if len(name) > 0 {
+				terms = append(terms, "WITH", strings.Join(name, "-")+"-exception")
+			}
+		default:
+			terms = append(terms, DebianLicenseID(fields[i]))
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// DebianLicenseID converts a license short name from a debian copyright file to
+// its SPDX ID. The names which don't have one are returned as they are.
+func DebianLicenseID(name string) string {
+	if id, exists := debianLicenseIDs[strings.ToLower(name)]; exists {
+		return id
+	}
+	if m := debianGNURegexp.FindStringSubmatch(name); m != nil {
+		family := strings.ToUpper(m[1])
+		version := m[2]
+		suffix := "-only"
+		if version == "" {
+			version = debianGNUVersions[family]
+			suffix = "-or-later" // any version
+		}
+		if !strings.Contains(version, ".") {
+			version += ".0"
+		}
+		if m[3] != "" {
+			suffix = "-or-later"
+		}
+		return family + "-" + version + suffix
+	}
+
+	// The spec has versions like Apache-2 which are Apache-2.0 in SPDX.
+	base := strings.TrimSuffix(name, "+")
+	for _, x := range []string{base, base + ".0"} {
+		for _, license := range licenses.LicenseList.Licenses {
+			if strings.EqualFold(x, license.LicenseID) {
+				return license.LicenseID
+			}
+		}
+	}
+	return name
+}
+
+// debianResult returns a result with the licenses in these license fields, or
+// nil if there aren't any.
+func debianResult(fields []string) *interfaces.Result {
+	licenseMap := make(map[string]struct{})
+	clauses := [][]string{}
+	for _, x := range fields {
+		expression := DebianExpression(x)
+		if expression == "" {
+			continue
+		}
+		for _, lid := range ExpressionIDs(expression) {
+			licenseMap[lid] = struct{}{}
+		}
+		clauses = append(clauses, ExpressionClauses(expression)...)
+	}
+	if len(licenseMap) == 0 {
+		return nil
+	}
+
+	return &interfaces.Result{
+		Licenses:   idsToLicenses(licenseMap),
+		Confidence: 1.0, // TODO: what should we put here?
+		Choices:    clausesToChoices(clauses),
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/yesiscan/backend"
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/licenses"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+const debianCopyright = `Format: https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/
+Upstream-Name: example
+Source: https://example.com/example
+
+Files: *
+Copyright: 2020 Example Authors
+License: GPL-2+ or BSD-3-clause
+ This program is free software.
+ .
+ On Debian systems, the full text is in /usr/share/common-licenses.
+
+Files: src/vendor/*
+ src/third\_party/lib?.c
+Copyright: 2019 Someone Else
+License: Expat
+
+Files: debian/*
+Copyright: 2021 A Maintainer
+License: public-domain
+
+License: BSD-3-clause
+ Redistribution and use in source and binary forms, with or without
+ modification, are permitted.
+`
+
+func TestDebianExpression(t *testing.T) {
+	tests := []struct {
+		license  string
+		expected string
+	}{
+		{"Expat", "MIT"},
+		{"GPL-2", "GPL-2.0-only"},
+		{"GPL-2+", "GPL-2.0-or-later"},
+		{"lgpl-2.1+", "LGPL-2.1-or-later"},
+		{"GPL", "GPL-1.0-or-later"},
+		{"Apache-2", "Apache-2.0"},
+		{"BSD-3-clause", "BSD-3-Clause"},
+		{"public-domain", "public-domain"},
+		{"GPL-2+ or Artistic", "GPL-2.0-or-later OR Artistic-1.0"},
+		{"GPL-2+ or Artistic, and BSD-3-clause", "(GPL-2.0-or-later OR Artistic-1.0) AND (BSD-3-Clause)"},
+		{"GPL-2+ with OpenSSL exception", "GPL-2.0-or-later WITH OpenSSL-exception"},
+	}
+	for i, tc := range tests {
+		if s := backend.DebianExpression(tc.license); s != tc.expected {
+			t.Errorf("test #%d (%s): expected %q, got: %q", i, tc.license, tc.expected, s)
+		}
+	}
+}
+
+func TestDebian(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		backend.DebianCopyrightPath: debianCopyright,
+		"debian/rules":              "#!/usr/bin/make -f\n",
+		"main.c":                    "int main() {}\n",
+		"src/vendor/x/y.c":          "int y;\n",
+		"src/third_party/lib1.c":    "int z;\n",
+		"src/third_party/lib10.c":   "int z;\n",
+	}
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+		if err := os.WriteFile(p, []byte(data), 0600); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+	}
+
+	b := &backend.Debian{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf("backend: "+format, v...)
+		},
+	}
+	fileInfo, err := os.Stat(dir)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	root, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	info := &interfaces.Info{FileInfo: fileInfo, UID: "file://" + dir + "/", Root: true}
+	resultSet, err := b.ScanRoot(context.Background(), root, info)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	expected := map[string]string{
+		backend.DebianCopyrightPath: "BSD-3-Clause, GPL-2.0-or-later, MIT, public-domain(unknown)",
+		"debian/rules":              "public-domain(unknown)",
+		"main.c":                    "BSD-3-Clause, GPL-2.0-or-later",
+		"src/vendor/x/y.c":          "MIT",
+		"src/third_party/lib1.c":    "MIT",
+		"src/third_party/lib10.c":   "BSD-3-Clause, GPL-2.0-or-later", // ? is one character
+	}
+	for name, s := range expected {
+		m, exists := resultSet[filepath.Join(dir, filepath.FromSlash(name))]
+		if !exists {
+			t.Errorf("expected a result for: %s", name)
+			continue
+		}
+		if x := licenses.Join(m[b].Licenses); x != s {
+			t.Errorf("%s: expected %q, got: %q", name, s, x)
+		}
+	}
+
+	// the copyright file on its own
+	p := filepath.Join(dir, filepath.FromSlash(backend.DebianCopyrightPath))
+	fileInfo, err = os.Stat(p)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	info = &interfaces.Info{FileInfo: fileInfo, UID: "file://" + p}
+	result, err := b.ScanData(context.Background(), []byte(debianCopyright), info)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if s := licenses.Join(result.Licenses); s != expected[backend.DebianCopyrightPath] {
+		t.Errorf("expected %q, got: %q", expected[backend.DebianCopyrightPath], s)
+	}
+	if result, _ := b.ScanData(context.Background(), []byte("This package was debianized by someone.\n"), info); result != nil {
+		t.Errorf("expected a free-form copyright file to be skipped")
+	}
+}
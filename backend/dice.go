@@ -0,0 +1,392 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// TODO: should this be a subpackage?
+package backend
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+const (
+	// DiceDefaultThreshold is the lowest score from zero to one that a
+	// match must have to be reported, if no other threshold is set.
+	DiceDefaultThreshold = 0.8
+
+	// DiceMaxBytes is the largest file that we compare to the licenses.
+	// Anything bigger is almost certainly not a license, and would take a
+	// long time to look at.
+	DiceMaxBytes = 1024 * 1024 * 1 // 1 MiB
+
+	// diceContainment is the least fraction of the bigrams of a license
+	// which must be found somewhere in a file, before we bother to look
+	// for the part of that file which the license is in.
+	diceContainment = 0.5
+)
+
+// diceBigram is a pair of adjacent words.
+type diceBigram [2]string
+
+// diceTemplate is a license text which has been broken down into its bigrams.
+type diceTemplate struct {
+	id     string
+	text   string
+	counts map[diceBigram]int
+	total  int
+}
+
+// Dice is a pure golang backend which compares each file to the text of every
+// license in the embedded SPDX license list, using the Sørensen–Dice coefficient
+// of their bigrams of words, which is the same thing that askalono does. Since
+// there's nothing to install, it's a reasonable default for when askalono isn't
+// available. The part of a file which best matches a license is found first, so
+// that a license header at the top of a source file is still recognized. Only
+// the best match in each file is reported.
+// See: https://en.wikipedia.org/wiki/S%C3%B8rensen%E2%80%93Dice_coefficient
+type Dice struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	// Threshold is the lowest score from zero to one that a match must have
+	// to be reported. If it is zero, then the DiceDefaultThreshold is used.
+	Threshold float64
+
+	// Texts maps SPDX ID's to the license texts to compare with. If it is
+	// nil, then every license in the embedded SPDX license list which isn't
+	// deprecated is used.
+	Texts map[string]string
+
+	templates []*diceTemplate
+	version   string
+}
+
+func (obj *Dice) String() string {
+	return "dice"
+}
+
+// Setup breaks down each of the license texts into bigrams, once, so that this
+// isn't repeated for each file.
+func (obj *Dice) Setup(ctx context.Context) error {
+	if obj.Threshold < 0.0 || obj.Threshold > 1.0 {
+		return fmt.Errorf("dice threshold must be between 0 and 1")
+	}
+	if obj.templates != nil {
+		return nil // already done
+	}
+
+	texts := obj.Texts
+	obj.version = "custom"
+	if texts == nil {
+		texts = make(map[string]string)
+		for _, x := range licenses.LicenseList.Licenses {
+			if x.IsDeprecated {
+				continue
+			}
+			texts[x.LicenseID] = x.Text
+		}
+		obj.version = licenses.LicenseList.Version
+	}
+	if len(texts) == 0 {
+		return fmt.Errorf("there are no license texts")
+	}
+
+	ids := []string{}
+	for id := range texts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids) // so that a tie always goes the same way
+	templates := []*diceTemplate{}
+	for _, id := range ids {
+		select {
+		case <-ctx.Done():
+			return errwrap.Wrapf(ctx.Err(), "setup ended early")
+		default:
+		}
+		counts, total := diceCount(diceBigrams(diceWords(texts[id])))
+		if total == 0 {
+			continue // nothing to compare with
+		}
+		templates = append(templates, &diceTemplate{
+			id:     id,
+			text:   texts[id],
+			counts: counts,
+			total:  total,
+		})
+	}
+	obj.templates = templates
+	obj.Logf("loaded %d license texts", len(templates))
+	return nil
+}
+
+// Version changes whenever the license texts or the threshold do.
+func (obj *Dice) Version() string {
+	return fmt.Sprintf("%s\nthreshold: %v", obj.version, obj.threshold())
+}
+
+// threshold returns the threshold to use.
+func (obj *Dice) threshold() float64 {
+	if obj.Threshold == 0.0 {
+		return DiceDefaultThreshold
+	}
+	return obj.Threshold
+}
+
+func (obj *Dice) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
+	if info.FileInfo.IsDir() {
+		return nil, nil // skip
+	}
+	return obj.scanText(ctx, data)
+}
+
+// scanText finds the license which best matches the data. It's the part of
+// ScanData which doesn't need a file, so that the other backends can use it to
+// identify a license text that they found on their own.
+func (obj *Dice) scanText(ctx context.Context, data []byte) (*interfaces.Result, error) {
+	if len(data) == 0 || len(data) > DiceMaxBytes {
+		return nil, nil // skip
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return nil, nil // binary files aren't licenses
+	}
+
+	lines := diceLines(string(data))
+	bigrams := []diceBigram{}
+	lineOf := []int{} // the line that each bigram starts on
+	words, wordLines := []string{}, []int{}
+	for i, line := range lines {
+		for _, w := range line {
+			words = append(words, w)
+			wordLines = append(wordLines, i)
+		}
+	}
+	for i := 0; i+1 < len(words); i++ {
+		bigrams = append(bigrams, diceBigram{words[i], words[i+1]})
+		lineOf = append(lineOf, wordLines[i])
+	}
+	if len(bigrams) == 0 {
+		return nil, nil
+	}
+	counts, _ := diceCount(bigrams)
+
+	var best *diceTemplate
+	score := 0.0
+	lo, hi := 0, len(bigrams) // the best range of bigrams
+	for _, t := range obj.templates {
+		select {
+		case <-ctx.Done():
+			return nil, errwrap.Wrapf(ctx.Err(), "scanner ended early")
+		default:
+		}
+
+		inter := diceIntersection(counts, t.counts)
+		if float64(inter)/float64(t.total) < diceContainment {
+			continue // the license isn't in here
+		}
+		s, i, j := diceOptimize(bigrams, lineOf, t)
+		if s > score {
+			best, score, lo, hi = t, s, i, j
+		}
+	}
+
+	if best == nil || score < obj.threshold() {
+		return nil, nil
+	}
+	if obj.Debug {
+		obj.Logf("match: %s (%.4f)", best.id, score)
+	}
+
+	result := &interfaces.Result{
+		Licenses: []*licenses.License{
+			{
+				SPDX: best.id,
+			},
+		},
+		Confidence: score,
+	}
+	if score < 1.0 { // show what's different about a near match
+		// the last word of the last bigram is the one after it
+		found := strings.Split(string(data), "\n")[wordLines[lo] : wordLines[hi]+1]
+		result.Diff = licenses.WordDiff(diceText(best.text), diceText(strings.Join(found, "\n")))
+	}
+	return result, nil
+}
+
+// diceText returns the text without its copyright lines, since they're ignored
+// when comparing, and would only clutter up a diff.
+func diceText(s string) string {
+	lines := []string{}
+	for _, line := range strings.Split(s, "\n") {
+		words := diceLineWords(line)
+		if len(words) > 0 && diceCopyright(words[0], line) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// diceLines splits the text into the normalized words of each line. Copyright
+// lines have no words, so that they never count.
+func diceLines(s string) [][]string {
+	lines := [][]string{}
+	for _, line := range strings.Split(s, "\n") {
+		words := diceLineWords(line)
+		if len(words) > 0 && diceCopyright(words[0], line) {
+			words = nil
+		}
+		lines = append(lines, words)
+	}
+	return lines
+}
+
+// diceLineWords returns the normalized words of a single line.
+func diceLineWords(line string) []string {
+	return strings.FieldsFunc(strings.ToLower(line), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '©'
+	})
+}
+
+// diceCopyright returns true if this is a copyright line, which is different in
+// each file, and so would only get in the way of the comparison. The first word
+// of a line that starts with (c) is just the c.
+func diceCopyright(first, line string) bool {
+	if first == "copyright" || first == "©" {
+		return true
+	}
+	return first == "c" && strings.HasPrefix(strings.TrimLeftFunc(strings.ToLower(line), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '('
+	}), "(c)")
+}
+
+// diceWords returns the normalized words of the whole text.
+func diceWords(s string) []string {
+	words := []string{}
+	for _, line := range diceLines(s) {
+		words = append(words, line...)
+	}
+	return words
+}
+
+// diceBigrams returns each pair of adjacent words.
+func diceBigrams(words []string) []diceBigram {
+	bigrams := []diceBigram{}
+	for i := 0; i+1 < len(words); i++ {
+		bigrams = append(bigrams, diceBigram{words[i], words[i+1]})
+	}
+	return bigrams
+}
+
+// diceCount returns how many times each bigram appears, and the total.
+func diceCount(bigrams []diceBigram) (map[diceBigram]int, int) {
+	counts := make(map[diceBigram]int)
+	for _, x := range bigrams {
+		counts[x]++
+	}
+	return counts, len(bigrams)
+}
+
+// diceIntersection returns the size of the intersection of the two multisets.
+func diceIntersection(a, b map[diceBigram]int) int {
+	if len(a) > len(b) {
+		a, b = b, a // iterate over the smaller one
+	}
+	n := 0
+	for k, v := range a {
+		if w := b[k]; w < v {
+			n += w
+		} else {
+			n += v
+		}
+	}
+	return n
+}
+
+// diceOptimize returns the best score of the template against any range of
+// whole lines of the file. It starts with the whole file, and then removes
+// lines from the top and then from the bottom for as long as that helps. Each
+// step only looks at the bigrams that are removed, so this is fast even for a
+// large file. It also returns the range of bigrams that had the best score.
+func diceOptimize(bigrams []diceBigram, lineOf []int, t *diceTemplate) (float64, int, int) {
+	counts, total := diceCount(bigrams)
+	inter := diceIntersection(counts, t.counts)
+	dice := func(inter, total int) float64 {
+		return 2.0 * float64(inter) / float64(total+t.total)
+	}
+
+	// remove takes out the bigrams in [i, j) and returns the new values.
+	remove := func(i, j int, counts map[diceBigram]int, inter, total int) (int, int) {
+		for k := i; k < j; k++ {
+			x := bigrams[k]
+			if counts[x] <= t.counts[x] {
+				inter-- // this one was a match
+			}
+			counts[x]--
+			total--
+		}
+		return inter, total
+	}
+	// restore puts back the bigrams in [i, j).
+	restore := func(i, j int, counts map[diceBigram]int) {
+		for k := i; k < j; k++ {
+			counts[bigrams[k]]++
+		}
+	}
+
+	lo, hi := 0, len(bigrams)
+	score := dice(inter, total)
+	for lo < hi { // from the top
+		j := lo
+		for j < hi && lineOf[j] == lineOf[lo] {
+			j++
+		}
+		i2, t2 := remove(lo, j, counts, inter, total)
+		if s := dice(i2, t2); s >= score && t2 > 0 {
+			lo, inter, total, score = j, i2, t2, s
+			continue
+		}
+		restore(lo, j, counts)
+		break
+	}
+	for lo < hi { // from the bottom
+		i := hi - 1
+		for i > lo && lineOf[i-1] == lineOf[hi-1] {
+			i--
+		}
+		i2, t2 := remove(i, hi, counts, inter, total)
+		if s := dice(i2, t2); s >= score && t2 > 0 {
+			hi, inter, total, score = i, i2, t2, s
+			continue
+		}
+		break
+	}
+	return score, lo, hi
+}
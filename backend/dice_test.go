@@ -0,0 +1,158 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/backend"
+	"github.com/awslabs/yesiscan/interfaces"
+)
+
+const diceMIT = `MIT License
+
+Copyright (c) <year> <copyright holders>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+`
+
+const diceISC = `ISC License
+
+Copyright (c) <year> <copyright holders>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+`
+
+// comment turns the text into a comment at the top of a source file.
+func comment(text string) string {
+	s := ""
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		s += strings.TrimSpace("// "+line) + "\n"
+	}
+	return s
+}
+
+func TestDice(t *testing.T) {
+	b := &backend.Dice{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf("backend: "+format, v...)
+		},
+		Texts: map[string]string{
+			"MIT": diceMIT,
+			"ISC": diceISC,
+		},
+	}
+	if err := b.Setup(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	code := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n"
+	mit := strings.Replace(diceMIT, "<year> <copyright holders>", "2022 Jane Doe", 1)
+	restricted := strings.Replace(mit, "without restriction", "for non-commercial purposes only", 1)
+	tests := []struct {
+		name string
+		data string
+		exp  string // empty for no result
+		diff string // expected part of the diff, empty for an exact match
+	}{
+		{"LICENSE", mit, "MIT", ""},
+		{"LICENSE.isc", diceISC, "ISC", ""},
+		{"main.go", comment(mit) + "\n" + code, "MIT", ""},
+		{"reflowed", strings.Join(strings.Fields(mit), " "), "MIT", "{+Copyright (c) 2022 Jane Doe+}"},
+		{"code.go", code, "", ""},
+		{"half", diceMIT[0 : len(diceMIT)/2], "", ""},
+		{"restricted.go", comment(restricted) + "\n" + code, "MIT", "[-without restriction,-] {+for non-commercial purposes only,+}"},
+	}
+
+	dir := t.TempDir()
+	for _, x := range tests {
+		filename := filepath.Join(dir, "file")
+		if err := os.WriteFile(filename, []byte(x.data), 0600); err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		fileInfo, err := os.Stat(filename)
+		if err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		info := &interfaces.Info{FileInfo: fileInfo, UID: "file://" + filename}
+		result, err := b.ScanData(context.Background(), []byte(x.data), info)
+		if err != nil {
+			t.Errorf("%s: error: %+v", x.name, err)
+			continue
+		}
+		if x.exp == "" {
+			if result != nil {
+				t.Errorf("%s: expected no result, got: %s", x.name, result.Licenses[0])
+			}
+			continue
+		}
+		if result == nil || len(result.Licenses) != 1 || result.Licenses[0].SPDX != x.exp {
+			t.Errorf("%s: expected %s, got: %+v", x.name, x.exp, result)
+			continue
+		}
+		if result.Confidence < backend.DiceDefaultThreshold || result.Confidence > 1.0 {
+			t.Errorf("%s: unexpected confidence: %f", x.name, result.Confidence)
+		}
+		if !strings.Contains(result.Diff, x.diff) || (result.Diff == "") != (x.diff == "") {
+			t.Errorf("%s: expected a diff with %q, got: %s", x.name, x.diff, result.Diff)
+		}
+		t.Logf("%s: %s (%.4f)", x.name, result.Licenses[0], result.Confidence)
+	}
+
+	// the embedded license list always loads
+	if err := (&backend.Dice{Logf: t.Logf}).Setup(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+	}
+}
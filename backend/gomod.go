@@ -0,0 +1,568 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// TODO: should this be a subpackage?
+package backend
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+const (
+	// GomodManifestFilename is the file name used by the golang module
+	// manifests.
+	GomodManifestFilename = "go.mod"
+
+	// GomodChecksumFilename is the file name used by the golang module
+	// checksum files, which list every module that the build needs.
+	GomodChecksumFilename = "go.sum"
+
+	// GomodLicenseFileOrigin is the origin of the custom licenses that we
+	// return for the license files of modules which aren't a license that
+	// we know.
+	GomodLicenseFileOrigin = "pkg.go.dev"
+
+	// GomodMaxZipSize is the largest module zip file that we'll download
+	// from the proxy. It's the same limit that the go command has.
+	GomodMaxZipSize = 500 * 1024 * 1024
+)
+
+var (
+	// gomodLicenseRegexp matches the names of the license files that are
+	// looked for in the top directory of each module. It's similar to the
+	// list that pkg.go.dev uses.
+	gomodLicenseRegexp = regexp.MustCompile(`(?i)^((un)?licen[cs]e|copying)([-._].*)?$`)
+)
+
+// Gomod is a backend for the go.mod manifests and the go.sum checksum files of
+// golang modules. Neither of them record any licenses, so like go-licenses, it
+// looks for the license files in the top directory of each module that they
+// list, and identifies them with the same method as the Dice backend. These
+// are found in the local module cache, where the go command put them when the
+// project was built. If a module proxy is set, then the modules which aren't
+// in the cache are downloaded from it instead. Otherwise they're skipped, and
+// the result says so, so build the project first for the best results. The
+// modules that are replaced with a local directory are skipped, since those
+// are scanned on their own.
+type Gomod struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	// ModCache is the module cache directory that the modules are looked
+	// up in. If it is empty, then $GOMODCACHE is used, or the pkg/mod
+	// directory in $GOPATH, or in ~/go, the same as the go command does.
+	ModCache string
+
+	// Proxy is the url of the module proxy to download the modules which
+	// aren't in the module cache from, such as https://proxy.golang.org.
+	// If it is empty, then nothing is downloaded.
+	Proxy string
+
+	// Offline forbids downloading from the Proxy, so that only the module
+	// cache is used. The modules which aren't in it are skipped.
+	Offline bool
+
+	// Texts maps SPDX ID's to the license texts that the license files are
+	// compared with. If it is nil, then the same ones as the Dice backend
+	// uses are used.
+	Texts map[string]string
+
+	dice    *Dice
+	mutex   *sync.Mutex
+	fetched map[string]*gomodFetched // module@version -> files
+}
+
+// gomodFetched is what was downloaded from the proxy for one module.
+type gomodFetched struct {
+	files map[string][]byte
+	err   error
+}
+
+// GomodModule is a single version of a module.
+type GomodModule struct {
+	// Path is the module path, such as github.com/awslabs/yesiscan.
+	Path string
+
+	// Version is the semantic version, such as v1.2.3.
+	Version string
+}
+
+// String returns the module in the usual path@version form.
+func (obj *GomodModule) String() string {
+	return obj.Path + "@" + obj.Version
+}
+
+// String method returns the name of the backend.
+func (obj *Gomod) String() string {
+	return "gomod"
+}
+
+// Setup loads the license texts that the license files are compared to.
+func (obj *Gomod) Setup(ctx context.Context) error {
+	if obj.Proxy != "" {
+		u, err := url.Parse(obj.Proxy)
+		if err != nil {
+			return errwrap.Wrapf(err, "invalid module proxy")
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid module proxy scheme: %s", u.Scheme)
+		}
+	}
+	if obj.dice != nil {
+		return nil // already done
+	}
+	obj.mutex = &sync.Mutex{}
+	obj.fetched = make(map[string]*gomodFetched)
+	dice := &Dice{
+		Debug: obj.Debug,
+		Logf:  obj.Logf,
+		Texts: obj.Texts,
+	}
+	if err := dice.Setup(ctx); err != nil {
+		return err
+	}
+	obj.dice = dice
+	return nil
+}
+
+// Version changes whenever the license texts or the module proxy do.
+func (obj *Gomod) Version() string {
+	s := fmt.Sprintf("%s\nproxy: %s", obj.dice.Version(), obj.Proxy)
+	if obj.Offline {
+		s += "\noffline" // the modules that would be downloaded are skipped
+	}
+	return s
+}
+
+// ScanData method is used to extract license ids from data and return licenses
+// based on the license ids.
+func (obj *Gomod) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
+	name := info.FileInfo.Name()
+	if name != GomodManifestFilename && name != GomodChecksumFilename {
+		return nil, nil // skip
+	}
+	if info.FileInfo.IsDir() {
+		return nil, nil // skip
+	}
+	if len(data) == 0 {
+		return nil, nil // skip
+	}
+
+	parse := GomodRequires
+	if name == GomodChecksumFilename {
+		parse = GomodSums
+	}
+	modules, err := parse(data)
+	if err != nil {
+		// There is a parse error with the file, so we can't properly
+		// examine it for licensing information.
+		result := &interfaces.Result{
+			Confidence: 1.0, // TODO: what should we put here?
+			Skip:       errwrap.Wrapf(err, "parse error"),
+		}
+		return result, nil
+	}
+
+	licenseMap := make(map[string]struct{})
+	custom := make(map[string]struct{})
+	confidence := 1.0
+	missing, unlicensed := 0, 0
+	for _, m := range modules {
+		files, err := obj.licenseFiles(ctx, m)
+		if err := ctx.Err(); err != nil {
+			return nil, errwrap.Wrapf(err, "scanner ended early")
+		}
+		if err != nil {
+			if obj.Debug {
+				obj.Logf("gomod: %s: %+v", m.String(), err)
+			}
+			missing++
+			continue
+		}
+		if len(files) == 0 {
+			if obj.Debug {
+				obj.Logf("gomod: %s: no license file", m.String())
+			}
+			unlicensed++
+			continue
+		}
+
+		names := []string{}
+		for x := range files {
+			names = append(names, x)
+		}
+		sort.Strings(names) // deterministic order
+		for _, x := range names {
+			result, err := obj.dice.scanText(ctx, files[x])
+			if err != nil {
+				return nil, err
+			}
+			if result == nil {
+				// a custom license, or one we don't know
+				custom[m.Path+"/"+x] = struct{}{}
+				continue
+			}
+			for _, license := range result.Licenses {
+				licenseMap[license.SPDX] = struct{}{}
+			}
+			confidence = math.Min(confidence, result.Confidence)
+			if obj.Debug {
+				obj.Logf("gomod: %s: %s: %s", m.String(), x, licenses.Join(result.Licenses))
+			}
+		}
+	}
+
+	var skip error
+	if missing > 0 {
+		where := "are not in the module cache, build first to fetch them"
+		if obj.Proxy != "" && !obj.Offline {
+			where = "could not be downloaded from the module proxy"
+		}
+		skip = fmt.Errorf("%d of %d modules %s", missing, len(modules), where)
+		obj.Logf("gomod: %s: %s", info.UID, skip.Error())
+	}
+	if unlicensed > 0 {
+		obj.Logf("gomod: %s: %d modules don't have a license file", info.UID, unlicensed)
+	}
+
+	if len(licenseMap) == 0 && len(custom) == 0 && skip == nil {
+		// If we did not find any licenses we return nil, nil.
+		return nil, nil
+	}
+
+	files := []string{}
+	for x := range custom {
+		files = append(files, x)
+	}
+	sort.Strings(files) // deterministic order
+
+	licenseList := idsToLicenses(licenseMap)
+	for _, x := range files {
+		license := &licenses.License{
+			Origin: GomodLicenseFileOrigin,
+			Custom: x,
+		}
+		licenseList = append(licenseList, license)
+	}
+
+	result := &interfaces.Result{
+		Licenses:   licenseList,
+		Confidence: confidence,
+		Skip:       skip,
+	}
+
+	return result, nil
+}
+
+// modCache returns the module cache directory to look in.
+func (obj *Gomod) modCache() string {
+	if obj.ModCache != "" {
+		return obj.ModCache
+	}
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	gopath := filepath.SplitList(os.Getenv("GOPATH"))
+	if len(gopath) > 0 && gopath[0] != "" {
+		return filepath.Join(gopath[0], "pkg", "mod")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, "go", "pkg", "mod")
+}
+
+// licenseFiles returns the contents of the license files in the top directory
+// of a module, keyed by their names. It looks in the module cache first, and
+// then downloads the module from the proxy if there is one.
+func (obj *Gomod) licenseFiles(ctx context.Context, m *GomodModule) (map[string][]byte, error) {
+	dir := ""
+	if cache := obj.modCache(); cache != "" {
+		dir = filepath.Join(cache, GomodEscape(m.Path)+"@"+GomodEscape(m.Version))
+	}
+	entries, err := os.ReadDir(dir)
+	if dir == "" || os.IsNotExist(err) {
+		if obj.Proxy == "" || obj.Offline {
+			return nil, os.ErrNotExist
+		}
+		return obj.fetch(ctx, m)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+	for _, x := range entries {
+		if !x.Type().IsRegular() || !gomodLicenseRegexp.MatchString(x.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, x.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[x.Name()] = data
+	}
+	return files, nil
+}
+
+// fetch downloads the zip file of a module from the proxy and returns the
+// license files in its top directory. Each module is only downloaded once,
+// since the go.mod and the go.sum usually list the same ones.
+func (obj *Gomod) fetch(ctx context.Context, m *GomodModule) (map[string][]byte, error) {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock() // one at a time is polite to the proxy
+	if x, exists := obj.fetched[m.String()]; exists {
+		return x.files, x.err
+	}
+	files, err := obj.download(ctx, m)
+	if ctx.Err() == nil { // don't remember a cancellation
+		obj.fetched[m.String()] = &gomodFetched{
+			files: files,
+			err:   err,
+		}
+	}
+	return files, err
+}
+
+// download does the actual work of fetch.
+func (obj *Gomod) download(ctx context.Context, m *GomodModule) (map[string][]byte, error) {
+	u := fmt.Sprintf("%s/%s/@v/%s.zip", strings.TrimRight(obj.Proxy, "/"), GomodEscape(m.Path), GomodEscape(m.Version))
+	if obj.Debug {
+		obj.Logf("gomod: downloading %s", u)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "error building request for %s", u)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "error do-ing request for %s", u)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status code of: %d", resp.StatusCode)
+	}
+
+	// the zip reader needs to seek, and these can be big, so use a file
+	f, err := os.CreateTemp("", "yesiscan-gomod-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	size, err := io.Copy(f, io.LimitReader(resp.Body, GomodMaxZipSize+1))
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "error downloading %s", u)
+	}
+	if size > GomodMaxZipSize {
+		return nil, fmt.Errorf("module zip is too big: %s", u)
+	}
+	z, err := zip.NewReader(f, size)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "error reading zip from %s", u)
+	}
+
+	prefix := m.String() + "/" // every file in a module zip is in here
+	files := make(map[string][]byte)
+	for _, x := range z.File {
+		name := strings.TrimPrefix(x.Name, prefix)
+		if name == x.Name || strings.Contains(name, "/") || !gomodLicenseRegexp.MatchString(name) {
+			continue
+		}
+		if x.UncompressedSize64 > DiceMaxBytes {
+			continue // not a license text
+		}
+		r, err := x.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[name] = data
+	}
+	return files, nil
+}
+
+// GomodEscape escapes a module path or version the way that the module cache
+// and the module proxy protocol do, so that it's safe on case-insensitive file
+// systems. Each upper case letter is replaced with an exclamation mark that is
+// followed by the lower case letter.
+func GomodEscape(s string) string {
+	b := &strings.Builder{}
+	for _, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteRune('!')
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// GomodRequires returns the modules that a go.mod file requires, sorted and
+// without any duplicates. The replace directives are applied, and the modules
+// that are replaced with a local directory are left out.
+func GomodRequires(data []byte) ([]*GomodModule, error) {
+	requires := []*GomodModule{}
+	replaces := make(map[string]*GomodModule) // path or path@version -> new
+	block := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i] // remove the comments
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if block != "" && fields[0] == ")" {
+			block = ""
+			continue
+		}
+		verb := block
+		if verb == "" {
+			verb = fields[0]
+			fields = fields[1:]
+			if len(fields) == 1 && fields[0] == "(" {
+				block = verb
+				continue
+			}
+		}
+		for i, x := range fields {
+			if s, err := strconv.Unquote(x); err == nil {
+				fields[i] = s
+			}
+		}
+
+		switch verb {
+		case "require":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("invalid require on line %d", n)
+			}
+			requires = append(requires, &GomodModule{Path: fields[0], Version: fields[1]})
+
+		case "replace":
+			i := -1 // where the arrow is
+			for j, x := range fields {
+				if x == "=>" {
+					i = j
+				}
+			}
+			if i < 1 || i > 2 || len(fields)-i < 2 || len(fields)-i > 3 {
+				return nil, fmt.Errorf("invalid replace on line %d", n)
+			}
+			old := fields[0]
+			if i == 2 {
+				old += "@" + fields[1]
+			}
+			var m *GomodModule // nil means a local directory
+			if len(fields)-i == 3 {
+				m = &GomodModule{Path: fields[i+1], Version: fields[i+2]}
+			}
+			replaces[old] = m
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	modules := []*GomodModule{}
+	for _, m := range requires {
+		r, exists := replaces[m.String()]
+		if !exists {
+			r, exists = replaces[m.Path]
+		}
+		if exists && r == nil {
+			continue // scanned on its own
+		}
+		if exists {
+			m = r
+		}
+		modules = append(modules, m)
+	}
+	return gomodUnique(modules), nil
+}
+
+// GomodSums returns the modules that a go.sum file has the checksums of, sorted
+// and without any duplicates. The modules which it only has the go.mod file of
+// are left out, since they weren't needed for the build.
+func GomodSums(data []byte) ([]*GomodModule, error) {
+	modules := []*GomodModule{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid checksum on line %d", n)
+		}
+		if strings.HasSuffix(fields[1], "/"+GomodManifestFilename) {
+			continue
+		}
+		modules = append(modules, &GomodModule{Path: fields[0], Version: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return gomodUnique(modules), nil
+}
+
+// gomodUnique sorts the modules and removes the duplicates.
+func gomodUnique(modules []*GomodModule) []*GomodModule {
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].String() < modules[j].String()
+	})
+	result := []*GomodModule{}
+	for i, m := range modules {
+		if i > 0 && m.String() == modules[i-1].String() {
+			continue
+		}
+		result = append(result, m)
+	}
+	return result
+}
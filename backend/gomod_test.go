@@ -0,0 +1,166 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend_test
+
+import (
+	"archive/zip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/backend"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestGomodRequires(t *testing.T) {
+	data := `module github.com/awslabs/example
+
+go 1.16
+
+require github.com/BurntSushi/toml v1.0.0
+
+require (
+	"example.com/quoted" v1.2.0 // indirect
+	example.com/local v0.1.0
+	example.com/forked v1.0.0
+	example.com/pinned v1.0.0
+)
+
+replace example.com/local => ../local
+
+replace (
+	example.com/forked => example.com/fork v1.1.0
+	example.com/pinned v0.9.0 => example.com/other v0.9.0
+)
+`
+	modules, err := backend.GomodRequires([]byte(data))
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	found := []string{}
+	for _, m := range modules {
+		found = append(found, m.String())
+	}
+	exp := []string{
+		"example.com/fork@v1.1.0",
+		"example.com/pinned@v1.0.0", // a different version was replaced
+		"example.com/quoted@v1.2.0",
+		"github.com/BurntSushi/toml@v1.0.0",
+	}
+	if !reflect.DeepEqual(found, exp) {
+		t.Errorf("expected %v, got: %v", exp, found)
+	}
+
+	if _, err := backend.GomodRequires([]byte("require example.com/broken\n")); err == nil {
+		t.Errorf("expected a parse error")
+	}
+}
+
+func TestGomod(t *testing.T) {
+	cache := t.TempDir()
+	write := func(name, data string) {
+		filename := filepath.Join(cache, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		if err := os.WriteFile(filename, []byte(data), 0600); err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+	}
+	write("github.com/!burnt!sushi/toml@v1.0.0/COPYING", diceMIT)
+	write("example.com/custom@v1.0.0/LICENSE.txt", "All rights reserved. Do not copy.\n")
+	write("example.com/custom@v1.0.0/README", diceISC) // not a license file
+
+	// the proxy serves the one module that isn't in the cache
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/example.com/fetched/@v/v2.0.0.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		z := zip.NewWriter(w)
+		for name, text := range map[string]string{"LICENSE": diceISC, "sub/LICENSE": diceMIT} {
+			f, err := z.Create("example.com/fetched@v2.0.0/" + name)
+			if err != nil {
+				t.Errorf("error: %+v", err)
+				return
+			}
+			f.Write([]byte(text))
+		}
+		z.Close()
+	}))
+	defer proxy.Close()
+
+	b := &backend.Gomod{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf("backend: "+format, v...)
+		},
+		ModCache: cache,
+		Texts: map[string]string{
+			"MIT": diceMIT,
+			"ISC": diceISC,
+		},
+	}
+	if err := b.Setup(context.Background()); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+
+	sums := strings.Join([]string{
+		"example.com/custom v1.0.0 h1:aaa=",
+		"example.com/custom v1.0.0/go.mod h1:bbb=",
+		"example.com/fetched v2.0.0 h1:ccc=",
+		"example.com/unused v1.0.0/go.mod h1:ddd=",
+		"github.com/BurntSushi/toml v1.0.0 h1:eee=",
+		"",
+	}, "\n")
+	result := scanData(t, b, backend.GomodChecksumFilename, sums)
+	if result == nil || result.Skip == nil {
+		t.Fatalf("expected a skip for the missing module, got: %+v", result)
+	}
+	if s := licenses.Join(result.Licenses); s != "MIT, example.com/custom/LICENSE.txt(pkg.go.dev)" {
+		t.Errorf("unexpected licenses: %s", s)
+	}
+
+	b.Proxy = proxy.URL
+	b.Offline = true // the proxy must not be used
+	result = scanData(t, b, backend.GomodChecksumFilename, sums)
+	if result == nil || result.Skip == nil {
+		t.Fatalf("expected a skip for the missing module when offline, got: %+v", result)
+	}
+
+	b.Offline = false
+	result = scanData(t, b, backend.GomodChecksumFilename, sums)
+	if result == nil || result.Skip != nil {
+		t.Fatalf("expected no skip with the proxy, got: %+v", result)
+	}
+	if s := licenses.Join(result.Licenses); s != "ISC, MIT, example.com/custom/LICENSE.txt(pkg.go.dev)" {
+		t.Errorf("unexpected licenses: %s", s)
+	}
+
+	testDataSkip(t, b, backend.GomodChecksumFilename, "example.com/broken\n")
+}
@@ -27,6 +27,7 @@ package backend
 import (
 	"context"
 	"fmt"
+	"runtime/debug"
 	"sort"
 
 	"github.com/awslabs/yesiscan/interfaces"
@@ -73,6 +74,22 @@ func (obj *LicenseClassifier) String() string {
 	return "licenseclassifier"
 }
 
+// Version returns the version of the licenseclassifier module that we were
+// built with, since the license database is embedded in it. The settings which
+// change the results are included too.
+func (obj *LicenseClassifier) Version() string {
+	version := "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, x := range info.Deps {
+			if x.Path == "github.com/google/licenseclassifier" {
+				version = x.Version
+				break
+			}
+		}
+	}
+	return fmt.Sprintf("%s headers=%t default-confidence=%t", version, obj.IncludeHeaders, obj.UseDefaultConfidence)
+}
+
 func (obj *LicenseClassifier) ScanPath(ctx context.Context, path safepath.Path, info *interfaces.Info) (*interfaces.Result, error) {
 
 	if info.FileInfo.IsDir() { // path.IsDir() should be the same.
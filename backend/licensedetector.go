@@ -0,0 +1,148 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// TODO: should this be a subpackage?
+package backend
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/licenses"
+
+	"github.com/go-enry/go-license-detector/v4/licensedb"
+)
+
+const (
+	// LicenseDetectorDefaultThreshold is the lowest confidence from zero to
+	// one that a match must have to be reported, if no other threshold is
+	// set. The library itself keeps weaker matches when there are no others.
+	LicenseDetectorDefaultThreshold = 0.8
+
+	// LicenseDetectorMaxBytes is the largest file that we look at. Anything
+	// bigger is almost certainly not a license.
+	LicenseDetectorMaxBytes = 1024 * 1024 * 1 // 1 MiB
+
+	// LicenseDetectorVersion is the version of the library that we embed.
+	// It has to be changed by hand whenever go.mod changes it.
+	LicenseDetectorVersion = "v4.3.0"
+)
+
+// LicenseDetector is a pure golang backend which wraps the go-license-detector
+// library from the go-enry project. It hashes each file against the license
+// texts that it embeds to find the candidates, and then scores each of them by
+// the edit distance between the words. Like the dice backend, there is nothing
+// to install, but the method is different enough that the two make a useful
+// pair. Only the best match in each file is reported.
+// See: https://github.com/go-enry/go-license-detector
+type LicenseDetector struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	// Threshold is the lowest confidence from zero to one that a match must
+	// have to be reported. If it is zero, then the default is used.
+	Threshold float64
+}
+
+func (obj *LicenseDetector) String() string {
+	return "licensedetector"
+}
+
+// Setup loads the license database, once, so that the first file doesn't have
+// to pay for it.
+func (obj *LicenseDetector) Setup(ctx context.Context) error {
+	if obj.Threshold < 0.0 || obj.Threshold > 1.0 {
+		return fmt.Errorf("licensedetector threshold must be between 0 and 1")
+	}
+	licensedb.Preload()
+	return nil
+}
+
+// Version changes whenever the library or the threshold do.
+func (obj *LicenseDetector) Version() string {
+	return fmt.Sprintf("%s\nthreshold: %v", LicenseDetectorVersion, obj.threshold())
+}
+
+// threshold returns the threshold to use.
+func (obj *LicenseDetector) threshold() float64 {
+	if obj.Threshold == 0.0 {
+		return LicenseDetectorDefaultThreshold
+	}
+	return obj.Threshold
+}
+
+func (obj *LicenseDetector) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
+	if info.FileInfo.IsDir() {
+		return nil, nil // skip
+	}
+	if len(data) == 0 || len(data) > LicenseDetectorMaxBytes {
+		return nil, nil // skip
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return nil, nil // binary files aren't licenses
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, errwrap.Wrapf(ctx.Err(), "scanner ended early")
+	default:
+	}
+
+	matches := licensedb.InvestigateLicenseText(data)
+	ids := []string{}
+	for id := range matches {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids) // so that a tie always goes the same way
+
+	best := ""
+	score := 0.0
+	for _, id := range ids {
+		if s := float64(matches[id]); s > score {
+			best, score = id, s
+		}
+	}
+
+	if best == "" || score < obj.threshold() {
+		return nil, nil
+	}
+	if score > 1.0 { // it's a float32, so don't trust it to stay in range
+		score = 1.0
+	}
+	if obj.Debug {
+		obj.Logf("match: %s (%.4f)", best, score)
+	}
+
+	return &interfaces.Result{
+		Licenses: []*licenses.License{
+			{
+				SPDX: best,
+			},
+		},
+		Confidence: score,
+	}, nil
+}
@@ -0,0 +1,91 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/backend"
+	"github.com/awslabs/yesiscan/interfaces"
+)
+
+func TestLicenseDetector(t *testing.T) {
+	b := &backend.LicenseDetector{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf("backend: "+format, v...)
+		},
+	}
+	if err := b.Setup(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	code := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n"
+	mit := strings.Replace(diceMIT, "<year> <copyright holders>", "2022 Jane Doe", 1)
+	tests := []struct {
+		name string
+		data string
+		exp  string // empty for no result
+	}{
+		{"LICENSE", mit, "MIT"},
+		{"code.go", code, ""},
+		{"empty", "", ""},
+	}
+
+	dir := t.TempDir()
+	for _, x := range tests {
+		filename := filepath.Join(dir, "file")
+		if err := os.WriteFile(filename, []byte(x.data), 0600); err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		fileInfo, err := os.Stat(filename)
+		if err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		info := &interfaces.Info{FileInfo: fileInfo, UID: "file://" + filename}
+		result, err := b.ScanData(context.Background(), []byte(x.data), info)
+		if err != nil {
+			t.Errorf("%s: error: %+v", x.name, err)
+			continue
+		}
+		if x.exp == "" {
+			if result != nil {
+				t.Errorf("%s: expected no result, got: %s", x.name, result.Licenses[0])
+			}
+			continue
+		}
+		if result == nil || len(result.Licenses) != 1 || result.Licenses[0].SPDX != x.exp {
+			t.Errorf("%s: expected %s, got: %+v", x.name, x.exp, result)
+			continue
+		}
+		if result.Confidence < backend.LicenseDetectorDefaultThreshold || result.Confidence > 1.0 {
+			t.Errorf("%s: unexpected confidence: %f", x.name, result.Confidence)
+		}
+		t.Logf("%s: %s (%.4f)", x.name, result.Licenses[0], result.Confidence)
+	}
+}
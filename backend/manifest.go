@@ -0,0 +1,320 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// TODO: should this be a subpackage?
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+// ExpressionIDs returns the license ID's in an SPDX license expression, such as
+// "(MIT OR Apache-2.0)", in the order that they appear and without duplicates.
+// The AND and OR operators and the parentheses are dropped, which means that a
+// choice of licenses is reported as if all of them apply. This is the safe way
+// to get it wrong. The exception after a WITH is dropped too, since it only
+// ever grants additional permissions. A single license name which isn't an
+// expression at all is returned as it is.
+func ExpressionIDs(expression string) []string {
+	s := strings.NewReplacer("(", " ", ")", " ").Replace(expression)
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	isExpression := false
+	for _, x := range fields {
+		switch strings.ToUpper(x) {
+		case "AND", "OR", "WITH":
+			isExpression = true
+		}
+	}
+	if !isExpression { // probably a license name with spaces in it
+		return []string{strings.Join(fields, " ")}
+	}
+
+	ids := []string{}
+	seen := make(map[string]struct{})
+	for i := 0; i < len(fields); i++ {
+		switch strings.ToUpper(fields[i]) {
+		case "AND", "OR":
+			continue
+		case "WITH":
+			i++ // skip the exception
+			continue
+		}
+		if _, exists := seen[fields[i]]; exists {
+			continue
+		}
+		seen[fields[i]] = struct{}{}
+		ids = append(ids, fields[i])
+	}
+	return ids
+}
+
+// ExpressionMaxClauses is the most clauses that ExpressionClauses returns.
+// Expanding an expression which has an OR of many AND's can grow quickly, so
+// past this it gives up on the choices and requires all of the licenses.
+const ExpressionMaxClauses = 64
+
+// ExpressionClauses returns the license ID's in an SPDX license expression in
+// conjunctive normal form. Each clause is a list of licenses of which any one
+// can be used, and all of the clauses apply, so "(MIT OR Apache-2.0) AND ISC"
+// is [[MIT Apache-2.0] [ISC]]. The AND operator binds tighter than OR, as the
+// spec says. The exception after a WITH is dropped like in ExpressionIDs. If
+// the expression can't be parsed, then every license gets a clause of its own,
+// which is the same safe answer that ExpressionIDs gives.
+func ExpressionClauses(expression string) [][]string {
+	s := strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expression)
+	p := &expressionParser{
+		tokens: strings.Fields(s),
+	}
+	clauses, err := p.expression()
+	if err != nil || p.i != len(p.tokens) || len(clauses) > ExpressionMaxClauses {
+		clauses = [][]string{}
+		for _, id := range ExpressionIDs(expression) {
+			clauses = append(clauses, []string{id})
+		}
+		return clauses
+	}
+
+	// Remove the duplicate clauses, such as from "MIT AND MIT", and the ones
+	// which another clause already satisfies. Since [MIT] must be met, the
+	// choice in [MIT ISC] is no choice at all, and keeping it would prevent
+	// a preference for ISC elsewhere from taking effect.
+	result := [][]string{}
+	seen := make(map[string]struct{})
+Loop:
+	for i, clause := range clauses {
+		for j, x := range clauses {
+			if i != j && len(x) < len(clause) && expressionSubset(x, clause) {
+				continue Loop
+			}
+		}
+		ids := []string{}
+		ids = append(ids, clause...)
+		sort.Strings(ids)
+		key := strings.Join(ids, " ")
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		result = append(result, clause)
+	}
+	return result
+}
+
+// expressionSubset returns true if every ID in a is also in b.
+func expressionSubset(a, b []string) bool {
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if x == y {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// expressionParser is a recursive descent parser for SPDX license expressions
+// which builds the conjunctive normal form as it goes.
+type expressionParser struct {
+	tokens []string
+	i      int
+}
+
+// peek returns the next token in upper case, or the empty string at the end.
+func (obj *expressionParser) peek() string {
+	if obj.i >= len(obj.tokens) {
+		return ""
+	}
+	return strings.ToUpper(obj.tokens[obj.i])
+}
+
+// expression parses a list of terms joined by OR.
+func (obj *expressionParser) expression() ([][]string, error) {
+	clauses, err := obj.term()
+	if err != nil {
+		return nil, err
+	}
+	for obj.peek() == "OR" {
+		obj.i++
+		right, err := obj.term()
+		if err != nil {
+			return nil, err
+		}
+		// (a AND b) OR (c AND d) = (a OR c) AND (a OR d) AND ...
+		product := [][]string{}
+		for _, x := range clauses {
+			for _, y := range right {
+				product = append(product, expressionUnion(x, y))
+			}
+		}
+		if len(product) > ExpressionMaxClauses {
+			return nil, fmt.Errorf("too many clauses")
+		}
+		clauses = product
+	}
+	return clauses, nil
+}
+
+// term parses a list of factors joined by AND.
+func (obj *expressionParser) term() ([][]string, error) {
+	clauses, err := obj.factor()
+	if err != nil {
+		return nil, err
+	}
+	for obj.peek() == "AND" {
+		obj.i++
+		right, err := obj.factor()
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, right...)
+	}
+	return clauses, nil
+}
+
+// factor parses a license ID, with an optional exception, or an expression in
+// parentheses.
+func (obj *expressionParser) factor() ([][]string, error) {
+	switch obj.peek() {
+	case "", ")", "AND", "OR", "WITH":
+		return nil, fmt.Errorf("unexpected token: %s", obj.peek())
+	case "(":
+		obj.i++
+		clauses, err := obj.expression()
+		if err != nil {
+			return nil, err
+		}
+		if obj.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		obj.i++
+		return clauses, nil
+	}
+	id := obj.tokens[obj.i]
+	obj.i++
+	if obj.peek() == "WITH" {
+		obj.i += 2 // skip the exception
+		if obj.i > len(obj.tokens) {
+			return nil, fmt.Errorf("missing exception")
+		}
+	}
+	return [][]string{{id}}, nil
+}
+
+// expressionUnion returns the ID's which are in either of the two clauses, in
+// order and without duplicates.
+func expressionUnion(a, b []string) []string {
+	result := []string{}
+	seen := make(map[string]struct{})
+	for _, x := range append(append([]string{}, a...), b...) {
+		if _, exists := seen[x]; exists {
+			continue
+		}
+		seen[x] = struct{}{}
+		result = append(result, x)
+	}
+	return result
+}
+
+// clausesToChoices turns the clauses of the license expressions that were
+// declared in a manifest into the choices of a result. It returns nil if none
+// of them offer a choice, since then all of the licenses simply apply.
+func clausesToChoices(clauses [][]string) [][]*licenses.License {
+	choice := false
+	for _, clause := range clauses {
+		if len(clause) > 1 {
+			choice = true
+			break
+		}
+	}
+	if !choice {
+		return nil
+	}
+
+	choices := [][]*licenses.License{}
+	seen := make(map[string]struct{})
+	for _, clause := range clauses {
+		ids := []string{}
+		ids = append(ids, clause...)
+		sort.Strings(ids)
+		key := strings.Join(ids, " ")
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		ls := []*licenses.License{}
+		for _, id := range clause {
+			ls = append(ls, idToLicense(id))
+		}
+		choices = append(choices, ls)
+	}
+	return choices
+}
+
+// idsToLicenses turns a set of license ID's that were declared in a manifest
+// into a sorted list of licenses. The ones which aren't valid SPDX ID's are
+// returned as custom licenses of unknown origin.
+func idsToLicenses(licenseMap map[string]struct{}) []*licenses.License {
+	ids := []string{}
+	for id := range licenseMap {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids) // deterministic order
+
+	licenseList := []*licenses.License{}
+	for _, id := range ids {
+		licenseList = append(licenseList, idToLicense(id))
+	}
+	return licenseList
+}
+
+// idToLicense turns a license ID that was declared in a manifest into a license.
+func idToLicense(id string) *licenses.License {
+	license := &licenses.License{
+		SPDX: id,
+	}
+
+	// If we find an unknown SPDX ID, we don't want to error, because that
+	// would allow someone to put junk in their manifest to prevent us
+	// scanning it. Instead, create an invalid license but return it anyways.
+	if err := license.Validate(); err != nil {
+		license = &licenses.License{
+			Origin: "", // unknown!
+			Custom: id,
+		}
+	}
+	return license
+}
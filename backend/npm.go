@@ -0,0 +1,247 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// TODO: should this be a subpackage?
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+const (
+	// NpmManifestFilename is the file name used by the npm manifests.
+	NpmManifestFilename = "package.json"
+
+	// NpmLockfileFilename is the file name used by the npm lockfiles.
+	NpmLockfileFilename = "package-lock.json"
+
+	// NpmShrinkwrapFilename is the file name used by the npm lockfiles that
+	// are meant to be published. It has the same format as the lockfile.
+	NpmShrinkwrapFilename = "npm-shrinkwrap.json"
+
+	// NpmUnlicensed is the special license value that npm uses for the
+	// packages that aren't licensed for use by others at all.
+	NpmUnlicensed = "UNLICENSED"
+
+	// npmSeeLicenseIn is the prefix of the special license value that
+	// points to a license file in the package instead.
+	npmSeeLicenseIn = "SEE LICENSE IN "
+)
+
+// Npm is a backend for the package.json manifests and the package-lock.json
+// lockfiles of npm. It finds the licenses declared in the license field of the
+// manifest, which is usually an SPDX expression, or in the older licenses
+// field. For a lockfile, it finds the licenses of the package and of each of
+// the dependencies that are locked in it, which the newer lockfile versions
+// record. The oldest lockfile version doesn't, so those are skipped. When the
+// license field points at a file, it's left for the other backends to scan.
+type Npm struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+}
+
+// String method returns the name of the backend.
+func (obj *Npm) String() string {
+	return "npm"
+}
+
+// ScanData method is used to extract license ids from data and return licenses
+// based on the license ids.
+func (obj *Npm) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
+	name := info.FileInfo.Name()
+	if name != NpmManifestFilename && name != NpmLockfileFilename && name != NpmShrinkwrapFilename {
+		return nil, nil // skip
+	}
+	if info.FileInfo.IsDir() {
+		return nil, nil // skip
+	}
+	if len(data) == 0 {
+		return nil, nil // skip
+	}
+
+	packages := make(map[string]*NpmPackage) // keyed by the path in the lockfile
+	if name == NpmManifestFilename {
+		var manifest NpmPackage
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			// There is a parse error with the file, so we can't
+			// properly examine it for licensing information.
+			result := &interfaces.Result{
+				Confidence: 1.0, // TODO: what should we put here?
+				Skip:       errwrap.Wrapf(err, "parse error"),
+			}
+			return result, nil
+		}
+		packages[""] = &manifest
+	} else {
+		var lockfile NpmLockfile
+		if err := json.Unmarshal(data, &lockfile); err != nil {
+			result := &interfaces.Result{
+				Confidence: 1.0, // TODO: what should we put here?
+				Skip:       errwrap.Wrapf(err, "parse error"),
+			}
+			return result, nil
+		}
+		for k, v := range lockfile.Packages {
+			if v == nil {
+				continue
+			}
+			packages[k] = v
+		}
+	}
+
+	keys := []string{}
+	for k := range packages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // deterministic order
+
+	licenseMap := make(map[string]struct{})
+	clauses := [][]string{}
+	for _, k := range keys {
+		ids := packages[k].LicenseIDs()
+		for _, lid := range ids {
+			licenseMap[lid] = struct{}{}
+		}
+		clauses = append(clauses, packages[k].LicenseClauses()...)
+		if obj.Debug && k != "" && len(ids) > 0 {
+			obj.Logf("npm: %s: %s", k, strings.Join(ids, ", "))
+		}
+	}
+
+	if len(licenseMap) == 0 {
+		// If we did not get any license names we return nil, nil.
+		return nil, nil
+	}
+
+	result := &interfaces.Result{
+		Licenses:   idsToLicenses(licenseMap),
+		Confidence: 1.0, // TODO: what should we put here?
+		Choices:    clausesToChoices(clauses),
+	}
+
+	return result, nil
+}
+
+// NpmLockfile is a struct that helps store the licenses of the packages in a
+// package-lock.json or npm-shrinkwrap.json file.
+type NpmLockfile struct {
+	// LockfileVersion is the version of the format of the lockfile. The
+	// packages field only exists from version 2.
+	LockfileVersion int `json:"lockfileVersion"`
+
+	// Packages maps the path of each package, such as node_modules/foo, to
+	// its metadata. The package itself is at the empty path.
+	Packages map[string]*NpmPackage `json:"packages"`
+}
+
+// NpmPackage is a struct that helps store the license fields of a package.json
+// file, or of a package in a lockfile.
+type NpmPackage struct {
+	// License is the SPDX expression of the license of the package. It is
+	// an object with a type field in some old packages.
+	License json.RawMessage `json:"license"`
+
+	// Licenses is the deprecated list of licenses of the package. Each one
+	// is an object with a type field, or sometimes just a string.
+	Licenses json.RawMessage `json:"licenses"`
+}
+
+// LicenseIDs returns the license ID's that this package declares. Invalid or
+// unexpected values are ignored, since they can't tell us anything.
+func (obj *NpmPackage) LicenseIDs() []string {
+	ids := []string{}
+	for _, x := range obj.licenseValues() {
+		if x == NpmUnlicensed {
+			ids = append(ids, x)
+			continue
+		}
+		ids = append(ids, ExpressionIDs(x)...)
+	}
+	return ids
+}
+
+// LicenseClauses returns the same license ID's as LicenseIDs, but grouped into
+// the clauses that ExpressionClauses returns, so that the choices are kept.
+func (obj *NpmPackage) LicenseClauses() [][]string {
+	clauses := [][]string{}
+	for _, x := range obj.licenseValues() {
+		if x == NpmUnlicensed {
+			clauses = append(clauses, []string{x})
+			continue
+		}
+		clauses = append(clauses, ExpressionClauses(x)...)
+	}
+	return clauses
+}
+
+// licenseValues returns the license strings that this package declares, except
+// for the ones which point to a file instead.
+func (obj *NpmPackage) licenseValues() []string {
+	values := []string{}
+	values = append(values, npmLicenseValues(obj.License)...)
+	values = append(values, npmLicenseValues(obj.Licenses)...)
+
+	result := []string{}
+	for _, x := range values {
+		x = strings.TrimSpace(x)
+		if x == "" || strings.HasPrefix(x, npmSeeLicenseIn) {
+			continue // the file is scanned by the other backends
+		}
+		result = append(result, x)
+	}
+	return result
+}
+
+// npmLicenseValues returns the license strings in the raw value of one of the
+// license fields. It can be a string, an object with a type field, or a list of
+// either of those.
+func npmLicenseValues(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return []string{s}
+	}
+	var t struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &t); err == nil {
+		return []string{t.Type}
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil
+	}
+	values := []string{}
+	for _, x := range list {
+		values = append(values, npmLicenseValues(x)...)
+	}
+	return values
+}
@@ -0,0 +1,90 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/awslabs/yesiscan/backend"
+)
+
+func TestExpressionIDs(t *testing.T) {
+	tests := map[string][]string{
+		"MIT":                            {"MIT"},
+		"(MIT OR Apache-2.0)":            {"MIT", "Apache-2.0"},
+		"MIT AND (BSD-2-Clause OR MIT)":  {"MIT", "BSD-2-Clause"},
+		"Apache-2.0 WITH LLVM-exception": {"Apache-2.0"},
+		"GPL-2.0-only with Classpath-exception-2.0 or MIT": {"GPL-2.0-only", "MIT"},
+		"Apache License 2.0": {"Apache License 2.0"},
+		"  ":                 nil,
+	}
+	for expression, expected := range tests {
+		if ids := backend.ExpressionIDs(expression); !reflect.DeepEqual(ids, expected) {
+			t.Errorf("expression %q: expected %v, got: %v", expression, expected, ids)
+		}
+	}
+}
+
+func TestExpressionClauses(t *testing.T) {
+	tests := map[string][][]string{
+		"MIT":                             {{"MIT"}},
+		"(MIT OR Apache-2.0)":             {{"MIT", "Apache-2.0"}},
+		"MIT OR Apache-2.0 AND ISC":       {{"MIT", "Apache-2.0"}, {"MIT", "ISC"}},
+		"(MIT OR Apache-2.0) AND ISC":     {{"MIT", "Apache-2.0"}, {"ISC"}},
+		"MIT AND MIT":                     {{"MIT"}},
+		"GPL-2.0-only WITH x OR MIT":      {{"GPL-2.0-only", "MIT"}},
+		"Apache License 2.0":              {{"Apache License 2.0"}},
+		"(MIT OR Apache-2.0":              {{"MIT"}, {"Apache-2.0"}},
+		"(MIT AND ISC) OR (MIT AND Zlib)": {{"MIT"}, {"ISC", "Zlib"}},
+	}
+	for expression, expected := range tests {
+		if clauses := backend.ExpressionClauses(expression); !reflect.DeepEqual(clauses, expected) {
+			t.Errorf("expression %q: expected %v, got: %v", expression, expected, clauses)
+		}
+	}
+}
+
+func TestNpm(t *testing.T) {
+	b := &backend.Npm{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf("backend: "+format, v...)
+		},
+	}
+
+	testDataBackend(t, b, []dataTest{
+		{"package.json", `{"name": "x", "license": "(MIT OR Apache-2.0)"}`, "Apache-2.0, MIT"},
+		{"package.json", `{"license": {"type": "ISC", "url": "https://example.com/"}}`, "ISC"},
+		{"package.json", `{"licenses": [{"type": "MIT"}, "BSD-3-Clause"]}`, "BSD-3-Clause, MIT"},
+		{"package.json", `{"license": "SEE LICENSE IN LICENSE.txt"}`, ""},
+		{"package.json", `{"license": "UNLICENSED"}`, "UNLICENSED(unknown)"},
+		{"package.json", `{"name": "x"}`, ""},
+		{"package-lock.json", `{"lockfileVersion": 3, "packages": {"": {"license": "MIT"}, "node_modules/a": {"license": "ISC"}, "node_modules/a/node_modules/b": {"license": "Apache-2.0 AND MIT"}, "node_modules/c": {}}}`, "Apache-2.0, ISC, MIT"},
+		{"package-lock.json", `{"lockfileVersion": 1, "dependencies": {"a": {"version": "1.0.0"}}}`, ""},
+		{"npm-shrinkwrap.json", `{"lockfileVersion": 2, "packages": {"node_modules/a": {"license": "0BSD"}}}`, "0BSD"},
+		{"other.json", `{"license": "MIT"}`, ""},
+	})
+
+	testDataSkip(t, b, "package.json", `{"license": `)
+}
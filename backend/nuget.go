@@ -0,0 +1,401 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// TODO: should this be a subpackage?
+package backend
+
+import (
+	"context"
+	"encoding/xml"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+const (
+	// NugetSpecExtension is the file extension used by the nuget package
+	// manifests.
+	NugetSpecExtension = ".nuspec"
+
+	// NugetLicenseOrigin is the origin of the custom licenses that we
+	// return for the packages which only point to a license file or to a
+	// license url that we don't recognize.
+	NugetLicenseOrigin = "nuget.org"
+
+	// nugetLicenseExpressionURL is the prefix of the license urls that
+	// nuget generates from an SPDX expression. The expression is the rest
+	// of the url.
+	nugetLicenseExpressionURL = "https://licenses.nuget.org/"
+
+	// nugetDeprecatedLicenseURL is the placeholder license url that gets
+	// put in the packages which use the newer license element instead.
+	nugetDeprecatedLicenseURL = "https://aka.ms/deprecateLicenseUrl"
+)
+
+var (
+	// NugetProjectExtensions are the file extensions used by the .NET
+	// project files which can contain the nuget package metadata.
+	NugetProjectExtensions = []string{
+		".csproj",
+		".fsproj",
+		".vbproj",
+	}
+
+	// nugetLicenseURLs are some well-known license urls that are used
+	// instead of an SPDX ID in older packages.
+	nugetLicenseURLs = map[string]string{
+		"http://www.apache.org/licenses/LICENSE-2.0":      "Apache-2.0",
+		"http://www.apache.org/licenses/LICENSE-2.0.txt":  "Apache-2.0",
+		"http://www.apache.org/licenses/LICENSE-2.0.html": "Apache-2.0",
+		"http://www.gnu.org/licenses/gpl-2.0.html":        "GPL-2.0-only",
+		"http://www.gnu.org/licenses/gpl-3.0.html":        "GPL-3.0-only",
+		"http://www.gnu.org/licenses/lgpl-2.1.html":       "LGPL-2.1-only",
+		"http://www.gnu.org/licenses/lgpl-3.0.html":       "LGPL-3.0-only",
+		"http://opensource.org/licenses/MIT":              "MIT",
+		"http://opensource.org/licenses/BSD-2-Clause":     "BSD-2-Clause",
+		"http://opensource.org/licenses/BSD-3-Clause":     "BSD-3-Clause",
+		"http://opensource.org/licenses/MS-PL":            "MS-PL",
+	}
+)
+
+// Nuget is a backend for the .nuspec manifests of nuget packages, and for the
+// .csproj project files which contain the same metadata for the packages that
+// they build. It finds the SPDX expression in the license element, or in the
+// PackageLicenseExpression property of a project. A package with a
+// non-standard license points to its license file instead, and it gets a
+// custom license named after that file, so that it's looked at. The older
+// packages only have a license url, which is turned into an SPDX ID if it's
+// well-known, and into a custom license otherwise. The manifest of each of the
+// PackageReference dependencies of a project is looked up in the local nuget
+// global packages folder, where nuget put it when it restored them. The
+// dependencies which aren't in there are skipped, so restore the project first
+// for the best results.
+type Nuget struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	// Packages is the list of nuget global packages folders that the
+	// manifests of the dependencies are looked up in. If it is nil, then
+	// the one in $NUGET_PACKAGES, or in ~/.nuget/packages is used.
+	Packages []string
+}
+
+// String method returns the name of the backend.
+func (obj *Nuget) String() string {
+	return "nuget"
+}
+
+// ScanData method is used to extract license ids from data and return licenses
+// based on the license ids.
+func (obj *Nuget) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
+	name := info.FileInfo.Name()
+	ext := strings.ToLower(filepath.Ext(name))
+	isProject := false
+	for _, x := range NugetProjectExtensions {
+		if ext == x {
+			isProject = true
+		}
+	}
+	if ext != NugetSpecExtension && !isProject {
+		return nil, nil // skip
+	}
+	if info.FileInfo.IsDir() {
+		return nil, nil // skip
+	}
+	if len(data) == 0 {
+		return nil, nil // skip
+	}
+
+	licenseList := []*NugetLicense{}
+	if !isProject {
+		var spec NugetSpec
+		if err := xml.Unmarshal(data, &spec); err != nil {
+			// There is a parse error with the file, so we can't
+			// properly examine it for licensing information.
+			result := &interfaces.Result{
+				Confidence: 1.0, // TODO: what should we put here?
+				Skip:       errwrap.Wrapf(err, "parse error"),
+			}
+			return result, nil
+		}
+		licenseList = append(licenseList, spec.License())
+	} else {
+		var project NugetProject
+		if err := xml.Unmarshal(data, &project); err != nil {
+			result := &interfaces.Result{
+				Confidence: 1.0, // TODO: what should we put here?
+				Skip:       errwrap.Wrapf(err, "parse error"),
+			}
+			return result, nil
+		}
+		licenseList = append(licenseList, project.License(strings.TrimSuffix(name, filepath.Ext(name))))
+
+		missing := 0
+		for _, x := range project.References() {
+			spec, err := obj.packageSpec(x.Include, x.version())
+			if err != nil {
+				if obj.Debug {
+					obj.Logf("nuget: %s %s: %+v", x.Include, x.version(), err)
+				}
+				missing++
+				continue
+			}
+			licenseList = append(licenseList, spec.License())
+		}
+		if missing > 0 {
+			obj.Logf("nuget: %d package references are not in the global packages folder, restore first to fetch them", missing)
+		}
+	}
+
+	licenseMap := make(map[string]struct{})
+	custom := make(map[string]struct{})
+	clauses := [][]string{}
+	for _, x := range licenseList {
+		expression := x.Expression
+		if expression == "" && x.URL != "" {
+			expression = nugetURLExpression(x.URL)
+		}
+		if expression != "" {
+			for _, lid := range ExpressionIDs(expression) {
+				licenseMap[lid] = struct{}{}
+			}
+			clauses = append(clauses, ExpressionClauses(expression)...)
+			continue
+		}
+		if x.File != "" {
+			custom[x.Name+"/"+x.File] = struct{}{}
+			continue
+		}
+		if x.URL != "" {
+			custom[x.URL] = struct{}{}
+		}
+	}
+
+	if len(licenseMap) == 0 && len(custom) == 0 {
+		// If we did not get any license names we return nil, nil.
+		return nil, nil
+	}
+
+	names := []string{}
+	for x := range custom {
+		names = append(names, x)
+	}
+	sort.Strings(names) // deterministic order
+
+	result := &interfaces.Result{
+		Licenses:   idsToLicenses(licenseMap),
+		Confidence: 1.0, // TODO: what should we put here?
+		Choices:    clausesToChoices(clauses),
+	}
+	for _, x := range names {
+		license := &licenses.License{
+			Origin: NugetLicenseOrigin,
+			Custom: x,
+		}
+		result.Licenses = append(result.Licenses, license)
+	}
+
+	return result, nil
+}
+
+// packages returns the list of nuget global packages folders to look in.
+func (obj *Nuget) packages() []string {
+	if obj.Packages != nil {
+		return obj.Packages
+	}
+	if dir := os.Getenv("NUGET_PACKAGES"); dir != "" {
+		return []string{dir}
+	}
+	h, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []string{filepath.Join(h, ".nuget", "packages")}
+}
+
+// packageSpec finds and parses the manifest of this version of a package in
+// the global packages folder. The package names are case insensitive, and nuget
+// stores them in lower case.
+func (obj *Nuget) packageSpec(name, version string) (*NugetSpec, error) {
+	if name == "" || version == "" || strings.ContainsAny(version, "[]()*,") {
+		// TODO: resolve version ranges and central package management
+		return nil, os.ErrNotExist
+	}
+	id := strings.ToLower(name)
+	for _, dir := range obj.packages() {
+		p := filepath.Join(dir, id, strings.ToLower(version), id+NugetSpecExtension)
+		data, err := os.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var spec NugetSpec
+		if err := xml.Unmarshal(data, &spec); err != nil {
+			return nil, errwrap.Wrapf(err, "parse error")
+		}
+		return &spec, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+// nugetURLExpression returns the SPDX expression that a license url stands for,
+// or an empty string if we don't recognize it.
+func nugetURLExpression(u string) string {
+	if strings.HasPrefix(u, nugetLicenseExpressionURL) {
+		s := strings.TrimPrefix(u, nugetLicenseExpressionURL)
+		if expression, err := url.PathUnescape(s); err == nil {
+			return strings.TrimSpace(expression)
+		}
+		return ""
+	}
+	key := strings.TrimSuffix(strings.Replace(u, "https://", "http://", 1), "/")
+	return nugetLicenseURLs[key]
+}
+
+// NugetLicense is the license information of a single nuget package. At most
+// one of the expression or the file is set. The url is kept for the packages
+// which were made before those existed.
+type NugetLicense struct {
+	// Name is the name of the package.
+	Name string
+
+	// Expression is the SPDX expression of the license of the package.
+	Expression string
+
+	// File is the path to the license file of the package, if it doesn't
+	// use a standard license.
+	File string
+
+	// URL is the deprecated url of the license of the package.
+	URL string
+}
+
+// NugetSpec is a struct that helps store the license fields of a .nuspec file.
+type NugetSpec struct {
+	Metadata struct {
+		// ID is the name of the package.
+		ID string `xml:"id"`
+
+		// License is the license of the package. Its type is either
+		// expression or file.
+		License struct {
+			Type  string `xml:"type,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"license"`
+
+		// LicenseURL is the deprecated url of the license.
+		LicenseURL string `xml:"licenseUrl"`
+	} `xml:"metadata"`
+}
+
+// License returns the license information that is in this manifest.
+func (obj *NugetSpec) License() *NugetLicense {
+	license := &NugetLicense{
+		Name: strings.TrimSpace(obj.Metadata.ID),
+	}
+	value := strings.TrimSpace(obj.Metadata.License.Value)
+	switch strings.ToLower(obj.Metadata.License.Type) {
+	case "expression":
+		license.Expression = value
+	case "file":
+		license.File = value
+	}
+	if u := strings.TrimSpace(obj.Metadata.LicenseURL); u != nugetDeprecatedLicenseURL {
+		license.URL = u
+	}
+	return license
+}
+
+// NugetProject is a struct that helps store the package metadata and the
+// package references of a .csproj file.
+type NugetProject struct {
+	// PropertyGroups contain the metadata of the package that the project
+	// builds, if any.
+	PropertyGroups []struct {
+		PackageID                string `xml:"PackageId"`
+		PackageLicenseExpression string `xml:"PackageLicenseExpression"`
+		PackageLicenseFile       string `xml:"PackageLicenseFile"`
+		PackageLicenseURL        string `xml:"PackageLicenseUrl"`
+	} `xml:"PropertyGroup"`
+
+	// ItemGroups contain the packages that the project depends on.
+	ItemGroups []struct {
+		PackageReferences []*NugetPackageReference `xml:"PackageReference"`
+	} `xml:"ItemGroup"`
+}
+
+// License returns the license information of the package that this project
+// builds. The name is used if the project doesn't set a package id.
+func (obj *NugetProject) License(name string) *NugetLicense {
+	license := &NugetLicense{
+		Name: name,
+	}
+	for _, x := range obj.PropertyGroups {
+		if s := strings.TrimSpace(x.PackageID); s != "" {
+			license.Name = s
+		}
+		if s := strings.TrimSpace(x.PackageLicenseExpression); s != "" {
+			license.Expression = s
+		}
+		if s := strings.TrimSpace(x.PackageLicenseFile); s != "" {
+			license.File = s
+		}
+		if s := strings.TrimSpace(x.PackageLicenseURL); s != "" {
+			license.URL = s
+		}
+	}
+	return license
+}
+
+// References returns the list of package references in this project.
+func (obj *NugetProject) References() []*NugetPackageReference {
+	references := []*NugetPackageReference{}
+	for _, x := range obj.ItemGroups {
+		references = append(references, x.PackageReferences...)
+	}
+	return references
+}
+
+// NugetPackageReference is a dependency of a .csproj project on a nuget
+// package. The version is either an attribute or a child element.
+type NugetPackageReference struct {
+	Include        string `xml:"Include,attr"`
+	Version        string `xml:"Version,attr"`
+	VersionElement string `xml:"Version"`
+}
+
+// version returns the version of the package that is referenced.
+func (obj *NugetPackageReference) version() string {
+	if obj.Version != "" {
+		return strings.TrimSpace(obj.Version)
+	}
+	return strings.TrimSpace(obj.VersionElement)
+}
@@ -0,0 +1,94 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/yesiscan/backend"
+)
+
+func TestNuget(t *testing.T) {
+	packages := t.TempDir()
+	specs := map[string]string{
+		"newtonsoft.json/13.0.1": `<package><metadata><id>Newtonsoft.Json</id><license type="expression">MIT</license><licenseUrl>https://aka.ms/deprecateLicenseUrl</licenseUrl></metadata></package>`,
+		"oldthing/1.0.0":         `<package><metadata><id>OldThing</id><licenseUrl>https://www.example.com/eula.html</licenseUrl></metadata></package>`,
+	}
+	for name, data := range specs {
+		dir := filepath.Join(packages, filepath.FromSlash(name))
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+		id := filepath.Base(filepath.Dir(dir))
+		if err := os.WriteFile(filepath.Join(dir, id+backend.NugetSpecExtension), []byte(data), 0600); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+	}
+
+	b := &backend.Nuget{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf("backend: "+format, v...)
+		},
+		Packages: []string{packages},
+	}
+
+	project := `<Project Sdk="Microsoft.NET.Sdk">
+  <PropertyGroup>
+    <TargetFramework>net8.0</TargetFramework>
+    <PackageLicenseExpression>Apache-2.0</PackageLicenseExpression>
+  </PropertyGroup>
+  <ItemGroup>
+    <PackageReference Include="Newtonsoft.Json" Version="13.0.1" />
+    <PackageReference Include="OldThing">
+      <Version>1.0.0</Version>
+    </PackageReference>
+    <PackageReference Include="Missing" Version="9.9.9" />
+  </ItemGroup>
+</Project>
+`
+	nuspec := `<?xml version="1.0" encoding="utf-8"?>
+<package xmlns="http://schemas.microsoft.com/packaging/2013/05/nuspec.xsd">
+  <metadata>
+    <id>Example</id>
+    <license type="expression">MIT OR Apache-2.0</license>
+  </metadata>
+</package>
+`
+	testDataBackend(t, b, []dataTest{
+		{"example.nuspec", nuspec, "Apache-2.0, MIT"},
+		{"x.nuspec", `<package><metadata><id>X</id><license type="file">docs/EULA.txt</license></metadata></package>`, "X/docs/EULA.txt(nuget.org)"},
+		{"x.nuspec", `<package><metadata><id>X</id><licenseUrl>https://licenses.nuget.org/MIT</licenseUrl></metadata></package>`, "MIT"},
+		{"x.nuspec", `<package><metadata><id>X</id><licenseUrl>http://www.apache.org/licenses/LICENSE-2.0</licenseUrl></metadata></package>`, "Apache-2.0"},
+		{"x.nuspec", `<package><metadata><id>X</id></metadata></package>`, ""},
+		{"App.csproj", project, "Apache-2.0, MIT, https://www.example.com/eula.html(nuget.org)"},
+		{"Lib.fsproj", `<Project><PropertyGroup><PackageLicenseFile>LICENSE.md</PackageLicenseFile></PropertyGroup></Project>`, "Lib/LICENSE.md(nuget.org)"},
+		{"other.xml", nuspec, ""},
+	})
+
+	testDataSkip(t, b, "x.nuspec", "<package><metadata>")
+}
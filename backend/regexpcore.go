@@ -28,6 +28,9 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"regexp"
 	"sort"
 	"strings"
@@ -87,6 +90,22 @@ func (obj *RegexpCore) Setup(ctx context.Context) error {
 	return nil
 }
 
+// Version returns a hash of the rules, since the results only change when the
+// rules do.
+func (obj *RegexpCore) Version() string {
+	b, err := json.Marshal(obj.Rules)
+	if err != nil {
+		return "" // can't happen with a list of simple structs
+	}
+	h := sha256.New()
+	h.Write(b)
+	h.Write([]byte(obj.Origin))
+	if obj.MultipleMatch {
+		h.Write([]byte("multiple"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func (obj *RegexpCore) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
 	if info.FileInfo.IsDir() {
 		return nil, nil // skip
@@ -30,10 +30,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
+	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
 	"github.com/awslabs/yesiscan/util/errwrap"
 	"github.com/awslabs/yesiscan/util/licenses"
 	"github.com/awslabs/yesiscan/util/safepath"
@@ -42,17 +46,140 @@ import (
 const (
 	// ScancodeProgram is the name of the scancode executable.
 	ScancodeProgram = "scancode"
+
+	// ScancodeDefaultTimeout is the per-file timeout in seconds which is
+	// used when none is specified. This matches the scancode default.
+	ScancodeDefaultTimeout = 120
+
+	// ScancodeTimeoutGrace is how much longer than the scancode timeout
+	// we wait for each invocation, since scancode takes a while to start
+	// up, and the timeout it enforces internally doesn't include that.
+	ScancodeTimeoutGrace = 30 * time.Second
+
+	// errScancodeTimeout is returned by run when we had to kill scancode.
+	errScancodeTimeout = interfaces.Error("scancode timed out")
 )
 
+// ScancodeOptions are the settings that get passed through to scancode. The
+// zero value of each field keeps the default behaviour.
+type ScancodeOptions struct {
+	// Processes is the number of worker processes that scancode uses. If
+	// it is zero, then we don't pass the option, and scancode decides,
+	// except when we scan a whole root, where we use one for each cpu.
+	Processes int `json:"processes"`
+
+	// Timeout is the number of seconds that each file may be scanned for.
+	// If it is zero, then ScancodeDefaultTimeout is used. If it is
+	// negative, then there is no timeout at all.
+	Timeout int `json:"timeout"`
+
+	// LicenseScore is the minimum score from zero to one hundred that a
+	// license match must have to be reported. If it is zero, then we don't
+	// pass the option, and every match is reported.
+	LicenseScore int `json:"license-score"`
+
+	// Plugins is a list of extra args to enable more scancode plugins. Eg:
+	// "--license-text" or "--classify". Options which change the output
+	// format or the input path will break the parsing of the results.
+	Plugins []string `json:"plugins"`
+
+	// Workers is the number of warm python processes with scancode loaded
+	// that we keep around and send each file to, instead of running all of
+	// scancode for each root. If it is zero, then we don't use any.
+	Workers int `json:"workers"`
+
+	// Python is the interpreter that runs the workers. It must be able to
+	// import scancode. If it is empty, then we look for the one next to the
+	// scancode in our $PATH.
+	Python string `json:"python"`
+}
+
+// Validate returns an error if these options are not valid.
+func (obj *ScancodeOptions) Validate() error {
+	if obj == nil {
+		return nil
+	}
+	if obj.Processes < 0 {
+		return fmt.Errorf("scancode processes must not be negative")
+	}
+	if obj.LicenseScore < 0 || obj.LicenseScore > 100 {
+		return fmt.Errorf("scancode license score must be between 0 and 100")
+	}
+	for _, x := range obj.Plugins {
+		if !strings.HasPrefix(x, "-") {
+			return fmt.Errorf("scancode plugin %s is not an option", x)
+		}
+	}
+	if obj.Workers < 0 {
+		return fmt.Errorf("scancode workers must not be negative")
+	}
+	if obj.Workers > 0 && len(obj.Plugins) > 0 {
+		return fmt.Errorf("scancode plugins can't be used with workers")
+	}
+	return nil
+}
+
+// timeout returns the per-file timeout in seconds, or zero if there is none.
+func (obj *ScancodeOptions) timeout() int {
+	if obj == nil || obj.Timeout == 0 {
+		return ScancodeDefaultTimeout
+	}
+	if obj.Timeout < 0 {
+		return 0
+	}
+	return obj.Timeout
+}
+
+// resultArgs returns the args which can change the results of a scan.
+func (obj *ScancodeOptions) resultArgs() []string {
+	args := []string{}
+	if obj == nil {
+		return args
+	}
+	if obj.LicenseScore > 0 {
+		args = append(args, "--license-score", strconv.Itoa(obj.LicenseScore))
+	}
+	return append(args, obj.Plugins...)
+}
+
+// args returns all of the args that these options add to a scancode run.
+func (obj *ScancodeOptions) args() []string {
+	args := []string{}
+	if obj != nil && obj.Processes > 0 {
+		args = append(args, "--processes", strconv.Itoa(obj.Processes))
+	}
+	if timeout := obj.timeout(); timeout > 0 {
+		args = append(args, "--timeout", strconv.Itoa(timeout))
+	}
+	return append(args, obj.resultArgs()...)
+}
+
 // Scancode is based on the python scancode project. It uses their heuristic to
 // identify licenses and other things. It would probably be pretty easy to just
 // take the core license identification heuristic and implement it in pure
 // golang and then use it that way. At the moment, this is not as efficient as
-// it could be because we spawn many slow separate python processes to scan.
+// it could be because scancode is slow to start up, so where it can, this runs
+// a single scancode process over the whole root of each iterator, and only the
+// paths which are not under one get a separate python process each to scan.
 // Please note that the project spells it ScanCode, but here we use Scancode.
 type Scancode struct {
 	Debug bool
 	Logf  func(format string, v ...interface{})
+
+	// Options are passed through to each scancode run. If this is nil, then
+	// the defaults are used.
+	Options *ScancodeOptions
+
+	// Raw keeps the compressed json output of scancode in each result, so
+	// that it can be reviewed later on. This makes the results much larger.
+	Raw bool
+
+	// version is the output of scancode --version, which also includes the
+	// version of the license database that it uses.
+	version string
+
+	// pool is the set of warm workers if we're using them, otherwise nil.
+	pool *scancodePool
 }
 
 func (obj *Scancode) String() string {
@@ -60,6 +187,10 @@ func (obj *Scancode) String() string {
 }
 
 func (obj *Scancode) Setup(ctx context.Context) error {
+	if err := obj.Options.Validate(); err != nil {
+		return err
+	}
+
 	// This runs --help the first time to warm up scancode and finish the
 	// setup in case it wasn't done previously. This is a silly way for it
 	// to be built, but we'll go with it for now. This also checks that it
@@ -91,12 +222,52 @@ func (obj *Scancode) Setup(ctx context.Context) error {
 		return errwrap.Wrapf(err, "error running: %s", prog)
 	}
 
+	args = []string{"--version"}
+	prog = fmt.Sprintf("%s %s", ScancodeProgram, strings.Join(args, " "))
+	obj.Logf("running: %s", prog)
+	cmd = exec.CommandContext(ctx, ScancodeProgram, args...)
+	cmd.Dir = ""
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+		Pgid:    0,
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return errwrap.Wrapf(err, "error running: %s", prog)
+	}
+	obj.version = strings.TrimSpace(string(out))
+
+	if obj.Options != nil && obj.Options.Workers > 0 && obj.pool == nil {
+		python := obj.Options.Python
+		if python == "" {
+			python = scancodePython()
+		}
+		pool := newScancodePool(obj.Options.Workers, python, obj.Logf)
+		if err := pool.warm(ctx); err != nil {
+			return err
+		}
+		obj.pool = pool
+	}
+
 	return nil
 }
 
+// Version returns the version of scancode and of its license database, along
+// with any of our options which change the results. This is only valid after
+// Setup has run.
+func (obj *Scancode) Version() string {
+	version := obj.version
+	if args := obj.Options.resultArgs(); len(args) > 0 {
+		version += "\n" + strings.Join(args, " ")
+	}
+	if obj.Raw {
+		version += "\nraw" // cached results without it aren't enough
+	}
+	return version
+}
+
 func (obj *Scancode) ScanPath(ctx context.Context, path safepath.Path, info *interfaces.Info) (*interfaces.Result, error) {
 
-	// TODO: eventually we can have scancode operate on whole dirs
 	if info.FileInfo.IsDir() { // path.IsDir() should be the same.
 		return nil, nil // skip
 	}
@@ -106,38 +277,28 @@ func (obj *Scancode) ScanPath(ctx context.Context, path safepath.Path, info *int
 
 	filename := path.Path()
 
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	// TODO: --processes $NUM_CPUS
-	args := []string{"--license", "--copyright", "--full-root", "--json-pp", "-", filename}
-
-	prog := fmt.Sprintf("%s %s", ScancodeProgram, strings.Join(args, " "))
-
-	// TODO: add a progress bar of some sort somewhere
-	if obj.Debug {
-		obj.Logf("running: %s", prog)
+	if obj.pool != nil {
+		return obj.scanWorker(ctx, filename)
 	}
 
-	// TODO: do we need to do the ^C handling?
-	// XXX: is the ^C context cancellation propagating into this correctly?
-	cmd := exec.CommandContext(ctx, ScancodeProgram, args...)
-
-	cmd.Dir = ""
-	//cmd.Env = []string{} // XXX: don't nuke python, filter eventually
-
-	// ignore signals sent to parent process (we're in our own group)
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true,
-		Pgid:    0,
+	var timeout time.Duration
+	if seconds := obj.Options.timeout(); seconds > 0 {
+		timeout = time.Duration(seconds)*time.Second + ScancodeTimeoutGrace
 	}
 
-	out, err := cmd.Output()
+	raw, err := obj.run(ctx, obj.Options.args(), filename, timeout)
+	if err == errScancodeTimeout {
+		obj.Logf("scancode timed out after %s on: %s", timeout, filename)
+		return &interfaces.Result{
+			Licenses:   []*licenses.License{},
+			Confidence: 1.0,
+			Skip:       fmt.Errorf("scancode timed out after %s", timeout),
+		}, nil
+	}
 	if err != nil {
-		return nil, errwrap.Wrapf(err, "error running: %s", prog)
+		return nil, err
 	}
-
-	buffer := bytes.NewBuffer(out)
+	buffer := bytes.NewBuffer(raw)
 	decoder := json.NewDecoder(buffer)
 
 	var scancodeOutput ScancodeOutput // this gets populated during decode
@@ -159,9 +320,21 @@ func (obj *Scancode) ScanPath(ctx context.Context, path safepath.Path, info *int
 
 		// TODO: is this how this works?
 		if errs := x.ScanErrors; len(errs) > 0 {
+			timedOut := false
 			for i, e := range errs {
 				obj.Logf("scancode error at path: %s", filename)
 				obj.Logf("scancode error(%d): %s", i, e)
+				if s, ok := e.(string); ok && strings.Contains(s, "timeout") {
+					timedOut = true
+				}
+			}
+			// scancode enforces its own per-file timeout too
+			if timedOut {
+				return &interfaces.Result{
+					Licenses:   []*licenses.License{},
+					Confidence: 1.0,
+					Skip:       fmt.Errorf("scancode timed out"),
+				}, nil
 			}
 			return nil, fmt.Errorf("scancode got multiple errors")
 		}
@@ -213,9 +386,183 @@ func (obj *Scancode) ScanPath(ctx context.Context, path safepath.Path, info *int
 		return nil, err
 	}
 
+	if result, err = deduplicateResult(result); err != nil {
+		return nil, err
+	}
+	if obj.Raw {
+		if result.Raw, err = compressRaw(raw); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// ScanRoot runs a single scancode process over everything under this root, and
+// returns the results for each file that it found something in. This is much
+// faster than running it once per file, since most of the time is spent while
+// scancode starts up. There's no overall timeout here, since scancode enforces
+// the per-file one itself, and reports it like any other error for that file.
+// If we have a pool of workers, then this declines, so that each path gets sent
+// to one of them instead.
+func (obj *Scancode) ScanRoot(ctx context.Context, path safepath.Path, info *interfaces.Info) (interfaces.ResultSet, error) {
+	if obj.pool != nil {
+		return nil, nil // use ScanPath
+	}
+
+	args := obj.Options.args()
+	if obj.Options == nil || obj.Options.Processes == 0 {
+		args = append([]string{"--processes", strconv.Itoa(runtime.NumCPU())}, args...)
+	}
+	// scancode does its own walk, so it needs to skip what we skip too
+	for _, x := range iterator.SkipDirPaths {
+		args = append(args, "--ignore", strings.TrimSuffix(x, "/"))
+	}
+
+	raw, err := obj.run(ctx, args, path.Path(), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var scancodeOutput ScancodeOutput // this gets populated during decode
+	if err := json.Unmarshal(raw, &scancodeOutput); err != nil {
+		// programming error, report this to us please
+		return nil, errwrap.Wrapf(err, "error decoding scancode json output")
+	}
+
+	resultSet := make(interfaces.ResultSet)
+	for _, x := range scancodeOutput.Files {
+		if x.Type != "file" {
+			continue
+		}
+		p := x.Path
+		// some versions of scancode are apparently not including this!
+		if !strings.HasPrefix(p, "/") {
+			p = "/" + p
+		}
+
+		result, err := obj.fileResult(p, x)
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "error with: %s", p)
+		}
+		if result == nil {
+			continue
+		}
+		if obj.Raw {
+			b, err := json.Marshal(x)
+			if err != nil {
+				return nil, err
+			}
+			if result.Raw, err = compressRaw(b); err != nil {
+				return nil, err
+			}
+		}
+		resultSet[p] = map[interfaces.Backend]*interfaces.Result{
+			obj: result,
+		}
+	}
+	if obj.Debug {
+		obj.Logf("scancode found results in %d of %d paths", len(resultSet), len(scancodeOutput.Files))
+	}
+
+	return resultSet, nil
+}
+
+// fileResult converts the scancode output for a single file from a root scan or
+// from a worker into our result. Unlike when we run scancode on a single path,
+// an error with the file is reported in its result, so that it doesn't fail the
+// whole scan.
+func (obj *Scancode) fileResult(p string, x *ScancodeFileResult) (*interfaces.Result, error) {
+	if errs := x.ScanErrors; len(errs) > 0 {
+		var skip error
+		for i, e := range errs {
+			obj.Logf("scancode error at path: %s", p)
+			obj.Logf("scancode error(%d): %s", i, e)
+			if s, ok := e.(string); ok && strings.Contains(s, "timeout") {
+				skip = errwrap.Append(skip, fmt.Errorf("scancode timed out"))
+				continue
+			}
+			skip = errwrap.Append(skip, fmt.Errorf("scancode error: %v", e))
+		}
+		return &interfaces.Result{
+			Licenses:   []*licenses.License{},
+			Confidence: 1.0,
+			Skip:       skip,
+		}, nil
+	}
+
+	if len(x.Licenses) == 0 {
+		return nil, nil
+	}
+
+	result, err := scancodeLicensesHelper(x.Licenses, nil)
+	if err != nil {
+		return nil, err
+	}
 	return deduplicateResult(result)
 }
 
+// run runs scancode with these extra args on the target, which can either be a
+// file or a directory, and returns the json output. If the timeout is positive
+// and it runs for longer than that, then it returns errScancodeTimeout.
+func (obj *Scancode) run(ctx context.Context, extra []string, target string, timeout time.Duration) ([]byte, error) {
+	parentCtx := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(parentCtx, timeout)
+	}
+	defer cancel()
+
+	args := []string{"--license", "--copyright", "--full-root", "--json-pp", "-"}
+	args = append(args, extra...)
+	args = append(args, target)
+
+	prog := fmt.Sprintf("%s %s", ScancodeProgram, strings.Join(args, " "))
+
+	// TODO: add a progress bar of some sort somewhere
+	if obj.Debug {
+		obj.Logf("running: %s", prog)
+	}
+
+	// TODO: do we need to do the ^C handling?
+	cmd := exec.Command(ScancodeProgram, args...)
+
+	cmd.Dir = ""
+	//cmd.Env = []string{} // XXX: don't nuke python, filter eventually
+
+	// ignore signals sent to parent process (we're in our own group)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+		Pgid:    0,
+	}
+
+	buffer := &bytes.Buffer{}
+	cmd.Stdout = buffer
+	if err := cmd.Start(); err != nil {
+		return nil, errwrap.Wrapf(err, "error running: %s", prog)
+	}
+	// When the context closes, we kill the whole process group, since the
+	// worker processes would otherwise keep running, and would also keep
+	// our end of the output pipe open.
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) // ignore err
+		case <-done:
+		}
+	}()
+	err := cmd.Wait()
+	close(done)
+	if err != nil && ctx.Err() == context.DeadlineExceeded && parentCtx.Err() == nil {
+		return nil, errScancodeTimeout
+	}
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "error running: %s", prog)
+	}
+
+	return buffer.Bytes(), nil
+}
+
 // ScancodeOutput is modelled after the scancode output format.
 //
 // example:
@@ -0,0 +1,326 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+const (
+	// ScancodeDefaultPython is the python interpreter that runs the pool of
+	// scancode workers when we can't find the one that scancode uses.
+	ScancodeDefaultPython = "python3"
+
+	// scancodeWorkerScript is run by each worker. It loads the scancode
+	// license index once, and then scans each path that we send it on a
+	// line of stdin, and sends back a line of json that's in the same
+	// format as an entry of the files list in the normal scancode output.
+	scancodeWorkerScript = `
+import json, sys
+from scancode.api import get_copyrights, get_licenses
+from licensedcode.cache import get_index
+get_index()
+sys.stdout.write(json.dumps({"ready": True}) + "\n")
+sys.stdout.flush()
+for line in sys.stdin:
+    req = json.loads(line)
+    res = {"path": req["path"], "type": "file", "scan_errors": []}
+    try:
+        res.update(get_licenses(req["path"], min_score=req["min_score"]))
+        res.update(get_copyrights(req["path"]))
+    except Exception as e:
+        res["scan_errors"].append(repr(e))
+    sys.stdout.write(json.dumps(res) + "\n")
+    sys.stdout.flush()
+`
+)
+
+// scancodeWorkerRequest is what we send to a worker for each path.
+type scancodeWorkerRequest struct {
+	Path     string `json:"path"`
+	MinScore int    `json:"min_score"`
+}
+
+// scancodeWorker is a warm python process which has scancode loaded, and which
+// scans each path that we send it, one at a time.
+type scancodeWorker struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// kill stops the worker and all of its children.
+func (obj *scancodeWorker) kill() {
+	syscall.Kill(-obj.cmd.Process.Pid, syscall.SIGKILL) // ignore err
+	obj.cmd.Wait()                                      // ignore err
+}
+
+// scan sends the path to the worker and waits for it to send back the result.
+func (obj *scancodeWorker) scan(request *scancodeWorkerRequest) (*ScancodeFileResult, []byte, error) {
+	b, err := json.Marshal(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := obj.stdin.Write(append(b, '\n')); err != nil {
+		return nil, nil, errwrap.Wrapf(err, "could not send to the scancode worker")
+	}
+	line, err := obj.stdout.ReadBytes('\n')
+	if err != nil {
+		return nil, nil, errwrap.Wrapf(err, "could not read from the scancode worker")
+	}
+	fileResult := &ScancodeFileResult{}
+	if err := json.Unmarshal(line, fileResult); err != nil {
+		return nil, nil, errwrap.Wrapf(err, "error decoding scancode worker output")
+	}
+	return fileResult, line, nil
+}
+
+// scancodePool is a fixed number of scancode workers, which are each started
+// when they are first needed, and again each time that one has to be killed.
+type scancodePool struct {
+	python string
+	logf   func(format string, v ...interface{})
+
+	// workers holds each idle worker. A nil entry is one that needs to be
+	// started before it can be used.
+	workers chan *scancodeWorker
+}
+
+// newScancodePool builds a pool of this size, but doesn't start any workers.
+func newScancodePool(size int, python string, logf func(format string, v ...interface{})) *scancodePool {
+	pool := &scancodePool{
+		python:  python,
+		logf:    logf,
+		workers: make(chan *scancodeWorker, size),
+	}
+	for i := 0; i < size; i++ {
+		pool.workers <- nil
+	}
+	return pool
+}
+
+// warm starts every worker in parallel, so that we wait for them to load the
+// license index once at the start, instead of in the middle of the scan.
+func (obj *scancodePool) warm(ctx context.Context) error {
+	size := cap(obj.workers)
+	wg := &sync.WaitGroup{}
+	mu := &sync.Mutex{}
+	var reterr error
+	for i := 0; i < size; i++ {
+		w := <-obj.workers
+		wg.Add(1)
+		go func(w *scancodeWorker) {
+			defer wg.Done()
+			var err error
+			if w == nil {
+				if w, err = obj.start(ctx); err != nil {
+					mu.Lock()
+					reterr = errwrap.Append(reterr, err)
+					mu.Unlock()
+				}
+			}
+			obj.workers <- w // nil if it failed
+		}(w)
+	}
+	wg.Wait()
+	return reterr
+}
+
+// start runs a new worker and waits until it's ready.
+func (obj *scancodePool) start(ctx context.Context) (*scancodeWorker, error) {
+	prog := fmt.Sprintf("%s -c <scancode worker>", obj.python)
+	obj.logf("running: %s", prog)
+
+	// This isn't tied to the context, since it outlives this scan.
+	cmd := exec.Command(obj.python, "-c", scancodeWorkerScript)
+	cmd.Dir = ""
+	cmd.Stderr = os.Stderr // python tracebacks are useful to see
+	// ignore signals sent to parent process (we're in our own group)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+		Pgid:    0,
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errwrap.Wrapf(err, "error running: %s", prog)
+	}
+	worker := &scancodeWorker{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}
+
+	ready := make(chan error, 1)
+	go func() {
+		_, err := worker.stdout.ReadBytes('\n') // the ready line
+		ready <- err
+	}()
+	select {
+	case err := <-ready:
+		if err != nil {
+			worker.kill()
+			return nil, errwrap.Wrapf(err, "the scancode worker didn't start, can %s import scancode?", obj.python)
+		}
+	case <-ctx.Done():
+		worker.kill()
+		return nil, ctx.Err()
+	}
+	return worker, nil
+}
+
+// scan runs the request on the next idle worker. If the timeout is positive and
+// the worker takes longer than that, then it's killed, and errScancodeTimeout is
+// returned.
+func (obj *scancodePool) scan(ctx context.Context, request *scancodeWorkerRequest, timeout time.Duration) (*ScancodeFileResult, []byte, error) {
+	var worker *scancodeWorker
+	select {
+	case worker = <-obj.workers:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	if worker == nil {
+		var err error
+		if worker, err = obj.start(ctx); err != nil {
+			obj.workers <- nil // try again next time
+			return nil, nil, err
+		}
+	}
+
+	type response struct {
+		fileResult *ScancodeFileResult
+		line       []byte
+		err        error
+	}
+	ch := make(chan *response, 1)
+	go func() {
+		fileResult, line, err := worker.scan(request)
+		ch <- &response{fileResult, line, err}
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			worker.kill() // it's in an unknown state
+			obj.workers <- nil
+			return nil, nil, r.err
+		}
+		obj.workers <- worker
+		return r.fileResult, r.line, nil
+	case <-expired:
+		worker.kill()
+		obj.workers <- nil
+		return nil, nil, errScancodeTimeout
+	case <-ctx.Done():
+		worker.kill()
+		obj.workers <- nil
+		return nil, nil, ctx.Err()
+	}
+}
+
+// scancodePython returns the python interpreter that the scancode in our $PATH
+// uses, if it's in one of the usual places for a release of it. Otherwise it
+// returns ScancodeDefaultPython, which might still work if it was installed with
+// pip.
+func scancodePython() string {
+	p, err := exec.LookPath(ScancodeProgram)
+	if err != nil {
+		return ScancodeDefaultPython
+	}
+	if p, err = filepath.EvalSymlinks(p); err != nil {
+		return ScancodeDefaultPython
+	}
+	dir := filepath.Dir(p)
+	for _, x := range []string{"venv/bin/python", "bin/python", "python"} {
+		if _, err := os.Stat(filepath.Join(dir, x)); err == nil {
+			return filepath.Join(dir, x)
+		}
+	}
+	return ScancodeDefaultPython
+}
+
+// scanWorker scans a single path with the pool of workers.
+func (obj *Scancode) scanWorker(ctx context.Context, filename string) (*interfaces.Result, error) {
+	var timeout time.Duration
+	if seconds := obj.Options.timeout(); seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	request := &scancodeWorkerRequest{
+		Path: filename,
+	}
+	if obj.Options != nil {
+		request.MinScore = obj.Options.LicenseScore
+	}
+
+	fileResult, line, err := obj.pool.scan(ctx, request, timeout)
+	if err == errScancodeTimeout {
+		obj.Logf("scancode timed out after %s on: %s", timeout, filename)
+		return &interfaces.Result{
+			Licenses:   []*licenses.License{},
+			Confidence: 1.0,
+			Skip:       fmt.Errorf("scancode timed out after %s", timeout),
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := obj.fileResult(filename, fileResult)
+	if err != nil || result == nil {
+		return nil, err
+	}
+	if obj.Raw {
+		if result.Raw, err = compressRaw(line); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
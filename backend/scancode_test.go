@@ -0,0 +1,274 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/backend"
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+// fakeScancode records the args it gets, and then reports no licenses.
+const fakeScancode = `#!/bin/sh
+if [ "$1" = "--version" ]; then
+	echo "ScanCode version 0.0.0"
+	exit 0
+fi
+echo "$@" > "$FAKE_SCANCODE_ARGS"
+for last; do :; done
+printf '{"files": [{"path": "%s", "type": "file", "licenses": []}]}' "$last"
+`
+
+func TestScancodeOptions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, backend.ScancodeProgram), []byte(fakeScancode), 0700); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	argsFile := filepath.Join(dir, "args")
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	defer os.Unsetenv("FAKE_SCANCODE_ARGS")
+	os.Setenv("FAKE_SCANCODE_ARGS", argsFile)
+
+	input := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(input, []byte("hello\n"), 0600); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	absFile, err := safepath.ParseIntoAbsFile(input)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	fileInfo, err := os.Stat(input)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	scancode := &backend.Scancode{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf(format, v...)
+		},
+		Options: &backend.ScancodeOptions{
+			Processes:    4,
+			Timeout:      60,
+			LicenseScore: 50,
+			Plugins:      []string{"--classify"},
+		},
+	}
+	if err := scancode.Setup(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if v := scancode.Version(); !strings.Contains(v, "--license-score 50 --classify") {
+		t.Errorf("expected the version to include the options, got: %s", v)
+	}
+	if v := scancode.Version(); strings.Contains(v, "--processes") {
+		t.Errorf("expected the version to not include the processes, got: %s", v)
+	}
+
+	info := &interfaces.Info{FileInfo: fileInfo}
+	if _, err := scancode.ScanPath(context.Background(), absFile, info); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	b, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	exp := "--processes 4 --timeout 60 --license-score 50 --classify " + input
+	if args := strings.TrimSpace(string(b)); !strings.HasSuffix(args, exp) {
+		t.Errorf("expected args ending in: %s, got: %s", exp, args)
+	}
+}
+
+func TestScancodeOptionsValidate(t *testing.T) {
+	tests := []struct {
+		options *backend.ScancodeOptions
+		valid   bool
+	}{
+		{nil, true},
+		{&backend.ScancodeOptions{}, true},
+		{&backend.ScancodeOptions{Timeout: -1}, true},
+		{&backend.ScancodeOptions{Processes: -1}, false},
+		{&backend.ScancodeOptions{LicenseScore: 101}, false},
+		{&backend.ScancodeOptions{Plugins: []string{"classify"}}, false},
+		{&backend.ScancodeOptions{Workers: -1}, false},
+		{&backend.ScancodeOptions{Workers: 2, Plugins: []string{"--classify"}}, false},
+	}
+	for i, x := range tests {
+		if err := x.options.Validate(); (err == nil) != x.valid {
+			t.Errorf("test %d: expected valid: %t, got: %+v", i, x.valid, err)
+		}
+	}
+}
+
+// fakeScancodeMIT reports that every file is MIT licensed.
+const fakeScancodeMIT = `#!/bin/sh
+if [ "$1" = "--version" ]; then
+	echo "ScanCode version 0.0.0"
+	exit 0
+fi
+for last; do :; done
+printf '{"files": [{"path": "%s", "type": "file", "licenses": [{"key": "mit", "score": 100.0, "spdx_license_key": "MIT"}]}]}' "$last"
+`
+
+func TestScancodeRaw(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, backend.ScancodeProgram), []byte(fakeScancodeMIT), 0700); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+
+	input := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(input, []byte("hello\n"), 0600); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	absFile, err := safepath.ParseIntoAbsFile(input)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	fileInfo, err := os.Stat(input)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	info := &interfaces.Info{FileInfo: fileInfo}
+
+	for _, raw := range []bool{false, true} {
+		scancode := &backend.Scancode{
+			Logf: func(format string, v ...interface{}) {
+				t.Logf(format, v...)
+			},
+			Raw: raw,
+		}
+		if err := scancode.Setup(context.Background()); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+		result, err := scancode.ScanPath(context.Background(), absFile, info)
+		if err != nil || result == nil {
+			t.Errorf("unexpected result: %+v, error: %+v", result, err)
+			return
+		}
+		if !raw {
+			if result.Raw != nil {
+				t.Errorf("expected no raw output")
+			}
+			continue
+		}
+		b, err := backend.DecompressRaw(result.Raw)
+		if err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+		if !strings.Contains(string(b), `"spdx_license_key": "MIT"`) {
+			t.Errorf("unexpected raw output: %s", b)
+		}
+	}
+}
+
+// fakeScancodeWorker is started as the python interpreter, and reports that
+// every file it gets sent is MIT licensed.
+const fakeScancodeWorker = `#!/bin/sh
+echo '{"ready": true}'
+while read line; do
+	echo '{"type": "file", "licenses": [{"key": "mit", "score": 100.0, "spdx_license_key": "MIT"}]}'
+done
+`
+
+func TestScancodeWorkers(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, backend.ScancodeProgram), []byte(fakeScancode), 0700); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	python := filepath.Join(dir, "python")
+	if err := os.WriteFile(python, []byte(fakeScancodeWorker), 0700); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+
+	input := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(input, []byte("hello\n"), 0600); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	absFile, err := safepath.ParseIntoAbsFile(input)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	fileInfo, err := os.Stat(input)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	info := &interfaces.Info{FileInfo: fileInfo, Root: true}
+
+	scancode := &backend.Scancode{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf(format, v...)
+		},
+		Options: &backend.ScancodeOptions{
+			Workers: 2,
+			Python:  python,
+		},
+	}
+	if err := scancode.Setup(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if resultSet, err := scancode.ScanRoot(context.Background(), absFile, info); resultSet != nil || err != nil {
+		t.Errorf("expected the root scan to be declined, got: %+v, error: %+v", resultSet, err)
+	}
+	for i := 0; i < 3; i++ { // more than the number of workers
+		result, err := scancode.ScanPath(context.Background(), absFile, info)
+		if err != nil || result == nil {
+			t.Errorf("unexpected result: %+v, error: %+v", result, err)
+			return
+		}
+		if len(result.Licenses) != 1 || result.Licenses[0].SPDX != "MIT" {
+			t.Errorf("unexpected licenses: %+v", result.Licenses)
+		}
+	}
+}
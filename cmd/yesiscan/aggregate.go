@@ -0,0 +1,123 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/ansi"
+	"github.com/awslabs/yesiscan/util/errwrap"
+
+	cli "github.com/urfave/cli/v2" // imports as package "cli"
+)
+
+// Aggregate merges many stored json outputs into a single roll-up report. Each
+// arg is either a json output file, or a directory which is searched for them.
+func Aggregate(c *cli.Context, program, version string, debug bool) error {
+	logf := (&ansi.Logf{
+		Prefix:   "main: ",
+		Ellipsis: "...",
+		Enable:   false,
+		Prefixes: []string{},
+	}).Init()
+
+	if c.NArg() == 0 {
+		return cli.ShowSubcommandHelp(c)
+	}
+
+	perms, err := interfaces.ParsePerms(c.String("permissions"))
+	if err != nil {
+		return err
+	}
+
+	paths, err := JSONOutputPaths(c.Args().Slice())
+	if err != nil {
+		return err
+	}
+
+	aggregate := &lib.Aggregate{}
+	for _, path := range paths {
+		output, err := lib.ReadJSONOutput(path)
+		if err != nil {
+			return errwrap.Wrapf(err, "could not read output %s", path)
+		}
+		aggregate.Add(path, output)
+	}
+
+	var s string
+	switch outputType := c.String("output-type"); outputType {
+	case "csv":
+		s, err = lib.ReturnAggregateCSV(aggregate)
+	case "html":
+		s, err = lib.ReturnAggregateHtml(aggregate, c.StringSlice("license"))
+	default:
+		return fmt.Errorf("unknown output type: %s", outputType)
+	}
+	if err != nil {
+		return err
+	}
+
+	// NOTE: we only log when writing to a file, to keep stdout clean
+	if outputPath := c.String("output-path"); outputPath != "" && outputPath != "-" {
+		logf("writing an aggregate of %d outputs to: %s", len(paths), outputPath)
+		return os.WriteFile(outputPath, []byte(s), perms.FileMode())
+	}
+	_, err = fmt.Print(s) // to stdout
+	return err
+}
+
+// JSONOutputPaths returns the paths of the stored json outputs. Each arg is
+// either one of them, or a directory which is searched for them.
+func JSONOutputPaths(args []string) ([]string, error) {
+	paths := []string{}
+	for _, arg := range args {
+		fileInfo, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !fileInfo.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".json") {
+				paths = append(paths, path) // walk is in lexical order
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,152 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/awslabs/yesiscan"
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/ansi"
+	"github.com/awslabs/yesiscan/util/errwrap"
+
+	cli "github.com/urfave/cli/v2" // imports as package "cli"
+)
+
+// Bench runs a single scan with cpu and heap profiling enabled, and prints out
+// the time spent in each stage. This is used to track performance regressions
+// across releases. The pprof files can be examined with `go tool pprof`.
+func Bench(c *cli.Context, program, version string, debug bool) error {
+	logf := (&ansi.Logf{
+		Prefix:   "main: ",
+		Ellipsis: "...",
+		Enable:   false,
+		Prefixes: []string{},
+	}).Init()
+	logf("Hello from purpleidea! This is %s, version: %s", program, version)
+	defer logf("Done!")
+
+	if c.NArg() == 0 {
+		return fmt.Errorf("nothing to benchmark, specify at least one uri")
+	}
+
+	var backends map[string]bool // nil means all of them
+	if c.IsSet("backend") {
+		backends = make(map[string]bool)
+		for _, x := range c.StringSlice("backend") {
+			backends[x] = true
+		}
+	}
+
+	perms, err := interfaces.ParsePerms(c.String("permissions"))
+	if err != nil {
+		return err
+	}
+
+	y, err := yesiscan.New(&yesiscan.Options{
+		Program: program,
+		Version: version,
+		Debug:   debug,
+		Logf: func(format string, v ...interface{}) {
+			logf("lib: "+format, v...)
+		},
+		Backends: backends,
+		Perms:    perms,
+		Timings:  true,
+	})
+	if err != nil {
+		return err
+	}
+
+	cpuProfile := c.String("cpu-profile")
+	heapProfile := c.String("heap-profile")
+
+	f, err := os.OpenFile(cpuProfile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perms.FileMode())
+	if err != nil {
+		return errwrap.Wrapf(err, "could not create cpu profile")
+	}
+	defer f.Close()
+	if err := pprof.StartCPUProfile(f); err != nil {
+		return errwrap.Wrapf(err, "could not start cpu profile")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	start := time.Now()
+	output, err := y.Scan(ctx, c.Args().Slice()...)
+	elapsed := time.Since(start)
+	pprof.StopCPUProfile()
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return errwrap.Wrapf(err, "could not write cpu profile")
+	}
+	logf("wrote cpu profile to: %s", cpuProfile)
+
+	h, err := os.OpenFile(heapProfile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perms.FileMode())
+	if err != nil {
+		return errwrap.Wrapf(err, "could not create heap profile")
+	}
+	defer h.Close()
+	runtime.GC() // get up-to-date statistics
+	if err := pprof.WriteHeapProfile(h); err != nil {
+		return errwrap.Wrapf(err, "could not write heap profile")
+	}
+	if err := h.Close(); err != nil {
+		return errwrap.Wrapf(err, "could not write heap profile")
+	}
+	logf("wrote heap profile to: %s", heapProfile)
+
+	fmt.Print(BenchTable(output.Timings, elapsed))
+	return nil
+}
+
+// BenchTable returns a table of the time spent in each stage, followed by the
+// total time that the whole scan took.
+func BenchTable(timings []*lib.Timing, elapsed time.Duration) string {
+	s := &strings.Builder{}
+	w := tabwriter.NewWriter(s, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "stage\tcount\ttotal\taverage\n")
+	for _, t := range timings {
+		average := time.Duration(0)
+		if t.Count > 0 {
+			average = t.Duration / time.Duration(t.Count)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", t.Stage, t.Count, t.Duration, average)
+	}
+	fmt.Fprintf(w, "elapsed\t\t%s\t\n", elapsed)
+	w.Flush()
+	return s.String()
+}
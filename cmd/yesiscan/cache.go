@@ -0,0 +1,101 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/ansi"
+	"github.com/awslabs/yesiscan/util/safepath"
+
+	"github.com/awslabs/yesiscan/util/errwrap"
+
+	cli "github.com/urfave/cli/v2" // imports as package "cli"
+)
+
+// ReadCacheSigningKey reads the signing key of the remote cache from a file. The
+// whitespace around it is removed, so a key that was generated as text, for
+// example with `openssl rand -hex 32`, can be used as is.
+func ReadCacheSigningKey(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "could not read the cache signing key")
+	}
+	return bytes.TrimSpace(b), nil
+}
+
+// NewCacheRemote builds the remote cache in this S3 bucket, and reads the key
+// that its entries are signed with. If the bucket is empty, then there is no
+// remote cache, and this returns nil.
+func NewCacheRemote(debug bool, logf func(format string, v ...interface{}), region, bucket, prefix, keyPath string) (lib.CacheRemote, []byte, error) {
+	if bucket == "" {
+		return nil, nil, nil
+	}
+	if keyPath == "" {
+		return nil, nil, fmt.Errorf("the shared cache needs a signing key")
+	}
+	key, err := ReadCacheSigningKey(keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	remote := &lib.S3CacheRemote{
+		Debug: debug,
+		Logf: func(format string, v ...interface{}) {
+			logf("cache: "+format, v...)
+		},
+
+		Region: region,
+		Bucket: bucket,
+		Prefix: prefix,
+	}
+	return remote, key, nil
+}
+
+// CacheClean removes all of the cached backend results. The cache keys already
+// include the backend and license database versions, so this is only needed to
+// reclaim the disk space used by stale entries, or if a backend has changed in
+// a way that its version doesn't show.
+func CacheClean(c *cli.Context, program, version string, debug bool) error {
+	logf := (&ansi.Logf{
+		Prefix:   "main: ",
+		Ellipsis: "...",
+		Enable:   false,
+		Prefixes: []string{},
+	}).Init()
+
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return err
+	}
+	prefix, err := safepath.ParseIntoAbsDir(filepath.Join(userCacheDir, program) + "/")
+	if err != nil {
+		return err
+	}
+	logf("removing cached results from: %s", safepath.JoinToAbsDir(prefix, safepath.UnsafeParseIntoRelDir(lib.CacheDir)))
+	return lib.CleanCache(prefix)
+}
@@ -0,0 +1,69 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/errwrap"
+
+	cli "github.com/urfave/cli/v2" // imports as package "cli"
+)
+
+// Diff compares two stored json outputs and prints the paths whose license
+// determinations were added, removed, or changed between them.
+func Diff(c *cli.Context, program, version string, debug bool) error {
+	if c.NArg() != 2 {
+		return cli.ShowSubcommandHelp(c)
+	}
+	args := c.Args().Slice()
+
+	old, err := lib.ReadJSONOutput(args[0])
+	if err != nil {
+		return errwrap.Wrapf(err, "could not read output %s", args[0])
+	}
+	new, err := lib.ReadJSONOutput(args[1])
+	if err != nil {
+		return errwrap.Wrapf(err, "could not read output %s", args[1])
+	}
+	entries := lib.DiffOutputs(old, new)
+
+	var s string
+	switch outputType := c.String("output-type"); outputType {
+	case "text":
+		s = lib.ReturnDiff(entries)
+	case "json":
+		b, err := json.MarshalIndent(entries, "", "\t")
+		if err != nil {
+			return err
+		}
+		s = string(b) + "\n"
+	default:
+		return fmt.Errorf("unknown output type: %s", outputType)
+	}
+	_, err = fmt.Print(s) // to stdout
+	return err
+}
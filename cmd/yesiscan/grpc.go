@@ -0,0 +1,112 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/rpc"
+	"github.com/awslabs/yesiscan/util/ansi"
+
+	cli "github.com/urfave/cli/v2" // imports as package "cli"
+)
+
+// Grpc is the entry point for running this software as a grpc server, for other
+// programs which would rather use rpc than the web ui or the cli.
+func Grpc(c *cli.Context, program, version string, debug bool, messages io.Writer) error {
+	logf := (&ansi.Logf{
+		Prefix:   "main: ",
+		Ellipsis: "...",
+		Enable:   false,
+		Prefixes: []string{},
+	}).Init()
+	logf("Hello from purpleidea! This is %s, version: %s", program, version)
+	defer logf("Done!")
+
+	perms, err := interfaces.ParsePerms(c.String("permissions"))
+	if err != nil {
+		return err
+	}
+
+	server := &rpc.Server{
+		Program: program,
+		Version: version,
+
+		Debug: debug,
+		Logf: func(format string, v ...interface{}) {
+			fmt.Fprintf(messages, "grpc: "+strings.TrimRight(format, "\n")+"\n", v...)
+		},
+
+		Profiles:  c.StringSlice("profile"),
+		Listen:    c.String("listen"),
+		Workspace: c.Bool("workspace"),
+		Perms:     perms,
+
+		TLSCertFile: c.String("tls-cert"),
+		TLSKeyFile:  c.String("tls-key"),
+
+		MaxFiles:    c.Int64("max-files"),
+		MaxBytes:    c.Int64("max-size") * 1024 * 1024, // MiB to bytes
+		MaxDuration: c.Duration("max-time"),
+
+		MaxConcurrentScans: c.Int("max-concurrent-scans"),
+
+		Tokens: make(map[string]string),
+	}
+	if server.MaxFiles < 0 || server.MaxBytes < 0 || server.MaxDuration < 0 {
+		return fmt.Errorf("the scan limits must not be negative")
+	}
+
+	reports, err := NewReportStore(debug, server.Logf, perms, c.String("region"), c.String("report-s3bucket"), c.String("report-s3prefix"), c.String("report-sqlite"))
+	if err != nil {
+		return err
+	}
+	server.Reports = reports // nil for the default
+
+	cacheRemote, cacheSigningKey, err := NewCacheRemote(debug, server.Logf, c.String("region"), c.String("cache-s3bucket"), c.String("cache-s3prefix"), c.String("cache-signing-key-path"))
+	if err != nil {
+		return err
+	}
+	server.CacheRemote = cacheRemote
+	server.CacheSigningKey = cacheSigningKey
+
+	for _, x := range c.StringSlice("auth-token") {
+		i := strings.Index(x, ":")
+		if i <= 0 || i == len(x)-1 {
+			return fmt.Errorf("invalid auth token, expected name:token")
+		}
+		server.Tokens[x[i+1:]] = x[:i]
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return server.Run(ctx)
+}
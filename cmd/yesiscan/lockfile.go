@@ -0,0 +1,158 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/awslabs/yesiscan"
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util"
+	"github.com/awslabs/yesiscan/util/ansi"
+	"github.com/awslabs/yesiscan/util/errwrap"
+
+	cli "github.com/urfave/cli/v2" // imports as package "cli"
+)
+
+// Lockfile compares two versions of a dependency lockfile, and scans only the
+// source of the packages which were added or updated. This is much faster than
+// a scan of the whole tree, so it's useful to review a change to the
+// dependencies of a project.
+func Lockfile(c *cli.Context, program, version string, debug bool) error {
+	logf := (&ansi.Logf{
+		Prefix:   "main: ",
+		Ellipsis: "...",
+		Enable:   false,
+		Prefixes: []string{},
+	}).Init()
+	if c.String("output-type") == "json" { // keep stdout clean
+		logf = func(format string, v ...interface{}) {}
+	}
+
+	if c.NArg() != 2 {
+		return cli.ShowSubcommandHelp(c)
+	}
+	oldPath, newPath := c.Args().Get(0), c.Args().Get(1)
+
+	// One of them is often a temporary copy of an older version, such as
+	// from `git show main:go.sum > /tmp/old`, so either name decides.
+	name := filepath.Base(newPath)
+	if !util.StrInList(name, lib.LockfileNames) {
+		name = filepath.Base(oldPath)
+	}
+	read := func(p string) ([]*lib.LockPackage, error) {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		return lib.ParseLockfile(name, data)
+	}
+	oldPackages, err := read(oldPath)
+	if err != nil {
+		return errwrap.Wrapf(err, "could not read old lockfile")
+	}
+	newPackages, err := read(newPath)
+	if err != nil {
+		return errwrap.Wrapf(err, "could not read new lockfile")
+	}
+
+	changes := lib.LockfileDelta(oldPackages, newPackages)
+	inputs := []string{}
+	checksums := make(map[string]string) // input -> sha256
+	for _, x := range changes {
+		if input := x.Input(); input != "" {
+			inputs = append(inputs, input)
+			if x.Checksum != "" {
+				checksums[input] = x.Checksum
+			}
+		} else {
+			logf("no source to scan for: %s", x.LockPackage)
+		}
+	}
+
+	outputs := make(map[string]*lib.Output)
+	errors := make(map[string]error)
+	if len(inputs) > 0 {
+		var backends map[string]bool // nil means all of them
+		if c.IsSet("backend") {
+			backends = make(map[string]bool)
+			for _, x := range c.StringSlice("backend") {
+				backends[x] = true
+			}
+		}
+		perms, err := interfaces.ParsePerms(c.String("permissions"))
+		if err != nil {
+			return err
+		}
+		y, err := yesiscan.New(&yesiscan.Options{
+			Program: program,
+			Version: version,
+			Debug:   debug,
+			Logf: func(format string, v ...interface{}) {
+				logf("lib: "+format, v...)
+			},
+			Backends:  backends,
+			Profiles:  c.StringSlice("profile"),
+			Checksums: checksums,
+			Offline:   c.Bool("offline"),
+			Perms:     perms,
+			Workspace: true, // don't keep all the downloads
+			Cache:     true,
+		})
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		logf("scanning %d of %d changed packages", len(inputs), len(changes))
+		for _, input := range inputs {
+			if _, exists := outputs[input]; exists {
+				continue // several packages from the same repository
+			}
+			output, err := y.Scan(ctx, input)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				logf("scan of %s failed: %+v", input, errwrap.Cause(err))
+				errors[input] = errwrap.Cause(err)
+			}
+			outputs[input] = output
+		}
+	}
+
+	s, err := lib.ReturnLockDelta(lib.LockDelta(changes, outputs, errors), c.String("output-type"))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Print(s) // to stdout
+	return err
+}
@@ -40,16 +40,23 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"os/user"
 	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/awslabs/yesiscan"
+	"github.com/awslabs/yesiscan/backend"
 	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
 	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/parser"
+	"github.com/awslabs/yesiscan/publish"
 	"github.com/awslabs/yesiscan/s3"
 	"github.com/awslabs/yesiscan/util"
 	"github.com/awslabs/yesiscan/util/ansi"
@@ -57,6 +64,7 @@ import (
 	"github.com/awslabs/yesiscan/util/safepath"
 	"github.com/awslabs/yesiscan/web"
 
+	colour "github.com/fatih/color"
 	"github.com/mitchellh/go-homedir"
 	"github.com/ssgelm/cookiejarparser"
 	cli "github.com/urfave/cli/v2" // imports as package "cli"
@@ -67,16 +75,18 @@ import (
 //go:generate bash -c "git describe --match '[0-9]*.[0-9]*.[0-9]*' --tags --dirty --always > .version"
 
 //go:embed .program
-var program string
+var embeddedProgram string
 
 //go:embed .version
-var version string
+var embeddedVersion string
 
-// autoConfigURI is set via -ldflags build time flags.
-var autoConfigURI string
+// buildAutoConfigURI is set via -ldflags build time flags. It is only ever read
+// and is used as the default value for the auto config URI.
+var buildAutoConfigURI string
 
-// autoConfigCookiePath is set via -ldflags build time flags.
-var autoConfigCookiePath string
+// buildAutoConfigCookiePath is set via -ldflags build time flags. It is only
+// ever read and is used as the default value for the auto config cookie path.
+var buildAutoConfigCookiePath string
 
 const (
 	// ConfigFileName is the name of the config file used to pull in all the
@@ -96,8 +106,25 @@ const (
 	MaxRedirects = 20 // do what firefox does
 )
 
-// CLI is the entry point for the CLI frontend.
-func CLI(program, version string, debug bool) error {
+// Messages is where the errors and other notices that aren't part of the report
+// get printed. This is stderr, so that stdout only ever carries the report,
+// unless the legacy-stdout option asks for the old behaviour.
+type Messages struct {
+	// LegacyStdout prints them to stdout instead.
+	LegacyStdout bool
+}
+
+// Write prints to stderr, or to stdout if the legacy behaviour was asked for.
+func (obj *Messages) Write(p []byte) (int, error) {
+	if obj.LegacyStdout {
+		return os.Stdout.Write(p)
+	}
+	return os.Stderr.Write(p)
+}
+
+// CLI is the entry point for the CLI frontend. The errors and other notices go
+// to messages, which the legacy-stdout option changes.
+func CLI(program, version string, debug bool, messages *Messages) error {
 
 	flags := []cli.Flag{
 		&cli.StringFlag{
@@ -128,6 +155,10 @@ func CLI(program, version string, debug bool) error {
 			Name:  "quiet",
 			Usage: "remove most log messages",
 		},
+		&cli.BoolFlag{
+			Name:  "changed-only",
+			Usage: "scan only the files staged in git and fail on any profile violation, for use in a pre-commit hook",
+		},
 		&cli.BoolFlag{
 			Name:  "ansi-magic",
 			Usage: "do some ansi terminal escape sequence magic",
@@ -136,6 +167,18 @@ func CLI(program, version string, debug bool) error {
 			Name:  "no-ansi-magic",
 			Usage: "do not use the ansi terminal escape sequence magic",
 		},
+		&cli.BoolFlag{
+			Name:  "legacy-stdout",
+			Usage: "print errors and notices to stdout and silence the logs when the output is stdout, like older versions did",
+		},
+		&cli.BoolFlag{
+			Name:  "porcelain",
+			Usage: "print one tab separated line per finding as soon as it's found, instead of the console report",
+		},
+		&cli.StringFlag{
+			Name:  "color",
+			Usage: "colour the console output, one of auto, always, or never",
+		},
 		&cli.StringFlag{
 			Name:  "regexp-path",
 			Usage: "path to regexp rules file",
@@ -144,9 +187,13 @@ func CLI(program, version string, debug bool) error {
 			Name:  "config-path",
 			Usage: "path to the main config file",
 		},
+		&cli.StringFlag{
+			Name:  "preset",
+			Usage: "name of a preset of settings from the config file to use",
+		},
 		&cli.StringFlag{
 			Name:  "output-type",
-			Usage: "output type for reports, one of `html` or `text`",
+			Usage: "output type for reports, one of `html`, `text`, `json`, `scancode`, `ort`, `cyclonedx`, `spdx`, `spdx-json`, `sarif`, `csv`, or `notice`",
 		},
 		&cli.StringFlag{
 			Name:  "output-path",
@@ -160,6 +207,22 @@ func CLI(program, version string, debug bool) error {
 			Name:  "output-s3bucket",
 			Usage: "bucket name to upload to s3",
 		},
+		&cli.BoolFlag{
+			Name:  "redact",
+			Usage: "replace local paths, user names, and internal host names in the reports with placeholders",
+		},
+		&cli.StringSliceFlag{
+			Name:  "public-host",
+			Usage: "host name to leave alone when redacting, in addition to the well known public ones",
+		},
+		&cli.StringSliceFlag{
+			Name:  "display-name",
+			Usage: "show uids which start with a prefix as starting with a friendlier name in the reports, as prefix=name",
+		},
+		&cli.StringSliceFlag{
+			Name:  "smart-uri",
+			Usage: "link the uids which match a regexp to a code browser in the reports, as match=template",
+		},
 		&cli.StringFlag{
 			Name:  "region",
 			Usage: "region to use for s3 api requests",
@@ -168,6 +231,294 @@ func CLI(program, version string, debug bool) error {
 			Name:  "profile",
 			Usage: "license set filtering profile to include",
 		},
+		&cli.StringFlag{
+			Name:  "ignore-path",
+			Usage: "path to the ignore list of content hashes",
+		},
+		&cli.StringFlag{
+			Name:  "curations-path",
+			Usage: "path to the curations file of reviewer decisions by content hash",
+		},
+		&cli.StringFlag{
+			Name:  "obligations-path",
+			Usage: "path to additional license obligations",
+		},
+		&cli.StringSliceFlag{
+			Name:  "first-party",
+			Usage: "path pattern of first-party code, in the .gitignore syntax (may be repeated)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "third-party",
+			Usage: "path pattern of third-party code, which wins over the other rules (may be repeated)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "first-party-remote",
+			Usage: "git remote prefix that is first-party, eg: github.com/awslabs/ (may be repeated)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "first-party-owner",
+			Usage: "CODEOWNERS owner whose files are first-party, eg: @awslabs/maintainers (may be repeated)",
+		},
+		&cli.StringFlag{
+			Name:  "since",
+			Usage: "only scan the files changed in git since this date, eg: 2024-01-31 or an RFC 3339 time",
+		},
+		&cli.StringFlag{
+			Name:  "since-commit",
+			Usage: "only scan the files changed in git since this commit, eg: v1.0.0",
+		},
+		&cli.BoolFlag{
+			Name:  "summary-first-party",
+			Usage: "include the first-party code in the summaries, not just the third-party code",
+		},
+		&cli.StringFlag{
+			Name:  "confidence-blend",
+			Usage: "method used to combine backend confidences, one of `linear`, `max`, or `agreement`",
+		},
+		&cli.StringFlag{
+			Name:  "triage-path",
+			Usage: "output path for the list of unknown files (csv, or json if it ends in .json)",
+		},
+		&cli.StringFlag{
+			Name:  "remediation-path",
+			Usage: "output path for the list of suggested spdx headers (csv, or json if it ends in .json)",
+		},
+		&cli.StringFlag{
+			Name:  "sbom-dir",
+			Usage: "output directory for one sbom document per package plus an index",
+		},
+		&cli.StringFlag{
+			Name:  "sbom-format",
+			Usage: fmt.Sprintf("format of the sbom documents, one of: %s", strings.Join(lib.SBOMFormats, ", ")),
+		},
+		&cli.BoolFlag{
+			Name:  "infer-licenses",
+			Usage: "infer licenses for unmatched files from the nearest LICENSE file",
+		},
+		&cli.StringFlag{
+			Name:  "ort-analyzer",
+			Usage: "path to an ORT analyzer result in json whose packages should also be scanned",
+		},
+		&cli.StringFlag{
+			Name:  "checksums-path",
+			Usage: "path to a json manifest of the sha256 sums that downloaded inputs must have",
+		},
+		&cli.StringSliceFlag{
+			Name:  "parser-plugin",
+			Usage: "command which resolves the inputs with a custom scheme, eg: internal=/usr/bin/resolve (may be repeated)",
+		},
+		&cli.BoolFlag{
+			Name:  "offline",
+			Usage: "forbid all network access and only use local paths and caches",
+		},
+		&cli.BoolFlag{
+			Name:  "reuse",
+			Usage: "check each artifact for compliance with the REUSE specification",
+		},
+		&cli.BoolFlag{
+			Name:  "workspace",
+			Usage: "use a temporary per-scan workspace which is removed afterwards",
+		},
+		&cli.StringFlag{
+			Name:  "permissions",
+			Usage: "permission policy for files we write, one of `private`, `group`, or `shared`",
+		},
+		&cli.StringFlag{
+			Name:  "duplicates",
+			Usage: "policy for differing duplicate results, one of `error`, `keep-first`, or `keep-highest`",
+		},
+		&cli.Int64Flag{
+			Name:  "memory-budget",
+			Usage: "maximum MiB of file data to hold in memory while scanning (zero is unlimited)",
+		},
+		&cli.Int64Flag{
+			Name:  "mmap-threshold",
+			Usage: "memory map files of at least this many MiB instead of reading them (zero is never)",
+		},
+		&cli.BoolFlag{
+			Name:  "cache",
+			Usage: "cache the result of each backend for each file between scans",
+		},
+		&cli.StringFlag{
+			Name:  "cache-s3bucket",
+			Usage: "bucket name to share the cache in with other machines, which enables the cache",
+		},
+		&cli.StringFlag{
+			Name:  "cache-s3prefix",
+			Usage: "prefix of the names of the cache entries in the s3 bucket",
+		},
+		&cli.StringFlag{
+			Name:  "cache-signing-key-path",
+			Usage: "path to the secret that the entries of the shared cache are signed with",
+		},
+		&cli.BoolFlag{
+			Name:  "estimate",
+			Usage: "predict how long each backend will take before the scan starts",
+		},
+		&cli.StringFlag{
+			Name:  "gomod-proxy",
+			Usage: "module proxy url that the gomod backend downloads uncached modules from, eg: https://proxy.golang.org",
+		},
+		&cli.BoolFlag{
+			Name:  "raw-output",
+			Usage: "keep the compressed json output of scancode and askalono in the json results",
+		},
+		&cli.BoolFlag{
+			Name:  "quick",
+			Usage: "only run the fast built-in backends, for a lower assurance scan",
+		},
+		&cli.BoolFlag{
+			Name:  "deep",
+			Usage: "extract and scan the text of pdf, docx and rtf documents",
+		},
+		&cli.BoolFlag{
+			Name:  "schedule",
+			Usage: "only run the expensive backends on files that the others found nothing in or disagreed about",
+		},
+		&cli.Int64Flag{
+			Name:  "bandwidth-limit",
+			Usage: "maximum KiB per second to download in total (zero is unlimited)",
+		},
+		&cli.Int64Flag{
+			Name:  "host-bandwidth-limit",
+			Usage: "maximum KiB per second to download from any one host (zero is unlimited)",
+		},
+		&cli.IntFlag{
+			Name:  "max-downloads",
+			Usage: "maximum number of downloads to run at the same time (zero is unlimited)",
+		},
+		&cli.Int64Flag{
+			Name:  "max-files",
+			Usage: "stop the scan after this many files and report what was scanned (zero is unlimited)",
+		},
+		&cli.Int64Flag{
+			Name:  "max-size",
+			Usage: "stop the scan after this many MiB of files and report what was scanned (zero is unlimited)",
+		},
+		&cli.DurationFlag{
+			Name:  "max-time",
+			Usage: "stop the scan after this long and report what was scanned (zero is unlimited)",
+		},
+		&cli.IntFlag{
+			Name:  "scancode-processes",
+			Usage: "number of worker processes that scancode uses (zero lets scancode decide)",
+		},
+		&cli.IntFlag{
+			Name:  "scancode-timeout",
+			Usage: "seconds that scancode may spend on each file (zero is the default, negative is none)",
+		},
+		&cli.IntFlag{
+			Name:  "scancode-license-score",
+			Usage: "minimum score from 0 to 100 of the license matches that scancode reports",
+		},
+		&cli.StringSliceFlag{
+			Name:  "scancode-plugin",
+			Usage: "extra scancode option to enable a plugin, eg: --classify (may be repeated)",
+		},
+		&cli.IntFlag{
+			Name:  "scancode-workers",
+			Usage: "number of warm scancode worker processes to send each file to (zero runs scancode on each directory)",
+		},
+		&cli.StringFlag{
+			Name:  "scancode-python",
+			Usage: "python interpreter that can import scancode, for the scancode workers",
+		},
+		&cli.StringFlag{
+			Name:  "askalono-dataset",
+			Usage: "path to an askalono cache file or an SPDX json license directory to use instead of the built-in one",
+		},
+		&cli.Float64Flag{
+			Name:  "askalono-confidence",
+			Usage: "minimum score from 0 to 1 of the license matches that askalono reports",
+		},
+		&cli.StringFlag{
+			Name:  "dependency-track-url",
+			Usage: "base url of a Dependency-Track server to upload a CycloneDX bom of the scan to",
+		},
+		&cli.StringFlag{
+			Name:    "dependency-track-api-key",
+			Usage:   "api key to use for the Dependency-Track upload",
+			EnvVars: []string{"YESISCAN_DEPENDENCY_TRACK_API_KEY"},
+		},
+		&cli.StringFlag{
+			Name:  "dependency-track-project",
+			Usage: "name of the Dependency-Track project to upload to",
+		},
+		&cli.StringFlag{
+			Name:  "dependency-track-version",
+			Usage: "version of the Dependency-Track project to upload to",
+		},
+		&cli.StringFlag{
+			Name:  "sw360-url",
+			Usage: "base url of an SW360 rest api to push the concluded licenses to",
+		},
+		&cli.StringFlag{
+			Name:    "sw360-token",
+			Usage:   "rest api token to use for SW360",
+			EnvVars: []string{"YESISCAN_SW360_TOKEN"},
+		},
+		&cli.StringFlag{
+			Name:  "sw360-version",
+			Usage: "SW360 release version to use for artifacts without a known version",
+		},
+		&cli.StringFlag{
+			Name:  "jira-url",
+			Usage: "base url of a Jira server to open a ticket in for each violating artifact",
+		},
+		&cli.StringFlag{
+			Name:  "jira-project",
+			Usage: "key of the Jira project to open tickets in",
+		},
+		&cli.StringFlag{
+			Name:  "jira-issue-type",
+			Usage: "type of Jira issue to open",
+		},
+		&cli.StringFlag{
+			Name:  "jira-user",
+			Usage: "Jira user for an api token, leave empty for a personal access token",
+		},
+		&cli.StringFlag{
+			Name:    "jira-token",
+			Usage:   "Jira api token or personal access token",
+			EnvVars: []string{"YESISCAN_JIRA_TOKEN"},
+		},
+		&cli.StringFlag{
+			Name:  "jira-report-url",
+			Usage: "link to the full report to put in the Jira tickets",
+		},
+		&cli.StringFlag{
+			Name:  "chat-webhook",
+			Usage: "incoming webhook url of a Slack or Chime room to post a summary of each scan to",
+		},
+		&cli.StringFlag{
+			Name:  "chat-kind",
+			Usage: "kind of chat webhook, either slack or chime, guessed from the url if empty",
+		},
+		&cli.StringFlag{
+			Name:  "email-server",
+			Usage: "host and port of the smtp server to email a summary of each scan with",
+		},
+		&cli.StringFlag{
+			Name:  "email-username",
+			Usage: "smtp user name, if the server needs a login",
+		},
+		&cli.StringFlag{
+			Name:    "email-password",
+			Usage:   "smtp password",
+			EnvVars: []string{"YESISCAN_EMAIL_PASSWORD"},
+		},
+		&cli.StringFlag{
+			Name:  "email-from",
+			Usage: "address to send the email from",
+		},
+		&cli.StringSliceFlag{
+			Name:  "email-to",
+			Usage: "address to send the email to",
+		},
+		&cli.BoolFlag{
+			Name:  "email-attach",
+			Usage: "attach the html report to the email",
+		},
 		//&cli.StringSliceFlag{Name: "config"}, // TODO: map not list
 	}
 	// build the yes and no backend flags
@@ -199,8 +550,14 @@ func CLI(program, version string, debug bool) error {
 			{Name: "James Shubin (@purpleidea)", Email: "purple@amazon.com"},
 		},
 		Description: strings.TrimSuffix(description, "\n"),
+		Before: func(c *cli.Context) error {
+			if c.Bool("legacy-stdout") {
+				messages.LegacyStdout = true
+			}
+			return nil
+		},
 		Action: func(c *cli.Context) error {
-			return App(c, program, version, debug)
+			return App(c, program, version, debug, messages)
 		},
 		Flags:                flags,
 		EnableBashCompletion: true,
@@ -220,7 +577,7 @@ func CLI(program, version string, debug bool) error {
 				Aliases: []string{"web"},
 				Usage:   "launch a web server mode",
 				Action: func(c *cli.Context) error {
-					return Web(c, program, version, debug)
+					return Web(c, program, version, debug, messages)
 				},
 				Flags: []cli.Flag{
 					&cli.StringSliceFlag{
@@ -231,45 +588,566 @@ func CLI(program, version string, debug bool) error {
 						Name:  "listen",
 						Usage: "address/port to listen on (eg: 127.0.0.1:8000)",
 					},
+					&cli.BoolFlag{
+						Name:  "public-badges",
+						Usage: "let anyone see the status badge of any uri without logging in",
+					},
+					&cli.StringSliceFlag{
+						Name:  "display-name",
+						Usage: "show uids which start with a prefix as starting with a friendlier name in the reports, as prefix=name",
+					},
+					&cli.StringSliceFlag{
+						Name:  "smart-uri",
+						Usage: "link the uids which match a regexp to a code browser in the reports, as match=template",
+					},
+					&cli.StringFlag{
+						Name:  "tls-cert",
+						Usage: "path to a pem certificate to serve https with",
+					},
+					&cli.StringFlag{
+						Name:  "tls-key",
+						Usage: "path to the pem private key of the tls certificate",
+					},
+					&cli.StringSliceFlag{
+						Name:  "autocert-host",
+						Usage: "host name to get an https certificate for from an acme authority such as Let's Encrypt",
+					},
+					&cli.StringFlag{
+						Name:  "autocert-email",
+						Usage: "contact address for the acme account",
+					},
+					&cli.StringFlag{
+						Name:  "autocert-directory-url",
+						Usage: "acme directory url of the certificate authority to use instead of Let's Encrypt",
+					},
+					&cli.StringFlag{
+						Name:  "autocert-cache",
+						Usage: "directory to keep the acme account key and certificates in",
+					},
+					&cli.BoolFlag{
+						Name:  "workspace",
+						Usage: "use a temporary per-scan workspace which is removed afterwards",
+					},
+					&cli.StringFlag{
+						Name:  "permissions",
+						Usage: "permission policy for files we write, one of `private`, `group`, or `shared`",
+					},
+					&cli.StringFlag{
+						Name:  "public-url",
+						Usage: "public base url of this server, used to link to the reports",
+					},
+					&cli.StringFlag{
+						Name:  "chat-webhook",
+						Usage: "incoming webhook url of a Slack or Chime room to post a summary of each scan to",
+					},
+					&cli.StringFlag{
+						Name:  "chat-kind",
+						Usage: "kind of chat webhook, either slack or chime, guessed from the url if empty",
+					},
+					&cli.StringFlag{
+						Name:  "email-server",
+						Usage: "host and port of the smtp server to email a summary of each scan with",
+					},
+					&cli.StringFlag{
+						Name:  "email-username",
+						Usage: "smtp user name, if the server needs a login",
+					},
+					&cli.StringFlag{
+						Name:    "email-password",
+						Usage:   "smtp password",
+						EnvVars: []string{"YESISCAN_EMAIL_PASSWORD"},
+					},
+					&cli.StringFlag{
+						Name:  "email-from",
+						Usage: "address to send the email from",
+					},
+					&cli.StringSliceFlag{
+						Name:  "email-to",
+						Usage: "address to send the email to",
+					},
+					&cli.BoolFlag{
+						Name:  "email-attach",
+						Usage: "attach the html report to the email",
+					},
+					&cli.Int64Flag{
+						Name:  "max-files",
+						Usage: "stop each scan after this many files and report what was scanned (zero is unlimited)",
+					},
+					&cli.Int64Flag{
+						Name:  "max-size",
+						Usage: "stop each scan after this many MiB of files and report what was scanned (zero is unlimited)",
+					},
+					&cli.DurationFlag{
+						Name:  "max-time",
+						Usage: "stop each scan after this long and report what was scanned (zero is unlimited)",
+					},
+					&cli.Int64Flag{
+						Name:  "max-upload-size",
+						Usage: "largest file in MiB that can be uploaded to scan (zero is the default of 100)",
+					},
+					&cli.IntFlag{
+						Name:  "max-concurrent-scans",
+						Usage: "most scans that may run at the same time (zero is unlimited)",
+					},
+					&cli.IntFlag{
+						Name:  "max-scans-per-ip",
+						Usage: "most scans that each client ip address may start in the rate window (zero is unlimited)",
+					},
+					&cli.IntFlag{
+						Name:  "max-scans-per-user",
+						Usage: "most scans that each logged in user or token may start in the rate window (zero is unlimited)",
+					},
+					&cli.DurationFlag{
+						Name:  "rate-window",
+						Usage: "how far back the scans are counted for the rate limits (zero is the default of one hour)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "trusted-proxy",
+						Usage: "address or cidr range of a reverse proxy whose X-Forwarded-For header is believed",
+					},
+					&cli.StringFlag{
+						Name:  "cache-s3bucket",
+						Usage: "bucket name to share the cache of backend results in with other machines",
+					},
+					&cli.StringFlag{
+						Name:  "cache-s3prefix",
+						Usage: "prefix of the names of the cache entries in the s3 bucket",
+					},
+					&cli.StringFlag{
+						Name:  "cache-signing-key-path",
+						Usage: "path to the secret that the entries of the shared cache are signed with",
+					},
+					&cli.StringFlag{
+						Name:  "report-s3bucket",
+						Usage: "bucket name to store the reports in, so that many servers can share them",
+					},
+					&cli.StringFlag{
+						Name:  "report-s3prefix",
+						Usage: "prefix of the names of the reports in the s3 bucket",
+					},
+					&cli.StringFlag{
+						Name:  "report-sqlite",
+						Usage: "path to an sqlite database to store the reports in, so that many servers can share them",
+					},
+					&cli.StringFlag{
+						Name:  "region",
+						Value: s3.DefaultRegion,
+						Usage: "region to use for s3 api requests",
+					},
+					&cli.DurationFlag{
+						Name:  "retention-age",
+						Usage: "remove stored reports this long after they were last written (zero keeps them forever)",
+					},
+					&cli.IntFlag{
+						Name:  "retention-count",
+						Usage: "keep at most this many stored reports, removing the oldest first (zero is unlimited)",
+					},
+					&cli.Int64Flag{
+						Name:  "retention-size",
+						Usage: "keep at most this many MiB of stored reports, removing the oldest first (zero is unlimited)",
+					},
+					&cli.StringFlag{
+						Name:  "curations-path",
+						Usage: "path to the curations file that reviewers save their decisions to",
+					},
+					&cli.StringSliceFlag{
+						Name:  "reviewer",
+						Usage: "user name and password of a reviewer who may save curations, as name:password",
+					},
+					&cli.StringSliceFlag{
+						Name:    "auth-token",
+						Usage:   "user name and secret token of a user who may log in, as name:token",
+						EnvVars: []string{"YESISCAN_AUTH_TOKENS"},
+					},
+					&cli.StringFlag{
+						Name:  "oidc-issuer",
+						Usage: "url of an OpenID Connect identity provider that users log in with",
+					},
+					&cli.StringFlag{
+						Name:  "oidc-client-id",
+						Usage: "client id of this server at the identity provider",
+					},
+					&cli.StringFlag{
+						Name:    "oidc-client-secret",
+						Usage:   "client secret of this server at the identity provider",
+						EnvVars: []string{"YESISCAN_OIDC_CLIENT_SECRET"},
+					},
+					&cli.StringFlag{
+						Name:  "oidc-claim",
+						Usage: "claim of the id token to use as the user name",
+						Value: web.DefaultOIDCClaim,
+					},
+					&cli.StringSliceFlag{
+						Name:  "admin",
+						Usage: "user name of an admin who may see every report",
+					},
 				},
 			},
-		},
-	}
-
-	return app.Run(os.Args)
-}
-
-// App is the main entry point action for the regular yesiscan cli application.
-func App(c *cli.Context, program, version string, debug bool) error {
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer stop()
-
-	bigIntStr := "" // for our int
-	var autoConfigExpirySeconds int
-	var autoConfigForceUpdate bool
-	var autoConfigBinaryVersion string
-	var quiet bool
-	var ansiMagic bool
+			{
+				Name:    "grpc",
+				Aliases: []string{"grpc"},
+				Usage:   "launch a grpc server to run scans and get their reports",
+				Action: func(c *cli.Context) error {
+					return Grpc(c, program, version, debug, messages)
+				},
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "profile",
+						Usage: "license set filtering profile that a scan may ask for",
+					},
+					&cli.StringFlag{
+						Name:  "listen",
+						Usage: "address/port to listen on (eg: 127.0.0.1:8443)",
+					},
+					&cli.StringFlag{
+						Name:  "tls-cert",
+						Usage: "path to a pem certificate to serve tls with",
+					},
+					&cli.StringFlag{
+						Name:  "tls-key",
+						Usage: "path to the pem private key of the tls certificate",
+					},
+					&cli.BoolFlag{
+						Name:  "workspace",
+						Usage: "use a temporary per-scan workspace which is removed afterwards",
+					},
+					&cli.StringFlag{
+						Name:  "permissions",
+						Usage: "permission policy for files we write, one of `private`, `group`, or `shared`",
+					},
+					&cli.Int64Flag{
+						Name:  "max-files",
+						Usage: "stop each scan after this many files and report what was scanned (zero is unlimited)",
+					},
+					&cli.Int64Flag{
+						Name:  "max-size",
+						Usage: "stop each scan after this many MiB of files and report what was scanned (zero is unlimited)",
+					},
+					&cli.DurationFlag{
+						Name:  "max-time",
+						Usage: "stop each scan after this long and report what was scanned (zero is unlimited)",
+					},
+					&cli.IntFlag{
+						Name:  "max-concurrent-scans",
+						Usage: "most scans that may run at the same time (zero is unlimited)",
+					},
+					&cli.StringFlag{
+						Name:  "cache-s3bucket",
+						Usage: "bucket name to share the cache of backend results in with other machines",
+					},
+					&cli.StringFlag{
+						Name:  "cache-s3prefix",
+						Usage: "prefix of the names of the cache entries in the s3 bucket",
+					},
+					&cli.StringFlag{
+						Name:  "cache-signing-key-path",
+						Usage: "path to the secret that the entries of the shared cache are signed with",
+					},
+					&cli.StringFlag{
+						Name:  "report-s3bucket",
+						Usage: "bucket name to store the reports in, so that many servers can share them",
+					},
+					&cli.StringFlag{
+						Name:  "report-s3prefix",
+						Usage: "prefix of the names of the reports in the s3 bucket",
+					},
+					&cli.StringFlag{
+						Name:  "report-sqlite",
+						Usage: "path to an sqlite database to store the reports in, so that many servers can share them",
+					},
+					&cli.StringFlag{
+						Name:  "region",
+						Value: s3.DefaultRegion,
+						Usage: "region to use for s3 api requests",
+					},
+					&cli.StringSliceFlag{
+						Name:    "auth-token",
+						Usage:   "user name and secret bearer token of a user who may use the server, as name:token",
+						EnvVars: []string{"YESISCAN_AUTH_TOKENS"},
+					},
+				},
+			},
+			{
+				Name:      "bench",
+				Aliases:   []string{"bench"},
+				Usage:     "run a scan with profiling and print the time spent in each stage",
+				ArgsUsage: "<uri>...",
+				Action: func(c *cli.Context) error {
+					return Bench(c, program, version, debug)
+				},
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "backend",
+						Usage: "backend to run, all of them are run if none are specified",
+					},
+					&cli.StringFlag{
+						Name:  "cpu-profile",
+						Value: "cpu.pprof",
+						Usage: "path to write the cpu profile to",
+					},
+					&cli.StringFlag{
+						Name:  "heap-profile",
+						Value: "heap.pprof",
+						Usage: "path to write the heap profile to",
+					},
+					&cli.StringFlag{
+						Name:  "permissions",
+						Usage: "permission policy for files we write, one of `private`, `group`, or `shared`",
+					},
+				},
+			},
+			{
+				Name:      "watch",
+				Aliases:   []string{"watch"},
+				Usage:     "scan a local path again each time it changes and show a live summary",
+				ArgsUsage: "<path>",
+				Action: func(c *cli.Context) error {
+					return Watch(c, program, version, debug, messages)
+				},
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "backend",
+						Usage: "backend to run, all of them are run if none are specified",
+					},
+					&cli.StringSliceFlag{
+						Name:  "profile",
+						Usage: "license set filtering profile to include",
+					},
+					&cli.DurationFlag{
+						Name:  "interval",
+						Value: lib.DefaultWatchInterval,
+						Usage: "how often to check for changes",
+					},
+					&cli.StringFlag{
+						Name:  "listen",
+						Usage: "address/port to serve a live report on (eg: 127.0.0.1:8001)",
+					},
+					&cli.StringFlag{
+						Name:  "permissions",
+						Usage: "permission policy for files we write, one of `private`, `group`, or `shared`",
+					},
+				},
+			},
+			{
+				Name:    "cache",
+				Aliases: []string{"cache"},
+				Usage:   "manage the cache of backend results",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "clean",
+						Usage: "remove all of the cached backend results",
+						Action: func(c *cli.Context) error {
+							return CacheClean(c, program, version, debug)
+						},
+					},
+				},
+			},
+			{
+				Name:    "selftest",
+				Aliases: []string{"selftest"},
+				Usage:   "check that each backend finds the licenses in a known corpus",
+				Action: func(c *cli.Context) error {
+					return SelfTest(c, program, version, debug)
+				},
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "backend",
+						Usage: "backend to check, all of them are checked if none are specified",
+					},
+				},
+			},
+			{
+				Name:      "aggregate",
+				Aliases:   []string{"aggregate"},
+				Usage:     "merge many stored json outputs into a single roll-up report",
+				ArgsUsage: "<output.json or dir>...",
+				Action: func(c *cli.Context) error {
+					return Aggregate(c, program, version, debug)
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "output-type",
+						Value: "html",
+						Usage: "format of the report, one of `html` or `csv`",
+					},
+					&cli.StringFlag{
+						Name:  "output-path",
+						Usage: "path to write the report to, or - for stdout (the default)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "license",
+						Usage: "license to list the repos containing, all of them are listed if none are specified",
+					},
+					&cli.StringFlag{
+						Name:  "permissions",
+						Usage: "permission policy for files we write, one of `private`, `group`, or `shared`",
+					},
+				},
+			},
+			{
+				Name:      "lockfile",
+				Aliases:   []string{"lockfile"},
+				Usage:     "scan only the packages that were added or updated between two versions of a lockfile",
+				ArgsUsage: "<old lockfile> <new lockfile>",
+				Action: func(c *cli.Context) error {
+					return Lockfile(c, program, version, debug)
+				},
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "backend",
+						Usage: "backend to run, all of them are run if none are specified",
+					},
+					&cli.StringSliceFlag{
+						Name:  "profile",
+						Usage: "license set filtering profile to include",
+					},
+					&cli.StringFlag{
+						Name:  "output-type",
+						Value: "text",
+						Usage: "format of the report, one of `text` or `json`",
+					},
+					&cli.StringFlag{
+						Name:  "permissions",
+						Usage: "permission policy for files we write, one of `private`, `group`, or `shared`",
+					},
+					&cli.BoolFlag{
+						Name:  "offline",
+						Usage: "forbid all network access and only use local caches",
+					},
+				},
+			},
+			{
+				Name:      "query",
+				Aliases:   []string{"query"},
+				Usage:     "find the results in stored json outputs which match a query",
+				ArgsUsage: "<query> <output.json or dir>...",
+				Action: func(c *cli.Context) error {
+					return Query(c, program, version, debug)
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "output-type",
+						Value: "text",
+						Usage: "format of the matches, one of `text` or `json`",
+					},
+				},
+			},
+			{
+				Name:      "diff",
+				Aliases:   []string{"diff"},
+				Usage:     "show the license determinations that changed between two stored json outputs",
+				ArgsUsage: "<old.json> <new.json>",
+				Action: func(c *cli.Context) error {
+					return Diff(c, program, version, debug)
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "output-type",
+						Value: "text",
+						Usage: "format of the changes, one of `text` or `json`",
+					},
+				},
+			},
+		},
+	}
+
+	return app.Run(os.Args)
+}
+
+// App is the main entry point action for the regular yesiscan cli application.
+func App(c *cli.Context, program, version string, debug bool, messages *Messages) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	bigIntStr := "" // for our int
+	autoConfigURI := buildAutoConfigURI
+	autoConfigCookiePath := buildAutoConfigCookiePath
+	var autoConfigExpirySeconds int
+	var autoConfigForceUpdate bool
+	var autoConfigBinaryVersion string
+	var quiet bool
+	var porcelain bool
+	var legacyStdout bool
+	// changed-only makes no sense in the config file
+	changedOnly := c.Bool("changed-only")
+	var ansiMagic bool
+	color := ansi.ColorAuto
 	var regexpPath string
 	// config-path makes no sense here
 	var outputType string
 	var outputPath string
 	var outputTemplate string
 	var outputS3Bucket string
+	var redact bool
+	publicHosts := []string{}
+	displayNames := make(lib.DisplayNames) // uid prefix -> display name
+	smartURIs := []*util.SmartURITemplate{}
 	region := s3.DefaultRegion
 	profiles := []string{}
+	var inferLicenses bool
+	var reuse bool
+	var ortAnalyzerPath string
+	var checksumsPath string
+	var offline bool
+	parserPlugins := make(map[string]string) // scheme -> command
+	var triagePath string
+	var remediationPath string
+	var sbomDir string
+	var sbomFormat string
+	var ignorePath string
+	var curationsPath string
+	var obligationsPath string
+	ownership := &lib.Ownership{}
+	scope := &lib.Scope{}
+	var summaryFirstParty bool
+	var confidenceBlend string
+	var workspace bool
+	var permissions string
+	var duplicates string
+	var memoryBudget int64  // MiB
+	var mmapThreshold int64 // MiB
+	var cache bool
+	var cacheS3Bucket string
+	var cacheS3Prefix string
+	var cacheSigningKeyPath string
+	var estimate bool
+	var rawOutput bool
+	var gomodProxy string
+	var quick bool
+	var deep bool
+	var schedule bool
+	var bandwidthLimit int64     // KiB/s
+	var hostBandwidthLimit int64 // KiB/s
+	var maxDownloads int
+	var maxFiles int64
+	var maxSize int64 // MiB
+	var maxTime time.Duration
+	scancodeOptions := &backend.ScancodeOptions{}
+	askalonoOptions := &backend.AskalonoOptions{}
+	dependencyTrackOptions := &publish.DependencyTrackOptions{}
+	sw360Options := &publish.SW360Options{}
+	jiraOptions := &publish.JiraOptions{}
+	chatOptions := &publish.ChatOptions{}
+	emailOptions := &publish.EmailOptions{}
 	configs := make(map[string]string)
 	backends := make(map[string]bool)
 	binaries := make(map[string]string)
 
 	// load from main config file or xdg if config is empty
-	config, err := GetConfig(c.String("config-path"))
+	config, err := GetConfig(program, c.String("config-path"))
 	if err != nil {
 		return err
 	}
+	if preset := c.String("preset"); preset != "" {
+		if config == nil {
+			return fmt.Errorf("preset %s needs a config file", preset)
+		}
+		if err := config.ApplyPreset(preset); err != nil {
+			return err
+		}
+	}
 	if config != nil {
 		if config.AutoConfigURI != nil {
-			// set this global var
 			autoConfigURI = *config.AutoConfigURI
 		}
 		if config.AutoConfigCookiePath != nil {
@@ -291,9 +1169,18 @@ func App(c *cli.Context, program, version string, debug bool) error {
 		if config.Quiet != nil {
 			quiet = *config.Quiet
 		}
+		if config.Porcelain != nil {
+			porcelain = *config.Porcelain
+		}
+		if config.LegacyStdout != nil {
+			legacyStdout = *config.LegacyStdout
+		}
 		if config.AnsiMagic != nil {
 			ansiMagic = *config.AnsiMagic
 		}
+		if config.Color != nil {
+			color = *config.Color
+		}
 		if config.RegexpPath != nil {
 			regexpPath = *config.RegexpPath
 		}
@@ -310,6 +1197,26 @@ func App(c *cli.Context, program, version string, debug bool) error {
 		if config.OutputS3Bucket != nil {
 			outputS3Bucket = *config.OutputS3Bucket
 		}
+		if config.Redact != nil {
+			redact = *config.Redact
+		}
+		if config.PublicHosts != nil {
+			publicHosts = []string{} // erase any previous
+			for _, x := range *config.PublicHosts {
+				publicHosts = append(publicHosts, x)
+			}
+		}
+		if config.DisplayNames != nil {
+			for k, v := range *config.DisplayNames {
+				displayNames[k] = v // copy
+			}
+		}
+		if config.SmartURIs != nil {
+			smartURIs = []*util.SmartURITemplate{} // erase any previous
+			for _, x := range *config.SmartURIs {
+				smartURIs = append(smartURIs, x)
+			}
+		}
 		if config.Region != nil {
 			region = *config.Region
 		}
@@ -319,6 +1226,154 @@ func App(c *cli.Context, program, version string, debug bool) error {
 				profiles = append(profiles, x)
 			}
 		}
+		if config.InferLicenses != nil {
+			inferLicenses = *config.InferLicenses
+		}
+		if config.Reuse != nil {
+			reuse = *config.Reuse
+		}
+		if config.OrtAnalyzer != nil {
+			ortAnalyzerPath = *config.OrtAnalyzer
+		}
+		if config.ChecksumsPath != nil {
+			checksumsPath = *config.ChecksumsPath
+		}
+		if config.Offline != nil {
+			offline = *config.Offline
+		}
+		if config.ParserPlugins != nil {
+			for k, v := range *config.ParserPlugins {
+				parserPlugins[k] = v // copy
+			}
+		}
+		if config.TriagePath != nil {
+			triagePath = *config.TriagePath
+		}
+		if config.RemediationPath != nil {
+			remediationPath = *config.RemediationPath
+		}
+		if config.SBOMDir != nil {
+			sbomDir = *config.SBOMDir
+		}
+		if config.SBOMFormat != nil {
+			sbomFormat = *config.SBOMFormat
+		}
+		if config.IgnorePath != nil {
+			ignorePath = *config.IgnorePath
+		}
+		if config.CurationsPath != nil {
+			curationsPath = *config.CurationsPath
+		}
+		if config.ObligationsPath != nil {
+			obligationsPath = *config.ObligationsPath
+		}
+		if config.Ownership != nil {
+			ownership = config.Ownership
+		}
+		if config.Since != nil {
+			t, err := lib.ParseScopeSince(*config.Since)
+			if err != nil {
+				return errwrap.Wrapf(err, "invalid since in config")
+			}
+			scope.Since = t
+		}
+		if config.SinceCommit != nil {
+			scope.SinceCommit = *config.SinceCommit
+		}
+		if config.SummaryFirstParty != nil {
+			summaryFirstParty = *config.SummaryFirstParty
+		}
+		if config.ConfidenceBlend != nil {
+			confidenceBlend = *config.ConfidenceBlend
+		}
+		if config.Workspace != nil {
+			workspace = *config.Workspace
+		}
+		if config.Permissions != nil {
+			permissions = *config.Permissions
+		}
+		if config.Duplicates != nil {
+			duplicates = *config.Duplicates
+		}
+		if config.MemoryBudget != nil {
+			memoryBudget = *config.MemoryBudget
+		}
+		if config.MmapThreshold != nil {
+			mmapThreshold = *config.MmapThreshold
+		}
+		if config.Cache != nil {
+			cache = *config.Cache
+		}
+		if config.CacheS3Bucket != nil {
+			cacheS3Bucket = *config.CacheS3Bucket
+		}
+		if config.CacheS3Prefix != nil {
+			cacheS3Prefix = *config.CacheS3Prefix
+		}
+		if config.CacheSigningKeyPath != nil {
+			cacheSigningKeyPath = *config.CacheSigningKeyPath
+		}
+		if config.Estimate != nil {
+			estimate = *config.Estimate
+		}
+		if config.RawOutput != nil {
+			rawOutput = *config.RawOutput
+		}
+		if config.GomodProxy != nil {
+			gomodProxy = *config.GomodProxy
+		}
+		if config.Quick != nil {
+			quick = *config.Quick
+		}
+		if config.Deep != nil {
+			deep = *config.Deep
+		}
+		if config.Schedule != nil {
+			schedule = *config.Schedule
+		}
+		if config.BandwidthLimit != nil {
+			bandwidthLimit = *config.BandwidthLimit
+		}
+		if config.HostBandwidthLimit != nil {
+			hostBandwidthLimit = *config.HostBandwidthLimit
+		}
+		if config.MaxDownloads != nil {
+			maxDownloads = *config.MaxDownloads
+		}
+		if config.MaxFiles != nil {
+			maxFiles = *config.MaxFiles
+		}
+		if config.MaxSize != nil {
+			maxSize = *config.MaxSize
+		}
+		if config.MaxTime != nil {
+			d, err := time.ParseDuration(*config.MaxTime)
+			if err != nil {
+				return errwrap.Wrapf(err, "invalid max-time in config")
+			}
+			maxTime = d
+		}
+		if config.Scancode != nil {
+			*scancodeOptions = *config.Scancode // copy
+		}
+		if config.Askalono != nil {
+			*askalonoOptions = *config.Askalono // copy
+		}
+		if config.DependencyTrack != nil {
+			*dependencyTrackOptions = *config.DependencyTrack // copy
+		}
+		if config.SW360 != nil {
+			*sw360Options = *config.SW360 // copy
+		}
+		if config.Jira != nil {
+			*jiraOptions = *config.Jira // copy
+		}
+		if config.Chat != nil {
+			*chatOptions = *config.Chat // copy
+		}
+		if config.Email != nil {
+			*emailOptions = *config.Email // copy
+		}
 		if config.Configs != nil {
 			configs = make(map[string]string) // erase any previous
 			for k, v := range *config.Configs {
@@ -336,57 +1391,401 @@ func App(c *cli.Context, program, version string, debug bool) error {
 			}
 		}
 	}
-
-	// Command line options override anything in the config.
-	if c.IsSet("auto-config-uri") {
-		autoConfigURI = c.String("auto-config-uri")
+
+	// Command line options override anything in the config.
+	if c.IsSet("auto-config-uri") {
+		autoConfigURI = c.String("auto-config-uri")
+	}
+	if c.IsSet("auto-config-cookie-path") {
+		autoConfigCookiePath = c.String("auto-config-cookie-path")
+	}
+	if c.IsSet("auto-config-expiry-seconds") {
+		autoConfigExpirySeconds = c.Int("auto-config-expiry-seconds")
+	}
+	if c.IsSet("auto-config-force-update") {
+		autoConfigForceUpdate = c.Bool("auto-config-force-update")
+	}
+	if c.IsSet("auto-config-binary-version") {
+		autoConfigBinaryVersion = c.String("auto-config-binary-version")
+	}
+	if c.IsSet("quiet") {
+		quiet = c.Bool("quiet")
+	}
+	if c.IsSet("porcelain") {
+		porcelain = c.Bool("porcelain")
+	}
+	if c.IsSet("legacy-stdout") {
+		legacyStdout = c.Bool("legacy-stdout")
+	}
+	messages.LegacyStdout = legacyStdout
+	if c.IsSet("ansi-magic") {
+		ansiMagic = c.Bool("ansi-magic")
+	}
+	if c.IsSet("no-ansi-magic") {
+		ansiMagic = !c.Bool("no-ansi-magic")
+	}
+	if c.IsSet("color") {
+		color = c.String("color")
+	}
+	useColor, err := ansi.UseColor(color, int(os.Stdout.Fd()))
+	if err != nil {
+		return err
+	}
+	colour.NoColor = !useColor // so that always works when it's not a tty
+	if c.IsSet("regexp-path") {
+		regexpPath = c.String("regexp-path")
+	}
+	// config-path makes no sense here
+	if c.IsSet("output-type") {
+		outputType = c.String("output-type")
+	}
+	if c.IsSet("output-path") {
+		outputPath = c.String("output-path")
+	}
+	if c.IsSet("output-template") {
+		outputTemplate = c.String("output-template")
+	}
+	if c.IsSet("output-s3bucket") {
+		outputS3Bucket = c.String("output-s3bucket")
+	}
+	if c.IsSet("redact") {
+		redact = c.Bool("redact")
+	}
+	if c.IsSet("public-host") {
+		publicHosts = []string{} // erase any previous
+		for _, x := range c.StringSlice("public-host") {
+			publicHosts = append(publicHosts, x)
+		}
+	}
+	if c.IsSet("display-name") {
+		displayNames = make(lib.DisplayNames) // erase any previous
+		for _, x := range c.StringSlice("display-name") {
+			split := strings.SplitN(x, "=", 2)
+			if len(split) != 2 || split[0] == "" {
+				return fmt.Errorf("invalid display name, expected prefix=name: %s", x)
+			}
+			displayNames[split[0]] = split[1]
+		}
+	}
+	if c.IsSet("smart-uri") {
+		smartURIs = []*util.SmartURITemplate{} // erase any previous
+		for _, x := range c.StringSlice("smart-uri") {
+			split := strings.SplitN(x, "=", 2)
+			if len(split) != 2 {
+				return fmt.Errorf("invalid smart uri, expected match=template: %s", x)
+			}
+			smartURIs = append(smartURIs, &util.SmartURITemplate{
+				Match:    split[0],
+				Template: split[1],
+			})
+		}
+	}
+	smartURIFuncs, err := util.NewSmartURIFuncs(smartURIs) // in order
+	if err != nil {
+		return err
+	}
+	if c.IsSet("region") {
+		region = c.String("region")
+	}
+	if c.IsSet("profile") {
+		profiles = []string{} // erase any previous
+		for _, x := range c.StringSlice("profile") {
+			profiles = append(profiles, x)
+		}
+	}
+	if c.IsSet("infer-licenses") {
+		inferLicenses = c.Bool("infer-licenses")
+	}
+	if c.IsSet("reuse") {
+		reuse = c.Bool("reuse")
+	}
+	if c.IsSet("ort-analyzer") {
+		ortAnalyzerPath = c.String("ort-analyzer")
+	}
+	if c.IsSet("checksums-path") {
+		checksumsPath = c.String("checksums-path")
+	}
+	if c.IsSet("offline") {
+		offline = c.Bool("offline")
+	}
+	if c.IsSet("parser-plugin") {
+		parserPlugins = make(map[string]string) // erase any previous
+		for _, x := range c.StringSlice("parser-plugin") {
+			split := strings.SplitN(x, "=", 2)
+			if len(split) != 2 || split[0] == "" || split[1] == "" {
+				return fmt.Errorf("invalid parser plugin, expected scheme=command: %s", x)
+			}
+			parserPlugins[split[0]] = split[1]
+		}
+	}
+	plugins := make(map[string]parser.PluginFunc)
+	for k, v := range parserPlugins {
+		plugins[k] = parser.ExecPlugin(v)
+	}
+	if c.IsSet("triage-path") {
+		triagePath = c.String("triage-path")
+	}
+	if c.IsSet("remediation-path") {
+		remediationPath = c.String("remediation-path")
+	}
+	if c.IsSet("sbom-dir") {
+		sbomDir = c.String("sbom-dir")
+	}
+	if c.IsSet("sbom-format") {
+		sbomFormat = c.String("sbom-format")
+	}
+	if c.IsSet("ignore-path") {
+		ignorePath = c.String("ignore-path")
+	}
+	if c.IsSet("curations-path") {
+		curationsPath = c.String("curations-path")
+	}
+	if c.IsSet("obligations-path") {
+		obligationsPath = c.String("obligations-path")
+	}
+	if c.IsSet("first-party") {
+		ownership.FirstParty = c.StringSlice("first-party")
+	}
+	if c.IsSet("third-party") {
+		ownership.ThirdParty = c.StringSlice("third-party")
+	}
+	if c.IsSet("first-party-remote") {
+		ownership.Remotes = c.StringSlice("first-party-remote")
+	}
+	if c.IsSet("first-party-owner") {
+		ownership.Owners = c.StringSlice("first-party-owner")
+	}
+	if c.IsSet("since") {
+		t, err := lib.ParseScopeSince(c.String("since"))
+		if err != nil {
+			return errwrap.Wrapf(err, "invalid since")
+		}
+		scope.Since = t
+		scope.SinceCommit = "" // the flag wins over the config
+	}
+	if c.IsSet("since-commit") {
+		scope.SinceCommit = c.String("since-commit")
+		if !c.IsSet("since") {
+			scope.Since = time.Time{} // the flag wins over the config
+		}
 	}
-	if c.IsSet("auto-config-cookie-path") {
-		autoConfigCookiePath = c.String("auto-config-cookie-path")
+	if c.IsSet("summary-first-party") {
+		summaryFirstParty = c.Bool("summary-first-party")
 	}
-	if c.IsSet("auto-config-expiry-seconds") {
-		autoConfigExpirySeconds = c.Int("auto-config-expiry-seconds")
+	if c.IsSet("confidence-blend") {
+		confidenceBlend = c.String("confidence-blend")
 	}
-	if c.IsSet("auto-config-force-update") {
-		autoConfigForceUpdate = c.Bool("auto-config-force-update")
+	if c.IsSet("workspace") {
+		workspace = c.Bool("workspace")
 	}
-	if c.IsSet("auto-config-binary-version") {
-		autoConfigBinaryVersion = c.String("auto-config-binary-version")
+	if c.IsSet("permissions") {
+		permissions = c.String("permissions")
 	}
-	if c.IsSet("quiet") {
-		quiet = c.Bool("quiet")
+	if c.IsSet("duplicates") {
+		duplicates = c.String("duplicates")
 	}
-	if c.IsSet("ansi-magic") {
-		ansiMagic = c.Bool("ansi-magic")
+	if c.IsSet("memory-budget") {
+		memoryBudget = c.Int64("memory-budget")
 	}
-	if c.IsSet("no-ansi-magic") {
-		ansiMagic = !c.Bool("no-ansi-magic")
+	if memoryBudget < 0 {
+		return fmt.Errorf("the memory budget must not be negative")
 	}
-	if c.IsSet("regexp-path") {
-		regexpPath = c.String("regexp-path")
+	if c.IsSet("mmap-threshold") {
+		mmapThreshold = c.Int64("mmap-threshold")
 	}
-	// config-path makes no sense here
-	if c.IsSet("output-type") {
-		outputType = c.String("output-type")
+	if mmapThreshold < 0 {
+		return fmt.Errorf("the mmap threshold must not be negative")
 	}
-	if c.IsSet("output-path") {
-		outputPath = c.String("output-path")
+	if c.IsSet("cache") {
+		cache = c.Bool("cache")
 	}
-	if c.IsSet("output-template") {
-		outputTemplate = c.String("output-template")
+	if c.IsSet("cache-s3bucket") {
+		cacheS3Bucket = c.String("cache-s3bucket")
 	}
-	if c.IsSet("output-s3bucket") {
-		outputS3Bucket = c.String("output-s3bucket")
+	if c.IsSet("cache-s3prefix") {
+		cacheS3Prefix = c.String("cache-s3prefix")
 	}
-	if c.IsSet("region") {
-		region = c.String("region")
+	if c.IsSet("cache-signing-key-path") {
+		cacheSigningKeyPath = c.String("cache-signing-key-path")
 	}
-	if c.IsSet("profile") {
-		profiles = []string{} // erase any previous
-		for _, x := range c.StringSlice("profile") {
-			profiles = append(profiles, x)
+	if c.IsSet("estimate") {
+		estimate = c.Bool("estimate")
+	}
+	if c.IsSet("raw-output") {
+		rawOutput = c.Bool("raw-output")
+	}
+	if c.IsSet("gomod-proxy") {
+		gomodProxy = c.String("gomod-proxy")
+	}
+	if c.IsSet("quick") {
+		quick = c.Bool("quick")
+	}
+	if c.IsSet("deep") {
+		deep = c.Bool("deep")
+	}
+	if c.IsSet("schedule") {
+		schedule = c.Bool("schedule")
+	}
+	if c.IsSet("bandwidth-limit") {
+		bandwidthLimit = c.Int64("bandwidth-limit")
+	}
+	if c.IsSet("host-bandwidth-limit") {
+		hostBandwidthLimit = c.Int64("host-bandwidth-limit")
+	}
+	if c.IsSet("max-downloads") {
+		maxDownloads = c.Int("max-downloads")
+	}
+	if c.IsSet("max-files") {
+		maxFiles = c.Int64("max-files")
+	}
+	if c.IsSet("max-size") {
+		maxSize = c.Int64("max-size")
+	}
+	if c.IsSet("max-time") {
+		maxTime = c.Duration("max-time")
+	}
+	if maxFiles < 0 || maxSize < 0 || maxTime < 0 {
+		return fmt.Errorf("the scan limits must not be negative")
+	}
+	if c.IsSet("scancode-processes") {
+		scancodeOptions.Processes = c.Int("scancode-processes")
+	}
+	if c.IsSet("scancode-timeout") {
+		scancodeOptions.Timeout = c.Int("scancode-timeout")
+	}
+	if c.IsSet("scancode-license-score") {
+		scancodeOptions.LicenseScore = c.Int("scancode-license-score")
+	}
+	if c.IsSet("scancode-plugin") {
+		scancodeOptions.Plugins = c.StringSlice("scancode-plugin")
+	}
+	if c.IsSet("scancode-workers") {
+		scancodeOptions.Workers = c.Int("scancode-workers")
+	}
+	if c.IsSet("scancode-python") {
+		scancodeOptions.Python = c.String("scancode-python")
+	}
+	if err := scancodeOptions.Validate(); err != nil {
+		return err
+	}
+	if c.IsSet("askalono-dataset") {
+		askalonoOptions.Dataset = c.String("askalono-dataset")
+	}
+	if c.IsSet("askalono-confidence") {
+		askalonoOptions.Confidence = c.Float64("askalono-confidence")
+	}
+	if err := askalonoOptions.Validate(); err != nil {
+		return err
+	}
+	if c.IsSet("dependency-track-url") {
+		dependencyTrackOptions.URL = c.String("dependency-track-url")
+	}
+	if c.IsSet("dependency-track-api-key") {
+		dependencyTrackOptions.APIKey = c.String("dependency-track-api-key")
+	}
+	if c.IsSet("dependency-track-project") {
+		dependencyTrackOptions.Project = c.String("dependency-track-project")
+	}
+	if c.IsSet("dependency-track-version") {
+		dependencyTrackOptions.Version = c.String("dependency-track-version")
+	}
+	if c.IsSet("sw360-url") {
+		sw360Options.URL = c.String("sw360-url")
+	}
+	if c.IsSet("sw360-token") {
+		sw360Options.Token = c.String("sw360-token")
+	}
+	if c.IsSet("sw360-version") {
+		sw360Options.Version = c.String("sw360-version")
+	}
+	if c.IsSet("jira-url") {
+		jiraOptions.URL = c.String("jira-url")
+	}
+	if c.IsSet("jira-project") {
+		jiraOptions.Project = c.String("jira-project")
+	}
+	if c.IsSet("jira-issue-type") {
+		jiraOptions.IssueType = c.String("jira-issue-type")
+	}
+	if c.IsSet("jira-user") {
+		jiraOptions.User = c.String("jira-user")
+	}
+	if c.IsSet("jira-token") {
+		jiraOptions.Token = c.String("jira-token")
+	}
+	if c.IsSet("jira-report-url") {
+		jiraOptions.ReportURL = c.String("jira-report-url")
+	}
+	if c.IsSet("chat-webhook") {
+		chatOptions.Webhook = c.String("chat-webhook")
+	}
+	if c.IsSet("chat-kind") {
+		chatOptions.Kind = c.String("chat-kind")
+	}
+	if c.IsSet("email-server") {
+		emailOptions.Server = c.String("email-server")
+	}
+	if c.IsSet("email-username") {
+		emailOptions.Username = c.String("email-username")
+	}
+	if c.IsSet("email-password") {
+		emailOptions.Password = c.String("email-password")
+	}
+	if c.IsSet("email-from") {
+		emailOptions.From = c.String("email-from")
+	}
+	if c.IsSet("email-to") {
+		emailOptions.To = c.StringSlice("email-to")
+	}
+	if c.IsSet("email-attach") {
+		emailOptions.Attach = c.Bool("email-attach")
+	}
+	// check these before the scan, so that we don't throw the results away
+	if sbomFormat != "" && !util.StrInList(sbomFormat, lib.SBOMFormats) {
+		return fmt.Errorf("invalid sbom format: %s", sbomFormat)
+	}
+	if redact { // these send the results themselves, without the redactor
+		if dependencyTrackOptions.URL != "" || sw360Options.URL != "" || jiraOptions.URL != "" || chatOptions.Webhook != "" {
+			return fmt.Errorf("redact can't be used with the dependency-track, sw360, jira or chat options")
+		}
+	}
+	if offline { // these all publish the results over the network
+		if outputS3Bucket != "" {
+			return fmt.Errorf("offline mode can't be used with an s3 bucket")
+		}
+		if dependencyTrackOptions.URL != "" || sw360Options.URL != "" || jiraOptions.URL != "" || chatOptions.Webhook != "" || emailOptions.Server != "" {
+			return fmt.Errorf("offline mode can't be used with a publisher")
+		}
+	}
+	if dependencyTrackOptions.URL != "" {
+		if err := dependencyTrackOptions.Validate(); err != nil {
+			return errwrap.Wrapf(err, "invalid dependency-track options")
+		}
+	}
+	if sw360Options.URL != "" {
+		if err := sw360Options.Validate(); err != nil {
+			return errwrap.Wrapf(err, "invalid sw360 options")
+		}
+	}
+	if jiraOptions.URL != "" {
+		if err := jiraOptions.Validate(); err != nil {
+			return errwrap.Wrapf(err, "invalid jira options")
+		}
+	}
+	if chatOptions.Webhook != "" {
+		if err := chatOptions.Validate(); err != nil {
+			return errwrap.Wrapf(err, "invalid chat options")
+		}
+	}
+	if emailOptions.Server != "" {
+		if err := emailOptions.Validate(); err != nil {
+			return errwrap.Wrapf(err, "invalid email options")
 		}
 	}
+	perms, err := interfaces.ParsePerms(permissions)
+	if err != nil {
+		return err
+	}
 	//if c.IsSet("config") {
 	//	configs = make(map[string]string) // erase any previous
 	//	for k, x := range c.StringSlice("config") { // TODO: map not list
@@ -417,6 +1816,12 @@ func App(c *cli.Context, program, version string, debug bool) error {
 	if autoConfigForceUpdate && autoConfigURI == "" { // be helpful
 		logf("unable to force auto-config update because auto-config-uri is empty")
 	}
+	if offline && autoConfigURI != "" {
+		logf("offline: using the cached config instead of: %s", autoConfigURI)
+		autoConfigURI = "" // we can't download anything
+		configs = make(map[string]string)
+		autoConfigBinaryVersion = ""
+	}
 
 	isExpired := false
 	if autoConfigForceUpdate {
@@ -429,7 +1834,7 @@ func App(c *cli.Context, program, version string, debug bool) error {
 		isExpired = false
 
 	} else if autoConfigURI != "" {
-		p, err := GetConfigPath(c.String("config-path"))
+		p, err := GetConfigPath(program, c.String("config-path"))
 		if err != nil {
 			return err
 		}
@@ -466,7 +1871,7 @@ func App(c *cli.Context, program, version string, debug bool) error {
 		if err != nil {
 			return errwrap.Wrapf(err, "autoConfigURI download failed on: %s", autoConfigURI)
 		}
-		p, err := GetConfigPath(c.String("config-path"))
+		p, err := GetConfigPath(program, c.String("config-path"))
 		if err != nil {
 			return err
 		}
@@ -499,20 +1904,19 @@ func App(c *cli.Context, program, version string, debug bool) error {
 			d := filepath.Dir(p)
 			// maybe the ~/.config/yesiscan/ dir doesn't exist yet!
 			if _, err := os.Stat(d); os.IsNotExist(err) { // no config exists here...
-				if err := os.MkdirAll(d, interfaces.Umask); err != nil {
+				if err := os.MkdirAll(d, perms.DirMode()); err != nil {
 					return errwrap.Wrapf(err, "couldn't make config dir at: %s", d)
 				}
 			}
 
-			// XXX: set umask for u=rw,go=
-			if err := os.WriteFile(p, data, interfaces.Umask); err != nil {
+			if err := os.WriteFile(p, data, perms.FileMode()); err != nil {
 				return errwrap.Wrapf(err, "autoConfigURI store failed on: %s", p)
 			}
 
 			// recurse!
 			if !equal { // otherwise we'd infinitely loop!
 				logf("recursing on new config...")
-				return App(c, program, version, debug)
+				return App(c, program, version, debug, messages)
 			}
 
 		} else if err != nil {
@@ -542,7 +1946,7 @@ func App(c *cli.Context, program, version string, debug bool) error {
 
 	var absFile safepath.AbsFile
 	if len(configKeys) > 0 {
-		p, err := GetConfigPath(c.String("config-path"))
+		p, err := GetConfigPath(program, c.String("config-path"))
 		if err != nil {
 			return err
 		}
@@ -604,13 +2008,12 @@ func App(c *cli.Context, program, version string, debug bool) error {
 			d := filepath.Dir(h)
 			// maybe the ~/.config/yesiscan/?/ dir doesn't exist yet!
 			if _, err := os.Stat(d); os.IsNotExist(err) { // no config exists here...
-				if err := os.MkdirAll(d, interfaces.Umask); err != nil {
+				if err := os.MkdirAll(d, perms.DirMode()); err != nil {
 					return errwrap.Wrapf(err, "couldn't make config dir at: %s", d)
 				}
 			}
 
-			// XXX: set umask for u=rw,go=
-			if err := os.WriteFile(h, data, interfaces.Umask); err != nil {
+			if err := os.WriteFile(h, data, perms.FileMode()); err != nil {
 				return errwrap.Wrapf(err, "autoConfigURI store additional failed on: %s", k)
 			}
 
@@ -632,7 +2035,7 @@ func App(c *cli.Context, program, version string, debug bool) error {
 	}
 	if recurse {
 		logf("recursing on new additional config...")
-		return App(c, program, version, debug)
+		return App(c, program, version, debug, messages)
 	}
 
 	// Are we requesting a specific version?
@@ -641,7 +2044,7 @@ func App(c *cli.Context, program, version string, debug bool) error {
 	}
 	if autoConfigBinaryVersion != "" && version != autoConfigBinaryVersion && autoConfigError == nil {
 		logf("%s does NOT match the recommended version of: %s", program, autoConfigBinaryVersion)
-		configPath, err := GetConfigPath(c.String("config-path"))
+		configPath, err := GetConfigPath(program, c.String("config-path"))
 		if err != nil {
 			return err
 		}
@@ -700,13 +2103,12 @@ func App(c *cli.Context, program, version string, debug bool) error {
 				d := filepath.Dir(bPath)
 				// maybe the ~/.config/yesiscan/binaries/ dir doesn't exist yet!
 				if _, err := os.Stat(d); os.IsNotExist(err) { // no config exists here...
-					if err := os.MkdirAll(d, interfaces.Umask); err != nil {
+					if err := os.MkdirAll(d, perms.DirMode()); err != nil {
 						return errwrap.Wrapf(err, "couldn't make binaries dir at: %s", d)
 					}
 				}
 
-				// XXX: set umask for u=rwx,go=rx
-				if err := os.WriteFile(bPath, data, 0777); err != nil {
+				if err := os.WriteFile(bPath, data, perms.ExecMode()); err != nil {
 					return errwrap.Wrapf(err, "autoConfigURI store binary failed on: %s", bPath)
 				}
 				stdoutStderr, runErr = run() // now try again
@@ -757,11 +2159,69 @@ func App(c *cli.Context, program, version string, debug bool) error {
 		}
 	}
 
-	if outputPath == "-" || outputTemplate == "-" || quiet { // if output is stdout, noop logs
+	// The logs go to stderr, so they can only mix with a report on stdout
+	// in the legacy mode, where they get silenced instead.
+	toStdout := outputPath == "-" || outputTemplate == "-"
+	if quiet || (legacyStdout && toStdout) {
 		logf = func(format string, v ...interface{}) {
 			// noop
 		}
 	}
+	publishers := []publish.Publisher{}
+	if dependencyTrackOptions.URL != "" {
+		publishers = append(publishers, &publish.DependencyTrack{
+			Debug: debug,
+			Logf: func(format string, v ...interface{}) {
+				logf("publish: dependency-track: "+format, v...)
+			},
+			Options: dependencyTrackOptions,
+		})
+	}
+	if sw360Options.URL != "" {
+		publishers = append(publishers, &publish.SW360{
+			Debug: debug,
+			Logf: func(format string, v ...interface{}) {
+				logf("publish: sw360: "+format, v...)
+			},
+			Options: sw360Options,
+		})
+	}
+	if jiraOptions.URL != "" {
+		publishers = append(publishers, &publish.Jira{
+			Debug: debug,
+			Logf: func(format string, v ...interface{}) {
+				logf("publish: jira: "+format, v...)
+			},
+			Options: jiraOptions,
+		})
+	}
+	if chatOptions.Webhook != "" {
+		stateDir := "" // if we can't remember, then everything is new
+		if userCacheDir, err := os.UserCacheDir(); err == nil {
+			stateDir = filepath.Join(userCacheDir, program, publish.ChatStateDir)
+		}
+		publishers = append(publishers, &publish.Chat{
+			Debug: debug,
+			Logf: func(format string, v ...interface{}) {
+				logf("publish: chat: "+format, v...)
+			},
+			Options:  chatOptions,
+			StateDir: stateDir,
+			Perms:    perms,
+		})
+	}
+	var emailPublisher *publish.Email // the report is attached once we have it
+	if emailOptions.Server != "" {
+		emailPublisher = &publish.Email{
+			Debug: debug,
+			Logf: func(format string, v ...interface{}) {
+				logf("publish: email: "+format, v...)
+			},
+			Options: emailOptions,
+		}
+		publishers = append(publishers, emailPublisher)
+	}
+
 	args := []string{}
 	for i := 0; i < c.NArg(); i++ {
 		s := c.Args().Get(i)
@@ -804,6 +2264,23 @@ func App(c *cli.Context, program, version string, debug bool) error {
 			backends[b] = true
 		}
 	}
+	// The staged files only exist in memory, so by default we don't even
+	// start the backends that could never see them.
+	for _, b := range lib.Backends {
+		if changedOnly && !util.StrInList(b, lib.DataBackends) && !c.Bool(fmt.Sprintf("yes-backend-%s", b)) {
+			backends[b] = false
+		}
+	}
+
+	if offline && cacheS3Bucket != "" {
+		logf("offline: using the local cache instead of the shared one in: %s", cacheS3Bucket)
+		cacheS3Bucket = "" // we can't download anything
+		cache = true       // the shared cache would have enabled this
+	}
+	cacheRemote, cacheSigningKey, err := NewCacheRemote(debug, logf, region, cacheS3Bucket, cacheS3Prefix, cacheSigningKeyPath)
+	if err != nil {
+		return err
+	}
 
 	if outputS3Bucket != "" { // do a test-for-auth run
 
@@ -844,41 +2321,195 @@ func App(c *cli.Context, program, version string, debug bool) error {
 		return nil
 	}
 
-	m := &lib.Main{
+	// The porcelain lines go out as soon as each result is found, so that
+	// a pipeline can consume them while the scan is still running.
+	var onResult func(string, interfaces.Backend, *interfaces.Result)
+	if porcelain {
+		if outputPath == "-" || outputTemplate == "-" {
+			return fmt.Errorf("porcelain mode can't share stdout with the output")
+		}
+		mutex := &sync.Mutex{}
+		onResult = func(uid string, backend interfaces.Backend, result *interfaces.Result) {
+			s := lib.ReturnPorcelain(displayNames.Replace(uid), backend, result)
+			mutex.Lock()
+			defer mutex.Unlock()
+			fmt.Print(s)
+		}
+	}
+
+	y, err := yesiscan.New(&yesiscan.Options{
 		Program: program,
 		Version: version,
 		Debug:   debug,
 		Logf:    logf,
 
-		Args:     args,
 		Backends: backends,
 
 		Profiles: profiles,
 
 		RegexpPath: regexpPath,
-	}
 
-	output, err := m.Run(ctx)
+		OrtAnalyzerPath: ortAnalyzerPath,
+		ChecksumsPath:   checksumsPath,
+		Offline:         offline,
+		ParserPlugins:   plugins,
+
+		InferLicenses: inferLicenses,
+		Reuse:         reuse,
+		IgnorePath:    ignorePath,
+		CurationsPath: curationsPath,
+
+		// only the notice output needs these, so don't waste the time
+		ExtractCopyrights: outputType == "notice",
+
+		Ownership:         ownership,
+		Scope:             scope,
+		SummaryFirstParty: summaryFirstParty,
+
+		ObligationsPath: obligationsPath,
+		Blend:           confidenceBlend,
+		SmartURIs:       smartURIFuncs,
+		Duplicates:      duplicates,
+		MemoryBudget:    memoryBudget * 1024 * 1024,  // MiB to bytes
+		MmapThreshold:   mmapThreshold * 1024 * 1024, // MiB to bytes
+		Workspace:       workspace,
+		Perms:           perms,
+		Cache:           cache,
+		CacheRemote:     cacheRemote,
+		CacheSigningKey: cacheSigningKey,
+		Estimate:        estimate,
+		RawOutput:       rawOutput,
+		Quick:           quick,
+		Deep:            deep,
+		Schedule:        schedule,
+		OnResult:        onResult,
+
+		BandwidthLimit:     bandwidthLimit * 1024,     // KiB to bytes
+		HostBandwidthLimit: hostBandwidthLimit * 1024, // KiB to bytes
+		MaxDownloads:       maxDownloads,
+
+		MaxFiles:    maxFiles,
+		MaxBytes:    maxSize * 1024 * 1024, // MiB to bytes
+		MaxDuration: maxTime,
+
+		Scancode:   scancodeOptions,
+		Askalono:   askalonoOptions,
+		GomodProxy: gomodProxy,
+
+		Stdin: os.Stdin,
+	})
 	if err != nil {
 		return err
 	}
 
+	var output *lib.Output
+	if changedOnly {
+		if len(args) > 1 {
+			return fmt.Errorf("changed-only mode takes at most one repository path")
+		}
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		fsys, err := iterator.GitStagedFS(ctx, dir)
+		if err != nil {
+			return errwrap.Wrapf(err, "could not read the staged files")
+		}
+		name, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		// only the backends which can scan data see these files
+		if output, err = y.ScanFS(ctx, filepath.Base(name), fsys); err != nil {
+			return err
+		}
+	} else {
+		if output, err = y.Scan(ctx, args...); err != nil {
+			return err
+		}
+	}
+	// violations is what we return at the end in changed-only mode, so that
+	// a hook can stop the commit once the results have been shown.
+	var violations error
+	for _, x := range output.Verdicts {
+		if changedOnly && x.Verdict == lib.VerdictFail {
+			violations = fmt.Errorf("profile %s has %d violations", x.Profile, len(x.Violations))
+			break
+		}
+	}
+
 	s := ""
 	if outputPath != "" || outputTemplate != "" || outputS3Bucket != "" {
 		var err error
 		// TODO: when we render an html version, should
 		// it look the same as the web `save` output?
-		if outputType == "text" {
+		switch outputType {
+		case "text":
 			if s, err = lib.ReturnOutputFile(output); err != nil {
 				return err
 			}
-		} else {
+		case "json":
+			if s, err = lib.ReturnOutputJSON(output); err != nil {
+				return err
+			}
+		case "scancode":
+			if s, err = lib.ReturnOutputScancode(output); err != nil {
+				return err
+			}
+		case "ort":
+			if s, err = lib.ReturnOutputOrt(output); err != nil {
+				return err
+			}
+		case "cyclonedx":
+			if s, err = lib.ReturnOutputCycloneDX(output); err != nil {
+				return err
+			}
+		case "spdx":
+			if s, err = lib.ReturnOutputSPDX(output); err != nil {
+				return err
+			}
+		case "spdx-json":
+			if s, err = lib.ReturnOutputSPDXJSON(output); err != nil {
+				return err
+			}
+		case "sarif":
+			if s, err = lib.ReturnOutputSARIF(output); err != nil {
+				return err
+			}
+		case "csv":
+			if s, err = lib.ReturnOutputCSV(output); err != nil {
+				return err
+			}
+		case "notice":
+			if s, err = lib.ReturnOutputNotice(output); err != nil {
+				return err
+			}
+		default:
 			if s, err = web.ReturnOutputHtml(output); err != nil {
 				return err
 			}
 		}
 	}
 
+	// The display names are shown first, and then the redaction hides any
+	// of them that are internal. Both are a noop unless they were asked for.
+	redactString := displayNames.Replace
+	if redact {
+		redactor := newRedactor(program, output, publicHosts)
+		redactString = func(s string) string {
+			return redactor.Redact(displayNames.Replace(s))
+		}
+	}
+	s = redactString(s)
+	if emailPublisher != nil {
+		emailPublisher.Render = func(output *lib.Output) (string, error) {
+			h, err := web.ReturnOutputHtml(output)
+			return redactString(h), err
+		}
+		emailPublisher.Redact = redactString
+	}
+
+	reportURL := "" // public link to the report, if we know one
 	if outputS3Bucket != "" {
 		ext := "html"
 		contentType := "text/html"
@@ -886,6 +2517,26 @@ func App(c *cli.Context, program, version string, debug bool) error {
 			ext = "txt"
 			contentType = "text/plain"
 		}
+		if outputType == "spdx" {
+			ext = "spdx"
+			contentType = "text/plain"
+		}
+		if outputType == "notice" {
+			ext = "txt"
+			contentType = "text/plain"
+		}
+		if outputType == "json" || outputType == "scancode" || outputType == "ort" || outputType == "cyclonedx" || outputType == "spdx-json" {
+			ext = "json"
+			contentType = "application/json"
+		}
+		if outputType == "sarif" {
+			ext = "sarif"
+			contentType = "application/sarif+json"
+		}
+		if outputType == "csv" {
+			ext = "csv"
+			contentType = "text/csv"
+		}
 
 		// make a unique ID for the file
 		// XXX: we can consider different algorithms or methods here later...
@@ -922,22 +2573,30 @@ func App(c *cli.Context, program, version string, debug bool) error {
 		if err != nil {
 			logf("could not write s3 file: %+v", err)
 		} else {
-			fmt.Printf("S3 Sig URL: %s\n", u)
-			fmt.Printf("S3 Pub URL: %s\n", s3.PubURL(region, outputS3Bucket, objectName))
+			fmt.Fprintf(messages, "S3 Sig URL: %s\n", u)
+			fmt.Fprintf(messages, "S3 Pub URL: %s\n", s3.PubURL(region, outputS3Bucket, objectName))
+			reportURL = s3.PubURL(region, outputS3Bucket, objectName)
+		}
+	}
+
+	for _, x := range publishers {
+		if err := x.Publish(ctx, output, reportURL); err != nil {
+			logf("could not publish to %s: %+v", x, err)
 		}
 	}
 
 	if outputPath == "-" {
-		// NOTE: if we get asked for stdout, we
-		// turn off other output to make it sane
-		// TODO: should logs go to stderr instead?
-		quiet = true           // redundant for now
+		// NOTE: if we get asked for stdout, then
+		// only the report goes there, and there
+		// is no console output after it either.
 		_, err := fmt.Print(s) // to stdout
-		return err
+		if err != nil {
+			return err
+		}
+		return violations
 
 	} else if outputPath != "" {
-		// TODO: is this the umask we should use?
-		if err := os.WriteFile(outputPath, []byte(s), 0660); err != nil {
+		if err := os.WriteFile(outputPath, []byte(s), perms.FileMode()); err != nil {
 			logf("could not write output file: %+v", err)
 		}
 	} else if outputTemplate != "" {
@@ -951,23 +2610,102 @@ func App(c *cli.Context, program, version string, debug bool) error {
 
 		outputPath := util.NamedArgsTemplate(outputTemplate, replacements)
 
-		// TODO: is this the umask we should use?
-		// XXX: set umask for u=rw,go=
-		if err := os.WriteFile(outputPath, []byte(s), 0660); err != nil {
+		if err := os.WriteFile(outputPath, []byte(s), perms.FileMode()); err != nil {
 			logf("could not write templated output file: %+v", err)
 		}
 	}
 
-	if !quiet {
-		s, err := lib.ReturnOutputConsole(output)
+	if triagePath != "" {
+		f := lib.ReturnTriageCSV
+		if strings.HasSuffix(strings.ToLower(triagePath), ".json") {
+			f = lib.ReturnTriageJSON
+		}
+		t, err := f(output)
+		if err != nil {
+			return err
+		}
+		logf("triage: %d unknown files", len(output.Triage))
+		if err := os.WriteFile(triagePath, []byte(redactString(t)), perms.FileMode()); err != nil {
+			logf("could not write triage file: %+v", err)
+		}
+	}
+
+	if remediationPath != "" {
+		f := lib.ReturnRemediationCSV
+		if strings.HasSuffix(strings.ToLower(remediationPath), ".json") {
+			f = lib.ReturnRemediationJSON
+		}
+		r, err := f(output)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(remediationPath, []byte(redactString(r)), perms.FileMode()); err != nil {
+			logf("could not write remediation file: %+v", err)
+		}
+	}
+
+	if sbomDir != "" {
+		documents, index, err := lib.SplitSBOM(output, sbomFormat)
+		if err != nil {
+			return err
+		}
+		i, err := lib.ReturnSBOMIndex(index)
+		if err != nil {
+			return err
+		}
+		documents[lib.SBOMIndexName] = i
+		if err := os.MkdirAll(sbomDir, perms.DirMode()); err != nil {
+			return err
+		}
+		for name, data := range documents {
+			if err := os.WriteFile(filepath.Join(sbomDir, name), []byte(redactString(data)), perms.FileMode()); err != nil {
+				logf("could not write sbom file: %+v", err)
+			}
+		}
+		logf("sbom: wrote %d documents to: %s", len(index.Documents), sbomDir)
+	}
+
+	if !quiet && !porcelain { // the porcelain lines were the console output
+		f := lib.ReturnOutputFile // no escape sequences
+		if useColor {
+			f = lib.ReturnOutputConsole
+		}
+		s, err := f(output)
 		if err != nil {
 			return err
 		}
 
-		fmt.Print(s) // display it
+		// wrap it to fit the terminal, this is a noop if it's not one
+		width := ansi.Width(int(os.Stdout.Fd()))
+		fmt.Print(ansi.Wrap(displayNames.Replace(s), width)) // display it
 	}
 
-	return nil
+	return violations
+}
+
+// newRedactor returns a redactor which hides everything identifying in this
+// output, as well as the cache directory, the home directory, the name of the
+// current user, and the host name of this machine.
+func newRedactor(program string, output *lib.Output, publicHosts []string) *lib.Redactor {
+	redactor := &lib.Redactor{}
+	if len(publicHosts) > 0 {
+		redactor.PublicHosts = append(publicHosts, lib.DefaultPublicHosts...)
+	}
+	redactor.AddOutput(output) // the args get the first placeholders
+	if dir, err := os.UserCacheDir(); err == nil {
+		redactor.AddPath(filepath.Join(dir, program))
+	}
+	if dir, err := os.UserHomeDir(); err == nil {
+		redactor.AddPath(dir)
+	}
+	redactor.AddPath(os.TempDir())
+	if u, err := user.Current(); err == nil {
+		redactor.AddUser(u.Username)
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		redactor.AddHost(hostname)
+	}
+	return redactor
 }
 
 // NamedArgsTemplate takes a format string that contains named args wrapped in
@@ -1027,19 +2765,31 @@ type Config struct {
 	AutoConfigBinaryVersion *string `json:"auto-config-binary-version"`
 
 	// Quiet will prevent the tool from talking too much on the console.
-	// This is implied if you use the stdout option of --output-path.
 	Quiet *bool `json:"quiet"`
 
+	// LegacyStdout prints the errors and other notices to stdout and
+	// silences the logs when the output is stdout, like older versions.
+	LegacyStdout *bool `json:"legacy-stdout"`
+
+	// Porcelain prints one stable line per finding to stdout as soon as
+	// it's found, instead of the console report at the end.
+	Porcelain *bool `json:"porcelain"`
+
 	// AnsiMagic will do some ansi terminal escape sequence magic to keep
 	// the console output cleaner if this is set.
 	AnsiMagic *bool `json:"ansi-magic"`
 
+	// Color specifies whether to colour the console output. It can be
+	// auto, always, or never. In auto mode, the output is only coloured if
+	// it's going to a terminal and if NO_COLOR isn't set.
+	Color *string `json:"color"`
+
 	// RegexpPath specifies a path the regular expressions to use.
 	RegexpPath *string `json:"regexp-path"`
 	// config-path makes no sense here
 
 	// OutputType is the format the report will be sent as. Options include
-	// "html" and "text".
+	// "html", "text", and "json".
 	OutputType *string `json:"output-type"`
 
 	// OutputPath is the location where the report will be saved. This will
@@ -1058,6 +2808,25 @@ type Config struct {
 	// "date": Returns the RFC3339 date with colons changed to dashes.
 	OutputTemplate *string `json:"output-template"`
 
+	// Redact replaces the local paths, the user names, and the internal host
+	// names in the saved reports with stable placeholders, so that they can
+	// be shared with people outside of the organization.
+	Redact *bool `json:"redact"`
+
+	// PublicHosts is a list of host names to leave alone when redacting, in
+	// addition to the well known public ones.
+	PublicHosts *[]string `json:"public-hosts"`
+
+	// DisplayNames maps the prefixes of UID's to the friendlier prefixes to
+	// show in the reports instead. For example, to show an internal mirror
+	// as the upstream: {"https://git.example.com/mirror/": "https://github.com/"}.
+	DisplayNames *map[string]string `json:"display-names"`
+
+	// SmartURIs link the UID's that match each regexp to an internal code
+	// browser in the reports, instead of only to the well known public
+	// hosts. The first one that matches wins.
+	SmartURIs *[]*util.SmartURITemplate `json:"smart-uris"`
+
 	// OutputS3Bucket prints the report to an S3 bucket with this name. Make
 	// sure you don't have anything important in the bucket as it might
 	// overwrite any file in there as the report name is chosen
@@ -1071,6 +2840,198 @@ type Config struct {
 	// ~/.config/yesiscan/profiles/<name>.json or full paths.
 	Profiles *[]string `json:"profiles"`
 
+	// InferLicenses enables the inference pass which gives files without
+	// any license determination the license of the nearest enclosing
+	// LICENSE or COPYING file. These results are flagged as inferred.
+	InferLicenses *bool `json:"infer-licenses"`
+
+	// Reuse enables the check of each artifact against the REUSE
+	// specification, which is shown as an additional verdict.
+	Reuse *bool `json:"reuse"`
+
+	// OrtAnalyzer is the path to an ORT analyzer result in json. The source
+	// of each package in there is also scanned.
+	OrtAnalyzer *string `json:"ort-analyzer"`
+
+	// ChecksumsPath is the path to a json manifest of the expected sha256
+	// sums of the inputs which get downloaded.
+	ChecksumsPath *string `json:"checksums-path"`
+
+	// Offline forbids all network access. Only local paths and git repos
+	// which are already in the local cache can be scanned.
+	Offline *bool `json:"offline"`
+
+	// ParserPlugins maps a URI scheme to the command which resolves the
+	// inputs with that scheme. Eg: {"internal": "/usr/bin/resolve"}.
+	ParserPlugins *map[string]string `json:"parser-plugins"`
+
+	// TriagePath is the location where the list of files with an unknown
+	// license will be saved. If it ends with .json then it will be in the
+	// json format, otherwise it will be csv.
+	TriagePath *string `json:"triage-path"`
+
+	// RemediationPath is the location where the list of suggested SPDX
+	// headers will be saved. If it ends with .json then it will be in the
+	// json format, otherwise it will be csv.
+	RemediationPath *string `json:"remediation-path"`
+
+	// SBOMDir is the directory where one SBOM document for each package is
+	// saved, along with an index.json file which lists them.
+	SBOMDir *string `json:"sbom-dir"`
+
+	// SBOMFormat is the format of the split SBOM documents.
+	SBOMFormat *string `json:"sbom-format"`
+
+	// IgnorePath specifies a path to the ignore list of content hashes.
+	IgnorePath *string `json:"ignore-path"`
+
+	// CurationsPath specifies a path to the curations file of the decisions
+	// that reviewers made about the findings.
+	CurationsPath *string `json:"curations-path"`
+
+	// ObligationsPath specifies a path to a file of license obligations to
+	// add to the built-in knowledge base.
+	ObligationsPath *string `json:"obligations-path"`
+
+	// Ownership is the set of rules that classify each scanned file as
+	// first-party or third-party code.
+	Ownership *lib.Ownership `json:"ownership"`
+
+	// Since only scans the files which were changed in git since this date,
+	// either as 2006-01-02 or as an RFC 3339 time.
+	Since *string `json:"since"`
+
+	// SinceCommit only scans the files which were changed in git since this
+	// commit, which may be any revision such as a hash or a tag.
+	SinceCommit *string `json:"since-commit"`
+
+	// SummaryFirstParty includes the first-party code in the summaries,
+	// and not just the third-party code.
+	SummaryFirstParty *bool `json:"summary-first-party"`
+
+	// ConfidenceBlend is the method used to combine the confidence values
+	// of the different backends. Options include "linear", "max", and
+	// "agreement".
+	ConfidenceBlend *string `json:"confidence-blend"`
+
+	// Workspace isolates the downloads and extractions of each scan in a
+	// temporary directory which is removed afterwards.
+	Workspace *bool `json:"workspace"`
+
+	// Permissions is the permission policy for everything that we write.
+	// Options include "private", "group", and "shared".
+	Permissions *string `json:"permissions"`
+
+	// Duplicates is the policy for what to do when a backend gives us two
+	// different results for the same path. Options include "error",
+	// "keep-first", and "keep-highest".
+	Duplicates *string `json:"duplicates"`
+
+	// MemoryBudget is the maximum number of MiB of file data to hold in
+	// memory at once while scanning. Zero means there is no limit.
+	MemoryBudget *int64 `json:"memory-budget"`
+
+	// MmapThreshold is the file size in MiB at or above which files are
+	// memory mapped instead of read. Zero means we never use mmap.
+	MmapThreshold *int64 `json:"mmap-threshold"`
+
+	// Cache enables the on-disk cache of the result of each backend for
+	// each file.
+	Cache *bool `json:"cache"`
+
+	// CacheS3Bucket shares the cache with other machines in the S3 bucket
+	// with this name. It enables the cache.
+	CacheS3Bucket *string `json:"cache-s3bucket"`
+
+	// CacheS3Prefix is the prefix of the names of the cache entries in the
+	// S3 bucket.
+	CacheS3Prefix *string `json:"cache-s3prefix"`
+
+	// CacheSigningKeyPath is the path to the secret that the entries of the
+	// shared cache are signed with.
+	CacheSigningKeyPath *string `json:"cache-signing-key-path"`
+
+	// Estimate predicts how long each backend will take before the scan.
+	Estimate *bool `json:"estimate"`
+
+	// RawOutput keeps the compressed json output of scancode and askalono
+	// in the json results.
+	RawOutput *bool `json:"raw-output"`
+
+	// GomodProxy is the url of the module proxy that the gomod backend
+	// downloads the modules which aren't in the module cache from.
+	GomodProxy *string `json:"gomod-proxy"`
+
+	// Quick only runs the fast built-in backends, for a lower assurance
+	// scan that gives feedback quickly.
+	Quick *bool `json:"quick"`
+
+	// Deep extracts the text of pdf, docx and rtf documents, and scans that
+	// instead of the raw file data.
+	Deep *bool `json:"deep"`
+
+	// Schedule only runs the expensive backends on the files that the
+	// cheap ones found nothing in, or disagreed about.
+	Schedule *bool `json:"schedule"`
+
+	// BandwidthLimit is the maximum number of KiB per second that all the
+	// downloads share. Zero means there is no limit.
+	BandwidthLimit *int64 `json:"bandwidth-limit"`
+
+	// HostBandwidthLimit is the maximum number of KiB per second that we
+	// download from any one host. Zero means there is no limit.
+	HostBandwidthLimit *int64 `json:"host-bandwidth-limit"`
+
+	// MaxDownloads is the maximum number of downloads that run at the
+	// same time. Zero means there is no limit.
+	MaxDownloads *int `json:"max-downloads"`
+
+	// MaxFiles is the most files that a scan will read before it stops.
+	// Zero means there is no limit.
+	MaxFiles *int64 `json:"max-files"`
+
+	// MaxSize is the most MiB of files that a scan will read before it
+	// stops. Zero means there is no limit.
+	MaxSize *int64 `json:"max-size"`
+
+	// MaxTime is the longest a scan will run for before it stops, as a
+	// golang duration string such as "10m". Zero means there is no limit.
+	MaxTime *string `json:"max-time"`
+
+	// Scancode are the options that get passed through to scancode. Eg:
+	// {"processes": 4, "timeout": 60, "license-score": 50, "plugins": []}.
+	Scancode *backend.ScancodeOptions `json:"scancode"`
+
+	// Askalono are the options for the askalono backend. Eg:
+	// {"dataset": "/path/to/license-list-data/json/details/", "confidence": 0.9}.
+	Askalono *backend.AskalonoOptions `json:"askalono"`
+
+	// DependencyTrack are the settings for uploading a CycloneDX bom of the
+	// scan to Dependency-Track. Eg: {"url": "https://dtrack.example.com",
+	// "api-key": "...", "project": "myproject", "version": "1.0"}.
+	DependencyTrack *publish.DependencyTrackOptions `json:"dependency-track"`
+
+	// SW360 are the settings for pushing the concluded licenses of each
+	// artifact to SW360. Eg: {"url": "https://sw360.example.com/resource/api",
+	// "token": "...", "version": "1.0"}.
+	SW360 *publish.SW360Options `json:"sw360"`
+
+	// Jira are the settings for opening a ticket for each artifact with a
+	// policy violation. Eg: {"url": "https://example.atlassian.net",
+	// "project": "LIC", "user": "me@example.com", "token": "..."}.
+	Jira *publish.JiraOptions `json:"jira"`
+
+	// Chat are the settings for posting a summary of each scan to a chat
+	// room. Eg: {"webhook": "https://hooks.slack.com/services/...",
+	// "kind": "slack"}.
+	Chat *publish.ChatOptions `json:"chat"`
+
+	// Email are the settings for emailing a summary of each scan. Eg:
+	// {"server": "smtp.example.com:587", "username": "...", "password":
+	// "...", "from": "yesiscan@example.com", "to": ["legal@example.com"],
+	// "attach": true}.
+	Email *publish.EmailOptions `json:"email"`
+
 	// Configs is the list of config additions to use. These files are
 	// downloaded from the URI's (map values) and put into the corresponding
 	// source (map keys).
@@ -1086,12 +3047,17 @@ type Config struct {
 	// path. The unique binary identifier is in the format: "%s-%s-%s" where
 	// the three substitutions are GOOS, GOARCH, and program version.
 	Binaries *map[string]string `json:"binaries"`
+
+	// Presets are named sets of these same settings, which are applied on
+	// top of the rest of the config when they're chosen with --preset. Eg:
+	// {"quick": {"backends": {"regexp": true}, "output-type": "text"}}.
+	Presets map[string]*Config `json:"presets"`
 }
 
 // GetConfig loads the config file data into a struct.
-func GetConfig(p string) (*Config, error) {
+func GetConfig(program, p string) (*Config, error) {
 
-	configPath, err := GetConfigPath(p)
+	configPath, err := GetConfigPath(program, p)
 	if err != nil {
 		return nil, err
 	}
@@ -1122,7 +3088,7 @@ func GetConfig(p string) (*Config, error) {
 // GetConfigPath returns the expected path to the main config.json file given
 // the input arg for that setting.
 // FIXME: switch to using types (at least for the return type) from safepath lib
-func GetConfigPath(configPath string) (string, error) {
+func GetConfigPath(program, configPath string) (string, error) {
 	// If config path is set, we look in there for a config, otherwise we
 	// use the default xdg path.
 	if configPath != "" {
@@ -1137,7 +3103,6 @@ func GetConfigPath(configPath string) (string, error) {
 		return "", fmt.Errorf("home directory is empty")
 	}
 
-	// TODO: get program from an input var perhaps?
 	p := filepath.Join(home, ".config/", program+"/", ConfigFileName)
 	return filepath.Clean(p), nil
 }
@@ -1197,11 +3162,11 @@ func DownloadConfig(uri, cookie string) ([]byte, error) {
 func main() {
 	debug := false // TODO: hardcoded for now
 
-	program = strings.TrimSpace(program)
-	version = strings.TrimSpace(version)
+	program := strings.TrimSpace(embeddedProgram)
+	version := strings.TrimSpace(embeddedVersion)
 	if program == "" || version == "" {
 		// run `go generate` before you build it.
-		fmt.Printf("program was not compiled correctly\n")
+		fmt.Fprintf(os.Stderr, "program was not compiled correctly\n")
 		os.Exit(1)
 		return
 	}
@@ -1209,12 +3174,14 @@ func main() {
 	// FIXME: We discard output from lib's that use `log` package directly.
 	log.SetOutput(io.Discard)
 
+	messages := &Messages{}
+
 	// TODO: put these args in an input struct
-	if err := CLI(program, version, debug); err != nil {
+	if err := CLI(program, version, debug, messages); err != nil {
 		if debug {
-			fmt.Printf("failed: %+v\n", err)
+			fmt.Fprintf(messages, "failed: %+v\n", err)
 		} else {
-			fmt.Printf("failed: %+v\n", errwrap.Cause(err))
+			fmt.Fprintf(messages, "failed: %+v\n", errwrap.Cause(err))
 		}
 		os.Exit(1)
 		return
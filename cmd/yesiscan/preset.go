@@ -0,0 +1,63 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ApplyPreset copies every setting of the named preset on top of this config.
+// The settings which the preset doesn't have are left alone. The maps, such as
+// the backends, are replaced entirely instead of being merged.
+func (obj *Config) ApplyPreset(name string) error {
+	preset, exists := obj.Presets[name]
+	if !exists || preset == nil {
+		names := []string{}
+		for k := range obj.Presets {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("unknown preset %s, the config has none", name)
+		}
+		return fmt.Errorf("unknown preset %s, expected one of: %s", name, strings.Join(names, ", "))
+	}
+	if preset.Presets != nil {
+		return fmt.Errorf("preset %s can't contain more presets", name)
+	}
+
+	dst := reflect.ValueOf(obj).Elem()
+	src := reflect.ValueOf(preset).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		// every field is a pointer or a map, so nil means it's unset
+		if src.Field(i).IsNil() {
+			continue
+		}
+		dst.Field(i).Set(src.Field(i))
+	}
+	return nil
+}
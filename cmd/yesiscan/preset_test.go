@@ -0,0 +1,69 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestApplyPreset(t *testing.T) {
+	data := `{
+		"output-type": "html",
+		"quiet": true,
+		"backends": {"spdx": true, "scancode": true},
+		"presets": {
+			"quick": {"output-type": "text", "backends": {"regexp": true}},
+			"nested": {"presets": {"x": {}}}
+		}
+	}`
+	config := &Config{}
+	if err := json.Unmarshal([]byte(data), config); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+
+	if err := config.ApplyPreset("quick"); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if config.OutputType == nil || *config.OutputType != "text" {
+		t.Errorf("expected the output type of the preset, got: %v", config.OutputType)
+	}
+	if config.Quiet == nil || !*config.Quiet {
+		t.Errorf("expected the settings that the preset doesn't have to be kept")
+	}
+	if len(config.Backends) != 1 || !config.Backends["regexp"] {
+		t.Errorf("expected the backends to be replaced, got: %v", config.Backends)
+	}
+
+	if err := config.ApplyPreset("nested"); err == nil {
+		t.Errorf("expected an error for a preset with presets in it")
+	}
+	if err := config.ApplyPreset("missing"); err == nil || !strings.Contains(err.Error(), "nested, quick") {
+		t.Errorf("expected an error with the list of presets, got: %v", err)
+	}
+	if err := (&Config{}).ApplyPreset("quick"); err == nil || !strings.Contains(err.Error(), "has none") {
+		t.Errorf("expected an error for a config without presets, got: %v", err)
+	}
+}
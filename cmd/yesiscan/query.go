@@ -0,0 +1,84 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/errwrap"
+
+	cli "github.com/urfave/cli/v2" // imports as package "cli"
+)
+
+// Query runs a query over stored json outputs and prints the matching results.
+// The first arg is the query, and each of the others is either a json output
+// file, or a directory which is searched for them.
+func Query(c *cli.Context, program, version string, debug bool) error {
+	if c.NArg() < 2 {
+		return cli.ShowSubcommandHelp(c)
+	}
+	args := c.Args().Slice()
+
+	query, err := lib.ParseQuery(args[0])
+	if err != nil {
+		return errwrap.Wrapf(err, "invalid query")
+	}
+	paths, err := JSONOutputPaths(args[1:])
+	if err != nil {
+		return err
+	}
+
+	matches := []*lib.QueryRow{}
+	for _, path := range paths {
+		output, err := lib.ReadJSONOutput(path)
+		if err != nil {
+			return errwrap.Wrapf(err, "could not read output %s", path)
+		}
+		source := ""
+		if len(paths) > 1 {
+			source = path
+		}
+		matches = append(matches, query.Filter(lib.QueryRows(source, output))...)
+	}
+
+	var s string
+	switch outputType := c.String("output-type"); outputType {
+	case "text":
+		if s, err = lib.ReturnQueryRows(matches); err != nil {
+			return err
+		}
+	case "json":
+		b, err := json.MarshalIndent(matches, "", "\t")
+		if err != nil {
+			return err
+		}
+		s = string(b) + "\n"
+	default:
+		return fmt.Errorf("unknown output type: %s", outputType)
+	}
+	_, err = fmt.Print(s) // to stdout
+	return err
+}
@@ -0,0 +1,135 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/awslabs/yesiscan"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/ansi"
+	"github.com/awslabs/yesiscan/util/errwrap"
+
+	cli "github.com/urfave/cli/v2" // imports as package "cli"
+)
+
+// SelfTest scans the embedded golden corpus of known license files and checks
+// that each enabled backend finds the expected licenses. This catches broken
+// local tool installs and license database drift before anyone trusts a scan.
+func SelfTest(c *cli.Context, program, version string, debug bool) error {
+	logf := (&ansi.Logf{
+		Prefix:   "main: ",
+		Ellipsis: "...",
+		Enable:   false,
+		Prefixes: []string{},
+	}).Init()
+	logf("Hello from purpleidea! This is %s, version: %s", program, version)
+	defer logf("Done!")
+
+	backends := make(map[string]bool)
+	for _, x := range lib.Backends {
+		backends[x] = true
+	}
+	if c.IsSet("backend") {
+		backends = make(map[string]bool) // erase any previous
+		for _, x := range c.StringSlice("backend") {
+			backends[x] = true
+		}
+	}
+
+	cases, err := lib.SelfTestCases()
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", program+"-selftest-")
+	if err != nil {
+		return errwrap.Wrapf(err, "could not make temporary directory")
+	}
+	defer os.RemoveAll(dir)
+	dir += "/" // the fs iterator needs a trailing slash for dirs
+	if err := lib.WriteSelfTestCorpus(dir, nil); err != nil {
+		return errwrap.Wrapf(err, "could not write corpus")
+	}
+
+	y, err := yesiscan.New(&yesiscan.Options{
+		Program: program,
+		Version: version,
+		Debug:   debug,
+		Logf: func(format string, v ...interface{}) {
+			logf("lib: "+format, v...)
+		},
+		Backends: backends,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	output, err := y.Scan(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	uid := func(path string) string {
+		return iterator.FileScheme + dir + path
+	}
+	outcomes := lib.CheckSelfTest(cases, output.Results, backends, uid)
+
+	s := &strings.Builder{}
+	w := tabwriter.NewWriter(s, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "backend\tpath\tlicense\tstatus\n")
+	failed := 0
+	checked := make(map[string]struct{})
+	for _, x := range outcomes {
+		checked[x.Backend] = struct{}{}
+		status := "ok"
+		if x.Err != nil {
+			status = fmt.Sprintf("FAIL: %s", x.Err)
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", x.Backend, x.Path, x.License, status)
+	}
+	for _, x := range lib.Backends { // the enabled backends with no cases
+		if _, exists := checked[x]; !exists && backends[x] {
+			fmt.Fprintf(w, "%s\t\t\t%s\n", x, "no cases")
+		}
+	}
+	w.Flush()
+	fmt.Print(s.String())
+
+	if failed > 0 {
+		return fmt.Errorf("self test failed %d of %d checks", failed, len(outcomes))
+	}
+	logf("self test passed %d checks", len(outcomes))
+	return nil
+}
@@ -0,0 +1,185 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awslabs/yesiscan"
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/ansi"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/web"
+
+	colour "github.com/fatih/color"
+	cli "github.com/urfave/cli/v2" // imports as package "cli"
+	"golang.org/x/term"
+)
+
+// Watch scans a local path, and then scans it again each time that something
+// in it changes. A live summary is printed to the terminal, and if a listen
+// address is set, the full report is also served as a web page which reloads
+// itself. The result cache is always enabled, so that only the changed files
+// are sent to the backends again.
+func Watch(c *cli.Context, program, version string, debug bool, messages io.Writer) error {
+	logf := (&ansi.Logf{
+		Prefix:   "main: ",
+		Ellipsis: "...",
+		Enable:   false,
+		Prefixes: []string{},
+	}).Init()
+	logf("Hello from purpleidea! This is %s, version: %s", program, version)
+	defer logf("Done!")
+
+	if c.NArg() != 1 {
+		return fmt.Errorf("specify exactly one local path to watch")
+	}
+	path, err := filepath.Abs(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return errwrap.Wrapf(err, "can't watch path")
+	}
+	if info.IsDir() {
+		path += "/" // the parser wants this for dirs
+	}
+
+	var backends map[string]bool // nil means all of them
+	if c.IsSet("backend") {
+		backends = make(map[string]bool)
+		for _, x := range c.StringSlice("backend") {
+			backends[x] = true
+		}
+	}
+	perms, err := interfaces.ParsePerms(c.String("permissions"))
+	if err != nil {
+		return err
+	}
+
+	y, err := yesiscan.New(&yesiscan.Options{
+		Program: program,
+		Version: version,
+		Debug:   debug,
+		Logf: func(format string, v ...interface{}) {
+			if debug {
+				logf("lib: "+format, v...)
+			}
+		},
+		Backends: backends,
+		Profiles: c.StringSlice("profile"),
+		Perms:    perms,
+		Cache:    true, // this is what makes the rescans incremental
+	})
+	if err != nil {
+		return err
+	}
+
+	interval := c.Duration("interval")
+	clear := term.IsTerminal(int(os.Stdout.Fd()))
+	useColor, err := ansi.UseColor(c.String("color"), int(os.Stdout.Fd()))
+	if err != nil {
+		return err
+	}
+	colour.NoColor = !useColor
+
+	mutex := &sync.Mutex{}
+	page := "scanning..." // the latest html report
+	if listen := c.String("listen"); listen != "" {
+		refresh := fmt.Sprintf("<head>\n<meta http-equiv=\"refresh\" content=\"%d\">", int(interval.Seconds())+1)
+		server := &http.Server{
+			Addr: listen,
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				fmt.Fprint(w, strings.Replace(page, "<head>", refresh, 1))
+			}),
+		}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logf("web: %+v", err)
+			}
+		}()
+		defer server.Close()
+		logf("serving the live report on: %s", listen)
+	}
+
+	watcher := &lib.Watcher{
+		Debug: debug,
+		Logf: func(format string, v ...interface{}) {
+			logf("watch: "+format, v...)
+		},
+		Path:     path,
+		Interval: interval,
+		Scan: func(ctx context.Context) (*lib.Output, error) {
+			return y.Scan(ctx, path)
+		},
+		Update: func(output *lib.Output, changed []string, err error) {
+			if clear {
+				fmt.Print("\033[H\033[2J") // move to the top and clear
+			}
+			fmt.Printf("%s: %s\n", time.Now().Format(time.Kitchen), path)
+			if err != nil {
+				fmt.Fprintf(messages, "scan failed: %+v\n", err)
+				return
+			}
+			style := "text"
+			if clear && useColor {
+				style = "ansi"
+			}
+			s, err := lib.ReturnWatchSummary(output, changed, style)
+			if err != nil {
+				fmt.Fprintf(messages, "summary failed: %+v\n", err)
+				return
+			}
+			fmt.Print(s)
+
+			h, err := web.ReturnOutputHtml(output)
+			if err != nil {
+				logf("html report failed: %+v", err)
+				return
+			}
+			mutex.Lock()
+			page = h
+			mutex.Unlock()
+		},
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return watcher.Run(ctx)
+}
@@ -26,21 +26,67 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/publish"
+	"github.com/awslabs/yesiscan/util"
 	"github.com/awslabs/yesiscan/util/ansi"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/safepath"
 	"github.com/awslabs/yesiscan/web"
 
 	cli "github.com/urfave/cli/v2" // imports as package "cli"
 )
 
+// NewReportStore builds the store that the servers keep their reports in. This
+// is the S3 bucket if there is one, or else the SQLite database if there is a
+// path to it. If neither is set, then this returns nil, and the server uses its
+// default store.
+func NewReportStore(debug bool, logf func(format string, v ...interface{}), perms *interfaces.Perms, region, bucket, prefix, sqlitePath string) (web.ReportStore, error) {
+	if bucket != "" && sqlitePath != "" {
+		return nil, fmt.Errorf("the reports can't be in both an s3 bucket and an sqlite database")
+	}
+	if bucket != "" {
+		return &web.S3ReportStore{
+			Debug: debug,
+			Logf:  logf,
+
+			Region: region,
+			Bucket: bucket,
+			Prefix: prefix,
+		}, nil
+	}
+	if sqlitePath == "" {
+		return nil, nil
+	}
+	p, err := filepath.Abs(sqlitePath)
+	if err != nil {
+		return nil, err
+	}
+	absFile, err := safepath.ParseIntoAbsFile(p)
+	if err != nil {
+		return nil, err
+	}
+	return &web.SQLiteReportStore{
+		Debug: debug,
+		Logf:  logf,
+
+		Path:  absFile,
+		Perms: perms,
+	}, nil
+}
+
 // Web is the general entry point for running this software as an http web
 // server.
 // TODO: replace the *cli.Context with a more general context that can be used
 // by all the different frontends.
-func Web(c *cli.Context, program, version string, debug bool) error {
+func Web(c *cli.Context, program, version string, debug bool, messages io.Writer) error {
 	logf := (&ansi.Logf{
 		Prefix:   "main: ",
 		Ellipsis: "...",
@@ -50,6 +96,56 @@ func Web(c *cli.Context, program, version string, debug bool) error {
 	logf("Hello from purpleidea! This is %s, version: %s", program, version)
 	defer logf("Done!")
 
+	perms, err := interfaces.ParsePerms(c.String("permissions"))
+	if err != nil {
+		return err
+	}
+
+	publishers := []publish.Publisher{}
+	if webhook := c.String("chat-webhook"); webhook != "" {
+		chatOptions := &publish.ChatOptions{
+			Webhook: webhook,
+			Kind:    c.String("chat-kind"),
+		}
+		if err := chatOptions.Validate(); err != nil {
+			return errwrap.Wrapf(err, "invalid chat options")
+		}
+		stateDir := "" // if we can't remember, then everything is new
+		if userCacheDir, err := os.UserCacheDir(); err == nil {
+			stateDir = filepath.Join(userCacheDir, program, publish.ChatStateDir)
+		}
+		publishers = append(publishers, &publish.Chat{
+			Debug: debug,
+			Logf: func(format string, v ...interface{}) {
+				fmt.Fprintf(messages, "publish: chat: "+strings.TrimRight(format, "\n")+"\n", v...)
+			},
+			Options:  chatOptions,
+			StateDir: stateDir,
+			Perms:    perms,
+		})
+	}
+	if server := c.String("email-server"); server != "" {
+		emailOptions := &publish.EmailOptions{
+			Server:   server,
+			Username: c.String("email-username"),
+			Password: c.String("email-password"),
+			From:     c.String("email-from"),
+			To:       c.StringSlice("email-to"),
+			Attach:   c.Bool("email-attach"),
+		}
+		if err := emailOptions.Validate(); err != nil {
+			return errwrap.Wrapf(err, "invalid email options")
+		}
+		publishers = append(publishers, &publish.Email{
+			Debug: debug,
+			Logf: func(format string, v ...interface{}) {
+				fmt.Fprintf(messages, "publish: email: "+strings.TrimRight(format, "\n")+"\n", v...)
+			},
+			Options: emailOptions,
+			Render:  web.ReturnOutputHtml,
+		})
+	}
+
 	server := &web.Server{
 		Program: program,
 		Version: version,
@@ -57,11 +153,132 @@ func Web(c *cli.Context, program, version string, debug bool) error {
 		Debug: debug,
 		Logf: func(format string, v ...interface{}) {
 			//logf(format, v...) // XXX: replaced for now b/c of gin logs
-			fmt.Printf(strings.TrimRight(format, "\n")+"\n", v...) // avoid prefixing for now
+			fmt.Fprintf(messages, strings.TrimRight(format, "\n")+"\n", v...) // avoid prefixing for now
 		},
 
-		Profiles: c.StringSlice("profile"),
-		Listen:   c.String("listen"),
+		Profiles:  c.StringSlice("profile"),
+		Listen:    c.String("listen"),
+		Workspace: c.Bool("workspace"),
+		Perms:     perms,
+
+		TLSCertFile: c.String("tls-cert"),
+		TLSKeyFile:  c.String("tls-key"),
+
+		AutocertHosts:        c.StringSlice("autocert-host"),
+		AutocertEmail:        c.String("autocert-email"),
+		AutocertDirectoryURL: c.String("autocert-directory-url"),
+		AutocertCache:        c.String("autocert-cache"),
+
+		URL:        c.String("public-url"),
+		Publishers: publishers,
+
+		MaxFiles:    c.Int64("max-files"),
+		MaxBytes:    c.Int64("max-size") * 1024 * 1024, // MiB to bytes
+		MaxDuration: c.Duration("max-time"),
+
+		MaxUploadBytes: c.Int64("max-upload-size") * 1024 * 1024, // MiB to bytes
+
+		MaxConcurrentScans: c.Int("max-concurrent-scans"),
+		MaxScansPerIP:      c.Int("max-scans-per-ip"),
+		MaxScansPerUser:    c.Int("max-scans-per-user"),
+		RateWindow:         c.Duration("rate-window"),
+		TrustedProxies:     c.StringSlice("trusted-proxy"),
+
+		RetentionAge:   c.Duration("retention-age"),
+		RetentionCount: c.Int("retention-count"),
+		RetentionBytes: c.Int64("retention-size") * 1024 * 1024, // MiB to bytes
+
+		CurationsPath: c.String("curations-path"),
+		Reviewers:     make(map[string]string),
+	}
+
+	for _, x := range c.StringSlice("display-name") {
+		split := strings.SplitN(x, "=", 2)
+		if len(split) != 2 || split[0] == "" {
+			return fmt.Errorf("invalid display name, expected prefix=name: %s", x)
+		}
+		if server.DisplayNames == nil {
+			server.DisplayNames = make(lib.DisplayNames)
+		}
+		server.DisplayNames[split[0]] = split[1]
+	}
+
+	smartURIs := []*util.SmartURITemplate{}
+	for _, x := range c.StringSlice("smart-uri") {
+		split := strings.SplitN(x, "=", 2)
+		if len(split) != 2 {
+			return fmt.Errorf("invalid smart uri, expected match=template: %s", x)
+		}
+		smartURIs = append(smartURIs, &util.SmartURITemplate{
+			Match:    split[0],
+			Template: split[1],
+		})
+	}
+	smartURIFuncs, err := util.NewSmartURIFuncs(smartURIs) // in order
+	if err != nil {
+		return err
+	}
+	server.SmartURIs = smartURIFuncs
+
+	reports, err := NewReportStore(debug, server.Logf, perms, c.String("region"), c.String("report-s3bucket"), c.String("report-s3prefix"), c.String("report-sqlite"))
+	if err != nil {
+		return err
+	}
+	server.Reports = reports // nil for the default
+
+	cacheRemote, cacheSigningKey, err := NewCacheRemote(debug, server.Logf, c.String("region"), c.String("cache-s3bucket"), c.String("cache-s3prefix"), c.String("cache-signing-key-path"))
+	if err != nil {
+		return err
+	}
+	server.CacheRemote = cacheRemote
+	server.CacheSigningKey = cacheSigningKey
+
+	tokens := make(map[string]string)
+	for _, x := range c.StringSlice("auth-token") {
+		i := strings.Index(x, ":")
+		if i <= 0 || i == len(x)-1 {
+			return fmt.Errorf("invalid auth token, expected name:token")
+		}
+		tokens[x[i+1:]] = x[:i]
+	}
+	if issuer := c.String("oidc-issuer"); issuer != "" {
+		if len(tokens) > 0 {
+			return fmt.Errorf("can't use both token and oidc auth")
+		}
+		if server.URL == "" {
+			return fmt.Errorf("oidc auth needs the public url to redirect back to")
+		}
+		server.Auth = &web.OIDCAuth{
+			Debug: debug,
+			Logf:  server.Logf,
+
+			Issuer:       issuer,
+			ClientID:     c.String("oidc-client-id"),
+			ClientSecret: c.String("oidc-client-secret"),
+			RedirectURL:  strings.TrimRight(server.URL, "/") + web.AuthPrefix + "callback",
+			Claim:        c.String("oidc-claim"),
+		}
+	} else if len(tokens) > 0 {
+		server.Auth = &web.TokenAuth{
+			Tokens: tokens,
+		}
+	}
+	server.Admins = c.StringSlice("admin")
+	server.PublicBadges = c.Bool("public-badges")
+
+	for _, x := range c.StringSlice("reviewer") {
+		i := strings.Index(x, ":")
+		if server.Auth != nil && i < 0 { // they log in with the auth
+			server.Reviewers[x] = ""
+			continue
+		}
+		if i <= 0 || i == len(x)-1 {
+			return fmt.Errorf("invalid reviewer, expected name:password")
+		}
+		server.Reviewers[x[:i]] = x[i+1:]
+	}
+	if server.MaxFiles < 0 || server.MaxBytes < 0 || server.MaxDuration < 0 {
+		return fmt.Errorf("the scan limits must not be negative")
 	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
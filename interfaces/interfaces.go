@@ -64,9 +64,6 @@ const (
 	// distinct condition from identifying a license but with a extremely
 	// low confidence.
 	ErrUnknownLicense = Error("license is unknown")
-
-	// Umask is the value used whenever we need to make a directory.
-	Umask = 0770 // TODO: what should this be?
 )
 
 // Parser is the interface that every parser must implement. You populate the
@@ -134,6 +131,21 @@ type Info struct {
 	// modified with the GenUID function to return something more useful, as
 	// a human readable UID is more valuable than an internal path.
 	UID string
+
+	// FS is the filesystem that the path belongs to. If it is nil, then the
+	// path is on the local disk. Otherwise, the path is rooted at the top of
+	// this filesystem and does not exist locally, so only a DataBackend can
+	// scan it.
+	FS fs.FS
+
+	// Root is true if this path is the top of the tree that the iterator
+	// walks. This is where a RootBackend runs.
+	Root bool
+
+	// Symlink is the UID of the file that this path is a symlink to, if it
+	// is one. The FileInfo is of that file, and reading the path reads it.
+	// It is empty for regular files.
+	Symlink string
 }
 
 // Backend is the common interface for backends. Any useful backend must also
@@ -158,6 +170,30 @@ type SetupBackend interface {
 	Setup(ctx context.Context) error
 }
 
+// VersionBackend adds a method that returns the version of the tool or of the
+// license database that the backend uses. It is part of the key for any cached
+// results, so that an upgrade never serves an outdated determination. It is only
+// called after Setup, so the version can be found there.
+type VersionBackend interface {
+	Backend
+
+	// Version returns a string which changes whenever the results of this
+	// backend might change. It does not need to be human readable.
+	Version() string
+}
+
+// CacheableBackend adds a method that says whether the results of the backend
+// may be cached. They are cached by the name and the contents of each file, so
+// a backend whose results also depend on where the file is, or on the files
+// around it, must not be. The backends which don't implement this are cached.
+type CacheableBackend interface {
+	Backend
+
+	// Cacheable returns false if the results of this backend must never
+	// be cached.
+	Cacheable() bool
+}
+
 // DataBackend is the extended backend that is most efficient for receiving data
 // since all the reads are done once, and each backend only has to read from one
 // memory address. You should implement this backend if you can. It assumes that
@@ -174,10 +210,11 @@ type DataBackend interface {
 	// makes a whole repository or directory determination. In this case,
 	// the iterator stores this rejection so that we don't wastefully call
 	// the backend again with a child path. The backend will likely want to
-	// also return a result with the SkipDir. It must not return SkipDir in
-	// response to a non directory path. This must be able to handle
-	// receiving an empty byte array, which can happen if a directory path
-	// is presented. Since a byte array is effectively a pointer to the set
+	// also return a result with the SkipDir. If it does, that result will
+	// be copied to each child path, and marked as inherited. It must not
+	// return SkipDir in response to a non directory path. This must be able
+	// to handle receiving an empty byte array, which can happen if a
+	// directory path is presented. Since a byte array is effectively a pointer to the set
 	// of data that each backend will share the same view of, you must *not*
 	// edit this data in any way, since this would change the view of it for
 	// every backend, and unexpected things might happen.
@@ -200,18 +237,28 @@ type PathBackend interface {
 	// repository or directory determination. In this case, the iterator
 	// stores this rejection so that we don't wastefully call the backend
 	// again with a child path. The backend will likely want to also return
-	// a result with the SkipDir. It must not return SkipDir in response to
-	// a non directory path.
+	// a result with the SkipDir. If it does, that result will be copied to
+	// each child path, and marked as inherited. It must not return SkipDir
+	// in response to a non directory path.
 	// TODO: this API might change.
 	ScanPath(ctx context.Context, path safepath.Path, info *Info) (*Result, error)
 }
 
-// TODO: implement a new iterator that simply passes the root dir off to a
-// special backend that does its own iteration. This needs a new extended
-// backend interface as well. (RootBackend)
+// RootBackend is an extended backend for tools which do their own iteration,
+// and which are much faster when they run once over a whole directory than when
+// they run once for each file. It gets the root of each iterator, and each path
+// underneath it then looks up its own result in what it returned. If it is also
+// a PathBackend, then that is used for paths which aren't under a scanned root.
 type RootBackend interface {
 	Backend
 
+	// ScanRoot takes the root path of an iterator, which is usually a
+	// directory, and returns the results for everything underneath it,
+	// keyed by the absolute path of each file. Any path which it doesn't
+	// return a result for is considered to have no determination. If it
+	// returns a nil ResultSet, then it declined to scan this root, and each
+	// path is sent to ScanPath instead. This is never called for paths
+	// which are in an FS.
 	// TODO: this API might change.
 	ScanRoot(ctx context.Context, path safepath.Path, info *Info) (ResultSet, error)
 }
@@ -258,6 +305,28 @@ type Result struct {
 	// level deep. (IOW, these results must not contain child results.)
 	// TODO: is it okay to support storing multiple results?
 	More []*Result
+
+	// Raw is the original output of the tool that made this determination,
+	// compressed with gzip. It's only set by the backends which run an
+	// external tool, and only when they're asked to keep it, since it is
+	// large. It lets a determination be reviewed without scanning again.
+	Raw []byte
+
+	// Choices is the license expression that the Licenses came from, in
+	// conjunctive normal form, if it offered a choice such as "MIT OR
+	// GPL-2.0-only". Each entry is a list of licenses of which any one can
+	// be used, and all of the entries apply. It's nil when there's nothing
+	// to choose, and then all of the Licenses apply. The Licenses always
+	// contain every license that is in here, and the ones which aren't in
+	// here always apply, so this is safe to ignore.
+	Choices [][]*licenses.License
+
+	// Diff shows how the text that was found differs from the canonical
+	// text of the license, for a match that was close but not exact. It
+	// is a word diff as returned by licenses.WordDiff, and it is empty for
+	// the other results. It lets a reviewer see at a glance whether the
+	// difference matters.
+	Diff string
 }
 
 // Cmp compares two results and returns nil if they are the same. We don't
@@ -331,6 +400,21 @@ type Meta struct {
 	// this field is redundant, but it is here for consistency with the idea
 	// of storing the Result's associated metadata alongside it.
 	Backend Backend
+
+	// Inherited is the UID of the path that this result was copied from if
+	// it was not directly produced by the backend for this UID. It is empty
+	// for regular results.
+	Inherited string
+
+	// Inferred is true if this result was not determined by a backend, but
+	// was instead guessed by an inference pass such as the one that looks
+	// at the nearest enclosing LICENSE file.
+	Inferred bool
+
+	// Symlink is the UID of the file that the path of this result is a
+	// symlink to, if it was one. The result is of the content of that file.
+	// It is empty for regular files.
+	Symlink string
 }
 
 // ResultSet is the organized set of results that is produced after running a
@@ -0,0 +1,109 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package interfaces
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+const (
+	// PermsPrivate is the name of the policy where only the current user
+	// can read anything that we write. This is the default.
+	PermsPrivate = "private"
+
+	// PermsGroup is the name of the policy where the group of the current
+	// user can also read and write anything that we write.
+	PermsGroup = "group"
+
+	// PermsShared is the name of the policy where anyone can read anything
+	// that we write, but only the current user can change it.
+	PermsShared = "shared"
+
+	// DefaultPerms is the name of the policy that is used if none is
+	// specified.
+	DefaultPerms = PermsPrivate
+)
+
+// Perms is the file permission policy that is used for everything that we
+// write to disk. This includes cache files, extracted archives, reports, and
+// config files. A nil *Perms or a zero field uses the default policy. The umask
+// of the process still applies on top of these values.
+type Perms struct {
+	// Dir is the mode used when making a directory.
+	Dir fs.FileMode
+
+	// File is the mode used when writing a regular file.
+	File fs.FileMode
+
+	// Exec is the mode used when writing a binary that we will run.
+	Exec fs.FileMode
+}
+
+// ParsePerms returns the permission policy of that name. An empty name returns
+// the default policy.
+func ParsePerms(name string) (*Perms, error) {
+	switch name {
+	case PermsPrivate, "":
+		return &Perms{Dir: 0700, File: 0600, Exec: 0700}, nil
+	case PermsGroup:
+		return &Perms{Dir: 0770, File: 0660, Exec: 0770}, nil
+	case PermsShared:
+		return &Perms{Dir: 0755, File: 0644, Exec: 0755}, nil
+	}
+	return nil, fmt.Errorf("unknown permission policy: %s", name)
+}
+
+// defaultPerms returns the default policy.
+func defaultPerms() *Perms {
+	perms, err := ParsePerms(DefaultPerms)
+	if err != nil {
+		panic(err) // programming error
+	}
+	return perms
+}
+
+// DirMode returns the mode to use when making a directory.
+func (obj *Perms) DirMode() fs.FileMode {
+	if obj == nil || obj.Dir == 0 {
+		return defaultPerms().Dir
+	}
+	return obj.Dir
+}
+
+// FileMode returns the mode to use when writing a regular file.
+func (obj *Perms) FileMode() fs.FileMode {
+	if obj == nil || obj.File == 0 {
+		return defaultPerms().File
+	}
+	return obj.File
+}
+
+// ExecMode returns the mode to use when writing a binary that we will run.
+func (obj *Perms) ExecMode() fs.FileMode {
+	if obj == nil || obj.Exec == 0 {
+		return defaultPerms().Exec
+	}
+	return obj.Exec
+}
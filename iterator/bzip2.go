@@ -26,14 +26,11 @@ package iterator
 import (
 	"compress/bzip2"
 	"context"
-	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/awslabs/yesiscan/interfaces"
 	"github.com/awslabs/yesiscan/util/errwrap"
@@ -62,6 +59,26 @@ var (
 func init() {
 	bzip2MapMutex = &sync.Mutex{}
 	bzip2Mutexes = make(map[string]*sync.Mutex)
+
+	Register(&Registration{
+		Name:       "bzip2",
+		Extensions: Bzip2Extensions,
+		File: func(settings *Settings, path safepath.AbsFile) (interfaces.Iterator, error) {
+			return &Bzip2{
+				Debug:   settings.Debug,
+				Logf:    settings.Logf,
+				Prefix:  settings.Prefix,
+				Perms:   settings.Perms,
+				Limiter: settings.Limiter,
+				Offline: settings.Offline,
+
+				Parser:   settings.Parser,
+				Iterator: settings.Iterator,
+
+				Path: path,
+			}, nil
+		},
+	})
 }
 
 // Bzip2 is an iterator that takes a .bz or similar URI to open and performs the
@@ -75,6 +92,18 @@ type Bzip2 struct {
 	Logf   func(format string, v ...interface{})
 	Prefix safepath.AbsDir
 
+	// Perms is the permission policy for everything that we write. If it
+	// is nil, then the default policy is used.
+	Perms *interfaces.Perms
+
+	// Limiter throttles the downloads. It is shared with every iterator
+	// that we return. If it is nil, then nothing is throttled.
+	Limiter *Limiter
+
+	// Offline forbids any network access. It is passed down to every
+	// iterator that we return.
+	Offline bool
+
 	// Parser is a pointer to the parser that returned this. If it wasn't
 	// returned by a parser, leave this nil. If this iterator came from an
 	// iterator, then the Iterator handle should be filled instead.
@@ -175,20 +204,16 @@ func (obj *Bzip2) GetIterator() interfaces.Iterator { return obj.Iterator }
 func (obj *Bzip2) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interfaces.Iterator, error) {
 	relDir := safepath.UnsafeParseIntoRelDir("bzip2/")
 	prefix := safepath.JoinToAbsDir(obj.Prefix, relDir)
-	if err := os.MkdirAll(prefix.Path(), interfaces.Umask); err != nil {
+	if err := os.MkdirAll(prefix.Path(), obj.Perms.DirMode()); err != nil {
 		return nil, err
 	}
 
-	// make a unique ID for the directory
-	// XXX: we can consider different algorithms or methods here later...
-	now := strconv.FormatInt(time.Now().UnixMilli(), 10) // itoa but int64
-	sum := sha256.Sum256([]byte(obj.Path.Path() + now))
-	hashRelDir, err := safepath.ParseIntoRelDir(fmt.Sprintf("%x", sum))
+	// The directory is named after the archive, so that an unchanged one
+	// only gets extracted once, and is then reused by any later scans.
+	bzip2AbsDir, err := ExtractionDir(prefix, obj.Path)
 	if err != nil {
 		return nil, err
 	}
-	// ensure it gets put into a folder so it doesn't explode current dir
-	bzip2AbsDir := safepath.JoinToAbsDir(prefix, hashRelDir)
 
 	bzip2MapMutex.Lock()
 	mu, exists := bzip2Mutexes[obj.Path.Path()]
@@ -215,8 +240,33 @@ func (obj *Bzip2) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inte
 
 	// XXX: unlock when context closes?
 
-	// XXX: If the destination dir has contents, consider removing them
-	// first. This is one reason why we have a mutex.
+	// A previous run might have been interrupted part of the way through,
+	// so we only reuse the directory if it was marked as complete. This is
+	// one reason why we have a mutex.
+	complete, err := IsComplete(bzip2AbsDir)
+	if err != nil {
+		obj.unlock()
+		return nil, errwrap.Wrapf(err, "error checking %s", bzip2AbsDir)
+	}
+	if complete {
+		obj.Logf("already extracted %s into %s", obj.String(), bzip2AbsDir)
+		obj.iterators = []interfaces.Iterator{obj.fsIterator(bzip2AbsDir)}
+		return obj.iterators, nil
+	}
+
+	// Another process might be extracting the same archive, so we each
+	// extract into a directory of our own, and then move it into place.
+	stagingAbsDir, err := newStaging(bzip2AbsDir, obj.Perms)
+	if err != nil {
+		obj.unlock()
+		return nil, errwrap.Wrapf(err, "error preparing %s", bzip2AbsDir)
+	}
+	finished := false
+	defer func() {
+		if !finished {
+			os.RemoveAll(stagingAbsDir.Path()) // whatever we got done
+		}
+	}()
 
 	// Open the bzip2 file for reading.
 	// FIXME: use a variant that can take a context
@@ -230,8 +280,12 @@ func (obj *Bzip2) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inte
 	z := bzip2.NewReader(f)
 
 	bytesTotal := int64(0)
-	// Iterate through the files in the archive.
-	// TODO: add a recurring progress logf if it takes longer than 30 sec
+	progress := &Progress{
+		Logf: obj.Logf,
+		Name: fmt.Sprintf("decompressing %s", obj.Path),
+	}
+	progress.Start()
+	defer progress.Stop()
 
 	// TODO: obj.Debug ?
 
@@ -261,22 +315,22 @@ func (obj *Bzip2) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inte
 	}
 
 	// this is where the output file will be stored
-	absFile := safepath.JoinToAbsFile(bzip2AbsDir, relFile)
+	absFile := safepath.JoinToAbsFile(stagingAbsDir, relFile)
 
 	// XXX: sanity check (is output in the dir?)
 	// TODO: we could add this, but safepath automatically does this
-	// if absFile is not inside of bzip2AbsDir then error
+	// if absFile is not inside of stagingAbsDir then error
 
 	absDir := absFile.Dir() // get the absDir that absFile is in
 
-	if err := os.MkdirAll(absDir.Path(), os.ModePerm); err != nil {
+	if err := os.MkdirAll(absDir.Path(), obj.Perms.DirMode()); err != nil {
 		// programming error
 		obj.unlock()
 		return nil, err
 	}
 
 	// write to this location
-	dest, err := os.OpenFile(absFile.Path(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+	dest, err := os.OpenFile(absFile.Path(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, obj.Perms.FileMode())
 	if err != nil {
 		obj.unlock()
 		return nil, errwrap.Wrapf(err, "error writing our file to disk at %s", absFile)
@@ -284,7 +338,7 @@ func (obj *Bzip2) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inte
 	// don't `defer` close here because we want to free in the loop
 
 	// FIXME: use a variant that can take a context
-	size, err := io.Copy(dest, z)
+	size, err := io.Copy(dest, io.TeeReader(z, progress))
 	if e, ok := err.(bzip2.StructuralError); ok {
 		dest.Close() // close dest file on error!
 		obj.unlock()
@@ -307,26 +361,38 @@ func (obj *Bzip2) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inte
 	// TODO: change to human readable bytes
 	obj.Logf("uncompressed from %s into %s (%d bytes)", obj.String(), bzip2AbsDir, bytesTotal)
 
-	obj.iterators = []interfaces.Iterator{}
+	if bzip2AbsDir, err = finishStaging(stagingAbsDir, bzip2AbsDir, obj.Perms, obj.Logf); err != nil {
+		obj.unlock()
+		return nil, errwrap.Wrapf(err, "error moving %s into place", stagingAbsDir)
+	}
+	finished = true
+
+	obj.iterators = []interfaces.Iterator{obj.fsIterator(bzip2AbsDir)}
 
+	return obj.iterators, nil
+}
+
+// fsIterator returns the iterator which scans the directory that we extracted
+// into.
+func (obj *Bzip2) fsIterator(dir safepath.AbsDir) interfaces.Iterator {
 	// if it's a single bzip2 file we return an fs iterator and let the fs
 	// iterator sort that out...
-	iterator := &Fs{
+	return &Fs{
 		Debug: obj.Debug,
 		Logf: func(format string, v ...interface{}) {
 			obj.Logf(format, v...) // TODO: add a prefix?
 		},
-		Prefix: obj.Prefix,
+		Prefix:  obj.Prefix,
+		Perms:   obj.Perms,
+		Limiter: obj.Limiter,
+		Offline: obj.Offline,
 
 		Iterator: obj,
 
-		Path: bzip2AbsDir,
+		Path: dir,
 
 		//Unlock: unlock,
 	}
-	obj.iterators = append(obj.iterators, iterator)
-
-	return obj.iterators, nil
 }
 
 // Close shuts down the iterator and/or performs clean up after the Recurse
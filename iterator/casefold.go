@@ -0,0 +1,193 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package iterator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+// IsCaseInsensitive returns true if the filesystem that this directory is on
+// doesn't tell names apart by case, which is the default on macOS and Windows.
+// It finds out by creating a small temporary file in there.
+func IsCaseInsensitive(dir string) (bool, error) {
+	f, err := os.CreateTemp(dir, ".case-probe-")
+	if err != nil {
+		return false, err
+	}
+	name := f.Name()
+	f.Close()
+	defer os.Remove(name)
+
+	upper := filepath.Join(filepath.Dir(name), strings.ToUpper(filepath.Base(name)))
+	if _, err := os.Stat(upper); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// newCaseNames returns a CaseNames to disambiguate the names in an archive that
+// gets extracted into this directory, or nil if the filesystem that it's on can
+// tell them apart already.
+func newCaseNames(dir safepath.AbsDir, logf func(format string, v ...interface{})) (*CaseNames, error) {
+	caseInsensitive, err := IsCaseInsensitive(dir.Path())
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "error checking the filesystem in %s", dir)
+	}
+	if !caseInsensitive {
+		return nil, nil
+	}
+	logf("the filesystem in %s is case-insensitive", dir)
+	return &CaseNames{}, nil
+}
+
+// foldCase returns the form of a name that is compared on a case-insensitive
+// filesystem. This doesn't know about unicode normalization, which some of them
+// also ignore.
+func foldCase(s string) string {
+	return strings.ToLower(s)
+}
+
+// CaseNames hands out the names of the entries in an archive when it gets
+// extracted onto a case-insensitive filesystem. Two entries whose names only
+// differ by case would otherwise be the same file on disk, and the second one
+// would silently overwrite the first before it was ever scanned, so the second
+// gets a new name instead. Two directories like that are simply merged, since
+// nothing gets lost. The zero value is ready to use.
+type CaseNames struct {
+	// names maps each folded name that was used to whether it's a dir.
+	names map[string]bool
+
+	// exact is the set of the exact names that were handed out.
+	exact map[string]bool
+
+	// renamed maps each folded dir that had to be renamed to its new name,
+	// so that everything inside of it follows it.
+	renamed map[string]string
+}
+
+// Claim returns the name to extract this entry to, which is the same one that
+// it has, unless it collides with an earlier one. The name must be relative,
+// and a trailing slash marks a directory. The second return value is true if
+// the entry was renamed. A nil CaseNames never renames anything.
+func (obj *CaseNames) Claim(name string) (string, bool) {
+	if obj == nil {
+		return name, false
+	}
+	if obj.names == nil {
+		obj.names = make(map[string]bool)
+		obj.exact = make(map[string]bool)
+		obj.renamed = make(map[string]string)
+	}
+	isDir := strings.HasSuffix(name, "/")
+	parts := strings.Split(strings.TrimSuffix(name, "/"), "/")
+
+	// The parents are dirs, even if the archive never listed them.
+	out := ""
+	for _, x := range parts[:len(parts)-1] {
+		out = obj.claim(out+x, true) + "/"
+	}
+
+	last := obj.claim(out+parts[len(parts)-1], isDir)
+	if isDir {
+		last += "/"
+	}
+	return last, last != name
+}
+
+// claim returns the name to use for this path, whose parents were claimed.
+func (obj *CaseNames) claim(p string, isDir bool) string {
+	folded := foldCase(p)
+	if r, exists := obj.renamed[folded]; exists && isDir {
+		return r
+	}
+	wasDir, exists := obj.names[folded]
+	if !exists {
+		obj.names[folded] = isDir
+		obj.exact[p] = true
+		return p
+	}
+	if isDir && wasDir {
+		return p // merge them
+	}
+	if !isDir && !wasDir && obj.exact[p] {
+		return p // the archive has the same name twice, so it overwrites
+	}
+
+	dir, base := "", p
+	if i := strings.LastIndex(p, "/"); i != -1 {
+		dir, base = p[:i+1], p[i+1:]
+	}
+	ext := ""
+	if !isDir {
+		ext = filepath.Ext(base)
+		if ext == base { // a dotfile
+			ext = ""
+		}
+	}
+	stem := strings.TrimSuffix(base, ext)
+	for i := 2; ; i++ {
+		n := fmt.Sprintf("%s%s~%d%s", dir, stem, i, ext)
+		if _, exists := obj.names[foldCase(n)]; exists {
+			continue
+		}
+		obj.names[foldCase(n)] = isDir
+		obj.exact[n] = true
+		if isDir {
+			obj.renamed[folded] = n
+		}
+		return n
+	}
+}
+
+// CaseCollisions returns each group of paths in this list which would be the
+// same path on a case-insensitive filesystem. Each group is sorted, and so is
+// the list of groups.
+func CaseCollisions(paths []string) [][]string {
+	groups := make(map[string][]string)
+	for _, x := range paths {
+		folded := foldCase(x)
+		groups[folded] = append(groups[folded], x)
+	}
+	result := [][]string{}
+	for _, g := range groups {
+		if len(g) < 2 {
+			continue
+		}
+		sort.Strings(g)
+		result = append(result, g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i][0] < result[j][0]
+	})
+	return result
+}
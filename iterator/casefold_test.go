@@ -0,0 +1,93 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package iterator_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/awslabs/yesiscan/iterator"
+)
+
+func TestCaseNames(t *testing.T) {
+	tests := []struct {
+		name string
+		exp  string
+	}{
+		{"README.md", "README.md"},
+		{"readme.md", "readme~2.md"},
+		{"Readme.md", "Readme~3.md"},
+		{"README.md", "README.md"}, // the same name twice is not renamed
+		{"src/", "src/"},
+		{"SRC/", "SRC/"}, // dirs are merged
+		{"SRC/main.go", "SRC/main.go"},
+		{"src/MAIN.go", "src/MAIN~2.go"},
+		{".env", ".env"},
+		{".ENV", ".ENV~2"},
+		{"lib", "lib"},
+		{"LIB/a.txt", "LIB~2/a.txt"}, // a dir that collides with a file
+		{"lib/b.txt", "LIB~2/b.txt"}, // and its contents follow it
+	}
+	caseNames := &iterator.CaseNames{}
+	for i, x := range tests {
+		out, renamed := caseNames.Claim(x.name)
+		if out != x.exp {
+			t.Errorf("test %d: %s: expected %s, got %s", i, x.name, x.exp, out)
+		}
+		if renamed != (out != x.name) {
+			t.Errorf("test %d: %s: unexpected renamed: %t", i, x.name, renamed)
+		}
+	}
+
+	var none *iterator.CaseNames // a nil one never renames
+	if out, renamed := none.Claim("readme.md"); out != "readme.md" || renamed {
+		t.Errorf("unexpected rename: %s", out)
+	}
+}
+
+func TestCaseCollisions(t *testing.T) {
+	paths := []string{
+		"README.md",
+		"docs/a.txt",
+		"readme.md",
+		"Docs/A.txt",
+		"main.go",
+	}
+	exp := [][]string{
+		{"Docs/A.txt", "docs/a.txt"},
+		{"README.md", "readme.md"},
+	}
+	if out := iterator.CaseCollisions(paths); !reflect.DeepEqual(out, exp) {
+		t.Errorf("expected %v, got %v", exp, out)
+	}
+}
+
+func TestIsCaseInsensitive(t *testing.T) {
+	caseInsensitive, err := iterator.IsCaseInsensitive(t.TempDir())
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	t.Logf("case-insensitive: %t", caseInsensitive)
+}
@@ -0,0 +1,272 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package iterator
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+const (
+	// CompleteSuffix is appended to the name of an extraction directory to
+	// get the name of the sentinel file which marks it as complete. It is
+	// kept next to the directory instead of inside of it, so that it never
+	// gets scanned, and so that it never shows up in a git worktree.
+	CompleteSuffix = ".complete"
+
+	// StagingSuffix is the suffix of the hidden directory next to an
+	// extraction directory that a single process extracts into, before it
+	// gets moved into place.
+	StagingSuffix = ".tmp"
+
+	// ArchiveNameMax is the longest that a single component of a path that
+	// gets extracted from an archive can be, in bytes. This is the limit
+	// of most filesystems, and escaping can make a name longer than it was.
+	ArchiveNameMax = 255
+)
+
+// ExtractionDir returns the directory under the prefix that this archive gets
+// extracted into. The name is built from the path, the size and the mtime of
+// the archive, so that an unchanged archive always maps to the same directory,
+// without having to read the whole thing first.
+func ExtractionDir(prefix safepath.AbsDir, path safepath.AbsFile) (safepath.AbsDir, error) {
+	info, err := os.Stat(path.Path())
+	if err != nil {
+		return safepath.AbsDir{}, err
+	}
+	uniqueString := path.Path() + separator + strconv.FormatInt(info.Size(), 10) + separator + strconv.FormatInt(info.ModTime().UnixNano(), 10)
+	sum := sha256.Sum256([]byte(uniqueString))
+	hashRelDir, err := safepath.ParseIntoRelDir(fmt.Sprintf("%x", sum))
+	if err != nil {
+		return safepath.AbsDir{}, err
+	}
+	return safepath.JoinToAbsDir(prefix, hashRelDir), nil
+}
+
+// completePath returns the path of the sentinel file for this directory.
+func completePath(dir safepath.AbsDir) string {
+	return strings.TrimSuffix(dir.Path(), "/") + CompleteSuffix
+}
+
+// IsComplete returns true if this extraction directory exists and was marked
+// as complete. A directory without the sentinel was left behind by a run that
+// was interrupted part of the way through, and its contents can't be trusted.
+func IsComplete(dir safepath.AbsDir) (bool, error) {
+	for _, x := range []string{completePath(dir), dir.Path()} {
+		if _, err := os.Stat(x); os.IsNotExist(err) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// MarkComplete writes the sentinel file for this extraction directory. This
+// must only be called once everything was written into it successfully.
+func MarkComplete(dir safepath.AbsDir, perms *interfaces.Perms) error {
+	return os.WriteFile(completePath(dir), []byte{}, perms.FileMode())
+}
+
+// newStaging makes a new and empty directory next to this extraction directory
+// for us to extract into. Nobody else ever writes into it, so more than one
+// process can share the same prefix, and each extraction directory only ever
+// shows up once it's whole. Move it into place with finishStaging, and remove it
+// if that never happens.
+func newStaging(dir safepath.AbsDir, perms *interfaces.Perms) (safepath.AbsDir, error) {
+	parent, name := filepath.Split(dir.Path())
+	p, err := os.MkdirTemp(parent, "."+name+".*"+StagingSuffix)
+	if err != nil {
+		return safepath.AbsDir{}, err
+	}
+	if err := os.Chmod(p, perms.DirMode()); err != nil {
+		os.Remove(p)
+		return safepath.AbsDir{}, err
+	}
+	return safepath.ParseIntoAbsDir(p + "/")
+}
+
+// finishStaging moves the staging directory into place as this extraction
+// directory, and marks it as complete. It returns the directory that should be
+// used from now on. If another process got there first, then ours is removed
+// and theirs is used. If there's an incomplete directory in the way, then we
+// don't know if something is still writing into it, so it's left alone, and the
+// staging directory is used as-is for this run.
+func finishStaging(staging, dir safepath.AbsDir, perms *interfaces.Perms, logf func(format string, v ...interface{})) (safepath.AbsDir, error) {
+	err := os.Rename(staging.Path(), dir.Path())
+	if err == nil {
+		return dir, MarkComplete(dir, perms)
+	}
+	if _, e := os.Stat(dir.Path()); e != nil {
+		return safepath.AbsDir{}, err // nothing was in the way
+	}
+	if complete, e := IsComplete(dir); e != nil {
+		return safepath.AbsDir{}, e
+	} else if complete {
+		logf("another run already extracted into %s", dir)
+		return dir, os.RemoveAll(staging.Path())
+	}
+	logf("leaving the incomplete extraction in %s alone, and using %s", dir, staging)
+	return staging, nil
+}
+
+// ExtractMode returns the permission bits to use for a file or directory that
+// was extracted from an archive with this mode. Only the permission bits are
+// kept, which drops the setuid, setgid, and sticky bits, and they can never
+// allow more than the permission policy does. The owner can always read and
+// write everything, and enter every directory, so that we are able to scan and
+// then remove whatever we extracted.
+func ExtractMode(mode fs.FileMode, isDir bool, perms *interfaces.Perms) fs.FileMode {
+	if isDir {
+		return mode.Perm()&perms.DirMode() | 0700
+	}
+	return mode.Perm()&perms.ExecMode() | 0600
+}
+
+// extracted is a file or directory that was extracted from an archive, along
+// with the metadata from the archive that we want to keep.
+type extracted struct {
+	path  string
+	mode  fs.FileMode
+	mtime time.Time
+}
+
+// finish sets the mode and the modification time. A zero time is left alone,
+// since that means the archive didn't store one. Directories must be finished
+// after everything inside of them was written, because that changes the mtime.
+func (obj *extracted) finish() error {
+	if err := os.Chmod(obj.path, obj.mode); err != nil {
+		return err
+	}
+	if obj.mtime.IsZero() {
+		return nil
+	}
+	return os.Chtimes(obj.path, obj.mtime, obj.mtime)
+}
+
+// SanitizeArchiveName turns the name of an entry in an archive into a relative
+// path that is safe to create on this platform. Archives which were made on
+// Windows or in an East Asian locale often have names that aren't UTF-8, so
+// rather than failing, we change backslashes into slashes, remove any leading
+// slashes or drive letters, and percent escape the bytes which are not valid
+// UTF-8, control characters, and anything else this platform can't store in a
+// name. Components that are still too long are cut short and get a hash of the
+// whole thing, so that the same entry always gets the same name. A trailing
+// slash is kept, since that's how directories are named. This errors if there
+// is nothing left of the name, or if it would escape the extraction directory,
+// and the caller should then warn and skip that entry.
+func SanitizeArchiveName(name string) (string, error) {
+	s := strings.ReplaceAll(name, `\`, "/")
+	isDir := strings.HasSuffix(s, "/")
+	if len(s) >= 2 && s[1] == ':' && (s[0] >= 'a' && s[0] <= 'z' || s[0] >= 'A' && s[0] <= 'Z') {
+		s = s[2:] // C:/foo
+	}
+
+	parts := []string{}
+	for _, x := range strings.Split(s, "/") {
+		switch x {
+		case "", ".":
+			continue
+		case "..":
+			if len(parts) == 0 {
+				return "", fmt.Errorf("name escapes the archive")
+			}
+			parts = parts[:len(parts)-1]
+			continue
+		}
+		parts = append(parts, sanitizeArchiveComponent(x))
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("name is empty")
+	}
+
+	out := strings.Join(parts, "/")
+	if isDir {
+		out += "/"
+	}
+	return out, nil
+}
+
+// sanitizeArchiveComponent escapes a single component of a name in an archive.
+// The percent sign itself is not escaped, so that ordinary names are unchanged.
+func sanitizeArchiveComponent(s string) string {
+	windows := runtime.GOOS == "windows"
+	b := &strings.Builder{}
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		last := i+size == len(s)
+		if r == utf8.RuneError && size == 1 || unicode.IsControl(r) || windows && (strings.ContainsRune(`<>:"|?*`, r) || last && (r == '.' || r == ' ')) {
+			for _, c := range []byte(s[i : i+size]) {
+				fmt.Fprintf(b, "%%%02X", c)
+			}
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	out := b.String()
+
+	if windows && isWindowsReserved(out) {
+		out = "_" + out
+	}
+
+	if len(out) > ArchiveNameMax {
+		suffix := fmt.Sprintf("~%x", sha256.Sum256([]byte(out)))[:9]
+		cut := ArchiveNameMax - len(suffix)
+		for cut > 0 && !utf8.RuneStart(out[cut]) { // don't split a rune
+			cut--
+		}
+		out = out[:cut] + suffix
+	}
+	return out
+}
+
+// isWindowsReserved returns true if this is one of the device names that can't
+// be used as a filename on Windows, with or without an extension.
+func isWindowsReserved(s string) bool {
+	base := strings.ToUpper(s)
+	if i := strings.Index(base, "."); i != -1 {
+		base = base[:i]
+	}
+	switch base {
+	case "CON", "PRN", "AUX", "NUL":
+		return true
+	}
+	if len(base) == 4 && (strings.HasPrefix(base, "COM") || strings.HasPrefix(base, "LPT")) {
+		return base[3] >= '1' && base[3] <= '9'
+	}
+	return false
+}
@@ -0,0 +1,392 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package iterator_test
+
+import (
+	"archive/tar"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+func TestExtractMode(t *testing.T) {
+	private, err := interfaces.ParsePerms(interfaces.PermsPrivate)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	shared, err := interfaces.ParsePerms(interfaces.PermsShared)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	tests := []struct {
+		mode  fs.FileMode
+		isDir bool
+		perms *interfaces.Perms
+		exp   fs.FileMode
+	}{
+		{0644, false, shared, 0644},
+		{0755, false, shared, 0755},
+		{0777 | fs.ModeSetuid, false, shared, 0755},
+		{0644, false, private, 0600},
+		{0755, false, private, 0700},
+		{0000, false, shared, 0600}, // we can always read it
+		{0555, true, shared, 0755},  // and write into dirs
+		{0777 | fs.ModeSticky, true, private, 0700},
+	}
+	for i, x := range tests {
+		if mode := iterator.ExtractMode(x.mode, x.isDir, x.perms); mode != x.exp {
+			t.Errorf("test %d: expected %v, got %v", i, x.exp, mode)
+		}
+	}
+}
+
+func TestTarMetadata(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	p := filepath.Join(dir, "test.tar")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	w := tar.NewWriter(f)
+	data := []byte("#!/bin/sh\n")
+	headers := []*tar.Header{
+		{Typeflag: tar.TypeDir, Name: "bin/", Mode: 0555, ModTime: mtime},
+		{Typeflag: tar.TypeReg, Name: "bin/run.sh", Mode: 04755, ModTime: mtime, Size: int64(len(data))},
+	}
+	for _, h := range headers {
+		if err := w.WriteHeader(h); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+		if h.Typeflag == tar.TypeReg {
+			if _, err := w.Write(data); err != nil {
+				t.Errorf("error: %+v", err)
+				return
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	f.Close()
+
+	prefix, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	absFile, err := safepath.ParseIntoAbsFile(p)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	it := &iterator.Tar{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf(format, v...)
+		},
+		Prefix:            prefix,
+		Path:              absFile,
+		AllowAnyExtension: true,
+	}
+	defer it.Close()
+	iterators, err := it.Recurse(context.Background(), nil)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if len(iterators) != 1 {
+		t.Errorf("expected one iterator, got: %d", len(iterators))
+		return
+	}
+	fsIterator, ok := iterators[0].(*iterator.Fs)
+	if !ok {
+		t.Errorf("expected an fs iterator")
+		return
+	}
+	root := fsIterator.Path.Path()
+
+	for _, x := range []struct {
+		name string
+		mode fs.FileMode
+	}{
+		{"bin", 0700}, // default policy is private
+		{"bin/run.sh", 0700},
+	} {
+		fi, err := os.Stat(filepath.Join(root, x.name))
+		if err != nil {
+			t.Errorf("error: %+v", err)
+			continue
+		}
+		if m := fi.Mode().Perm(); m != x.mode {
+			t.Errorf("%s: expected mode %v, got %v", x.name, x.mode, m)
+		}
+		if fi.Mode()&fs.ModeSetuid != 0 {
+			t.Errorf("%s: setuid was not removed", x.name)
+		}
+		if !fi.ModTime().Equal(mtime) {
+			t.Errorf("%s: expected mtime %v, got %v", x.name, mtime, fi.ModTime())
+		}
+	}
+}
+
+func TestExtractionResume(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "test.tar")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	w := tar.NewWriter(f)
+	data := []byte("hello\n")
+	if err := w.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "hello.txt", Mode: 0644, Size: int64(len(data))}); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	f.Close()
+
+	prefix, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	absFile, err := safepath.ParseIntoAbsFile(p)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	// extract runs the iterator and returns the directory it extracted to.
+	extract := func() string {
+		it := &iterator.Tar{
+			Logf: func(format string, v ...interface{}) {
+				t.Logf(format, v...)
+			},
+			Prefix:            prefix,
+			Path:              absFile,
+			AllowAnyExtension: true,
+		}
+		defer it.Close()
+		iterators, err := it.Recurse(context.Background(), nil)
+		if err != nil {
+			t.Errorf("error: %+v", err)
+			return ""
+		}
+		if len(iterators) != 1 {
+			t.Errorf("expected one iterator, got: %d", len(iterators))
+			return ""
+		}
+		fsIterator, ok := iterators[0].(*iterator.Fs)
+		if !ok {
+			t.Errorf("expected an fs iterator")
+			return ""
+		}
+		return fsIterator.Path.Path()
+	}
+
+	root := extract()
+	if root == "" {
+		return
+	}
+	absDir, err := safepath.ParseIntoAbsDir(root + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if complete, err := iterator.IsComplete(absDir); err != nil || !complete {
+		t.Errorf("expected a complete extraction, got: %t, %+v", complete, err)
+	}
+	if files, err := os.ReadDir(filepath.Dir(root)); err != nil || len(files) != 2 { // and the sentinel
+		t.Errorf("expected nothing else to be left behind: %+v, %+v", files, err)
+	}
+	stray := filepath.Join(root, "stray.txt")
+	if err := os.WriteFile(stray, []byte{}, 0600); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	// a complete extraction gets reused as-is
+	if r := extract(); r != root {
+		t.Errorf("expected the same directory, got: %s", r)
+	}
+	if _, err := os.Stat(stray); err != nil {
+		t.Errorf("expected the directory to be reused: %+v", err)
+	}
+
+	// An incomplete one is never scanned, but since something might still
+	// be writing into it, it's left alone, and we extract somewhere else.
+	if err := os.Remove(strings.TrimSuffix(root, "/") + iterator.CompleteSuffix); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	r := extract()
+	if r == "" || r == root {
+		t.Errorf("expected a different directory, got: %s", r)
+		return
+	}
+	if _, err := os.Stat(stray); err != nil {
+		t.Errorf("expected the incomplete directory to be left alone: %+v", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(r, "hello.txt")); err != nil || string(b) != string(data) {
+		t.Errorf("expected the file to be extracted again: %+v", err)
+	}
+	if _, err := os.Stat(filepath.Join(r, "stray.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected a clean extraction: %+v", err)
+	}
+}
+
+func TestSanitizeArchiveName(t *testing.T) {
+	long := strings.Repeat("é", 200) // 400 bytes
+	tests := []struct {
+		name string
+		exp  string
+		err  bool
+	}{
+		{"foo/bar.txt", "foo/bar.txt", false},
+		{"foo/", "foo/", false},
+		{"日本語/ファイル.txt", "日本語/ファイル.txt", false},
+		{"50%.txt", "50%.txt", false},
+		{`dir\sub\file.c`, "dir/sub/file.c", false},
+		{`C:\Users\foo.txt`, "Users/foo.txt", false},
+		{"/etc/passwd", "etc/passwd", false},
+		{"./a/./b", "a/b", false},
+		{"a/../b", "b", false},
+		{"\x93\xfa\x96{.txt", "%93%FA%96{.txt", false}, // shift-jis
+		{"tab\there", "tab%09here", false},
+		{"../evil", "", true},
+		{"a/../../evil", "", true},
+		{"/", "", true},
+		{"", "", true},
+	}
+	for i, x := range tests {
+		out, err := iterator.SanitizeArchiveName(x.name)
+		if x.err != (err != nil) {
+			t.Errorf("test %d: %q: unexpected error: %v", i, x.name, err)
+			continue
+		}
+		if out != x.exp {
+			t.Errorf("test %d: %q: expected %q, got %q", i, x.name, x.exp, out)
+		}
+	}
+
+	out, err := iterator.SanitizeArchiveName("dir/" + long)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	base := strings.TrimPrefix(out, "dir/")
+	if len(base) > iterator.ArchiveNameMax || !utf8.ValidString(base) {
+		t.Errorf("long name was not shortened safely: %q", base)
+	}
+	if again, _ := iterator.SanitizeArchiveName("dir/" + long); again != out {
+		t.Errorf("long name is not stable: %q != %q", again, out)
+	}
+}
+
+func TestTarUnsafeNames(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "test.tar")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	w := tar.NewWriter(f)
+	data := []byte("hello\n")
+	for _, name := range []string{"../evil.txt", "\x93\xfa.txt", `win\path.txt`} {
+		if err := w.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(data)), Format: tar.FormatGNU}); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	f.Close()
+
+	prefix, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	absFile, err := safepath.ParseIntoAbsFile(p)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	it := &iterator.Tar{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf(format, v...)
+		},
+		Prefix:            prefix,
+		Path:              absFile,
+		AllowAnyExtension: true,
+	}
+	defer it.Close()
+	iterators, err := it.Recurse(context.Background(), nil)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if len(iterators) != 1 {
+		t.Errorf("expected one iterator, got: %d", len(iterators))
+		return
+	}
+	root := iterators[0].(*iterator.Fs).Path.Path()
+
+	for _, x := range []string{"%93%FA.txt", "win/path.txt"} {
+		if _, err := os.Stat(filepath.Join(root, x)); err != nil {
+			t.Errorf("error: %+v", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "..", "evil.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected the escaping entry to be skipped: %+v", err)
+	}
+}
@@ -65,6 +65,18 @@ type Fs struct {
 	Logf   func(format string, v ...interface{})
 	Prefix safepath.AbsDir
 
+	// Perms is the permission policy for everything that we write. If it
+	// is nil, then the default policy is used.
+	Perms *interfaces.Perms
+
+	// Limiter throttles the downloads. It is shared with every iterator
+	// that we return. If it is nil, then nothing is throttled.
+	Limiter *Limiter
+
+	// Offline forbids any network access. It is passed down to every
+	// iterator that we return.
+	Offline bool
+
 	// Parser is a pointer to the parser that returned this. If it wasn't
 	// returned by a parser, leave this nil. If this iterator came from an
 	// iterator, then the Iterator handle should be filled instead.
@@ -164,105 +176,14 @@ func (obj *Fs) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interfa
 		info := &interfaces.Info{
 			FileInfo: fileInfo,
 			UID:      uid,
+			Root:     true,
 		}
 
-		if absFile.HasExtInsensitive(ZipExtension) || absFile.HasExtInsensitive(JarExtension) || absFile.HasExtInsensitive(WhlExtension) {
-			iterator := &Zip{
-				Debug: obj.Debug,
-				Logf: func(format string, v ...interface{}) {
-					obj.Logf(format, v...) // TODO: add a prefix?
-				},
-				Prefix: obj.Prefix,
-
-				Iterator: obj,
-
-				Path: absFile,
-
-				//AllowAnyExtension: false, // not helpful here
-				AllowedExtensions: []string{
-					ZipExtension,
-					JarExtension,
-					WhlExtension,
-				},
-			}
-
-			mu.Lock()
-			iterators = append(iterators, iterator)
-			mu.Unlock()
-			return iterators, nil
-		}
-
-		if absFile.HasExtInsensitive(TarExtension) {
-			iterator := &Tar{
-				Debug: obj.Debug,
-				Logf: func(format string, v ...interface{}) {
-					obj.Logf(format, v...) // TODO: add a prefix?
-				},
-				Prefix: obj.Prefix,
-
-				Iterator: obj,
-
-				Path: absFile,
-
-				//AllowAnyExtension: false, // not helpful here
-			}
-
-			mu.Lock()
-			iterators = append(iterators, iterator)
-			mu.Unlock()
-			return iterators, nil
-		}
-
-		isGzip := false
-		for _, x := range GzipExtensions {
-			if absFile.HasExtInsensitive(x) {
-				isGzip = true
-				break
-			}
-		}
-		if isGzip {
-			iterator := &Gzip{
-				Debug: obj.Debug,
-				Logf: func(format string, v ...interface{}) {
-					obj.Logf(format, v...) // TODO: add a prefix?
-				},
-				Prefix: obj.Prefix,
-
-				Iterator: obj,
-
-				Path: absFile,
-
-				//AllowAnyExtension: false, // not helpful here
-			}
-
-			mu.Lock()
-			iterators = append(iterators, iterator)
-			mu.Unlock()
-			return iterators, nil
-		}
-
-		isBzip2 := false
-		for _, x := range Bzip2Extensions {
-			if absFile.HasExtInsensitive(x) {
-				isBzip2 = true
-				break
-			}
+		iterator, err := obj.fileIterator(absFile)
+		if err != nil {
+			return nil, err
 		}
-		if isBzip2 {
-			iterator := &Bzip2{
-				Debug: obj.Debug,
-				Logf: func(format string, v ...interface{}) {
-					obj.Logf(format, v...) // TODO: add a prefix?
-				},
-				Prefix: obj.Prefix,
-
-				Iterator: obj,
-
-				Path: absFile,
-
-				//AllowAnyExtension: false, // not helpful here
-			}
-
+		if iterator != nil {
 			mu.Lock()
 			iterators = append(iterators, iterator)
 			mu.Unlock()
@@ -306,9 +227,22 @@ func (obj *Fs) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interfa
 		default:
 		}
 
-		// skip symlinks
+		// Skip symlinks, except for license files, since in a monorepo
+		// they often point to the one at the root, which is what applies.
+		symlink := ""
 		if fileInfo.Mode()&os.ModeSymlink == os.ModeSymlink {
-			return nil
+			if !IsLicenseName(fileInfo.Name()) {
+				return nil
+			}
+			target, targetInfo, err := obj.resolveSymlink(path)
+			if err != nil {
+				obj.Logf("skipping symlink: %s: %s", path, err)
+				return nil
+			}
+			if symlink, err = obj.uid(target); err != nil {
+				return err
+			}
+			fileInfo = targetInfo // so that the size is of the target
 		}
 
 		// Check for a .gitmodules file.
@@ -334,114 +268,11 @@ func (obj *Fs) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interfa
 
 		if !safePath.IsDir() && safePath.IsAbs() {
 			absFile := safepath.UnsafeParseIntoAbsFile(safePath.Path())
-			// TODO: it's time to create a generic "register"
-			// constructor that the other iterators can use to
-			// connect into this fs iterator... This will avoid a
-			// lot of code duplication and also prevent us from
-			// forgetting to add these everywhere...
-			if absFile.HasExtInsensitive(ZipExtension) || absFile.HasExtInsensitive(JarExtension) || absFile.HasExtInsensitive(WhlExtension) {
-				iterator := &Zip{
-					Debug: obj.Debug,
-					Logf: func(format string, v ...interface{}) {
-						obj.Logf(format, v...) // TODO: add a prefix?
-					},
-					Prefix: obj.Prefix,
-
-					Iterator: obj,
-
-					Path: absFile,
-
-					//AllowAnyExtension: false, // not helpful here
-					AllowedExtensions: []string{
-						ZipExtension,
-						JarExtension,
-						WhlExtension,
-					},
-				}
-
-				mu.Lock()
-				iterators = append(iterators, iterator)
-				mu.Unlock()
-				// NOTE: if we return nil here, then we block
-				// any scanners that might want to handle a
-				// whole .zip file in one go specially...
-			}
-
-			if absFile.HasExtInsensitive(TarExtension) {
-				iterator := &Tar{
-					Debug: obj.Debug,
-					Logf: func(format string, v ...interface{}) {
-						obj.Logf(format, v...) // TODO: add a prefix?
-					},
-					Prefix: obj.Prefix,
-
-					Iterator: obj,
-
-					Path: absFile,
-
-					//AllowAnyExtension: false, // not helpful here
-				}
-
-				mu.Lock()
-				iterators = append(iterators, iterator)
-				mu.Unlock()
-				// NOTE: if we return nil here, then we block
-				// any scanners that might want to handle a
-				// whole .zip file in one go specially...
-			}
-
-			isGzip := false
-			for _, x := range GzipExtensions {
-				if absFile.HasExtInsensitive(x) {
-					isGzip = true
-					break
-				}
-			}
-			if isGzip {
-				iterator := &Gzip{
-					Debug: obj.Debug,
-					Logf: func(format string, v ...interface{}) {
-						obj.Logf(format, v...) // TODO: add a prefix?
-					},
-					Prefix: obj.Prefix,
-
-					Iterator: obj,
-
-					Path: absFile,
-
-					//AllowAnyExtension: false, // not helpful here
-				}
-
-				mu.Lock()
-				iterators = append(iterators, iterator)
-				mu.Unlock()
-				// NOTE: if we return nil here, then we block
-				// any scanners that might want to handle a
-				// whole .zip file in one go specially...
-			}
-
-			isBzip2 := false
-			for _, x := range Bzip2Extensions {
-				if absFile.HasExtInsensitive(x) {
-					isBzip2 = true
-					break
-				}
+			iterator, err := obj.fileIterator(absFile)
+			if err != nil {
+				return err
 			}
-			if isBzip2 {
-				iterator := &Bzip2{
-					Debug: obj.Debug,
-					Logf: func(format string, v ...interface{}) {
-						obj.Logf(format, v...) // TODO: add a prefix?
-					},
-					Prefix: obj.Prefix,
-
-					Iterator: obj,
-
-					Path: absFile,
-
-					//AllowAnyExtension: false, // not helpful here
-				}
-
+			if iterator != nil {
 				mu.Lock()
 				iterators = append(iterators, iterator)
 				mu.Unlock()
@@ -455,18 +286,15 @@ func (obj *Fs) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interfa
 			obj.Logf("visited file or dir: %q", path)
 		}
 
-		uid := FileScheme + safePath.String() // the (ugly) default
-		if obj.GenUID != nil {
-			var err error
-			uid, err = obj.GenUID(safePath)
-			if err != nil {
-				// probable programming error
-				return errwrap.Wrapf(err, "the GetUID func failed")
-			}
+		uid, err := obj.uid(safePath)
+		if err != nil {
+			return err
 		}
 		info := &interfaces.Info{
 			FileInfo: fileInfo,
 			UID:      uid,
+			Root:     safePath.Path() == obj.Path.Path(),
+			Symlink:  symlink,
 		}
 		// We want to ignore the ErrUnknownLicense results, and error if
 		// we hit any actual errors that we should bubble upwards.
@@ -482,6 +310,73 @@ func (obj *Fs) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interfa
 	return iterators, errwrap.Wrapf(err, "walk failed")
 }
 
+// uid returns the UID of this path, using the GenUID function if there is one.
+func (obj *Fs) uid(p safepath.Path) (string, error) {
+	if obj.GenUID == nil {
+		return FileScheme + p.String(), nil // the (ugly) default
+	}
+	uid, err := obj.GenUID(p)
+	if err != nil {
+		// probable programming error
+		return "", errwrap.Wrapf(err, "the GetUID func failed")
+	}
+	return uid, nil
+}
+
+// resolveSymlink returns the regular file that this symlink points to, and the
+// file info of it. It errors if the link is broken, or if the file isn't inside
+// of the path that we're walking, since we must never read outside of it.
+func (obj *Fs) resolveSymlink(p string) (safepath.AbsFile, fs.FileInfo, error) {
+	root, err := filepath.EvalSymlinks(obj.Path.Path())
+	if err != nil {
+		return safepath.AbsFile{}, nil, err
+	}
+	target, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return safepath.AbsFile{}, nil, err // a broken link
+	}
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return safepath.AbsFile{}, nil, fmt.Errorf("it points outside of %s", obj.Path)
+	}
+	fileInfo, err := os.Stat(target)
+	if err != nil {
+		return safepath.AbsFile{}, nil, err
+	}
+	if !fileInfo.Mode().IsRegular() {
+		return safepath.AbsFile{}, nil, fmt.Errorf("it is not a regular file")
+	}
+	// use the path that the walk would have used for the same file
+	absFile, err := safepath.ParseIntoAbsFile(filepath.Join(obj.Path.Path(), rel))
+	if err != nil {
+		return safepath.AbsFile{}, nil, err
+	}
+	return absFile, fileInfo, nil
+}
+
+// fileIterator returns the iterator from the registry which handles this file,
+// such as the one for an archive. It returns nil if none of them do.
+func (obj *Fs) fileIterator(absFile safepath.AbsFile) (interfaces.Iterator, error) {
+	registration := LookupExtension(absFile.Path())
+	if registration == nil {
+		return nil, nil
+	}
+	settings := &Settings{
+		Debug: obj.Debug,
+		Logf: func(format string, v ...interface{}) {
+			obj.Logf(format, v...) // TODO: add a prefix?
+		},
+		Prefix:  obj.Prefix,
+		Perms:   obj.Perms,
+		Limiter: obj.Limiter,
+		Offline: obj.Offline,
+
+		Iterator: obj,
+	}
+	iterator, err := registration.File(settings, absFile)
+	return iterator, errwrap.Wrapf(err, "could not build %s iterator", registration.Name)
+}
+
 // Close shuts down the iterator and/or performs clean up after the Recurse
 // method has run. This must be called if you run Recurse.
 func (obj *Fs) Close() error {
@@ -571,7 +466,10 @@ func (obj *Fs) GitSubmodulesHelper(ctx context.Context, p safepath.Path) ([]inte
 			Logf: func(format string, v ...interface{}) {
 				obj.Logf(format, v...) // TODO: add a prefix?
 			},
-			Prefix: obj.Prefix,
+			Prefix:  obj.Prefix,
+			Perms:   obj.Perms,
+			Limiter: obj.Limiter,
+			Offline: obj.Offline,
 
 			Iterator: obj,
 
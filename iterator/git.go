@@ -93,6 +93,19 @@ type Git struct {
 	Logf   func(format string, v ...interface{})
 	Prefix safepath.AbsDir
 
+	// Perms is the permission policy for everything that we write. If it
+	// is nil, then the default policy is used.
+	Perms *interfaces.Perms
+
+	// Limiter throttles the downloads. It is shared with every iterator
+	// that we return. If it is nil, then nothing is throttled.
+	Limiter *Limiter
+
+	// Offline forbids any network access. The repository must already
+	// be in the local cache from a previous run, and if no Hash, Ref or
+	// Rev is specified, then the HEAD of that cached clone is used.
+	Offline bool
+
 	// Parser is a pointer to the parser that returned this. If it wasn't
 	// returned by a parser, leave this nil. If this iterator came from an
 	// iterator, then the Iterator handle should be filled instead.
@@ -273,13 +286,46 @@ func (obj *Git) GetParser() interfaces.Parser { return obj.Parser }
 // if there is one.
 func (obj *Git) GetIterator() interfaces.Iterator { return obj.Iterator }
 
+// clone clones the repository into this directory, which must not have anything
+// else in it yet.
+func (obj *Git) clone(ctx context.Context, directory string) error {
+	release, err := obj.Limiter.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// The server sends us its progress messages, which already include
+	// the counts and the rate, so we show the latest one as our status.
+	status := &lastLine{}
+	progress := &Progress{
+		Logf:   obj.Logf,
+		Name:   fmt.Sprintf("cloning %s", obj.URL),
+		Status: status.String,
+	}
+	progress.Start()
+	defer progress.Stop()
+
+	isBare := false
+	_, err = git.PlainCloneContext(withLimiter(ctx, obj.Limiter), directory, isBare, &git.CloneOptions{
+		URL: obj.URL,
+		// Don't recurse, we do it manually with the FsIterator, as this
+		// way we'll get all the repositories cloned next to each other,
+		// instead of in a big recursive filesystem tree.
+		RecurseSubmodules: git.NoRecurseSubmodules,
+		//Auth transport.AuthMethod
+		Progress: status,
+	})
+	return err
+}
+
 // Recurse runs a simple iterator that is responsible for cloning a git
 // repository into a local filesystem path. If this happens successfully, it
 // will return a new FsIterator that is initialized to this root path.
 func (obj *Git) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interfaces.Iterator, error) {
 	relDir := safepath.UnsafeParseIntoRelDir("git/")
 	prefix := safepath.JoinToAbsDir(obj.Prefix, relDir)
-	if err := os.MkdirAll(prefix.Path(), interfaces.Umask); err != nil {
+	if err := os.MkdirAll(prefix.Path(), obj.Perms.DirMode()); err != nil {
 		return nil, err
 	}
 
@@ -320,31 +366,50 @@ func (obj *Git) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 
 	// XXX: unlock when context closes?
 
-	obj.Logf("cloning %s into %s", obj.String(), repoAbsDir)
-
-	directory := repoAbsDir.Path()
-	isBare := false
-	repository, err := git.PlainCloneContext(ctx, directory, isBare, &git.CloneOptions{
-		URL: obj.URL,
-		// Don't recurse, we do it manually with the FsIterator, as this
-		// way we'll get all the repositories cloned next to each other,
-		// instead of in a big recursive filesystem tree.
-		RecurseSubmodules: git.NoRecurseSubmodules,
-		//Auth transport.AuthMethod
-		//Progress: os.Stdout,
+	// An interrupted clone leaves a repository behind which opens without
+	// any errors, but which might be missing objects, so we only reuse one
+	// that was marked as complete.
+	complete, err := IsComplete(repoAbsDir)
+	if err != nil {
+		obj.unlock()
+		return nil, errwrap.Wrapf(err, "error checking %s", repoAbsDir)
+	}
+	if obj.Offline && !complete {
+		obj.unlock()
+		return nil, fmt.Errorf("offline: %s is not in the local cache", obj.String())
+	}
 
-	})
-	if err == git.ErrRepositoryAlreadyExists {
+	if complete {
 		obj.Logf("repo %s already exists", obj.String())
-		repository, err = git.PlainOpenWithOptions(directory, &git.PlainOpenOptions{})
+
+	} else {
+		obj.Logf("cloning %s into %s", obj.String(), repoAbsDir)
+
+		// Another process might be cloning the same repository, so we
+		// each clone into a directory of our own, and then move it into
+		// place.
+		stagingAbsDir, err := newStaging(repoAbsDir, obj.Perms)
 		if err != nil {
 			obj.unlock()
-			return nil, errwrap.Wrapf(err, "error opening repository")
+			return nil, errwrap.Wrapf(err, "error preparing %s", repoAbsDir)
+		}
+		if err := obj.clone(ctx, stagingAbsDir.Path()); err != nil {
+			os.RemoveAll(stagingAbsDir.Path()) // whatever we got done
+			obj.unlock()
+			return nil, errwrap.Wrapf(err, "error cloning repository %s", obj.String())
+		}
+		if repoAbsDir, err = finishStaging(stagingAbsDir, repoAbsDir, obj.Perms, obj.Logf); err != nil {
+			os.RemoveAll(stagingAbsDir.Path())
+			obj.unlock()
+			return nil, errwrap.Wrapf(err, "error moving %s into place", stagingAbsDir)
 		}
+	}
 
-	} else if err != nil {
+	directory := repoAbsDir.Path()
+	repository, err := git.PlainOpenWithOptions(directory, &git.PlainOpenOptions{})
+	if err != nil {
 		obj.unlock()
-		return nil, errwrap.Wrapf(err, "error cloning repository %s", obj.String())
+		return nil, errwrap.Wrapf(err, "error opening repository")
 	}
 
 	var hash plumbing.Hash
@@ -400,6 +465,18 @@ func (obj *Git) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 	//	obj.Logf("default HEAD is at: %s", name)
 	//}
 
+	if hash.IsZero() && obj.Offline {
+		// We can't ask the remote for its HEAD branch, so we use the
+		// HEAD that this cache entry was last checked out at instead.
+		head, err := repository.Head()
+		if err != nil {
+			obj.unlock()
+			return nil, errwrap.Wrapf(err, "could not find cached HEAD")
+		}
+		hash = head.Hash()
+		obj.Logf("offline: using cached HEAD at: %s", hash)
+	}
+
 	if hash.IsZero() {
 		// git symbolic-ref refs/remotes/origin/HEAD			# doesn't work in this clone!
 		// git remote show origin | grep 'HEAD branch' | cut -d' ' -f5	# does work
@@ -503,6 +580,17 @@ func (obj *Git) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 		}
 	}
 
+	// Git itself can't disambiguate paths which only differ by case, so on a
+	// case-insensitive filesystem, only one of each ends up in the worktree.
+	// The others are never scanned, so we at least say which ones they are.
+	if caseInsensitive, err := IsCaseInsensitive(repoAbsDir.Path()); err != nil {
+		obj.Logf("could not check the filesystem: %+v", err)
+	} else if caseInsensitive {
+		if err := obj.warnCaseCollisions(repository, hash); err != nil {
+			obj.Logf("could not check for case collisions: %+v", err)
+		}
+	}
+
 	obj.iterators = []interfaces.Iterator{}
 
 	u, err := url.Parse(obj.URL) // build a url to modify
@@ -535,7 +623,10 @@ func (obj *Git) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 		Logf: func(format string, v ...interface{}) {
 			obj.Logf(format, v...) // TODO: add a prefix?
 		},
-		Prefix: obj.Prefix,
+		Prefix:  obj.Prefix,
+		Perms:   obj.Perms,
+		Limiter: obj.Limiter,
+		Offline: obj.Offline,
 
 		Iterator: obj,
 
@@ -570,6 +661,30 @@ func (obj *Git) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 	return obj.iterators, nil
 }
 
+// warnCaseCollisions logs each group of paths in this commit which only differ
+// by case. Only one of each group was checked out and can be scanned.
+func (obj *Git) warnCaseCollisions(repository *git.Repository, hash plumbing.Hash) error {
+	commit, err := repository.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+	paths := []string{}
+	if err := tree.Files().ForEach(func(f *object.File) error {
+		paths = append(paths, f.Name)
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, x := range CaseCollisions(paths) {
+		obj.Logf("warning: these paths only differ by case, and only one of them can be scanned on this filesystem: %s", strings.Join(x, ", "))
+	}
+	return nil
+}
+
 // Close shuts down the iterator and/or performs clean up after the Recurse
 // method has run. This must be called if you run Recurse.
 func (obj *Git) Close() error {
@@ -0,0 +1,98 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package iterator_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+func TestGitOffline(t *testing.T) {
+	if _, err := exec.LookPath(iterator.GitProgram); err != nil {
+		t.Skipf("no git: %+v", err)
+	}
+	origin := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command(iterator.GitProgram, args...)
+		cmd.Dir = origin
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %+v: %+v: %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q")
+	if err := os.WriteFile(filepath.Join(origin, "LICENSE"), []byte("MIT\n"), 0600); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	git("add", "LICENSE")
+	git("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial")
+	hash := git("rev-parse", "HEAD")
+
+	prefix, err := safepath.ParseIntoAbsDir(t.TempDir() + "/")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	recurse := func(offline bool) error {
+		it := &iterator.Git{
+			Logf: func(format string, v ...interface{}) {
+				t.Logf("iterator: "+format, v...)
+			},
+			Prefix:  prefix,
+			URL:     "file://" + origin,
+			Hash:    hash,
+			Offline: offline,
+		}
+		if err := it.Validate(); err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		defer it.Close()
+		_, err := it.Recurse(context.Background(), nil)
+		return err
+	}
+
+	if err := recurse(true); err == nil || !strings.Contains(err.Error(), "not in the local cache") {
+		t.Errorf("expected a cache error, got: %+v", err)
+	}
+	if err := recurse(false); err != nil { // populates the cache
+		t.Fatalf("error: %+v", err)
+	}
+	// the clone was moved into place, and marked as complete
+	if files, err := os.ReadDir(filepath.Join(prefix.Path(), "git")); err != nil || len(files) != 2 {
+		t.Errorf("expected the clone and its sentinel: %+v, %+v", files, err)
+	}
+	if err := os.RemoveAll(origin); err != nil { // the remote is gone now
+		t.Fatalf("error: %+v", err)
+	}
+	if err := recurse(true); err != nil {
+		t.Errorf("error: %+v", err)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package iterator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+// GitStagedFiles returns the list of files that are staged in the git index of
+// the repository that contains dir, and which differ from HEAD. Deleted files
+// are not included, since there's nothing left to scan. The paths are relative
+// to the top of the repository.
+func GitStagedFiles(ctx context.Context, dir string) ([]string, error) {
+	args := []string{"diff", "--cached", "--name-only", "--no-renames", "--diff-filter=ACM", "-z"}
+	cmd := exec.CommandContext(ctx, GitProgram, args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "error running: %s %s", GitProgram, strings.Join(args, " "))
+	}
+	files := []string{}
+	for _, x := range strings.Split(string(out), "\x00") {
+		if x == "" {
+			continue
+		}
+		files = append(files, x)
+	}
+	return files, nil
+}
+
+// GitStagedFS returns an in-memory filesystem with the staged contents of each
+// of the files that are staged in the git index of the repository that
+// contains dir. This is what will be committed, which is not necessarily what
+// is in the working tree. Anything that isn't a regular blob, such as a
+// submodule, is left out. This is useful for a pre-commit hook.
+func GitStagedFS(ctx context.Context, dir string) (fs.FS, error) {
+	files, err := GitStagedFiles(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &bytes.Buffer{}
+	for _, x := range files {
+		if strings.Contains(x, "\n") {
+			continue // the batch protocol can't express this
+		}
+		fmt.Fprintf(input, ":%s\n", x) // the index version of the path
+	}
+
+	args := []string{"cat-file", "--batch"}
+	cmd := exec.CommandContext(ctx, GitProgram, args...)
+	cmd.Dir = dir
+	cmd.Stdin = input
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "error running: %s %s", GitProgram, strings.Join(args, " "))
+	}
+
+	fsys := &memFS{
+		files:   make(map[string][]byte),
+		modTime: time.Now(),
+	}
+	r := bufio.NewReader(bytes.NewReader(out))
+	for _, x := range files {
+		if strings.Contains(x, "\n") {
+			continue
+		}
+		// <oid> <type> <size>\n<contents>\n or <object> missing\n
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "could not read header of: %s", x)
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			continue // missing
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "invalid size of: %s", x)
+		}
+		data := make([]byte, size+1) // with the trailing newline
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, errwrap.Wrapf(err, "could not read contents of: %s", x)
+		}
+		if fields[1] != "blob" {
+			continue
+		}
+		fsys.files[x] = data[:size]
+	}
+	return fsys, nil
+}
@@ -0,0 +1,95 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package iterator_test
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/awslabs/yesiscan/iterator"
+)
+
+func TestGitStagedFS(t *testing.T) {
+	if _, err := exec.LookPath(iterator.GitProgram); err != nil {
+		t.Skipf("no git: %+v", err)
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command(iterator.GitProgram, args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %+v: %+v: %s", args, err, out)
+		}
+	}
+	write := func(name, data string) {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0600); err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+	}
+
+	git("init", "-q")
+	write("sub/staged.txt", "what gets committed\n")
+	write("unstaged.txt", "not added\n")
+	git("add", "sub/staged.txt")
+	write("sub/staged.txt", "edited after staging\n")
+
+	ctx := context.Background()
+	fsys, err := iterator.GitStagedFS(ctx, dir)
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	names := []string{}
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			names = append(names, name)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if expected := []string{"sub/staged.txt"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %+v, got: %+v", expected, names)
+	}
+	data, err := fs.ReadFile(fsys, "sub/staged.txt")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if s := string(data); s != "what gets committed\n" {
+		t.Errorf("expected the staged contents, got: %q", s)
+	}
+	if err := fstest.TestFS(fsys, "sub/staged.txt"); err != nil {
+		t.Errorf("error: %+v", err)
+	}
+}
@@ -26,14 +26,11 @@ package iterator
 import (
 	"compress/gzip"
 	"context"
-	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/awslabs/yesiscan/interfaces"
 	"github.com/awslabs/yesiscan/util/errwrap"
@@ -46,6 +43,7 @@ var (
 		".gz",
 		".gzip",
 		".tgz",
+		".crate", // rust crate, a .tar.gz by another name
 		//".tar.gz",
 		//".tar.gzip",
 	}
@@ -57,6 +55,26 @@ var (
 func init() {
 	gzipMapMutex = &sync.Mutex{}
 	gzipMutexes = make(map[string]*sync.Mutex)
+
+	Register(&Registration{
+		Name:       "gzip",
+		Extensions: GzipExtensions,
+		File: func(settings *Settings, path safepath.AbsFile) (interfaces.Iterator, error) {
+			return &Gzip{
+				Debug:   settings.Debug,
+				Logf:    settings.Logf,
+				Prefix:  settings.Prefix,
+				Perms:   settings.Perms,
+				Limiter: settings.Limiter,
+				Offline: settings.Offline,
+
+				Parser:   settings.Parser,
+				Iterator: settings.Iterator,
+
+				Path: path,
+			}, nil
+		},
+	})
 }
 
 // Gzip is an iterator that takes a .gz or similar URI to open and performs the
@@ -71,6 +89,18 @@ type Gzip struct {
 	Logf   func(format string, v ...interface{})
 	Prefix safepath.AbsDir
 
+	// Perms is the permission policy for everything that we write. If it
+	// is nil, then the default policy is used.
+	Perms *interfaces.Perms
+
+	// Limiter throttles the downloads. It is shared with every iterator
+	// that we return. If it is nil, then nothing is throttled.
+	Limiter *Limiter
+
+	// Offline forbids any network access. It is passed down to every
+	// iterator that we return.
+	Offline bool
+
 	// Parser is a pointer to the parser that returned this. If it wasn't
 	// returned by a parser, leave this nil. If this iterator came from an
 	// iterator, then the Iterator handle should be filled instead.
@@ -171,20 +201,16 @@ func (obj *Gzip) GetIterator() interfaces.Iterator { return obj.Iterator }
 func (obj *Gzip) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interfaces.Iterator, error) {
 	relDir := safepath.UnsafeParseIntoRelDir("gzip/")
 	prefix := safepath.JoinToAbsDir(obj.Prefix, relDir)
-	if err := os.MkdirAll(prefix.Path(), interfaces.Umask); err != nil {
+	if err := os.MkdirAll(prefix.Path(), obj.Perms.DirMode()); err != nil {
 		return nil, err
 	}
 
-	// make a unique ID for the directory
-	// XXX: we can consider different algorithms or methods here later...
-	now := strconv.FormatInt(time.Now().UnixMilli(), 10) // itoa but int64
-	sum := sha256.Sum256([]byte(obj.Path.Path() + now))
-	hashRelDir, err := safepath.ParseIntoRelDir(fmt.Sprintf("%x", sum))
+	// The directory is named after the archive, so that an unchanged one
+	// only gets extracted once, and is then reused by any later scans.
+	gzipAbsDir, err := ExtractionDir(prefix, obj.Path)
 	if err != nil {
 		return nil, err
 	}
-	// ensure it gets put into a folder so it doesn't explode current dir
-	gzipAbsDir := safepath.JoinToAbsDir(prefix, hashRelDir)
 
 	gzipMapMutex.Lock()
 	mu, exists := gzipMutexes[obj.Path.Path()]
@@ -211,8 +237,33 @@ func (obj *Gzip) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inter
 
 	// XXX: unlock when context closes?
 
-	// XXX: If the destination dir has contents, consider removing them
-	// first. This is one reason why we have a mutex.
+	// A previous run might have been interrupted part of the way through,
+	// so we only reuse the directory if it was marked as complete. This is
+	// one reason why we have a mutex.
+	complete, err := IsComplete(gzipAbsDir)
+	if err != nil {
+		obj.unlock()
+		return nil, errwrap.Wrapf(err, "error checking %s", gzipAbsDir)
+	}
+	if complete {
+		obj.Logf("already extracted %s into %s", obj.String(), gzipAbsDir)
+		obj.iterators = []interfaces.Iterator{obj.fsIterator(gzipAbsDir)}
+		return obj.iterators, nil
+	}
+
+	// Another process might be extracting the same archive, so we each
+	// extract into a directory of our own, and then move it into place.
+	stagingAbsDir, err := newStaging(gzipAbsDir, obj.Perms)
+	if err != nil {
+		obj.unlock()
+		return nil, errwrap.Wrapf(err, "error preparing %s", gzipAbsDir)
+	}
+	finished := false
+	defer func() {
+		if !finished {
+			os.RemoveAll(stagingAbsDir.Path()) // whatever we got done
+		}
+	}()
 
 	// Open the gzip file for reading.
 	// FIXME: use a variant that can take a context
@@ -233,8 +284,13 @@ func (obj *Gzip) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inter
 
 	filesTotal := 0
 	bytesTotal := int64(0)
+	progress := &Progress{
+		Logf: obj.Logf,
+		Name: fmt.Sprintf("decompressing %s", obj.Path),
+	}
+	progress.Start()
+	defer progress.Stop()
 	// Iterate through the files in the archive.
-	// TODO: add a recurring progress logf if it takes longer than 30 sec
 	for {
 		// In an effort to short-circuit things if needed, we run a
 		// check ourselves and break out early if we see that we have
@@ -273,7 +329,7 @@ func (obj *Gzip) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inter
 		}
 
 		// add in a .tar if it's an embedded tar file
-		if strings.HasSuffix(strings.ToLower(obj.Path.Path()), ".tgz") {
+		if ext := strings.ToLower(obj.Path.Path()); strings.HasSuffix(ext, ".tgz") || strings.HasSuffix(ext, ".crate") {
 			newName += ".tar"
 		}
 		relFile, err := safepath.ParseIntoRelFile(newName)
@@ -284,22 +340,22 @@ func (obj *Gzip) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inter
 		}
 
 		// this is where the output file will be stored
-		absFile := safepath.JoinToAbsFile(gzipAbsDir, relFile)
+		absFile := safepath.JoinToAbsFile(stagingAbsDir, relFile)
 
 		// XXX: sanity check (is output in the dir?)
 		// TODO: we could add this, but safepath automatically does this
-		// if absFile is not inside of gzipAbsDir then error
+		// if absFile is not inside of stagingAbsDir then error
 
 		absDir := absFile.Dir() // get the absDir that absFile is in
 
-		if err := os.MkdirAll(absDir.Path(), os.ModePerm); err != nil {
+		if err := os.MkdirAll(absDir.Path(), obj.Perms.DirMode()); err != nil {
 			// programming error
 			obj.unlock()
 			return nil, err
 		}
 
 		// write to this location
-		dest, err := os.OpenFile(absFile.Path(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+		dest, err := os.OpenFile(absFile.Path(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, obj.Perms.FileMode())
 		if err != nil {
 			obj.unlock()
 			return nil, errwrap.Wrapf(err, "error writing our file to disk at %s", absFile)
@@ -307,7 +363,7 @@ func (obj *Gzip) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inter
 		// don't `defer` close here because we want to free in the loop
 
 		// FIXME: use a variant that can take a context
-		size, err := io.Copy(dest, z)
+		size, err := io.Copy(dest, io.TeeReader(z, progress))
 		if err != nil {
 			dest.Close() // close dest file on error!
 			obj.unlock()
@@ -317,6 +373,17 @@ func (obj *Gzip) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inter
 
 		dest.Close() // close dest file on error!
 
+		// gzip only stores the mtime, so we keep the policy mode
+		file := &extracted{
+			path:  absFile.Path(),
+			mode:  obj.Perms.FileMode(),
+			mtime: z.Header.ModTime,
+		}
+		if err := file.finish(); err != nil {
+			obj.unlock()
+			return nil, errwrap.Wrapf(err, "error setting metadata on %s", absFile)
+		}
+
 		filesTotal++
 		bytesTotal += int64(size)
 
@@ -326,26 +393,38 @@ func (obj *Gzip) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inter
 	// TODO: change to human readable bytes
 	obj.Logf("uncompressed: %d files from %s into %s (%d bytes)", filesTotal, obj.String(), gzipAbsDir, bytesTotal)
 
-	obj.iterators = []interfaces.Iterator{}
+	if gzipAbsDir, err = finishStaging(stagingAbsDir, gzipAbsDir, obj.Perms, obj.Logf); err != nil {
+		obj.unlock()
+		return nil, errwrap.Wrapf(err, "error moving %s into place", stagingAbsDir)
+	}
+	finished = true
 
+	obj.iterators = []interfaces.Iterator{obj.fsIterator(gzipAbsDir)}
+
+	return obj.iterators, nil
+}
+
+// fsIterator returns the iterator which scans the directory that we extracted
+// into.
+func (obj *Gzip) fsIterator(dir safepath.AbsDir) interfaces.Iterator {
 	// if it's a single gzip file we return an fs iterator and let the fs
 	// iterator sort that out...
-	iterator := &Fs{
+	return &Fs{
 		Debug: obj.Debug,
 		Logf: func(format string, v ...interface{}) {
 			obj.Logf(format, v...) // TODO: add a prefix?
 		},
-		Prefix: obj.Prefix,
+		Prefix:  obj.Prefix,
+		Perms:   obj.Perms,
+		Limiter: obj.Limiter,
+		Offline: obj.Offline,
 
 		Iterator: obj,
 
-		Path: gzipAbsDir,
+		Path: dir,
 
 		//Unlock: unlock,
 	}
-	obj.iterators = append(obj.iterators, iterator)
-
-	return obj.iterators, nil
 }
 
 // Close shuts down the iterator and/or performs clean up after the Recurse
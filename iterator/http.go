@@ -26,6 +26,7 @@ package iterator
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
@@ -34,6 +35,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/awslabs/yesiscan/interfaces"
@@ -84,6 +86,14 @@ type Http struct {
 	Logf   func(format string, v ...interface{})
 	Prefix safepath.AbsDir
 
+	// Perms is the permission policy for everything that we write. If it
+	// is nil, then the default policy is used.
+	Perms *interfaces.Perms
+
+	// Limiter throttles the downloads. It is shared with every iterator
+	// that we return. If it is nil, then nothing is throttled.
+	Limiter *Limiter
+
 	// Parser is a pointer to the parser that returned this. If it wasn't
 	// returned by a parser, leave this nil. If this iterator came from an
 	// iterator, then the Iterator handle should be filled instead.
@@ -103,6 +113,16 @@ type Http struct {
 	// (unencrypted) URLs.
 	AllowHttp bool
 
+	// Checksum is the lowercase hex sha256 sum that the downloaded file
+	// must have. If it doesn't match, then the file is removed and nothing
+	// gets scanned. If it is empty, then any file is accepted.
+	Checksum string
+
+	// Sum is the lowercase hex sha256 sum of the downloaded file. It is set
+	// by Recurse once the download has finished, so that the caller can
+	// record it.
+	Sum string
+
 	// iterators store the list of which iterators we created, so we know
 	// which ones we have to close!
 	iterators []interfaces.Iterator
@@ -151,6 +171,12 @@ func (obj *Http) Validate() error {
 		return fmt.Errorf("the http scheme is not allowed without the allow http option")
 	}
 
+	if obj.Checksum != "" {
+		if b, err := hex.DecodeString(obj.Checksum); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("invalid checksum: %s", obj.Checksum)
+		}
+	}
+
 	return nil
 }
 
@@ -168,7 +194,7 @@ func (obj *Http) GetIterator() interfaces.Iterator { return obj.Iterator }
 func (obj *Http) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interfaces.Iterator, error) {
 	relDir := safepath.UnsafeParseIntoRelDir("http/")
 	prefix := safepath.JoinToAbsDir(obj.Prefix, relDir)
-	if err := os.MkdirAll(prefix.Path(), interfaces.Umask); err != nil {
+	if err := os.MkdirAll(prefix.Path(), obj.Perms.DirMode()); err != nil {
 		return nil, err
 	}
 
@@ -231,7 +257,7 @@ func (obj *Http) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inter
 	fullFileName := fullFileNameAbsFile.Path()
 
 	// make the dir we put the downloaded file into
-	if err := os.MkdirAll(httpAbsDir.Path(), interfaces.Umask); err != nil {
+	if err := os.MkdirAll(httpAbsDir.Path(), obj.Perms.DirMode()); err != nil {
 		obj.unlock()
 		return nil, err
 	}
@@ -242,7 +268,7 @@ func (obj *Http) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inter
 	}
 
 	// create blank file
-	file, err := os.Create(fullFileName)
+	file, err := os.OpenFile(fullFileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, obj.Perms.FileMode())
 	if err != nil {
 		obj.unlock()
 		return nil, errwrap.Wrapf(err, "error writing file %s", fullFileNameAbsFile)
@@ -269,7 +295,18 @@ func (obj *Http) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inter
 		CheckRedirect: nil,
 	}
 
-	// TODO: add a recurring progress logf if it takes longer than 30 sec
+	progress := &Progress{
+		Logf: obj.Logf,
+		Name: fmt.Sprintf("downloading %s", obj.URL),
+	}
+	release, err := obj.Limiter.Acquire(ctx)
+	if err != nil {
+		obj.unlock()
+		return nil, err
+	}
+	defer release()
+	progress.Start()
+	defer progress.Stop()
 	resp, err := client.Do(req)
 	if err != nil {
 		obj.unlock()
@@ -283,14 +320,26 @@ func (obj *Http) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inter
 		return nil, fmt.Errorf("bad status code of: %d", resp.StatusCode)
 	}
 
+	atomic.StoreInt64(&progress.Total, resp.ContentLength) // -1 if unknown
+	hash := sha256.New()
 	// FIXME: add a variant that can take a context
-	size, err := io.Copy(file, resp.Body)
+	size, err := io.Copy(io.MultiWriter(file, hash), io.TeeReader(obj.Limiter.Reader(ctx, u.Hostname(), resp.Body), progress))
 	if err != nil {
 		obj.unlock()
 		return nil, errwrap.Wrapf(err, "error writing our file to disk at %s", fullFileNameAbsFile)
 	}
 	obj.Logf("copied: %d bytes to disk at %s", size, fullFileNameAbsFile)
 
+	obj.Sum = hex.EncodeToString(hash.Sum(nil))
+	if obj.Checksum != "" && !strings.EqualFold(obj.Checksum, obj.Sum) {
+		// don't leave it around for anything else to pick up
+		if err := os.Remove(fullFileName); err != nil {
+			obj.Logf("could not remove %s: %+v", fullFileNameAbsFile, err)
+		}
+		obj.unlock()
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", obj.URL, obj.Checksum, obj.Sum)
+	}
+
 	obj.iterators = []interfaces.Iterator{}
 
 	if strings.HasPrefix(obj.URL, HttpScheme) {
@@ -320,7 +369,9 @@ func (obj *Http) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]inter
 		Logf: func(format string, v ...interface{}) {
 			obj.Logf(format, v...) // TODO: add a prefix?
 		},
-		Prefix: obj.Prefix,
+		Prefix:  obj.Prefix,
+		Perms:   obj.Perms,
+		Limiter: obj.Limiter,
 
 		Iterator: obj,
 
@@ -0,0 +1,98 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package iterator_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+func TestHttpChecksum(t *testing.T) {
+	data := []byte("not really a tarball")
+	sum := sha256.Sum256(data)
+	expected := hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	prefix, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	download := func(name, checksum string) (*iterator.Http, error) {
+		it := &iterator.Http{
+			Logf: func(format string, v ...interface{}) {
+				t.Logf("iterator: "+format, v...)
+			},
+			Prefix:    prefix,
+			URL:       server.URL + "/" + name,
+			AllowHttp: true,
+			Checksum:  checksum,
+		}
+		if err := it.Validate(); err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		defer it.Close()
+		_, err := it.Recurse(context.Background(), nil)
+		return it, err
+	}
+
+	it, err := download("good.tar.gz", "")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if it.Sum != expected {
+		t.Errorf("expected sum %s, got: %s", expected, it.Sum)
+	}
+
+	if _, err := download("good.tar.gz", expected); err != nil {
+		t.Errorf("error: %+v", err)
+	}
+
+	_, err = download("bad.tar.gz", strings.Repeat("0", 64))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected a checksum mismatch, got: %+v", err)
+	}
+
+	// the bad download must not be left around on disk
+	matches, err := filepath.Glob(filepath.Join(dir, "http", "*", "bad.tar.gz"))
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("expected the bad download to be removed, got: %+v", matches)
+	}
+}
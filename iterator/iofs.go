@@ -0,0 +1,191 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package iterator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+const (
+	// IOFSScheme is the standard prefix used for io/fs.FS path UID's.
+	IOFSScheme = "iofs://"
+)
+
+// IOFS is an iterator that scans an io/fs.FS filesystem. This can be an
+// in-memory filesystem, an embedded one, or any remote implementation. Nothing
+// is ever read from the local disk. The paths that are passed to the scan
+// function are absolute paths rooted at the top of the filesystem, and they are
+// never real paths on the local disk. As a result, only the DataBackends can
+// look at these files, since we pass the filesystem along in the Info struct.
+// TODO: This iterator could learn how to return new iterators for archives.
+type IOFS struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	// Parser is a pointer to the parser that returned this. If it wasn't
+	// returned by a parser, leave this nil. If this iterator came from an
+	// iterator, then the Iterator handle should be filled instead.
+	Parser interfaces.Parser
+
+	// Iterator is a pointer to the iterator that returned this. If it
+	// wasn't returned by an iterator, leave this nil. If this iterator came
+	// from a parser, then the Parser handle should be filled instead.
+	Iterator interfaces.Iterator
+
+	// FS is the filesystem to walk.
+	FS fs.FS
+
+	// Name is a human readable name for this filesystem. It is used to
+	// build the UID's, so it should be unique among the things you scan.
+	Name string
+
+	// GenUID takes the safe path that would have been used to build the UID
+	// and returns an improved UID that is more pleasantly human readable.
+	// Specifying this function is optional, but if it is used, it's not
+	// recommended to error unless there's a programming mistake, and you
+	// must be confident that your results will be properly unique.
+	GenUID func(safepath.Path) (string, error)
+}
+
+// String returns a human-readable representation of the filesystem we're
+// looking at. The output of this format is not guaranteed to be constant, so
+// don't try to parse it.
+func (obj *IOFS) String() string {
+	return fmt.Sprintf("iofs: %s", obj.Name)
+}
+
+// Validate runs some checks to ensure this iterator was built correctly.
+func (obj *IOFS) Validate() error {
+	if obj.Logf == nil {
+		return fmt.Errorf("the Logf function must be specified")
+	}
+	if obj.FS == nil {
+		return fmt.Errorf("the FS must be specified")
+	}
+	if obj.Name == "" {
+		return fmt.Errorf("the Name must be specified")
+	}
+	return nil
+}
+
+// GetParser returns a handle to the parent parser that built this iterator if
+// there is one.
+func (obj *IOFS) GetParser() interfaces.Parser { return obj.Parser }
+
+// GetIterator returns a handle to the parent iterator that built this iterator
+// if there is one.
+func (obj *IOFS) GetIterator() interfaces.Iterator { return obj.Iterator }
+
+// Recurse runs a simple recursive iterator that walks through the filesystem.
+// It applies a scan function to everything that it encounters.
+func (obj *IOFS) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interfaces.Iterator, error) {
+	obj.Logf("running %s", obj.String())
+
+	err := fs.WalkDir(obj.FS, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// prevent panic by handling failure accessing a path
+			return errwrap.Wrapf(err, "fail inside walk with: %s", name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return errwrap.Wrapf(ctx.Err(), "ended walk early")
+		default:
+		}
+
+		// skip symlinks
+		if d.Type()&fs.ModeSymlink == fs.ModeSymlink {
+			return nil
+		}
+
+		p := "/" + name
+		if name == "." {
+			p = "/"
+		}
+		safePath, err := safepath.ParseIntoPath(p, d.IsDir())
+		if err != nil {
+			return err
+		}
+
+		fileInfo, err := d.Info()
+		if err != nil {
+			return errwrap.Wrapf(err, "could not stat: %s", name)
+		}
+
+		// Skip iterating over certain paths.
+		if skip, err := SkipPath(safePath, fileInfo); skip || err != nil {
+			if obj.Debug && (skip || err == interfaces.SkipDir) {
+				obj.Logf("skipping: %s", safePath.String())
+			}
+			return err // nil to skip, interfaces.SkipDir, or error
+		}
+
+		uid := IOFSScheme + obj.Name + safePath.String() // the default
+		if obj.GenUID != nil {
+			var err error
+			uid, err = obj.GenUID(safePath)
+			if err != nil {
+				// probable programming error
+				return errwrap.Wrapf(err, "the GetUID func failed")
+			}
+		}
+		info := &interfaces.Info{
+			FileInfo: fileInfo,
+			UID:      uid,
+			FS:       obj.FS,
+		}
+		// We want to ignore the ErrUnknownLicense results, and error if
+		// we hit any actual errors that we should bubble upwards.
+		if err := scan(ctx, safePath, info); err != nil && !errors.Is(err, interfaces.ErrUnknownLicense) {
+			return errwrap.Wrapf(err, "scan func failed")
+		}
+
+		return nil
+	})
+
+	return nil, err
+}
+
+// Close shuts down the iterator and/or performs clean up after the Recurse
+// method has run. This must be called if you run Recurse.
+func (obj *IOFS) Close() error {
+	return nil
+}
+
+// IOFSName returns the name that should be used to read a path that was given
+// to a scan function by the IOFS iterator from the io/fs.FS that it came from.
+func IOFSName(path safepath.Path) string {
+	name := path.Path() // this is cleaned
+	if name == "/" {
+		return "."
+	}
+	return name[1:] // remove the leading slash
+}
@@ -26,6 +26,7 @@ package iterator
 import (
 	"fmt"
 	"io/fs"
+	"strings"
 
 	"github.com/awslabs/yesiscan/interfaces"
 	"github.com/awslabs/yesiscan/util/safepath"
@@ -45,7 +46,6 @@ var (
 		".jpeg",      // image format with weird naming
 		".jpg",       // image format
 		".ico",       // icon file format
-		".pdf",       // document format
 		".png",       // image format
 		".ppt",       // presentation format (microsoft)
 		".svg",       // image format
@@ -63,8 +63,30 @@ var (
 		".svn/",    // internal svn folder
 		//".eggs/", // python ??? directory
 	}
+
+	// LicenseFilePrefixes is the list of (upper case) file name prefixes
+	// that we consider to be a top-level license declaration for the
+	// directory that they are in. For example, this matches LICENSE,
+	// LICENSE.md, and COPYING.txt.
+	LicenseFilePrefixes = []string{
+		"LICENSE",
+		"LICENCE", // common misspelling (or british english)
+		"COPYING",
+	}
 )
 
+// IsLicenseName returns true if this file name looks like it is a LICENSE or
+// COPYING file. It should be the base name only, and not the whole path.
+func IsLicenseName(name string) bool {
+	name = strings.ToUpper(name)
+	for _, x := range LicenseFilePrefixes {
+		if strings.HasPrefix(name, x) {
+			return true
+		}
+	}
+	return false
+}
+
 // SkipPath takes an input path and file info struct, and returns whether we
 // should skip over it or not. To skip it, return true and no error. To skip a
 // directory, return interfaces.SkipDir as the error. Lastly, if anything goes
@@ -0,0 +1,279 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package iterator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gitclient "github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+func init() {
+	// The git clones don't let us pass in a client of our own, so this
+	// transport finds the Limiter for each request in its context instead.
+	transport := githttp.NewClient(&http.Client{
+		Transport: &limitTransport{
+			transport: http.DefaultTransport,
+		},
+	})
+	gitclient.InstallProtocol(HttpsSchemeRaw, transport)
+	gitclient.InstallProtocol(HttpSchemeRaw, transport)
+}
+
+// Limiter throttles the downloads of the http and git iterators, so that a
+// large scan doesn't saturate the network link that it runs on. It can limit
+// the total bandwidth, the bandwidth to each host, and the number of downloads
+// that run at the same time. The same Limiter must be shared by every iterator
+// that these limits should apply to. It also counts the bytes that go through
+// it, so that the progress of a scan can be shown. A nil Limiter doesn't limit
+// or count anything.
+type Limiter struct {
+	// downloaded is the running total. It's first so that it's aligned for
+	// the atomic operations on 32-bit platforms.
+	downloaded int64
+
+	// Rate is the maximum number of bytes per second that we download in
+	// total. If it is zero, then there is no limit.
+	Rate int64
+
+	// HostRate is the maximum number of bytes per second that we download
+	// from any one host. If it is zero, then there is no limit.
+	HostRate int64
+
+	// Downloads is the maximum number of downloads that can run at the same
+	// time. If it is zero, then there is no limit.
+	Downloads int
+
+	once  sync.Once
+	mutex sync.Mutex
+	slots chan struct{}
+	total *bucket
+	hosts map[string]*bucket
+}
+
+// Validate runs some checks to ensure this limiter was built correctly.
+func (obj *Limiter) Validate() error {
+	if obj == nil {
+		return nil
+	}
+	if obj.Rate < 0 {
+		return fmt.Errorf("the rate must not be negative")
+	}
+	if obj.HostRate < 0 {
+		return fmt.Errorf("the host rate must not be negative")
+	}
+	if obj.Downloads < 0 {
+		return fmt.Errorf("the number of downloads must not be negative")
+	}
+	return nil
+}
+
+// init builds the internal state the first time that it's needed.
+func (obj *Limiter) init() {
+	obj.once.Do(func() {
+		if obj.Downloads > 0 {
+			obj.slots = make(chan struct{}, obj.Downloads)
+		}
+		if obj.Rate > 0 {
+			obj.total = &bucket{rate: obj.Rate}
+		}
+		obj.hosts = make(map[string]*bucket)
+	})
+}
+
+// Acquire waits until another download is allowed to start. The returned
+// function must be called once that download has finished. It errors if the
+// context closes while we wait.
+func (obj *Limiter) Acquire(ctx context.Context) (func(), error) {
+	if obj == nil || obj.Downloads <= 0 {
+		return func() {}, nil
+	}
+	obj.init()
+	select {
+	case obj.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	once := &sync.Once{}
+	return func() {
+		once.Do(func() { <-obj.slots })
+	}, nil
+}
+
+// Downloaded returns the total number of bytes that have been read through all
+// of the readers of this limiter.
+func (obj *Limiter) Downloaded() int64 {
+	if obj == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&obj.downloaded)
+}
+
+// Reader returns a reader which reads from the input no faster than the limits
+// for this host allow, and which counts what it reads. If this is a nil Limiter,
+// then the input is returned as-is.
+func (obj *Limiter) Reader(ctx context.Context, host string, reader io.Reader) io.Reader {
+	if obj == nil {
+		return reader
+	}
+	reader = &countReader{
+		reader:  reader,
+		counter: &obj.downloaded,
+	}
+	if obj.Rate <= 0 && obj.HostRate <= 0 {
+		return reader
+	}
+	obj.init()
+	buckets := []*bucket{}
+	if obj.total != nil {
+		buckets = append(buckets, obj.total)
+	}
+	if obj.HostRate > 0 {
+		obj.mutex.Lock()
+		b, exists := obj.hosts[host]
+		if !exists {
+			b = &bucket{rate: obj.HostRate}
+			obj.hosts[host] = b
+		}
+		obj.mutex.Unlock()
+		buckets = append(buckets, b)
+	}
+	return &limitReader{
+		ctx:     ctx,
+		reader:  reader,
+		buckets: buckets,
+	}
+}
+
+// bucket paces the bytes that go through it to a fixed rate. Each read books
+// the next slot of time for its bytes, so that concurrent readers which share
+// a bucket split the rate between them.
+type bucket struct {
+	rate  int64 // bytes per second
+	mutex sync.Mutex
+	next  time.Time
+}
+
+// wait books the time for this many bytes, and blocks until it has passed.
+func (obj *bucket) wait(ctx context.Context, n int) error {
+	obj.mutex.Lock()
+	now := time.Now()
+	if obj.next.Before(now) {
+		obj.next = now // unused time isn't saved up for later
+	}
+	obj.next = obj.next.Add(time.Duration(int64(n) * int64(time.Second) / obj.rate))
+	d := obj.next.Sub(now)
+	obj.mutex.Unlock()
+
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// limitReader is the reader returned by Limiter.Reader.
+type limitReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	buckets []*bucket
+}
+
+// Read reads at most one second's worth of data for the slowest bucket, and
+// then waits until each bucket allows it.
+func (obj *limitReader) Read(p []byte) (int, error) {
+	for _, b := range obj.buckets {
+		if int64(len(p)) > b.rate {
+			p = p[:b.rate]
+		}
+	}
+	n, err := obj.reader.Read(p)
+	for _, b := range obj.buckets {
+		if e := b.wait(obj.ctx, n); e != nil {
+			return n, e
+		}
+	}
+	return n, err
+}
+
+// countReader adds the number of bytes that it reads to a counter.
+type countReader struct {
+	reader  io.Reader
+	counter *int64
+}
+
+// Read reads from the input and counts the bytes.
+func (obj *countReader) Read(p []byte) (int, error) {
+	n, err := obj.reader.Read(p)
+	atomic.AddInt64(obj.counter, int64(n))
+	return n, err
+}
+
+// limiterKey is the context key for the Limiter of a git clone.
+type limiterKey struct{}
+
+// withLimiter returns a context that the limitTransport will find this Limiter
+// in.
+func withLimiter(ctx context.Context, limiter *Limiter) context.Context {
+	return context.WithValue(ctx, limiterKey{}, limiter)
+}
+
+// limitTransport is the http transport that the git clones use. It throttles
+// each response body with the Limiter from the context of its request.
+type limitTransport struct {
+	transport http.RoundTripper
+}
+
+// RoundTrip runs a single http transaction.
+func (obj *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := obj.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	limiter, _ := req.Context().Value(limiterKey{}).(*Limiter)
+	resp.Body = &limitBody{
+		Reader: limiter.Reader(req.Context(), req.URL.Hostname(), resp.Body),
+		Closer: resp.Body,
+	}
+	return resp, nil
+}
+
+// limitBody is a throttled response body which still closes the original one.
+type limitBody struct {
+	io.Reader
+	io.Closer
+}
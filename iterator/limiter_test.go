@@ -0,0 +1,91 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package iterator_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/awslabs/yesiscan/iterator"
+)
+
+func TestLimiterReader(t *testing.T) {
+	limiter := &iterator.Limiter{
+		HostRate: 40 * 1024, // bytes per second
+	}
+	if err := limiter.Validate(); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	data := make([]byte, 10*1024)
+	start := time.Now()
+	ctx := context.Background()
+	b, err := io.ReadAll(limiter.Reader(ctx, "example.com", bytes.NewReader(data)))
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if len(b) != len(data) {
+		t.Errorf("expected %d bytes, got: %d", len(data), len(b))
+	}
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Errorf("read too fast, took: %s", d)
+	}
+
+	// another host has its own limit, so it doesn't wait on the first one
+	b, err = io.ReadAll(limiter.Reader(ctx, "example.org", bytes.NewReader(data[:1024])))
+	if err != nil || len(b) != 1024 {
+		t.Errorf("error: %+v", err)
+	}
+
+	if n := limiter.Downloaded(); n != int64(len(data)+1024) {
+		t.Errorf("expected %d bytes downloaded, got: %d", len(data)+1024, n)
+	}
+}
+
+func TestLimiterAcquire(t *testing.T) {
+	limiter := &iterator.Limiter{
+		Downloads: 1,
+	}
+	release, err := limiter.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.Acquire(ctx); err == nil {
+		t.Errorf("expected the second download to wait")
+	}
+	release()
+	release() // safe to call twice
+	if _, err := limiter.Acquire(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+	}
+
+	var nilLimiter *iterator.Limiter // nil doesn't limit anything
+	if _, err := nilLimiter.Acquire(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+	}
+}
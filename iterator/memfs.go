@@ -0,0 +1,154 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+package iterator
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// memFS is a read-only in-memory filesystem. The keys of the files map are the
+// slash separated paths of the regular files, and the directories are implied
+// by them.
+type memFS struct {
+	files   map[string][]byte
+	modTime time.Time
+}
+
+// Open returns the file or the implied directory with this name.
+func (obj *memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if data, exists := obj.files[name]; exists {
+		return &memFile{
+			Reader: bytes.NewReader(data),
+			info:   obj.info(name, int64(len(data)), 0644),
+		}, nil
+	}
+
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	children := make(map[string]*memInfo)
+	for x, data := range obj.files {
+		if !strings.HasPrefix(x, prefix) {
+			continue
+		}
+		child := strings.TrimPrefix(x, prefix)
+		if i := strings.Index(child, "/"); i >= 0 {
+			children[child[:i]] = obj.info(child[:i], 0, fs.ModeDir|0755)
+			continue
+		}
+		children[child] = obj.info(child, int64(len(data)), 0644)
+	}
+	if len(children) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	entries := []fs.DirEntry{}
+	for _, x := range children {
+		entries = append(entries, x)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return &memDir{
+		info:    obj.info(name, 0, fs.ModeDir|0755),
+		entries: entries,
+	}, nil
+}
+
+// info returns the file info of the file or directory with this name.
+func (obj *memFS) info(name string, size int64, mode fs.FileMode) *memInfo {
+	return &memInfo{
+		name:    path.Base(name),
+		size:    size,
+		mode:    mode,
+		modTime: obj.modTime,
+	}
+}
+
+// memInfo is the fs.FileInfo and the fs.DirEntry of a memFS file.
+type memInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (obj *memInfo) Name() string               { return obj.name }
+func (obj *memInfo) Size() int64                { return obj.size }
+func (obj *memInfo) Mode() fs.FileMode          { return obj.mode }
+func (obj *memInfo) ModTime() time.Time         { return obj.modTime }
+func (obj *memInfo) IsDir() bool                { return obj.mode.IsDir() }
+func (obj *memInfo) Sys() interface{}           { return nil }
+func (obj *memInfo) Type() fs.FileMode          { return obj.mode.Type() }
+func (obj *memInfo) Info() (fs.FileInfo, error) { return obj, nil }
+
+// memFile is an open regular file of a memFS.
+type memFile struct {
+	*bytes.Reader
+	info *memInfo
+}
+
+func (obj *memFile) Stat() (fs.FileInfo, error) { return obj.info, nil }
+func (obj *memFile) Close() error               { return nil }
+
+// memDir is an open directory of a memFS.
+type memDir struct {
+	info    *memInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (obj *memDir) Stat() (fs.FileInfo, error) { return obj.info, nil }
+func (obj *memDir) Close() error               { return nil }
+
+// Read fails, since a directory has no contents to read.
+func (obj *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: obj.info.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir returns the next n entries of the directory, or all of the remaining
+// ones if n <= 0. This follows the fs.ReadDirFile interface.
+func (obj *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := obj.entries[obj.offset:]
+	if n <= 0 {
+		obj.offset = len(obj.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	obj.offset += n
+	return remaining[:n], nil
+}
@@ -0,0 +1,168 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package iterator
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// ProgressInterval is how often a long running download, clone, or
+	// extraction logs a message about how it's doing. This lets the user
+	// tell the difference between a slow operation and a hang.
+	ProgressInterval = 30 * time.Second
+)
+
+// Progress counts the bytes that are written to it, and logs a heartbeat with
+// the total and the rate every interval until it is stopped. Nothing is logged
+// if the operation finishes before the first interval. Use it with something
+// like io.TeeReader so it sees all of the data as it goes by.
+type Progress struct {
+	// bytes is the running total. It's first so that it's aligned for the
+	// atomic operations on 32-bit platforms.
+	bytes int64
+
+	// Total is the number of bytes that we expect. If it is zero or less,
+	// then it is unknown, and no percentage is shown. If it is only known
+	// after Start, then it must be set with atomic.StoreInt64.
+	Total int64
+
+	Logf func(format string, v ...interface{})
+
+	// Name describes what we're doing, eg: "unzipping foo.zip".
+	Name string
+
+	// Interval is how often we log. If it is zero, then ProgressInterval
+	// is used.
+	Interval time.Duration
+
+	// Status is an optional function which returns some more information
+	// to add to each message.
+	Status func() string
+
+	start time.Time
+	done  chan struct{}
+	wg    *sync.WaitGroup
+	once  *sync.Once
+}
+
+// Start begins the heartbeat. You must call Stop when you are done.
+func (obj *Progress) Start() {
+	interval := obj.Interval
+	if interval <= 0 {
+		interval = ProgressInterval
+	}
+	obj.start = time.Now()
+	obj.done = make(chan struct{})
+	obj.wg = &sync.WaitGroup{}
+	obj.once = &sync.Once{}
+
+	obj.wg.Add(1)
+	go func() {
+		defer obj.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				obj.Logf("%s", obj.String())
+			case <-obj.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the heartbeat. It is safe to call this more than once.
+func (obj *Progress) Stop() {
+	obj.once.Do(func() { close(obj.done) })
+	obj.wg.Wait()
+}
+
+// Write counts the bytes and never fails.
+func (obj *Progress) Write(p []byte) (int, error) {
+	atomic.AddInt64(&obj.bytes, int64(len(p)))
+	return len(p), nil
+}
+
+// String returns the message that we log for each heartbeat.
+// TODO: change to human readable bytes
+func (obj *Progress) String() string {
+	elapsed := time.Since(obj.start)
+	n := atomic.LoadInt64(&obj.bytes)
+	total := atomic.LoadInt64(&obj.Total)
+	s := fmt.Sprintf("%s: still running after %s", obj.Name, elapsed.Round(time.Second))
+	if n > 0 || total > 0 {
+		s += fmt.Sprintf(", %d bytes", n)
+		if total > 0 {
+			s += fmt.Sprintf(" of %d (%d%%)", total, n*100/total)
+		}
+		if seconds := elapsed.Seconds(); seconds > 0 {
+			s += fmt.Sprintf(" at %d bytes/s", int64(float64(n)/seconds))
+		}
+	}
+	if obj.Status != nil {
+		if status := obj.Status(); status != "" {
+			s += fmt.Sprintf(" (%s)", status)
+		}
+	}
+	return s
+}
+
+// lastLine is a writer that remembers the last line of text written to it. It
+// is used to catch the progress messages that a git server sends us, which are
+// separated by carriage returns as they are meant for a terminal.
+type lastLine struct {
+	mu   sync.Mutex
+	line string
+	buf  string // the incomplete line so far
+}
+
+// Write stores the last complete line and never fails.
+func (obj *lastLine) Write(p []byte) (int, error) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	obj.buf += string(p)
+	if i := strings.LastIndexAny(obj.buf, "\r\n"); i != -1 {
+		lines := strings.FieldsFunc(obj.buf[:i], func(r rune) bool {
+			return r == '\r' || r == '\n'
+		})
+		if len(lines) > 0 {
+			obj.line = strings.TrimSpace(lines[len(lines)-1])
+		}
+		obj.buf = obj.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// String returns the last complete line.
+func (obj *lastLine) String() string {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	return obj.line
+}
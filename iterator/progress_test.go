@@ -0,0 +1,68 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package iterator_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awslabs/yesiscan/iterator"
+)
+
+func TestProgress(t *testing.T) {
+	mu := &sync.Mutex{}
+	logs := []string{}
+	progress := &iterator.Progress{
+		Logf: func(format string, v ...interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+			logs = append(logs, strings.TrimSpace(fmt.Sprintf(format, v...)))
+		},
+		Name:     "copying",
+		Interval: 10 * time.Millisecond,
+		Total:    8,
+	}
+	progress.Start()
+	if _, err := io.Copy(io.Discard, io.TeeReader(bytes.NewBufferString("abcd"), progress)); err != nil {
+		t.Errorf("error: %+v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	progress.Stop()
+	progress.Stop() // safe to call twice
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(logs) == 0 {
+		t.Errorf("expected at least one heartbeat")
+		return
+	}
+	if s := logs[len(logs)-1]; !strings.Contains(s, "copying: still running") || !strings.Contains(s, "4 bytes of 8 (50%)") {
+		t.Errorf("unexpected heartbeat: %s", s)
+	}
+}
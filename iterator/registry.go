@@ -0,0 +1,159 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package iterator
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+var (
+	registryMutex = &sync.Mutex{}
+	registry      = make(map[string]*Registration) // name -> registration
+	registryOrder = []string{}                     // names in the order they were registered
+)
+
+// Settings are the common fields that an iterator gets from whatever built it.
+// They are passed to the registered constructors.
+type Settings struct {
+	Debug  bool
+	Logf   func(format string, v ...interface{})
+	Prefix safepath.AbsDir
+
+	// Perms is the permission policy for everything that we write.
+	Perms *interfaces.Perms
+
+	// Limiter throttles the downloads.
+	Limiter *Limiter
+
+	// Offline forbids any network access.
+	Offline bool
+
+	// Parser is the parser that is building this iterator, if it is one.
+	Parser interfaces.Parser
+
+	// Iterator is the iterator that is building this iterator, if it is
+	// one.
+	Iterator interfaces.Iterator
+}
+
+// Registration describes an iterator that the parser and the fs iterator can
+// discover, so that a new fetcher or archive format can be added in its own
+// file, with an init function which calls Register, and without changing them.
+type Registration struct {
+	// Name is the unique name of the iterator.
+	Name string
+
+	// Schemes is the list of lower case URL schemes that the URL function
+	// builds iterators for, such as "s3".
+	Schemes []string
+
+	// Extensions is the list of lower case file extensions, including the
+	// leading dot, that the File function builds iterators for.
+	Extensions []string
+
+	// URL builds an iterator for an input with one of the Schemes. This is
+	// only used by the parser.
+	URL func(settings *Settings, u *url.URL) (interfaces.Iterator, error)
+
+	// File builds an iterator for a local file with one of the Extensions.
+	// The fs iterator uses this for every file that it walks through.
+	File func(settings *Settings, path safepath.AbsFile) (interfaces.Iterator, error)
+}
+
+// Register adds an iterator to the registry. It's usually called from an init
+// function. It panics if the registration is invalid, or if the name is already
+// taken, since that is a programming error.
+func Register(registration *Registration) {
+	if registration.Name == "" {
+		panic("iterator: registration has no name")
+	}
+	if len(registration.Schemes) > 0 && registration.URL == nil {
+		panic(fmt.Sprintf("iterator: %s has schemes but no URL function", registration.Name))
+	}
+	if len(registration.Extensions) > 0 && registration.File == nil {
+		panic(fmt.Sprintf("iterator: %s has extensions but no File function", registration.Name))
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if _, exists := registry[registration.Name]; exists {
+		panic(fmt.Sprintf("iterator: %s is already registered", registration.Name))
+	}
+	registry[registration.Name] = registration
+	registryOrder = append(registryOrder, registration.Name)
+}
+
+// Registered returns the sorted names of all the registered iterators.
+func Registered() []string {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	names := append([]string{}, registryOrder...)
+	sort.Strings(names)
+	return names
+}
+
+// LookupScheme returns the registered iterator which handles this URL scheme.
+// If more than one does, then the first one which was registered wins. It
+// returns nil if there isn't one.
+func LookupScheme(scheme string) *Registration {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	scheme = strings.ToLower(scheme)
+	for _, name := range registryOrder {
+		for _, x := range registry[name].Schemes {
+			if x == scheme {
+				return registry[name]
+			}
+		}
+	}
+	return nil
+}
+
+// LookupExtension returns the registered iterator which handles the extension
+// of this file name or URL. The longest matching extension wins, so that a
+// .tar.zst handler is picked over a .zst one, and then the first one which was
+// registered. It returns nil if there isn't one.
+func LookupExtension(name string) *Registration {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	name = strings.ToLower(name)
+	var found *Registration
+	length := 0
+	for _, n := range registryOrder {
+		for _, x := range registry[n].Extensions {
+			if strings.HasSuffix(name, x) && len(x) > length {
+				found = registry[n]
+				length = len(x)
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,78 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package iterator_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/util"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+func TestRegistry(t *testing.T) {
+	iterator.Register(&iterator.Registration{
+		Name:       "registrytest",
+		Schemes:    []string{"registrytest"},
+		Extensions: []string{".test.gz"},
+		URL: func(settings *iterator.Settings, u *url.URL) (interfaces.Iterator, error) {
+			return &iterator.Fs{Logf: settings.Logf, Parser: settings.Parser}, nil
+		},
+		File: func(settings *iterator.Settings, path safepath.AbsFile) (interfaces.Iterator, error) {
+			return &iterator.Fs{Logf: settings.Logf, Path: path, Iterator: settings.Iterator}, nil
+		},
+	})
+
+	for name, exp := range map[string]string{
+		"/a/b.zip":      "zip",
+		"/a/b.JAR":      "zip",
+		"/a/b.tar":      "tar",
+		"/a/b.tgz":      "gzip",
+		"/a/b.tar.bz2":  "bzip2",
+		"/a/b.gz":       "gzip",
+		"/a/b.test.gz":  "registrytest", // the longest extension wins
+		"/a/b.txt":      "",
+		"/a/b.zip/c.go": "",
+	} {
+		got := ""
+		if r := iterator.LookupExtension(name); r != nil {
+			got = r.Name
+		}
+		if got != exp {
+			t.Errorf("%s: expected %q, got %q", name, exp, got)
+		}
+	}
+
+	if r := iterator.LookupScheme("RegistryTest"); r == nil || r.Name != "registrytest" {
+		t.Errorf("expected the registrytest iterator, got: %+v", r)
+	}
+	if r := iterator.LookupScheme("nope"); r != nil {
+		t.Errorf("expected nothing, got: %+v", r)
+	}
+	if names := iterator.Registered(); !util.StrInList("registrytest", names) || !util.StrInList("zip", names) {
+		t.Errorf("unexpected names: %+v", names)
+	}
+}
@@ -26,14 +26,11 @@ package iterator
 import (
 	"archive/tar"
 	"context"
-	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/awslabs/yesiscan/interfaces"
 	"github.com/awslabs/yesiscan/util/errwrap"
@@ -53,6 +50,26 @@ var (
 func init() {
 	tarMapMutex = &sync.Mutex{}
 	tarMutexes = make(map[string]*sync.Mutex)
+
+	Register(&Registration{
+		Name:       "tar",
+		Extensions: []string{TarExtension},
+		File: func(settings *Settings, path safepath.AbsFile) (interfaces.Iterator, error) {
+			return &Tar{
+				Debug:   settings.Debug,
+				Logf:    settings.Logf,
+				Prefix:  settings.Prefix,
+				Perms:   settings.Perms,
+				Limiter: settings.Limiter,
+				Offline: settings.Offline,
+
+				Parser:   settings.Parser,
+				Iterator: settings.Iterator,
+
+				Path: path,
+			}, nil
+		},
+	})
 }
 
 // Tar is an iterator that takes a .tar URI to open and performs the un-tar
@@ -67,6 +84,18 @@ type Tar struct {
 	Logf   func(format string, v ...interface{})
 	Prefix safepath.AbsDir
 
+	// Perms is the permission policy for everything that we write. If it
+	// is nil, then the default policy is used.
+	Perms *interfaces.Perms
+
+	// Limiter throttles the downloads. It is shared with every iterator
+	// that we return. If it is nil, then nothing is throttled.
+	Limiter *Limiter
+
+	// Offline forbids any network access. It is passed down to every
+	// iterator that we return.
+	Offline bool
+
 	// Parser is a pointer to the parser that returned this. If it wasn't
 	// returned by a parser, leave this nil. If this iterator came from an
 	// iterator, then the Iterator handle should be filled instead.
@@ -165,20 +194,16 @@ func (obj *Tar) GetIterator() interfaces.Iterator { return obj.Iterator }
 func (obj *Tar) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interfaces.Iterator, error) {
 	relDir := safepath.UnsafeParseIntoRelDir("tar/")
 	prefix := safepath.JoinToAbsDir(obj.Prefix, relDir)
-	if err := os.MkdirAll(prefix.Path(), interfaces.Umask); err != nil {
+	if err := os.MkdirAll(prefix.Path(), obj.Perms.DirMode()); err != nil {
 		return nil, err
 	}
 
-	// make a unique ID for the directory
-	// XXX: we can consider different algorithms or methods here later...
-	now := strconv.FormatInt(time.Now().UnixMilli(), 10) // itoa but int64
-	sum := sha256.Sum256([]byte(obj.Path.Path() + now))
-	hashRelDir, err := safepath.ParseIntoRelDir(fmt.Sprintf("%x", sum))
+	// The directory is named after the archive, so that an unchanged one
+	// only gets extracted once, and is then reused by any later scans.
+	tarAbsDir, err := ExtractionDir(prefix, obj.Path)
 	if err != nil {
 		return nil, err
 	}
-	// ensure it gets put into a folder so it doesn't explode current dir
-	tarAbsDir := safepath.JoinToAbsDir(prefix, hashRelDir)
 
 	tarMapMutex.Lock()
 	mu, exists := tarMutexes[obj.Path.Path()]
@@ -205,8 +230,33 @@ func (obj *Tar) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 
 	// XXX: unlock when context closes?
 
-	// XXX: If the destination dir has contents, consider removing them
-	// first. This is one reason why we have a mutex.
+	// A previous run might have been interrupted part of the way through,
+	// so we only reuse the directory if it was marked as complete. This is
+	// one reason why we have a mutex.
+	complete, err := IsComplete(tarAbsDir)
+	if err != nil {
+		obj.unlock()
+		return nil, errwrap.Wrapf(err, "error checking %s", tarAbsDir)
+	}
+	if complete {
+		obj.Logf("already extracted %s into %s", obj.String(), tarAbsDir)
+		obj.iterators = []interfaces.Iterator{obj.fsIterator(tarAbsDir)}
+		return obj.iterators, nil
+	}
+
+	// Another process might be extracting the same archive, so we each
+	// extract into a directory of our own, and then move it into place.
+	stagingAbsDir, err := newStaging(tarAbsDir, obj.Perms)
+	if err != nil {
+		obj.unlock()
+		return nil, errwrap.Wrapf(err, "error preparing %s", tarAbsDir)
+	}
+	finished := false
+	defer func() {
+		if !finished {
+			os.RemoveAll(stagingAbsDir.Path()) // whatever we got done
+		}
+	}()
 
 	f, err := os.Open(obj.Path.Path())
 	if err != nil {
@@ -220,12 +270,24 @@ func (obj *Tar) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 	z := tar.NewReader(f)
 	//defer z.Close() // doesn't exist, magic happens in Next()!
 
+	caseNames, err := newCaseNames(prefix, obj.Logf)
+	if err != nil {
+		obj.unlock()
+		return nil, err
+	}
+
 	filesTotal := 0
 	bytesTotal := int64(0)
 	emptyTotal := 0
+	dirs := []*extracted{} // finished at the end once they're full
+	progress := &Progress{
+		Logf: obj.Logf,
+		Name: fmt.Sprintf("untar-ing %s", obj.Path),
+	}
+	progress.Start()
+	defer progress.Stop()
 	// Iterate through the files in the archive.
 	// XXX: can a child directory appear before a parent?
-	// TODO: add a recurring progress logf if it takes longer than 30 sec
 	for {
 		// In an effort to short-circuit things if needed, we run a
 		// check ourselves and break out early if we see that we have
@@ -303,6 +365,18 @@ func (obj *Tar) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 			}
 		}
 
+		if sanitized, err := SanitizeArchiveName(newName); err != nil {
+			obj.Logf("tar: skipping %q: %v", newName, err)
+			continue
+		} else if sanitized != newName {
+			obj.Logf("tar: renamed %q to: %s", newName, sanitized)
+			newName = sanitized
+		}
+		if n, renamed := caseNames.Claim(newName); renamed {
+			obj.Logf("tar: %s only differs by case from another name, extracting it to: %s", newName, n)
+			newName = n
+		}
+
 		fileInfo := header.FileInfo()
 		// TODO: obj.Debug ?
 
@@ -322,19 +396,24 @@ func (obj *Tar) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 			}
 
 			// this is where the new dir will be created
-			absDir := safepath.JoinToAbsDir(tarAbsDir, relDir)
+			absDir := safepath.JoinToAbsDir(stagingAbsDir, relDir)
 
 			// XXX: sanity check (is output in the dir?)
 			// TODO: we could add this, but safepath automatically does this
-			// if absDir is not inside of tarAbsDir then error
+			// if absDir is not inside of stagingAbsDir then error
 
-			// XXX: which mode method?
-			//if err := os.MkdirAll(absDir.Path(), fileInfo.Mode()); err != nil {
-			if err := os.MkdirAll(absDir.Path(), os.ModePerm); err != nil {
+			// We use the policy mode for now, and we set the
+			// sanitized mode from the archive once we're done.
+			if err := os.MkdirAll(absDir.Path(), obj.Perms.DirMode()); err != nil {
 				// programming error
 				obj.unlock()
 				return nil, err
 			}
+			dirs = append(dirs, &extracted{
+				path:  absDir.Path(),
+				mode:  ExtractMode(fileInfo.Mode(), true, obj.Perms),
+				mtime: header.ModTime,
+			})
 
 			continue
 		} else if header.Typeflag != tar.TypeReg {
@@ -362,26 +441,24 @@ func (obj *Tar) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 		}
 
 		// this is where the output file will be stored
-		absFile := safepath.JoinToAbsFile(tarAbsDir, relFile)
+		absFile := safepath.JoinToAbsFile(stagingAbsDir, relFile)
 
 		// XXX: sanity check (is output in the dir?)
 		// TODO: we could add this, but safepath automatically does this
-		// if absFile is not inside of tarAbsDir then error
+		// if absFile is not inside of stagingAbsDir then error
 
 		absDir := absFile.Dir() // get the absDir that absFile is in
 
-		// XXX: which mode to use? Maybe we are assuming a mode here
-		// because we haven't seen that dir yet! Maybe if we pre-sort
-		// all of the tar file entries first...
-		//if err := os.MkdirAll(absDir.Path(), x.Mode()); err != nil {
-		if err := os.MkdirAll(absDir.Path(), os.ModePerm); err != nil {
+		// We might not have seen this dir yet, so we use the policy
+		// mode. If it appears later on, it gets its own mode then.
+		if err := os.MkdirAll(absDir.Path(), obj.Perms.DirMode()); err != nil {
 			// programming error
 			obj.unlock()
 			return nil, err
 		}
 
 		// write to this location
-		dest, err := os.OpenFile(absFile.Path(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+		dest, err := os.OpenFile(absFile.Path(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, obj.Perms.FileMode())
 		if err != nil {
 			obj.unlock()
 			return nil, errwrap.Wrapf(err, "error writing our file to disk at %s", absFile)
@@ -390,7 +467,7 @@ func (obj *Tar) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 
 		// FIXME: use a variant that can take a context
 		// XXX: do we see ErrFieldTooLong here? (return IteratorError)
-		size, err := io.Copy(dest, z)
+		size, err := io.Copy(dest, io.TeeReader(z, progress))
 		if err != nil {
 			dest.Close() // close dest file on error!
 			obj.unlock()
@@ -400,33 +477,61 @@ func (obj *Tar) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 
 		dest.Close() // close dest file on error!
 
+		file := &extracted{
+			path:  absFile.Path(),
+			mode:  ExtractMode(fileInfo.Mode(), false, obj.Perms),
+			mtime: header.ModTime,
+		}
+		if err := file.finish(); err != nil {
+			obj.unlock()
+			return nil, errwrap.Wrapf(err, "error setting metadata on %s", absFile)
+		}
+
 		filesTotal++
 		bytesTotal += int64(size)
 	}
+	for _, x := range dirs {
+		if err := x.finish(); err != nil {
+			obj.unlock()
+			return nil, errwrap.Wrapf(err, "error setting metadata on %s", x.path)
+		}
+	}
 
 	// TODO: change to human readable bytes
 	obj.Logf("untar-ed: %d files from %s into %s (%d bytes)", filesTotal, obj.String(), tarAbsDir, bytesTotal)
 
-	obj.iterators = []interfaces.Iterator{}
+	if tarAbsDir, err = finishStaging(stagingAbsDir, tarAbsDir, obj.Perms, obj.Logf); err != nil {
+		obj.unlock()
+		return nil, errwrap.Wrapf(err, "error moving %s into place", stagingAbsDir)
+	}
+	finished = true
 
+	obj.iterators = []interfaces.Iterator{obj.fsIterator(tarAbsDir)}
+
+	return obj.iterators, nil
+}
+
+// fsIterator returns the iterator which scans the directory that we extracted
+// into.
+func (obj *Tar) fsIterator(dir safepath.AbsDir) interfaces.Iterator {
 	// if it's a single tar file we return an fs iterator and let the fs
 	// iterator sort that out...
-	iterator := &Fs{
+	return &Fs{
 		Debug: obj.Debug,
 		Logf: func(format string, v ...interface{}) {
 			obj.Logf(format, v...) // TODO: add a prefix?
 		},
-		Prefix: obj.Prefix,
+		Prefix:  obj.Prefix,
+		Perms:   obj.Perms,
+		Limiter: obj.Limiter,
+		Offline: obj.Offline,
 
 		Iterator: obj,
 
-		Path: tarAbsDir,
+		Path: dir,
 
 		//Unlock: unlock,
 	}
-	obj.iterators = append(obj.iterators, iterator)
-
-	return obj.iterators, nil
 }
 
 // Close shuts down the iterator and/or performs clean up after the Recurse
@@ -26,13 +26,10 @@ package iterator
 import (
 	"archive/zip"
 	"context"
-	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
-	"strconv"
 	"sync"
-	"time"
 
 	"github.com/awslabs/yesiscan/interfaces"
 	"github.com/awslabs/yesiscan/util/errwrap"
@@ -60,6 +57,33 @@ var (
 func init() {
 	zipMapMutex = &sync.Mutex{}
 	zipMutexes = make(map[string]*sync.Mutex)
+
+	Register(&Registration{
+		Name:       "zip",
+		Extensions: []string{ZipExtension, JarExtension, WhlExtension},
+		File: func(settings *Settings, path safepath.AbsFile) (interfaces.Iterator, error) {
+			return &Zip{
+				Debug:   settings.Debug,
+				Logf:    settings.Logf,
+				Prefix:  settings.Prefix,
+				Perms:   settings.Perms,
+				Limiter: settings.Limiter,
+				Offline: settings.Offline,
+
+				Parser:   settings.Parser,
+				Iterator: settings.Iterator,
+
+				Path: path,
+
+				//AllowAnyExtension: false, // not helpful here
+				AllowedExtensions: []string{
+					ZipExtension,
+					JarExtension,
+					WhlExtension,
+				},
+			}, nil
+		},
+	})
 }
 
 // Zip is an iterator that takes a .zip URI to open and performs the unzip
@@ -72,6 +96,18 @@ type Zip struct {
 	Logf   func(format string, v ...interface{})
 	Prefix safepath.AbsDir
 
+	// Perms is the permission policy for everything that we write. If it
+	// is nil, then the default policy is used.
+	Perms *interfaces.Perms
+
+	// Limiter throttles the downloads. It is shared with every iterator
+	// that we return. If it is nil, then nothing is throttled.
+	Limiter *Limiter
+
+	// Offline forbids any network access. It is passed down to every
+	// iterator that we return.
+	Offline bool
+
 	// Parser is a pointer to the parser that returned this. If it wasn't
 	// returned by a parser, leave this nil. If this iterator came from an
 	// iterator, then the Iterator handle should be filled instead.
@@ -172,20 +208,16 @@ func (obj *Zip) GetIterator() interfaces.Iterator { return obj.Iterator }
 func (obj *Zip) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interfaces.Iterator, error) {
 	relDir := safepath.UnsafeParseIntoRelDir("zip/")
 	prefix := safepath.JoinToAbsDir(obj.Prefix, relDir)
-	if err := os.MkdirAll(prefix.Path(), interfaces.Umask); err != nil {
+	if err := os.MkdirAll(prefix.Path(), obj.Perms.DirMode()); err != nil {
 		return nil, err
 	}
 
-	// make a unique ID for the directory
-	// XXX: we can consider different algorithms or methods here later...
-	now := strconv.FormatInt(time.Now().UnixMilli(), 10) // itoa but int64
-	sum := sha256.Sum256([]byte(obj.Path.Path() + now))
-	hashRelDir, err := safepath.ParseIntoRelDir(fmt.Sprintf("%x", sum))
+	// The directory is named after the archive, so that an unchanged one
+	// only gets extracted once, and is then reused by any later scans.
+	zipAbsDir, err := ExtractionDir(prefix, obj.Path)
 	if err != nil {
 		return nil, err
 	}
-	// ensure it gets put into a folder so it doesn't explode current dir
-	zipAbsDir := safepath.JoinToAbsDir(prefix, hashRelDir)
 
 	zipMapMutex.Lock()
 	mu, exists := zipMutexes[obj.Path.Path()]
@@ -212,8 +244,33 @@ func (obj *Zip) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 
 	// XXX: unlock when context closes?
 
-	// XXX: If the destination dir has contents, consider removing them
-	// first. This is one reason why we have a mutex.
+	// A previous run might have been interrupted part of the way through,
+	// so we only reuse the directory if it was marked as complete. This is
+	// one reason why we have a mutex.
+	complete, err := IsComplete(zipAbsDir)
+	if err != nil {
+		obj.unlock()
+		return nil, errwrap.Wrapf(err, "error checking %s", zipAbsDir)
+	}
+	if complete {
+		obj.Logf("already extracted %s into %s", obj.String(), zipAbsDir)
+		obj.iterators = []interfaces.Iterator{obj.fsIterator(zipAbsDir)}
+		return obj.iterators, nil
+	}
+
+	// Another process might be extracting the same archive, so we each
+	// extract into a directory of our own, and then move it into place.
+	stagingAbsDir, err := newStaging(zipAbsDir, obj.Perms)
+	if err != nil {
+		obj.unlock()
+		return nil, errwrap.Wrapf(err, "error preparing %s", zipAbsDir)
+	}
+	finished := false
+	defer func() {
+		if !finished {
+			os.RemoveAll(stagingAbsDir.Path()) // whatever we got done
+		}
+	}()
 
 	// Open the zip archive for reading.
 	// FIXME: use a variant that can take a context
@@ -238,11 +295,26 @@ func (obj *Zip) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 		obj.Logf("zip has comment: %s", z.Comment)
 	}
 
+	caseNames, err := newCaseNames(prefix, obj.Logf)
+	if err != nil {
+		obj.unlock()
+		return nil, err
+	}
+
 	filesTotal := 0
 	bytesTotal := int64(0)
+	dirs := []*extracted{} // finished at the end once they're full
+	progress := &Progress{
+		Logf: obj.Logf,
+		Name: fmt.Sprintf("unzipping %s", obj.Path),
+	}
+	for _, x := range z.File {
+		progress.Total += int64(x.UncompressedSize64)
+	}
+	progress.Start()
+	defer progress.Stop()
 	// Iterate through the files in the archive.
 	// XXX: can a child directory appear before a parent?
-	// TODO: add a recurring progress logf if it takes longer than 30 sec
 	for _, x := range z.File {
 		// In an effort to short-circuit things if needed, we run a
 		// check ourselves and break out early if we see that we have
@@ -257,8 +329,21 @@ func (obj *Zip) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 		// TODO: obj.Debug ?
 		obj.Logf("zip: %s", x.Name)
 
+		name, err := SanitizeArchiveName(x.Name)
+		if err != nil {
+			obj.Logf("zip: skipping %q: %v", x.Name, err)
+			continue
+		}
+		if name != x.Name {
+			obj.Logf("zip: renamed %q to: %s", x.Name, name)
+		}
+		if n, renamed := caseNames.Claim(name); renamed {
+			obj.Logf("zip: %s only differs by case from another name, extracting it to: %s", name, n)
+			name = n
+		}
+
 		if x.FileInfo().IsDir() {
-			relDir, err := safepath.ParseIntoRelDir(x.Name)
+			relDir, err := safepath.ParseIntoRelDir(name)
 			if err != nil {
 				// programming error
 				obj.unlock()
@@ -266,24 +351,29 @@ func (obj *Zip) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 			}
 
 			// this is where the new dir will be created
-			absDir := safepath.JoinToAbsDir(zipAbsDir, relDir)
+			absDir := safepath.JoinToAbsDir(stagingAbsDir, relDir)
 
 			// XXX: sanity check (is output in the dir?)
 			// TODO: we could add this, but safepath automatically does this
-			// if absDir is not inside of zipAbsDir then error
+			// if absDir is not inside of stagingAbsDir then error
 
-			// XXX: which mode method?
-			//if err := os.MkdirAll(absDir.Path(), x.Mode()); err != nil {
-			if err := os.MkdirAll(absDir.Path(), os.ModePerm); err != nil {
+			// We use the policy mode for now, and we set the
+			// sanitized mode from the archive once we're done.
+			if err := os.MkdirAll(absDir.Path(), obj.Perms.DirMode()); err != nil {
 				// programming error
 				obj.unlock()
 				return nil, err
 			}
+			dirs = append(dirs, &extracted{
+				path:  absDir.Path(),
+				mode:  ExtractMode(x.Mode(), true, obj.Perms),
+				mtime: x.FileInfo().ModTime(),
+			})
 
 			continue
 		}
 
-		relFile, err := safepath.ParseIntoRelFile(x.Name)
+		relFile, err := safepath.ParseIntoRelFile(name)
 		if err != nil {
 			// programming error
 			obj.unlock()
@@ -291,28 +381,24 @@ func (obj *Zip) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 		}
 
 		// this is where the output file will be stored
-		absFile := safepath.JoinToAbsFile(zipAbsDir, relFile)
+		absFile := safepath.JoinToAbsFile(stagingAbsDir, relFile)
 
 		// XXX: sanity check (is output in the dir?)
 		// TODO: we could add this, but safepath automatically does this
-		// if absFile is not inside of zipAbsDir then error
+		// if absFile is not inside of stagingAbsDir then error
 
 		absDir := absFile.Dir() // get the absDir that absFile is in
 
-		// XXX: which mode to use? Maybe we are assuming a mode here
-		// because we haven't seen that dir yet! Maybe if we pre-sort
-		// all of the zip file entries first...
-		//if err := os.MkdirAll(absDir.Path(), x.Mode()); err != nil {
-		if err := os.MkdirAll(absDir.Path(), os.ModePerm); err != nil {
+		// We might not have seen this dir yet, so we use the policy
+		// mode. If it appears later on, it gets its own mode then.
+		if err := os.MkdirAll(absDir.Path(), obj.Perms.DirMode()); err != nil {
 			// programming error
 			obj.unlock()
 			return nil, err
 		}
 
 		// write to this location
-		// XXX: which mode method?
-		//dest, err := os.OpenFile(absFile.Path(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, x.Mode())
-		dest, err := os.OpenFile(absFile.Path(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+		dest, err := os.OpenFile(absFile.Path(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, obj.Perms.FileMode())
 		if err != nil {
 			obj.unlock()
 			return nil, errwrap.Wrapf(err, "error writing our file to disk at %s", absFile)
@@ -328,7 +414,7 @@ func (obj *Zip) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 		// don't `defer` close here because we want to free in the loop
 
 		// FIXME: use a variant that can take a context
-		size, err := io.Copy(dest, f)
+		size, err := io.Copy(dest, io.TeeReader(f, progress))
 		if err != nil {
 			f.Close()    // close file on error!
 			dest.Close() // close dest file on error!
@@ -340,33 +426,61 @@ func (obj *Zip) Recurse(ctx context.Context, scan interfaces.ScanFunc) ([]interf
 		f.Close()    // close on success to save memory!
 		dest.Close() // close dest file on error!
 
+		file := &extracted{
+			path:  absFile.Path(),
+			mode:  ExtractMode(x.Mode(), false, obj.Perms),
+			mtime: x.FileInfo().ModTime(),
+		}
+		if err := file.finish(); err != nil {
+			obj.unlock()
+			return nil, errwrap.Wrapf(err, "error setting metadata on %s", absFile)
+		}
+
 		filesTotal++
 		bytesTotal += int64(size)
 	}
+	for _, x := range dirs {
+		if err := x.finish(); err != nil {
+			obj.unlock()
+			return nil, errwrap.Wrapf(err, "error setting metadata on %s", x.path)
+		}
+	}
 
 	// TODO: change to human readable bytes
 	obj.Logf("unzipped: %d files from %s into %s (%d bytes)", filesTotal, obj.String(), zipAbsDir, bytesTotal)
 
-	obj.iterators = []interfaces.Iterator{}
+	if zipAbsDir, err = finishStaging(stagingAbsDir, zipAbsDir, obj.Perms, obj.Logf); err != nil {
+		obj.unlock()
+		return nil, errwrap.Wrapf(err, "error moving %s into place", stagingAbsDir)
+	}
+	finished = true
+
+	obj.iterators = []interfaces.Iterator{obj.fsIterator(zipAbsDir)}
 
+	return obj.iterators, nil
+}
+
+// fsIterator returns the iterator which scans the directory that we extracted
+// into.
+func (obj *Zip) fsIterator(dir safepath.AbsDir) interfaces.Iterator {
 	// if it's a single zip file we return an fs iterator and let the fs
 	// iterator sort that out...
-	iterator := &Fs{
+	return &Fs{
 		Debug: obj.Debug,
 		Logf: func(format string, v ...interface{}) {
 			obj.Logf(format, v...) // TODO: add a prefix?
 		},
-		Prefix: obj.Prefix,
+		Prefix:  obj.Prefix,
+		Perms:   obj.Perms,
+		Limiter: obj.Limiter,
+		Offline: obj.Offline,
 
 		Iterator: obj,
 
-		Path: zipAbsDir,
+		Path: dir,
 
 		//Unlock: unlock,
 	}
-	obj.iterators = append(obj.iterators, iterator)
-
-	return obj.iterators, nil
 }
 
 // Close shuts down the iterator and/or performs clean up after the Recurse
@@ -0,0 +1,346 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AggregateRepo is the roll-up of every stored output for a single repo, or
+// more precisely, for a single input argument.
+type AggregateRepo struct {
+	// Repo is the input argument that was scanned.
+	Repo string
+
+	// Files is the number of distinct UID's that had any result.
+	Files int
+
+	// Errors is the number of distinct UID's that had a scanning error.
+	Errors int
+
+	// Licenses is the number of files that each license was found in.
+	Licenses map[string]int
+
+	// Violations is the number of files that matched each profile.
+	Violations map[string]int
+
+	// Verdicts is the worst verdict seen for each profile.
+	Verdicts map[string]string
+
+	uids       map[string]map[string]struct{} // uid -> license
+	errors     map[string]struct{}            // uid
+	violations map[string]map[string]struct{} // profile -> uid
+}
+
+// Aggregate merges many stored outputs into a single roll-up, such as when many
+// repos get scanned overnight and we want to see them all at once. The same uid
+// seen in more than one output is only counted once, so a rescan of one of the
+// repos doesn't inflate its numbers.
+type Aggregate struct {
+	// Sources is the list of stored outputs that were merged.
+	Sources []string
+
+	repos map[string]*AggregateRepo
+}
+
+// Add merges a stored output into the aggregate. The source is normally the
+// path it was loaded from. Results that can't be attributed to any input
+// argument are counted under the source instead.
+func (obj *Aggregate) Add(source string, output *JSONOutput) {
+	if obj.repos == nil {
+		obj.repos = make(map[string]*AggregateRepo)
+	}
+	obj.Sources = append(obj.Sources, source)
+
+	artifact := func(a string) string {
+		if a != "" {
+			return a
+		}
+		if len(output.Args) == 1 {
+			return output.Args[0]
+		}
+		return source
+	}
+
+	for uid, m := range output.Results {
+		repo := obj.repo(artifact(output.Artifacts[uid]))
+		if _, exists := repo.uids[uid]; !exists {
+			repo.uids[uid] = make(map[string]struct{})
+		}
+		for _, result := range m {
+			if result.Skip != "" {
+				repo.errors[uid] = struct{}{}
+			}
+			for _, license := range result.Licenses {
+				repo.uids[uid][license.String()] = struct{}{}
+			}
+		}
+	}
+
+	for _, x := range output.Verdicts {
+		repo := obj.repo(artifact(x.Artifact))
+		if _, exists := repo.violations[x.Profile]; !exists {
+			repo.violations[x.Profile] = make(map[string]struct{})
+		}
+		for _, uid := range x.Violations {
+			repo.violations[x.Profile][uid] = struct{}{}
+		}
+		if verdictRank(x.Verdict) >= verdictRank(repo.Verdicts[x.Profile]) {
+			repo.Verdicts[x.Profile] = x.Verdict
+		}
+	}
+}
+
+// repo returns the entry for this repo, and builds it if it doesn't exist yet.
+func (obj *Aggregate) repo(name string) *AggregateRepo {
+	if repo, exists := obj.repos[name]; exists {
+		return repo
+	}
+	repo := &AggregateRepo{
+		Repo:       name,
+		Verdicts:   make(map[string]string),
+		uids:       make(map[string]map[string]struct{}),
+		errors:     make(map[string]struct{}),
+		violations: make(map[string]map[string]struct{}),
+	}
+	obj.repos[name] = repo
+	return repo
+}
+
+// verdictRank orders the verdicts from best to worst.
+func verdictRank(verdict string) int {
+	switch verdict {
+	case VerdictPass:
+		return 1
+	case VerdictWarn:
+		return 2
+	case VerdictFail:
+		return 3
+	}
+	return 0
+}
+
+// Repos returns the roll-up of each repo, sorted by name.
+func (obj *Aggregate) Repos() []*AggregateRepo {
+	names := []string{}
+	for name := range obj.repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	repos := []*AggregateRepo{}
+	for _, name := range names {
+		repo := obj.repos[name]
+		repo.Files = len(repo.uids)
+		repo.Errors = len(repo.errors)
+		repo.Licenses = make(map[string]int)
+		for _, m := range repo.uids {
+			for license := range m {
+				repo.Licenses[license]++
+			}
+		}
+		repo.Violations = make(map[string]int)
+		for profile, m := range repo.violations {
+			repo.Violations[profile] = len(m)
+		}
+		repos = append(repos, repo)
+	}
+	return repos
+}
+
+// Profiles returns the sorted list of every profile seen.
+func (obj *Aggregate) Profiles() []string {
+	m := make(map[string]struct{})
+	for _, repo := range obj.repos {
+		for profile := range repo.Verdicts {
+			m[profile] = struct{}{}
+		}
+	}
+	profiles := []string{}
+	for profile := range m {
+		profiles = append(profiles, profile)
+	}
+	sort.Strings(profiles)
+	return profiles
+}
+
+// Containing returns a map of license to the sorted list of repos that contain
+// it. If no licenses are specified, then every license that was found is used.
+func (obj *Aggregate) Containing(licenses []string) map[string][]string {
+	containing := make(map[string][]string)
+	for _, license := range licenses {
+		containing[license] = []string{}
+	}
+	for _, repo := range obj.Repos() { // sorted
+		for license := range repo.Licenses {
+			if _, exists := containing[license]; !exists && len(licenses) > 0 {
+				continue
+			}
+			containing[license] = append(containing[license], repo.Repo)
+		}
+	}
+	return containing
+}
+
+// Histogram returns the licenses of this repo, sorted by the number of files
+// that they were found in, and then by name.
+func (obj *AggregateRepo) Histogram() []string {
+	licenses := []string{}
+	for license := range obj.Licenses {
+		licenses = append(licenses, license)
+	}
+	sort.Slice(licenses, func(i, j int) bool {
+		a, b := obj.Licenses[licenses[i]], obj.Licenses[licenses[j]]
+		if a != b {
+			return a > b
+		}
+		return licenses[i] < licenses[j]
+	})
+	return licenses
+}
+
+// ReadJSONOutput loads a stored output that was written with the json output
+// type.
+func ReadJSONOutput(path string) (*JSONOutput, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	output := &JSONOutput{}
+	if err := json.Unmarshal(b, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// ReturnAggregateCSV returns the aggregate as csv. There is one row per fact,
+// and each has the repo, the kind of fact, its name, a count, and a verdict.
+// The kinds are "files" and "errors" which have no name, "license" which is
+// named after the license and counts files, and "profile" which is named after
+// the profile and counts violations.
+func ReturnAggregateCSV(aggregate *Aggregate) (string, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	records := [][]string{{"repo", "kind", "name", "count", "verdict"}}
+	profiles := aggregate.Profiles()
+	for _, repo := range aggregate.Repos() {
+		records = append(records, []string{repo.Repo, "files", "", strconv.Itoa(repo.Files), ""})
+		records = append(records, []string{repo.Repo, "errors", "", strconv.Itoa(repo.Errors), ""})
+		for _, license := range repo.Histogram() {
+			records = append(records, []string{repo.Repo, "license", license, strconv.Itoa(repo.Licenses[license]), ""})
+		}
+		for _, profile := range profiles {
+			verdict, exists := repo.Verdicts[profile]
+			if !exists {
+				continue
+			}
+			records = append(records, []string{repo.Repo, "profile", profile, strconv.Itoa(repo.Violations[profile]), verdict})
+		}
+	}
+	if err := w.WriteAll(records); err != nil { // flushes
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ReturnAggregateHtml returns the aggregate as a standalone html page. It has a
+// table of the verdicts and violation counts of each repo, the license
+// histogram of each repo, and the repos that contain each of the licenses. If
+// licenses are specified, then only those are shown in the last table.
+func ReturnAggregateHtml(aggregate *Aggregate, licenses []string) (string, error) {
+	repos := aggregate.Repos()
+	profiles := aggregate.Profiles()
+
+	s := "<!DOCTYPE html>\n"
+	s += `<html><head><meta charset="utf-8"><title>aggregate report</title>`
+	s += "<style>table { border-collapse: collapse; margin-bottom: 2em; } th, td { border: 1px solid #ccc; padding: 0.2em 0.5em; text-align: left; vertical-align: top; }</style>"
+	s += "</head><body>"
+	s += fmt.Sprintf("<h1>aggregate report</h1><p>%d repos from %d outputs</p>", len(repos), len(aggregate.Sources))
+
+	s += `<h2>repos</h2><table id="repos">`
+	s += "<tr><th>repo</th><th>files</th><th>errors</th>"
+	for _, p := range profiles {
+		s += fmt.Sprintf("<th>%s</th>", html.EscapeString(p))
+	}
+	s += "</tr>"
+	for _, repo := range repos {
+		s += fmt.Sprintf("<tr><td>%s</td><td>%d</td><td>%d</td>", html.EscapeString(repo.Repo), repo.Files, repo.Errors)
+		for _, p := range profiles {
+			verdict, exists := repo.Verdicts[p]
+			if !exists {
+				s += "<td></td>"
+				continue
+			}
+			c := "green"
+			if verdict == VerdictWarn {
+				c = "orange"
+			}
+			if verdict == VerdictFail {
+				c = "red"
+			}
+			s += fmt.Sprintf(`<td><span style="color: %s;">%s</span> (%d)</td>`, c, html.EscapeString(verdict), repo.Violations[p])
+		}
+		s += "</tr>"
+	}
+	s += "</table>"
+
+	s += `<h2>licenses</h2><table id="licenses">`
+	s += "<tr><th>repo</th><th>licenses (files)</th></tr>"
+	for _, repo := range repos {
+		histogram := []string{}
+		for _, license := range repo.Histogram() {
+			histogram = append(histogram, fmt.Sprintf("%s (%d)", html.EscapeString(license), repo.Licenses[license]))
+		}
+		s += fmt.Sprintf("<tr><td>%s</td><td>%s</td></tr>", html.EscapeString(repo.Repo), strings.Join(histogram, ", "))
+	}
+	s += "</table>"
+
+	containing := aggregate.Containing(licenses)
+	keys := []string{}
+	for license := range containing {
+		keys = append(keys, license)
+	}
+	sort.Strings(keys)
+	s += `<h2>repos containing</h2><table id="containing">`
+	s += "<tr><th>license</th><th>repos</th></tr>"
+	for _, license := range keys {
+		names := []string{}
+		for _, x := range containing[license] {
+			names = append(names, html.EscapeString(x))
+		}
+		s += fmt.Sprintf("<tr><td>%s</td><td>%s</td></tr>", html.EscapeString(license), strings.Join(names, "<br />"))
+	}
+	s += "</table>"
+
+	s += "</body></html>\n"
+	return s, nil
+}
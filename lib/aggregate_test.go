@@ -0,0 +1,110 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestAggregate(t *testing.T) {
+	mit := &licenses.License{SPDX: "MIT"}
+	apache := &licenses.License{SPDX: "Apache-2.0"}
+	gpl := &licenses.License{SPDX: "GPL-2.0-only"}
+
+	// one output with two repos, and then a rescan of the second one
+	first := &lib.JSONOutput{
+		Args: []string{"repo1", "repo2"},
+		Results: map[string]map[string]*lib.JSONResult{
+			"repo1/a": {"spdx": {Licenses: []*licenses.License{mit}}, "askalono": {Licenses: []*licenses.License{mit}}},
+			"repo1/b": {"spdx": {Licenses: []*licenses.License{mit, apache}}},
+			"repo2/a": {"spdx": {Licenses: []*licenses.License{gpl}}},
+			"repo2/b": {"scancode": {Licenses: []*licenses.License{}, Skip: "timed out"}},
+		},
+		Artifacts: map[string]string{
+			"repo1/a": "repo1",
+			"repo1/b": "repo1",
+			"repo2/a": "repo2",
+			"repo2/b": "repo2",
+		},
+		Verdicts: []*lib.Verdict{
+			{Artifact: "repo1", Profile: "strict", Verdict: lib.VerdictPass},
+			{Artifact: "repo2", Profile: "strict", Verdict: lib.VerdictFail, Violations: []string{"repo2/a"}},
+		},
+	}
+	second := &lib.JSONOutput{
+		Args: []string{"repo2"}, // no artifacts, so this is used
+		Results: map[string]map[string]*lib.JSONResult{
+			"repo2/a": {"spdx": {Licenses: []*licenses.License{gpl}}},
+		},
+		Verdicts: []*lib.Verdict{
+			{Artifact: "repo2", Profile: "strict", Verdict: lib.VerdictWarn, Violations: []string{"repo2/a"}},
+		},
+	}
+
+	aggregate := &lib.Aggregate{}
+	aggregate.Add("first.json", first)
+	aggregate.Add("second.json", second)
+
+	repos := aggregate.Repos()
+	if len(repos) != 2 {
+		t.Errorf("expected 2 repos, got: %d", len(repos))
+		return
+	}
+	if exp := map[string]int{"MIT": 2, "Apache-2.0": 1}; !reflect.DeepEqual(repos[0].Licenses, exp) {
+		t.Errorf("expected %v, got: %v", exp, repos[0].Licenses)
+	}
+	if exp := []string{"MIT", "Apache-2.0"}; !reflect.DeepEqual(repos[0].Histogram(), exp) {
+		t.Errorf("expected %v, got: %v", exp, repos[0].Histogram())
+	}
+	if repos[1].Files != 2 || repos[1].Errors != 1 {
+		t.Errorf("expected 2 files and 1 error, got: %d and %d", repos[1].Files, repos[1].Errors)
+	}
+	if v := repos[1].Violations["strict"]; v != 1 {
+		t.Errorf("expected the violation to be counted once, got: %d", v)
+	}
+	if v := repos[1].Verdicts["strict"]; v != lib.VerdictFail {
+		t.Errorf("expected the worst verdict, got: %s", v)
+	}
+
+	containing := aggregate.Containing([]string{"MIT", "0BSD"})
+	if exp := map[string][]string{"MIT": {"repo1"}, "0BSD": {}}; !reflect.DeepEqual(containing, exp) {
+		t.Errorf("expected %v, got: %v", exp, containing)
+	}
+
+	s, err := lib.ReturnAggregateCSV(aggregate)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	for _, x := range []string{"repo,kind,name,count,verdict\n", "repo1,license,MIT,2,\n", "repo2,profile,strict,1,fail\n"} {
+		if !strings.Contains(s, x) {
+			t.Errorf("expected csv to contain %q, got:\n%s", x, s)
+		}
+	}
+}
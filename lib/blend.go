@@ -0,0 +1,139 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+)
+
+const (
+	// BlendLinear is the original blending method. Each confidence value is
+	// scaled by the weight of its backend, over the total weight of all the
+	// backends that returned a result for that path.
+	BlendLinear = "linear"
+
+	// BlendMax takes the highest confidence value of any of the backends.
+	// This doesn't penalize a path that only one backend had an opinion on.
+	BlendMax = "max"
+
+	// BlendAgreement is a Bayesian-style agreement boost. The backends are
+	// grouped by the set of licenses that they found. The confidences within
+	// each group are combined as if they were independent pieces of
+	// evidence, so that backends which agree increase the confidence. This
+	// is then scaled by the fraction of the total weight in that group, and
+	// the group with the highest value wins.
+	BlendAgreement = "agreement"
+
+	// DefaultBlend is the blending method that is used if none is chosen.
+	DefaultBlend = BlendLinear
+)
+
+// Blends is the list of valid blending methods.
+var Blends = []string{
+	BlendLinear,
+	BlendMax,
+	BlendAgreement,
+}
+
+// ValidateBlend returns an error if the blending method is not a valid one. An
+// empty blend is valid and means the default.
+func ValidateBlend(blend string) error {
+	if blend == "" {
+		return nil
+	}
+	for _, x := range Blends {
+		if blend == x {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid blend: %s, must be one of: %s", blend, strings.Join(Blends, ", "))
+}
+
+// BlendConfidence combines the confidence values of each backend at a path into
+// a single value using the chosen method. The annotated backends must already
+// contain the weights, and the ScaledConfidence field will be set on each one.
+func BlendConfidence(blend string, bs []*AnnotatedBackend, m map[interfaces.Backend]*interfaces.Result) float64 {
+	ttl := 0.0 // total weight for the set of backends at this uri
+	for _, b := range bs {
+		ttl += b.Weight
+	}
+	if ttl == 0 {
+		return 0.0
+	}
+
+	f := 0.0 // NOTE: confidence *if* the different results agree!
+	for _, b := range bs {
+		result := m[b.Backend]
+		scale := b.Weight / ttl
+		b.ScaledConfidence = result.Confidence * scale
+		f = f + b.ScaledConfidence
+	}
+
+	switch blend {
+	case BlendMax:
+		f = 0.0
+		for _, b := range bs {
+			if c := m[b.Backend].Confidence; c > f {
+				f = c
+			}
+		}
+
+	case BlendAgreement:
+		groups := make(map[string][]*AnnotatedBackend)
+		for _, b := range bs {
+			ids := []string{}
+			for _, x := range m[b.Backend].Licenses {
+				ids = append(ids, x.String())
+			}
+			sort.Strings(ids)
+			key := strings.Join(ids, ", ")
+			groups[key] = append(groups[key], b)
+		}
+		keys := []string{}
+		for k := range groups {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys) // deterministic tie breaking
+
+		best := 0.0
+		for _, k := range keys {
+			weight := 0.0
+			doubt := 1.0 // probability that they're all wrong
+			for _, b := range groups[k] {
+				weight += b.Weight
+				doubt *= 1.0 - m[b.Backend].Confidence
+			}
+			if c := (1.0 - doubt) * weight / ttl; c > best {
+				best = c
+			}
+		}
+		f = best
+	}
+
+	return f
+}
@@ -0,0 +1,62 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+type testBackend string
+
+func (obj testBackend) String() string { return string(obj) }
+
+func TestBlendConfidence(t *testing.T) {
+	mit := []*licenses.License{{SPDX: "MIT"}}
+	b1, b2 := testBackend("b1"), testBackend("b2")
+	m := map[interfaces.Backend]*interfaces.Result{
+		b1: {Licenses: mit, Confidence: 0.8},
+		b2: {Licenses: mit, Confidence: 0.5},
+	}
+	tests := map[string]float64{
+		lib.BlendLinear:    0.8*0.25 + 0.5*0.75,
+		lib.BlendMax:       0.8,
+		lib.BlendAgreement: 1.0 - 0.2*0.5,
+	}
+	for blend, exp := range tests {
+		bs := []*lib.AnnotatedBackend{
+			{Backend: b1, Weight: 1.0},
+			{Backend: b2, Weight: 3.0},
+		}
+		if f := lib.BlendConfidence(blend, bs, m); math.Abs(f-exp) > 1e-9 {
+			t.Errorf("blend: %s", blend)
+			t.Errorf("exp: %f", exp)
+			t.Errorf("got: %f", f)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"context"
+	"sync"
+)
+
+// Budget is a counting semaphore that is measured in bytes. It is used to limit
+// how much file data the scanners hold in memory at the same time. When the
+// budget is used up, Acquire blocks, which pauses whichever iterator is walking
+// until some of the in flight data is released. A single request that is larger
+// than the whole budget is allowed through on its own, so we never deadlock.
+type Budget struct {
+	// Max is the maximum number of bytes to have in flight at once. If it
+	// is zero or negative, then there is no limit.
+	Max int64
+
+	mu   sync.Mutex
+	used int64
+	wake chan struct{} // closed and replaced whenever something is released
+}
+
+// Acquire blocks until there is room for n more bytes in the budget, or until
+// the context is cancelled, in which case it returns the context error. Every
+// successful Acquire must be followed by a Release of the same size.
+func (obj *Budget) Acquire(ctx context.Context, n int64) error {
+	for {
+		obj.mu.Lock()
+		if obj.Max <= 0 || obj.used == 0 || obj.used+n <= obj.Max {
+			obj.used += n
+			obj.mu.Unlock()
+			return nil
+		}
+		if obj.wake == nil {
+			obj.wake = make(chan struct{})
+		}
+		wake := obj.wake
+		obj.mu.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release returns n bytes to the budget and wakes up anyone who was waiting.
+func (obj *Budget) Release(n int64) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	obj.used -= n
+	if obj.wake != nil {
+		close(obj.wake)
+		obj.wake = nil
+	}
+}
+
+// Used returns the number of bytes that are currently in flight.
+func (obj *Budget) Used() int64 {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	return obj.used
+}
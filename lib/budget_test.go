@@ -0,0 +1,78 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/awslabs/yesiscan/lib"
+)
+
+func TestBudget(t *testing.T) {
+	ctx := context.Background()
+	budget := &lib.Budget{Max: 100}
+
+	if err := budget.Acquire(ctx, 60); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	// this doesn't fit, so it must wait until the release
+	done := make(chan error)
+	go func() {
+		done <- budget.Acquire(ctx, 60)
+	}()
+	select {
+	case <-done:
+		t.Errorf("acquired more than the budget")
+		return
+	case <-time.After(50 * time.Millisecond):
+	}
+	budget.Release(60)
+	if err := <-done; err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	budget.Release(60)
+
+	// a single oversized request is allowed through on its own
+	if err := budget.Acquire(ctx, 1000); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	// and a cancelled wait returns the context error
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := budget.Acquire(ctx, 1); err == nil {
+		t.Errorf("expected a context error")
+	}
+	budget.Release(1000)
+
+	if used := budget.Used(); used != 0 {
+		t.Errorf("expected an empty budget, got: %d", used)
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/licenses"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+const (
+	// CacheDir is the directory under the prefix where the results of each
+	// backend are cached if that option is enabled.
+	CacheDir = "results/"
+
+	// CacheMinSigningKeySize is the smallest signing key in bytes that we
+	// accept for a remote cache.
+	CacheMinSigningKeySize = 32
+)
+
+// CacheRemote is a store of cache entries that is shared by many machines, such
+// as all of the CI runners and the web servers, so that each of them benefits
+// from what the others have already scanned. The entries are opaque and signed
+// by the Cache, so the remote doesn't need to be trusted with their integrity.
+type CacheRemote interface {
+	fmt.Stringer
+
+	// Init prepares the remote, and checks that it can be used.
+	Init(ctx context.Context) error
+
+	// Get returns the data stored for this key. If there is nothing, then
+	// it returns nil and no error.
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Put stores the data for this key, replacing anything already there.
+	// It should never leave half of the data behind.
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// Cache stores the result of each backend for each file on disk, so that the
+// same content doesn't get scanned again. The key includes the version of the
+// backend, of the output schema, and of our license database, so that an
+// upgrade of any of them never serves an outdated determination. Those stale entries are simply never read
+// again, and can be removed with CleanCache.
+type Cache struct {
+	// Dir is the directory where the cached results are stored.
+	Dir safepath.AbsDir
+
+	// Perms is the permission policy for everything that we write. If it
+	// is nil, then the default policy is used.
+	Perms *interfaces.Perms
+
+	// Version is the version of the program. Since the built-in backends
+	// don't have their own version, any new release invalidates the cache.
+	Version string
+
+	// Remote is the shared store that is used when an entry isn't on disk.
+	// Everything that is put is also put there, and everything that is got
+	// from it is kept on disk. If it is nil, then only the disk is used.
+	Remote CacheRemote
+
+	// SigningKey is the secret that the entries of the remote are signed
+	// with, since anyone who can write to the remote could otherwise change
+	// the results of everyone else. Entries with an invalid signature are
+	// never used. It must be set if there is a remote, and all of the
+	// machines that share the remote must use the same one.
+	SigningKey []byte
+}
+
+// remoteEntry is what gets stored in the remote for each key.
+type remoteEntry struct {
+	// Signature is the hex HMAC-SHA256 of the key and of the entry.
+	Signature string `json:"signature"`
+
+	// Entry is the same json of the cacheEntry that gets stored on disk.
+	Entry json.RawMessage `json:"entry"`
+}
+
+// cacheEntry is what gets stored on disk for each key.
+type cacheEntry struct {
+	// Result is nil if the backend had no determination for this file.
+	Result *cacheResult `json:"result"`
+}
+
+// cacheResult is the part of an interfaces.Result that we can store. Results
+// with a Skip error are never cached.
+type cacheResult struct {
+	Licenses   []*licenses.License `json:"licenses"`
+	Confidence float64             `json:"confidence"`
+	More       []*cacheResult      `json:"more,omitempty"`
+	Raw        []byte              `json:"raw,omitempty"`
+	Diff       string              `json:"diff,omitempty"`
+
+	Choices [][]*licenses.License `json:"choices,omitempty"`
+}
+
+// Cacheable returns true if the results of this backend may be cached.
+func Cacheable(backend interfaces.Backend) bool {
+	if x, ok := backend.(interfaces.CacheableBackend); ok {
+		return x.Cacheable()
+	}
+	return true
+}
+
+// Key returns the cache key for a backend scanning a file with this name and
+// content hash. The name is part of the key because many backends only look at
+// files with a particular name.
+func (obj *Cache) Key(backend interfaces.Backend, name, sum string) string {
+	version := ""
+	if x, ok := backend.(interfaces.VersionBackend); ok {
+		version = x.Version()
+	}
+	h := sha256.New()
+	schema := strconv.Itoa(SchemaVersion)
+	for _, x := range []string{obj.Version, schema, licenses.LicenseList.Version, backend.String(), version, name, sum} {
+		h.Write([]byte(x))
+		h.Write([]byte{0}) // separator
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Init checks that the cache is valid, and prepares the remote if there is one.
+func (obj *Cache) Init(ctx context.Context) error {
+	if obj.Remote == nil {
+		return nil
+	}
+	if len(obj.SigningKey) < CacheMinSigningKeySize {
+		return fmt.Errorf("the signing key of a remote cache must have at least %d bytes", CacheMinSigningKeySize)
+	}
+	return errwrap.Wrapf(obj.Remote.Init(ctx), "could not initialize the remote cache %s", obj.Remote)
+}
+
+// sign returns the signature of the entry data for this key. The key is signed
+// too, so that a valid entry can't be copied to a different file.
+func (obj *Cache) sign(key string, data []byte) string {
+	h := hmac.New(sha256.New, obj.SigningKey)
+	h.Write([]byte(key))
+	h.Write([]byte{0}) // separator
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// path returns the file where the entry for this key is stored.
+func (obj *Cache) path(key string) string {
+	return filepath.Join(obj.Dir.Path(), key[:2], key+".json")
+}
+
+// Get returns the cached result for this key. The bool is true if there was an
+// entry, even if the result is nil, which means there was no determination. If
+// it isn't on disk, then the remote is checked, and what it has is kept.
+func (obj *Cache) Get(ctx context.Context, key string) (*interfaces.Result, bool, error) {
+	b, err := os.ReadFile(obj.path(key))
+	if os.IsNotExist(err) && obj.Remote != nil {
+		if b, err = obj.getRemote(ctx, key); err != nil {
+			return nil, false, err
+		}
+		if b == nil {
+			return nil, false, nil
+		}
+		if err := obj.write(key, b); err != nil {
+			return nil, false, err
+		}
+	} else if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	var entry cacheEntry
+	decoder := json.NewDecoder(bytes.NewBuffer(b))
+	if err := decoder.Decode(&entry); err != nil {
+		return nil, false, errwrap.Wrapf(err, "error decoding cache entry: %s", key)
+	}
+	if entry.Result == nil {
+		return nil, true, nil
+	}
+	return entry.Result.result(), true, nil
+}
+
+// getRemote returns the entry data for this key from the remote, or nil if it
+// doesn't have it. It errors if the signature isn't valid.
+func (obj *Cache) getRemote(ctx context.Context, key string) ([]byte, error) {
+	b, err := obj.Remote.Get(ctx, key)
+	if err != nil || b == nil {
+		return nil, errwrap.Wrapf(err, "error getting the remote cache entry: %s", key)
+	}
+	var entry remoteEntry
+	decoder := json.NewDecoder(bytes.NewBuffer(b))
+	if err := decoder.Decode(&entry); err != nil {
+		return nil, errwrap.Wrapf(err, "error decoding remote cache entry: %s", key)
+	}
+	signature, err := hex.DecodeString(entry.Signature)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "error decoding remote cache signature: %s", key)
+	}
+	expected, _ := hex.DecodeString(obj.sign(key, entry.Entry))
+	if !hmac.Equal(signature, expected) {
+		return nil, fmt.Errorf("invalid signature on remote cache entry: %s", key)
+	}
+	return entry.Entry, nil
+}
+
+// Put stores the result for this key. The result may be nil if there was no
+// determination. Results with a Skip error are not stored. This is safe to call
+// concurrently, even for the same key.
+func (obj *Cache) Put(ctx context.Context, key string, result *interfaces.Result) error {
+	entry := &cacheEntry{}
+	if result != nil {
+		if result.Skip != nil {
+			return nil
+		}
+		entry.Result = newCacheResult(result)
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := obj.write(key, b); err != nil {
+		return err
+	}
+	if obj.Remote == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(&remoteEntry{
+		Signature: obj.sign(key, b),
+		Entry:     b,
+	})
+	if err != nil {
+		return err
+	}
+	return errwrap.Wrapf(obj.Remote.Put(ctx, key, data), "error putting the remote cache entry: %s", key)
+}
+
+// write stores the entry data for this key on disk.
+func (obj *Cache) write(key string, b []byte) error {
+	p := obj.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), obj.Perms.DirMode()); err != nil {
+		return err
+	}
+	// write to a temporary file and rename so readers never see half of it
+	f, err := os.CreateTemp(filepath.Dir(p), key+".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), obj.Perms.FileMode())
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), p)
+	}
+	if err != nil {
+		return errwrap.Append(err, os.Remove(f.Name()))
+	}
+	return nil
+}
+
+// newCacheResult converts a result into the form that we store.
+func newCacheResult(result *interfaces.Result) *cacheResult {
+	r := &cacheResult{
+		Licenses:   result.Licenses,
+		Confidence: result.Confidence,
+		Raw:        result.Raw,
+		Diff:       result.Diff,
+		Choices:    result.Choices,
+	}
+	for _, x := range result.More {
+		r.More = append(r.More, newCacheResult(x))
+	}
+	return r
+}
+
+// result converts what we stored back into a regular result.
+func (obj *cacheResult) result() *interfaces.Result {
+	r := &interfaces.Result{
+		Licenses:   obj.Licenses,
+		Confidence: obj.Confidence,
+		Raw:        obj.Raw,
+		Diff:       obj.Diff,
+		Choices:    obj.Choices,
+	}
+	for _, x := range obj.More {
+		r.More = append(r.More, x.result())
+	}
+	return r
+}
+
+// CleanCache removes all of the cached results under the prefix. This is safe
+// to run when nothing is cached.
+func CleanCache(prefix safepath.AbsDir) error {
+	dir := safepath.JoinToAbsDir(prefix, safepath.UnsafeParseIntoRelDir(CacheDir))
+	return os.RemoveAll(dir.Path())
+}
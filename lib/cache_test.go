@@ -0,0 +1,213 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+// countingBackend counts how many times it actually scanned a file.
+type countingBackend struct {
+	version string
+	count   int // only one file, so no mutex needed
+}
+
+func (obj *countingBackend) String() string { return "counting" }
+
+func (obj *countingBackend) Version() string { return obj.version }
+
+func (obj *countingBackend) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
+	if info.FileInfo.IsDir() {
+		return nil, nil
+	}
+	obj.count++
+	result := &interfaces.Result{
+		Licenses:   []*licenses.License{{SPDX: "MIT"}},
+		Confidence: 1.0,
+	}
+	return result, nil
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "LICENSE"), []byte("MIT License\n"), 0600); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	absDir, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	prefix, err := safepath.ParseIntoAbsDir(t.TempDir() + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	logf := func(format string, v ...interface{}) {
+		t.Logf(format, v...)
+	}
+	backend := &countingBackend{version: "1"}
+	cache := &lib.Cache{
+		Dir:     safepath.JoinToAbsDir(prefix, safepath.UnsafeParseIntoRelDir(lib.CacheDir)),
+		Version: "test",
+	}
+
+	scan := func() {
+		core := &lib.Core{
+			Logf:     logf,
+			Backends: []interfaces.Backend{backend},
+			Iterators: []interfaces.Iterator{
+				&iterator.Fs{
+					Logf:   logf,
+					Prefix: absDir,
+					Path:   absDir,
+				},
+			},
+			Cache: cache,
+		}
+		if err := core.Init(context.Background()); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+		results, _, _, err := core.Run(context.Background())
+		if err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+		r := results[iterator.FileScheme+absDir.String()+"LICENSE"][backend]
+		if r == nil || len(r.Licenses) != 1 || r.Licenses[0].SPDX != "MIT" {
+			t.Errorf("unexpected result: %+v", r)
+		}
+	}
+
+	scan()
+	scan() // this one is cached
+	if backend.count != 1 {
+		t.Errorf("expected one scan, got: %d", backend.count)
+	}
+
+	backend.version = "2" // an upgrade invalidates the cache
+	scan()
+	if backend.count != 2 {
+		t.Errorf("expected two scans, got: %d", backend.count)
+	}
+
+	if err := lib.CleanCache(prefix); err != nil {
+		t.Errorf("error: %+v", err)
+	}
+	if _, err := os.Stat(cache.Dir.Path()); !os.IsNotExist(err) {
+		t.Errorf("expected the cache to be removed")
+	}
+}
+
+// memoryCacheRemote is a remote cache that is shared by the caches in a test.
+type memoryCacheRemote struct {
+	mutex   sync.Mutex
+	entries map[string][]byte
+}
+
+func (obj *memoryCacheRemote) String() string { return "memory" }
+
+func (obj *memoryCacheRemote) Init(ctx context.Context) error { return nil }
+
+func (obj *memoryCacheRemote) Get(ctx context.Context, key string) ([]byte, error) {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	return obj.entries[key], nil
+}
+
+func (obj *memoryCacheRemote) Put(ctx context.Context, key string, data []byte) error {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.entries[key] = data
+	return nil
+}
+
+func TestCacheRemote(t *testing.T) {
+	ctx := context.Background()
+	remote := &memoryCacheRemote{entries: make(map[string][]byte)}
+	signingKey := bytes.Repeat([]byte("k"), lib.CacheMinSigningKeySize)
+	newCache := func(signingKey []byte) *lib.Cache {
+		dir, err := safepath.ParseIntoAbsDir(t.TempDir() + "/")
+		if err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		return &lib.Cache{
+			Dir:        dir,
+			Version:    "test",
+			Remote:     remote,
+			SigningKey: signingKey,
+		}
+	}
+
+	if err := newCache([]byte("short")).Init(ctx); err == nil {
+		t.Errorf("expected a short signing key to error")
+	}
+
+	backend := &countingBackend{version: "1"}
+	runner := newCache(signingKey) // one machine...
+	if err := runner.Init(ctx); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	key := runner.Key(backend, "LICENSE", "0123")
+	result := &interfaces.Result{
+		Licenses:   []*licenses.License{{SPDX: "MIT"}},
+		Confidence: 1.0,
+	}
+	if err := runner.Put(ctx, key, result); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	server := newCache(signingKey) // ...and another one
+	r, cached, err := server.Get(ctx, key)
+	if err != nil || !cached || r == nil || len(r.Licenses) != 1 || r.Licenses[0].SPDX != "MIT" {
+		t.Errorf("unexpected result: %+v, %t, %+v", r, cached, err)
+	}
+	if r, cached, err := server.Get(ctx, runner.Key(backend, "LICENSE", "4567")); err != nil || cached || r != nil {
+		t.Errorf("expected a miss: %+v, %t, %+v", r, cached, err)
+	}
+
+	// someone who doesn't have the key can't change the results
+	if _, _, err := newCache(bytes.Repeat([]byte("x"), lib.CacheMinSigningKeySize)).Get(ctx, key); err == nil {
+		t.Errorf("expected a different signing key to error")
+	}
+	remote.entries[key] = bytes.Replace(remote.entries[key], []byte("MIT"), []byte("GPL-2.0-only"), 1)
+	if _, _, err := newCache(signingKey).Get(ctx, key); err == nil {
+		t.Errorf("expected a changed entry to error")
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+const (
+	// ChecksumAlgorithm is the name of the only checksum algorithm that we
+	// record and verify. It is spelled the way that ORT and CycloneDX do.
+	ChecksumAlgorithm = "SHA-256"
+)
+
+// ChecksumConfig is the datastructure representing the checksum manifest that
+// is used for the .json files on disk. It lists the expected checksum of each
+// input which gets downloaded, so that a changed artifact is never scanned.
+type ChecksumConfig struct {
+	// Checksums is a map of input arguments to the lowercase hex sha256 sum
+	// that the downloaded artifact must have.
+	Checksums map[string]string `json:"checksums"`
+
+	// Comment adds a user friendly comment for this file.
+	Comment string `json:"comment"`
+}
+
+// LoadChecksums reads a checksum manifest file and returns the map of inputs
+// to expected checksums that is in it.
+func LoadChecksums(filename string) (map[string]string, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := bytes.NewBuffer(b)
+	if buffer.Len() == 0 {
+		// TODO: should this be an error, or just a silent ignore?
+		return nil, fmt.Errorf("empty input file")
+	}
+	decoder := json.NewDecoder(buffer)
+
+	var checksumConfig ChecksumConfig // this gets populated during decode
+	if err := decoder.Decode(&checksumConfig); err != nil {
+		return nil, errwrap.Wrapf(err, "error decoding checksum json output")
+	}
+
+	checksums := make(map[string]string)
+	for k, v := range checksumConfig.Checksums {
+		sum, err := ParseChecksum(v)
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "invalid checksum for %s", k)
+		}
+		checksums[k] = sum
+	}
+
+	return checksums, nil
+}
+
+// ParseChecksum validates a hex sha256 sum and returns it in lowercase.
+func ParseChecksum(sum string) (string, error) {
+	s := strings.ToLower(strings.TrimSpace(sum))
+	if b, err := hex.DecodeString(s); err != nil || len(b) != 32 {
+		return "", fmt.Errorf("invalid sha256 hash: %s", sum)
+	}
+	return s, nil
+}
+
+// isChecksumAlgorithm returns true if the algorithm name is sha256, however it
+// happens to be spelled.
+func isChecksumAlgorithm(algorithm string) bool {
+	s := strings.ToLower(strings.ReplaceAll(algorithm, "-", ""))
+	return s == "sha256"
+}
+
+// addChecksum adds an expected checksum for an input to the map. It errors if
+// a different checksum was already expected for it.
+func addChecksum(checksums map[string]string, input, sum string) error {
+	sum, err := ParseChecksum(sum)
+	if err != nil {
+		return errwrap.Wrapf(err, "invalid checksum for %s", input)
+	}
+	if x, exists := checksums[input]; exists && x != sum {
+		return fmt.Errorf("conflicting checksums for %s: %s and %s", input, x, sum)
+	}
+	checksums[input] = sum
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"bytes"
+	"encoding/csv"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReturnOutputCSV returns the per file results formatted as CSV, so that they
+// can be imported into a spreadsheet. There is one row for each backend which
+// found a license in a file, with all of the licenses that it found, its
+// confidence, and the weight of that backend. Files without any licenses are
+// left out.
+func ReturnOutputCSV(output *Output) (string, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	if err := w.Write([]string{"path", "backend", "licenses", "confidence", "weight"}); err != nil {
+		return "", err
+	}
+	uids := []string{}
+	for uid := range output.Results {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	for _, uid := range uids {
+		m := output.Results[uid]
+		for _, backend := range SortedResultBackends(m) {
+			result := m[backend]
+			if len(result.Licenses) == 0 {
+				continue
+			}
+			ids := []string{}
+			for _, license := range SortedLicenses(result.Licenses) {
+				ids = append(ids, scancodeSPDX(license))
+			}
+			record := []string{
+				uid,
+				backend.String(),
+				strings.Join(ids, " AND "),
+				strconv.FormatFloat(result.Confidence, 'f', 4, 64),
+				strconv.FormatFloat(output.BackendWeights[backend], 'f', -1, 64),
+			}
+			if err := w.Write(record); err != nil {
+				return "", err
+			}
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
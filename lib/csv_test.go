@@ -0,0 +1,61 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestOutputCSV(t *testing.T) {
+	b1, b2 := testBackend("b1"), testBackend("b2")
+	output := &lib.Output{
+		Results: interfaces.ResultSet{
+			"file:///a/main.go": {
+				b2: {Licenses: []*licenses.License{{SPDX: "MIT"}, {SPDX: "Apache-2.0"}}, Confidence: 0.5},
+				b1: {Licenses: []*licenses.License{{Custom: "mine, really"}}, Confidence: 1.0},
+			},
+			"file:///a/empty.go": {
+				b1: {Confidence: 1.0},
+			},
+		},
+		BackendWeights: map[interfaces.Backend]float64{
+			b1: 1.0,
+			b2: 2.5,
+		},
+	}
+	s, err := lib.ReturnOutputCSV(output)
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	exp := "path,backend,licenses,confidence,weight\n" +
+		"file:///a/main.go,b1,LicenseRef-yesiscan-mine-really,1.0000,1\n" +
+		"file:///a/main.go,b2,Apache-2.0 AND MIT,0.5000,2.5\n"
+	if s != exp {
+		t.Errorf("expected:\n%s\ngot:\n%s", exp, s)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+const (
+	// CurationsFileName is the default name of the curations file, which
+	// stores the decisions that reviewers made about the findings.
+	CurationsFileName = "curations.json"
+
+	// CurationAccepted is the decision that the findings for a file are
+	// correct. The backends still run on it in future scans.
+	CurationAccepted = "accepted"
+
+	// CurationFalsePositive is the decision that the findings for a file
+	// are wrong, and that it has no license. Future scans don't run the
+	// backends on it.
+	CurationFalsePositive = "false-positive"
+
+	// CurationOverridden is the decision that a file has the concluded
+	// licenses that the reviewer chose. Future scans don't run the backends
+	// on it, and report the concluded licenses instead.
+	CurationOverridden = "overridden"
+)
+
+// CurationDecisions is the list of valid curation decisions.
+var CurationDecisions = []string{
+	CurationAccepted,
+	CurationFalsePositive,
+	CurationOverridden,
+}
+
+// curationBackend is the stand-in backend that the concluded licenses of the
+// overridden files are reported under.
+type curationBackend struct{}
+
+// String returns the name of the backend.
+func (obj *curationBackend) String() string { return "curation" }
+
+// CurationBackend is the stand-in backend that the results of the overridden
+// files are reported under, so that they can't be mistaken for real findings.
+var CurationBackend interfaces.Backend = &curationBackend{}
+
+// Curation is a decision that a reviewer made about the findings in a file. It
+// is keyed by the content hash of the file, so it applies to every copy of it
+// in future scans.
+type Curation struct {
+	// Decision is one of the CurationDecisions.
+	Decision string `json:"decision"`
+
+	// Licenses is the list of concluded licenses for an overridden file, in
+	// the same format as the profiles use.
+	Licenses []string `json:"licenses,omitempty"`
+
+	// Comment is a user friendly explanation of the decision.
+	Comment string `json:"comment,omitempty"`
+
+	// Reviewer is the name of who made the decision.
+	Reviewer string `json:"reviewer,omitempty"`
+
+	// Time is when the decision was made, in RFC3339 format.
+	Time string `json:"time,omitempty"`
+
+	// UID is the file that the decision was made on, for reference.
+	UID string `json:"uid,omitempty"`
+}
+
+// Validate returns an error if this curation is not valid.
+func (obj *Curation) Validate() error {
+	switch obj.Decision {
+	case CurationAccepted, CurationFalsePositive:
+		if len(obj.Licenses) > 0 {
+			return fmt.Errorf("only an overridden curation has licenses")
+		}
+	case CurationOverridden:
+		if len(obj.Licenses) == 0 {
+			return fmt.Errorf("an overridden curation needs the concluded licenses")
+		}
+		if _, err := licenses.StringsToLicenses(obj.Licenses); err != nil {
+			return errwrap.Wrapf(err, "invalid concluded license")
+		}
+	default:
+		return fmt.Errorf("invalid curation decision: %s", obj.Decision)
+	}
+	return nil
+}
+
+// Result returns the result that is reported instead of the findings of the
+// backends for an overridden file. It is nil for the other decisions.
+func (obj *Curation) Result() (*interfaces.Result, error) {
+	if obj.Decision != CurationOverridden {
+		return nil, nil
+	}
+	list, err := licenses.StringsToLicenses(obj.Licenses)
+	if err != nil {
+		return nil, err
+	}
+	return &interfaces.Result{
+		Licenses:   list,
+		Confidence: 1.0,
+	}, nil
+}
+
+// CurationsConfig is the datastructure representing the curations file that is
+// stored on disk. Like the ignore list, it is usually stored in the users
+// config directory, so that it can be shared across many projects.
+type CurationsConfig struct {
+	// Comment adds a user friendly comment for this file.
+	Comment string `json:"comment"`
+
+	// Hashes is a map of lowercase hex sha256 content hashes to the
+	// decision that was made for the files with that content.
+	Hashes map[string]*Curation `json:"hashes"`
+}
+
+// LoadCurations reads a curations file and returns the curations that are in
+// it, keyed by content hash.
+func LoadCurations(filename string) (map[string]*Curation, error) {
+	config, err := loadCurationsConfig(filename)
+	if err != nil {
+		return nil, err // the caller might want to check os.IsNotExist
+	}
+	return config.Hashes, nil
+}
+
+// loadCurationsConfig reads and validates the whole curations file.
+func loadCurationsConfig(filename string) (*CurationsConfig, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := bytes.NewBuffer(b)
+	if buffer.Len() == 0 {
+		return nil, fmt.Errorf("empty input file")
+	}
+	decoder := json.NewDecoder(buffer)
+
+	var config CurationsConfig // this gets populated during decode
+	if err := decoder.Decode(&config); err != nil {
+		return nil, errwrap.Wrapf(err, "error decoding curations json output")
+	}
+
+	hashes := make(map[string]*Curation)
+	for k, v := range config.Hashes {
+		h := strings.ToLower(strings.TrimSpace(k))
+		if b, err := hex.DecodeString(h); err != nil || len(b) != 32 {
+			return nil, fmt.Errorf("invalid sha256 hash: %s", k)
+		}
+		if v == nil {
+			return nil, fmt.Errorf("empty curation for: %s", k)
+		}
+		if err := v.Validate(); err != nil {
+			return nil, errwrap.Wrapf(err, "invalid curation for: %s", k)
+		}
+		hashes[h] = v
+	}
+	config.Hashes = hashes
+
+	return &config, nil
+}
+
+// SaveCuration adds the curation for this content hash to the curations file,
+// replacing any previous decision for it. The file is created if it doesn't
+// exist. This is not safe to call concurrently on the same file.
+func SaveCuration(filename, hash string, curation *Curation, perms *interfaces.Perms) error {
+	hash = strings.ToLower(hash)
+	if b, err := hex.DecodeString(hash); err != nil || len(b) != 32 {
+		return fmt.Errorf("invalid sha256 hash: %s", hash)
+	}
+	if err := curation.Validate(); err != nil {
+		return err
+	}
+
+	config, err := loadCurationsConfig(filename)
+	if os.IsNotExist(err) {
+		config, err = &CurationsConfig{
+			Comment: "the decisions that reviewers made about the findings",
+		}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if config.Hashes == nil {
+		config.Hashes = make(map[string]*Curation)
+	}
+	config.Hashes[hash] = curation
+
+	b, err := json.MarshalIndent(config, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), perms.DirMode()); err != nil {
+		return err
+	}
+	// write to a temporary file and rename so readers never see half of it
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), perms.FileMode())
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		return errwrap.Append(err, os.Remove(f.Name()))
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+func TestCurationValidate(t *testing.T) {
+	tests := []struct {
+		curation *lib.Curation
+		valid    bool
+	}{
+		{&lib.Curation{Decision: lib.CurationAccepted}, true},
+		{&lib.Curation{Decision: lib.CurationFalsePositive}, true},
+		{&lib.Curation{Decision: lib.CurationOverridden, Licenses: []string{"MIT"}}, true},
+		{&lib.Curation{Decision: lib.CurationOverridden}, false},
+		{&lib.Curation{Decision: lib.CurationAccepted, Licenses: []string{"MIT"}}, false},
+		{&lib.Curation{Decision: "maybe"}, false},
+	}
+	for i, x := range tests {
+		if err := x.curation.Validate(); (err == nil) != x.valid {
+			t.Errorf("test %d: expected valid: %t, got: %+v", i, x.valid, err)
+		}
+	}
+}
+
+func TestCurations(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"wrong.go": "this is not really GPL\n",
+		"custom.c": "a custom license header\n",
+		"fine.go":  "MIT License\n",
+	}
+	hashes := make(map[string]string)
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+		h := sha256.Sum256([]byte(data))
+		hashes[name] = hex.EncodeToString(h[:])
+	}
+
+	filename := filepath.Join(t.TempDir(), "sub", lib.CurationsFileName)
+	save := map[string]*lib.Curation{
+		"wrong.go": {Decision: lib.CurationFalsePositive, Reviewer: "alice"},
+		"custom.c": {Decision: lib.CurationOverridden, Licenses: []string{"Apache-2.0"}},
+		"fine.go":  {Decision: lib.CurationAccepted},
+	}
+	for name, curation := range save {
+		if err := lib.SaveCuration(filename, hashes[name], curation, nil); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+	}
+	if err := lib.SaveCuration(filename, "nope", save["fine.go"], nil); err == nil {
+		t.Errorf("expected an invalid hash to error")
+	}
+	curations, err := lib.LoadCurations(filename)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if len(curations) != 3 || curations[hashes["wrong.go"]].Reviewer != "alice" {
+		t.Errorf("unexpected curations: %+v", curations)
+	}
+
+	absDir, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	logf := func(format string, v ...interface{}) {
+		t.Logf(format, v...)
+	}
+	backend := &countingBackend{version: "1"}
+	core := &lib.Core{
+		Logf:     logf,
+		Backends: []interfaces.Backend{backend},
+		Iterators: []interfaces.Iterator{
+			&iterator.Fs{
+				Logf:   logf,
+				Prefix: absDir,
+				Path:   absDir,
+			},
+		},
+		Curations: curations,
+	}
+	if err := core.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	results, _, _, err := core.Run(context.Background())
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if backend.count != 1 {
+		t.Errorf("expected only the accepted file to be scanned, got: %d", backend.count)
+	}
+	uid := func(name string) string { return iterator.FileScheme + absDir.String() + name }
+	if _, exists := results[uid("wrong.go")]; exists {
+		t.Errorf("expected no results for the false positive")
+	}
+	r := results[uid("custom.c")][lib.CurationBackend]
+	if r == nil || len(r.Licenses) != 1 || r.Licenses[0].SPDX != "Apache-2.0" {
+		t.Errorf("unexpected overridden result: %+v", r)
+	}
+	if r := results[uid("fine.go")][backend]; r == nil {
+		t.Errorf("expected a result for the accepted file")
+	}
+	if curated := core.Curated(); len(curated) != 3 {
+		t.Errorf("expected three curated files, got: %+v", curated)
+	}
+	if sum := core.ContentHashes()[uid("fine.go")]; sum != hashes["fine.go"] {
+		t.Errorf("unexpected content hash: %s", sum)
+	}
+}
@@ -0,0 +1,384 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/util"
+	"github.com/awslabs/yesiscan/util/licenses"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+const (
+	// CycloneDXSpecVersion is the version of the CycloneDX specification
+	// that we generate.
+	CycloneDXSpecVersion = "1.5"
+
+	// CycloneDXFormat is the value of the bomFormat field.
+	CycloneDXFormat = "CycloneDX"
+
+	// CycloneDXPropertyConfidence is the name of the license property with
+	// the highest blended confidence of any file that the license was found
+	// in, from 0 to 1.
+	CycloneDXPropertyConfidence = "yesiscan:confidence"
+
+	// CycloneDXPropertyBackends is the name of the license property with the
+	// comma separated list of backends that found the license.
+	CycloneDXPropertyBackends = "yesiscan:backends"
+
+	// CycloneDXPropertyFiles is the name of the license property with the
+	// number of files that the license was found in.
+	CycloneDXPropertyFiles = "yesiscan:files"
+)
+
+// CycloneDXBom is a CycloneDX software bill of materials in the json format.
+// We only fill in the fields that we have data for. There is one component for
+// each artifact that was scanned. See: https://cyclonedx.org/specification/
+type CycloneDXBom struct {
+	BomFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	SerialNumber string                `json:"serialNumber"`
+	Version      int                   `json:"version"`
+	Metadata     *CycloneDXMetadata    `json:"metadata"`
+	Components   []*CycloneDXComponent `json:"components"`
+}
+
+// CycloneDXMetadata describes the bom and the tool which made it.
+type CycloneDXMetadata struct {
+	Timestamp string           `json:"timestamp"`
+	Tools     []*CycloneDXTool `json:"tools"`
+}
+
+// CycloneDXTool is the tool which made the bom.
+type CycloneDXTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// CycloneDXComponent is a single component of the bom.
+type CycloneDXComponent struct {
+	Type               string                        `json:"type"`
+	BomRef             string                        `json:"bom-ref"`
+	Name               string                        `json:"name"`
+	Version            string                        `json:"version,omitempty"`
+	Licenses           []*CycloneDXLicenseChoice     `json:"licenses"`
+	Hashes             []*CycloneDXHash              `json:"hashes,omitempty"`
+	ExternalReferences []*CycloneDXExternalReference `json:"externalReferences,omitempty"`
+	Evidence           *CycloneDXEvidence            `json:"evidence,omitempty"`
+}
+
+// CycloneDXEvidence is what the licenses of a component are based on. Each of
+// the licenses that a backend found is listed with its confidence as a property.
+type CycloneDXEvidence struct {
+	Licenses []*CycloneDXLicenseChoice `json:"licenses"`
+}
+
+// CycloneDXHash is a checksum of the downloaded component.
+type CycloneDXHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+// CycloneDXLicenseChoice wraps a license.
+type CycloneDXLicenseChoice struct {
+	License *CycloneDXLicense `json:"license"`
+}
+
+// CycloneDXLicense is either an SPDX ID, or the name of some other license.
+type CycloneDXLicense struct {
+	ID         string               `json:"id,omitempty"`
+	Name       string               `json:"name,omitempty"`
+	Properties []*CycloneDXProperty `json:"properties,omitempty"`
+}
+
+// CycloneDXProperty is a name and value pair for things that the specification
+// doesn't have a field for.
+type CycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// CycloneDXExternalReference is where the component came from.
+type CycloneDXExternalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// ArtifactCoordinates returns a name and version for an input argument. If it
+// came from an ORT analyzer result, then the package coordinates are used.
+// Otherwise the name is the last element of the path, without any archive or
+// .git extension, and the version is the git commit hash if there is one. The
+// version is empty if we can't tell what it is.
+func ArtifactCoordinates(output *Output, artifact string) (string, string) {
+	if pkg, exists := output.OrtPackages[artifact]; exists {
+		// the ORT format is type:namespace:name:version
+		if fields := strings.SplitN(pkg.ID, ":", 4); len(fields) == 4 {
+			name := fields[2]
+			if fields[1] != "" {
+				name = fields[1] + "/" + name
+			}
+			return name, fields[3]
+		}
+	}
+
+	version := ""
+	p := strings.TrimSuffix(uidPath(artifact), "/")
+	if i := strings.Index(p, "://"); i >= 0 {
+		p = p[i+len("://"):]
+	}
+	if base := path.Base(p); plumbing.IsHash(base) {
+		version = base
+		p = path.Dir(path.Dir(p)) // remove the /commit/<hash> suffix
+	}
+	name := path.Base(p)
+	extensions := []string{
+		".git",
+		iterator.ZipExtension,
+		iterator.JarExtension,
+		iterator.WhlExtension,
+		iterator.TarExtension,
+	}
+	extensions = append(extensions, iterator.GzipExtensions...)
+	extensions = append(extensions, iterator.Bzip2Extensions...)
+	// strip them all, so that foo.tar.gz becomes foo
+	for suffix := iterator.WhichSuffixInsensitive(name, extensions); suffix != ""; suffix = iterator.WhichSuffixInsensitive(name, extensions) {
+		name = name[:len(name)-len(suffix)]
+	}
+	if name == "" || name == "." || name == "/" {
+		name = artifact
+	}
+	return name, version
+}
+
+// ArtifactLicenses returns the sorted union of every license that was found in
+// the files of each artifact. This includes the inferred ones, since this is
+// meant to be the concluded license data.
+func ArtifactLicenses(output *Output, artifacts []string) map[string][]*licenses.License {
+	files, _ := ArtifactFiles(output, artifacts)
+	result := make(map[string][]*licenses.License)
+	for _, a := range artifacts {
+		result[a] = packageLicenses(output, nil, files[a])
+	}
+	return result
+}
+
+// NewCycloneDXBom builds a CycloneDX bom with one component for each artifact.
+func NewCycloneDXBom(output *Output) (*CycloneDXBom, error) {
+	artifacts := []string{}
+	artifacts = append(artifacts, output.Args...)
+	if len(artifacts) == 0 {
+		artifacts = append(artifacts, "")
+	}
+	files, _ := ArtifactFiles(output, artifacts)
+	found := make(map[string][]*licenses.License)
+	for _, a := range artifacts {
+		found[a] = packageLicenses(output, nil, files[a])
+	}
+
+	components := []*CycloneDXComponent{}
+	for i, a := range artifacts {
+		name, version := ArtifactCoordinates(output, a)
+		component := &CycloneDXComponent{
+			Type:     "library",
+			BomRef:   fmt.Sprintf("%s-%d", output.Program, i),
+			Name:     name,
+			Version:  version,
+			Licenses: cycloneDXLicenses(found[a]),
+		}
+		component.Hashes = cycloneDXHashes(output, a)
+		component.ExternalReferences = cycloneDXReferences(a)
+		component.Evidence = cycloneDXEvidence(output, files[a])
+		components = append(components, component)
+	}
+	sort.SliceStable(components, func(i, j int) bool {
+		return components[i].Name < components[j].Name
+	})
+
+	return newCycloneDXBom(output, components)
+}
+
+// newCycloneDXBom wraps the components in a new bom.
+func newCycloneDXBom(output *Output, components []*CycloneDXComponent) (*CycloneDXBom, error) {
+	serial, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	return &CycloneDXBom{
+		BomFormat:    CycloneDXFormat,
+		SpecVersion:  CycloneDXSpecVersion,
+		SerialNumber: "urn:uuid:" + serial,
+		Version:      1,
+		Metadata: &CycloneDXMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools: []*CycloneDXTool{
+				{
+					Name:    output.Program,
+					Version: output.Version,
+				},
+			},
+		},
+		Components: components,
+	}, nil
+}
+
+// cycloneDXLicenses returns the license choices for a list of licenses.
+func cycloneDXLicenses(ls []*licenses.License) []*CycloneDXLicenseChoice {
+	choices := []*CycloneDXLicenseChoice{}
+	for _, x := range ls {
+		license := &CycloneDXLicense{ID: x.SPDX}
+		if x.SPDX == "" {
+			license = &CycloneDXLicense{Name: x.String()}
+		}
+		choices = append(choices, &CycloneDXLicenseChoice{License: license})
+	}
+	return choices
+}
+
+// cycloneDXEvidence returns the license evidence for the files of a component.
+// The confidence of a license is the highest blended confidence of any file it
+// was found in. Inferred results aren't evidence, so they're left out. If there
+// is no evidence, then this returns nil.
+func cycloneDXEvidence(output *Output, uids []string) *CycloneDXEvidence {
+	type found struct {
+		license    *licenses.License
+		confidence float64
+		backends   []string
+		files      int
+	}
+	ids := []string{}
+	evidence := make(map[string]*found) // license string -> evidence
+	for _, uid := range uids {
+		m := output.Results[uid]
+		bs := []*AnnotatedBackend{}
+		for _, backend := range SortedResultBackends(m) {
+			weight, exists := output.BackendWeights[backend]
+			if !exists {
+				weight = 1.0 // unknown, so treat them all the same
+			}
+			bs = append(bs, &AnnotatedBackend{Backend: backend, Weight: weight})
+		}
+		confidence := BlendConfidence(output.Blend, bs, m)
+
+		seen := make(map[string]struct{}) // count each file once
+		for _, backend := range SortedResultBackends(m) {
+			r := m[backend]
+			if r.Meta != nil && r.Meta.Inferred {
+				continue
+			}
+			for _, x := range r.Licenses {
+				k := x.String()
+				e, exists := evidence[k]
+				if !exists {
+					e = &found{license: x}
+					evidence[k] = e
+					ids = append(ids, k)
+				}
+				if confidence > e.confidence {
+					e.confidence = confidence
+				}
+				if !util.StrInList(backend.String(), e.backends) {
+					e.backends = append(e.backends, backend.String())
+				}
+				if _, exists := seen[k]; !exists {
+					seen[k] = struct{}{}
+					e.files++
+				}
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Strings(ids)
+
+	result := &CycloneDXEvidence{
+		Licenses: []*CycloneDXLicenseChoice{},
+	}
+	for _, k := range ids {
+		e := evidence[k]
+		sort.Strings(e.backends)
+		choice := cycloneDXLicenses([]*licenses.License{e.license})[0]
+		choice.License.Properties = []*CycloneDXProperty{
+			{Name: CycloneDXPropertyConfidence, Value: strconv.FormatFloat(e.confidence, 'f', 4, 64)},
+			{Name: CycloneDXPropertyBackends, Value: strings.Join(e.backends, ",")},
+			{Name: CycloneDXPropertyFiles, Value: strconv.Itoa(e.files)},
+		}
+		result.Licenses = append(result.Licenses, choice)
+	}
+	return result
+}
+
+// cycloneDXHashes returns the checksum of an artifact, if it was downloaded.
+func cycloneDXHashes(output *Output, artifact string) []*CycloneDXHash {
+	sum, exists := output.Checksums[artifact]
+	if !exists {
+		return nil
+	}
+	return []*CycloneDXHash{{Alg: ChecksumAlgorithm, Content: sum}}
+}
+
+// cycloneDXReferences returns where an artifact came from, if we know.
+func cycloneDXReferences(artifact string) []*CycloneDXExternalReference {
+	if provenance := ortProvenance(artifact); provenance.VcsInfo != nil {
+		return []*CycloneDXExternalReference{{Type: "vcs", URL: artifact}}
+	} else if provenance.SourceArtifact != nil {
+		return []*CycloneDXExternalReference{{Type: "distribution", URL: artifact}}
+	}
+	return nil
+}
+
+// ReturnOutputCycloneDX returns a string of output, formatted as a CycloneDX
+// bom in json.
+func ReturnOutputCycloneDX(output *Output) (string, error) {
+	bom, err := NewCycloneDXBom(output)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,99 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestCycloneDXEvidence(t *testing.T) {
+	b1, b2 := testBackend("b1"), testBackend("b2")
+	mit := &licenses.License{SPDX: "MIT"}
+	output := &lib.Output{
+		Program: "yesiscan",
+		Args:    []string{"file:///m/"},
+		Results: interfaces.ResultSet{
+			"file:///m/a.go": {
+				b1: {Licenses: []*licenses.License{mit}, Confidence: 1.0},
+				b2: {Licenses: []*licenses.License{mit}, Confidence: 0.5},
+			},
+			"file:///m/b.go": {
+				b2: {Licenses: []*licenses.License{mit}, Confidence: 0.5},
+			},
+			"file:///m/c.go": {
+				b1: {
+					Licenses:   []*licenses.License{{SPDX: "Apache-2.0"}},
+					Confidence: 1.0,
+					Meta:       &interfaces.Meta{Inferred: true},
+				},
+			},
+		},
+		BackendWeights: map[interfaces.Backend]float64{b1: 1.0, b2: 3.0},
+		Blend:          lib.BlendLinear,
+		Profiles:       []string{lib.DefaultProfileName},
+	}
+
+	s, err := lib.ReturnOutputCycloneDX(output)
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	bom := &lib.CycloneDXBom{}
+	if err := json.Unmarshal([]byte(s), bom); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if bom.SpecVersion != "1.5" || len(bom.Components) != 1 {
+		t.Fatalf("unexpected bom: %s", s)
+	}
+	c := bom.Components[0]
+	if len(c.Licenses) != 2 {
+		t.Errorf("expected the inferred license to be concluded, got: %+v", c.Licenses)
+	}
+	if c.Evidence == nil || len(c.Evidence.Licenses) != 1 {
+		t.Fatalf("expected evidence for one license, got: %s", s)
+	}
+	license := c.Evidence.Licenses[0].License
+	properties := make(map[string]string)
+	for _, x := range license.Properties {
+		properties[x.Name] = x.Value
+	}
+	// a.go is (1.0*1 + 0.5*3) / 4 and b.go is 0.5
+	exp := map[string]string{
+		lib.CycloneDXPropertyConfidence: "0.6250",
+		lib.CycloneDXPropertyBackends:   "b1,b2",
+		lib.CycloneDXPropertyFiles:      "2",
+	}
+	if license.ID != "MIT" {
+		t.Errorf("unexpected license: %+v", license)
+	}
+	for k, v := range exp {
+		if properties[k] != v {
+			t.Errorf("expected %s of %s, got: %s", k, v, properties[k])
+		}
+	}
+}
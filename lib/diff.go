@@ -0,0 +1,199 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// DiffAdded is a path that has licenses, but wasn't in the old output.
+	DiffAdded = "added"
+
+	// DiffRemoved is a path that had licenses, but isn't in the new output.
+	DiffRemoved = "removed"
+
+	// DiffChanged is a path that is in both outputs with different licenses.
+	DiffChanged = "changed"
+)
+
+// DiffEntry is a change in the license determination of a single path.
+type DiffEntry struct {
+	// Path is relative to the root of the output that it's in, so that two
+	// scans of the same repo at different commits or in different cache
+	// directories can be compared.
+	Path string `json:"path"`
+
+	// Change is one of DiffAdded, DiffRemoved, or DiffChanged.
+	Change string `json:"change"`
+
+	// Old is the sorted list of licenses in the old output.
+	Old []string `json:"old"`
+
+	// New is the sorted list of licenses in the new output.
+	New []string `json:"new"`
+}
+
+// DiffOutputs compares the license determinations of each path in two stored
+// outputs. The licenses of a path are the union of what each backend found in
+// it. Paths are matched up by their location under the deepest directory which
+// contains all of the scanned paths of each output. Paths without any licenses
+// in either output are left out, and so are files without any licenses that
+// were only added or removed. The entries are sorted by path.
+func DiffOutputs(old, new *JSONOutput) []*DiffEntry {
+	oldPaths := diffPaths(old)
+	newPaths := diffPaths(new)
+
+	keys := []string{}
+	for k := range oldPaths {
+		keys = append(keys, k)
+	}
+	for k := range newPaths {
+		if _, exists := oldPaths[k]; !exists {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	entries := []*DiffEntry{}
+	for _, k := range keys {
+		o, inOld := oldPaths[k]
+		n, inNew := newPaths[k]
+		entry := &DiffEntry{
+			Path: k,
+			Old:  o,
+			New:  n,
+		}
+		if entry.Old == nil {
+			entry.Old = []string{}
+		}
+		if entry.New == nil {
+			entry.New = []string{}
+		}
+		switch {
+		case !inOld && len(n) > 0:
+			entry.Change = DiffAdded
+		case !inNew && len(o) > 0:
+			entry.Change = DiffRemoved
+		case inOld && inNew && strings.Join(o, "\x00") != strings.Join(n, "\x00"):
+			entry.Change = DiffChanged
+		default:
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// diffPaths returns the sorted licenses of every scanned path in the output,
+// keyed by its path relative to the root. The paths without any results have an
+// empty list.
+func diffPaths(output *JSONOutput) map[string][]string {
+	uids := []string{}
+	for uid := range output.Results {
+		uids = append(uids, uid)
+	}
+	uids = append(uids, output.Passes...)
+	paths := make(map[string][]string)
+	if len(uids) == 0 {
+		return paths
+	}
+	sort.Strings(uids)
+	root := diffRoot(uids)
+	for _, uid := range uids {
+		p := strings.TrimPrefix(uidPath(uid), uidPath(root))
+		if p == "" {
+			p = "."
+		}
+		set := make(map[string]struct{})
+		for _, result := range output.Results[uid] {
+			for _, x := range result.Licenses {
+				set[x.String()] = struct{}{}
+			}
+		}
+		ls := []string{}
+		for x := range set {
+			ls = append(ls, x)
+		}
+		sort.Strings(ls)
+		if _, exists := paths[p]; !exists || len(ls) > 0 {
+			paths[p] = ls
+		}
+	}
+	return paths
+}
+
+// diffRoot returns the deepest directory UID which contains all of the UID's.
+// Unlike commonParentUID, this can be one of the UID's itself, which is what
+// happens when a directory gets scanned, since the top is included.
+func diffRoot(uids []string) string {
+	root, ok := uids[0], strings.HasSuffix(uidPath(uids[0]), "/")
+	if !ok {
+		root, ok = ParentUID(uids[0])
+	}
+	for ok {
+		contains := true
+		for _, uid := range uids {
+			if !strings.HasPrefix(uid, uidPath(root)) {
+				contains = false
+				break
+			}
+		}
+		if contains {
+			return root
+		}
+		root, ok = ParentUID(root)
+	}
+	return ""
+}
+
+// ReturnDiff returns the diff as text, with one line for each path. Added paths
+// start with a plus, removed paths with a minus, and changed paths with a tilde.
+func ReturnDiff(entries []*DiffEntry) string {
+	if len(entries) == 0 {
+		return "no changes\n"
+	}
+	s := ""
+	for _, x := range entries {
+		switch x.Change {
+		case DiffAdded:
+			s += fmt.Sprintf("+ %s: %s\n", x.Path, diffLicenses(x.New))
+		case DiffRemoved:
+			s += fmt.Sprintf("- %s: %s\n", x.Path, diffLicenses(x.Old))
+		case DiffChanged:
+			s += fmt.Sprintf("~ %s: %s -> %s\n", x.Path, diffLicenses(x.Old), diffLicenses(x.New))
+		}
+	}
+	return s
+}
+
+// diffLicenses returns the list of licenses as a string.
+func diffLicenses(ls []string) string {
+	if len(ls) == 0 {
+		return "(none)"
+	}
+	return strings.Join(ls, ", ")
+}
@@ -0,0 +1,103 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestDiffOutputs(t *testing.T) {
+	mit := []*licenses.License{{SPDX: "MIT"}}
+	apache := []*licenses.License{{SPDX: "Apache-2.0"}}
+	old := &lib.JSONOutput{
+		Results: map[string]map[string]*lib.JSONResult{
+			"https://example.com/repo/abc123/main.go": {
+				"spdx": {Licenses: mit},
+			},
+			"https://example.com/repo/abc123/same.go": {
+				"spdx": {Licenses: mit},
+			},
+			"https://example.com/repo/abc123/gone.go": {
+				"spdx": {Licenses: apache},
+			},
+		},
+		Passes: []string{
+			"https://example.com/repo/abc123/",
+			"https://example.com/repo/abc123/later.go",
+			"https://example.com/repo/abc123/empty.go",
+		},
+	}
+	new := &lib.JSONOutput{
+		Results: map[string]map[string]*lib.JSONResult{
+			"https://example.com/repo/def456/main.go": {
+				"spdx":     {Licenses: mit},
+				"askalono": {Licenses: apache},
+			},
+			"https://example.com/repo/def456/same.go": {
+				"askalono": {Licenses: mit},
+			},
+			"https://example.com/repo/def456/later.go": {
+				"spdx": {Licenses: mit},
+			},
+			"https://example.com/repo/def456/sub/new.go": {
+				"spdx": {Licenses: apache},
+			},
+		},
+		Passes: []string{
+			"https://example.com/repo/def456/",
+			"https://example.com/repo/def456/sub/",
+			"https://example.com/repo/def456/blank.go",
+		},
+	}
+	exp := []*lib.DiffEntry{
+		{Path: "gone.go", Change: lib.DiffRemoved, Old: []string{"Apache-2.0"}, New: []string{}},
+		{Path: "later.go", Change: lib.DiffChanged, Old: []string{}, New: []string{"MIT"}},
+		{Path: "main.go", Change: lib.DiffChanged, Old: []string{"MIT"}, New: []string{"Apache-2.0", "MIT"}},
+		{Path: "sub/new.go", Change: lib.DiffAdded, Old: []string{}, New: []string{"Apache-2.0"}},
+	}
+	entries := lib.DiffOutputs(old, new)
+	if !reflect.DeepEqual(entries, exp) {
+		for _, x := range entries {
+			t.Logf("got: %+v", x)
+		}
+		t.Errorf("unexpected diff")
+	}
+
+	s := lib.ReturnDiff(entries)
+	expStr := "- gone.go: Apache-2.0\n" +
+		"~ later.go: (none) -> MIT\n" +
+		"~ main.go: MIT -> Apache-2.0, MIT\n" +
+		"+ sub/new.go: Apache-2.0\n"
+	if s != expStr {
+		t.Errorf("expected:\n%s\ngot:\n%s", expStr, s)
+	}
+
+	if s := lib.ReturnDiff(lib.DiffOutputs(old, old)); s != "no changes\n" {
+		t.Errorf("expected no changes, got:\n%s", s)
+	}
+}
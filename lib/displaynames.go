@@ -0,0 +1,67 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"sort"
+	"strings"
+)
+
+// DisplayNames maps the prefixes of UID's to friendlier prefixes to show in the
+// reports instead. For example, the url of an internal git mirror can be shown
+// as the canonical upstream url, so that the reports read naturally for the
+// reviewers. Like the Redactor, it works on the rendered reports, so every
+// output type shows the same names. It isn't used for anything but display.
+type DisplayNames map[string]string
+
+// Replace returns the string with every one of the prefixes replaced by its
+// display name. It works on any of the rendered output types.
+func (obj DisplayNames) Replace(s string) string {
+	oldnew := []string{}
+	for _, k := range obj.prefixes() {
+		oldnew = append(oldnew, k, obj[k])
+	}
+	if len(oldnew) == 0 {
+		return s
+	}
+	return strings.NewReplacer(oldnew...).Replace(s)
+}
+
+// prefixes returns the non-empty prefixes, longest first, so that the most
+// specific one wins.
+func (obj DisplayNames) prefixes() []string {
+	prefixes := []string{}
+	for k := range obj {
+		if k != "" {
+			prefixes = append(prefixes, k)
+		}
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		if len(prefixes[i]) != len(prefixes[j]) {
+			return len(prefixes[i]) > len(prefixes[j])
+		}
+		return prefixes[i] < prefixes[j]
+	})
+	return prefixes
+}
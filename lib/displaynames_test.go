@@ -0,0 +1,48 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/awslabs/yesiscan/lib"
+)
+
+func TestDisplayNames(t *testing.T) {
+	names := lib.DisplayNames{
+		"https://git.example.com/mirror/":     "https://github.com/",
+		"https://git.example.com/mirror/foo/": "https://gitlab.com/foo/",
+		"":                                    "ignored",
+	}
+	s := `{"results": {"https://git.example.com/mirror/bar/LICENSE": 1, "https://git.example.com/mirror/foo/README": 2, "file:///tmp/x": 3}}`
+	exp := `{"results": {"https://github.com/bar/LICENSE": 1, "https://gitlab.com/foo/README": 2, "file:///tmp/x": 3}}`
+	if got := names.Replace(s); got != exp {
+		t.Errorf("expected: %s, got: %s", exp, got)
+	}
+
+	var empty lib.DisplayNames
+	if got := empty.Replace(s); got != s {
+		t.Errorf("expected no change, got: %s", got)
+	}
+}
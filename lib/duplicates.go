@@ -0,0 +1,123 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+const (
+	// DuplicatesError is the original duplicate result policy. If we get a
+	// second result for the same path and backend which differs from the
+	// first, then that is an error.
+	DuplicatesError = "error"
+
+	// DuplicatesKeepFirst keeps whichever result we got first, and drops
+	// any others that differ from it.
+	DuplicatesKeepFirst = "keep-first"
+
+	// DuplicatesKeepHighest keeps whichever result has the highest
+	// confidence. If they are tied, then the first one is kept.
+	DuplicatesKeepHighest = "keep-highest"
+
+	// DefaultDuplicates is the duplicate result policy that is used if none
+	// is chosen.
+	DefaultDuplicates = DuplicatesError
+)
+
+// DuplicatesPolicies is the list of valid duplicate result policies.
+var DuplicatesPolicies = []string{
+	DuplicatesError,
+	DuplicatesKeepFirst,
+	DuplicatesKeepHighest,
+}
+
+// ValidateDuplicates returns an error if the duplicate result policy is not a
+// valid one. An empty policy is valid and means the default.
+func ValidateDuplicates(policy string) error {
+	if policy == "" {
+		return nil
+	}
+	for _, x := range DuplicatesPolicies {
+		if policy == x {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid duplicates policy: %s, must be one of: %s", policy, strings.Join(DuplicatesPolicies, ", "))
+}
+
+// ResolveDuplicate decides which of two results for the same path and backend
+// to keep. If they are the same, then the old one is kept. Otherwise the policy
+// decides. Nondeterministic backends can return slightly different results for
+// identical content, which is why this is configurable.
+func ResolveDuplicate(policy string, old, result *interfaces.Result) (*interfaces.Result, error) {
+	err := old.Cmp(result)
+	if err == nil {
+		return old, nil // same
+	}
+
+	switch policy {
+	case DuplicatesKeepFirst:
+		return old, nil
+
+	case DuplicatesKeepHighest:
+		if old == nil || (result != nil && result.Confidence > old.Confidence) {
+			return result, nil
+		}
+		return old, nil
+	}
+
+	return nil, err // DuplicatesError
+}
+
+// MergeResultSets merges two result sets together. If it would have to
+// overwrite a result, then the duplicate result policy decides what happens.
+func MergeResultSets(policy string, r1, r2 interfaces.ResultSet) (interfaces.ResultSet, error) {
+	resultSet := make(interfaces.ResultSet)
+
+	for _, rs := range []interfaces.ResultSet{r1, r2} {
+		for p, m := range rs {
+			if _, exists := resultSet[p]; !exists {
+				resultSet[p] = make(map[interfaces.Backend]*interfaces.Result)
+			}
+
+			for b, r := range m {
+				if old, exists := resultSet[p][b]; exists {
+					var err error
+					r, err = ResolveDuplicate(policy, old, r)
+					if err != nil {
+						return nil, errwrap.Wrapf(err, "duplicate result for %s in %s", p, b)
+					}
+				}
+				resultSet[p][b] = r
+			}
+		}
+	}
+
+	return resultSet, nil
+}
@@ -0,0 +1,61 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestResolveDuplicate(t *testing.T) {
+	mit := []*licenses.License{{SPDX: "MIT"}}
+	low := &interfaces.Result{Licenses: mit, Confidence: 0.5}
+	high := &interfaces.Result{Licenses: mit, Confidence: 0.9}
+	same := &interfaces.Result{Licenses: mit, Confidence: 0.5}
+
+	for _, policy := range lib.DuplicatesPolicies {
+		if r, err := lib.ResolveDuplicate(policy, low, same); err != nil || r != low {
+			t.Errorf("policy %s: expected the old identical result, got: %+v", policy, err)
+		}
+	}
+
+	if _, err := lib.ResolveDuplicate(lib.DuplicatesError, low, high); err == nil {
+		t.Errorf("expected an error")
+	}
+	if _, err := lib.ResolveDuplicate("", low, high); err == nil {
+		t.Errorf("expected an error with the default policy")
+	}
+	if r, err := lib.ResolveDuplicate(lib.DuplicatesKeepFirst, low, high); err != nil || r != low {
+		t.Errorf("expected the first result")
+	}
+	if r, err := lib.ResolveDuplicate(lib.DuplicatesKeepHighest, low, high); err != nil || r != high {
+		t.Errorf("expected the highest result")
+	}
+	if r, err := lib.ResolveDuplicate(lib.DuplicatesKeepHighest, high, low); err != nil || r != high {
+		t.Errorf("expected the highest result")
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+// Election records that a profile chose one of the licenses which a license
+// expression offered, such as MIT out of "MIT OR GPL-2.0-only".
+type Election struct {
+	// Profile is the name of the profile that made the choice.
+	Profile string `json:"profile"`
+
+	// UID is the file that the choice was made in.
+	UID string `json:"uid"`
+
+	// Backend is the name of the backend which found the expression.
+	Backend string `json:"backend"`
+
+	// Chosen is the license that was chosen.
+	Chosen string `json:"chosen"`
+
+	// Rejected is the sorted list of licenses that it was chosen over.
+	Rejected []string `json:"rejected"`
+}
+
+// String returns a short description of the election.
+func (obj *Election) String() string {
+	return fmt.Sprintf("%s over %s", obj.Chosen, strings.Join(obj.Rejected, ", "))
+}
+
+// Elect returns the license that the profile prefers out of a choice, or nil if
+// it doesn't prefer any of them. The first of its preferences that is offered
+// wins. A nil profile never prefers anything.
+func (obj *ProfileData) Elect(choice []*licenses.License) *licenses.License {
+	if obj == nil {
+		return nil
+	}
+	for _, x := range obj.Prefer {
+		for _, license := range choice {
+			if license.Cmp(x) == nil {
+				return license
+			}
+		}
+	}
+	return nil
+}
+
+// ElectedLicenses returns the licenses of a result which apply when looked at
+// through a profile. For each choice that the result offers, the license that
+// the profile prefers is kept and the others are dropped, unless some other
+// part of the expression needs them too. When there's no preference, all of
+// the licenses apply, which is the safe answer.
+func ElectedLicenses(profile *ProfileData, result *interfaces.Result) []*licenses.License {
+	if profile == nil || len(profile.Prefer) == 0 || len(result.Choices) == 0 {
+		return result.Licenses
+	}
+	kept := []*licenses.License{}
+	rejected := []*licenses.License{}
+	for _, choice := range result.Choices {
+		chosen := profile.Elect(choice)
+		for _, x := range choice {
+			if chosen == nil || x.Cmp(chosen) == nil {
+				kept = append(kept, x)
+				continue
+			}
+			rejected = append(rejected, x)
+		}
+	}
+
+	ls := []*licenses.License{}
+	for _, x := range result.Licenses {
+		if licenses.InList(x, rejected) && !licenses.InList(x, kept) {
+			continue
+		}
+		ls = append(ls, x)
+	}
+	return ls
+}
+
+// resultElections returns the elections that a profile made for a result,
+// without the profile, file, or backend filled in.
+func resultElections(profile *ProfileData, result *interfaces.Result) []*Election {
+	if profile == nil || len(profile.Prefer) == 0 {
+		return nil
+	}
+	elections := []*Election{}
+	seen := make(map[string]struct{})
+	for _, choice := range result.Choices {
+		if len(choice) < 2 {
+			continue // nothing to choose
+		}
+		chosen := profile.Elect(choice)
+		if chosen == nil {
+			continue
+		}
+		rejected := []string{}
+		for _, x := range SortedLicenses(choice) {
+			if x.Cmp(chosen) != nil {
+				rejected = append(rejected, x.String())
+			}
+		}
+		election := &Election{
+			Chosen:   chosen.String(),
+			Rejected: rejected,
+		}
+		if _, exists := seen[election.String()]; exists {
+			continue
+		}
+		seen[election.String()] = struct{}{}
+		elections = append(elections, election)
+	}
+	return elections
+}
+
+// Elections returns every choice of license that each of the profiles made. It
+// is sorted by profile, then by file, and then by backend. It returns nil if no
+// choices were made.
+func Elections(output *Output) []*Election {
+	var elections []*Election
+	for _, p := range output.Profiles {
+		profile := output.ProfilesData[p]
+		for _, uid := range SortedUIDs(output.Results) {
+			m := output.Results[uid]
+			for _, backend := range SortedResultBackends(m) {
+				for _, x := range resultElections(profile, m[backend]) {
+					x.Profile = p
+					x.UID = uid
+					x.Backend = backend.String()
+					elections = append(elections, x)
+				}
+			}
+		}
+	}
+	return elections
+}
+
+// NoticeProfile returns the profile whose preferences are used to choose the
+// licenses in the NOTICE file. It's the first of the profiles which has any,
+// since the NOTICE can only have one answer. It returns nil if none do.
+func NoticeProfile(output *Output) *ProfileData {
+	for _, p := range output.Profiles {
+		if profile := output.ProfilesData[p]; profile != nil && len(profile.Prefer) > 0 {
+			return profile
+		}
+	}
+	return nil
+}
+
+// ReturnElections returns the list of elections as a string. Style can be
+// `ansi`, `html`, or `text`.
+func ReturnElections(elections []*Election, style string) (string, error) {
+	if style != "ansi" && style != "html" && style != "text" {
+		return "", fmt.Errorf("invalid style: %s", style)
+	}
+	if style == "html" {
+		s := "<ul>"
+		for _, x := range elections {
+			s += fmt.Sprintf("<li>%s: %s (%s) %s</li>", html.EscapeString(x.Profile), html.EscapeString(x.UID), html.EscapeString(x.Backend), html.EscapeString(x.String()))
+		}
+		s += "</ul>"
+		return s, nil
+	}
+	s := ""
+	for _, x := range elections {
+		s += fmt.Sprintf("%s: %s (%s) %s\n", x.Profile, x.UID, x.Backend, x.String())
+	}
+	return s, nil
+}
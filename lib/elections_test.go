@@ -0,0 +1,95 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestElections(t *testing.T) {
+	b := testBackend("npm")
+	mit := &licenses.License{SPDX: "MIT"}
+	gpl := &licenses.License{SPDX: "GPL-2.0-only"}
+	output := &lib.Output{
+		Program: "yesiscan",
+		Args:    []string{"file:///m/"},
+		Results: interfaces.ResultSet{
+			"file:///m/package-lock.json": {
+				b: {
+					Licenses:   []*licenses.License{gpl, mit},
+					Confidence: 1.0,
+					Choices:    [][]*licenses.License{{mit, gpl}},
+				},
+			},
+			"file:///m/COPYING": {
+				b: {Licenses: []*licenses.License{gpl}, Confidence: 1.0},
+			},
+		},
+		BackendWeights: map[interfaces.Backend]float64{b: 1.0},
+		Blend:          lib.BlendLinear,
+		Profiles:       []string{"copyleft", "strict"},
+		ProfilesData: map[string]*lib.ProfileData{
+			"copyleft": {
+				Licenses: []*licenses.License{gpl},
+				Prefer:   []*licenses.License{mit},
+			},
+			"strict": {
+				Licenses: []*licenses.License{gpl},
+			},
+		},
+	}
+
+	elections := lib.Elections(output)
+	if len(elections) != 1 {
+		t.Fatalf("expected one election, got: %+v", elections)
+	}
+	if x := elections[0]; x.Profile != "copyleft" || x.UID != "file:///m/package-lock.json" || x.String() != "MIT over GPL-2.0-only" {
+		t.Errorf("unexpected election: %+v", x)
+	}
+
+	// the license file still fails, but the lockfile doesn't anymore
+	output.Elections = elections
+	for _, x := range lib.Verdicts(output) {
+		exp := []string{"file:///m/COPYING"}
+		if x.Profile == "strict" {
+			exp = append(exp, "file:///m/package-lock.json")
+		}
+		if strings.Join(x.Violations, " ") != strings.Join(exp, " ") {
+			t.Errorf("profile %s: expected violations %v, got: %v", x.Profile, exp, x.Violations)
+		}
+	}
+
+	s, err := lib.ReturnOutputFile(output)
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if !strings.Contains(s, "elections:\ncopyleft: file:///m/package-lock.json (npm) MIT over GPL-2.0-only\n") {
+		t.Errorf("expected the election in the output, got:\n%s", s)
+	}
+}
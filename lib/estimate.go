@@ -0,0 +1,238 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+const (
+	// ThroughputFileName is the name of the file under the prefix where we
+	// keep the historical throughput of each backend.
+	ThroughputFileName = "throughput.json"
+
+	// InteractiveDuration is how long a scan can take before we no longer
+	// consider it interactive. Backends that are predicted to take longer
+	// than this are suggested for disabling.
+	InteractiveDuration = 30 * time.Second
+)
+
+// Throughput is the historical throughput of each backend, keyed by the
+// backend name. It's learned from the timings of previous scans.
+type Throughput map[string]*BackendThroughput
+
+// BackendThroughput is the amount of work that a backend did in some amount of
+// time. Older scans are decayed so that recent ones count for more.
+type BackendThroughput struct {
+	// Paths is the number of files and directories that were scanned.
+	Paths int64 `json:"paths"`
+
+	// Bytes is the amount of data that was read.
+	Bytes int64 `json:"bytes"`
+
+	Duration time.Duration `json:"duration"`
+}
+
+// LoadThroughput reads the historical throughput from a file.
+func LoadThroughput(filename string) (Throughput, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewBuffer(b))
+	throughput := make(Throughput)
+	if err := decoder.Decode(&throughput); err != nil {
+		return nil, errwrap.Wrapf(err, "error decoding throughput json")
+	}
+	return throughput, nil
+}
+
+// Save writes the throughput to a file.
+func (obj Throughput) Save(filename string, perms *interfaces.Perms) error {
+	b, err := json.MarshalIndent(obj, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(b, '\n'), perms.FileMode())
+}
+
+// Record adds the timings of a scan to the history. Each backend is assumed to
+// have seen all of the bytes that were read. The old values are halved first,
+// so that the history follows any changes in speed.
+func (obj Throughput) Record(timings []*Timing) {
+	var read *Timing
+	for _, t := range timings {
+		if t.Stage == TimingRead {
+			read = t
+		}
+	}
+	if read == nil {
+		return // nothing was read
+	}
+	for _, t := range timings {
+		if !strings.HasPrefix(t.Stage, TimingBackendPrefix) || t.Count == 0 {
+			continue
+		}
+		name := strings.TrimPrefix(t.Stage, TimingBackendPrefix)
+		x, exists := obj[name]
+		if !exists {
+			x = &BackendThroughput{}
+			obj[name] = x
+		}
+		x.Paths = x.Paths/2 + int64(t.Count)
+		x.Bytes = x.Bytes/2 + read.Bytes
+		x.Duration = x.Duration/2 + t.Duration
+	}
+}
+
+// Estimate is the predicted time that one backend will spend scanning.
+type Estimate struct {
+	Backend string
+
+	// Duration is the predicted time. It is zero if we don't know.
+	Duration time.Duration
+
+	// Known is false if there is no history for this backend yet.
+	Known bool
+}
+
+// Estimates predicts the time that each backend will spend on a scan of this
+// many paths and bytes. The time is scaled from the history by the mean of the
+// ratio of the paths and of the bytes, since some backends mostly pay for each
+// path that they start on, and others for each byte that they read. They are
+// returned with the slowest first, and then the unknown ones by name.
+func (obj Throughput) Estimates(backends []string, paths, bytes int64) []*Estimate {
+	estimates := []*Estimate{}
+	for _, name := range backends {
+		e := &Estimate{Backend: name}
+		if x, exists := obj[name]; exists && x.Paths > 0 {
+			ratio := float64(paths) / float64(x.Paths)
+			if x.Bytes > 0 {
+				ratio = (ratio + float64(bytes)/float64(x.Bytes)) / 2.0
+			}
+			e.Duration = time.Duration(float64(x.Duration) * ratio)
+			e.Known = true
+		}
+		estimates = append(estimates, e)
+	}
+	sort.SliceStable(estimates, func(i, j int) bool {
+		if estimates[i].Known != estimates[j].Known {
+			return estimates[i].Known
+		}
+		if estimates[i].Duration != estimates[j].Duration {
+			return estimates[i].Duration > estimates[j].Duration
+		}
+		return estimates[i].Backend < estimates[j].Backend
+	})
+	return estimates
+}
+
+// SuggestDisable returns the backends which are predicted to take longer than
+// the interactive duration. Since the backends run in parallel, the scan takes
+// about as long as the slowest one, so it's these that would need disabling.
+func SuggestDisable(estimates []*Estimate, interactive time.Duration) []string {
+	names := []string{}
+	for _, e := range estimates {
+		if e.Known && e.Duration > interactive {
+			names = append(names, e.Backend)
+		}
+	}
+	return names
+}
+
+// WalkSize counts the paths and the bytes that the iterators will scan, without
+// reading any of them. The paths include the directories, since the backends
+// get to look at those too. Only local filesystem iterators can be walked ahead of
+// time, so the number of iterators which couldn't be is returned as well. Those
+// are the ones which need to download something before we can know.
+func WalkSize(ctx context.Context, iterators []interfaces.Iterator) (int64, int64, int, error) {
+	paths, size, unknown := int64(0), int64(0), 0
+	for _, x := range iterators {
+		it, ok := x.(*iterator.Fs)
+		if !ok || it.Path == nil {
+			unknown++
+			continue
+		}
+		err := filepath.WalkDir(it.Path.Path(), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+			if d.Type()&fs.ModeSymlink != 0 {
+				return nil // the iterator skips these too
+			}
+			fileInfo, err := d.Info()
+			if err != nil {
+				return err
+			}
+			safePath, err := safepath.ParseIntoPath(path, d.IsDir())
+			if err != nil {
+				return err
+			}
+			if skip, err := iterator.SkipPath(safePath, fileInfo); skip || err != nil {
+				return err // nil to skip, interfaces.SkipDir, or error
+			}
+			paths++
+			if !d.IsDir() {
+				size += fileInfo.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, 0, 0, errwrap.Wrapf(err, "could not walk: %s", it.Path.Path())
+		}
+	}
+	return paths, size, unknown, nil
+}
+
+// ReturnEstimates returns the estimates in a human readable form, one per line.
+func ReturnEstimates(estimates []*Estimate) string {
+	s := ""
+	for _, e := range estimates {
+		if !e.Known {
+			s += fmt.Sprintf("%s: unknown, no history yet\n", e.Backend)
+			continue
+		}
+		s += fmt.Sprintf("%s: %s\n", e.Backend, e.Duration.Round(time.Second))
+	}
+	return s
+}
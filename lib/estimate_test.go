@@ -0,0 +1,118 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+func TestEstimates(t *testing.T) {
+	throughput := make(lib.Throughput)
+	throughput.Record([]*lib.Timing{
+		{Stage: lib.TimingRead, Count: 10, Duration: time.Second, Bytes: 1000},
+		{Stage: lib.TimingBackendPrefix + "slow", Count: 10, Duration: 60 * time.Second},
+		{Stage: lib.TimingBackendPrefix + "fast", Count: 10, Duration: time.Second},
+		{Stage: lib.TimingIteration, Count: 1, Duration: time.Second},
+	})
+	if _, exists := throughput[lib.TimingIteration]; exists {
+		t.Errorf("only the backends should be recorded")
+	}
+
+	// twice the paths and the same bytes is one and a half times as long
+	estimates := throughput.Estimates([]string{"fast", "new", "slow"}, 20, 1000)
+	exp := []*lib.Estimate{
+		{Backend: "slow", Duration: 90 * time.Second, Known: true},
+		{Backend: "fast", Duration: 1500 * time.Millisecond, Known: true},
+		{Backend: "new"},
+	}
+	if !reflect.DeepEqual(estimates, exp) {
+		for _, x := range estimates {
+			t.Logf("got: %+v", x)
+		}
+		t.Errorf("unexpected estimates")
+	}
+	if s := lib.SuggestDisable(estimates, lib.InteractiveDuration); !reflect.DeepEqual(s, []string{"slow"}) {
+		t.Errorf("expected to disable the slow backend, got: %+v", s)
+	}
+
+	// the old history is halved when we record again
+	throughput.Record([]*lib.Timing{
+		{Stage: lib.TimingRead, Count: 10, Duration: time.Second, Bytes: 1000},
+		{Stage: lib.TimingBackendPrefix + "fast", Count: 10, Duration: time.Second},
+	})
+	if x := throughput["fast"]; x.Paths != 15 || x.Bytes != 1500 || x.Duration != 1500*time.Millisecond {
+		t.Errorf("unexpected throughput: %+v", x)
+	}
+
+	filename := filepath.Join(t.TempDir(), lib.ThroughputFileName)
+	if err := throughput.Save(filename, nil); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	loaded, err := lib.LoadThroughput(filename)
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if !reflect.DeepEqual(loaded, throughput) {
+		t.Errorf("the throughput changed when it was saved and loaded")
+	}
+}
+
+func TestWalkSize(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0700); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a"), []byte("hello"), 0600); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "b"), []byte("world!"), 0600); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	absDir, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	iterators := []interfaces.Iterator{
+		&iterator.Fs{Path: absDir},
+		&iterator.Git{},
+	}
+	paths, size, unknown, err := lib.WalkSize(context.Background(), iterators)
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	// the two files, and the two directories
+	if paths != 4 || size != 11 || unknown != 1 {
+		t.Errorf("got %d paths, %d bytes, and %d unknown", paths, size, unknown)
+	}
+}
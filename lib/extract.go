@@ -0,0 +1,613 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/xml"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+const (
+	// ExtractFormatPDF is the format name of a PDF document.
+	ExtractFormatPDF = "pdf"
+
+	// ExtractFormatDOCX is the format name of an office open xml document.
+	ExtractFormatDOCX = "docx"
+
+	// ExtractFormatRTF is the format name of a rich text format document.
+	ExtractFormatRTF = "rtf"
+
+	// MaxExtractBytes is the most text that we extract from one document.
+	// Documents are compressed, so without this, a small file could make
+	// us use an unbounded amount of memory.
+	MaxExtractBytes = 64 * 1024 * 1024
+)
+
+var (
+	// docxParts matches the parts of a docx file which contain the text.
+	docxParts = regexp.MustCompile(`^word/(document|header[0-9]*|footer[0-9]*|footnotes|endnotes)\.xml$`)
+
+	// rtfSkip are the rtf destinations which don't contain any text.
+	rtfSkip = map[string]struct{}{
+		"fonttbl":            {},
+		"colortbl":           {},
+		"stylesheet":         {},
+		"info":               {},
+		"pict":               {},
+		"object":             {},
+		"listtable":          {},
+		"listoverridetable":  {},
+		"rsidtbl":            {},
+		"generator":          {},
+		"themedata":          {},
+		"colorschememapping": {},
+		"datastore":          {},
+		"latentstyles":       {},
+		"xmlnstbl":           {},
+	}
+
+	// DeepOnlyExtensions are the file extensions which are only scanned in
+	// deep mode. Without the text extraction, there's nothing in the raw
+	// data that a backend could find, so they're skipped.
+	DeepOnlyExtensions = []string{".pdf"}
+
+	// pdfSkip are the dictionary entries of the pdf streams which we know
+	// can't contain any page text, such as images and embedded fonts.
+	pdfSkip = []string{"/Image", "/ObjStm", "/XRef", "/Metadata", "/Length1", "/Length2", "/Length3", "/FontFile"}
+)
+
+// ExtractFormat returns the document format of this file, or the empty string
+// if it's not one that we know how to extract the text from. The name is used
+// first, and then the data must also look like that kind of document.
+func ExtractFormat(name string, data []byte) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".pdf":
+		if bytes.HasPrefix(data, []byte("%PDF-")) {
+			return ExtractFormatPDF
+		}
+	case ".docx":
+		if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+			return ExtractFormatDOCX
+		}
+	case ".rtf":
+		if bytes.HasPrefix(data, []byte(`{\rtf`)) {
+			return ExtractFormatRTF
+		}
+	}
+	return ""
+}
+
+// DeepOnly returns true if this file name has one of the DeepOnlyExtensions.
+func DeepOnly(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, x := range DeepOnlyExtensions {
+		if ext == x && len(name) > len(ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractText returns the plain text inside of a document, so that it can be
+// scanned like any other text file. It returns the format that was used, and
+// it returns an empty format and no error if this isn't a document that we
+// know about. Only the text is extracted, so the layout is mostly lost.
+func ExtractText(name string, data []byte) ([]byte, string, error) {
+	format := ExtractFormat(name, data)
+	var text []byte
+	var err error
+	switch format {
+	case ExtractFormatPDF:
+		text, err = extractPDF(data)
+	case ExtractFormatDOCX:
+		text, err = extractDOCX(data)
+	case ExtractFormatRTF:
+		text, err = extractRTF(data)
+	default:
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, format, errwrap.Wrapf(err, "could not extract %s text", format)
+	}
+	return text, format, nil
+}
+
+// extractDOCX returns the text of each paragraph in the body, headers, footers
+// and notes of a docx file.
+func extractDOCX(data []byte) ([]byte, error) {
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	for _, f := range z.File {
+		if !docxParts.MatchString(f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		r := io.LimitReader(rc, int64(MaxExtractBytes-buf.Len()))
+		err = extractWordML(buf, r)
+		rc.Close()
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "could not parse %s", f.Name)
+		}
+		if buf.Len() >= MaxExtractBytes {
+			break
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// extractWordML writes the text runs of a word xml part to the buffer, with
+// each paragraph on its own line.
+func extractWordML(buf *bytes.Buffer, r io.Reader) error {
+	d := xml.NewDecoder(r)
+	text := false // are we inside of a text run?
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				text = true
+			case "tab":
+				buf.WriteByte('\t')
+			case "br", "cr":
+				buf.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				text = false
+			case "p":
+				buf.WriteByte('\n')
+			}
+		case xml.CharData:
+			if text {
+				buf.Write(t)
+			}
+		}
+	}
+}
+
+// extractRTF strips the control words and groups from an rtf file, and keeps
+// the text. Destinations which are known not to contain text are skipped.
+func extractRTF(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	skip := []bool{false} // stack of whether each group is skipped
+	uc := 1               // bytes to skip after each \u char
+	pending := 0          // bytes left to skip after a \u char
+	emit := func(s string) {
+		if skip[len(skip)-1] {
+			return
+		}
+		if pending > 0 {
+			pending--
+			return
+		}
+		buf.WriteString(s)
+	}
+
+	for i := 0; i < len(data) && buf.Len() < MaxExtractBytes; i++ {
+		c := data[i]
+		switch c {
+		case '{':
+			skip = append(skip, skip[len(skip)-1])
+			// A \* destination is one that readers may ignore.
+			if bytes.HasPrefix(data[i+1:], []byte(`\*`)) {
+				skip[len(skip)-1] = true
+			}
+			continue
+		case '}':
+			if len(skip) > 1 {
+				skip = skip[:len(skip)-1]
+			}
+			continue
+		case '\r', '\n':
+			continue
+		case '\\':
+		default:
+			emit(string(rune(c)))
+			continue
+		}
+
+		// control symbol or control word
+		if i+1 >= len(data) {
+			break
+		}
+		n := data[i+1]
+		if n == '\\' || n == '{' || n == '}' {
+			emit(string(rune(n)))
+			i++
+			continue
+		}
+		if n == '\'' && i+3 < len(data) { // hex char, assume latin1
+			v, err := strconv.ParseUint(string(data[i+2:i+4]), 16, 8)
+			if err == nil {
+				emit(string(rune(v)))
+			}
+			i += 3
+			continue
+		}
+		if n == '~' {
+			emit(" ")
+			i++
+			continue
+		}
+		if !isASCIILetter(n) { // other control symbols
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j < len(data) && isASCIILetter(data[j]) {
+			j++
+		}
+		word := string(data[i+1 : j])
+		k := j
+		if k < len(data) && data[k] == '-' {
+			k++
+		}
+		for k < len(data) && data[k] >= '0' && data[k] <= '9' {
+			k++
+		}
+		param, hasParam := 0, k > j
+		if hasParam {
+			param, _ = strconv.Atoi(string(data[j:k]))
+		}
+		if k < len(data) && data[k] == ' ' { // the delimiter is eaten
+			k++
+		}
+		i = k - 1
+
+		if _, exists := rtfSkip[word]; exists {
+			skip[len(skip)-1] = true
+			continue
+		}
+		switch word {
+		case "par", "line", "row", "sect", "page":
+			emit("\n")
+		case "tab", "cell":
+			emit("\t")
+		case "uc":
+			if hasParam {
+				uc = param
+			}
+		case "u":
+			if param < 0 {
+				param += 65536
+			}
+			emit(string(rune(param)))
+			if !skip[len(skip)-1] {
+				pending = uc
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// extractPDF returns the text which is drawn by the content streams of a pdf
+// file. The streams may be uncompressed or use the FlateDecode filter. This is
+// not a complete pdf parser. Text in fonts which use a custom encoding can't be
+// decoded without the font, so any characters that aren't printable are lost.
+func extractPDF(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	i := 0
+	for buf.Len() < MaxExtractBytes {
+		s := bytes.Index(data[i:], []byte("stream"))
+		if s == -1 {
+			break
+		}
+		s += i
+		i = s + len("stream")
+		if s >= 3 && string(data[s-3:s]) == "end" {
+			continue
+		}
+		// The stream keyword is followed by an EOL, and the dictionary
+		// is found between the obj keyword and here.
+		start := i
+		if bytes.HasPrefix(data[start:], []byte("\r\n")) {
+			start += 2
+		} else if bytes.HasPrefix(data[start:], []byte("\n")) {
+			start++
+		} else {
+			continue
+		}
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end == -1 {
+			break
+		}
+		end += start
+		i = end + len("endstream")
+
+		dict := data[:s]
+		if o := bytes.LastIndex(dict, []byte("obj")); o != -1 {
+			dict = dict[o:]
+		}
+		stream, err := pdfStream(dict, data[start:end], MaxExtractBytes-buf.Len())
+		if err != nil {
+			return nil, err
+		}
+		if stream == nil {
+			continue
+		}
+		pdfContent(buf, stream)
+	}
+	return buf.Bytes(), nil
+}
+
+// pdfStream returns the decoded data of a pdf stream, or nil if it's not one
+// which could contain page text.
+func pdfStream(dict, data []byte, limit int) ([]byte, error) {
+	for _, x := range pdfSkip {
+		if bytes.Contains(dict, []byte(x)) {
+			return nil, nil
+		}
+	}
+	if !bytes.Contains(dict, []byte("/Filter")) {
+		return data, nil
+	}
+	// We only know about one filter, and it must be the only one.
+	if !bytes.Contains(dict, []byte("/FlateDecode")) || bytes.Count(dict, []byte("Decode")) > 1 {
+		return nil, nil
+	}
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil // not what it says it is, so skip it
+	}
+	defer r.Close()
+	b, err := io.ReadAll(io.LimitReader(r, int64(limit)))
+	if err != nil && len(b) == 0 {
+		return nil, nil // truncated or corrupt streams are common
+	}
+	return b, nil
+}
+
+// pdfContent writes the text of the text showing operators in a pdf content
+// stream to the buffer. Each operator that moves to a new line adds a newline.
+func pdfContent(buf *bytes.Buffer, data []byte) {
+	strs := []string{} // string operands since the last operator
+	newline := func() {
+		if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case isPDFSpace(c):
+			i++
+
+		case c == '%': // comment
+			for i < len(data) && data[i] != '\n' && data[i] != '\r' {
+				i++
+			}
+
+		case c == '(':
+			s, n := pdfLiteral(data[i:])
+			strs = append(strs, s)
+			i += n
+
+		case c == '<' && i+1 < len(data) && data[i+1] == '<':
+			i += 2
+
+		case c == '<':
+			j := bytes.IndexByte(data[i:], '>')
+			if j == -1 {
+				return
+			}
+			strs = append(strs, pdfHex(data[i+1:i+j]))
+			i += j + 1
+
+		case c == '>' || c == '[' || c == ']' || c == '{' || c == '}':
+			i++
+
+		case c == '/' || c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			// Names and numbers are operands that we don't need,
+			// except that a large negative kerning in a TJ array
+			// is usually the space between two words.
+			j := i + 1
+			for j < len(data) && !isPDFSpace(data[j]) && !isPDFDelim(data[j]) {
+				j++
+			}
+			if c != '/' && len(strs) > 0 {
+				if f, err := strconv.ParseFloat(string(data[i:j]), 64); err == nil && f < -200 {
+					strs = append(strs, " ")
+				}
+			}
+			i = j
+
+		default: // operator
+			j := i + 1
+			for j < len(data) && !isPDFSpace(data[j]) && !isPDFDelim(data[j]) {
+				j++
+			}
+			op := string(data[i:j])
+			i = j
+			switch op {
+			case "'", `"`:
+				newline()
+				fallthrough
+			case "Tj", "TJ":
+				for _, s := range strs {
+					buf.WriteString(s)
+				}
+			case "Td", "TD", "T*", "Tm", "ET":
+				newline()
+			case "ID": // inline image data is binary
+				e := bytes.Index(data[i:], []byte("EI"))
+				if e == -1 {
+					return
+				}
+				i += e + 2
+			}
+			strs = []string{}
+		}
+	}
+	newline()
+}
+
+// pdfLiteral decodes the pdf literal string at the start of the data, and it
+// returns the number of bytes that it used.
+func pdfLiteral(data []byte) (string, int) {
+	b := []byte{}
+	depth := 0
+	i := 0
+	for ; i < len(data); i++ {
+		c := data[i]
+		switch c {
+		case '(':
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfText(b), i + 1
+			}
+		case '\\':
+			i++
+			if i >= len(data) {
+				break
+			}
+			switch e := data[i]; e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n': // line continuation
+				if e == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+				continue
+			default:
+				if e >= '0' && e <= '7' { // octal
+					j := i
+					for j < len(data) && j < i+3 && data[j] >= '0' && data[j] <= '7' {
+						j++
+					}
+					v, _ := strconv.ParseUint(string(data[i:j]), 8, 8)
+					c = byte(v)
+					i = j - 1
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return pdfText(b), i
+}
+
+// pdfHex decodes the contents of a pdf hex string.
+func pdfHex(data []byte) string {
+	b := []byte{}
+	h := []byte{}
+	for _, c := range data {
+		if isPDFSpace(c) {
+			continue
+		}
+		h = append(h, c)
+		if len(h) == 2 {
+			v, err := strconv.ParseUint(string(h), 16, 8)
+			if err == nil {
+				b = append(b, byte(v))
+			}
+			h = h[:0]
+		}
+	}
+	if len(h) == 1 { // a missing final digit is zero
+		if v, err := strconv.ParseUint(string(h)+"0", 16, 8); err == nil {
+			b = append(b, byte(v))
+		}
+	}
+	return pdfText(b)
+}
+
+// pdfText converts the bytes of a pdf string into printable text. They're
+// utf-16 if they start with a byte order mark, and otherwise we treat them as
+// latin1, which is close enough to the standard pdf encodings for the ascii
+// text that we care about.
+func pdfText(b []byte) string {
+	runes := []rune{}
+	if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+		u := []uint16{}
+		for i := 2; i+1 < len(b); i += 2 {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		runes = utf16.Decode(u)
+	} else {
+		for _, c := range b {
+			runes = append(runes, rune(c))
+		}
+	}
+	var sb strings.Builder
+	for _, r := range runes {
+		if r == '\t' || r == '\n' || unicode.IsPrint(r) {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// isPDFSpace returns true if the byte is pdf white-space.
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+// isPDFDelim returns true if the byte is a pdf delimiter.
+func isPDFDelim(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) != -1
+}
+
+// isASCIILetter returns true if the byte is an ascii letter.
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,106 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/lib"
+)
+
+func TestExtractText(t *testing.T) {
+	docx := &bytes.Buffer{}
+	z := zip.NewWriter(docx)
+	w, err := z.Create("word/document.xml")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	w.Write([]byte(`<?xml version="1.0"?><w:document xmlns:w="urn:w"><w:body>` +
+		`<w:p><w:r><w:t>Licensed under the </w:t></w:r><w:r><w:t>Apache License</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>Version 2.0</w:t></w:r></w:p></w:body></w:document>`))
+	if err := z.Close(); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+
+	rtf := `{\rtf1\ansi{\fonttbl{\f0 Times;}}{\*\generator Foo;}\f0 Permission is hereby granted,\par free of charge \u169?\'e9.}`
+
+	content := &bytes.Buffer{}
+	zw := zlib.NewWriter(content)
+	zw.Write([]byte("BT /F1 12 Tf 72 712 Td (THE SOFTWARE IS PROVIDED \\(AS IS\\)) Tj T* [(WITH)-100(OUT)-1000(WARRANTY)] TJ ET"))
+	zw.Close()
+	pdf := &bytes.Buffer{}
+	fmt.Fprintf(pdf, "%%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n")
+	fmt.Fprintf(pdf, "4 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", content.Len())
+	pdf.Write(content.Bytes())
+	fmt.Fprintf(pdf, "\nendstream\nendobj\n5 0 obj\n<< /Length 10 /Subtype /Image >>\nstream\n(x) Tj ETX\nendstream\nendobj\n%%%%EOF\n")
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+		text   string
+	}{
+		{"EULA.docx", docx.Bytes(), lib.ExtractFormatDOCX, "Licensed under the Apache License\nVersion 2.0\n"},
+		{"license.RTF", []byte(rtf), lib.ExtractFormatRTF, "Permission is hereby granted,\nfree of charge ©é."},
+		{"eula.pdf", pdf.Bytes(), lib.ExtractFormatPDF, "THE SOFTWARE IS PROVIDED (AS IS)\nWITHOUT WARRANTY\n"},
+		{"notes.txt", []byte("hello"), "", ""},
+		{"fake.pdf", []byte("not a pdf"), "", ""},
+	}
+	for _, tc := range tests {
+		text, format, err := lib.ExtractText(tc.name, tc.data)
+		if err != nil {
+			t.Errorf("%s: error: %+v", tc.name, err)
+			continue
+		}
+		if format != tc.format {
+			t.Errorf("%s: expected format: %q, got: %q", tc.name, tc.format, format)
+		}
+		if got := string(text); got != tc.text {
+			t.Errorf("%s: expected: %q, got: %q", tc.name, tc.text, got)
+		}
+	}
+
+	if _, _, err := lib.ExtractText("broken.docx", []byte("PK\x03\x04junk")); err == nil || !strings.Contains(err.Error(), "docx") {
+		t.Errorf("expected a docx error, got: %+v", err)
+	}
+}
+
+func TestDeepOnly(t *testing.T) {
+	for name, exp := range map[string]bool{
+		"eula.pdf":  true,
+		"EULA.PDF":  true,
+		".pdf":      false,
+		"eula.docx": false,
+		"pdf":       false,
+	} {
+		if got := lib.DeepOnly(name); got != exp {
+			t.Errorf("%s: expected: %t, got: %t", name, exp, got)
+		}
+	}
+}
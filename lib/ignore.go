@@ -0,0 +1,84 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+const (
+	// IgnoreFileName is the default name of the ignore list config file.
+	IgnoreFileName = "ignore.json"
+)
+
+// IgnoreConfig is the datastructure representing the ignore list config that is
+// used for the .json files on disk. It is usually stored in the users config
+// directory, so that it can be shared across many projects via auto config.
+type IgnoreConfig struct {
+	// Hashes is a map of lowercase hex sha256 content hashes to ignore. The
+	// value is a user friendly comment explaining why the file is ignored.
+	Hashes map[string]string `json:"hashes"`
+
+	// Comment adds a user friendly comment for this file.
+	Comment string `json:"comment"`
+}
+
+// LoadIgnoreHashes reads an ignore list config file and returns the set of
+// content hashes that are in it.
+func LoadIgnoreHashes(filename string) (map[string]struct{}, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err // the caller might want to check os.IsNotExist
+	}
+
+	buffer := bytes.NewBuffer(b)
+	if buffer.Len() == 0 {
+		// TODO: should this be an error, or just a silent ignore?
+		return nil, fmt.Errorf("empty input file")
+	}
+	decoder := json.NewDecoder(buffer)
+
+	var ignoreConfig IgnoreConfig // this gets populated during decode
+	if err := decoder.Decode(&ignoreConfig); err != nil {
+		return nil, errwrap.Wrapf(err, "error decoding ignore json output")
+	}
+
+	hashes := make(map[string]struct{})
+	for k := range ignoreConfig.Hashes {
+		h := strings.ToLower(strings.TrimSpace(k))
+		if b, err := hex.DecodeString(h); err != nil || len(b) != 32 {
+			return nil, fmt.Errorf("invalid sha256 hash: %s", k)
+		}
+		hashes[h] = struct{}{}
+	}
+
+	return hashes, nil
+}
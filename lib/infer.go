@@ -0,0 +1,210 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+)
+
+// IsLicenseFile returns true if this UID looks like it is a LICENSE or COPYING
+// file. Directories are never license files.
+func IsLicenseFile(uid string) bool {
+	if strings.HasSuffix(uid, "/") {
+		return false
+	}
+	base := uidPath(uid)
+	if i := strings.LastIndex(base, "/"); i >= 0 {
+		base = base[i+1:]
+	}
+	return iterator.IsLicenseName(base)
+}
+
+// ParentUID returns the UID of the directory that contains this UID. It does
+// this by looking at the path portion of the UID only, so any query string is
+// preserved. This is important for UID's such as the git ones that store the
+// commit hash in there. If we are at the root, then this returns false.
+func ParentUID(uid string) (string, bool) {
+	query := ""
+	if u, err := url.Parse(uid); err == nil && u.RawQuery != "" {
+		query = "?" + u.RawQuery
+	}
+	base := strings.TrimSuffix(uid, query)
+
+	scheme := ""
+	if i := strings.Index(base, "://"); i >= 0 {
+		scheme = base[:i+len("://")]
+		base = base[i+len("://"):]
+	}
+
+	trimmed := strings.TrimSuffix(base, "/")
+	i := strings.LastIndex(trimmed, "/")
+	if i < 0 {
+		return "", false // no parent
+	}
+	return scheme + trimmed[:i+1] + query, true
+}
+
+// uidPath returns the UID with any query string removed.
+func uidPath(uid string) string {
+	if u, err := url.Parse(uid); err == nil && u.RawQuery != "" {
+		return strings.TrimSuffix(uid, "?"+u.RawQuery)
+	}
+	return uid
+}
+
+// InferLicenses is an inference pass that runs after a scan has completed. It
+// looks for every file which did not get any license determination, and then
+// searches upwards through the directory hierarchy for the nearest enclosing
+// LICENSE or COPYING file that did get one. If it finds one, then the results
+// from that file get copied as "inherited" results onto the file that had none.
+// This matches how humans usually reason about repositories. Each new result is
+// flagged as inferred in its Meta field, so that it can be displayed properly.
+// Files which a backend skipped are left alone, since nobody knows what is in
+// them, and an inferred license would hide that they weren't scanned. The
+// results are modified in place, and the new list of passes is returned.
+func InferLicenses(results interfaces.ResultSet, passes []string) []string {
+	// collect the license determinations for each directory
+	dirs := make(map[string]map[interfaces.Backend]*interfaces.Result)
+	dirsFrom := make(map[string]string) // dir uid -> license file uid
+	uids := []string{}
+	for uid := range results {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids) // deterministic choice if we find more than one
+	for _, uid := range uids {
+		if !IsLicenseFile(uid) {
+			continue
+		}
+		m := results[uid]
+		if !hasLicenses(m) {
+			continue
+		}
+		parent, ok := ParentUID(uid)
+		if !ok {
+			continue
+		}
+		if _, exists := dirs[parent]; exists {
+			continue // keep the first one we found
+		}
+		dirs[parent] = m
+		dirsFrom[parent] = uid
+	}
+	if len(dirs) == 0 {
+		return passes // nothing to infer from
+	}
+
+	// find every file without a license determination
+	candidates := []string{}
+	candidates = append(candidates, passes...)
+	for _, uid := range uids {
+		if !hasLicenses(results[uid]) && !hasSkips(results[uid]) {
+			candidates = append(candidates, uid)
+		}
+	}
+
+	inferred := make(map[string]struct{})
+	for _, uid := range candidates {
+		if strings.HasSuffix(uid, "/") {
+			continue // skip directories
+		}
+
+		dir, ok := ParentUID(uid)
+		for ok {
+			if _, exists := dirs[dir]; exists {
+				break
+			}
+			dir, ok = ParentUID(dir)
+		}
+		if !ok {
+			continue // no enclosing license found
+		}
+
+		if _, exists := results[uid]; !exists {
+			results[uid] = make(map[interfaces.Backend]*interfaces.Result)
+		}
+		for backend, result := range dirs[dir] {
+			if len(result.Licenses) == 0 {
+				continue
+			}
+			if old, exists := results[uid][backend]; exists && len(old.Licenses) > 0 {
+				continue // don't overwrite real determinations
+			}
+			results[uid][backend] = inheritResult(result, dirsFrom[dir], true)
+		}
+		inferred[uid] = struct{}{}
+	}
+
+	newPasses := []string{}
+	for _, x := range passes {
+		if _, exists := inferred[x]; exists {
+			continue
+		}
+		newPasses = append(newPasses, x)
+	}
+	return newPasses
+}
+
+// inheritResult builds a copy of a result that is flagged as being inherited
+// from the path with the specified UID. If inferred is true, then it is also
+// flagged as being a guess made by an inference pass.
+func inheritResult(result *interfaces.Result, from string, inferred bool) *interfaces.Result {
+	meta := &interfaces.Meta{}
+	if result.Meta != nil {
+		*meta = *result.Meta // copy
+	}
+	meta.Inherited = from
+	meta.Inferred = inferred
+	meta.Symlink = "" // it's the license file that was the symlink
+
+	return &interfaces.Result{
+		Licenses:   result.Licenses,
+		Confidence: result.Confidence,
+		Meta:       meta,
+	}
+}
+
+// hasSkips returns true if at least one of the results was skipped.
+func hasSkips(m map[interfaces.Backend]*interfaces.Result) bool {
+	for _, result := range m {
+		if result != nil && result.Skip != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLicenses returns true if at least one of the results contains a license.
+func hasLicenses(m map[interfaces.Backend]*interfaces.Result) bool {
+	for _, result := range m {
+		if result != nil && len(result.Licenses) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestParentUID(t *testing.T) {
+	tests := []struct {
+		uid string
+		exp string
+		ok  bool
+	}{
+		{"file:///a/b/c.go", "file:///a/b/", true},
+		{"file:///a/b/", "file:///a/", true},
+		{"file:///", "", false},
+		{"git://github.com/x/y/z.go?sha1=abc", "git://github.com/x/y/?sha1=abc", true},
+		{"git://github.com/x/y/?sha1=abc", "git://github.com/x/?sha1=abc", true},
+	}
+	for _, tc := range tests {
+		s, ok := lib.ParentUID(tc.uid)
+		if s != tc.exp || ok != tc.ok {
+			t.Errorf("uid: %s", tc.uid)
+			t.Errorf("exp: %s (%t)", tc.exp, tc.ok)
+			t.Errorf("got: %s (%t)", s, ok)
+		}
+	}
+}
+
+func TestIsLicenseFile(t *testing.T) {
+	if !lib.IsLicenseFile("file:///a/b/LICENSE.md") {
+		t.Errorf("expected license file")
+	}
+	if !lib.IsLicenseFile("git://github.com/x/y/COPYING?sha1=abc") {
+		t.Errorf("expected license file")
+	}
+	if lib.IsLicenseFile("file:///a/LICENSE/") {
+		t.Errorf("directories are not license files")
+	}
+	if lib.IsLicenseFile("file:///a/main.go") {
+		t.Errorf("unexpected license file")
+	}
+}
+
+func TestInferLicenses(t *testing.T) {
+	b1, b2 := testBackend("b1"), testBackend("b2")
+	mit := []*licenses.License{{SPDX: "MIT"}}
+	apache := []*licenses.License{{SPDX: "Apache-2.0"}}
+	results := interfaces.ResultSet{
+		"file:///p/LICENSE": {
+			b1: {Licenses: mit, Confidence: 1.0},
+		},
+		"file:///p/matched.go": {
+			b1: {Licenses: apache, Confidence: 1.0},
+		},
+		"file:///p/empty.go": {
+			b1: {Licenses: []*licenses.License{}, Confidence: 1.0},
+		},
+		"file:///p/skipped.go": {
+			b1: {Licenses: []*licenses.License{}, Confidence: 1.0},
+			b2: {Skip: fmt.Errorf("timed out")},
+		},
+	}
+	passes := []string{"file:///p/", "file:///p/pass.go", "file:///other/pass.go"}
+
+	passes = lib.InferLicenses(results, passes)
+
+	if exp := []string{"file:///p/", "file:///other/pass.go"}; !reflect.DeepEqual(passes, exp) {
+		t.Errorf("expected passes %v, got %v", exp, passes)
+	}
+
+	for _, uid := range []string{"file:///p/pass.go", "file:///p/empty.go"} {
+		result := results[uid][b1]
+		if result == nil || !reflect.DeepEqual(result.Licenses, mit) {
+			t.Errorf("uid %s: expected the inferred license, got %+v", uid, result)
+			continue
+		}
+		if result.Meta == nil || !result.Meta.Inferred || result.Meta.Inherited != "file:///p/LICENSE" {
+			t.Errorf("uid %s: expected it to be flagged as inferred, got %+v", uid, result.Meta)
+		}
+	}
+
+	// real determinations are never changed
+	if result := results["file:///p/matched.go"][b1]; !reflect.DeepEqual(result.Licenses, apache) || result.Meta != nil {
+		t.Errorf("the matched file was changed: %+v", result)
+	}
+
+	// the skipped file was never scanned, so we don't guess
+	for backend, result := range results["file:///p/skipped.go"] {
+		if len(result.Licenses) > 0 || result.Meta != nil {
+			t.Errorf("backend %s: the skipped file got a license: %+v", backend, result)
+		}
+	}
+	if result := results["file:///p/skipped.go"][b2]; result.Skip == nil {
+		t.Errorf("the skip was lost")
+	}
+
+	// there's nothing to infer from outside of the directory
+	if _, exists := results["file:///other/pass.go"]; exists {
+		t.Errorf("the pass outside of the directory got a result")
+	}
+}
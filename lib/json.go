@@ -0,0 +1,291 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+// JSONOutput is the structured form of Output which can be serialized as json.
+// The backends are referred to by name, since the interfaces can't be encoded.
+type JSONOutput struct {
+	// SchemaVersion is the version of this format. It's always the current
+	// SchemaVersion once this has been decoded.
+	SchemaVersion int `json:"schemaVersion"`
+
+	Program string   `json:"program"`
+	Version string   `json:"version"`
+	Args    []string `json:"args"`
+
+	Backends       map[string]bool    `json:"backends"`
+	BackendWeights map[string]float64 `json:"backend-weights"`
+	Blend          string             `json:"blend"`
+
+	// Results is a map of UID to backend name to result.
+	Results map[string]map[string]*JSONResult `json:"results"`
+
+	// Artifacts is a map of UID to the input argument that it came from.
+	// UID's which couldn't be attributed to an argument are left out.
+	Artifacts map[string]string `json:"artifacts,omitempty"`
+
+	Passes   []string          `json:"passes"`
+	Warnings map[string]string `json:"warnings"`
+	Triage   []*TriageEntry    `json:"triage"`
+	Ignored  []string          `json:"ignored"`
+
+	Profiles []string   `json:"profiles"`
+	Verdicts []*Verdict `json:"verdicts"`
+
+	// Reuse is the list of REUSE compliance reports, if it was enabled.
+	Reuse []*ReuseReport `json:"reuse,omitempty"`
+
+	// Packages is the list of packages inside of the artifacts, if any.
+	Packages []*Package `json:"packages,omitempty"`
+
+	// Checksums is a map of each downloaded input argument to the sha256
+	// sum of what was downloaded.
+	Checksums map[string]string `json:"checksums,omitempty"`
+
+	// Incomplete is why the scan stopped before it was done, if it did.
+	Incomplete string `json:"incomplete,omitempty"`
+
+	// Quick is true if only the quick backends ran.
+	Quick bool `json:"quick,omitempty"`
+
+	// Scoped describes which files were scanned, if it was limited.
+	Scoped string `json:"scoped,omitempty"`
+
+	// Curated is the curation that was applied to each file, keyed by UID.
+	Curated map[string]*Curation `json:"curated,omitempty"`
+
+	// Provenance is the tree of iterators that ran, with the files that
+	// each of them scanned.
+	Provenance []*Provenance `json:"provenance,omitempty"`
+
+	// Languages are the statistics of each language that was scanned.
+	Languages []*LanguageStats `json:"languages,omitempty"`
+
+	// Origins is the first-party or third-party origin of each file.
+	Origins *Origins `json:"origins,omitempty"`
+
+	// Elections is every choice of license that the profiles made.
+	Elections []*Election `json:"elections,omitempty"`
+}
+
+// JSONResult is the structured form of a single result.
+type JSONResult struct {
+	Licenses   []*licenses.License `json:"licenses"`
+	Confidence float64             `json:"confidence"`
+	Skip       string              `json:"skip,omitempty"`
+	Inherited  string              `json:"inherited,omitempty"`
+	Inferred   bool                `json:"inferred,omitempty"`
+	Symlink    string              `json:"symlink,omitempty"`
+	More       []*JSONResult       `json:"more,omitempty"`
+
+	// Raw is the gzip compressed output of the tool which made this
+	// determination, if it was kept. It's base64 encoded in the json.
+	Raw []byte `json:"raw,omitempty"`
+
+	// Diff is the word diff between the canonical text of the license and
+	// the text that was found, for a near match.
+	Diff string `json:"diff,omitempty"`
+
+	// Choices is the license expression that the licenses came from, in
+	// conjunctive normal form, if it offered a choice.
+	Choices [][]*licenses.License `json:"choices,omitempty"`
+}
+
+// NewJSONOutput builds the structured form of the output.
+func NewJSONOutput(output *Output) *JSONOutput {
+	jsonOutput := &JSONOutput{
+		SchemaVersion:  SchemaVersion,
+		Program:        output.Program,
+		Version:        output.Version,
+		Args:           output.Args,
+		Backends:       output.Backends,
+		BackendWeights: make(map[string]float64),
+		Blend:          output.Blend,
+		Results:        make(map[string]map[string]*JSONResult),
+		Artifacts:      make(map[string]string),
+		Passes:         output.Passes,
+		Warnings:       make(map[string]string),
+		Triage:         output.Triage,
+		Ignored:        output.Ignored,
+		Profiles:       output.Profiles,
+		Verdicts:       output.Verdicts,
+		Reuse:          output.Reuse,
+		Packages:       output.Packages,
+		Checksums:      output.Checksums,
+		Incomplete:     output.Incomplete,
+		Quick:          output.Quick,
+		Scoped:         output.Scoped,
+		Provenance:     output.Provenance,
+		Languages:      output.Languages,
+		Origins:        output.Origins,
+		Elections:      output.Elections,
+	}
+	if len(output.Curated) > 0 {
+		jsonOutput.Curated = output.Curated
+	}
+	for backend, weight := range output.BackendWeights {
+		jsonOutput.BackendWeights[backend.String()] = weight
+	}
+	for uid, m := range output.Results {
+		jsonOutput.Results[uid] = make(map[string]*JSONResult)
+		for _, backend := range SortedResultBackends(m) {
+			jsonOutput.Results[uid][backend.String()] = newJSONResult(m[backend])
+			if a := Artifact(m[backend]); a != "" {
+				jsonOutput.Artifacts[uid] = a
+			}
+		}
+	}
+	keys := []string{}
+	for k := range output.Warnings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		jsonOutput.Warnings[k] = output.Warnings[k].Error()
+	}
+	if jsonOutput.Verdicts == nil {
+		jsonOutput.Verdicts = Verdicts(output)
+	}
+	return jsonOutput
+}
+
+// newJSONResult builds the structured form of a result.
+func newJSONResult(result *interfaces.Result) *JSONResult {
+	jsonResult := &JSONResult{
+		Licenses:   SortedLicenses(result.Licenses),
+		Confidence: result.Confidence,
+		Raw:        result.Raw,
+		Diff:       result.Diff,
+		Choices:    result.Choices,
+	}
+	if result.Skip != nil {
+		jsonResult.Skip = result.Skip.Error()
+	}
+	if result.Meta != nil {
+		jsonResult.Inherited = result.Meta.Inherited
+		jsonResult.Inferred = result.Meta.Inferred
+		jsonResult.Symlink = result.Meta.Symlink
+	}
+	for _, x := range result.More {
+		jsonResult.More = append(jsonResult.More, newJSONResult(x))
+	}
+	return jsonResult
+}
+
+// ReturnOutputJSON returns a string of output, formatted as json.
+func ReturnOutputJSON(output *Output) (string, error) {
+	// json encodes maps with sorted keys, so this output is deterministic
+	b, err := json.MarshalIndent(NewJSONOutput(output), "", "\t")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+// jsonBackend stands in for a backend when an output is rebuilt from json. It
+// only has the name of the backend, which is all that's needed for display.
+type jsonBackend string
+
+// String returns the name of the backend.
+func (obj jsonBackend) String() string { return string(obj) }
+
+// NewOutputFromJSON rebuilds an Output from the structured form, so that it can
+// be displayed again. The backends are stand-ins which only have a name, and
+// the artifacts of each result are lost, as are the profiles data and the
+// obligations, which the caller can add back if it has them.
+func NewOutputFromJSON(jsonOutput *JSONOutput) *Output {
+	output := &Output{
+		Program:        jsonOutput.Program,
+		Version:        jsonOutput.Version,
+		Args:           jsonOutput.Args,
+		Backends:       jsonOutput.Backends,
+		Results:        make(map[string]map[interfaces.Backend]*interfaces.Result),
+		Passes:         jsonOutput.Passes,
+		Warnings:       make(map[string]error),
+		Triage:         jsonOutput.Triage,
+		Ignored:        jsonOutput.Ignored,
+		Profiles:       jsonOutput.Profiles,
+		ProfilesData:   make(map[string]*ProfileData),
+		BackendWeights: make(map[interfaces.Backend]float64),
+		Blend:          jsonOutput.Blend,
+		Verdicts:       jsonOutput.Verdicts,
+		Reuse:          jsonOutput.Reuse,
+		Packages:       jsonOutput.Packages,
+		Checksums:      jsonOutput.Checksums,
+		Incomplete:     jsonOutput.Incomplete,
+		Quick:          jsonOutput.Quick,
+		Scoped:         jsonOutput.Scoped,
+		Provenance:     jsonOutput.Provenance,
+		Languages:      jsonOutput.Languages,
+		Origins:        jsonOutput.Origins,
+		Elections:      jsonOutput.Elections,
+		Curated:        jsonOutput.Curated,
+	}
+	for name, weight := range jsonOutput.BackendWeights {
+		output.BackendWeights[jsonBackend(name)] = weight
+	}
+	for uid, m := range jsonOutput.Results {
+		output.Results[uid] = make(map[interfaces.Backend]*interfaces.Result)
+		for name, result := range m {
+			output.Results[uid][jsonBackend(name)] = newResultFromJSON(result)
+		}
+	}
+	for k, v := range jsonOutput.Warnings {
+		output.Warnings[k] = interfaces.Error(v)
+	}
+	return output
+}
+
+// newResultFromJSON rebuilds a result from the structured form.
+func newResultFromJSON(jsonResult *JSONResult) *interfaces.Result {
+	result := &interfaces.Result{
+		Licenses:   jsonResult.Licenses,
+		Confidence: jsonResult.Confidence,
+		Raw:        jsonResult.Raw,
+		Diff:       jsonResult.Diff,
+		Choices:    jsonResult.Choices,
+	}
+	if jsonResult.Skip != "" {
+		result.Skip = interfaces.Error(jsonResult.Skip)
+	}
+	if jsonResult.Inherited != "" || jsonResult.Inferred || jsonResult.Symlink != "" {
+		result.Meta = &interfaces.Meta{
+			Inherited: jsonResult.Inherited,
+			Inferred:  jsonResult.Inferred,
+			Symlink:   jsonResult.Symlink,
+		}
+	}
+	for _, x := range jsonResult.More {
+		result.More = append(result.More, newResultFromJSON(x))
+	}
+	return result
+}
@@ -0,0 +1,88 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestNewOutputFromJSON(t *testing.T) {
+	mit := []*licenses.License{{SPDX: "MIT"}}
+	jsonOutput := &lib.JSONOutput{
+		Program:        "yesiscan",
+		Args:           []string{"/tmp/repo/"},
+		Backends:       map[string]bool{"spdx": true},
+		BackendWeights: map[string]float64{"spdx": 2.0},
+		Results: map[string]map[string]*lib.JSONResult{
+			"file:///tmp/repo/main.go": {
+				"spdx": {Licenses: mit, Confidence: 1.0},
+			},
+			"file:///tmp/repo/sub/main.go": {
+				"spdx": {
+					Licenses:   mit,
+					Confidence: 0.5,
+					Inherited:  "file:///tmp/repo/LICENSE",
+					Inferred:   true,
+					More:       []*lib.JSONResult{{Licenses: mit, Confidence: 0.1}},
+				},
+			},
+			"file:///tmp/repo/big.bin": {
+				"spdx": {Licenses: []*licenses.License{}, Skip: "file too big"},
+			},
+		},
+		Passes:     []string{"file:///tmp/repo/"},
+		Warnings:   map[string]string{"file:///tmp/repo/bad": "oops"},
+		Incomplete: "more than 3 files",
+		Quick:      true,
+	}
+
+	output := lib.NewOutputFromJSON(jsonOutput)
+	if output.Incomplete != jsonOutput.Incomplete {
+		t.Errorf("unexpected incomplete: %s", output.Incomplete)
+	}
+	if err := output.Warnings["file:///tmp/repo/bad"]; err == nil || err.Error() != "oops" {
+		t.Errorf("unexpected warning: %+v", err)
+	}
+	for backend, weight := range output.BackendWeights {
+		if backend.String() != "spdx" || weight != 2.0 {
+			t.Errorf("unexpected weight: %s: %f", backend, weight)
+		}
+	}
+
+	// going back to json should give us the same results
+	again := lib.NewJSONOutput(output)
+	if !reflect.DeepEqual(again.Results, jsonOutput.Results) {
+		t.Errorf("results changed after a round trip")
+	}
+	if !reflect.DeepEqual(again.BackendWeights, jsonOutput.BackendWeights) {
+		t.Errorf("weights changed after a round trip: %+v", again.BackendWeights)
+	}
+	if !again.Quick {
+		t.Errorf("the quick mark was lost after a round trip")
+	}
+}
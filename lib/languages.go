@@ -0,0 +1,239 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"fmt"
+	"html"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// LanguageOther is the language of files that we don't recognize.
+	LanguageOther = "Other"
+)
+
+var (
+	// languageFilenames maps some well known file names to their language.
+	// These are checked before the extension.
+	languageFilenames = map[string]string{
+		"cmakelists.txt": "CMake",
+		"copying":        "Text",
+		"dockerfile":     "Dockerfile",
+		"gemfile":        "Ruby",
+		"gnumakefile":    "Makefile",
+		"go.mod":         "Go",
+		"go.sum":         "Go",
+		"license":        "Text",
+		"makefile":       "Makefile",
+		"notice":         "Text",
+		"rakefile":       "Ruby",
+		"readme":         "Text",
+	}
+
+	// languageExtensions maps each file extension to its language. This is
+	// a small subset of what linguist knows about, with the common ones.
+	languageExtensions = map[string]string{
+		".bash":   "Shell",
+		".c":      "C",
+		".cc":     "C++",
+		".cjs":    "JavaScript",
+		".clj":    "Clojure",
+		".cmake":  "CMake",
+		".cpp":    "C++",
+		".cs":     "C#",
+		".css":    "CSS",
+		".cxx":    "C++",
+		".dart":   "Dart",
+		".erl":    "Erlang",
+		".ex":     "Elixir",
+		".exs":    "Elixir",
+		".fs":     "F#",
+		".go":     "Go",
+		".gradle": "Gradle",
+		".groovy": "Groovy",
+		".h":      "C",
+		".hh":     "C++",
+		".hpp":    "C++",
+		".hs":     "Haskell",
+		".htm":    "HTML",
+		".html":   "HTML",
+		".java":   "Java",
+		".jl":     "Julia",
+		".js":     "JavaScript",
+		".json":   "JSON",
+		".jsx":    "JavaScript",
+		".kt":     "Kotlin",
+		".kts":    "Kotlin",
+		".less":   "Less",
+		".lua":    "Lua",
+		".m":      "Objective-C",
+		".md":     "Markdown",
+		".mjs":    "JavaScript",
+		".ml":     "OCaml",
+		".mm":     "Objective-C++",
+		".php":    "PHP",
+		".pl":     "Perl",
+		".pm":     "Perl",
+		".proto":  "Protocol Buffer",
+		".ps1":    "PowerShell",
+		".py":     "Python",
+		".r":      "R",
+		".rb":     "Ruby",
+		".rs":     "Rust",
+		".rst":    "reStructuredText",
+		".sass":   "Sass",
+		".scala":  "Scala",
+		".scss":   "SCSS",
+		".sh":     "Shell",
+		".sql":    "SQL",
+		".swift":  "Swift",
+		".tf":     "HCL",
+		".toml":   "TOML",
+		".ts":     "TypeScript",
+		".tsx":    "TypeScript",
+		".txt":    "Text",
+		".vue":    "Vue",
+		".xml":    "XML",
+		".yaml":   "YAML",
+		".yml":    "YAML",
+		".zig":    "Zig",
+		".zsh":    "Shell",
+	}
+)
+
+// Language returns the language of a file from its name, in the style of the
+// linguist project, but only by looking at the name. It returns LanguageOther if
+// it doesn't recognize it.
+func Language(name string) string {
+	base := strings.ToLower(path.Base(name))
+	if language, exists := languageFilenames[base]; exists {
+		return language
+	}
+	if language, exists := languageExtensions[path.Ext(base)]; exists {
+		return language
+	}
+	return LanguageOther
+}
+
+// LanguageStats are the statistics of all the files of one language in a scan.
+type LanguageStats struct {
+	// Language is the name of the language.
+	Language string `json:"language"`
+
+	// Files is the number of files of this language that were scanned.
+	Files int64 `json:"files"`
+
+	// Bytes is the total size of those files.
+	Bytes int64 `json:"bytes"`
+
+	// Licenses is the number of those files that each license was found
+	// in, by any of the backends.
+	Licenses map[string]int64 `json:"licenses,omitempty"`
+}
+
+// Languages returns the statistics of each language in a scan, from the size of
+// each file that was read, and the results that the backends found in them.
+// They're sorted with the most bytes first.
+func Languages(sizes map[string]int64, output *Output) []*LanguageStats {
+	languages := make(map[string]*LanguageStats)
+	for uid, size := range sizes {
+		language := Language(uid)
+		stats, exists := languages[language]
+		if !exists {
+			stats = &LanguageStats{
+				Language: language,
+				Licenses: make(map[string]int64),
+			}
+			languages[language] = stats
+		}
+		stats.Files++
+		stats.Bytes += size
+
+		found := make(map[string]struct{})
+		for _, result := range output.Results[uid] {
+			for _, license := range result.Licenses {
+				found[license.String()] = struct{}{}
+			}
+		}
+		for license := range found {
+			stats.Licenses[license]++
+		}
+	}
+
+	list := []*LanguageStats{}
+	for _, stats := range languages {
+		if len(stats.Licenses) == 0 {
+			stats.Licenses = nil
+		}
+		list = append(list, stats)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Bytes != list[j].Bytes {
+			return list[i].Bytes > list[j].Bytes
+		}
+		return list[i].Language < list[j].Language
+	})
+	return list
+}
+
+// ReturnLanguages returns a table of the languages in a scan, with the number of
+// files and bytes of each, and the licenses that were found in them.
+func ReturnLanguages(languages []*LanguageStats, style string) (string, error) {
+	if style != "ansi" && style != "html" && style != "text" {
+		return "", fmt.Errorf("invalid style: %s", style)
+	}
+
+	s := ""
+	if style == "html" {
+		s += "<table>\n"
+		s += "<tr><th>language</th><th>files</th><th>bytes</th><th>licenses</th></tr>\n"
+	}
+	for _, x := range languages {
+		licenses := []string{}
+		for license := range x.Licenses {
+			licenses = append(licenses, license)
+		}
+		sort.Strings(licenses)
+		for i, license := range licenses {
+			licenses[i] = fmt.Sprintf("%s (%d)", license, x.Licenses[license])
+		}
+
+		if style == "html" {
+			s += fmt.Sprintf("<tr><td>%s</td><td>%d</td><td>%d</td><td>%s</td></tr>\n", html.EscapeString(x.Language), x.Files, x.Bytes, html.EscapeString(strings.Join(licenses, ", ")))
+			continue
+		}
+		s += fmt.Sprintf("    %s: %d files, %d bytes", x.Language, x.Files, x.Bytes)
+		if len(licenses) > 0 {
+			s += ": " + strings.Join(licenses, ", ")
+		}
+		s += "\n"
+	}
+	if style == "html" {
+		s += "</table>\n"
+	}
+	return s, nil
+}
@@ -0,0 +1,84 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestLanguage(t *testing.T) {
+	tests := map[string]string{
+		"file:///src/main.go":       "Go",
+		"file:///src/go.mod":        "Go",
+		"file:///src/Makefile":      "Makefile",
+		"file:///src/lib/Foo.JAVA":  "Java",
+		"file:///src/README":        "Text",
+		"file:///src/.travis":       lib.LanguageOther,
+		"file:///src/image.unknown": lib.LanguageOther,
+	}
+	for uid, expected := range tests {
+		if language := lib.Language(uid); language != expected {
+			t.Errorf("%s: expected: %s, got: %s", uid, expected, language)
+		}
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	backend := &mitBackend{}
+	mit := &interfaces.Result{
+		Licenses:   []*licenses.License{{SPDX: "MIT"}},
+		Confidence: 1.0,
+	}
+	output := &lib.Output{
+		Results: map[string]map[interfaces.Backend]*interfaces.Result{
+			"file:///a.go": {backend: mit},
+			"file:///b.go": {backend: mit},
+		},
+	}
+	sizes := map[string]int64{
+		"file:///a.go":    10,
+		"file:///b.go":    20,
+		"file:///c.go":    30,
+		"file:///x.py":    100,
+		"file:///.travis": 5,
+	}
+	languages := lib.Languages(sizes, output)
+	if len(languages) != 3 {
+		t.Errorf("expected 3 languages, got: %d", len(languages))
+		return
+	}
+	if x := languages[0]; x.Language != "Python" || x.Files != 1 || x.Bytes != 100 || x.Licenses != nil {
+		t.Errorf("unexpected stats: %+v", x)
+	}
+	if x := languages[1]; x.Language != "Go" || x.Files != 3 || x.Bytes != 60 || x.Licenses["MIT"] != 2 {
+		t.Errorf("unexpected stats: %+v", x)
+	}
+	if x := languages[2]; x.Language != lib.LanguageOther || x.Files != 1 {
+		t.Errorf("unexpected stats: %+v", x)
+	}
+}
@@ -24,13 +24,23 @@
 package lib
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"runtime"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
 	"github.com/awslabs/yesiscan/util/errwrap"
 	"github.com/awslabs/yesiscan/util/safepath"
 )
@@ -49,6 +59,125 @@ type Core struct {
 	Backends        []interfaces.Backend
 	Iterators       []interfaces.Iterator // TODO: should this be passed into Run instead?
 	ShutdownOnError bool
+
+	// IgnoreHashes is a set of lowercase hex sha256 content hashes. Any file
+	// which matches one of these is never passed to any of the backends.
+	IgnoreHashes map[string]struct{}
+
+	// Curations are the decisions that reviewers made about the findings,
+	// keyed by lowercase hex sha256 content hash. They are applied to every
+	// file with that content.
+	Curations map[string]*Curation
+
+	// Ownership is the set of rules that classify each file as first-party
+	// or third-party. If it is nil, then nothing is classified.
+	Ownership *Ownership
+
+	// Scope limits the scan to the files which were changed recently in the
+	// history of their git repository. The rest are never passed to any of
+	// the backends. If it is nil or empty, then every file is scanned.
+	Scope *Scope
+
+	// Duplicates is the policy for what to do when we get two different
+	// results for the same path and backend. If it is empty, then
+	// DefaultDuplicates is used.
+	Duplicates string
+
+	// MemoryBudget is the maximum number of bytes of file data that all of
+	// the scanners may hold in memory at the same time. When it is used
+	// up, the iterators pause walking until some of it is released. If it
+	// is zero, then there is no limit.
+	MemoryBudget int64
+
+	// MmapThreshold is the file size in bytes at or above which files are
+	// memory mapped instead of read into memory. This avoids a copy and
+	// lets the OS evict the pages. If it is zero, then we never use mmap.
+	MmapThreshold int64
+
+	// Timings collects the time spent in each stage of the scan. If it is
+	// nil, then nothing is collected.
+	Timings *Timings
+
+	// Progress tracks how far along the scan is while it runs. If it is
+	// nil, then nothing is tracked.
+	Progress *Progress
+
+	// Cache stores the result of each backend for each file so that the
+	// same content doesn't get scanned twice. If it is nil, then nothing is
+	// cached.
+	Cache *Cache
+
+	// ExtractCopyrights looks for copyright statements in the header of
+	// each file that is read, so that they can be used in a NOTICE file.
+	ExtractCopyrights bool
+
+	// Deep extracts the text from documents such as PDF, DOCX and RTF files
+	// so that the DataBackends can scan it instead of the raw file.
+	Deep bool
+
+	// OnResult is called with each result as soon as a backend returns it,
+	// long before the run is done. It may be called concurrently, so it
+	// must be safe for that. If it is nil, then nothing is called.
+	OnResult func(uid string, backend interfaces.Backend, result *interfaces.Result)
+
+	// MaxFiles is the most files that a run will scan. If it is zero, then
+	// there is no limit.
+	MaxFiles int64
+
+	// MaxBytes is the most bytes of file data that a run will scan. If it
+	// is zero, then there is no limit.
+	MaxBytes int64
+
+	// MaxDuration is the longest time that a run will scan for. If it is
+	// zero, then there is no limit.
+	MaxDuration time.Duration
+
+	// Expensive is the set of backends which are slow enough that they
+	// only run on the files where the other backends found nothing, or
+	// disagreed. At most one of them per cpu runs at the same time. If it
+	// is nil, then every backend runs on every file.
+	Expensive map[interfaces.Backend]struct{}
+
+	// Workspace is the per-scan directory that the iterators download and
+	// extract into, if there is one, and Prefix is the directory that it
+	// was made under. The UIDs of the files inside of the workspace are
+	// built as if they were inside of the prefix, so that the same input
+	// gets the same UIDs on every scan.
+	Workspace safepath.AbsDir
+	Prefix    safepath.AbsDir
+
+	// exceeded is why the run stopped early, or nil if it ran to the end.
+	exceeded error
+
+	// triage stores information about each file that had no determination.
+	triage map[string]*TriageEntry
+
+	// ignored stores the UID of each file that matched the IgnoreHashes.
+	ignored map[string]struct{}
+
+	// fileHashes stores the hex sha1 sum of each file that was read.
+	fileHashes map[string]string
+
+	// contentHashes stores the hex sha256 sum of each file that was read.
+	contentHashes map[string]string
+
+	// curated stores the curation that was applied to each file.
+	curated map[string]*Curation
+
+	// copyrights stores the copyright statements found in each file.
+	copyrights map[string][]string
+
+	// sizes stores the size in bytes of each file that was read.
+	sizes map[string]int64
+
+	// provenance is the tree of iterators that ran, and what they scanned.
+	provenance []*Provenance
+
+	// origins stores the first-party or third-party origin of each file.
+	origins map[string]string
+
+	// outOfScope stores the UID of each file that was left out by the scope.
+	outOfScope map[string]struct{}
 }
 
 // Init initializes and validates the core struct before use.
@@ -70,9 +199,11 @@ func (obj *Core) Init(ctx context.Context) error {
 		if !ok {
 			continue
 		}
+		start := time.Now()
 		if err := vb.Setup(ctx); err != nil {
 			return errwrap.Wrapf(err, "backend %s setup failed", vb.String())
 		}
+		obj.Timings.Since(TimingSetup, start)
 	}
 
 	return nil
@@ -105,9 +236,56 @@ func (obj *Core) Run(ctx context.Context) (interfaces.ResultSet, []string, map[s
 
 	allResultSets := make(map[string]map[interfaces.Backend]*interfaces.Result)
 	allPasses := make(map[string]struct{})
+	obj.triage = make(map[string]*TriageEntry)
+	obj.ignored = make(map[string]struct{})
+	obj.fileHashes = make(map[string]string)
+	obj.contentHashes = make(map[string]string)
+	obj.curated = make(map[string]*Curation)
+	obj.copyrights = make(map[string][]string)
+	obj.sizes = make(map[string]int64)
+	obj.provenance = nil
+	obj.origins = nil
+	obj.outOfScope = make(map[string]struct{})
 	iteratorErrors := make(map[string]error) // non-fatal iterator errors
 	resultErrors := []error{}
 
+	// The files that each iterator scanned, for the provenance tree.
+	scannedMu := &sync.Mutex{}
+	scannedFiles := make(map[interfaces.Iterator][]string) // guarded by scannedMu
+	scannedPaths := make(map[string]string)                // guarded by scannedMu
+	symlinks := make(map[string]string)                    // guarded by scannedMu
+
+	var scope *scopeFilter // nil scans everything
+	if !obj.Scope.Empty() {
+		scope = newScopeFilter(obj.Scope, obj.Logf)
+	}
+
+	// When we go over any of the limits, we stop and return what we have,
+	// which is why the deadline is on its own context, and not on ctx.
+	obj.exceeded = nil
+	var quota *Quota // nil is unlimited
+	if obj.MaxFiles > 0 || obj.MaxBytes > 0 {
+		quota = &Quota{
+			MaxFiles: obj.MaxFiles,
+			MaxBytes: obj.MaxBytes,
+		}
+	}
+	scanCtx := ctx
+	if obj.MaxDuration > 0 {
+		var scanCancel func()
+		scanCtx, scanCancel = context.WithTimeout(ctx, obj.MaxDuration)
+		defer scanCancel()
+	}
+	exceeded := func() error {
+		if err := quota.Exceeded(); err != nil {
+			return err
+		}
+		if scanCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return errwrap.Wrapf(ErrQuotaExceeded, "took longer than %s", obj.MaxDuration)
+		}
+		return nil
+	}
+
 	wg := &sync.WaitGroup{}
 	defer wg.Wait()
 	wg.Add(1)
@@ -121,6 +299,13 @@ func (obj *Core) Run(ctx context.Context) (interfaces.ResultSet, []string, map[s
 			}
 			results, err := scanner.Result() // this contains a wg
 			passes, _ := scanner.Passes()    // same error
+			triage := scanner.Triage()
+			ignored := scanner.Ignored()
+			fileHashes := scanner.FileHashes()
+			contentHashes := scanner.ContentHashes()
+			curated := scanner.Curated()
+			copyrights := scanner.Copyrights()
+			sizes := scanner.Sizes()
 			if obj.Debug {
 				obj.Logf("result(%d) done", i)
 			}
@@ -140,13 +325,34 @@ func (obj *Core) Run(ctx context.Context) (interfaces.ResultSet, []string, map[s
 			}
 
 			// inefficient, but fine for now
-			allResultSets, err = interfaces.MergeResultSets(allResultSets, results)
+			allResultSets, err = MergeResultSets(obj.Duplicates, allResultSets, results)
 			if err != nil {
 				resultErrors = append(resultErrors, err)
 			}
 			for _, v := range passes { // collect
 				allPasses[v] = struct{}{}
 			}
+			for k, v := range triage {
+				obj.triage[k] = v
+			}
+			for _, v := range ignored {
+				obj.ignored[v] = struct{}{}
+			}
+			for k, v := range fileHashes {
+				obj.fileHashes[k] = v
+			}
+			for k, v := range contentHashes {
+				obj.contentHashes[k] = v
+			}
+			for k, v := range curated {
+				obj.curated[k] = v
+			}
+			for k, v := range copyrights {
+				obj.copyrights[k] = v
+			}
+			for k, v := range sizes {
+				obj.sizes[k] = v
+			}
 		}
 	}()
 
@@ -155,12 +361,42 @@ func (obj *Core) Run(ctx context.Context) (interfaces.ResultSet, []string, map[s
 	for _, x := range obj.Backends {
 		obj.Logf("* %s", x.String())
 	}
+	var budget *Budget // shared by all the scanners
+	if obj.MemoryBudget > 0 {
+		obj.Logf("memory budget: %d bytes", obj.MemoryBudget)
+		budget = &Budget{
+			Max: obj.MemoryBudget,
+		}
+	}
+	var schedule *Schedule // nil runs every backend on every file
+	if len(obj.Expensive) > 0 {
+		names := []string{}
+		for backend := range obj.Expensive {
+			names = append(names, backend.String())
+		}
+		sort.Strings(names)
+		obj.Logf("scheduling the expensive backends: %s", strings.Join(names, ", "))
+		schedule = &Schedule{
+			Expensive: obj.Expensive,
+			Limit:     runtime.NumCPU(),
+		}
+		schedule.Init()
+		defer func() {
+			obj.Logf("schedule: %s", schedule)
+		}()
+	}
 	errors := []error{}
 	once := &sync.Once{}
 	closeFnDo := func() { close(scanners) }
 	closeFn := func() { once.Do(closeFnDo) }
 	defer closeFn()
+Loop:
 	for i := 0; len(iterators) > i; i++ { // while
+		if err := exceeded(); err != nil {
+			obj.exceeded = err
+			obj.Logf("stopping early: %s", err)
+			break // return the partial results
+		}
 		x := iterators[i]
 		defer func() {
 			// TODO: capture err and return it.
@@ -174,7 +410,22 @@ func (obj *Core) Run(ctx context.Context) (interfaces.ResultSet, []string, map[s
 				obj.Logf("scanner: "+format, v...)
 			},
 
-			Backends: obj.Backends,
+			Backends:     obj.Backends,
+			IgnoreHashes: obj.IgnoreHashes,
+			Curations:    obj.Curations,
+			Duplicates:   obj.Duplicates,
+			Budget:       budget,
+
+			MmapThreshold: obj.MmapThreshold,
+			Timings:       obj.Timings,
+			Progress:      obj.Progress,
+			Cache:         obj.Cache,
+
+			ExtractCopyrights: obj.ExtractCopyrights,
+			Deep:              obj.Deep,
+			Quota:             quota,
+			Schedule:          schedule,
+			OnResult:          obj.OnResult,
 		}
 		if err := scanner.Init(); err != nil {
 			return nil, nil, nil, errwrap.Wrapf(err, "scanner init failed")
@@ -196,18 +447,68 @@ func (obj *Core) Run(ctx context.Context) (interfaces.ResultSet, []string, map[s
 		select {
 		case <-ctx.Done():
 			errors = append(errors, ctx.Err())
-			break
+			break Loop
 		default:
 		}
 
 		if obj.Debug {
 			obj.Logf("recurse(%d) start", i)
 		}
-		it, err := x.Recurse(ctx, scanner.Scan)
+		// The iterator calls the scan func synchronously, so we take
+		// the time spent in there away from the time the iterator took.
+		// That way the backends aren't counted twice in the timings.
+		scanMu := &sync.Mutex{}
+		var scanned time.Duration // guarded by scanMu
+		scan := func(ctx context.Context, path safepath.Path, info *interfaces.Info) error {
+			if obj.Workspace.String() != "" {
+				x := *info // copy, since the iterator owns it
+				x.UID = WorkspaceUID(info.UID, obj.Workspace, obj.Prefix)
+				info = &x
+			}
+			if scope != nil && !info.FileInfo.IsDir() {
+				// files inside of archives take the scope of the archive
+				p := path.Path()
+				if rel, root, _ := ownershipLocation(x, p); root != "" {
+					p = filepath.Join(root, filepath.FromSlash(rel))
+				}
+				if !scope.InScope(p) {
+					scannedMu.Lock()
+					obj.outOfScope[info.UID] = struct{}{}
+					scannedMu.Unlock()
+					return nil // skip it
+				}
+			}
+			if !info.FileInfo.IsDir() {
+				scannedMu.Lock()
+				scannedFiles[x] = append(scannedFiles[x], info.UID)
+				scannedPaths[info.UID] = path.Path()
+				if info.Symlink != "" {
+					symlinks[info.UID] = info.Symlink
+				}
+				scannedMu.Unlock()
+			}
+			defer func(start time.Time) {
+				scanMu.Lock()
+				scanned += time.Since(start)
+				scanMu.Unlock()
+			}(time.Now())
+			return scanner.Scan(ctx, path, info)
+		}
+		obj.Progress.SetIterator(x.String())
+		start := time.Now()
+		it, err := x.Recurse(scanCtx, scan)
+		scanMu.Lock()
+		obj.Timings.Add(TimingStage(x), time.Since(start)-scanned)
+		scanMu.Unlock()
 		if obj.Debug {
 			obj.Logf("recurse(%d) done", i)
 		}
-		if e, ok := err.(*interfaces.IteratorError); ok {
+		if err != nil && exceeded() != nil {
+			// This iterator stopped part of the way through, but we
+			// still want what it scanned, so collect it as normal.
+			obj.exceeded = exceeded()
+
+		} else if e, ok := err.(*interfaces.IteratorError); ok {
 			mu.Lock()
 			if err, exists := iteratorErrors[e.Path]; exists {
 				// TODO: should err and e.Err be swapped?
@@ -234,7 +535,7 @@ func (obj *Core) Run(ctx context.Context) (interfaces.ResultSet, []string, map[s
 		case scanners <- scanner: // send
 		case <-ctx.Done():
 			errors = append(errors, ctx.Err())
-			break
+			break Loop
 		}
 
 		iterators = append(iterators, it...)
@@ -255,6 +556,24 @@ func (obj *Core) Run(ctx context.Context) (interfaces.ResultSet, []string, map[s
 
 	obj.Logf("scanning complete!") // clears the last "scanning: ..." message
 
+	obj.provenance = NewProvenance(iterators, scannedFiles)
+	if obj.Ownership != nil {
+		obj.origins = obj.Ownership.Classify(scannedFiles, scannedPaths)
+	}
+
+	// mark the results of the files that we reached through a symlink
+	for uid, symlink := range symlinks {
+		for _, result := range allResultSets[uid] {
+			tagResultSymlink(result, symlink)
+		}
+	}
+
+	// some backends scan whole directories, so they can still find these
+	for k := range obj.outOfScope {
+		delete(allResultSets, k)
+		delete(allPasses, k)
+	}
+
 	// remove any passes which have actually been scanned somewhere
 	for k := range allResultSets {
 		if _, exists := allPasses[k]; exists {
@@ -271,6 +590,87 @@ func (obj *Core) Run(ctx context.Context) (interfaces.ResultSet, []string, map[s
 	return allResultSets, passes, iteratorErrors, nil
 }
 
+// Exceeded returns the error which wraps ErrQuotaExceeded if the last run went
+// over one of its limits and stopped early, or nil if it didn't. The results of
+// that run only include what was scanned before it stopped.
+func (obj *Core) Exceeded() error {
+	return obj.exceeded
+}
+
+// OutOfScope returns the sorted list of UID's that were not scanned because
+// they were not in the Scope. It is only valid after Run.
+func (obj *Core) OutOfScope() []string {
+	outOfScope := []string{}
+	for k := range obj.outOfScope {
+		outOfScope = append(outOfScope, k)
+	}
+	sort.Strings(outOfScope)
+	return outOfScope
+}
+
+// Triage returns the information about each file that had no determination. It
+// is only valid after Run has completed. It may contain entries for files that
+// are not in the final list of passes, so filter it with TriageList.
+func (obj *Core) Triage() map[string]*TriageEntry {
+	return obj.triage
+}
+
+// Ignored returns the sorted list of UID's that were not scanned because their
+// content hash was in the IgnoreHashes set. It is only valid after Run.
+func (obj *Core) Ignored() []string {
+	ignored := []string{}
+	for k := range obj.ignored {
+		ignored = append(ignored, k)
+	}
+	sort.Strings(ignored)
+	return ignored
+}
+
+// FileHashes returns the hex sha1 sum of each file that was read, keyed by UID.
+// This is the checksum that SPDX requires for each file. It is only valid after
+// Run.
+func (obj *Core) FileHashes() map[string]string {
+	return obj.fileHashes
+}
+
+// ContentHashes returns the hex sha256 sum of each file that was read, keyed by
+// UID. This is the content hash that the ignore list and the curations use. It
+// is only valid after Run.
+func (obj *Core) ContentHashes() map[string]string {
+	return obj.contentHashes
+}
+
+// Curated returns the curation that was applied to each file, keyed by UID. It
+// is only valid after Run.
+func (obj *Core) Curated() map[string]*Curation {
+	return obj.curated
+}
+
+// Provenance returns the tree of iterators that ran, with the files that each of
+// them scanned. It is only valid after Run.
+func (obj *Core) Provenance() []*Provenance {
+	return obj.provenance
+}
+
+// Origins returns the first-party or third-party origin of each file that was
+// scanned in the last run, keyed by UID. It is nil if there were no ownership
+// rules.
+func (obj *Core) Origins() map[string]string {
+	return obj.origins
+}
+
+// Copyrights returns the copyright statements found in each file, keyed by UID.
+// It is empty unless ExtractCopyrights was set. It is only valid after Run.
+func (obj *Core) Copyrights() map[string][]string {
+	return obj.copyrights
+}
+
+// Sizes returns the size in bytes of each file that was read, keyed by UID. It
+// is only valid after Run.
+func (obj *Core) Sizes() map[string]int64 {
+	return obj.sizes
+}
+
 // Scanner is functionality that encapsulates the running of each backend. It
 // builds and provides a generic scan mechanism that can be easily passed to the
 // core logic for reuse. Concurrent running of each backend happens in here, and
@@ -281,6 +681,66 @@ type Scanner struct {
 
 	Backends []interfaces.Backend
 
+	// IgnoreHashes is a set of lowercase hex sha256 content hashes. Any file
+	// which matches one of these is never passed to any of the backends.
+	IgnoreHashes map[string]struct{}
+
+	// Curations are the decisions that reviewers made about the findings,
+	// keyed by lowercase hex sha256 content hash. The files which were
+	// overridden or were false positives are never passed to any of the
+	// backends.
+	Curations map[string]*Curation
+
+	// Duplicates is the policy for what to do when we get two different
+	// results for the same path and backend. If it is empty, then
+	// DefaultDuplicates is used.
+	Duplicates string
+
+	// Budget limits how much file data we hold in memory at once. It may be
+	// shared between many scanners. If it is nil, then there is no limit.
+	Budget *Budget
+
+	// MmapThreshold is the file size in bytes at or above which files are
+	// memory mapped instead of read into memory. If it is zero, then we
+	// never use mmap. The data is unmapped once all the backends return,
+	// so a DataBackend must never keep a reference to it after that.
+	MmapThreshold int64
+
+	// Timings collects the time spent reading files and running each of
+	// the backends. If it is nil, then nothing is collected.
+	Timings *Timings
+
+	// Progress tracks the files that are read and the backends that are
+	// running. If it is nil, then nothing is tracked.
+	Progress *Progress
+
+	// Cache stores the result of each backend for each file. If it is nil,
+	// then nothing is cached.
+	Cache *Cache
+
+	// ExtractCopyrights looks for copyright statements in the header of
+	// each file that is read.
+	ExtractCopyrights bool
+
+	// Deep extracts the text from each document that we know how to read,
+	// and passes that to the DataBackends instead of the raw file data. The
+	// content hashes are still of the raw file data.
+	Deep bool
+
+	// OnResult is called with each result as soon as it's stored. It may
+	// be called concurrently. If it is nil, then nothing is called.
+	OnResult func(uid string, backend interfaces.Backend, result *interfaces.Result)
+
+	// Quota counts each file that gets scanned, and once it is exceeded,
+	// Scan returns an error instead. It may be shared between many
+	// scanners. If it is nil, then there is no limit.
+	Quota *Quota
+
+	// Schedule runs the cheap backends on each file first, and only runs
+	// the expensive ones on it if they're needed. It may be shared between
+	// many scanners. If it is nil, then every backend runs at once.
+	Schedule *Schedule
+
 	wg *sync.WaitGroup
 	mu *sync.Mutex
 
@@ -297,9 +757,35 @@ type Scanner struct {
 	// had no determination was made.
 	passes map[string]struct{} // guarded by the mutex
 
+	// triage stores some information about each of the passes so that they
+	// can be reviewed by a human later on.
+	triage map[string]*TriageEntry // guarded by the mutex
+
+	// ignored is the set of files which matched one of the IgnoreHashes.
+	ignored map[string]struct{} // guarded by the mutex
+
+	// fileHashes is the hex sha1 sum of each file that we read.
+	fileHashes map[string]string // guarded by the mutex
+
+	// contentHashes is the hex sha256 sum of each file that we read.
+	contentHashes map[string]string // guarded by the mutex
+
+	// curated is the curation that was applied to each file.
+	curated map[string]*Curation // guarded by the mutex
+
+	// copyrights is the list of copyright statements found in each file.
+	copyrights map[string][]string // guarded by the mutex
+
+	// sizes is the size in bytes of each file that we read.
+	sizes map[string]int64 // guarded by the mutex
+
 	// skipdirs represents a list of dir paths that backends have told us to
 	// skip over. We cache these to avoid unnecessarily asking the backends.
 	skipdirs map[interfaces.Backend]map[string]struct{}
+
+	// roots stores the results of each RootBackend, first by the root that
+	// it scanned, and then by the path of each file underneath it.
+	roots map[interfaces.Backend]map[string]map[string]*interfaces.Result // guarded by the mutex
 }
 
 // Init initializes the scanner struct before use.
@@ -309,8 +795,16 @@ func (obj *Scanner) Init() error {
 
 	obj.results = make(interfaces.ResultSet)
 	obj.passes = make(map[string]struct{})
+	obj.triage = make(map[string]*TriageEntry)
+	obj.ignored = make(map[string]struct{})
+	obj.fileHashes = make(map[string]string)
+	obj.contentHashes = make(map[string]string)
+	obj.curated = make(map[string]*Curation)
+	obj.copyrights = make(map[string][]string)
+	obj.sizes = make(map[string]int64)
 
 	obj.skipdirs = make(map[interfaces.Backend]map[string]struct{})
+	obj.roots = make(map[interfaces.Backend]map[string]map[string]*interfaces.Result)
 	for _, backend := range obj.Backends {
 		_, ok1 := backend.(interfaces.DataBackend)
 		_, ok2 := backend.(interfaces.PathBackend)
@@ -319,8 +813,8 @@ func (obj *Scanner) Init() error {
 		if !ok1 && !ok2 && !ok3 && !ok4 {
 			return fmt.Errorf("invalid backend: %s", backend.String())
 		}
-		if !ok1 && !ok2 { // TODO: remove this when we implement them!
-			return fmt.Errorf("the RootBackend and SeekBackend is not yet supported")
+		if !ok1 && !ok2 && !ok3 { // TODO: remove this when we implement it!
+			return fmt.Errorf("the SeekBackend is not yet supported")
 		}
 
 		obj.skipdirs[backend] = make(map[string]struct{})
@@ -329,6 +823,55 @@ func (obj *Scanner) Init() error {
 	return nil
 }
 
+// scanRoot runs a RootBackend on this root, and stores the results that it got
+// for each path underneath it, so that they can be looked up by rootResult.
+func (obj *Scanner) scanRoot(ctx context.Context, backend interfaces.RootBackend, path safepath.Path, info *interfaces.Info) error {
+	resultSet, err := backend.ScanRoot(ctx, path, info)
+	if err != nil {
+		return err
+	}
+	if resultSet == nil {
+		return nil // it declined, so each path gets scanned on its own
+	}
+	results := make(map[string]*interfaces.Result)
+	for p, m := range resultSet {
+		if result, exists := m[backend]; exists {
+			results[p] = result
+		}
+	}
+
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	if _, exists := obj.roots[backend]; !exists {
+		obj.roots[backend] = make(map[string]map[string]*interfaces.Result)
+	}
+	obj.roots[backend][path.String()] = results // dirs end with a slash
+	return nil
+}
+
+// rootResult returns the result that a RootBackend got for this path, if it is
+// underneath a root that the backend has scanned. The result can be nil if the
+// backend didn't find anything. If there's more than one root that this path is
+// in, then the closest one is used.
+func (obj *Scanner) rootResult(backend interfaces.Backend, path safepath.Path) (*interfaces.Result, bool) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	p := path.String()
+	found := ""
+	for root := range obj.roots[backend] {
+		if root != p && !(strings.HasSuffix(root, "/") && strings.HasPrefix(p, root)) {
+			continue
+		}
+		if len(root) > len(found) {
+			found = root
+		}
+	}
+	if found == "" {
+		return nil, false
+	}
+	return obj.roots[backend][found][path.Path()], true
+}
+
 // Scan runs the correct scanning function of each backend. This function will
 // get called in parallel, by multiple different iterators. As a result, it must
 // be thread-safe. This function is passed in to the iterators by Core.
@@ -340,19 +883,150 @@ func (obj *Scanner) Scan(ctx context.Context, path safepath.Path, info *interfac
 	// TODO: we could switch and avoid doing this if we knew that
 	// zero backends were going to need it, but we know most will,
 	// so avoid optimizing early, and skip pre-checking for this.
+	if !obj.Deep && !info.FileInfo.IsDir() && DeepOnly(info.FileInfo.Name()) {
+		return nil // nothing in here for any backend to find
+	}
+
 	var data []byte
 	var err error
 	if !info.FileInfo.IsDir() {
+		// Stop the walk of the calling iterator when we're over quota.
+		if err := obj.Quota.Add(info.FileInfo.Size()); err != nil {
+			return err
+		}
+		obj.Progress.AddFile(info.FileInfo.Size())
+	}
+	if size := info.FileInfo.Size(); !info.FileInfo.IsDir() && obj.Budget != nil {
+		// This blocks the walk of the calling iterator when we're out
+		// of memory budget, which is how we apply the backpressure.
+		if err := obj.Budget.Acquire(ctx, size); err != nil {
+			return err
+		}
+		defer obj.Budget.Release(size)
+	}
+	readStart := time.Now()
+	if !info.FileInfo.IsDir() && info.FS != nil {
+		data, err = fs.ReadFile(info.FS, iterator.IOFSName(path))
+		if err != nil {
+			return err // TODO: errwrap?
+		}
+	} else if size := info.FileInfo.Size(); !info.FileInfo.IsDir() && obj.MmapThreshold > 0 && size > 0 && size >= obj.MmapThreshold {
+		var unmap func() error
+		data, unmap, err = mmapFile(path.Path(), size)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := unmap(); err != nil {
+				obj.Logf("could not unmap: %s: %+v", path, err)
+			}
+		}()
+	} else if !info.FileInfo.IsDir() {
 		data, err = os.ReadFile(path.Path())
 		if err != nil {
 			return err // TODO: errwrap?
 		}
 	}
 
+	if !info.FileInfo.IsDir() {
+		obj.Timings.Since(TimingRead, readStart)
+		obj.Timings.AddBytes(TimingRead, int64(len(data)))
+	}
+
+	sum := "" // content hash
+	if !info.FileInfo.IsDir() {
+		h := sha256.Sum256(data)
+		sum = hex.EncodeToString(h[:])
+		h1 := sha1.Sum(data)
+		obj.mu.Lock()
+		obj.contentHashes[info.UID] = sum
+		obj.fileHashes[info.UID] = hex.EncodeToString(h1[:])
+		obj.sizes[info.UID] = int64(len(data))
+		obj.mu.Unlock()
+	}
+
+	// The cache key has to change with the data that the backends see.
+	cacheSum := sum
+	if obj.Deep && !info.FileInfo.IsDir() {
+		text, format, err := ExtractText(info.FileInfo.Name(), data)
+		if err != nil {
+			// Fall back to the raw data, which is what we'd see
+			// without deep mode.
+			obj.Logf("could not extract: %s: %+v", path, err)
+		} else if len(bytes.TrimSpace(text)) > 0 {
+			if obj.Debug {
+				obj.Logf("extracted: %s (%s)", path, format)
+			}
+			data = text
+			h := sha256.Sum256(data)
+			cacheSum = format + ":" + hex.EncodeToString(h[:])
+		}
+	}
+
+	if obj.ExtractCopyrights && !info.FileInfo.IsDir() {
+		if copyrights := FindCopyrights(data); len(copyrights) > 0 {
+			obj.mu.Lock()
+			obj.copyrights[info.UID] = copyrights
+			obj.mu.Unlock()
+		}
+	}
+
+	if len(obj.IgnoreHashes) > 0 && !info.FileInfo.IsDir() {
+		if _, exists := obj.IgnoreHashes[sum]; exists {
+			if obj.Debug {
+				obj.Logf("ignored: %s", path)
+			}
+			obj.mu.Lock()
+			obj.ignored[info.UID] = struct{}{}
+			obj.mu.Unlock()
+			return nil // don't dispatch to any backends
+		}
+	}
+
+	if curation, exists := obj.Curations[sum]; exists && !info.FileInfo.IsDir() {
+		if obj.Debug {
+			obj.Logf("curated: %s (%s)", path, curation.Decision)
+		}
+		result, err := curation.Result()
+		if err != nil {
+			return err
+		}
+		obj.mu.Lock()
+		obj.curated[info.UID] = curation
+		if result != nil {
+			obj.results[info.UID] = map[interfaces.Backend]*interfaces.Result{
+				CurationBackend: result,
+			}
+		}
+		obj.mu.Unlock()
+		if curation.Decision != CurationAccepted {
+			return nil // don't dispatch to any backends
+		}
+	}
+
 	obj.Logf("scanning: %s", path)
 
+	// The expensive backends go last, and they only run if the results of
+	// all the ones before them leave the question open.
+	backends, expensive := obj.Schedule.Split(obj.Backends, info)
+	backends = append(backends, expensive...)
+	cheap := len(backends) - len(expensive)
+
 Loop:
-	for _, backend := range obj.Backends {
+	for i, backend := range backends {
+		if i == cheap { // first expensive backend
+			wg.Wait()
+			obj.mu.Lock()
+			needed := obj.Schedule.Needed(obj.results[info.UID])
+			obj.mu.Unlock()
+			if !needed {
+				if obj.Debug {
+					obj.Logf("not needed: %s", path)
+				}
+				break
+			}
+		}
+
 		// Some backends aren't particularly well-behaved with
 		// regards to obeying the context cancellation signal.
 		// In an effort to short-circuit things if needed, we
@@ -378,32 +1052,99 @@ Loop:
 			var result *interfaces.Result
 			var err error
 
-			// XXX: cache lookups here?
-			//	if x, ok := backend.(interfaces.CachedDataBackend); ok {
-			//		result, err = x.LookupData(ctx, data, info)
-			//	} else if x, ok := backend.(interfaces.CachedPathBackend); ok {
-			//		result, err = x.LookupPath(ctx, path, info)
-			//	}
-			// XXX: if err, look at cache policy, otherwise continue or err
-
-			if _, exists := obj.skipdirs[backend][info.UID]; info.FileInfo.IsDir() && exists {
+			// If this path is inside of a directory that this
+			// backend already made a determination for, then we
+			// don't need to run it again. Instead, we copy that
+			// result here so that this path doesn't look empty.
+			if dir, exists := obj.skipDir(backend, info.UID); exists {
 				if obj.Debug {
 					obj.Logf("skip dir: %s", path)
 				}
+				obj.mu.Lock()
+				defer obj.mu.Unlock()
+				r, exists := obj.results[dir][backend]
+				if !exists || dir == info.UID {
+					return
+				}
+				if _, exists := obj.results[info.UID]; !exists {
+					obj.results[info.UID] = make(map[interfaces.Backend]*interfaces.Result)
+				}
+				inherited := inheritResult(r, dir, false)
+				obj.results[info.UID][backend] = inherited
+				if obj.OnResult != nil {
+					obj.OnResult(info.UID, backend, inherited)
+				}
 				return
 			}
 
+			// Only files get cached, since the result for a dir
+			// depends on more than what we know about it here.
+			key := ""
+			cached := false
+			if obj.Cache != nil && !info.FileInfo.IsDir() && Cacheable(backend) {
+				key = obj.Cache.Key(backend, info.FileInfo.Name(), cacheSum)
+				if result, cached, err = obj.Cache.Get(ctx, key); err != nil {
+					obj.Logf("cache error: %+v", err)
+					result, cached, err = nil, false, nil // scan it
+				}
+			}
+
+			if !cached {
+				if err := obj.Schedule.Acquire(ctx, backend); err != nil {
+					mu.Lock()
+					errors = append(errors, err)
+					mu.Unlock()
+					return // goroutine ends
+				}
+				defer obj.Schedule.Release(backend)
+			}
+
 			// XXX: wrap these in a helper function
-			if x, ok := backend.(interfaces.DataBackend); ok {
+			start := time.Now()
+			if x, ok := backend.(interfaces.RootBackend); ok && !cached && info.FS == nil && info.Root {
+				// This runs once for everything under this root,
+				// and then each path looks up its own result.
+				obj.Progress.Begin(backend.String())
+				err := obj.scanRoot(ctx, x, path, info)
+				obj.Progress.End(backend.String())
+				if err != nil {
+					mu.Lock()
+					errors = append(errors, err)
+					mu.Unlock()
+					return // goroutine ends
+				}
+			}
+			if cached {
+				if obj.Debug {
+					obj.Logf("cached: %s", path)
+				}
+			} else if r, exists := obj.rootResult(backend, path); exists {
+				result = r
+			} else if x, ok := backend.(interfaces.DataBackend); ok {
 				//if len(data) == 0 { // possible directory
 				//	return // skip directories!
 				//}
+				obj.Progress.Begin(backend.String())
 				result, err = x.ScanData(ctx, data, info)
-			} else if x, ok := backend.(interfaces.PathBackend); ok {
+				obj.Progress.End(backend.String())
+			} else if x, ok := backend.(interfaces.PathBackend); ok && info.FS == nil {
+				// The path only exists on disk if there's no FS.
+				obj.Progress.Begin(backend.String())
 				result, err = x.ScanPath(ctx, path, info)
+				obj.Progress.End(backend.String())
 			} else {
 				return
 			}
+			if !cached {
+				obj.Timings.Since(TimingBackendPrefix+backend.String(), start)
+			}
+
+			// A backend that can't identify the license is the
+			// same as one that returns no result, so record it
+			// as a pass instead of as an error.
+			if err != nil && errwrap.Cause(err) == interfaces.ErrUnknownLicense {
+				result, err = nil, nil
+			}
 
 			// If a backend returns interfaces.SkipDir, then
 			// this is the signal that it doesn't need to
@@ -431,11 +1172,20 @@ Loop:
 				return // goroutine ends
 			}
 
+			if key != "" && !cached && err == nil {
+				if err := obj.Cache.Put(ctx, key, result); err != nil {
+					obj.Logf("cache error: %+v", err)
+				}
+			}
+
 			// This should also ingest the SkipDir values...
 			if result == nil { // skip nil results
-				mu.Lock()
+				obj.mu.Lock()
 				obj.passes[info.UID] = struct{}{}
-				mu.Unlock()
+				if _, exists := obj.triage[info.UID]; !exists && !info.FileInfo.IsDir() {
+					obj.triage[info.UID] = NewTriageEntry(data, info)
+				}
+				obj.mu.Unlock()
 				return
 			}
 			// tag (annotate) the result
@@ -451,25 +1201,33 @@ Loop:
 				// if there's a bug, or if we asked to scan the
 				// same thing more than once. As a result, run a
 				// cmp on both results, and if they're the same,
-				// then we can safely ignore this issue.
+				// then we can safely ignore this issue. If not,
+				// then the duplicates policy decides for us.
 				// XXX: can cached results cause this to fail?
-				if err := old.Cmp(result); err != nil {
+				r, err := ResolveDuplicate(obj.Duplicates, old, result)
+				if err != nil {
+					obj.mu.Unlock()
 					e := errwrap.Wrapf(err, "duplicate result for path: %s", path)
 					mu.Lock()
 					errors = append(errors, e)
 					mu.Unlock()
 					return // goroutine ends
 				}
+				if obj.Debug && r != result {
+					obj.Logf("duplicate result for path: %s, kept the old one", path)
+				}
+				if r == old {
+					obj.mu.Unlock()
+					return // nothing new to tell anyone
+				}
+				result = r
 			}
 			obj.results[info.UID][backend] = result
 			obj.mu.Unlock()
 
-			// XXX: cache results
-			//	if x, ok := backend.(interfaces.CachedDataBackend); ok {
-			//		result, err = x.LookupData(ctx, data, info)
-			//	} else if x, ok := backend.(interfaces.CachedPathBackend); ok {
-			//		result, err = x.LookupPath(ctx, path, info)
-			//	}
+			if obj.OnResult != nil {
+				obj.OnResult(info.UID, backend, result)
+			}
 
 		}(backend)
 	}
@@ -486,6 +1244,22 @@ Loop:
 	return nil
 }
 
+// skipDir returns the UID of the directory that this backend asked to skip if
+// this path is either that directory or is inside of it.
+func (obj *Scanner) skipDir(backend interfaces.Backend, uid string) (string, bool) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	if len(obj.skipdirs[backend]) == 0 {
+		return "", false // fast path
+	}
+	for dir, ok := uid, true; ok; dir, ok = ParentUID(dir) {
+		if _, exists := obj.skipdirs[backend][dir]; exists {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
 // Result returns the results after a Scan operation is run. It contains a Wait
 // the blocks until all the Scan work has finished. To cancel and unblock this,
 // cancel the context that was passed in to the Scan function. Do *not* call
@@ -521,6 +1295,91 @@ func (obj *Scanner) Passes() ([]string, error) {
 	return result, nil // TODO: should we pass the Recurse errors here?
 }
 
+// Triage returns the triage information for each file that was a pass. Like
+// Passes, it waits for all the Scan work to finish first.
+func (obj *Scanner) Triage() map[string]*TriageEntry {
+	obj.wg.Wait()
+	triage := make(map[string]*TriageEntry)
+	for k, v := range obj.triage {
+		if _, exists := obj.results[k]; exists {
+			continue // not a pass
+		}
+		triage[k] = v
+	}
+	return triage
+}
+
+// ContentHashes returns the hex sha256 sum of each file that was read, keyed by
+// UID. Like Passes, it waits for all the Scan work to finish first.
+func (obj *Scanner) ContentHashes() map[string]string {
+	obj.wg.Wait()
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	contentHashes := make(map[string]string)
+	for k, v := range obj.contentHashes {
+		contentHashes[k] = v
+	}
+	return contentHashes
+}
+
+// Curated returns the curation that was applied to each file, keyed by UID.
+// Like Passes, it waits for all the Scan work to finish first.
+func (obj *Scanner) Curated() map[string]*Curation {
+	obj.wg.Wait()
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	curated := make(map[string]*Curation)
+	for k, v := range obj.curated {
+		curated[k] = v
+	}
+	return curated
+}
+
+// Ignored returns the list of files that matched one of the IgnoreHashes. Like
+// Passes, it waits for all the Scan work to finish first.
+func (obj *Scanner) Ignored() []string {
+	obj.wg.Wait()
+	ignored := []string{}
+	for k := range obj.ignored {
+		ignored = append(ignored, k)
+	}
+	sort.Strings(ignored)
+	return ignored
+}
+
+// FileHashes returns the hex sha1 sum of each file that was read, keyed by UID.
+// Like Passes, it waits for all the Scan work to finish first.
+func (obj *Scanner) FileHashes() map[string]string {
+	obj.wg.Wait()
+	fileHashes := make(map[string]string)
+	for k, v := range obj.fileHashes {
+		fileHashes[k] = v
+	}
+	return fileHashes
+}
+
+// Copyrights returns the copyright statements found in each file, keyed by UID.
+// Like Passes, it waits for all the Scan work to finish first.
+func (obj *Scanner) Copyrights() map[string][]string {
+	obj.wg.Wait()
+	copyrights := make(map[string][]string)
+	for k, v := range obj.copyrights {
+		copyrights[k] = v
+	}
+	return copyrights
+}
+
+// Sizes returns the size in bytes of each file that was read, keyed by UID. Like
+// Passes, it waits for all the Scan work to finish first.
+func (obj *Scanner) Sizes() map[string]int64 {
+	obj.wg.Wait()
+	sizes := make(map[string]int64)
+	for k, v := range obj.sizes {
+		sizes[k] = v
+	}
+	return sizes
+}
+
 func tagResultBackend(result *interfaces.Result, backend interfaces.Backend) {
 	if result.Meta == nil {
 		result.Meta = &interfaces.Meta{}
@@ -546,3 +1405,15 @@ func tagResultIterator(result *interfaces.Result, iterator interfaces.Iterator)
 		tagResultIterator(x, iterator)
 	}
 }
+
+// tagResultSymlink marks the result, and any of its nested results, as being of
+// the file that the path is a symlink to.
+func tagResultSymlink(result *interfaces.Result, symlink string) {
+	if result.Meta == nil {
+		result.Meta = &interfaces.Meta{}
+	}
+	result.Meta.Symlink = symlink
+	for _, x := range result.More {
+		tagResultSymlink(x, symlink)
+	}
+}
@@ -0,0 +1,849 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/licenses"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+// skipDirBackend makes a determination for the whole directory that it's
+// first shown, and then asks to skip everything inside of it.
+type skipDirBackend struct{}
+
+func (obj *skipDirBackend) String() string { return "skipdir" }
+
+func (obj *skipDirBackend) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
+	if !info.FileInfo.IsDir() {
+		return nil, nil
+	}
+	result := &interfaces.Result{
+		Licenses:   []*licenses.License{{SPDX: "MIT"}},
+		Confidence: 1.0,
+	}
+	return result, interfaces.SkipDir
+}
+
+func TestSkipDirPropagation(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0700); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "file.txt"), []byte("hello\n"), 0600); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	absDir, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	logf := func(format string, v ...interface{}) {
+		t.Logf(format, v...)
+	}
+
+	core := &lib.Core{
+		Logf:     logf,
+		Backends: []interfaces.Backend{&skipDirBackend{}},
+		Iterators: []interfaces.Iterator{
+			&iterator.Fs{
+				Logf:   logf,
+				Prefix: absDir,
+				Path:   absDir,
+			},
+		},
+	}
+	if err := core.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	results, _, _, err := core.Run(context.Background())
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	root := iterator.FileScheme + absDir.String()
+	child := root + "sub/file.txt"
+	for _, m := range []map[interfaces.Backend]*interfaces.Result{results[root], results[child]} {
+		if len(m) != 1 {
+			t.Errorf("expected one result, got: %d", len(m))
+			return
+		}
+	}
+	for _, result := range results[child] {
+		if result.Meta == nil || result.Meta.Inherited != root {
+			t.Errorf("expected result to be inherited from: %s", root)
+		}
+		if result.Meta != nil && result.Meta.Inferred {
+			t.Errorf("expected result to not be inferred")
+		}
+	}
+}
+
+// rootBackend walks the whole root itself, and finds the MIT license in any
+// file which mentions it. It counts how many times that it was run.
+type rootBackend struct {
+	runs int
+}
+
+func (obj *rootBackend) String() string { return "root" }
+
+func (obj *rootBackend) ScanRoot(ctx context.Context, path safepath.Path, info *interfaces.Info) (interfaces.ResultSet, error) {
+	obj.runs++
+	resultSet := make(interfaces.ResultSet)
+	err := filepath.Walk(path.Path(), func(p string, fileInfo os.FileInfo, err error) error {
+		if err != nil || fileInfo.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil || !strings.Contains(string(data), "MIT") {
+			return err
+		}
+		resultSet[p] = map[interfaces.Backend]*interfaces.Result{
+			obj: {
+				Licenses:   []*licenses.License{{SPDX: "MIT"}},
+				Confidence: 1.0,
+			},
+		}
+		return nil
+	})
+	return resultSet, err
+}
+
+func TestRootBackend(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0700); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	files := map[string]string{
+		"LICENSE":      "MIT License\n",
+		"sub/main.go":  "package main\n",
+		"sub/other.go": "// SPDX-License-Identifier: MIT\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+	}
+	absDir, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	logf := func(format string, v ...interface{}) {
+		t.Logf(format, v...)
+	}
+
+	backend := &rootBackend{}
+	core := &lib.Core{
+		Logf:     logf,
+		Backends: []interfaces.Backend{backend},
+		Iterators: []interfaces.Iterator{
+			&iterator.Fs{
+				Logf:   logf,
+				Prefix: absDir,
+				Path:   absDir,
+			},
+		},
+	}
+	if err := core.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	results, _, _, err := core.Run(context.Background())
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	if backend.runs != 1 {
+		t.Errorf("expected one run, got: %d", backend.runs)
+	}
+	root := iterator.FileScheme + absDir.String()
+	for _, name := range []string{"LICENSE", "sub/other.go"} {
+		if len(results[root+name]) != 1 {
+			t.Errorf("expected a result for: %s", name)
+		}
+	}
+	if _, exists := results[root+"sub/main.go"]; exists {
+		t.Errorf("expected no result for: sub/main.go")
+	}
+}
+
+// mitBackend finds the MIT license in any file which mentions it.
+type mitBackend struct{}
+
+func (obj *mitBackend) String() string { return "mit" }
+
+func (obj *mitBackend) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
+	if !strings.Contains(string(data), "MIT") {
+		return nil, nil
+	}
+	result := &interfaces.Result{
+		Licenses:   []*licenses.License{{SPDX: "MIT"}},
+		Confidence: 1.0,
+	}
+	return result, nil
+}
+
+func TestIOFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"LICENSE":         {Data: []byte("MIT License\n")},
+		"src/main.go":     {Data: []byte("package main\n")},
+		".git/config":     {Data: []byte("MIT\n")}, // skipped
+		"src/vendor/y.go": {Data: []byte("// MIT\n")},
+	}
+	logf := func(format string, v ...interface{}) {
+		t.Logf(format, v...)
+	}
+
+	core := &lib.Core{
+		Logf:     logf,
+		Backends: []interfaces.Backend{&mitBackend{}},
+		Iterators: []interfaces.Iterator{
+			&iterator.IOFS{
+				Logf: logf,
+				FS:   fsys,
+				Name: "test",
+			},
+		},
+	}
+	if err := core.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	results, _, _, err := core.Run(context.Background())
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	if _, exists := results[iterator.IOFSScheme+"test/LICENSE"]; !exists {
+		t.Errorf("expected a result for the license file")
+	}
+	if _, exists := results[iterator.IOFSScheme+"test/src/main.go"]; exists {
+		t.Errorf("expected no result for the main file")
+	}
+	if len(results) != 2 {
+		t.Errorf("expected two results, got: %d", len(results))
+	}
+}
+
+func TestCancelledRun(t *testing.T) {
+	logf := func(format string, v ...interface{}) {
+		t.Logf(format, v...)
+	}
+	iterators := []interfaces.Iterator{}
+	for _, name := range []string{"one", "two"} {
+		iterators = append(iterators, &iterator.IOFS{
+			Logf: logf,
+			FS:   fstest.MapFS{"LICENSE": {Data: []byte("MIT License\n")}},
+			Name: name,
+		})
+	}
+	core := &lib.Core{
+		Logf:      logf,
+		Backends:  []interfaces.Backend{&mitBackend{}},
+		Iterators: iterators,
+	}
+	if err := core.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // before it even starts
+	if _, _, _, err := core.Run(ctx); err == nil {
+		t.Errorf("expected an error from a cancelled run")
+	}
+}
+
+func TestNewWorkspace(t *testing.T) {
+	prefix, err := safepath.ParseIntoAbsDir(t.TempDir())
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	workspace, cleanup, err := lib.NewWorkspace(prefix, nil)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	// something cached, and something which is not
+	if err := os.WriteFile(filepath.Join(workspace.Path(), "git", "cached"), []byte("hello\n"), 0600); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Join(workspace.Path(), "zip"), 0700); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if err := cleanup(); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if _, err := os.Stat(workspace.Path()); !os.IsNotExist(err) {
+		t.Errorf("expected the workspace to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(prefix.Path(), "git", "cached")); err != nil {
+		t.Errorf("expected the cache to be kept: %+v", err)
+	}
+}
+
+func TestWorkspaceUIDs(t *testing.T) {
+	// the cache dir is found from the environment
+	home := t.TempDir()
+	for _, k := range []string{"HOME", "XDG_CACHE_HOME"} {
+		defer os.Setenv(k, os.Getenv(k))
+	}
+	os.Setenv("HOME", home)
+	os.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+
+	// the archive gets extracted into the workspace
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "src.zip"))
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	z := zip.NewWriter(f)
+	w, err := z.Create("src/main.c")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	w.Write([]byte("// SPDX-License-Identifier: MIT\n"))
+	if err := z.Close(); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+
+	scan := func() []string {
+		m := &lib.Main{
+			Program: "yesiscan",
+			Logf: func(format string, v ...interface{}) {
+				t.Logf(format, v...)
+			},
+			Args:      []string{dir + "/"},
+			Backends:  map[string]bool{"spdx": true},
+			Workspace: true,
+		}
+		output, err := m.Run(context.Background())
+		if err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		uids := []string{}
+		for uid := range output.Results {
+			uids = append(uids, uid)
+		}
+		sort.Strings(uids)
+		return uids
+	}
+
+	uids := scan()
+	if len(uids) != 1 || !strings.HasSuffix(uids[0], "/src/main.c") {
+		t.Fatalf("unexpected results: %+v", uids)
+	}
+	if strings.Contains(uids[0], lib.WorkspaceDir) {
+		t.Errorf("the workspace is in the uid: %s", uids[0])
+	}
+	if again := scan(); !reflect.DeepEqual(uids, again) {
+		t.Errorf("expected the same uids, got: %+v and %+v", uids, again)
+	}
+}
+
+func TestMmapThreshold(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "LICENSE"), []byte("MIT License\n"), 0600); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, "empty"), []byte{}, 0600); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	absDir, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	logf := func(format string, v ...interface{}) {
+		t.Logf(format, v...)
+	}
+
+	core := &lib.Core{
+		Logf:     logf,
+		Backends: []interfaces.Backend{&mitBackend{}},
+		Iterators: []interfaces.Iterator{
+			&iterator.Fs{
+				Logf:   logf,
+				Prefix: absDir,
+				Path:   absDir,
+			},
+		},
+		MmapThreshold: 1, // everything that isn't empty
+	}
+	if err := core.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	results, _, _, err := core.Run(context.Background())
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if _, exists := results[iterator.FileScheme+absDir.String()+"LICENSE"]; !exists {
+		t.Errorf("expected a result for the mapped license file")
+	}
+}
+
+func TestSymlinkLicense(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "LICENSE"), []byte("MIT License\n"), 0600); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "LICENSE"), []byte("MIT License\n"), 0600); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0700); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	links := map[string]string{
+		"sub/LICENSE":     "../LICENSE",                         // followed
+		"sub/COPYING":     filepath.Join(outside, "LICENSE"),    // outside
+		"sub/LICENSE.old": "missing",                            // broken
+		"sub/main.go":     filepath.Join(dir, "sub", "LICENSE"), // not a license
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+	}
+	absDir, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	logf := func(format string, v ...interface{}) {
+		t.Logf(format, v...)
+	}
+
+	core := &lib.Core{
+		Logf:     logf,
+		Backends: []interfaces.Backend{&mitBackend{}},
+		Iterators: []interfaces.Iterator{
+			&iterator.Fs{
+				Logf:   logf,
+				Prefix: absDir,
+				Path:   absDir,
+			},
+		},
+	}
+	if err := core.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	results, _, _, err := core.Run(context.Background())
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	root := iterator.FileScheme + absDir.String()
+	for _, name := range []string{"sub/COPYING", "sub/LICENSE.old", "sub/main.go"} {
+		if _, exists := results[root+name]; exists {
+			t.Errorf("unexpected result for: %s", name)
+		}
+	}
+	m, exists := results[root+"sub/LICENSE"]
+	if !exists {
+		t.Errorf("expected a result for the symlinked license file")
+		return
+	}
+	for _, result := range m {
+		if result.Meta == nil || result.Meta.Symlink != root+"LICENSE" {
+			t.Errorf("expected the result to be marked as a symlink to: %s", root+"LICENSE")
+		}
+	}
+}
+
+// apacheBackend finds the Apache-2.0 license in any file which mentions it.
+type apacheBackend struct{}
+
+func (obj *apacheBackend) String() string { return "apache" }
+
+func (obj *apacheBackend) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
+	if !strings.Contains(string(data), "Apache") {
+		return nil, nil
+	}
+	result := &interfaces.Result{
+		Licenses:   []*licenses.License{{SPDX: "Apache-2.0"}},
+		Confidence: 1.0,
+	}
+	return result, nil
+}
+
+// recordingBackend records the UID of each file that it's asked to scan.
+type recordingBackend struct {
+	mu   sync.Mutex
+	uids []string
+}
+
+func (obj *recordingBackend) String() string { return "recording" }
+
+func (obj *recordingBackend) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
+	if info.FileInfo.IsDir() {
+		return nil, nil
+	}
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	obj.uids = append(obj.uids, info.UID)
+	return nil, nil
+}
+
+func TestSchedule(t *testing.T) {
+	fsys := fstest.MapFS{
+		"agree":    {Data: []byte("MIT\n")},
+		"disagree": {Data: []byte("MIT or Apache\n")},
+		"unknown":  {Data: []byte("package main\n")},
+	}
+	logf := func(format string, v ...interface{}) {
+		t.Logf(format, v...)
+	}
+
+	expensive := &recordingBackend{}
+	core := &lib.Core{
+		Logf:     logf,
+		Backends: []interfaces.Backend{&mitBackend{}, &apacheBackend{}, expensive},
+		Iterators: []interfaces.Iterator{
+			&iterator.IOFS{
+				Logf: logf,
+				FS:   fsys,
+				Name: "test",
+			},
+		},
+		Expensive: map[interfaces.Backend]struct{}{
+			expensive: {},
+		},
+	}
+	if err := core.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if _, _, _, err := core.Run(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	sort.Strings(expensive.uids)
+	expected := []string{
+		iterator.IOFSScheme + "test/disagree",
+		iterator.IOFSScheme + "test/unknown",
+	}
+	if strings.Join(expensive.uids, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected the expensive backend to scan: %v, got: %v", expected, expensive.uids)
+	}
+}
+
+func TestTriage(t *testing.T) {
+	fsys := fstest.MapFS{
+		"LICENSE":     {Data: []byte("MIT License\n")},
+		"src/main.go": {Data: []byte("package main\n")},
+		"blob":        {Data: []byte{0x00, 0x01, 0x02, 0xff}},
+	}
+	logf := func(format string, v ...interface{}) {
+		t.Logf(format, v...)
+	}
+
+	core := &lib.Core{
+		Logf:     logf,
+		Backends: []interfaces.Backend{&mitBackend{}},
+		Iterators: []interfaces.Iterator{
+			&iterator.IOFS{
+				Logf: logf,
+				FS:   fsys,
+				Name: "test",
+			},
+		},
+	}
+	if err := core.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	_, passes, _, err := core.Run(context.Background())
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	// the license file had a result, and the directories aren't files
+	triage := lib.TriageList(core.Triage(), passes)
+	if len(triage) != 2 {
+		t.Errorf("expected two entries, got: %d", len(triage))
+		return
+	}
+	if x := triage[0]; x.UID != iterator.IOFSScheme+"test/blob" || x.Size != 4 || strings.HasPrefix(x.Type, "text/") || x.Snippet != "" {
+		t.Errorf("unexpected entry for the binary file: %+v", x)
+	}
+	if x := triage[1]; x.UID != iterator.IOFSScheme+"test/src/main.go" || x.Size != 13 || !strings.HasPrefix(x.Type, "text/plain") || x.Snippet != "package main\n" || x.License != "" {
+		t.Errorf("unexpected entry for the text file: %+v", x)
+	}
+
+	s, err := lib.ReturnTriageCSV(&lib.Output{Triage: triage})
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) != 4 || lines[0] != "uid,size,type,snippet,license" { // the snippet has a newline
+		t.Errorf("unexpected csv:\n%s", s)
+	}
+}
+
+func TestIgnoreHashes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"LICENSE":         {Data: []byte("MIT License\n")},
+		"vendor/known.go": {Data: []byte("// MIT\n")},
+	}
+	sum := sha256.Sum256([]byte("// MIT\n"))
+	logf := func(format string, v ...interface{}) {
+		t.Logf(format, v...)
+	}
+
+	backend := &recordingBackend{}
+	core := &lib.Core{
+		Logf:     logf,
+		Backends: []interfaces.Backend{&mitBackend{}, backend},
+		Iterators: []interfaces.Iterator{
+			&iterator.IOFS{
+				Logf: logf,
+				FS:   fsys,
+				Name: "test",
+			},
+		},
+		IgnoreHashes: map[string]struct{}{
+			hex.EncodeToString(sum[:]): {},
+		},
+	}
+	if err := core.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	results, passes, _, err := core.Run(context.Background())
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	uid := iterator.IOFSScheme + "test/vendor/known.go"
+	if _, exists := results[uid]; exists {
+		t.Errorf("expected no result for the ignored file")
+	}
+	if util.StrInList(uid, passes) || util.StrInList(uid, backend.uids) {
+		t.Errorf("expected the ignored file to never be scanned")
+	}
+	if ignored := core.Ignored(); len(ignored) != 1 || ignored[0] != uid {
+		t.Errorf("expected the ignored file, got: %v", ignored)
+	}
+	if _, exists := results[iterator.IOFSScheme+"test/LICENSE"]; !exists {
+		t.Errorf("expected a result for the license file")
+	}
+}
+
+func TestLoadIgnoreHashes(t *testing.T) {
+	sum := sha256.Sum256([]byte("// MIT\n"))
+	h := hex.EncodeToString(sum[:])
+	dir := t.TempDir()
+
+	good := filepath.Join(dir, "good.json")
+	data := fmt.Sprintf(`{"hashes": {" %s ": "vendored copy"}}`, strings.ToUpper(h))
+	if err := os.WriteFile(good, []byte(data), 0600); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	hashes, err := lib.LoadIgnoreHashes(good)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if _, exists := hashes[h]; !exists || len(hashes) != 1 {
+		t.Errorf("expected the normalized hash, got: %v", hashes)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"hashes": {"abc123": "too short"}}`), 0600); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if _, err := lib.LoadIgnoreHashes(bad); err == nil {
+		t.Errorf("expected an error for an invalid hash")
+	}
+}
+
+// barrierBackend never finds anything, but it waits until it has been called
+// the expected number of times before it returns, so that all of the results
+// come back at once.
+type barrierBackend struct {
+	wg *sync.WaitGroup
+}
+
+func (obj *barrierBackend) String() string { return "barrier" }
+
+func (obj *barrierBackend) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
+	obj.wg.Done()
+	obj.wg.Wait()
+	return nil, nil
+}
+
+func TestScannerPasses(t *testing.T) {
+	const count = 50
+	fsys := fstest.MapFS{}
+	for i := 0; i < count; i++ {
+		fsys[fmt.Sprintf("file%d.go", i)] = &fstest.MapFile{Data: []byte("package main\n")}
+	}
+	barrier := &sync.WaitGroup{}
+	barrier.Add(count)
+	scanner := &lib.Scanner{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf(format, v...)
+		},
+		Backends: []interfaces.Backend{&barrierBackend{wg: barrier}},
+	}
+	if err := scanner.Init(); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+
+	// each iterator calls Scan concurrently, so this mustn't race
+	ctx := context.Background()
+	errors := make(chan error, len(fsys))
+	wg := &sync.WaitGroup{}
+	for name := range fsys {
+		name := name
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fileInfo, err := fs.Stat(fsys, name)
+			if err != nil {
+				errors <- err
+				return
+			}
+			info := &interfaces.Info{
+				FileInfo: fileInfo,
+				UID:      iterator.IOFSScheme + "test/" + name,
+				FS:       fsys,
+			}
+			errors <- scanner.Scan(ctx, safepath.UnsafeParseIntoAbsFile("/"+name), info)
+		}()
+	}
+	wg.Wait()
+	close(errors)
+	for err := range errors {
+		if err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+	}
+
+	passes, err := scanner.Passes()
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if len(passes) != len(fsys) {
+		t.Errorf("expected %d passes, got: %d", len(fsys), len(passes))
+	}
+}
+
+// unknownBackend can never identify the license of anything.
+type unknownBackend struct{}
+
+func (obj *unknownBackend) String() string { return "unknown" }
+
+func (obj *unknownBackend) ScanData(ctx context.Context, data []byte, info *interfaces.Info) (*interfaces.Result, error) {
+	return nil, errwrap.Wrapf(interfaces.ErrUnknownLicense, "no idea about: %s", info.UID)
+}
+
+func TestScannerUnknownLicense(t *testing.T) {
+	fsys := fstest.MapFS{"main.go": {Data: []byte("package main\n")}}
+	scanner := &lib.Scanner{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf(format, v...)
+		},
+		Backends: []interfaces.Backend{&unknownBackend{}},
+	}
+	if err := scanner.Init(); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	fileInfo, err := fs.Stat(fsys, "main.go")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	uid := iterator.IOFSScheme + "test/main.go"
+	info := &interfaces.Info{
+		FileInfo: fileInfo,
+		UID:      uid,
+		FS:       fsys,
+	}
+	if err := scanner.Scan(context.Background(), safepath.UnsafeParseIntoAbsFile("/main.go"), info); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+
+	// this is the same as a backend that found nothing
+	passes, err := scanner.Passes()
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if expected := []string{uid}; !reflect.DeepEqual(passes, expected) {
+		t.Errorf("expected %+v, got: %+v", expected, passes)
+	}
+	if _, exists := scanner.Triage()[uid]; !exists {
+		t.Errorf("expected a triage entry for: %s", uid)
+	}
+}
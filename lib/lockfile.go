@@ -0,0 +1,481 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/awslabs/yesiscan/backend"
+	"github.com/awslabs/yesiscan/util/errwrap"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+const (
+	// LockEcosystemNpm is the ecosystem of a package-lock.json file.
+	LockEcosystemNpm = "npm"
+
+	// LockEcosystemGo is the ecosystem of a go.sum file.
+	LockEcosystemGo = "go"
+
+	// LockEcosystemCargo is the ecosystem of a Cargo.lock file.
+	LockEcosystemCargo = "cargo"
+
+	// NpmRegistry is where npm packages without a resolved URL come from.
+	NpmRegistry = "https://registry.npmjs.org/"
+
+	// GoProxy is the module proxy that go modules are downloaded from.
+	GoProxy = "https://proxy.golang.org/"
+
+	// CratesDownload is where the crates from crates.io are downloaded from.
+	CratesDownload = "https://static.crates.io/crates/"
+)
+
+// LockPackage is a single pinned dependency from a lockfile.
+type LockPackage struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+
+	// Source is where the lockfile says the package came from. It's the
+	// resolved URL for npm, and the source string for cargo. It is empty
+	// for go, and for packages which come from the default registry.
+	Source string `json:"source,omitempty"`
+
+	// Checksum is the hex sha256 sum of the archive that Input returns, if
+	// the lockfile records one. Cargo always does for the crates from a
+	// registry, but npm usually records a different algorithm.
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// String returns a human readable name of the package and its version.
+func (obj *LockPackage) String() string {
+	return fmt.Sprintf("%s@%s", obj.Name, obj.Version)
+}
+
+// Input returns the input argument that we scan for the source of this package,
+// or an empty string if we don't know how to get it.
+func (obj *LockPackage) Input() string {
+	switch obj.Ecosystem {
+	case LockEcosystemNpm:
+		if s := strings.ToLower(obj.Source); strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://") {
+			return obj.Source
+		}
+		if obj.Source != "" {
+			return "" // a git url, a local path, or something else odd
+		}
+		return fmt.Sprintf("%s%s/-/%s-%s.tgz", NpmRegistry, obj.Name, path.Base(obj.Name), obj.Version)
+
+	case LockEcosystemGo:
+		return fmt.Sprintf("%s%s/@v/%s.zip", GoProxy, backend.GomodEscape(obj.Name), backend.GomodEscape(obj.Version))
+
+	case LockEcosystemCargo:
+		if strings.HasPrefix(obj.Source, "git+") {
+			u := strings.TrimPrefix(obj.Source, "git+")
+			hash := ""
+			if i := strings.Index(u, "#"); i != -1 {
+				u, hash = u[:i], u[i+1:]
+			}
+			if i := strings.Index(u, "?"); i != -1 {
+				u = u[:i]
+			}
+			if plumbing.IsHash(hash) {
+				// the parser trims this back off of the end again
+				u = strings.TrimSuffix(u, ".git") + "/commit/" + hash
+			}
+			return u
+		}
+		if !cargoIsCratesIO(obj.Source) {
+			return "" // a local path or a private registry
+		}
+		return fmt.Sprintf("%s%s/%s-%s.crate", CratesDownload, obj.Name, obj.Name, obj.Version)
+	}
+	return ""
+}
+
+// cargoIsCratesIO returns true if the cargo source string is crates.io.
+func cargoIsCratesIO(source string) bool {
+	sources := []string{
+		"registry+https://github.com/rust-lang/crates.io-index",
+		"sparse+https://index.crates.io/",
+	}
+	for _, x := range sources {
+		if source == x {
+			return true
+		}
+	}
+	return false
+}
+
+// LockfileNames are the names of the lockfiles that we can parse.
+var LockfileNames = []string{
+	"package-lock.json",
+	"npm-shrinkwrap.json",
+	"go.sum",
+	"Cargo.lock",
+}
+
+// ParseLockfile parses the contents of a lockfile. The name is the base name of
+// the file, which decides the format. Each package appears only once, and the
+// list is sorted. The package that the lockfile belongs to is not included.
+func ParseLockfile(name string, data []byte) ([]*LockPackage, error) {
+	var packages []*LockPackage
+	var err error
+	switch name {
+	case "package-lock.json", "npm-shrinkwrap.json":
+		packages, err = parseNpmLockfile(data)
+	case "go.sum":
+		packages, err = parseGoSum(data)
+	case "Cargo.lock":
+		packages, err = parseCargoLock(data)
+	default:
+		return nil, fmt.Errorf("unknown lockfile: %s", name)
+	}
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "could not parse %s", name)
+	}
+
+	seen := make(map[string]struct{})
+	result := []*LockPackage{}
+	for _, x := range packages {
+		if _, exists := seen[x.String()]; exists {
+			continue
+		}
+		seen[x.String()] = struct{}{}
+		result = append(result, x)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Version < result[j].Version
+	})
+	return result, nil
+}
+
+// npmLockEntry is a package in either of the package-lock.json formats.
+type npmLockEntry struct {
+	Version      string                   `json:"version"`
+	Resolved     string                   `json:"resolved"`
+	Integrity    string                   `json:"integrity"`
+	Link         bool                     `json:"link"`
+	Dependencies map[string]*npmLockEntry `json:"dependencies"` // v1 only
+}
+
+// parseNpmLockfile parses a package-lock.json file. Version 2 and 3 have a flat
+// map of install paths, and version 1 has a tree of dependencies.
+func parseNpmLockfile(data []byte) ([]*LockPackage, error) {
+	lock := struct {
+		Packages     map[string]*npmLockEntry `json:"packages"`
+		Dependencies map[string]*npmLockEntry `json:"dependencies"`
+	}{}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, err
+	}
+
+	packages := []*LockPackage{}
+	add := func(name string, x *npmLockEntry) {
+		if x == nil || x.Link || name == "" || x.Version == "" {
+			return // the root package, or a symlinked workspace
+		}
+		packages = append(packages, &LockPackage{
+			Ecosystem: LockEcosystemNpm,
+			Name:      name,
+			Version:   x.Version,
+			Source:    x.Resolved,
+			Checksum:  npmChecksum(x.Integrity),
+		})
+	}
+	if lock.Packages != nil {
+		for p, x := range lock.Packages {
+			const nm = "node_modules/"
+			i := strings.LastIndex(p, nm)
+			if i == -1 {
+				continue // the root, or a workspace that isn't installed
+			}
+			add(p[i+len(nm):], x)
+		}
+		return packages, nil
+	}
+
+	var walk func(map[string]*npmLockEntry)
+	walk = func(m map[string]*npmLockEntry) {
+		for name, x := range m {
+			add(name, x)
+			if x != nil {
+				walk(x.Dependencies)
+			}
+		}
+	}
+	walk(lock.Dependencies)
+	return packages, nil
+}
+
+// npmChecksum returns the hex sha256 sum from an npm subresource integrity
+// string, or an empty string if it doesn't have one. There can be more than one
+// hash in there, separated by spaces, each prefixed with its algorithm.
+func npmChecksum(integrity string) string {
+	for _, x := range strings.Fields(integrity) {
+		if !strings.HasPrefix(x, "sha256-") {
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(x, "sha256-"))
+		if err != nil || len(b) != sha256.Size {
+			continue
+		}
+		return hex.EncodeToString(b)
+	}
+	return ""
+}
+
+// parseGoSum parses a go.sum file. The lines for just the go.mod file of a
+// module are skipped, since its source isn't used in the build.
+func parseGoSum(data []byte) ([]*LockPackage, error) {
+	packages := []*LockPackage{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for i := 1; scanner.Scan(); i++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid line %d", i)
+		}
+		if strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+		packages = append(packages, &LockPackage{
+			Ecosystem: LockEcosystemGo,
+			Name:      fields[0],
+			Version:   fields[1],
+		})
+	}
+	return packages, scanner.Err()
+}
+
+// parseCargoLock parses a Cargo.lock file. This only understands the simple
+// subset of TOML that cargo writes, which is a list of package tables with one
+// string key per line. The packages without a source are part of the workspace
+// that the lockfile belongs to, so they are skipped.
+func parseCargoLock(data []byte) ([]*LockPackage, error) {
+	packages := []*LockPackage{}
+	var current *LockPackage
+	flush := func() {
+		if current != nil && current.Source != "" && current.Name != "" {
+			packages = append(packages, current)
+		}
+		current = nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			flush()
+			if line == "[[package]]" {
+				current = &LockPackage{Ecosystem: LockEcosystemCargo}
+			}
+			continue
+		}
+		if current == nil || line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ix := strings.Index(line, "=")
+		if ix == -1 {
+			continue // the rest of a multi-line array
+		}
+		key := strings.TrimSpace(line[:ix])
+		value := strings.TrimSpace(line[ix+1:])
+		if !strings.HasPrefix(value, `"`) {
+			continue // not a string, such as the dependencies array
+		}
+		s, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string on line %d", i)
+		}
+		switch key {
+		case "name":
+			current.Name = s
+		case "version":
+			current.Version = s
+		case "source":
+			current.Source = s
+		case "checksum":
+			current.Checksum = s
+		}
+	}
+	flush()
+	return packages, scanner.Err()
+}
+
+// LockChange is a package which was added or updated between two versions of a
+// lockfile.
+type LockChange struct {
+	*LockPackage
+
+	// From is the list of versions of this package in the old lockfile.
+	// It is empty if the package was added.
+	From []string `json:"from,omitempty"`
+}
+
+// LockfileDelta returns the packages in the new lockfile which are not in the
+// old one. If a different version of the same package was in the old one, then
+// that is an update, and otherwise it was added. Removed packages don't matter
+// for licensing, so they are left out.
+func LockfileDelta(oldPackages, newPackages []*LockPackage) []*LockChange {
+	versions := make(map[string][]string) // ecosystem and name -> versions
+	key := func(x *LockPackage) string {
+		return x.Ecosystem + "\x00" + x.Name
+	}
+	for _, x := range oldPackages {
+		versions[key(x)] = append(versions[key(x)], x.Version)
+	}
+	changes := []*LockChange{}
+	for _, x := range newPackages {
+		from := versions[key(x)]
+		found := false
+		for _, v := range from {
+			if v == x.Version {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		changes = append(changes, &LockChange{
+			LockPackage: x,
+			From:        from,
+		})
+	}
+	return changes
+}
+
+// LockDeltaRow is the result for one changed package.
+type LockDeltaRow struct {
+	*LockChange
+
+	// Input is what was scanned. It's empty if we couldn't get the source.
+	Input string `json:"input,omitempty"`
+
+	// Licenses are all the licenses found in the source of the package.
+	Licenses []string `json:"licenses"`
+
+	// Verdicts maps each profile to its verdict for this package.
+	Verdicts map[string]string `json:"verdicts,omitempty"`
+
+	// Error is set if the scan of this package failed.
+	Error string `json:"error,omitempty"`
+}
+
+// LockDelta returns the report for each of the changes. Each input is scanned
+// on its own, so that one bad download doesn't hide the rest, and the outputs
+// and errors maps are keyed by the input of each change.
+func LockDelta(changes []*LockChange, outputs map[string]*Output, errors map[string]error) []*LockDeltaRow {
+	rows := []*LockDeltaRow{}
+	for _, x := range changes {
+		input := x.Input()
+		row := &LockDeltaRow{
+			LockChange: x,
+			Input:      input,
+			Licenses:   []string{},
+		}
+		rows = append(rows, row)
+		if err := errors[input]; err != nil {
+			row.Error = err.Error()
+		}
+		output := outputs[input]
+		if input == "" || output == nil {
+			continue
+		}
+		for _, ls := range ArtifactLicenses(output, []string{input}) {
+			for _, l := range ls {
+				row.Licenses = append(row.Licenses, l.String())
+			}
+		}
+		row.Verdicts = make(map[string]string)
+		for _, v := range output.Verdicts {
+			row.Verdicts[v.Profile] = v.Verdict
+		}
+	}
+	return rows
+}
+
+// ReturnLockDelta returns the report of the changed packages. The style is
+// either text or json.
+func ReturnLockDelta(rows []*LockDeltaRow, style string) (string, error) {
+	if style == "json" {
+		b, err := json.MarshalIndent(rows, "", "\t")
+		if err != nil {
+			return "", err
+		}
+		return string(b) + "\n", nil
+	}
+	if style != "text" {
+		return "", fmt.Errorf("invalid style: %s", style)
+	}
+
+	s := ""
+	for _, x := range rows {
+		change := "added"
+		if len(x.From) > 0 {
+			change = "updated from " + strings.Join(x.From, ", ")
+		}
+		s += fmt.Sprintf("%s %s (%s)\n", x.Ecosystem, x.LockPackage, change)
+		if x.Input == "" {
+			s += "\tsource unknown, not scanned\n"
+			continue
+		}
+		if x.Error != "" {
+			s += fmt.Sprintf("\tscan failed: %s\n", x.Error)
+			continue
+		}
+		licenses := "none found"
+		if len(x.Licenses) > 0 {
+			licenses = strings.Join(x.Licenses, ", ")
+		}
+		s += fmt.Sprintf("\tlicenses: %s\n", licenses)
+		profiles := []string{}
+		for p := range x.Verdicts {
+			profiles = append(profiles, p)
+		}
+		sort.Strings(profiles)
+		for _, p := range profiles {
+			s += fmt.Sprintf("\tprofile %s: %s\n", p, x.Verdicts[p])
+		}
+	}
+	if len(rows) == 0 {
+		s = "no added or updated packages\n"
+	}
+	return s, nil
+}
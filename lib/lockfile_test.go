@@ -0,0 +1,156 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/awslabs/yesiscan/lib"
+)
+
+func TestLockfileDelta(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		exp  []string // name@version <- from
+		in   []string // inputs
+		sums []string // checksums
+	}{
+		{
+			name: "go.sum",
+			old: `github.com/pkg/errors v0.9.0 h1:aaa=
+github.com/pkg/errors v0.9.0/go.mod h1:bbb=
+`,
+			new: `github.com/BurntSushi/toml v1.0.0 h1:ccc=
+github.com/pkg/errors v0.9.1 h1:ddd=
+github.com/pkg/errors v0.9.1/go.mod h1:eee=
+golang.org/x/sys v0.1.0/go.mod h1:fff=
+`,
+			exp: []string{"github.com/BurntSushi/toml@v1.0.0 <- []", "github.com/pkg/errors@v0.9.1 <- [v0.9.0]"},
+			in: []string{
+				"https://proxy.golang.org/github.com/!burnt!sushi/toml/@v/v1.0.0.zip",
+				"https://proxy.golang.org/github.com/pkg/errors/@v/v0.9.1.zip",
+			},
+			sums: []string{"", ""},
+		},
+		{
+			name: "package-lock.json",
+			old: `{"lockfileVersion": 1, "dependencies": {
+				"left-pad": {"version": "1.0.0", "dependencies": {
+					"@scope/x": {"version": "2.0.0"}
+				}}
+			}}`,
+			new: `{"lockfileVersion": 3, "packages": {
+				"": {"name": "me", "version": "1.0.0"},
+				"node_modules/left-pad": {"version": "1.0.0"},
+				"node_modules/left-pad/node_modules/@scope/x": {"version": "2.1.0"},
+				"node_modules/mine": {"link": true},
+				"node_modules/y": {"version": "1.0.0", "resolved": "https://npm.example.com/y.tgz", "integrity": "sha512-abc sha256-AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="}
+			}}`,
+			exp: []string{"@scope/x@2.1.0 <- [2.0.0]", "y@1.0.0 <- []"},
+			in: []string{
+				"https://registry.npmjs.org/@scope/x/-/x-2.1.0.tgz",
+				"https://npm.example.com/y.tgz",
+			},
+			sums: []string{"", "0101010101010101010101010101010101010101010101010101010101010101"},
+		},
+		{
+			name: "Cargo.lock",
+			old: `version = 3
+
+[[package]]
+name = "me"
+version = "0.1.0"
+dependencies = [
+ "serde",
+]
+
+[[package]]
+name = "serde"
+version = "1.0.0"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+checksum = "abc"
+`,
+			new: `version = 3
+
+[[package]]
+name = "me"
+version = "0.2.0"
+
+[[package]]
+name = "serde"
+version = "1.0.1"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+checksum = "0101010101010101010101010101010101010101010101010101010101010101"
+
+[[package]]
+name = "forked"
+version = "0.1.0"
+source = "git+https://github.com/x/forked?branch=main#0123456789abcdef0123456789abcdef01234567"
+`,
+			exp: []string{"forked@0.1.0 <- []", "serde@1.0.1 <- [1.0.0]"},
+			in: []string{
+				"https://github.com/x/forked/commit/0123456789abcdef0123456789abcdef01234567",
+				"https://static.crates.io/crates/serde/serde-1.0.1.crate",
+			},
+			sums: []string{"", "0101010101010101010101010101010101010101010101010101010101010101"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			oldPackages, err := lib.ParseLockfile(tc.name, []byte(tc.old))
+			if err != nil {
+				t.Fatalf("error: %+v", err)
+			}
+			newPackages, err := lib.ParseLockfile(tc.name, []byte(tc.new))
+			if err != nil {
+				t.Fatalf("error: %+v", err)
+			}
+			got := []string{}
+			inputs := []string{}
+			sums := []string{}
+			for _, x := range lib.LockfileDelta(oldPackages, newPackages) {
+				got = append(got, x.LockPackage.String()+" <- "+fmt.Sprintf("%v", x.From))
+				inputs = append(inputs, x.Input())
+				sums = append(sums, x.Checksum)
+			}
+			if !reflect.DeepEqual(got, tc.exp) {
+				t.Errorf("expected %+v, got: %+v", tc.exp, got)
+			}
+			if !reflect.DeepEqual(inputs, tc.in) {
+				t.Errorf("expected inputs %+v, got: %+v", tc.in, inputs)
+			}
+			if !reflect.DeepEqual(sums, tc.sums) {
+				t.Errorf("expected checksums %+v, got: %+v", tc.sums, sums)
+			}
+		})
+	}
+
+	if _, err := lib.ParseLockfile("yarn.lock", nil); err == nil {
+		t.Errorf("expected an unknown lockfile error")
+	}
+}
@@ -32,10 +32,13 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/awslabs/yesiscan/backend"
 	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
 	"github.com/awslabs/yesiscan/parser"
+	"github.com/awslabs/yesiscan/util"
 	"github.com/awslabs/yesiscan/util/errwrap"
 	"github.com/awslabs/yesiscan/util/licenses"
 	"github.com/awslabs/yesiscan/util/safepath"
@@ -47,13 +50,70 @@ var Backends = []string{
 	"licenseclassifier",
 	"cran",
 	"pom",
+	"npm",
+	"cargo",
+	"gomod",
+	"composer",
+	"nuget",
+	"debian",
 	"spdx",
 	"askalono",
+	"dice",
+	"licensedetector",
 	"scancode",
 	"bitbake",
 	"regexp",
+	"binary",
 }
 
+// DataBackends are the backends from the above list which can scan the
+// contents of a file without it existing on the local disk. The others can't
+// see anything that comes from an io/fs.FS.
+var DataBackends = []string{
+	"cran",
+	"pom",
+	"npm",
+	"cargo",
+	"gomod",
+	"composer",
+	"nuget",
+	"debian",
+	"spdx",
+	"dice",
+	"licensedetector",
+	"bitbake",
+	"regexp",
+	"binary",
+}
+
+// QuickBackends are the backends from the above list which are built in and
+// fast, so that a quick scan gives feedback on a small tree in under a second.
+// The ones which run an external program, such as scancode, aren't in here.
+var QuickBackends = []string{
+	"licenseclassifier",
+	"cran",
+	"pom",
+	"npm",
+	"cargo",
+	"composer",
+	"nuget",
+	"debian",
+	"spdx",
+	"bitbake",
+	"regexp",
+}
+
+// ExpensiveBackends are the backends from the above list which are slow enough
+// that a scheduled scan only runs them on the files where the others found
+// nothing, or disagreed. They each start an external program.
+var ExpensiveBackends = []string{
+	"askalono",
+	"scancode",
+}
+
+// QuickWarning is shown at the top of the output of a quick scan.
+const QuickWarning = "only the fast built-in backends ran, so this is a lower assurance scan which may miss some licenses"
+
 // Main is the general entry point for running this software. Populate this
 // struct with the inputs and then call the Run() method.
 type Main struct {
@@ -65,6 +125,55 @@ type Main struct {
 	// This is the argv of the function.
 	Args []string
 
+	// OrtAnalyzerPath is the path to the json output of the ORT analyzer. If
+	// it is set, then the source of each package in there is also scanned.
+	// Packages whose source can't be parsed are skipped.
+	OrtAnalyzerPath string
+
+	// ChecksumsPath is the path to a manifest of the expected checksums of
+	// the inputs which get downloaded. If it is empty, then only the ones
+	// from the Checksums field and the ORT analyzer result are verified.
+	ChecksumsPath string
+
+	// Checksums maps input arguments to the lowercase hex sha256 sum that
+	// the downloaded artifact must have. An artifact that doesn't match is
+	// never scanned.
+	Checksums map[string]string
+
+	// Offline forbids any network access, so that we can run in an
+	// air-gapped environment. Inputs that would have to be downloaded are
+	// an error, and git repositories must already be in the local cache.
+	Offline bool
+
+	// Limiter throttles the downloads of the inputs, so that we don't
+	// saturate the network link. If it is nil, then nothing is throttled.
+	Limiter *iterator.Limiter
+
+	// Progress tracks how far along the scan is while it runs, so that it
+	// can be shown to someone who is waiting. To count the bytes that get
+	// downloaded, it should share the same Limiter. If it is nil, then
+	// nothing is tracked.
+	Progress *Progress
+
+	// OnResult is called with each result as soon as a backend returns it,
+	// so that they can be shown while the scan is still running. It may be
+	// called concurrently. If it is nil, then nothing is called.
+	OnResult func(uid string, backend interfaces.Backend, result *interfaces.Result)
+
+	// ParserPlugins maps a lower case URI scheme to the parser plugin that
+	// parses the args with that scheme.
+	ParserPlugins map[string]parser.PluginFunc
+
+	// Stdin is read from when one of the args is "-", or when there are no
+	// args at all. If it is nil, then either of those is an error. This is
+	// never os.Stdin unless the caller explicitly passes it in.
+	Stdin io.Reader
+
+	// Iterators is a list of additional iterators to run alongside the ones
+	// that get built from the args. This is useful to scan things that
+	// can't be expressed as a string, such as an io/fs.FS filesystem.
+	Iterators []interfaces.Iterator
+
 	// Backends gives us a list of backends we use. If the corresponding
 	// bool value in the map is true, then the backend is enabled. It can be
 	// false if we want to show that it exists but is not enabled. This is
@@ -77,20 +186,188 @@ type Main struct {
 
 	// RegexpPath specifies a path the regular expressions to use.
 	RegexpPath string
+
+	// InferLicenses enables the inference pass which gives files without
+	// any license determination the license of the nearest enclosing
+	// LICENSE or COPYING file. These results are flagged as inferred.
+	InferLicenses bool
+
+	// Reuse enables the check of each artifact against the REUSE
+	// specification. The result is shown as an additional verdict. This
+	// needs the spdx backend to be enabled.
+	Reuse bool
+
+	// ExtractCopyrights collects the copyright statements in the header of
+	// each file. They are needed for the NOTICE output.
+	ExtractCopyrights bool
+
+	// IgnorePath specifies a path to the ignore list of content hashes. If
+	// it is empty, then we look in the default location, and if nothing is
+	// there then nothing is ignored.
+	IgnorePath string
+
+	// CurationsPath specifies a path to the curations file of the decisions
+	// that reviewers made about the findings. If it is empty, then we look
+	// in the default location, and if nothing is there then nothing is
+	// curated.
+	CurationsPath string
+
+	// ObligationsPath specifies a path to a file of license obligations to
+	// add to the built-in knowledge base. If it is empty, then we look in
+	// the default location, and if nothing is there we use the built-in.
+	ObligationsPath string
+
+	// Ownership is the set of rules that classify each scanned file as
+	// first-party or third-party code. If it is nil or empty, then nothing
+	// is classified, and the summaries include every file.
+	Ownership *Ownership
+
+	// Scope limits the scan to the files which were changed since a date or
+	// a commit in the history of their git repository. This is useful for
+	// obligations which only apply to newly added code. If it is nil or
+	// empty, then every file is scanned.
+	Scope *Scope
+
+	// SummaryFirstParty includes the first-party files in the summaries.
+	// By default only the third-party files are counted when there are
+	// ownership rules.
+	SummaryFirstParty bool
+
+	// Blend is the method used to combine the confidence values of the
+	// different backends. If it is empty, then DefaultBlend is used.
+	Blend string
+
+	// SmartURIs are the handlers that turn the UID's of the results into
+	// links, before the built-in ones are tried. They are kept in the
+	// output for when it gets displayed.
+	SmartURIs util.SmartURIFuncs
+
+	// Duplicates is the policy for what to do when a backend gives us two
+	// different results for the same path. If it is empty, then
+	// DefaultDuplicates is used.
+	Duplicates string
+
+	// MemoryBudget is the maximum number of bytes of file data to hold in
+	// memory at once while scanning. If it is zero, then there is no limit.
+	MemoryBudget int64
+
+	// MmapThreshold is the file size in bytes at or above which files are
+	// memory mapped instead of read. If it is zero, then we never use mmap.
+	MmapThreshold int64
+
+	// Workspace isolates all the downloads and extractions of this scan in
+	// a temporary directory under the prefix, which is removed once the
+	// results have been collected. Only the content addressed caches are
+	// kept. This stops long-running servers from leaking disk.
+	Workspace bool
+
+	// Perms is the permission policy for everything that we write. If it
+	// is nil, then the default policy is used.
+	Perms *interfaces.Perms
+
+	// Timings enables collecting the time spent in each stage of the scan.
+	// They are returned in the Timings field of the output.
+	Timings bool
+
+	// MaxFiles is the most files that a scan will read. When it goes over,
+	// the scan stops and the partial results are returned. If it is zero,
+	// then there is no limit.
+	MaxFiles int64
+
+	// MaxBytes is the most bytes of file data that a scan will read. When
+	// it goes over, the scan stops and the partial results are returned.
+	// If it is zero, then there is no limit.
+	MaxBytes int64
+
+	// MaxDuration is the longest time that a scan will run for, not
+	// counting the setup of the backends. When it goes over, the scan
+	// stops and the partial results are returned. If it is zero, then
+	// there is no limit.
+	MaxDuration time.Duration
+
+	// Estimate walks the local inputs before the scan, and predicts how long
+	// each backend will take from the throughput of previous scans. It also
+	// updates that history with the timings of this scan.
+	Estimate bool
+
+	// RawOutput keeps the compressed json output of the scancode and the
+	// askalono backends in each of their results, for forensic review.
+	RawOutput bool
+
+	// Cache enables the on-disk cache of the results of each backend for
+	// each file. The key includes the backend and license database
+	// versions, so an upgrade never serves an outdated determination.
+	Cache bool
+
+	// CacheRemote is a store of cache entries that is shared with other
+	// machines. If it is set, then the cache is enabled even if Cache is
+	// not, since the entries from the remote are also kept on disk.
+	CacheRemote CacheRemote
+
+	// CacheSigningKey is the secret that the entries of the remote cache
+	// are signed with. It must be set if there is a remote cache.
+	CacheSigningKey []byte
+
+	// Scancode are the options that get passed through to the scancode
+	// backend. If it is nil, then the defaults are used.
+	Scancode *backend.ScancodeOptions
+
+	// Askalono are the options for the askalono backend. If it is nil,
+	// then the defaults are used.
+	Askalono *backend.AskalonoOptions
+
+	// GomodProxy is the url of the module proxy that the gomod backend
+	// downloads the modules which aren't in the module cache from. If it
+	// is empty, then those modules are skipped.
+	GomodProxy string
+
+	// Quick only runs the enabled backends which are also in the list of
+	// QuickBackends. The output is marked as quick, since it might miss
+	// what the slower backends would have found.
+	Quick bool
+
+	// Deep extracts the text from documents such as PDF, DOCX and RTF files
+	// before they get passed to the backends which scan file data. License
+	// agreements are often shipped this way, and are otherwise invisible.
+	Deep bool
+
+	// Schedule runs the cheap backends on each file first, and only runs
+	// the ExpensiveBackends on the files where those found nothing, or
+	// disagreed. This is much faster, and it only gives up the second
+	// opinion on the files which already have a clear answer.
+	Schedule bool
 }
 
 // Run is the main method for the Main struct. We use a struct as a way to pass
 // in a ton of different arguments in a cleaner way.
 func (obj *Main) Run(ctx context.Context) (*Output, error) {
+	if err := ValidateBlend(obj.Blend); err != nil {
+		return nil, err
+	}
+	if err := ValidateDuplicates(obj.Duplicates); err != nil {
+		return nil, err
+	}
+	blend := obj.Blend
+	if blend == "" {
+		blend = DefaultBlend
+	}
+	if obj.Quick {
+		m := make(map[string]bool)
+		for k, v := range obj.Backends {
+			m[k] = v && util.StrInList(k, QuickBackends)
+		}
+		obj.Backends = m // don't change the map of the caller
+	}
+
 	userCacheDir, err := os.UserCacheDir()
 	if err != nil {
 		return nil, err
 	}
-	if err := os.MkdirAll(userCacheDir, interfaces.Umask); err != nil {
+	if err := os.MkdirAll(userCacheDir, obj.Perms.DirMode()); err != nil {
 		return nil, err
 	}
 	prefix := filepath.Join(userCacheDir, obj.Program)
-	if err := os.MkdirAll(prefix, interfaces.Umask); err != nil {
+	if err := os.MkdirAll(prefix, obj.Perms.DirMode()); err != nil {
 		return nil, err
 	}
 	safePrefixAbsDir, err := safepath.ParseIntoAbsDir(prefix)
@@ -99,6 +376,23 @@ func (obj *Main) Run(ctx context.Context) (*Output, error) {
 	}
 	obj.Logf("prefix: %s", safePrefixAbsDir)
 
+	iteratorPrefix := safePrefixAbsDir // where the iterators store things
+	var workspace safepath.AbsDir
+	if obj.Workspace {
+		var cleanup func() error
+		workspace, cleanup, err = NewWorkspace(safePrefixAbsDir, obj.Perms)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := cleanup(); err != nil {
+				obj.Logf("workspace cleanup error: %+v", err)
+			}
+		}()
+		obj.Logf("workspace: %s", workspace)
+		iteratorPrefix = workspace
+	}
+
 	home, err := os.UserHomeDir()
 	if err != nil {
 		obj.Logf("error finding home directory: %+v", err)
@@ -111,15 +405,62 @@ func (obj *Main) Run(ctx context.Context) (*Output, error) {
 	for _, s := range obj.Args {
 		if s == "-" { // stdin
 			var err error
-			s, err = stdinAsString(obj.Logf)
+			s, err = obj.stdinAsString()
 			if err != nil {
 				return nil, err
 			}
 		}
 		inputStrings = append(inputStrings, s)
 	}
-	if len(obj.Args) == 0 { // if we didn't get any args, assume stdin
-		s, err := stdinAsString(obj.Logf)
+	ortPackages := make(map[string]*OrtPackage) // input -> package
+	if obj.OrtAnalyzerPath != "" {
+		packages, err := ReadOrtAnalyzerResult(obj.OrtAnalyzerPath)
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "could not read ort analyzer result")
+		}
+		for _, x := range packages {
+			input := x.Input()
+			if input == "" {
+				obj.Logf("ort: no supported source for package: %s", x.ID)
+				continue
+			}
+			if _, exists := ortPackages[input]; exists {
+				continue // multiple packages from the same repository
+			}
+			ortPackages[input] = x
+			inputStrings = append(inputStrings, input)
+		}
+	}
+
+	checksums := make(map[string]string) // input -> expected sha256
+	if obj.ChecksumsPath != "" {
+		m, err := LoadChecksums(obj.ChecksumsPath)
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "could not read checksums")
+		}
+		for k, v := range m {
+			checksums[k] = v
+		}
+	}
+	for k, v := range obj.Checksums {
+		if err := addChecksum(checksums, k, v); err != nil {
+			return nil, err
+		}
+	}
+	for input, x := range ortPackages {
+		_, provenance := x.source()
+		if provenance == nil {
+			continue
+		}
+		if a := provenance.SourceArtifact; a != nil && a.Hash != nil && isChecksumAlgorithm(a.Hash.Algorithm) {
+			if err := addChecksum(checksums, input, a.Hash.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// if we didn't get any args or iterators, assume stdin
+	if len(obj.Args) == 0 && len(obj.Iterators) == 0 && obj.OrtAnalyzerPath == "" {
+		s, err := obj.stdinAsString()
 		if err != nil {
 			return nil, err
 		}
@@ -127,23 +468,39 @@ func (obj *Main) Run(ctx context.Context) (*Output, error) {
 	}
 
 	iterators := []interfaces.Iterator{}
+	inputIterators := make(map[string][]interfaces.Iterator) // input -> iterators
+	parsedStrings := []string{}
 	for _, s := range inputStrings {
 		trivialURIParser := &parser.TrivialURIParser{
 			Debug: obj.Debug,
 			Logf: func(format string, v ...interface{}) {
 				obj.Logf(format, v...)
 			},
-			Prefix: safePrefixAbsDir,
-			Input:  s,
+			Prefix:   iteratorPrefix,
+			Perms:    obj.Perms,
+			Input:    s,
+			Checksum: checksums[s],
+			Offline:  obj.Offline,
+			Limiter:  obj.Limiter,
+			Plugins:  obj.ParserPlugins,
 		}
 		obj.Logf("input: %s", s)
 
 		ixs, err := trivialURIParser.Parse() // parser returns iterators
+		if pkg, exists := ortPackages[s]; err != nil && exists {
+			obj.Logf("ort: skipping package %s: %+v", pkg.ID, err)
+			delete(ortPackages, s)
+			continue
+		}
 		if err != nil {
 			return nil, errwrap.Wrapf(err, "parser failed")
 		}
 		iterators = append(iterators, ixs...)
+		inputIterators[s] = append(inputIterators[s], ixs...)
+		parsedStrings = append(parsedStrings, s)
 	}
+	inputStrings = parsedStrings
+	iterators = append(iterators, obj.Iterators...)
 
 	backends := []interfaces.Backend{}
 	backendWeights := make(map[interfaces.Backend]float64)
@@ -183,6 +540,74 @@ func (obj *Main) Run(ctx context.Context) (*Output, error) {
 		backendWeights[pomBackend] = 2.0 // TODO: adjust as needed
 	}
 
+	if enabled, _ := obj.Backends["npm"]; enabled {
+		npmBackend := &backend.Npm{
+			Debug: obj.Debug,
+			Logf: func(format string, v ...interface{}) {
+				obj.Logf("backend: "+format, v...)
+			},
+		}
+		backends = append(backends, npmBackend)
+		backendWeights[npmBackend] = 2.0 // TODO: adjust as needed
+	}
+
+	if enabled, _ := obj.Backends["cargo"]; enabled {
+		cargoBackend := &backend.Cargo{
+			Debug: obj.Debug,
+			Logf: func(format string, v ...interface{}) {
+				obj.Logf("backend: "+format, v...)
+			},
+		}
+		backends = append(backends, cargoBackend)
+		backendWeights[cargoBackend] = 2.0 // TODO: adjust as needed
+	}
+
+	if enabled, _ := obj.Backends["gomod"]; enabled {
+		gomodBackend := &backend.Gomod{
+			Debug: obj.Debug,
+			Logf: func(format string, v ...interface{}) {
+				obj.Logf("backend: "+format, v...)
+			},
+			Proxy:   obj.GomodProxy,
+			Offline: obj.Offline,
+		}
+		backends = append(backends, gomodBackend)
+		backendWeights[gomodBackend] = 2.0 // TODO: adjust as needed
+	}
+
+	if enabled, _ := obj.Backends["composer"]; enabled {
+		composerBackend := &backend.Composer{
+			Debug: obj.Debug,
+			Logf: func(format string, v ...interface{}) {
+				obj.Logf("backend: "+format, v...)
+			},
+		}
+		backends = append(backends, composerBackend)
+		backendWeights[composerBackend] = 2.0 // TODO: adjust as needed
+	}
+
+	if enabled, _ := obj.Backends["nuget"]; enabled {
+		nugetBackend := &backend.Nuget{
+			Debug: obj.Debug,
+			Logf: func(format string, v ...interface{}) {
+				obj.Logf("backend: "+format, v...)
+			},
+		}
+		backends = append(backends, nugetBackend)
+		backendWeights[nugetBackend] = 2.0 // TODO: adjust as needed
+	}
+
+	if enabled, _ := obj.Backends["debian"]; enabled {
+		debianBackend := &backend.Debian{
+			Debug: obj.Debug,
+			Logf: func(format string, v ...interface{}) {
+				obj.Logf("backend: "+format, v...)
+			},
+		}
+		backends = append(backends, debianBackend)
+		backendWeights[debianBackend] = 2.0 // TODO: adjust as needed
+	}
+
 	if enabled, _ := obj.Backends["spdx"]; enabled {
 		spdxBackend := &backend.Spdx{
 			Debug: obj.Debug,
@@ -200,18 +625,45 @@ func (obj *Main) Run(ctx context.Context) (*Output, error) {
 			Logf: func(format string, v ...interface{}) {
 				obj.Logf("backend: "+format, v...)
 			},
-			Prefix: safePrefixAbsDir,
+			Prefix:  safePrefixAbsDir,
+			Perms:   obj.Perms,
+			Options: obj.Askalono,
+			Raw:     obj.RawOutput,
 		}
 		backends = append(backends, askalonoBackend)
 		backendWeights[askalonoBackend] = 4.0 // TODO: adjust as needed
 	}
 
+	if enabled, _ := obj.Backends["dice"]; enabled {
+		diceBackend := &backend.Dice{
+			Debug: obj.Debug,
+			Logf: func(format string, v ...interface{}) {
+				obj.Logf("backend: "+format, v...)
+			},
+		}
+		backends = append(backends, diceBackend)
+		backendWeights[diceBackend] = 4.0 // same as askalono
+	}
+
+	if enabled, _ := obj.Backends["licensedetector"]; enabled {
+		licenseDetectorBackend := &backend.LicenseDetector{
+			Debug: obj.Debug,
+			Logf: func(format string, v ...interface{}) {
+				obj.Logf("backend: "+format, v...)
+			},
+		}
+		backends = append(backends, licenseDetectorBackend)
+		backendWeights[licenseDetectorBackend] = 3.0 // TODO: adjust as needed
+	}
+
 	if enabled, _ := obj.Backends["scancode"]; enabled {
 		scancodeBackend := &backend.Scancode{
 			Debug: obj.Debug,
 			Logf: func(format string, v ...interface{}) {
 				obj.Logf("backend: "+format, v...)
 			},
+			Options: obj.Scancode,
+			Raw:     obj.RawOutput,
 		}
 		backends = append(backends, scancodeBackend)
 		backendWeights[scancodeBackend] = 8.0 // TODO: adjust as needed
@@ -238,6 +690,10 @@ func (obj *Main) Run(ctx context.Context) (*Output, error) {
 				regexpPath = filepath.Join(home, ".config/", obj.Program+"/", "regexp.json")
 				regexpPath = filepath.Clean(regexpPath)
 			}
+			// A quick scan shouldn't fail because there are no rules.
+			if _, err := os.Stat(regexpPath); obj.Quick && err != nil {
+				regexpPath = ""
+			}
 		}
 	}
 	if regexpPath != "" {
@@ -255,6 +711,17 @@ func (obj *Main) Run(ctx context.Context) (*Output, error) {
 		backendWeights[regexpBackend] = 8.0 // TODO: adjust as needed
 	}
 
+	if enabled, _ := obj.Backends["binary"]; enabled {
+		binaryBackend := &backend.Binary{
+			Debug: obj.Debug,
+			Logf: func(format string, v ...interface{}) {
+				obj.Logf("backend: "+format, v...)
+			},
+		}
+		backends = append(backends, binaryBackend)
+		backendWeights[binaryBackend] = 1.0 // TODO: adjust as needed
+	}
+
 	//if enabled, _ := obj.Backends["example"]; enabled {
 	//	exampleBackend := &backend.ExampleClassifier{
 	//		Debug: obj.Debug,
@@ -311,9 +778,111 @@ func (obj *Main) Run(ctx context.Context) (*Output, error) {
 			continue
 		}
 
+		prefer, err := licenses.StringsToLicenses(profileConfig.Prefer)
+		if err != nil {
+			obj.Logf("profile %s: error parsing preferred license: %+v", x, err)
+			continue
+		}
+
 		profilesData[x] = &ProfileData{
 			Licenses: list,
 			Exclude:  profileConfig.Exclude,
+			Prefer:   prefer,
+		}
+	}
+
+	ignoreHashes := make(map[string]struct{})
+	ignorePath := obj.IgnorePath
+	// TODO: implement proper XDG and maybe path precedence?
+	if ignorePath == "" && home != "" {
+		ignorePath = filepath.Join(home, ".config/", obj.Program+"/", IgnoreFileName)
+		ignorePath = filepath.Clean(ignorePath)
+	}
+	if ignorePath != "" {
+		hashes, err := LoadIgnoreHashes(ignorePath)
+		if err != nil && (obj.IgnorePath != "" || !os.IsNotExist(err)) {
+			return nil, errwrap.Wrapf(err, "could not load ignore list: %s", ignorePath)
+		}
+		if err == nil {
+			obj.Logf("ignore list: %d hashes", len(hashes))
+			ignoreHashes = hashes
+		}
+	}
+
+	curations := make(map[string]*Curation)
+	curationsPath := obj.CurationsPath
+	// TODO: implement proper XDG and maybe path precedence?
+	if curationsPath == "" && home != "" {
+		curationsPath = filepath.Join(home, ".config/", obj.Program+"/", CurationsFileName)
+		curationsPath = filepath.Clean(curationsPath)
+	}
+	if curationsPath != "" {
+		c, err := LoadCurations(curationsPath)
+		if err != nil && (obj.CurationsPath != "" || !os.IsNotExist(err)) {
+			return nil, errwrap.Wrapf(err, "could not load curations: %s", curationsPath)
+		}
+		if err == nil {
+			obj.Logf("curations: %d hashes", len(c))
+			curations = c
+		}
+	}
+	if len(curations) > 0 {
+		backendWeights[CurationBackend] = 1.0 // it's alone on its files
+	}
+
+	var ownership *Ownership
+	if !obj.Ownership.Empty() {
+		if err := obj.Ownership.Validate(); err != nil {
+			return nil, errwrap.Wrapf(err, "invalid ownership rules")
+		}
+		ownership = obj.Ownership
+	}
+
+	var scope *Scope
+	if !obj.Scope.Empty() {
+		if err := obj.Scope.Validate(); err != nil {
+			return nil, errwrap.Wrapf(err, "invalid scope")
+		}
+		scope = obj.Scope
+		obj.Logf("scope: only the files %s", scope)
+	}
+
+	obligationsPath := obj.ObligationsPath
+	// TODO: implement proper XDG and maybe path precedence?
+	if obligationsPath == "" && home != "" {
+		obligationsPath = filepath.Join(home, ".config/", obj.Program+"/", ObligationsFileName)
+		obligationsPath = filepath.Clean(obligationsPath)
+	}
+	obligations, err := LoadObligations(obligationsPath)
+	if err != nil && obj.ObligationsPath == "" && os.IsNotExist(err) {
+		obligations, err = DefaultObligations()
+	}
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "could not load obligations: %s", obligationsPath)
+	}
+
+	var timings *Timings             // nil discards everything
+	if obj.Timings || obj.Estimate { // the estimate learns from these
+		timings = &Timings{}
+	}
+
+	var cache *Cache // nil disables it
+	if obj.Cache || obj.CacheRemote != nil {
+		relDir := safepath.UnsafeParseIntoRelDir(CacheDir)
+		cache = &Cache{
+			Dir:     safepath.JoinToAbsDir(safePrefixAbsDir, relDir),
+			Perms:   obj.Perms,
+			Version: obj.Version,
+
+			Remote:     obj.CacheRemote,
+			SigningKey: obj.CacheSigningKey,
+		}
+		if err := cache.Init(ctx); err != nil {
+			return nil, errwrap.Wrapf(err, "could not initialize the cache")
+		}
+		obj.Logf("cache: %s", cache.Dir)
+		if cache.Remote != nil {
+			obj.Logf("cache: remote %s", cache.Remote)
 		}
 	}
 
@@ -326,17 +895,85 @@ func (obj *Main) Run(ctx context.Context) (*Output, error) {
 		Iterators: iterators, // TODO: should this be passed into Run instead?
 		// XXX: deprecate this because we have IteratorError now...
 		ShutdownOnError: false, // set to true for "perfect" scanning.
+
+		IgnoreHashes: ignoreHashes,
+		Curations:    curations,
+		Ownership:    ownership,
+		Scope:        scope,
+		Duplicates:   obj.Duplicates,
+		MemoryBudget: obj.MemoryBudget,
+
+		MmapThreshold: obj.MmapThreshold,
+		Timings:       timings,
+		Progress:      obj.Progress,
+		OnResult:      obj.OnResult,
+		Cache:         cache,
+
+		ExtractCopyrights: obj.ExtractCopyrights,
+		Deep:              obj.Deep,
+
+		MaxFiles:    obj.MaxFiles,
+		MaxBytes:    obj.MaxBytes,
+		MaxDuration: obj.MaxDuration,
+
+		Workspace: workspace,
+		Prefix:    safePrefixAbsDir,
+	}
+	if obj.Schedule {
+		core.Expensive = make(map[interfaces.Backend]struct{})
+		for _, x := range backends {
+			if util.StrInList(x.String(), ExpensiveBackends) {
+				core.Expensive[x] = struct{}{}
+			}
+		}
 	}
 
 	if err := core.Init(ctx); err != nil {
 		return nil, errwrap.Wrapf(err, "could not initialize core")
 	}
 
+	throughputPath := filepath.Join(prefix, ThroughputFileName)
+	var throughput Throughput
+	if obj.Estimate {
+		if throughput, err = LoadThroughput(throughputPath); err != nil && !os.IsNotExist(err) {
+			obj.Logf("could not load the throughput history: %+v", err)
+		}
+		if throughput == nil {
+			throughput = make(Throughput)
+		}
+		if err := obj.estimate(ctx, throughput, backends, iterators); err != nil {
+			return nil, errwrap.Wrapf(err, "could not estimate the scan")
+		}
+	}
+
 	results, passes, warnings, err := core.Run(ctx)
 	if err != nil {
 		return nil, errwrap.Wrapf(err, "core run failed")
 	}
 
+	if obj.Estimate {
+		throughput.Record(timings.List())
+		if err := throughput.Save(throughputPath, obj.Perms); err != nil {
+			obj.Logf("could not save the throughput history: %+v", err)
+		}
+	}
+
+	// record the checksum of everything that we downloaded
+	sums := make(map[string]string) // input -> sha256
+	for input, ixs := range inputIterators {
+		for _, x := range ixs {
+			if it, ok := x.(*iterator.Http); ok && it.Sum != "" {
+				sums[input] = it.Sum
+			}
+		}
+	}
+
+	if obj.InferLicenses {
+		obj.Logf("inferring licenses...")
+		passes = InferLicenses(results, passes)
+	}
+	triage := TriageList(core.Triage(), passes)
+
 	// remove all the invalid/missing profiles, keep in the original order
 	profiles := []string{}
 	for _, x := range obj.Profiles {
@@ -349,7 +986,7 @@ func (obj *Main) Run(ctx context.Context) (*Output, error) {
 		profiles = append(profiles, DefaultProfileName)
 	}
 
-	return &Output{
+	output := &Output{
 		Program:        obj.Program,
 		Version:        obj.Version,
 		Args:           inputStrings,
@@ -357,10 +994,57 @@ func (obj *Main) Run(ctx context.Context) (*Output, error) {
 		Results:        results,
 		Passes:         passes,
 		Warnings:       warnings,
+		Triage:         triage,
+		Ignored:        core.Ignored(),
+		FileHashes:     core.FileHashes(),
+		ContentHashes:  core.ContentHashes(),
+		Curated:        core.Curated(),
+		Copyrights:     core.Copyrights(),
+		Provenance:     core.Provenance(),
 		Profiles:       profiles,
 		ProfilesData:   profilesData,
 		BackendWeights: backendWeights,
-	}, nil
+		Obligations:    obligations,
+		Blend:          blend,
+		OrtPackages:    ortPackages,
+		SmartURIs:      obj.SmartURIs,
+	}
+	if len(sums) > 0 {
+		output.Checksums = sums
+	}
+	if obj.Timings {
+		output.Timings = timings.List()
+	}
+	if err := core.Exceeded(); err != nil {
+		obj.Logf("the scan is incomplete: %s", err)
+		output.Incomplete = err.Error()
+	}
+	output.Quick = obj.Quick
+	if scope != nil {
+		output.Scoped = fmt.Sprintf("only the files %s were scanned, %d were left out", scope, len(core.OutOfScope()))
+	}
+	if obj.Reuse {
+		if enabled, _ := obj.Backends[reuseBackendName]; !enabled {
+			obj.Logf("the reuse check needs the %s backend, every file will fail", reuseBackendName)
+		}
+		output.Reuse = Reuse(output)
+	}
+	output.Verdicts = Verdicts(output)
+	output.Elections = Elections(output)
+	if packages := Packages(output); len(packages) > 0 {
+		output.Packages = packages
+	}
+	if languages := Languages(core.Sizes(), output); len(languages) > 0 {
+		output.Languages = languages
+	}
+	if origins := core.Origins(); origins != nil {
+		output.Origins = &Origins{
+			Files:      origins,
+			FirstParty: obj.SummaryFirstParty,
+		}
+	}
+
+	return output, nil
 }
 
 // Output combines all of the returned data from Run() into a consistent form.
@@ -375,48 +1059,298 @@ type Output struct {
 	Results        map[string]map[interfaces.Backend]*interfaces.Result
 	Passes         []string
 	Warnings       map[string]error
+	Triage         []*TriageEntry
+	Ignored        []string
 	Profiles       []string
 	ProfilesData   map[string]*ProfileData
 	BackendWeights map[interfaces.Backend]float64
+	Obligations    Obligations
+	Blend          string
+
+	// Verdicts is the artifact by profile matrix of verdicts.
+	Verdicts []*Verdict
+
+	// Reuse is the list of REUSE compliance reports. It is only set if the
+	// check was enabled.
+	Reuse []*ReuseReport
+
+	// Packages is the list of packages found inside of the artifacts, each
+	// with its own verdicts. It is nil if there weren't any.
+	Packages []*Package
+
+	// OrtPackages maps each input argument that came from an ORT analyzer
+	// result to the package that it is the source of.
+	OrtPackages map[string]*OrtPackage
+
+	// Checksums maps each input argument that was downloaded to the hex
+	// sha256 sum of what we downloaded. It is nil if nothing was.
+	Checksums map[string]string
+
+	// FileHashes is the hex sha1 sum of each file that was read, keyed by
+	// UID. The SPDX output needs these.
+	FileHashes map[string]string
+
+	// ContentHashes is the hex sha256 sum of each file that was read, keyed
+	// by UID. This is what the ignore list and the curations match on.
+	ContentHashes map[string]string
+
+	// Curated is the curation that was applied to each file, keyed by UID.
+	Curated map[string]*Curation
+
+	// Copyrights is the list of copyright statements found in each file,
+	// keyed by UID. It is empty unless they were extracted.
+	Copyrights map[string][]string
+
+	// Provenance is the tree of iterators that ran, which shows how each
+	// file that was scanned was reached.
+	Provenance []*Provenance
+
+	// Languages are the statistics of each language that was scanned, with
+	// the most bytes first.
+	Languages []*LanguageStats
+
+	// Origins is the first-party or third-party origin of each file. It is
+	// nil if there were no ownership rules.
+	Origins *Origins
+
+	// Elections is every choice of license that the profiles made when a
+	// license expression offered one. It is nil if none were made.
+	Elections []*Election
+
+	// Timings is the time spent in each stage of the scan. It is only set
+	// if timings were enabled.
+	Timings []*Timing
+
+	// Incomplete is why the scan stopped before it was done, such as when
+	// it went over one of its limits. It is empty if the scan completed.
+	Incomplete string
+
+	// Quick is true if only the QuickBackends ran, so the results are of a
+	// lower assurance than a full scan.
+	Quick bool
+
+	// Scoped describes which files were scanned when the scan was limited
+	// to a scope. It is empty if every file was scanned.
+	Scoped string
+
+	// SmartURIs are the handlers that turn the UID's into links when this
+	// is displayed. They aren't stored with the output, so the caller that
+	// loads it must set them again.
+	SmartURIs util.SmartURIFuncs `json:"-"`
 }
 
 // ReturnOutputConsole returns a string of output, formatted for the console.
 func ReturnOutputConsole(output *Output) (string, error) {
+	return returnOutput(output, "ansi")
+}
+
+// ReturnOutputFile returns a string of output, formatted for a text file.
+func ReturnOutputFile(output *Output) (string, error) {
+	return returnOutput(output, "text")
+}
+
+// returnOutput is the common implementation of the console and file outputs. If
+// there is more than one profile, then instead of repeating the whole report
+// for each profile, we show the compact verdict matrix at the top, followed by
+// the list of violations for each, and then the full report is shown once.
+func returnOutput(output *Output, style string) (string, error) {
 	s := ""
+	if output.Incomplete != "" {
+		s += fmt.Sprintf("incomplete: %s\n\n", output.Incomplete)
+	}
+	if output.Quick {
+		s += fmt.Sprintf("quick: %s\n\n", QuickWarning)
+	}
+	if output.Scoped != "" {
+		s += fmt.Sprintf("scope: %s\n\n", output.Scoped)
+	}
 	summary := true // TODO: perhaps configure this somewhere or as a flag?
-	for _, x := range output.Profiles {
-		pro, err := SimpleProfiles(output.Results, output.Passes, output.Warnings, output.ProfilesData[x], summary, output.BackendWeights, "ansi")
+	if len(output.Profiles) <= 1 {
+		for _, x := range output.Profiles {
+			pro, err := SimpleProfiles(output.Results, output.SimpleProfilesOptions(output.ProfilesData[x], summary, style))
+			if err != nil {
+				return "", err
+			}
+
+			s += fmt.Sprintf("profile %s:\n%s\n", x, pro)
+		}
+		if output.Reuse != nil {
+			r, err := ReturnReuse(output.Reuse, style)
+			if err != nil {
+				return "", err
+			}
+			s += r + "\n"
+		}
+		if output.Packages != nil {
+			p, err := ReturnPackages(output.Packages, style)
+			if err != nil {
+				return "", err
+			}
+			s += fmt.Sprintf("packages:\n%s\n", p)
+		}
+		l, err := returnLanguagesSection(output, style)
+		if err != nil {
+			return "", err
+		}
+		p, err := returnProvenanceSection(output, style)
+		if err != nil {
+			return "", err
+		}
+		e, err := returnElectionsSection(output, style)
 		if err != nil {
 			return "", err
 		}
+		return s + e + returnOriginsSection(output) + l + p, nil
+	}
 
-		s += fmt.Sprintf("profile %s:\n%s\n", x, pro)
+	verdicts := output.Verdicts
+	if verdicts == nil {
+		verdicts = Verdicts(output)
+	}
+	matrix, err := ReturnVerdicts(verdicts, style)
+	if err != nil {
+		return "", err
 	}
+	s += fmt.Sprintf("verdicts:\n%s\n", matrix)
 
-	return s, nil
-}
+	if output.Packages != nil {
+		p, err := ReturnPackages(output.Packages, style)
+		if err != nil {
+			return "", err
+		}
+		s += fmt.Sprintf("packages:\n%s\n", p)
+	}
 
-// ReturnOutputFile returns a string of output, formatted for a text file.
-func ReturnOutputFile(output *Output) (string, error) {
-	s := ""
-	summary := true // TODO: perhaps configure this somewhere or as a flag?
-	for _, x := range output.Profiles {
-		pro, err := SimpleProfiles(output.Results, output.Passes, output.Warnings, output.ProfilesData[x], summary, output.BackendWeights, "text")
+	for _, x := range verdicts {
+		if len(x.Violations) == 0 || x.Profile == ReuseProfileName {
+			continue // the reuse report is shown separately
+		}
+		s += fmt.Sprintf("profile %s violations in %s:\n", x.Profile, x.Artifact)
+		for _, uid := range x.Violations {
+			s += fmt.Sprintf("    %s\n", uid)
+		}
+		s += "\n"
+	}
+
+	if output.Reuse != nil {
+		r, err := ReturnReuse(output.Reuse, style)
 		if err != nil {
 			return "", err
 		}
+		s += r + "\n"
+	}
 
-		s += fmt.Sprintf("profile %s:\n%s\n", x, pro)
+	pro, err := SimpleProfiles(output.Results, output.SimpleProfilesOptions(nil, summary, style))
+	if err != nil {
+		return "", err
 	}
+	s += fmt.Sprintf("all results:\n%s\n", pro)
 
-	return s, nil
+	l, err := returnLanguagesSection(output, style)
+	if err != nil {
+		return "", err
+	}
+	p, err := returnProvenanceSection(output, style)
+	if err != nil {
+		return "", err
+	}
+	e, err := returnElectionsSection(output, style)
+	if err != nil {
+		return "", err
+	}
+	return s + e + returnOriginsSection(output) + l + p, nil
 }
 
-func stdinAsString(logf func(format string, v ...interface{})) (string, error) {
-	logf("waiting for stdin...")
-	b, err := io.ReadAll(os.Stdin)
+// returnElectionsSection returns the choices of license that the profiles made
+// as a section of the text output, or the empty string if none were made.
+func returnElectionsSection(output *Output, style string) (string, error) {
+	if len(output.Elections) == 0 {
+		return "", nil
+	}
+	e, err := ReturnElections(output.Elections, style)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("elections:\n%s\n", e), nil
+}
+
+// returnOriginsSection returns the number of first-party and third-party files
+// as a section of the text output, or the empty string if they weren't
+// classified.
+func returnOriginsSection(output *Output) string {
+	if output.Origins == nil {
+		return ""
+	}
+	first, third := output.Origins.Count()
+	return fmt.Sprintf("origins:\n%s: %d files\n%s: %d files\n\n", OriginFirstParty, first, OriginThirdParty, third)
+}
+
+// returnLanguagesSection returns the statistics of each language as a section of
+// the text output, or the empty string if there are none.
+func returnLanguagesSection(output *Output, style string) (string, error) {
+	if len(output.Languages) == 0 {
+		return "", nil
+	}
+	l, err := ReturnLanguages(output.Languages, style)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("languages:\n%s\n", l), nil
+}
+
+// returnProvenanceSection returns the provenance tree as a section of the text
+// output, or the empty string if none of the iterators were nested.
+func returnProvenanceSection(output *Output, style string) (string, error) {
+	if ProvenanceDepth(output.Provenance) <= 1 {
+		return "", nil
+	}
+	p, err := ReturnProvenance(output.Provenance, style)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("provenance:\n%s\n", p), nil
+}
+
+// stdinAsString reads all of the Stdin reader and returns it as a trimmed
+// string.
+func (obj *Main) stdinAsString() (string, error) {
+	if obj.Stdin == nil {
+		return "", fmt.Errorf("no stdin available for input")
+	}
+	obj.Logf("waiting for stdin...")
+	b, err := io.ReadAll(obj.Stdin)
 	if err != nil {
 		return "", err
 	}
 	return strings.TrimSpace(string(b)), nil
 }
+
+// estimate walks the local inputs and logs how long each backend is predicted
+// to take on them. It suggests which backends to disable if the scan won't be
+// fast enough for interactive use.
+func (obj *Main) estimate(ctx context.Context, throughput Throughput, backends []interfaces.Backend, iterators []interfaces.Iterator) error {
+	paths, size, unknown, err := WalkSize(ctx, iterators)
+	if err != nil {
+		return err
+	}
+	obj.Logf("estimate: %d paths, %d bytes", paths, size)
+	if unknown > 0 {
+		obj.Logf("estimate: %d inputs can't be counted until they're downloaded", unknown)
+	}
+	names := []string{}
+	for _, x := range backends {
+		names = append(names, x.String())
+	}
+	estimates := throughput.Estimates(names, paths, size)
+	for _, line := range strings.Split(strings.TrimSuffix(ReturnEstimates(estimates), "\n"), "\n") {
+		obj.Logf("estimate: %s", line)
+	}
+	if len(estimates) > 0 && estimates[0].Known {
+		// the backends run in parallel, so the slowest is the total
+		obj.Logf("estimate: total: %s", estimates[0].Duration.Round(time.Second))
+	}
+	for _, x := range SuggestDisable(estimates, InteractiveDuration) {
+		obj.Logf("estimate: for interactive use, consider disabling the %s backend", x)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+const (
+	// CopyrightHeaderSize is the number of bytes at the start of each file
+	// that we look through for copyright statements. They are almost
+	// always in the header, or in a short license file.
+	CopyrightHeaderSize = 64 * 1024
+
+	// CopyrightMaxLength is the longest copyright statement that we keep.
+	// Anything longer is probably a false positive from some code.
+	CopyrightMaxLength = 256
+
+	// noticeRule separates the sections of the notice file.
+	noticeRule = "================================================================================"
+)
+
+var (
+	// copyrightRegexp matches a line that starts with a copyright marker,
+	// after skipping any leading comment characters or whitespace.
+	copyrightRegexp = regexp.MustCompile(`(?im)^[^\pL\pN(©\n]*((?:copyright\b|\(c\)|©)[^\n]*)$`)
+
+	// copyrightYearRegexp matches something that looks like a year.
+	copyrightYearRegexp = regexp.MustCompile(`\b(?:19|20)[0-9]{2}\b`)
+
+	// copyrightSymbolRegexp matches the (c) or © symbols.
+	copyrightSymbolRegexp = regexp.MustCompile(`(?i)\(c\)|©`)
+)
+
+// FindCopyrights returns the copyright statements in the header of the data,
+// in the order that they were found and without any duplicates. To avoid
+// picking up the many sentences which merely mention copyright, a statement
+// must contain either a year or a copyright symbol. Binary data is skipped.
+func FindCopyrights(data []byte) []string {
+	if len(data) > CopyrightHeaderSize {
+		data = data[:CopyrightHeaderSize]
+	}
+	if bytes.IndexByte(data, 0) >= 0 { // binary
+		return nil
+	}
+	found := []string{}
+	seen := make(map[string]struct{})
+	for _, m := range copyrightRegexp.FindAllSubmatch(data, -1) {
+		s := strings.TrimSpace(string(m[1]))
+		s = strings.TrimSpace(strings.TrimRight(s, "*/#;-> \t\r"))
+		if len(s) > CopyrightMaxLength {
+			continue
+		}
+		if !copyrightYearRegexp.MatchString(s) && !copyrightSymbolRegexp.MatchString(s) {
+			continue
+		}
+		if _, exists := seen[s]; exists {
+			continue
+		}
+		seen[s] = struct{}{}
+		found = append(found, s)
+	}
+	return found
+}
+
+// ReturnOutputNotice returns a third-party NOTICE file for the scanned
+// artifacts. Each artifact is listed as a component along with all of the
+// licenses and the copyright statements that were found in it. The full text
+// of every SPDX license that was found is appended once at the end. Custom
+// licenses are listed by name only, since we don't have their text. The
+// copyrights are only available if they were extracted during the scan. If the
+// files were classified, then only the third-party ones are included, unless
+// the first-party ones were asked for too. When a license expression offers a
+// choice, only the license that the NoticeProfile prefers is listed.
+func ReturnOutputNotice(output *Output) (string, error) {
+	artifacts := []string{}
+	artifacts = append(artifacts, output.Args...)
+	if len(artifacts) == 0 {
+		artifacts = append(artifacts, "")
+	}
+	files, _ := ArtifactFiles(output, artifacts)
+	profile := NoticeProfile(output) // chooses from the dual licenses
+
+	firstParty := make(map[string]struct{}) // artifacts that are all ours
+	for _, a := range artifacts {
+		if output.Origins == nil || len(files[a]) == 0 {
+			continue
+		}
+		uids := []string{}
+		for _, uid := range files[a] {
+			if output.Origins.Summarized(uid) {
+				uids = append(uids, uid)
+			}
+		}
+		if len(uids) == 0 {
+			firstParty[a] = struct{}{}
+		}
+		files[a] = uids
+	}
+
+	s := "THIRD-PARTY SOFTWARE NOTICES AND INFORMATION\n\n"
+	s += fmt.Sprintf("This file was generated by %s %s. It lists the licenses and the\n", output.Program, output.Version)
+	s += "copyright statements which were found in each component.\n"
+
+	all := []*licenses.License{}
+	for _, a := range artifacts {
+		if _, exists := firstParty[a]; exists {
+			continue
+		}
+		ls := packageLicenses(output, profile, files[a])
+		for _, x := range ls {
+			if !licenses.InList(x, all) {
+				all = append(all, x)
+			}
+		}
+
+		name, version := ArtifactCoordinates(output, a)
+		if name == "" {
+			name = a
+		}
+		if name == "" {
+			name = "unknown component"
+		}
+		if version != "" {
+			name = name + " " + version
+		}
+		s += "\n" + noticeRule + "\n"
+		s += name + "\n"
+		if a != "" {
+			s += fmt.Sprintf("Source: %s\n", a)
+		}
+		if len(ls) == 0 {
+			s += "Licenses: none found\n"
+		} else {
+			s += fmt.Sprintf("Licenses: %s\n", licenses.Join(ls))
+		}
+
+		copyrights := []string{}
+		seen := make(map[string]struct{})
+		for _, uid := range files[a] {
+			for _, x := range output.Copyrights[uid] {
+				if _, exists := seen[x]; exists {
+					continue
+				}
+				seen[x] = struct{}{}
+				copyrights = append(copyrights, x)
+			}
+		}
+		if len(copyrights) > 0 {
+			s += "\n"
+		}
+		for _, x := range copyrights {
+			s += x + "\n"
+		}
+	}
+
+	if len(all) == 0 {
+		return s, nil
+	}
+	s += "\n" + noticeRule + "\n"
+	s += "LICENSE TEXTS\n"
+	for _, license := range SortedLicenses(all) {
+		s += "\n" + noticeRule + "\n"
+		if license.SPDX == "" {
+			s += fmt.Sprintf("%s\n\n", license.String())
+			s += "This is not a known SPDX license, so its text is not included.\n"
+			continue
+		}
+		l, err := licenses.ID(license.SPDX)
+		if err != nil {
+			s += fmt.Sprintf("%s\n\n", license.SPDX)
+			s += "This license is not in our copy of the SPDX license list, so its text is not included.\n"
+			continue
+		}
+		s += fmt.Sprintf("%s (%s)\n\n", l.LicenseID, l.Name)
+		s += strings.TrimRight(l.Text, "\n") + "\n"
+	}
+	return s, nil
+}
@@ -0,0 +1,95 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestFindCopyrights(t *testing.T) {
+	data := "// Copyright (c) 2021 Jane Doe. All rights reserved.\n" +
+		"# Copyright 2019-2022 The Foo Authors\n" +
+		"/* © ACME Corp */\n" +
+		"// Copyright (c) 2021 Jane Doe. All rights reserved.\n" +
+		"// Copyright holders may not be liable for anything.\n" +
+		"func copyright() {}\n"
+	exp := []string{
+		"Copyright (c) 2021 Jane Doe. All rights reserved.",
+		"Copyright 2019-2022 The Foo Authors",
+		"© ACME Corp",
+	}
+	if got := lib.FindCopyrights([]byte(data)); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected: %q, got: %q", exp, got)
+	}
+
+	if got := lib.FindCopyrights([]byte("Copyright 2020 Bin\x00ary")); len(got) != 0 {
+		t.Errorf("expected nothing from binary data, got: %q", got)
+	}
+}
+
+func TestOutputNotice(t *testing.T) {
+	b1 := testBackend("b1")
+	output := &lib.Output{
+		Program: "yesiscan",
+		Version: "0.0.1",
+		Results: interfaces.ResultSet{
+			"file:///a/main.go": {
+				b1: {Licenses: []*licenses.License{{SPDX: "MIT"}, {Custom: "mine"}}, Confidence: 1.0},
+			},
+		},
+		Passes: []string{"file:///a/README"},
+		Copyrights: map[string][]string{
+			"file:///a/main.go": {"Copyright 2021 Jane Doe"},
+			"file:///a/README":  {"Copyright 2021 Jane Doe", "© ACME Corp"},
+		},
+	}
+	s, err := lib.ReturnOutputNotice(output)
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	mit, err := licenses.ID("MIT")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	for _, x := range []string{
+		"Licenses: MIT, mine(unknown)\n",
+		"\nCopyright 2021 Jane Doe\n© ACME Corp\n",
+		"MIT (" + mit.Name + ")\n",
+		strings.TrimRight(mit.Text, "\n"),
+		"mine(unknown)\n\nThis is not a known SPDX license",
+	} {
+		if !strings.Contains(s, x) {
+			t.Errorf("expected %q in:\n%s", x, s)
+		}
+	}
+	if strings.Count(s, "Copyright 2021 Jane Doe") != 1 {
+		t.Errorf("expected the copyrights to be deduplicated:\n%s", s)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+const (
+	// ObligationsFileName is the default name of the user supplied
+	// obligations knowledge base which is merged with the built-in one.
+	ObligationsFileName = "obligations.json"
+)
+
+// obligationsData is the built-in obligations knowledge base. It is keyed by
+// the SPDX license ID.
+//
+//go:embed obligations.json
+var obligationsData []byte
+
+// Obligation is a short, non-authoritative summary of what a license asks of
+// the people who use it. It is meant to help non-lawyer engineers understand
+// why a finding matters, and it is not legal advice.
+type Obligation struct {
+	// Attribution is true if the license requires that the copyright or
+	// license notice be kept when distributing.
+	Attribution bool `json:"attribution"`
+
+	// SourceDisclosure is the scope of the source code that must be made
+	// available. It can be "file", "library", "program", "network", or the
+	// empty string if there is no such requirement.
+	SourceDisclosure string `json:"source-disclosure"`
+
+	// PatentGrant is true if the license contains an express patent grant.
+	PatentGrant bool `json:"patent-grant"`
+
+	// PatentRetaliation is true if the license terminates for those who
+	// start patent litigation.
+	PatentRetaliation bool `json:"patent-retaliation"`
+
+	// Summary is a short human readable summary of the license.
+	Summary string `json:"summary"`
+}
+
+// String returns a compact human readable representation of the obligation.
+func (obj *Obligation) String() string {
+	s := []string{}
+	if obj.Attribution {
+		s = append(s, "attribution required")
+	}
+	if obj.SourceDisclosure != "" {
+		s = append(s, fmt.Sprintf("source disclosure (%s)", obj.SourceDisclosure))
+	}
+	if obj.PatentGrant {
+		s = append(s, "patent grant")
+	}
+	if obj.PatentRetaliation {
+		s = append(s, "patent retaliation")
+	}
+	if obj.Summary != "" {
+		s = append(s, obj.Summary)
+	}
+	return strings.Join(s, "; ")
+}
+
+// Obligations is the knowledge base of obligations keyed by license ID.
+type Obligations map[string]*Obligation
+
+// Lookup returns the obligation for this license ID string or nil if unknown.
+// It is safe to call on a nil map.
+func (obj Obligations) Lookup(license string) *Obligation {
+	if obj == nil {
+		return nil
+	}
+	if x, exists := obj[license]; exists {
+		return x
+	}
+	// deprecated spdx names, eg: GPL-2.0 and GPL-2.0+
+	if strings.HasSuffix(license, "+") {
+		x, _ := obj[strings.TrimSuffix(license, "+")+"-or-later"]
+		return x
+	}
+	x, _ := obj[license+"-only"]
+	return x
+}
+
+// DefaultObligations returns a copy of the built-in obligations knowledge base.
+func DefaultObligations() (Obligations, error) {
+	obligations := make(Obligations)
+	if err := decodeObligations(obligationsData, obligations); err != nil {
+		return nil, errwrap.Wrapf(err, "error decoding built-in obligations")
+	}
+	return obligations, nil
+}
+
+// LoadObligations returns the built-in obligations knowledge base, with any of
+// the entries in the file at filename added in. Entries in the file take
+// precedence over the built-in ones.
+func LoadObligations(filename string) (Obligations, error) {
+	obligations, err := DefaultObligations()
+	if err != nil {
+		return nil, err
+	}
+	if filename == "" {
+		return obligations, nil
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err // the caller might want to check os.IsNotExist
+	}
+	if err := decodeObligations(b, obligations); err != nil {
+		return nil, errwrap.Wrapf(err, "error decoding obligations json output")
+	}
+	return obligations, nil
+}
+
+// decodeObligations decodes the json data and adds it into the obligations map.
+func decodeObligations(data []byte, obligations Obligations) error {
+	buffer := bytes.NewBuffer(data)
+	if buffer.Len() == 0 {
+		// TODO: should this be an error, or just a silent ignore?
+		return fmt.Errorf("empty input file")
+	}
+	decoder := json.NewDecoder(buffer)
+
+	m := make(map[string]*Obligation) // this gets populated during decode
+	if err := decoder.Decode(&m); err != nil {
+		return err
+	}
+	for k, v := range m {
+		obligations[k] = v
+	}
+	return nil
+}
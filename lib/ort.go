@@ -0,0 +1,385 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/util/errwrap"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+const (
+	// OrtVcsTypeGit is the ORT name for the git version control system.
+	OrtVcsTypeGit = "Git"
+
+	// OrtUnknownLine is the line number that ORT uses when it's not known.
+	OrtUnknownLine = -1
+
+	// OrtSeverityError is the ORT severity of a scanning error.
+	OrtSeverityError = "ERROR"
+)
+
+// OrtVcsInfo is the version control information of an ORT package.
+type OrtVcsInfo struct {
+	Type     string `json:"type"`
+	URL      string `json:"url"`
+	Revision string `json:"revision"`
+	Path     string `json:"path"`
+}
+
+// OrtHash is the hash of an ORT remote artifact.
+type OrtHash struct {
+	Value     string `json:"value"`
+	Algorithm string `json:"algorithm"`
+}
+
+// OrtRemoteArtifact is a downloadable artifact of an ORT package.
+type OrtRemoteArtifact struct {
+	URL  string   `json:"url"`
+	Hash *OrtHash `json:"hash,omitempty"`
+}
+
+// OrtPackage is the part of a package in an ORT analyzer result that we use.
+// The ID is in the ORT `type:namespace:name:version` coordinate format.
+type OrtPackage struct {
+	ID               string             `json:"id"`
+	DeclaredLicenses []string           `json:"declared_licenses"`
+	Vcs              *OrtVcsInfo        `json:"vcs,omitempty"`
+	VcsProcessed     *OrtVcsInfo        `json:"vcs_processed,omitempty"`
+	SourceArtifact   *OrtRemoteArtifact `json:"source_artifact,omitempty"`
+}
+
+// Input returns the input argument that we scan for this package. Like the ORT
+// scanner, we prefer the version control source over the source artifact. The
+// git revision is only kept if it is a commit hash. The path within the
+// repository is ignored, so the whole repository is scanned. If we can't find
+// a source which is supported, then this returns the empty string.
+func (obj *OrtPackage) Input() string {
+	input, _ := obj.source()
+	return input
+}
+
+// source returns the input argument that we scan for this package, and the
+// provenance that it corresponds to.
+func (obj *OrtPackage) source() (string, *OrtProvenance) {
+	vcs := obj.VcsProcessed
+	if vcs == nil || vcs.URL == "" {
+		vcs = obj.Vcs
+	}
+	if vcs != nil && vcs.URL != "" && strings.EqualFold(vcs.Type, OrtVcsTypeGit) {
+		u := strings.TrimPrefix(vcs.URL, "git+")
+		provenance := &OrtProvenance{VcsInfo: vcs, ResolvedRevision: vcs.Revision}
+		if !plumbing.IsHash(vcs.Revision) {
+			return u, provenance
+		}
+		if c := ortCommitURL(u, vcs.Revision); c != "" {
+			return c, provenance
+		}
+		// we can't pin this commit, so don't scan some other one instead
+	}
+	if a := obj.SourceArtifact; a != nil && strings.HasPrefix(strings.ToLower(a.URL), iterator.HttpsSchemeRaw+"://") && ortIsArchive(a.URL) {
+		return a.URL, &OrtProvenance{SourceArtifact: a}
+	}
+	return "", nil
+}
+
+// ortCommitURL returns the link to a single commit of a git repository, which
+// the parser trims back off of the end again to get the hash. The layout of this
+// link depends on the host, so for the hosts we don't know this returns the
+// empty string.
+func ortCommitURL(repo, hash string) string {
+	u, err := url.Parse(repo)
+	if err != nil || !strings.EqualFold(u.Scheme, iterator.HttpsSchemeRaw) {
+		return ""
+	}
+	host := strings.ToLower(u.Host)
+	switch {
+	case host == "github.com":
+		return strings.TrimSuffix(repo, ".git") + "/commit/" + hash
+	case strings.HasSuffix(host, ".googlesource.com"):
+		return strings.TrimSuffix(repo, "/") + "/+/" + hash
+	}
+	return ""
+}
+
+// ortIsArchive returns true if the URL has an archive extension that we can
+// extract.
+func ortIsArchive(u string) bool {
+	extensions := []string{
+		iterator.ZipExtension,
+		iterator.JarExtension,
+		iterator.WhlExtension,
+		iterator.TarExtension,
+	}
+	extensions = append(extensions, iterator.GzipExtensions...)
+	extensions = append(extensions, iterator.Bzip2Extensions...)
+	for _, x := range extensions {
+		if strings.HasSuffix(strings.ToLower(u), x) {
+			return true
+		}
+	}
+	return false
+}
+
+// ortAnalyzerResult is the part of the ORT analyzer result file that we read.
+// Older versions of ORT wrapped each package in an object with its curations,
+// so we decode them later once we know which format they're in.
+type ortAnalyzerResult struct {
+	Analyzer *struct {
+		Result *struct {
+			Packages []json.RawMessage `json:"packages"`
+		} `json:"result"`
+	} `json:"analyzer"`
+}
+
+// ReadOrtAnalyzerResult reads the list of packages from the json output of the
+// ORT analyzer. The packages are returned in the order of their ID's.
+func ReadOrtAnalyzerResult(path string) ([]*OrtPackage, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	result := &ortAnalyzerResult{}
+	if err := json.Unmarshal(b, result); err != nil {
+		return nil, errwrap.Wrapf(err, "could not decode ort analyzer result")
+	}
+	if result.Analyzer == nil || result.Analyzer.Result == nil {
+		return nil, errwrap.Wrapf(os.ErrNotExist, "no analyzer result found")
+	}
+
+	packages := []*OrtPackage{}
+	for _, raw := range result.Analyzer.Result.Packages {
+		wrapped := &struct {
+			Package *OrtPackage `json:"package"`
+		}{}
+		if err := json.Unmarshal(raw, wrapped); err != nil {
+			return nil, errwrap.Wrapf(err, "could not decode ort package")
+		}
+		pkg := wrapped.Package
+		if pkg == nil { // newer format
+			pkg = &OrtPackage{}
+			if err := json.Unmarshal(raw, pkg); err != nil {
+				return nil, errwrap.Wrapf(err, "could not decode ort package")
+			}
+		}
+		if pkg.ID == "" {
+			continue
+		}
+		packages = append(packages, pkg)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].ID < packages[j].ID
+	})
+	return packages, nil
+}
+
+// OrtScanResults is the list of ORT scan results that we export. There is one
+// for each artifact that was scanned.
+type OrtScanResults struct {
+	ScanResults []*OrtScanResult `json:"scan_results"`
+}
+
+// OrtScanResult is a single scan result in the ORT format. The ID is only set
+// if the artifact came from a package in an ORT analyzer result.
+type OrtScanResult struct {
+	ID         string             `json:"id,omitempty"`
+	Provenance *OrtProvenance     `json:"provenance"`
+	Scanner    *OrtScannerDetails `json:"scanner"`
+	Summary    *OrtScanSummary    `json:"summary"`
+}
+
+// OrtProvenance is where the scanned source code came from. It is empty if it
+// was a local path.
+type OrtProvenance struct {
+	VcsInfo          *OrtVcsInfo        `json:"vcs_info,omitempty"`
+	ResolvedRevision string             `json:"resolved_revision,omitempty"`
+	SourceArtifact   *OrtRemoteArtifact `json:"source_artifact,omitempty"`
+}
+
+// OrtScannerDetails identifies the scanner which produced the result.
+type OrtScannerDetails struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	Configuration string `json:"configuration"`
+}
+
+// OrtScanSummary contains the findings of a scan.
+type OrtScanSummary struct {
+	StartTime  string               `json:"start_time"`
+	EndTime    string               `json:"end_time"`
+	Licenses   []*OrtLicenseFinding `json:"licenses"`
+	Copyrights []struct{}           `json:"copyrights"`
+	Issues     []*OrtIssue          `json:"issues"`
+}
+
+// OrtLicenseFinding is a license that was found at a location.
+type OrtLicenseFinding struct {
+	License  string           `json:"license"`
+	Location *OrtTextLocation `json:"location"`
+	Score    float64          `json:"score"`
+}
+
+// OrtTextLocation is a location in a file. Since we don't know which lines a
+// license was found on, these are always unknown.
+type OrtTextLocation struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+}
+
+// OrtIssue is a problem that happened during the scan.
+type OrtIssue struct {
+	Timestamp string `json:"timestamp"`
+	Source    string `json:"source"`
+	Message   string `json:"message"`
+	Severity  string `json:"severity"`
+}
+
+// NewOrtScanResults builds the ORT form of the output. The paths are relative
+// to the deepest directory that contains all of the files of the artifact.
+// Inferred results are left out, since ORT does its own reasoning about the
+// license files. Each backend is listed in the scanner configuration.
+func NewOrtScanResults(output *Output) *OrtScanResults {
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	backends := []string{}
+	for name, enabled := range output.Backends {
+		if enabled {
+			backends = append(backends, name)
+		}
+	}
+	sort.Strings(backends)
+
+	artifacts := []string{}
+	artifacts = append(artifacts, output.Args...)
+	if len(artifacts) == 0 {
+		artifacts = append(artifacts, "")
+	}
+	files, _ := ArtifactFiles(output, artifacts)
+
+	results := []*OrtScanResult{}
+	for _, a := range artifacts {
+		result := &OrtScanResult{
+			Provenance: ortProvenance(a),
+			Scanner: &OrtScannerDetails{
+				Name:          output.Program,
+				Version:       output.Version,
+				Configuration: "backends=" + strings.Join(backends, ","),
+			},
+			Summary: &OrtScanSummary{
+				StartTime:  now,
+				EndTime:    now,
+				Licenses:   []*OrtLicenseFinding{},
+				Copyrights: []struct{}{},
+				Issues:     []*OrtIssue{},
+			},
+		}
+		if pkg, exists := output.OrtPackages[a]; exists {
+			result.ID = pkg.ID
+			_, result.Provenance = pkg.source()
+		}
+		// keep the hash from the analyzer, which we have verified if we can
+		if sum, exists := output.Checksums[a]; exists && result.Provenance.SourceArtifact != nil && result.Provenance.SourceArtifact.Hash == nil {
+			provenance := *result.Provenance       // copy
+			artifact := *provenance.SourceArtifact // copy
+			artifact.Hash = &OrtHash{Value: sum, Algorithm: ChecksumAlgorithm}
+			provenance.SourceArtifact = &artifact
+			result.Provenance = &provenance
+		}
+
+		uids := files[a]
+		root := ""
+		if len(uids) > 0 {
+			root = uidPath(commonParentUID(uids))
+		}
+		for _, uid := range uids {
+			p := strings.TrimPrefix(uidPath(uid), root)
+			m := output.Results[uid]
+			for _, backend := range SortedResultBackends(m) {
+				r := m[backend]
+				if r.Skip != nil {
+					result.Summary.Issues = append(result.Summary.Issues, &OrtIssue{
+						Timestamp: now,
+						Source:    backend.String(),
+						Message:   p + ": " + r.Skip.Error(),
+						Severity:  OrtSeverityError,
+					})
+				}
+				if r.Meta != nil && r.Meta.Inferred {
+					continue
+				}
+				for _, license := range SortedLicenses(r.Licenses) {
+					result.Summary.Licenses = append(result.Summary.Licenses, &OrtLicenseFinding{
+						License: scancodeSPDX(license),
+						Location: &OrtTextLocation{
+							Path:      p,
+							StartLine: OrtUnknownLine,
+							EndLine:   OrtUnknownLine,
+						},
+						Score: r.Confidence * 100.0,
+					})
+				}
+			}
+		}
+		results = append(results, result)
+	}
+
+	return &OrtScanResults{
+		ScanResults: results,
+	}
+}
+
+// ortProvenance guesses the provenance of an input argument which didn't come
+// from an ORT analyzer result.
+func ortProvenance(input string) *OrtProvenance {
+	lower := strings.ToLower(input)
+	if strings.HasPrefix(lower, iterator.HttpsSchemeRaw+"://") && ortIsArchive(input) {
+		return &OrtProvenance{
+			SourceArtifact: &OrtRemoteArtifact{URL: input},
+		}
+	}
+	if strings.Contains(lower, "://") {
+		return &OrtProvenance{
+			VcsInfo: &OrtVcsInfo{Type: OrtVcsTypeGit, URL: input},
+		}
+	}
+	return &OrtProvenance{} // unknown
+}
+
+// ReturnOutputOrt returns a string of output, formatted as ORT scan results.
+func ReturnOutputOrt(output *Output) (string, error) {
+	b, err := json.MarshalIndent(NewOrtScanResults(output), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
@@ -0,0 +1,166 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestReadOrtAnalyzerResult(t *testing.T) {
+	const hash = "496d080bc7fe835511d7220f127e118d0881b792"
+	data := `{
+	"analyzer": {
+		"result": {
+			"projects": [],
+			"packages": [
+				{
+					"package": {
+						"id": "NPM::b:1.0.0",
+						"vcs_processed": {"type": "Git", "url": "git+https://github.com/x/b.git", "revision": "` + hash + `", "path": ""}
+					},
+					"curations": []
+				},
+				{
+					"id": "Maven:org.example:a:1.0",
+					"vcs": {"type": "", "url": "", "revision": "", "path": ""},
+					"source_artifact": {"url": "https://example.com/a-1.0-sources.jar", "hash": {"value": "abc", "algorithm": "SHA-1"}}
+				},
+				{
+					"id": "PyPI::c:2.0",
+					"source_artifact": {"url": "https://example.com/c-2.0.exe"}
+				}
+			]
+		}
+	}
+}`
+	dir, err := os.MkdirTemp("", "yesiscan-ort-")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "analyzer-result.json")
+	if err := os.WriteFile(p, []byte(data), 0600); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+
+	packages, err := lib.ReadOrtAnalyzerResult(p)
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if len(packages) != 3 {
+		t.Fatalf("expected 3 packages, got %d", len(packages))
+	}
+	exp := []string{
+		"https://example.com/a-1.0-sources.jar",
+		"https://github.com/x/b/commit/" + hash,
+		"", // unsupported
+	}
+	for i, x := range packages {
+		if s := x.Input(); s != exp[i] {
+			t.Errorf("package %s: exp: %s, got: %s", x.ID, exp[i], s)
+		}
+	}
+
+	b := testBackend("b1")
+	input := exp[1]
+	output := &lib.Output{
+		Program: "yesiscan",
+		Args:    []string{input},
+		Backends: map[string]bool{
+			"b1": true,
+		},
+		Results: interfaces.ResultSet{
+			"file:///tmp/repo/src/main.go": {
+				b: {Licenses: []*licenses.License{{SPDX: "MIT"}}, Confidence: 0.9},
+			},
+			"file:///tmp/repo/README": {
+				b: {Licenses: []*licenses.License{{SPDX: "MIT"}}, Confidence: 0.5, Meta: &interfaces.Meta{Inferred: true}},
+			},
+		},
+		OrtPackages: map[string]*lib.OrtPackage{
+			input: packages[1],
+		},
+	}
+	results := lib.NewOrtScanResults(output).ScanResults
+	if len(results) != 1 {
+		t.Fatalf("expected 1 scan result, got %d", len(results))
+	}
+	r := results[0]
+	if r.ID != "NPM::b:1.0.0" || r.Provenance.VcsInfo == nil || r.Provenance.ResolvedRevision != hash {
+		t.Errorf("unexpected scan result: %+v", r)
+	}
+	if len(r.Summary.Licenses) != 1 {
+		t.Fatalf("expected 1 license finding, got %d", len(r.Summary.Licenses))
+	}
+	if x := r.Summary.Licenses[0]; x.License != "MIT" || x.Location.Path != "src/main.go" || x.Score != 90.0 {
+		t.Errorf("unexpected finding: %+v", x)
+	}
+}
+
+func TestOrtPackageInput(t *testing.T) {
+	const hash = "496d080bc7fe835511d7220f127e118d0881b792"
+	artifact := &lib.OrtRemoteArtifact{URL: "https://example.com/d-1.0.tar.gz"}
+	tests := []struct {
+		name string
+		pkg  *lib.OrtPackage
+		exp  string
+	}{
+		{
+			name: "github",
+			pkg:  &lib.OrtPackage{Vcs: &lib.OrtVcsInfo{Type: "Git", URL: "https://github.com/x/d.git", Revision: hash}},
+			exp:  "https://github.com/x/d/commit/" + hash,
+		},
+		{
+			name: "googlesource",
+			pkg:  &lib.OrtPackage{Vcs: &lib.OrtVcsInfo{Type: "Git", URL: "https://webrtc.googlesource.com/src", Revision: hash}},
+			exp:  "https://webrtc.googlesource.com/src/+/" + hash,
+		},
+		{
+			name: "branch",
+			pkg:  &lib.OrtPackage{Vcs: &lib.OrtVcsInfo{Type: "Git", URL: "https://gitlab.com/x/d.git", Revision: "main"}},
+			exp:  "https://gitlab.com/x/d.git",
+		},
+		{
+			name: "gitlab with artifact",
+			pkg:  &lib.OrtPackage{Vcs: &lib.OrtVcsInfo{Type: "Git", URL: "https://gitlab.com/x/d.git", Revision: hash}, SourceArtifact: artifact},
+			exp:  artifact.URL,
+		},
+		{
+			name: "scp",
+			pkg:  &lib.OrtPackage{Vcs: &lib.OrtVcsInfo{Type: "Git", URL: "git@github.com:x/d.git", Revision: hash}},
+			exp:  "",
+		},
+	}
+	for _, tt := range tests {
+		if s := tt.pkg.Input(); s != tt.exp {
+			t.Errorf("%s: exp: %s, got: %s", tt.name, tt.exp, s)
+		}
+	}
+}
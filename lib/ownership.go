@@ -0,0 +1,417 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/util/errwrap"
+
+	git "github.com/go-git/go-git/v5"
+)
+
+const (
+	// OriginFirstParty is the origin of the code that we wrote ourselves.
+	OriginFirstParty = "first-party"
+
+	// OriginThirdParty is the origin of the code that came from somewhere
+	// else, such as a vendored dependency. This is what the license
+	// obligations apply to.
+	OriginThirdParty = "third-party"
+)
+
+var (
+	// codeownersPaths are the places that a CODEOWNERS file is looked for,
+	// relative to the root of the scan, in the order that GitHub uses.
+	codeownersPaths = []string{
+		".github/CODEOWNERS",
+		"CODEOWNERS",
+		"docs/CODEOWNERS",
+	}
+)
+
+// Ownership is the set of rules that classify each scanned file as either
+// first-party or third-party code. The path rules are checked first, with the
+// third-party ones winning, so that a vendor directory inside of our own code
+// is still third-party. Then the CODEOWNERS file of the scanned root is
+// checked, then the git remote that it came from, and anything else gets the
+// default.
+type Ownership struct {
+	// FirstParty is the list of path patterns of first-party files. They
+	// use the same syntax as a .gitignore file, relative to the root of
+	// each scanned artifact.
+	FirstParty []string `json:"first-party,omitempty"`
+
+	// ThirdParty is the list of path patterns of third-party files. These
+	// win over all of the other rules.
+	ThirdParty []string `json:"third-party,omitempty"`
+
+	// Remotes is the list of git remote prefixes that we own, such as
+	// github.com/awslabs/ for example. The scheme, any user, and the .git
+	// suffix are ignored when comparing, and they match on whole path
+	// segments.
+	Remotes []string `json:"remotes,omitempty"`
+
+	// Owners is the list of CODEOWNERS owners that are us, such as
+	// @awslabs/yesiscan-maintainers for example. A file owned by any of
+	// them is first-party.
+	Owners []string `json:"owners,omitempty"`
+
+	// Default is the origin of the files that none of the rules matched.
+	// If it is empty, then they are third-party, since that is the safe
+	// choice.
+	Default string `json:"default,omitempty"`
+}
+
+// Validate returns an error if these rules are not valid.
+func (obj *Ownership) Validate() error {
+	switch obj.Default {
+	case "", OriginFirstParty, OriginThirdParty:
+	default:
+		return fmt.Errorf("invalid default origin: %s", obj.Default)
+	}
+	for _, x := range append(append([]string{}, obj.FirstParty...), obj.ThirdParty...) {
+		if _, err := ownershipRegexp(x); err != nil {
+			return errwrap.Wrapf(err, "invalid path pattern: %s", x)
+		}
+	}
+	return nil
+}
+
+// Empty returns true if there aren't any rules, in which case nothing should be
+// classified.
+func (obj *Ownership) Empty() bool {
+	return obj == nil || (len(obj.FirstParty) == 0 && len(obj.ThirdParty) == 0 && len(obj.Remotes) == 0 && len(obj.Owners) == 0 && obj.Default == "")
+}
+
+// Origin returns the origin of a file, given its path relative to the root of
+// the scan, the owners of it from the CODEOWNERS file, and the git remote that
+// it came from. Any of these can be empty if they're not known.
+func (obj *Ownership) Origin(rel string, owners []string, remote string) string {
+	if matchPatterns(obj.ThirdParty, rel) {
+		return OriginThirdParty
+	}
+	if matchPatterns(obj.FirstParty, rel) {
+		return OriginFirstParty
+	}
+	for _, x := range owners {
+		for _, y := range obj.Owners {
+			if strings.EqualFold(x, y) {
+				return OriginFirstParty
+			}
+		}
+	}
+	if remote != "" {
+		r := normalizeRemote(remote)
+		for _, x := range obj.Remotes {
+			// match on whole path segments only
+			if n := normalizeRemote(x); r == n || strings.HasPrefix(r, n+"/") {
+				return OriginFirstParty
+			}
+		}
+	}
+	if obj.Default != "" {
+		return obj.Default
+	}
+	return OriginThirdParty
+}
+
+// Classify returns the origin of each file that was scanned, keyed by UID. It
+// takes the iterators with the UID's of the files that each of them scanned,
+// and the path of each UID on disk. The root of each file is the outermost
+// filesystem iterator that it is in, and the files which were found inside of
+// an archive are classified by where that archive is.
+func (obj *Ownership) Classify(files map[interfaces.Iterator][]string, paths map[string]string) map[string]string {
+	origins := make(map[string]string)
+	codeowners := make(map[string][]*codeownersRule) // cache by root
+	remotes := make(map[string]string)               // cache by root
+	for it, uids := range files {
+		for _, uid := range uids {
+			p, exists := paths[uid]
+			if !exists {
+				continue
+			}
+			rel, root, remote := ownershipLocation(it, p)
+
+			var owners []string
+			if root != "" && len(obj.Owners) > 0 {
+				rules, exists := codeowners[root]
+				if !exists {
+					rules = loadCodeowners(root)
+					codeowners[root] = rules
+				}
+				owners = matchCodeowners(rules, rel)
+			}
+			if remote == "" && root != "" && len(obj.Remotes) > 0 {
+				r, exists := remotes[root]
+				if !exists {
+					r = gitRemote(root)
+					remotes[root] = r
+				}
+				remote = r
+			}
+
+			origins[uid] = obj.Origin(rel, owners, remote)
+		}
+	}
+	return origins
+}
+
+// ownershipLocation walks up the chain of iterators from the one that scanned
+// the file at this path, and returns its path relative to the outermost root,
+// that root directory, and the url of the git repository that it came from.
+// When it passes an archive, the path of the archive itself is used from then
+// on.
+func ownershipLocation(it interfaces.Iterator, p string) (string, string, string) {
+	rel, root, remote := filepath.Base(p), "", ""
+	for ; it != nil; it = it.GetIterator() {
+		switch x := it.(type) {
+		case *iterator.Fs:
+			if x.Path == nil {
+				continue
+			}
+			if !x.Path.IsDir() {
+				if x.Path.Path() == p {
+					rel, root = filepath.Base(p), filepath.Dir(p)
+				}
+				continue
+			}
+			dir := x.Path.Path()
+			if r, err := filepath.Rel(dir, p); err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+				rel, root = r, dir
+			}
+		case *iterator.Git:
+			if remote == "" {
+				remote = x.URL
+			}
+		case *iterator.Zip:
+			p = x.Path.Path()
+		case *iterator.Tar:
+			p = x.Path.Path()
+		case *iterator.Gzip:
+			p = x.Path.Path()
+		case *iterator.Bzip2:
+			p = x.Path.Path()
+		}
+	}
+	return filepath.ToSlash(rel), root, remote
+}
+
+// normalizeRemote strips the parts of a git url that don't matter for ownership
+// so that the https and the ssh forms of it can be compared.
+func normalizeRemote(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if i := strings.Index(s, "://"); i >= 0 {
+		s = s[i+len("://"):]
+	}
+	if i := strings.Index(s, "@"); i >= 0 && i < strings.IndexAny(s+"/", "/:") {
+		s = s[i+1:]
+	}
+	if i := strings.Index(s, ":"); i >= 0 && i < strings.IndexAny(s+"/", "/") {
+		s = s[:i] + "/" + s[i+1:] // scp style, or a port
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(s, "/"), ".git")
+}
+
+// gitRemote returns the url of the origin remote of the git repository at this
+// root directory, or the empty string if there isn't one.
+func gitRemote(root string) string {
+	repository, err := git.PlainOpen(root)
+	if err != nil {
+		return ""
+	}
+	remote, err := repository.Remote(git.DefaultRemoteName)
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return ""
+	}
+	return remote.Config().URLs[0]
+}
+
+// codeownersRule is a single line of a CODEOWNERS file.
+type codeownersRule struct {
+	pattern *regexp.Regexp
+	owners  []string
+}
+
+// loadCodeowners reads the CODEOWNERS file of this root directory. It returns
+// nil if there isn't one. Invalid lines are skipped like GitHub does.
+func loadCodeowners(root string) []*codeownersRule {
+	for _, x := range codeownersPaths {
+		b, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(x)))
+		if err != nil {
+			continue
+		}
+		return parseCodeowners(b)
+	}
+	return nil
+}
+
+// parseCodeowners parses the contents of a CODEOWNERS file.
+func parseCodeowners(data []byte) []*codeownersRule {
+	rules := []*codeownersRule{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		r, err := ownershipRegexp(fields[0])
+		if err != nil {
+			continue
+		}
+		rules = append(rules, &codeownersRule{
+			pattern: r,
+			owners:  fields[1:],
+		})
+	}
+	return rules
+}
+
+// matchCodeowners returns the owners of this relative path. The last rule that
+// matches wins, and it can have no owners at all.
+func matchCodeowners(rules []*codeownersRule, rel string) []string {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].pattern.MatchString(rel) {
+			return rules[i].owners
+		}
+	}
+	return nil
+}
+
+// matchPatterns returns true if any of these path patterns match this relative
+// path. Invalid patterns were already caught by Validate, so they never match.
+func matchPatterns(patterns []string, rel string) bool {
+	for _, x := range patterns {
+		r, err := ownershipRegexp(x)
+		if err != nil {
+			continue
+		}
+		if r.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// ownershipRegexp turns a path pattern in the .gitignore syntax into a regexp.
+// A pattern with a slash at the start or in the middle is anchored to the root,
+// and otherwise it can match at any depth. A pattern that matches a directory
+// also matches everything inside of it, and one with a trailing slash only
+// matches directories.
+func ownershipRegexp(pattern string) (*regexp.Regexp, error) {
+	p := strings.TrimSpace(pattern)
+	if p == "" || p == "/" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+	dir := strings.HasSuffix(p, "/")
+	p = strings.TrimSuffix(p, "/")
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+
+	s := ""
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			s += "(?:.*/)?"
+			i += len("**/") - 1
+		case strings.HasPrefix(p[i:], "**"):
+			s += ".*"
+			i += len("**") - 1
+		case p[i] == '*':
+			s += "[^/]*"
+		case p[i] == '?':
+			s += "[^/]"
+		default:
+			s += regexp.QuoteMeta(p[i : i+1])
+		}
+	}
+
+	prefix := "^(?:.*/)?"
+	if anchored {
+		prefix = "^"
+	}
+	suffix := "(?:/.*)?$"
+	if dir {
+		suffix = "/.*$"
+	}
+	return regexp.Compile(prefix + s + suffix)
+}
+
+// Origins is the origin of each file that was scanned, and whether the license
+// summaries should include the first-party ones.
+type Origins struct {
+	// Files maps each UID to either OriginFirstParty or OriginThirdParty.
+	Files map[string]string `json:"files"`
+
+	// FirstParty is true if the summaries include the first-party files
+	// too. By default they only cover the third-party ones, since those
+	// are what the license obligations apply to.
+	FirstParty bool `json:"first-party,omitempty"`
+}
+
+// Origin returns the origin of the file with this UID, or the empty string if
+// it wasn't classified. This is safe to call on a nil Origins.
+func (obj *Origins) Origin(uid string) string {
+	if obj == nil {
+		return ""
+	}
+	return obj.Files[uid]
+}
+
+// Summarized returns true if the file with this UID should be counted in the
+// license summaries. Everything is counted if there was no classification.
+func (obj *Origins) Summarized(uid string) bool {
+	if obj == nil || obj.FirstParty {
+		return true
+	}
+	return obj.Files[uid] != OriginFirstParty
+}
+
+// Count returns the number of first-party and third-party files.
+func (obj *Origins) Count() (int, int) {
+	first, third := 0, 0
+	if obj == nil {
+		return first, third
+	}
+	for _, x := range obj.Files {
+		if x == OriginFirstParty {
+			first++
+			continue
+		}
+		third++
+	}
+	return first, third
+}
@@ -0,0 +1,147 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+func TestOwnershipOrigin(t *testing.T) {
+	ownership := &lib.Ownership{
+		FirstParty: []string{"/src/", "*.md"},
+		ThirdParty: []string{"vendor/", "/src/**/generated_*.go"},
+		Remotes:    []string{"https://github.com/awslabs/"},
+		Owners:     []string{"@awslabs/maintainers"},
+	}
+	if err := ownership.Validate(); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	tests := []struct {
+		rel    string
+		owners []string
+		remote string
+		origin string
+	}{
+		{"src/main.go", nil, "", lib.OriginFirstParty},
+		{"src/vendor/x/y.go", nil, "", lib.OriginThirdParty},
+		{"src/a/b/generated_foo.go", nil, "", lib.OriginThirdParty},
+		{"docs/README.md", nil, "", lib.OriginFirstParty},
+		{"other/src/main.go", nil, "", lib.OriginThirdParty},
+		{"lib/x.go", []string{"@AWSLabs/maintainers"}, "", lib.OriginFirstParty},
+		{"lib/x.go", []string{"@someone"}, "", lib.OriginThirdParty},
+		{"lib/x.go", nil, "git@github.com:awslabs/yesiscan.git", lib.OriginFirstParty},
+		{"lib/x.go", nil, "https://github.com/awslabsfoo/x", lib.OriginThirdParty},
+		{"vendor/x.go", nil, "https://github.com/awslabs/yesiscan", lib.OriginThirdParty},
+	}
+	for i, tc := range tests {
+		if origin := ownership.Origin(tc.rel, tc.owners, tc.remote); origin != tc.origin {
+			t.Errorf("test #%d (%s): expected %s, got: %s", i, tc.rel, tc.origin, origin)
+		}
+	}
+
+	if err := (&lib.Ownership{Default: "ours"}).Validate(); err == nil {
+		t.Errorf("expected an invalid default to error")
+	}
+}
+
+func TestOwnershipCodeowners(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".github/CODEOWNERS": "* @someone\n/lib/ @awslabs/maintainers # us\n",
+		"lib/main.go":        "// MIT\n",
+		"lib/vendor/x.go":    "// MIT\n",
+		"other/y.go":         "// MIT\n",
+	}
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+		if err := os.WriteFile(p, []byte(data), 0600); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+	}
+	absDir, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	logf := func(format string, v ...interface{}) {
+		t.Logf(format, v...)
+	}
+
+	core := &lib.Core{
+		Logf:     logf,
+		Backends: []interfaces.Backend{&mitBackend{}},
+		Iterators: []interfaces.Iterator{
+			&iterator.Fs{
+				Logf:   logf,
+				Prefix: absDir,
+				Path:   absDir,
+			},
+		},
+		Ownership: &lib.Ownership{
+			ThirdParty: []string{"vendor/"},
+			Owners:     []string{"@awslabs/maintainers"},
+		},
+	}
+	if err := core.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if _, _, _, err := core.Run(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	root := iterator.FileScheme + absDir.String()
+	origins := &lib.Origins{Files: core.Origins()}
+	expected := map[string]string{
+		"lib/main.go":     lib.OriginFirstParty,
+		"lib/vendor/x.go": lib.OriginThirdParty,
+		"other/y.go":      lib.OriginThirdParty,
+	}
+	for name, origin := range expected {
+		if x := origins.Origin(root + name); x != origin {
+			t.Errorf("expected %s for %s, got: %s", origin, name, x)
+		}
+	}
+	if origins.Summarized(root + "lib/main.go") {
+		t.Errorf("expected the first-party file to be left out of the summary")
+	}
+	if !origins.Summarized(root + "other/y.go") {
+		t.Errorf("expected the third-party file to be in the summary")
+	}
+}
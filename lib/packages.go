@@ -0,0 +1,218 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+)
+
+// PackageMarkers are the names of the files which mark the top directory of a
+// package. A directory with any of these in it is a separate deliverable, and
+// all the files below it belong to it, unless they are in a deeper package.
+var PackageMarkers = []string{
+	"Cargo.toml",    // rust
+	"composer.json", // php
+	"go.mod",        // golang
+	"package.json",  // javascript
+	"pom.xml",       // java
+	"pyproject.toml",
+	"setup.py", // python
+}
+
+// Package is a group of files under a package marker inside of an artifact.
+type Package struct {
+	// Artifact is the input argument that this package is in.
+	Artifact string `json:"artifact"`
+
+	// Root is the UID of the top directory of the package.
+	Root string `json:"root"`
+
+	// Path is the directory of the package relative to the top of the
+	// artifact. It is "." for the top itself.
+	Path string `json:"path"`
+
+	// Markers are the package marker files that were found in the root. It
+	// is empty for the files at the top of an artifact which aren't in any
+	// package.
+	Markers []string `json:"markers,omitempty"`
+
+	// Files is the sorted list of UID's in this package.
+	Files []string `json:"files"`
+
+	// Verdicts is the verdict of each profile for just this package. The
+	// Artifact field of each of these is the Root of the package. The
+	// warnings which can't be attributed to any file aren't counted here.
+	Verdicts []*Verdict `json:"verdicts"`
+}
+
+// Packages splits each artifact into the packages inside of it, and returns
+// their verdicts. The artifacts without any package markers are left out, so
+// this returns nothing when scanning a single simple project.
+func Packages(output *Output) []*Package {
+	artifacts := []string{}
+	artifacts = append(artifacts, output.Args...)
+	if len(artifacts) == 0 {
+		artifacts = append(artifacts, "")
+	}
+
+	files, _ := ArtifactFiles(output, artifacts)
+
+	packages := []*Package{}
+	for _, a := range artifacts {
+		uids := files[a]
+		if len(uids) == 0 {
+			continue
+		}
+
+		roots := make(map[string][]string) // dir uid -> markers
+		for _, uid := range uids {
+			name := path.Base(uidPath(uid))
+			for _, x := range PackageMarkers {
+				if name != x {
+					continue
+				}
+				if dir, ok := ParentUID(uid); ok {
+					roots[dir] = append(roots[dir], name)
+				}
+			}
+		}
+		if len(roots) == 0 {
+			continue
+		}
+
+		top := commonParentUID(uids)
+		grouped := make(map[string][]string) // dir uid -> files
+		for _, uid := range uids {
+			owner := top
+			for p, ok := ParentUID(uid); ok; p, ok = ParentUID(p) {
+				if _, exists := roots[p]; exists {
+					owner = p
+					break
+				}
+			}
+			grouped[owner] = append(grouped[owner], uid)
+		}
+
+		group := []*Package{}
+		for dir, list := range grouped {
+			rel := strings.Trim(strings.TrimPrefix(uidPath(dir), uidPath(top)), "/")
+			if rel == "" {
+				rel = "."
+			}
+			sort.Strings(list)
+			group = append(group, &Package{
+				Artifact: a,
+				Root:     dir,
+				Path:     rel,
+				Markers:  roots[dir],
+				Files:    list,
+				Verdicts: packageVerdicts(output, dir, list),
+			})
+		}
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].Path < group[j].Path
+		})
+		packages = append(packages, group...)
+	}
+	return packages
+}
+
+// packageVerdicts returns the verdict of each profile for the files of one
+// package.
+func packageVerdicts(output *Output, root string, uids []string) []*Verdict {
+	errors := 0
+	for _, uid := range uids {
+		for _, result := range output.Results[uid] {
+			if result.Skip != nil {
+				errors++
+			}
+		}
+	}
+
+	verdicts := []*Verdict{}
+	for _, p := range output.Profiles {
+		profile := output.ProfilesData[p]
+		violations := []string{}
+		for _, uid := range uids {
+			if packageViolation(profile, output.Results[uid]) {
+				violations = append(violations, uid)
+			}
+		}
+		verdict := &Verdict{
+			Artifact:   root,
+			Profile:    p,
+			Verdict:    VerdictPass,
+			Violations: violations,
+			Errors:     errors,
+		}
+		if verdict.Errors > 0 {
+			verdict.Verdict = VerdictWarn
+		}
+		if len(violations) > 0 {
+			verdict.Verdict = VerdictFail
+		}
+		verdicts = append(verdicts, verdict)
+	}
+	return verdicts
+}
+
+// packageViolation returns true if any backend found a license in the file that
+// the profile matches.
+func packageViolation(profile *ProfileData, m map[interfaces.Backend]*interfaces.Result) bool {
+	for _, result := range m {
+		for _, license := range ElectedLicenses(profile, result) {
+			if ProfileMatch(profile, license) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReturnPackages returns the package by profile verdict matrix as a string. The
+// packages are named by their path, with the artifact in front if there is more
+// than one of them. Style can be `ansi`, `html`, or `text`.
+func ReturnPackages(packages []*Package, style string) (string, error) {
+	artifacts := make(map[string]struct{})
+	for _, x := range packages {
+		artifacts[x.Artifact] = struct{}{}
+	}
+	verdicts := []*Verdict{}
+	for _, x := range packages {
+		name := x.Path
+		if len(artifacts) > 1 {
+			name = x.Artifact + " " + x.Path
+		}
+		for _, v := range x.Verdicts {
+			c := *v // copy
+			c.Artifact = name
+			verdicts = append(verdicts, &c)
+		}
+	}
+	return returnVerdicts(verdicts, "package", style)
+}
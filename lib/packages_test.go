@@ -0,0 +1,88 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestPackages(t *testing.T) {
+	b := testBackend("spdx")
+	tag := func(s string) map[interfaces.Backend]*interfaces.Result {
+		return map[interfaces.Backend]*interfaces.Result{
+			b: {Licenses: []*licenses.License{{SPDX: s}}, Confidence: 1.0},
+		}
+	}
+	output := &lib.Output{
+		Results: interfaces.ResultSet{
+			"file:///m/LICENSE":                     tag("MIT"),
+			"file:///m/svc/api/main.go":             tag("GPL-3.0-only"),
+			"file:///m/web/index.js":                tag("MIT"),
+			"file:///m/web/node_modules/x/index.js": tag("MIT"),
+		},
+		Passes: []string{
+			"file:///m/",
+			"file:///m/README.md",
+			"file:///m/svc/api/go.mod",
+			"file:///m/web/package.json",
+			"file:///m/web/node_modules/x/package.json",
+		},
+		Profiles: []string{"strict"},
+		ProfilesData: map[string]*lib.ProfileData{
+			"strict": {Licenses: []*licenses.License{{SPDX: "GPL-3.0-only"}}},
+		},
+	}
+
+	packages := lib.Packages(output)
+	got := []string{}
+	for _, x := range packages {
+		got = append(got, x.Path+"="+x.Verdicts[0].Verdict)
+	}
+	exp := ".=pass svc/api=fail web=pass web/node_modules/x=pass"
+	if s := strings.Join(got, " "); s != exp {
+		t.Errorf("expected: %s, got: %s", exp, s)
+	}
+	if n := len(packages[2].Files); n != 2 {
+		t.Errorf("expected the nested package to be separate, got %d files in web", n)
+	}
+
+	s, err := lib.ReturnPackages(packages, "text")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if !strings.HasPrefix(s, "package") || !strings.Contains(s, "svc/api") {
+		t.Errorf("unexpected matrix:\n%s", s)
+	}
+
+	// a project without any markers has no packages
+	output.Passes = []string{"file:///m/", "file:///m/README.md"}
+	if packages := lib.Packages(output); len(packages) != 0 {
+		t.Errorf("expected no packages, got %d", len(packages))
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+)
+
+// porcelainReplacer escapes the characters which would break up a porcelain
+// line, so that each field can always be found by splitting on the tabs.
+var porcelainReplacer = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// ReturnPorcelain returns the porcelain lines for one result, with one line per
+// license that it found. Each line has the UID, the license, the confidence
+// from zero to one, and the name of the backend, separated by tabs. These
+// fields will stay in this order, and any new ones will be added at the end, so
+// that scripts can rely on them. A result without any licenses has no lines.
+func ReturnPorcelain(uid string, backend interfaces.Backend, result *interfaces.Result) string {
+	if result == nil {
+		return ""
+	}
+	name := ""
+	if backend != nil {
+		name = backend.String()
+	}
+	s := ""
+	for _, license := range result.Licenses {
+		s += fmt.Sprintf("%s\t%s\t%.4f\t%s\n", porcelainReplacer.Replace(uid), porcelainReplacer.Replace(license.String()), result.Confidence, porcelainReplacer.Replace(name))
+	}
+	return s
+}
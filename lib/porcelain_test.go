@@ -0,0 +1,95 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestReturnPorcelain(t *testing.T) {
+	result := &interfaces.Result{
+		Licenses:   []*licenses.License{{SPDX: "MIT"}, {SPDX: "Apache-2.0"}},
+		Confidence: 0.5,
+	}
+	s := lib.ReturnPorcelain("file:///tmp/odd\tname", &mitBackend{}, result)
+	exp := "file:///tmp/odd\\tname\tMIT\t0.5000\tmit\nfile:///tmp/odd\\tname\tApache-2.0\t0.5000\tmit\n"
+	if s != exp {
+		t.Errorf("expected: %q, got: %q", exp, s)
+	}
+	if s := lib.ReturnPorcelain("file:///tmp/x", &mitBackend{}, nil); s != "" {
+		t.Errorf("expected no lines, got: %q", s)
+	}
+}
+
+func TestOnResult(t *testing.T) {
+	logf := func(format string, v ...interface{}) {
+		t.Logf(format, v...)
+	}
+	mutex := &sync.Mutex{}
+	lines := []string{}
+	core := &lib.Core{
+		Logf:     logf,
+		Backends: []interfaces.Backend{&mitBackend{}},
+		Iterators: []interfaces.Iterator{
+			&iterator.IOFS{
+				Logf: logf,
+				FS: fstest.MapFS{
+					"LICENSE":     {Data: []byte("MIT License\n")},
+					"src/main.go": {Data: []byte("package main\n")},
+				},
+				Name: "test",
+			},
+		},
+		OnResult: func(uid string, backend interfaces.Backend, result *interfaces.Result) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			lines = append(lines, strings.TrimSpace(lib.ReturnPorcelain(uid, backend, result)))
+		},
+	}
+	if err := core.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	results, _, _, err := core.Run(context.Background())
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	sort.Strings(lines)
+	exp := []string{iterator.IOFSScheme + "test/LICENSE\tMIT\t1.0000\tmit"}
+	if len(results) != 1 || len(lines) != len(exp) || lines[0] != exp[0] {
+		t.Errorf("expected: %q, got: %q", exp, lines)
+	}
+}
@@ -25,6 +25,7 @@ package lib
 
 import (
 	"fmt"
+	"html"
 	"sort"
 	"strings"
 
@@ -54,6 +55,11 @@ type ProfileConfig struct {
 	// Exclude these licenses from match instead of including by default.
 	Exclude bool `json:"exclude"`
 
+	// Prefer is the ordered list of license SPDX ID's to choose from when a
+	// license expression offers a choice, such as "MIT OR GPL-2.0-only".
+	// The first one that is offered is chosen, and the others are dropped.
+	Prefer []string `json:"prefer"`
+
 	// Comment adds a user friendly comment for this file.
 	Comment string `json:"comment"`
 }
@@ -66,13 +72,126 @@ type ProfileData struct {
 
 	// Exclude these licenses from match instead of including by default.
 	Exclude bool
+
+	// Prefer is the ordered list of licenses to choose from a choice.
+	Prefer []*licenses.License
+}
+
+// SimpleProfilesOptions are the options for displaying the results with the
+// SimpleProfiles function. New options get added here, so that the signature of
+// the function doesn't change.
+type SimpleProfilesOptions struct {
+	// Passes are the files which were scanned but had no results. Only the
+	// count of them is shown.
+	Passes []string
+
+	// Warnings are the errors that aren't specific to any one backend.
+	Warnings map[string]error
+
+	// Profile is the profile to filter the results with. If it is nil, then
+	// every file that has any results is shown.
+	Profile *ProfileData
+
+	// Summary adds a summary of the licenses that were found at the end.
+	Summary bool
+
+	// BackendWeights is the weight of each backend. Every backend that has
+	// a result must have a weight.
+	BackendWeights map[interfaces.Backend]float64
+
+	// Obligations are shown next to each license that has one. If it is
+	// nil, then none are shown.
+	Obligations Obligations
+
+	// Origins marks the first-party files, and the summary is then of the
+	// third-party ones only, unless it says to include them. If it is nil,
+	// then nothing is marked.
+	Origins *Origins
+
+	// Blend is the method used to combine the confidence values. If it is
+	// empty, then DefaultBlend is used.
+	Blend string
+
+	// SmartURIs are the handlers that turn the UID's into links. If it is
+	// nil, then only the built-in ones are used.
+	SmartURIs util.SmartURIFuncs
+
+	// Style can be `ansi`, `html`, or `text`.
+	Style string
+}
+
+// SimpleProfilesOptions returns the options for displaying the results of this
+// output with the SimpleProfiles function, filtered by this profile.
+func (obj *Output) SimpleProfilesOptions(profile *ProfileData, summary bool, style string) *SimpleProfilesOptions {
+	return &SimpleProfilesOptions{
+		Passes:         obj.Passes,
+		Warnings:       obj.Warnings,
+		Profile:        profile,
+		Summary:        summary,
+		BackendWeights: obj.BackendWeights,
+		Obligations:    obj.Obligations,
+		Origins:        obj.Origins,
+		Blend:          obj.Blend,
+		SmartURIs:      obj.SmartURIs,
+		Style:          style,
+	}
 }
 
 // SimpleProfiles is a simple way to filter the results. This is the first
 // filter function created and is mostly used for an initial POC. It is the
-// more complicated successor to the SimpleResults function. Style can be
-// `ansi`, `html`, or `text`.
-func SimpleProfiles(results interfaces.ResultSet, passes []string, warnings map[string]error, profile *ProfileData, summary bool, backendWeights map[interfaces.Backend]float64, style string) (string, error) {
+// more complicated successor to the SimpleResults function.
+func SimpleProfiles(results interfaces.ResultSet, options *SimpleProfilesOptions) (string, error) {
+	return simpleProfiles(results, options, true)
+}
+
+// SimpleProfilesSummary is the same as SimpleProfiles, except that the results
+// of each file are left out. Only the skipped count, the errors, and the summary
+// are shown. This is useful when the files are displayed in some other way.
+func SimpleProfilesSummary(results interfaces.ResultSet, options *SimpleProfilesOptions) (string, error) {
+	return simpleProfiles(results, options, false)
+}
+
+// ProfileIncludes returns true if the results of a file should be shown in the
+// profile. A nil profile includes every file that has any results. Otherwise it
+// must have a license that the profile matches, or for an exclude profile, one
+// that it doesn't account for.
+func ProfileIncludes(profile *ProfileData, m map[interfaces.Backend]*interfaces.Result) bool {
+	for _, result := range m {
+		if profile == nil {
+			return true
+		}
+		// TODO: memoize this for performance
+		ls := ElectedLicenses(profile, result)
+		count := len(licenses.Union(profile.Licenses, ls))
+		// are there licenses that match in our profile?
+		if count > 0 && !profile.Exclude {
+			return true
+		}
+
+		// are there licenses we didn't account for?
+		if len(ls) > count && profile.Exclude {
+			return true
+		}
+	}
+	return false
+}
+
+// simpleProfiles is the implementation of SimpleProfiles. If rows is false, then
+// the results of each file are left out.
+func simpleProfiles(results interfaces.ResultSet, options *SimpleProfilesOptions, rows bool) (string, error) {
+	if options == nil {
+		options = &SimpleProfilesOptions{}
+	}
+	passes := options.Passes
+	warnings := options.Warnings
+	profile := options.Profile
+	summary := options.Summary
+	backendWeights := options.BackendWeights
+	obligations := options.Obligations
+	origins := options.Origins
+	blend := options.Blend
+	smartURIs := options.SmartURIs
+	style := options.Style
 	if style != "ansi" && style != "html" && style != "text" {
 		return "", fmt.Errorf("invalid style: %s", style)
 	}
@@ -110,16 +229,17 @@ func SimpleProfiles(results interfaces.ResultSet, passes []string, warnings map[
 	}) // for recording found skip errors
 	// XXX: handle dir's in here specially and merge in their weights with child paths!
 Loop:
-	for uri, m := range results { // FIXME: sort and process properly
+	for _, uri := range SortedUIDs(results) {
+		m := results[uri]
 		bs := []*AnnotatedBackend{}
-		ttl := 0.0      // total weight for the set of backends at this uri
-		skipUri := true // assume we skip
+		ttl := 0.0 // total weight for the set of backends at this uri
 		innerLicenseMap := make(map[string]int64)
 		plus := func(name string) {
 			val, _ := innerLicenseMap[name] // defaults to zero!
 			innerLicenseMap[name] = val + 1
 		}
-		for backend, result := range m {
+		for _, backend := range SortedResultBackends(m) {
+			result := m[backend]
 			if result.Skip != nil {
 				errorMap[uri] = struct {
 					backend string
@@ -130,26 +250,10 @@ Loop:
 				}
 			}
 			// accounting for licenses summary
-			for _, x := range result.Licenses {
+			for _, x := range ElectedLicenses(profile, result) {
 				plus(x.String())
 			}
 
-			if profile == nil {
-				skipUri = false
-			} else {
-				// TODO: memoize this for performance
-				count := len(licenses.Union(profile.Licenses, result.Licenses))
-				// are there licenses that match in our profile?
-				if count > 0 && !profile.Exclude {
-					skipUri = false
-				}
-
-				// are there licenses we didn't account for?
-				if len(result.Licenses) > count && profile.Exclude {
-					skipUri = false
-				}
-			}
-
 			weight, exists := backendWeights[backend]
 			if !exists {
 				return "", fmt.Errorf("no weight found for backend: %s", backend.String())
@@ -161,24 +265,21 @@ Loop:
 			bs = append(bs, b)
 			ttl += weight
 		}
-		if skipUri { // we don't want to display this Uri (this file)
+		if !ProfileIncludes(profile, m) { // we don't want to display this Uri (this file)
 			continue Loop
 		}
-		f := 0.0 // NOTE: confidence *if* the different results agree!
-		//for backend, result := range m {
-		for _, b := range bs { // for backend, result := range m
-			backend := b.Backend
-			weight := b.Weight // backendWeights[backend]
-			result := m[backend]
-			scale := weight / ttl
-			b.ScaledConfidence = result.Confidence * scale
-			f = f + b.ScaledConfidence
-		}
+		f := BlendConfidence(blend, bs, m)
 
-		// merge into to parent accounting
-		for k, v := range innerLicenseMap { // map[string]int64
-			val, _ := licenseMap[k] // defaults to zero!
-			licenseMap[k] = val + v
+		// merge into to parent accounting, unless it's our own code
+		if origins.Summarized(uri) {
+			for k, v := range innerLicenseMap { // map[string]int64
+				val, _ := licenseMap[k] // defaults to zero!
+				licenseMap[k] = val + v
+			}
+		}
+		originStr := ""
+		if origin := origins.Origin(uri); origin == OriginFirstParty {
+			originStr = fmt.Sprintf(" [%s]", origin)
 		}
 
 		// start table row here after the above continue...
@@ -186,19 +287,19 @@ Loop:
 			str += "<tr><td>"
 		}
 
-		sort.Sort(sort.Reverse(SortedBackends(bs)))
-		smartURI := util.SmartURI(uri) // make it useful to click on
+		sort.Stable(sort.Reverse(SortedBackends(bs)))
+		smartURI := smartURIs.SmartURI(uri) // make it useful to click on
 		if style == "ansi" {
 			hyperlink := util.ShellHyperlinkEncode(uri, smartURI)
-			str += fmt.Sprintf("%s (%.2f%%)\n", hyperlink, f*100.0)
+			str += fmt.Sprintf("%s (%.2f%%)%s\n", hyperlink, f*100.0, originStr)
 		}
 		if style == "html" {
 			hyperlink := util.HtmlHyperlinkEncode(uri, smartURI)
-			str += fmt.Sprintf("%s (%.2f%%)", hyperlink, f*100.0)
+			str += fmt.Sprintf("%s (%.2f%%)%s", hyperlink, f*100.0, originStr)
 		}
 		if style == "text" {
 			// TODO: can we do better for text output?
-			str += fmt.Sprintf("%s (%.2f%%)\n", uri, f*100.0)
+			str += fmt.Sprintf("%s (%.2f%%)%s\n", uri, f*100.0, originStr)
 		}
 		hasResults = true
 
@@ -210,15 +311,14 @@ Loop:
 			weight := b.Weight // backendWeights[backend]
 			result := m[backend]
 
-			l := licenses.Join(result.Licenses)
+			ls := SortedLicenses(ElectedLicenses(profile, result))
+			l := licenses.Join(ls)
 			if UseColour && profile != nil {
 				ll := []string{}
 				// only colour the matched ones!
-				for _, x := range result.Licenses {
+				for _, x := range ls {
 					r := x.String()
-					inList := licenses.InList(x, profile.Licenses)
-					if inList && !profile.Exclude || !inList && profile.Exclude {
-						r = x.String()
+					if ProfileMatch(profile, x) {
 						r = redString(r)
 					}
 
@@ -227,6 +327,10 @@ Loop:
 				l = strings.Join(ll, ", ")
 			}
 
+			l += inheritedString(result)
+			l += symlinkString(result)
+			l += electedString(profile, result)
+
 			s := ""
 			if style == "ansi" {
 				s = fmt.Sprintf("    %s (%.2f/%.2f)  %s (%.2f%%)\n", backend.String(), weight, ttl, l, result.Confidence*100.0)
@@ -258,6 +362,16 @@ Loop:
 				hasResults = true
 			}
 		}
+		// show what each license of this file asks of us, right next to it
+		for _, x := range obligationNames(innerLicenseMap, obligations) {
+			o := obligations.Lookup(x).String()
+			if style == "ansi" || style == "text" {
+				str += fmt.Sprintf("    obligations of %s: %s\n", x, o)
+			}
+			if style == "html" {
+				str += fmt.Sprintf("<li>obligations of %s: %s</li>", x, html.EscapeString(o))
+			}
+		}
 		if style == "html" {
 			str += "</ul>"
 			str += "</td></tr>"
@@ -275,7 +389,7 @@ Loop:
 		skippedStr = s
 	}
 	if style == "text" {
-		skippedStr = fmt.Sprintf("skipped: %d files/directories\n", countStr)
+		skippedStr = fmt.Sprintf("skipped: %s files/directories\n", countStr)
 	}
 
 	erroredStr := ""
@@ -343,23 +457,35 @@ Loop:
 
 	summaryStr := ""
 	if summary {
+		title := "summary:"
+		if origins != nil && !origins.FirstParty {
+			title = fmt.Sprintf("summary (%s):", OriginThirdParty)
+		}
 		names := []string{}
 		for k := range licenseMap { // map[string]int64
 			names = append(names, k)
 		}
 		sort.Strings(names)
 		if style == "ansi" || style == "text" {
-			s := boldString("summary:") + "\n"
+			s := boldString(title) + "\n"
 			for _, x := range names {
-				s += fmt.Sprintf("%s: %d\n", x, licenseMap[x])
+				o := ""
+				if obligation := obligations.Lookup(x); obligation != nil {
+					o = fmt.Sprintf(" (%s)", obligation)
+				}
+				s += fmt.Sprintf("%s: %d%s\n", x, licenseMap[x], o)
 			}
 			summaryStr = s
 		}
 		if style == "html" {
 			s := `<tr><td><table id="summary">`
-			s += fmt.Sprintf(`<tr><th colspan="2">%s</th></tr>`, boldString("summary:"))
+			s += fmt.Sprintf(`<tr><th colspan="3">%s</th></tr>`, boldString(title))
 			for _, x := range names {
-				s += fmt.Sprintf("<tr><td>%s</td><td>%d</td></tr>", x, licenseMap[x])
+				o := ""
+				if obligation := obligations.Lookup(x); obligation != nil {
+					o = html.EscapeString(obligation.String())
+				}
+				s += fmt.Sprintf("<tr><td>%s</td><td>%d</td><td>%s</td></tr>", x, licenseMap[x], o)
 			}
 
 			s += "</table></td></tr>"
@@ -370,8 +496,58 @@ Loop:
 	if !hasResults {
 		summaryStr = ""
 	}
+	if !rows {
+		str = ""
+	}
 	// glue it all together
 	str = skippedStr + warningStr + erroredStr + summaryStr + noResultsStr + str
 
 	return str, nil
 }
+
+// electedString returns the choices of license that the profile made for this
+// result, to be shown next to the licenses, or the empty string if none were.
+func electedString(profile *ProfileData, result *interfaces.Result) string {
+	ss := []string{}
+	for _, x := range resultElections(profile, result) {
+		ss = append(ss, x.String())
+	}
+	if len(ss) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" [chose %s]", strings.Join(ss, "; "))
+}
+
+// obligationNames returns the sorted names of the licenses which have a known
+// obligation.
+func obligationNames(licenseMap map[string]int64, obligations Obligations) []string {
+	names := []string{}
+	for x := range licenseMap {
+		if obligations.Lookup(x) != nil {
+			names = append(names, x)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inheritedString returns a short annotation for results that were not directly
+// determined by the backend for that path. It is empty for regular results.
+func inheritedString(result *interfaces.Result) string {
+	if result.Meta == nil || result.Meta.Inherited == "" {
+		return ""
+	}
+	if result.Meta.Inferred {
+		return fmt.Sprintf(" [inferred from %s]", result.Meta.Inherited)
+	}
+	return fmt.Sprintf(" [inherited from %s]", result.Meta.Inherited)
+}
+
+// symlinkString returns a short annotation for results of a path which was a
+// symlink, naming the file that was actually scanned. It is empty otherwise.
+func symlinkString(result *interfaces.Result) string {
+	if result.Meta == nil || result.Meta.Symlink == "" {
+		return ""
+	}
+	return fmt.Sprintf(" [symlink to %s]", result.Meta.Symlink)
+}
@@ -0,0 +1,189 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestSimpleProfilesDeterministic(t *testing.T) {
+	mit := &licenses.License{SPDX: "MIT"}
+	apache := &licenses.License{SPDX: "Apache-2.0"}
+	b1, b2, b3 := testBackend("b1"), testBackend("b2"), testBackend("b3")
+	weights := map[interfaces.Backend]float64{b1: 1.0, b2: 1.0, b3: 2.0}
+
+	results := make(interfaces.ResultSet)
+	for i := 0; i < 20; i++ {
+		results[fmt.Sprintf("file:///tmp/%02d", i)] = map[interfaces.Backend]*interfaces.Result{
+			b1: {Licenses: []*licenses.License{mit, apache}, Confidence: 0.5},
+			b2: {Licenses: []*licenses.License{apache, mit}, Confidence: 0.5},
+			b3: {Licenses: []*licenses.License{mit}, Confidence: 0.9},
+		}
+	}
+
+	var exp string
+	for i := 0; i < 10; i++ {
+		s, err := lib.SimpleProfiles(results, &lib.SimpleProfilesOptions{
+			Summary:        true,
+			BackendWeights: weights,
+			Style:          "text",
+		})
+		if err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+		if i == 0 {
+			exp = s
+			continue
+		}
+		if s != exp {
+			t.Errorf("output differs between runs")
+			t.Logf("exp:\n%s", exp)
+			t.Logf("got:\n%s", s)
+			return
+		}
+	}
+}
+
+func TestProfileIncludes(t *testing.T) {
+	mit := &licenses.License{SPDX: "MIT"}
+	gpl := &licenses.License{SPDX: "GPL-2.0-only"}
+	b1 := testBackend("b1")
+	m := map[interfaces.Backend]*interfaces.Result{
+		b1: {Licenses: []*licenses.License{mit}, Confidence: 1.0},
+	}
+	include := &lib.ProfileData{Licenses: []*licenses.License{gpl}}
+	exclude := &lib.ProfileData{Licenses: []*licenses.License{mit}, Exclude: true}
+	tests := []struct {
+		profile *lib.ProfileData
+		m       map[interfaces.Backend]*interfaces.Result
+		exp     bool
+	}{
+		{nil, m, true},
+		{nil, map[interfaces.Backend]*interfaces.Result{}, false},
+		{include, m, false},
+		{&lib.ProfileData{Licenses: []*licenses.License{mit}}, m, true},
+		{exclude, m, false},
+		{&lib.ProfileData{Licenses: []*licenses.License{gpl}, Exclude: true}, m, true},
+	}
+	for i, tt := range tests {
+		if got := lib.ProfileIncludes(tt.profile, tt.m); got != tt.exp {
+			t.Errorf("test %d: expected %t, got %t", i, tt.exp, got)
+		}
+	}
+}
+
+func TestSimpleProfilesSummary(t *testing.T) {
+	b1 := testBackend("b1")
+	results := interfaces.ResultSet{
+		"file:///tmp/a": {
+			b1: {Licenses: []*licenses.License{{SPDX: "MIT"}}, Confidence: 1.0},
+		},
+	}
+	weights := map[interfaces.Backend]float64{b1: 1.0}
+	s, err := lib.SimpleProfilesSummary(results, &lib.SimpleProfilesOptions{
+		Summary:        true,
+		BackendWeights: weights,
+		Style:          "text",
+	})
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if strings.Contains(s, "file:///tmp/a") {
+		t.Errorf("expected no files in the summary:\n%s", s)
+	}
+	if !strings.Contains(s, "MIT: 1") {
+		t.Errorf("expected the license summary:\n%s", s)
+	}
+}
+
+func TestSimpleProfilesObligations(t *testing.T) {
+	b1 := testBackend("b1")
+	results := interfaces.ResultSet{
+		"file:///tmp/a": {
+			b1: {Licenses: []*licenses.License{{SPDX: "MIT"}}, Confidence: 1.0},
+		},
+		"file:///tmp/b": {
+			b1: {Licenses: []*licenses.License{{SPDX: "Apache-2.0"}}, Confidence: 1.0},
+		},
+		"file:///tmp/c": {
+			b1: {Licenses: []*licenses.License{{Custom: "my license"}}, Confidence: 1.0},
+		},
+	}
+	obligations, err := lib.DefaultObligations()
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	mit := "obligations of MIT: " + obligations.Lookup("MIT").String()
+	apache := "obligations of Apache-2.0: " + obligations.Lookup("Apache-2.0").String()
+
+	s, err := lib.SimpleProfiles(results, &lib.SimpleProfilesOptions{
+		Summary:        true,
+		BackendWeights: map[interfaces.Backend]float64{b1: 1.0},
+		Obligations:    obligations,
+		Style:          "text",
+	})
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+
+	// split the output into the summary, and the section of each file
+	summary := strings.Index(s, "summary:")
+	a := strings.Index(s, "file:///tmp/a")
+	b := strings.Index(s, "file:///tmp/b")
+	c := strings.Index(s, "file:///tmp/c")
+	if summary < 0 || a < summary || b < a || c < b {
+		t.Fatalf("unexpected output:\n%s", s)
+	}
+	if x := s[a:b]; !strings.Contains(x, mit) || strings.Contains(x, apache) {
+		t.Errorf("expected only the obligations of MIT for the first file:\n%s", x)
+	}
+	if x := s[b:c]; !strings.Contains(x, apache) || strings.Contains(x, mit) {
+		t.Errorf("expected only the obligations of Apache-2.0 for the second file:\n%s", x)
+	}
+	if x := s[c:]; strings.Contains(x, "obligations of") {
+		t.Errorf("expected no obligations for the custom license:\n%s", x)
+	}
+	if x := s[summary:a]; !strings.Contains(x, "MIT: 1 ("+obligations.Lookup("MIT").String()+")") {
+		t.Errorf("expected the obligations in the summary too:\n%s", x)
+	}
+
+	// without a knowledge base, nothing is shown
+	s, err = lib.SimpleProfiles(results, &lib.SimpleProfilesOptions{
+		BackendWeights: map[interfaces.Backend]float64{b1: 1.0},
+		Style:          "text",
+	})
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if strings.Contains(s, "obligations of") {
+		t.Errorf("expected no obligations:\n%s", s)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/awslabs/yesiscan/iterator"
+)
+
+// Progress tracks how far along a running scan is, so that it can be shown to
+// someone who is waiting for it. It is safe for concurrent use. A nil *Progress
+// is valid and discards everything, so that callers don't need to check if
+// progress is enabled.
+type Progress struct {
+	// Limiter is shared with the iterators of the scan, and it counts the
+	// bytes that they download. If it is nil, then that isn't shown.
+	Limiter *iterator.Limiter
+
+	// MaxFiles is the most files that the scan will read, if it is known.
+	// It is used as the total for a progress bar.
+	MaxFiles int64
+
+	mu       sync.Mutex
+	files    int64
+	bytes    int64
+	iterator string
+	backends map[string]int
+}
+
+// ProgressStatus is a snapshot of the progress of a scan.
+type ProgressStatus struct {
+	// Files is the number of files that have been read.
+	Files int64 `json:"files"`
+
+	// Bytes is the amount of file data that has been read.
+	Bytes int64 `json:"bytes"`
+
+	// Downloaded is the amount of data that has been downloaded.
+	Downloaded int64 `json:"downloaded"`
+
+	// MaxFiles is the most files that the scan will read, or zero if there
+	// is no limit.
+	MaxFiles int64 `json:"max_files,omitempty"`
+
+	// Iterator is the iterator that is currently running.
+	Iterator string `json:"iterator,omitempty"`
+
+	// Backends is the sorted list of backends that are currently running.
+	Backends []string `json:"backends"`
+}
+
+// AddFile records that one more file of size bytes was read.
+func (obj *Progress) AddFile(size int64) {
+	if obj == nil {
+		return
+	}
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	obj.files++
+	obj.bytes += size
+}
+
+// SetIterator records which iterator is currently running.
+func (obj *Progress) SetIterator(name string) {
+	if obj == nil {
+		return
+	}
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	obj.iterator = name
+}
+
+// Begin records that the named backend started to scan a file. It must be
+// followed by a matching call to End.
+func (obj *Progress) Begin(backend string) {
+	if obj == nil {
+		return
+	}
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	if obj.backends == nil {
+		obj.backends = make(map[string]int)
+	}
+	obj.backends[backend]++
+}
+
+// End records that the named backend finished scanning a file.
+func (obj *Progress) End(backend string) {
+	if obj == nil {
+		return
+	}
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	if obj.backends[backend]--; obj.backends[backend] <= 0 {
+		delete(obj.backends, backend)
+	}
+}
+
+// Status returns a snapshot of the current progress.
+func (obj *Progress) Status() *ProgressStatus {
+	if obj == nil {
+		return &ProgressStatus{Backends: []string{}}
+	}
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	backends := []string{}
+	for x := range obj.backends {
+		backends = append(backends, x)
+	}
+	sort.Strings(backends)
+	return &ProgressStatus{
+		Files:      obj.files,
+		Bytes:      obj.bytes,
+		Downloaded: obj.Limiter.Downloaded(),
+		MaxFiles:   obj.MaxFiles,
+		Iterator:   obj.iterator,
+		Backends:   backends,
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+func TestProgress(t *testing.T) {
+	var nothing *lib.Progress // nil discards everything
+	nothing.AddFile(42)
+	nothing.Begin("x")
+	if s := nothing.Status(); s.Files != 0 || len(s.Backends) != 0 {
+		t.Errorf("unexpected status: %+v", s)
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("hello\n"), 0600); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+	}
+	absDir, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	logf := func(format string, v ...interface{}) {
+		t.Logf(format, v...)
+	}
+	progress := &lib.Progress{
+		MaxFiles: 10,
+	}
+	core := &lib.Core{
+		Logf:     logf,
+		Backends: []interfaces.Backend{&countingBackend{version: "1"}},
+		Iterators: []interfaces.Iterator{
+			&iterator.Fs{
+				Logf:   logf,
+				Prefix: absDir,
+				Path:   absDir,
+			},
+		},
+		Progress: progress,
+	}
+	if err := core.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if _, _, _, err := core.Run(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	s := progress.Status()
+	if s.Files != 2 || s.Bytes != 12 || s.MaxFiles != 10 {
+		t.Errorf("unexpected status: %+v", s)
+	}
+	if s.Iterator == "" {
+		t.Errorf("expected the iterator to be set")
+	}
+	if len(s.Backends) != 0 {
+		t.Errorf("expected no running backends, got: %+v", s.Backends)
+	}
+}
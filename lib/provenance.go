@@ -0,0 +1,144 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+)
+
+// Provenance is one iterator in the provenance tree of a scan. The roots are
+// the iterators of the input arguments, and the children of each one are the
+// iterators that it found along the way, such as a zip file in a directory, or
+// a tarball inside of that zip. Together they show how each file was reached,
+// so that an auditor can trace a finding back through the nested artifacts.
+type Provenance struct {
+	// Iterator is the description of the iterator.
+	Iterator string `json:"iterator"`
+
+	// Artifact is the input argument that a root iterator came from. It is
+	// empty for the children.
+	Artifact string `json:"artifact,omitempty"`
+
+	// Files is the sorted list of UID's of the files this iterator scanned.
+	Files []string `json:"files,omitempty"`
+
+	// Children are the iterators that this iterator returned.
+	Children []*Provenance `json:"children,omitempty"`
+}
+
+// Count returns the number of files that were scanned by this iterator and by
+// all of its children.
+func (obj *Provenance) Count() int {
+	n := len(obj.Files)
+	for _, x := range obj.Children {
+		n += x.Count()
+	}
+	return n
+}
+
+// NewProvenance builds the provenance tree from the list of iterators, in the
+// order that they ran, and the UID's of the files that each of them scanned.
+// Iterators whose parent isn't in the list are treated as roots.
+func NewProvenance(iterators []interfaces.Iterator, files map[interfaces.Iterator][]string) []*Provenance {
+	nodes := make(map[interfaces.Iterator]*Provenance)
+	for _, x := range iterators {
+		f := append([]string{}, files[x]...) // copy
+		sort.Strings(f)
+		nodes[x] = &Provenance{
+			Iterator: x.String(),
+			Files:    f,
+		}
+	}
+
+	roots := []*Provenance{}
+	for _, x := range iterators {
+		node := nodes[x]
+		if parent, exists := nodes[x.GetIterator()]; exists && x.GetIterator() != nil {
+			parent.Children = append(parent.Children, node)
+			continue
+		}
+		node.Artifact = iteratorArtifact(x)
+		roots = append(roots, node)
+	}
+	return roots
+}
+
+// ProvenanceDepth returns the number of levels in the provenance tree. If it's
+// one, then nothing was nested, and there's nothing interesting to show.
+func ProvenanceDepth(roots []*Provenance) int {
+	depth := 0
+	for _, x := range roots {
+		if d := 1 + ProvenanceDepth(x.Children); d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+// ReturnProvenance returns the provenance tree with the number of files that
+// were scanned by each iterator. The list of files is only in the json output.
+func ReturnProvenance(roots []*Provenance, style string) (string, error) {
+	if style != "ansi" && style != "html" && style != "text" {
+		return "", fmt.Errorf("invalid style: %s", style)
+	}
+	return returnProvenance(roots, style, 0), nil
+}
+
+// returnProvenance is the recursive implementation of ReturnProvenance.
+func returnProvenance(roots []*Provenance, style string, depth int) string {
+	if len(roots) == 0 {
+		return ""
+	}
+	s := ""
+	if style == "html" {
+		s += "<ul>\n"
+	}
+	for _, x := range roots {
+		name := x.Iterator
+		if x.Artifact != "" && x.Artifact != x.Iterator {
+			name = x.Artifact + ": " + name
+		}
+		count := fmt.Sprintf("%d of %d files", len(x.Files), x.Count())
+		if len(x.Children) == 0 {
+			count = fmt.Sprintf("%d files", len(x.Files))
+		}
+		if style == "html" {
+			s += fmt.Sprintf("<li>%s (%s)\n", html.EscapeString(name), count)
+			s += returnProvenance(x.Children, style, depth+1)
+			s += "</li>\n"
+			continue
+		}
+		s += fmt.Sprintf("%s%s (%s)\n", strings.Repeat("    ", depth), name, count)
+		s += returnProvenance(x.Children, style, depth+1)
+	}
+	if style == "html" {
+		s += "</ul>\n"
+	}
+	return s
+}
@@ -0,0 +1,72 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+func TestProvenance(t *testing.T) {
+	root := &iterator.Fs{Path: safepath.UnsafeParseIntoAbsDir("/src/")}
+	outer := &iterator.Fs{Path: safepath.UnsafeParseIntoAbsDir("/tmp/outer/"), Iterator: root}
+	inner := &iterator.Fs{Path: safepath.UnsafeParseIntoAbsDir("/tmp/inner/"), Iterator: outer}
+	other := &iterator.Fs{Path: safepath.UnsafeParseIntoAbsDir("/other/")}
+
+	iterators := []interfaces.Iterator{root, other, outer, inner}
+	files := map[interfaces.Iterator][]string{
+		root:  {"file:///src/b", "file:///src/a.zip"},
+		outer: {"file:///tmp/outer/c.tar"},
+		inner: {"file:///tmp/inner/d", "file:///tmp/inner/e"},
+	}
+	roots := lib.NewProvenance(iterators, files)
+	if len(roots) != 2 || roots[0].Iterator != "fs: /src/" || roots[1].Iterator != "fs: /other/" {
+		t.Fatalf("unexpected roots: %+v", roots)
+	}
+	if f := roots[0].Files; len(f) != 2 || f[0] != "file:///src/a.zip" {
+		t.Errorf("expected sorted files, got: %v", f)
+	}
+	if n := roots[0].Count(); n != 5 {
+		t.Errorf("expected 5 files, got: %d", n)
+	}
+	if d := lib.ProvenanceDepth(roots); d != 3 {
+		t.Errorf("expected a depth of 3, got: %d", d)
+	}
+
+	s, err := lib.ReturnProvenance(roots, "text")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	exp := "fs: /src/ (2 of 5 files)\n" +
+		"    fs: /tmp/outer/ (1 of 3 files)\n" +
+		"        fs: /tmp/inner/ (2 files)\n" +
+		"fs: /other/ (0 files)\n"
+	if s != exp {
+		t.Errorf("expected:\n%s\ngot:\n%s", exp, s)
+	}
+}
@@ -0,0 +1,471 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+)
+
+// QueryFields are the fields of a QueryRow that a query can look at, and the
+// kind of value that each one holds.
+var QueryFields = map[string]string{
+	"uid":        "string",
+	"artifact":   "string",
+	"backend":    "string",
+	"license":    "string", // matches if any of the licenses match
+	"confidence": "number",
+	"skip":       "string", // the error, or empty if there wasn't one
+	"inferred":   "bool",
+}
+
+// QueryRow is a single result that a query can match. There is one for each
+// backend of each uid in a stored output.
+type QueryRow struct {
+	UID        string   `json:"uid"`
+	Artifact   string   `json:"artifact,omitempty"`
+	Backend    string   `json:"backend"`
+	Licenses   []string `json:"licenses"`
+	Confidence float64  `json:"confidence"`
+	Skip       string   `json:"skip,omitempty"`
+	Inferred   bool     `json:"inferred,omitempty"`
+
+	// Source is where the stored output came from, if there is more than
+	// one.
+	Source string `json:"source,omitempty"`
+}
+
+// QueryRows returns the rows of a stored output, sorted by uid and then by
+// backend.
+func QueryRows(source string, output *JSONOutput) []*QueryRow {
+	uids := []string{}
+	for uid := range output.Results {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+
+	rows := []*QueryRow{}
+	for _, uid := range uids {
+		m := output.Results[uid]
+		backends := []string{}
+		for backend := range m {
+			backends = append(backends, backend)
+		}
+		sort.Strings(backends)
+		for _, backend := range backends {
+			result := m[backend]
+			row := &QueryRow{
+				UID:        uid,
+				Artifact:   output.Artifacts[uid],
+				Backend:    backend,
+				Licenses:   []string{},
+				Confidence: result.Confidence,
+				Skip:       result.Skip,
+				Inferred:   result.Inferred,
+				Source:     source,
+			}
+			if row.Artifact == "" && len(output.Args) == 1 {
+				row.Artifact = output.Args[0]
+			}
+			for _, license := range result.Licenses {
+				row.Licenses = append(row.Licenses, license.String())
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// Query is a parsed query over the results. The language is a list of field
+// comparisons such as `license = "MIT"` or `confidence > 0.8`, which may be
+// combined with AND, OR, NOT, and parentheses. The operators are =, !=, <, <=,
+// >, >=, and ~ which matches a regular expression. Strings are double quoted,
+// and the booleans are true and false. The keywords are case insensitive, and
+// licenses are compared case insensitively, like SPDX ID's are.
+type Query struct {
+	root queryNode
+}
+
+// ParseQuery parses a query string.
+func ParseQuery(s string) (*Query, error) {
+	tokens, err := lexQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t != nil {
+		return nil, fmt.Errorf("unexpected %s at position %d", t.text, t.pos)
+	}
+	return &Query{root: root}, nil
+}
+
+// Match returns true if this row matches the query.
+func (obj *Query) Match(row *QueryRow) bool {
+	return obj.root.match(row)
+}
+
+// Filter returns the rows which match the query, in the same order.
+func (obj *Query) Filter(rows []*QueryRow) []*QueryRow {
+	matches := []*QueryRow{}
+	for _, row := range rows {
+		if obj.Match(row) {
+			matches = append(matches, row)
+		}
+	}
+	return matches
+}
+
+// ReturnQueryRows returns the rows as an aligned text table.
+func ReturnQueryRows(rows []*QueryRow) (string, error) {
+	b := &strings.Builder{}
+	w := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "uid\tbackend\tlicenses\tconfidence\tskip\n")
+	for _, x := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.2f\t%s\n", x.UID, x.Backend, strings.Join(x.Licenses, ", "), x.Confidence, x.Skip)
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// queryNode is a node in the parsed query tree.
+type queryNode interface {
+	match(row *QueryRow) bool
+}
+
+type queryAnd struct{ a, b queryNode }
+
+func (obj *queryAnd) match(row *QueryRow) bool { return obj.a.match(row) && obj.b.match(row) }
+
+type queryOr struct{ a, b queryNode }
+
+func (obj *queryOr) match(row *QueryRow) bool { return obj.a.match(row) || obj.b.match(row) }
+
+type queryNot struct{ a queryNode }
+
+func (obj *queryNot) match(row *QueryRow) bool { return !obj.a.match(row) }
+
+// queryCompare compares one field with a value.
+type queryCompare struct {
+	field  string
+	op     string
+	str    string
+	num    float64
+	regexp *regexp.Regexp
+}
+
+func (obj *queryCompare) match(row *QueryRow) bool {
+	switch obj.field {
+	case "uid":
+		return obj.compareString(row.UID, false)
+	case "artifact":
+		return obj.compareString(row.Artifact, false)
+	case "backend":
+		return obj.compareString(row.Backend, false)
+	case "skip":
+		return obj.compareString(row.Skip, false)
+	case "license":
+		// != means that none of them are equal, so that it is the
+		// opposite of =, even when there is more than one license
+		found := false
+		for _, x := range row.Licenses {
+			if obj.op == "!=" {
+				if strings.EqualFold(x, obj.str) {
+					return false
+				}
+				continue
+			}
+			if obj.compareString(x, true) {
+				found = true
+				break
+			}
+		}
+		return found || obj.op == "!="
+	case "confidence":
+		return obj.compareNumber(row.Confidence)
+	case "inferred":
+		return obj.compareString(strconv.FormatBool(row.Inferred), false)
+	}
+	return false // can't happen, the parser checks this
+}
+
+func (obj *queryCompare) compareString(s string, fold bool) bool {
+	switch obj.op {
+	case "~":
+		return obj.regexp.MatchString(s)
+	case "=":
+		return s == obj.str || fold && strings.EqualFold(s, obj.str)
+	case "!=":
+		return !(s == obj.str || fold && strings.EqualFold(s, obj.str))
+	}
+	return false // can't happen, the parser checks this
+}
+
+func (obj *queryCompare) compareNumber(f float64) bool {
+	switch obj.op {
+	case "=":
+		return f == obj.num
+	case "!=":
+		return f != obj.num
+	case "<":
+		return f < obj.num
+	case "<=":
+		return f <= obj.num
+	case ">":
+		return f > obj.num
+	case ">=":
+		return f >= obj.num
+	}
+	return false // can't happen, the parser checks this
+}
+
+// queryToken is a lexed token. The kind is one of "ident", "string", "number",
+// "op", "(", or ")".
+type queryToken struct {
+	kind string
+	text string
+	pos  int
+}
+
+// lexQuery splits the query string into tokens.
+func lexQuery(s string) ([]*queryToken, error) {
+	tokens := []*queryToken{}
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '(' || c == ')':
+			tokens = append(tokens, &queryToken{kind: string(c), text: string(c), pos: i})
+			i++
+
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++ // skip the escaped char
+				}
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			text, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d", i)
+			}
+			tokens = append(tokens, &queryToken{kind: "string", text: text, pos: i})
+			i = j + 1
+
+		case strings.ContainsRune("=!<>~", c):
+			op := string(c)
+			if i+1 < len(s) && s[i+1] == '=' && c != '=' && c != '~' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("invalid operator at position %d", i)
+			}
+			tokens = append(tokens, &queryToken{kind: "op", text: op, pos: i})
+			i += len(op)
+
+		case c == '-' || c == '.' || unicode.IsDigit(c):
+			j := i + 1
+			for ; j < len(s) && (s[j] == '.' || unicode.IsDigit(rune(s[j]))); j++ {
+			}
+			tokens = append(tokens, &queryToken{kind: "number", text: s[i:j], pos: i})
+			i = j
+
+		case unicode.IsLetter(c) || c == '_':
+			j := i + 1
+			for ; j < len(s) && (unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j])) || s[j] == '_' || s[j] == '-'); j++ {
+			}
+			tokens = append(tokens, &queryToken{kind: "ident", text: s[i:j], pos: i})
+			i = j
+
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", c, i)
+		}
+	}
+	return tokens, nil
+}
+
+// queryParser is a recursive descent parser for the query tokens.
+type queryParser struct {
+	tokens []*queryToken
+	i      int
+}
+
+func (obj *queryParser) peek() *queryToken {
+	if obj.i >= len(obj.tokens) {
+		return nil
+	}
+	return obj.tokens[obj.i]
+}
+
+// keyword consumes the next token if it is this keyword.
+func (obj *queryParser) keyword(k string) bool {
+	if t := obj.peek(); t != nil && t.kind == "ident" && strings.EqualFold(t.text, k) {
+		obj.i++
+		return true
+	}
+	return false
+}
+
+func (obj *queryParser) or() (queryNode, error) {
+	a, err := obj.and()
+	if err != nil {
+		return nil, err
+	}
+	for obj.keyword("OR") {
+		b, err := obj.and()
+		if err != nil {
+			return nil, err
+		}
+		a = &queryOr{a, b}
+	}
+	return a, nil
+}
+
+func (obj *queryParser) and() (queryNode, error) {
+	a, err := obj.not()
+	if err != nil {
+		return nil, err
+	}
+	for obj.keyword("AND") {
+		b, err := obj.not()
+		if err != nil {
+			return nil, err
+		}
+		a = &queryAnd{a, b}
+	}
+	return a, nil
+}
+
+func (obj *queryParser) not() (queryNode, error) {
+	if obj.keyword("NOT") {
+		a, err := obj.not()
+		if err != nil {
+			return nil, err
+		}
+		return &queryNot{a}, nil
+	}
+	return obj.primary()
+}
+
+func (obj *queryParser) primary() (queryNode, error) {
+	t := obj.peek()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+	if t.kind == "(" {
+		obj.i++
+		a, err := obj.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := obj.peek(); t == nil || t.kind != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		obj.i++
+		return a, nil
+	}
+	return obj.compare()
+}
+
+func (obj *queryParser) compare() (queryNode, error) {
+	field := obj.peek()
+	if field.kind != "ident" {
+		return nil, fmt.Errorf("expected a field at position %d", field.pos)
+	}
+	name := strings.ToLower(field.text)
+	kind, exists := QueryFields[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown field %s at position %d", field.text, field.pos)
+	}
+	obj.i++
+
+	op := obj.peek()
+	if op == nil || op.kind != "op" {
+		return nil, fmt.Errorf("expected an operator after %s", field.text)
+	}
+	obj.i++
+
+	value := obj.peek()
+	if value == nil {
+		return nil, fmt.Errorf("expected a value after %s", op.text)
+	}
+	obj.i++
+
+	node := &queryCompare{field: name, op: op.text}
+	switch kind {
+	case "number":
+		if value.kind != "number" {
+			return nil, fmt.Errorf("expected a number at position %d", value.pos)
+		}
+		f, err := strconv.ParseFloat(value.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number at position %d", value.pos)
+		}
+		if op.text == "~" {
+			return nil, fmt.Errorf("can't use ~ on %s", name)
+		}
+		node.num = f
+
+	case "bool":
+		if value.kind != "ident" || (value.text != "true" && value.text != "false") {
+			return nil, fmt.Errorf("expected true or false at position %d", value.pos)
+		}
+		if op.text != "=" && op.text != "!=" {
+			return nil, fmt.Errorf("can only use = or != on %s", name)
+		}
+		node.str = value.text
+
+	default: // string
+		if value.kind != "string" {
+			return nil, fmt.Errorf("expected a quoted string at position %d", value.pos)
+		}
+		if op.text != "=" && op.text != "!=" && op.text != "~" {
+			return nil, fmt.Errorf("can only use =, !=, or ~ on %s", name)
+		}
+		node.str = value.text
+		if op.text == "~" {
+			r, err := regexp.Compile(value.text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression at position %d: %v", value.pos, err)
+			}
+			node.regexp = r
+		}
+	}
+	return node, nil
+}
@@ -0,0 +1,93 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/awslabs/yesiscan/lib"
+)
+
+func TestQuery(t *testing.T) {
+	rows := []*lib.QueryRow{
+		{UID: "file:///a.go", Backend: "spdx", Licenses: []string{"GPL-3.0-only"}, Confidence: 1.0},
+		{UID: "file:///a.go", Backend: "askalono", Licenses: []string{"GPL-3.0-only"}, Confidence: 0.7},
+		{UID: "file:///b.go", Backend: "spdx", Licenses: []string{"MIT", "Apache-2.0"}, Confidence: 0.9},
+		{UID: "file:///c.go", Backend: "scancode", Licenses: []string{}, Skip: "timed out"},
+		{UID: "file:///d.go", Backend: "spdx", Licenses: []string{"MIT"}, Confidence: 0.5, Inferred: true},
+	}
+	tests := []struct {
+		query string
+		exp   []int // indexes of the matching rows
+	}{
+		{`license = "GPL-3.0-only" AND confidence > 0.8`, []int{0}},
+		{`license = "gpl-3.0-only"`, []int{0, 1}},
+		{`license != "MIT"`, []int{0, 1, 3}},
+		{`license ~ "^Apache"`, []int{2}},
+		{`NOT (backend = "spdx" OR backend = "askalono")`, []int{3}},
+		{`skip != "" or inferred = true`, []int{3, 4}},
+		{`uid ~ "b\\.go$" and confidence >= 0.9`, []int{2}},
+		{`confidence < 0.6 AND NOT skip ~ "timed"`, []int{4}},
+	}
+	for i, x := range tests {
+		query, err := lib.ParseQuery(x.query)
+		if err != nil {
+			t.Errorf("test %d: error: %+v", i, err)
+			continue
+		}
+		matches := []int{}
+		for j, row := range rows {
+			if query.Match(row) {
+				matches = append(matches, j)
+			}
+		}
+		if len(matches) != len(x.exp) {
+			t.Errorf("test %d: %s: expected %v, got: %v", i, x.query, x.exp, matches)
+			continue
+		}
+		for j := range matches {
+			if matches[j] != x.exp[j] {
+				t.Errorf("test %d: %s: expected %v, got: %v", i, x.query, x.exp, matches)
+				break
+			}
+		}
+	}
+
+	for _, x := range []string{
+		``,
+		`license`,
+		`license = MIT`,
+		`colour = "red"`,
+		`confidence ~ "1"`,
+		`license < "MIT"`,
+		`(license = "MIT"`,
+		`license = "MIT" extra`,
+		`license = "unterminated`,
+		`inferred = "true"`,
+	} {
+		if _, err := lib.ParseQuery(x); err == nil {
+			t.Errorf("expected an error for: %s", x)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"sync"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+const (
+	// ErrQuotaExceeded is returned when a scan goes past one of its limits.
+	// The scan stops, but everything scanned so far is still returned.
+	ErrQuotaExceeded = interfaces.Error("scan quota exceeded")
+)
+
+// Quota counts the files and the bytes that get scanned and stops the scan once
+// it goes past either limit. It's shared by all of the scanners of one scan. A
+// nil *Quota is valid and has no limits.
+type Quota struct {
+	// MaxFiles is the most files to scan. If it is zero, then there is no
+	// limit.
+	MaxFiles int64
+
+	// MaxBytes is the most bytes of file data to scan. If it is zero, then
+	// there is no limit.
+	MaxBytes int64
+
+	mu       sync.Mutex
+	files    int64
+	bytes    int64
+	exceeded error
+}
+
+// Add counts one more file of size bytes. It returns an error which wraps
+// ErrQuotaExceeded if this file doesn't fit, in which case it must not be
+// scanned. Once that happens, every later call errors too.
+func (obj *Quota) Add(size int64) error {
+	if obj == nil {
+		return nil
+	}
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	if obj.exceeded != nil {
+		return obj.exceeded
+	}
+	if obj.MaxFiles > 0 && obj.files+1 > obj.MaxFiles {
+		obj.exceeded = errwrap.Wrapf(ErrQuotaExceeded, "more than %d files", obj.MaxFiles)
+		return obj.exceeded
+	}
+	if obj.MaxBytes > 0 && obj.bytes+size > obj.MaxBytes {
+		obj.exceeded = errwrap.Wrapf(ErrQuotaExceeded, "more than %d bytes", obj.MaxBytes)
+		return obj.exceeded
+	}
+	obj.files++
+	obj.bytes += size
+	return nil
+}
+
+// Exceeded returns the error from when the quota was first exceeded, or nil if
+// it hasn't been.
+func (obj *Quota) Exceeded() error {
+	if obj == nil {
+		return nil
+	}
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	return obj.exceeded
+}
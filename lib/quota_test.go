@@ -0,0 +1,125 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+func TestQuota(t *testing.T) {
+	var nilQuota *lib.Quota
+	if err := nilQuota.Add(1 << 40); err != nil {
+		t.Errorf("a nil quota should have no limits: %+v", err)
+	}
+
+	quota := &lib.Quota{MaxFiles: 2, MaxBytes: 100}
+	if err := quota.Add(40); err != nil {
+		t.Errorf("error: %+v", err)
+	}
+	if err := quota.Add(60); err != nil {
+		t.Errorf("error: %+v", err)
+	}
+	if err := quota.Exceeded(); err != nil {
+		t.Errorf("unexpected exceeded quota: %+v", err)
+	}
+	err := quota.Add(0)
+	if !errors.Is(err, lib.ErrQuotaExceeded) {
+		t.Errorf("expected the quota to be exceeded, got: %+v", err)
+	}
+	if !errors.Is(quota.Exceeded(), lib.ErrQuotaExceeded) {
+		t.Errorf("expected the quota to stay exceeded")
+	}
+
+	quota = &lib.Quota{MaxBytes: 10}
+	if err := quota.Add(11); !errors.Is(err, lib.ErrQuotaExceeded) {
+		t.Errorf("expected the byte quota to be exceeded, got: %+v", err)
+	}
+}
+
+func TestCoreMaxFiles(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("file%d", i))
+		if err := os.WriteFile(name, []byte("MIT License\n"), 0600); err != nil {
+			t.Errorf("error: %+v", err)
+			return
+		}
+	}
+	absDir, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	logf := func(format string, v ...interface{}) {
+		t.Logf(format, v...)
+	}
+	backend := &countingBackend{version: "1"}
+	core := &lib.Core{
+		Logf:     logf,
+		Backends: []interfaces.Backend{backend},
+		Iterators: []interfaces.Iterator{
+			&iterator.Fs{
+				Logf:   logf,
+				Prefix: absDir,
+				Path:   absDir,
+			},
+		},
+		MaxFiles: 2,
+	}
+	if err := core.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	results, _, _, err := core.Run(context.Background())
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if !errors.Is(core.Exceeded(), lib.ErrQuotaExceeded) {
+		t.Errorf("expected the scan to stop early, got: %+v", core.Exceeded())
+	}
+	if backend.count != 2 {
+		t.Errorf("expected two scanned files, got: %d", backend.count)
+	}
+	found := 0
+	for i := 0; i < 5; i++ {
+		uid := iterator.FileScheme + absDir.String() + fmt.Sprintf("file%d", i)
+		if _, exists := results[uid]; exists {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("expected two partial results, got: %d", found)
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/iterator"
+)
+
+var (
+	// DefaultPublicHosts is the list of well known public hosts which are
+	// left alone when redacting, since they don't reveal anything about
+	// where the scan was run.
+	DefaultPublicHosts = []string{
+		"bitbucket.org",
+		"codeberg.org",
+		"crates.io",
+		"cran.r-project.org",
+		"files.pythonhosted.org",
+		"gist.github.com",
+		"github.com",
+		"gitlab.com",
+		"go.googlesource.com",
+		"golang.org",
+		"proxy.golang.org",
+		"pypi.org",
+		"raw.githubusercontent.com",
+		"registry.npmjs.org",
+		"repo1.maven.org",
+		"rubygems.org",
+		"sourceforge.net",
+		"static.crates.io",
+	}
+
+	// redactScpRegexp matches the scp style of git uri, such as
+	// git@example.com:org/repo.git which has no scheme.
+	redactScpRegexp = regexp.MustCompile(`^[\w.-]+@([\w.-]+):`)
+
+	// redactUserRegexp matches the user name in the path of a home dir.
+	redactUserRegexp = regexp.MustCompile(`^/(home|Users)/([^/]+)/`)
+)
+
+// Redactor replaces the absolute local paths, the user names, and the internal
+// host names in a report with stable placeholders, so that the report can be
+// shared outside of the organization without leaking details about the
+// infrastructure. The same original string always gets the same placeholder,
+// so the structure of the report is kept. Add everything that should be hidden
+// first, and then run Redact on each of the rendered reports.
+type Redactor struct {
+	// PublicHosts is the list of host names which are left alone. If it is
+	// nil, then DefaultPublicHosts is used.
+	PublicHosts []string
+
+	placeholders map[string]string // kind and original -> placeholder
+	replacements map[string]string // original -> placeholder
+	counts       map[string]int    // kind -> number of placeholders
+}
+
+// placeholder returns the placeholder for this original string, making a new
+// one of this kind if it hasn't been seen before.
+func (obj *Redactor) placeholder(kind, original, format string) string {
+	if obj.placeholders == nil {
+		obj.placeholders = make(map[string]string)
+		obj.replacements = make(map[string]string)
+		obj.counts = make(map[string]int)
+	}
+	key := kind + "\x00" + original
+	if p, exists := obj.placeholders[key]; exists {
+		return p
+	}
+	obj.counts[kind]++
+	p := fmt.Sprintf(format, obj.counts[kind])
+	obj.placeholders[key] = p
+	return p
+}
+
+// AddPath adds an absolute local directory to be hidden. Everything under it
+// keeps the relative path, but the directory itself is replaced. The longest
+// matching directory wins, so nested directories can each be added.
+func (obj *Redactor) AddPath(dir string) {
+	if !strings.HasPrefix(dir, "/") || dir == "/" {
+		return
+	}
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	obj.replacements[dir] = obj.placeholder("path", dir, "/redacted-path-%d/")
+}
+
+// AddUser adds a local user name to be hidden when it appears as the name of a
+// home directory.
+func (obj *Redactor) AddUser(user string) {
+	if user == "" || strings.Contains(user, "/") {
+		return
+	}
+	p := obj.placeholder("user", user, "redacted-user-%d")
+	obj.replacements["/home/"+user+"/"] = "/home/" + p + "/"
+	obj.replacements["/Users/"+user+"/"] = "/Users/" + p + "/"
+}
+
+// AddHost adds a host name to be hidden, unless it is one of the public hosts.
+func (obj *Redactor) AddHost(host string) {
+	host = strings.ToLower(host)
+	if host == "" || host == "localhost" {
+		return
+	}
+	public := obj.PublicHosts
+	if public == nil {
+		public = DefaultPublicHosts
+	}
+	for _, x := range public {
+		if host == strings.ToLower(x) {
+			return
+		}
+	}
+	obj.replacements[host] = obj.placeholder("host", host, "redacted-host-%d")
+}
+
+// AddURI adds the identifying parts of a scan argument or of a result UID. A
+// local path adds the directory that it is in, a URL adds the host and any
+// credentials, and a path in a home directory adds the user name.
+func (obj *Redactor) AddURI(uri string) {
+	if strings.HasPrefix(uri, iterator.FileScheme) {
+		uri = strings.TrimPrefix(uri, iterator.FileScheme)
+	}
+	if strings.HasPrefix(uri, "/") {
+		if m := redactUserRegexp.FindStringSubmatch(uri); m != nil {
+			obj.AddUser(m[2])
+		}
+		return
+	}
+	if m := redactScpRegexp.FindStringSubmatch(uri); m != nil {
+		obj.AddHost(m[1])
+		return
+	}
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" {
+		return
+	}
+	obj.AddHost(u.Hostname())
+	if u.User != nil {
+		// this might contain a password, so hide all of it
+		x := u.User.String() + "@"
+		obj.replacements[x] = obj.placeholder("user", x, "redacted-user-%d@")
+	}
+}
+
+// AddOutput adds everything identifying from the output of a scan. The local
+// arguments are added as the directories to hide, and every UID is added too.
+func (obj *Redactor) AddOutput(output *Output) {
+	for _, arg := range output.Args {
+		if !strings.HasPrefix(arg, "/") {
+			obj.AddURI(arg)
+			continue
+		}
+		dir := arg
+		if !strings.HasSuffix(dir, "/") {
+			dir = path.Dir(dir) // it's a file, so hide the dir it is in
+		}
+		obj.AddPath(dir)
+		obj.AddURI(dir)
+	}
+	for uid := range output.Results {
+		obj.AddURI(uid)
+	}
+	for _, uid := range output.Passes {
+		obj.AddURI(uid)
+	}
+	for uid := range output.Warnings {
+		obj.AddURI(uid)
+	}
+}
+
+// Redact returns the string with everything that was added replaced by the
+// placeholders. It works on any of the rendered output types.
+func (obj *Redactor) Redact(s string) string {
+	originals := []string{}
+	for k := range obj.replacements {
+		originals = append(originals, k)
+	}
+	// the longest match wins, so nested paths get the deepest placeholder
+	sort.Slice(originals, func(i, j int) bool {
+		if len(originals[i]) != len(originals[j]) {
+			return len(originals[i]) > len(originals[j])
+		}
+		return originals[i] < originals[j]
+	})
+	oldnew := []string{}
+	for _, k := range originals {
+		oldnew = append(oldnew, k, obj.replacements[k])
+	}
+	return strings.NewReplacer(oldnew...).Replace(s)
+}
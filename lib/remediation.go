@@ -0,0 +1,283 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+)
+
+// Remediation is a suggested fix for a file which has no license determination
+// of its own, but which lives in a directory that clearly declares a single
+// license. Adding the header makes the license of the file explicit.
+type Remediation struct {
+	// UID is the unique identifier of the file that is missing a header.
+	UID string `json:"uid"`
+
+	// License is the SPDX ID of the declared license.
+	License string `json:"license"`
+
+	// From is the UID of the LICENSE or COPYING file that declared it.
+	From string `json:"from"`
+
+	// Header is the exact line of text to add near the top of the file. If
+	// the file starts with a shebang line, it should go right after it.
+	Header string `json:"header"`
+}
+
+// commentStyle is the pair of strings used to wrap a single line comment.
+type commentStyle struct {
+	start string
+	end   string
+}
+
+// remediationCommentStyles maps lower case file extensions (or file names for
+// files that usually have none) to the comment syntax used to write a header.
+// Files of a type which isn't listed here don't get a suggestion, since we'd
+// rather say nothing than suggest a header that breaks the file.
+var remediationCommentStyles = map[string]commentStyle{
+	".c":      {"// ", ""},
+	".h":      {"// ", ""},
+	".cc":     {"// ", ""},
+	".cpp":    {"// ", ""},
+	".cxx":    {"// ", ""},
+	".hpp":    {"// ", ""},
+	".cs":     {"// ", ""},
+	".dart":   {"// ", ""},
+	".go":     {"// ", ""},
+	".groovy": {"// ", ""},
+	".java":   {"// ", ""},
+	".js":     {"// ", ""},
+	".jsx":    {"// ", ""},
+	".kt":     {"// ", ""},
+	".kts":    {"// ", ""},
+	".php":    {"// ", ""},
+	".proto":  {"// ", ""},
+	".rs":     {"// ", ""},
+	".scala":  {"// ", ""},
+	".scss":   {"// ", ""},
+	".swift":  {"// ", ""},
+	".ts":     {"// ", ""},
+	".tsx":    {"// ", ""},
+
+	".bash":  {"# ", ""},
+	".cmake": {"# ", ""},
+	".mk":    {"# ", ""},
+	".pl":    {"# ", ""},
+	".pm":    {"# ", ""},
+	".ps1":   {"# ", ""},
+	".py":    {"# ", ""},
+	".r":     {"# ", ""},
+	".rb":    {"# ", ""},
+	".sh":    {"# ", ""},
+	".tf":    {"# ", ""},
+	".toml":  {"# ", ""},
+	".yaml":  {"# ", ""},
+	".yml":   {"# ", ""},
+	".zsh":   {"# ", ""},
+
+	"dockerfile":     {"# ", ""},
+	"makefile":       {"# ", ""},
+	"cmakelists.txt": {"# ", ""},
+
+	".hs":  {"-- ", ""},
+	".lua": {"-- ", ""},
+	".sql": {"-- ", ""},
+
+	".clj":  {";; ", ""},
+	".el":   {";; ", ""},
+	".lisp": {";; ", ""},
+	".scm":  {";; ", ""},
+
+	".erl": {"%% ", ""},
+	".tex": {"% ", ""},
+
+	".css": {"/* ", " */"},
+
+	".htm":  {"<!-- ", " -->"},
+	".html": {"<!-- ", " -->"},
+	".md":   {"<!-- ", " -->"},
+	".vue":  {"<!-- ", " -->"},
+	".xml":  {"<!-- ", " -->"},
+}
+
+// RemediationHeader returns the SPDX header line for a file with this UID and
+// this license ID. If we don't know how to comment in this type of file, then
+// this returns false.
+func RemediationHeader(uid, spdx string) (string, bool) {
+	base := path.Base(uidPath(uid))
+	style, exists := remediationCommentStyles[strings.ToLower(base)]
+	if !exists {
+		style, exists = remediationCommentStyles[strings.ToLower(path.Ext(base))]
+	}
+	if !exists {
+		return "", false
+	}
+	return style.start + "SPDX-License-Identifier: " + spdx + style.end, true
+}
+
+// Remediations returns the list of files which should get an SPDX header. These
+// are the files without a license determination of their own (inferred results
+// don't count) whose nearest enclosing LICENSE or COPYING files all agree on a
+// single SPDX license. If that nearest directory is ambiguous, then we don't
+// look any further upwards, because we don't want to guess. The returned list
+// is sorted by UID.
+func Remediations(results interfaces.ResultSet, passes []string) []*Remediation {
+	uids := []string{}
+	for uid := range results {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+
+	// collect the declared licenses for each directory
+	dirs := make(map[string]map[string]struct{}) // dir uid -> spdx ids
+	dirsFrom := make(map[string]string)          // dir uid -> license file uid
+	for _, uid := range uids {
+		if !IsLicenseFile(uid) {
+			continue
+		}
+		parent, ok := ParentUID(uid)
+		if !ok {
+			continue
+		}
+		if _, exists := dirs[parent]; !exists {
+			dirs[parent] = make(map[string]struct{})
+			dirsFrom[parent] = uid // keep the first one we found
+		}
+		for _, result := range results[uid] {
+			if result == nil || (result.Meta != nil && result.Meta.Inferred) {
+				continue
+			}
+			for _, x := range result.Licenses {
+				// a custom license is never a clear declaration
+				key := x.String()
+				if x.SPDX == "" {
+					key = "" // sentinel which is never a valid id
+				}
+				dirs[parent][key] = struct{}{}
+			}
+		}
+	}
+
+	candidates := []string{}
+	candidates = append(candidates, passes...)
+	for _, uid := range uids {
+		if !hasOwnLicenses(results[uid]) {
+			candidates = append(candidates, uid)
+		}
+	}
+
+	seen := make(map[string]struct{})
+	remediations := []*Remediation{}
+	for _, uid := range candidates {
+		if strings.HasSuffix(uid, "/") || IsLicenseFile(uid) {
+			continue // skip directories and the license files themselves
+		}
+		if _, exists := seen[uid]; exists {
+			continue
+		}
+		seen[uid] = struct{}{}
+
+		dir, ok := ParentUID(uid)
+		for ok {
+			if _, exists := dirs[dir]; exists {
+				break
+			}
+			dir, ok = ParentUID(dir)
+		}
+		if !ok || len(dirs[dir]) != 1 {
+			continue // no enclosing license, or it's not clear
+		}
+		spdx := ""
+		for x := range dirs[dir] {
+			spdx = x
+		}
+		if spdx == "" {
+			continue
+		}
+		header, ok := RemediationHeader(uid, spdx)
+		if !ok {
+			continue
+		}
+		remediations = append(remediations, &Remediation{
+			UID:     uid,
+			License: spdx,
+			From:    dirsFrom[dir],
+			Header:  header,
+		})
+	}
+	sort.Slice(remediations, func(i, j int) bool {
+		return remediations[i].UID < remediations[j].UID
+	})
+	return remediations
+}
+
+// hasOwnLicenses returns true if at least one of the results contains a license
+// that was not inferred from somewhere else.
+func hasOwnLicenses(m map[interfaces.Backend]*interfaces.Result) bool {
+	for _, result := range m {
+		if result == nil || len(result.Licenses) == 0 {
+			continue
+		}
+		if result.Meta != nil && result.Meta.Inferred {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// ReturnRemediationCSV returns the list of suggested headers formatted as CSV.
+func ReturnRemediationCSV(output *Output) (string, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	if err := w.Write([]string{"uid", "license", "from", "header"}); err != nil {
+		return "", err
+	}
+	for _, x := range Remediations(output.Results, output.Passes) {
+		if err := w.Write([]string{x.UID, x.License, x.From, x.Header}); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ReturnRemediationJSON returns the list of suggested headers formatted as JSON.
+func ReturnRemediationJSON(output *Output) (string, error) {
+	b, err := json.MarshalIndent(Remediations(output.Results, output.Passes), "", "\t")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
@@ -0,0 +1,81 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestRemediations(t *testing.T) {
+	b1, b2 := testBackend("b1"), testBackend("b2")
+	mit := []*licenses.License{{SPDX: "MIT"}}
+	apache := []*licenses.License{{SPDX: "Apache-2.0"}}
+	results := interfaces.ResultSet{
+		"file:///a/LICENSE": {
+			b1: {Licenses: mit, Confidence: 1.0},
+			b2: {Licenses: mit, Confidence: 0.9},
+		},
+		"file:///a/main.go": {
+			b1: {Licenses: mit, Confidence: 0.5, Meta: &interfaces.Meta{Inferred: true}},
+		},
+		"file:///a/own.go": {
+			b1: {Licenses: apache, Confidence: 1.0},
+		},
+		"file:///a/b/LICENSE": {
+			b1: {Licenses: mit, Confidence: 1.0},
+			b2: {Licenses: apache, Confidence: 1.0},
+		},
+	}
+	passes := []string{
+		"file:///a/",
+		"file:///a/run.py",
+		"file:///a/style.css",
+		"file:///a/image.png",      // unknown comment syntax
+		"file:///a/b/ambiguous.go", // nearest license is ambiguous
+		"file:///x/nolicense.go",   // no enclosing license
+	}
+
+	got := lib.Remediations(results, passes)
+	exp := []*lib.Remediation{
+		{"file:///a/main.go", "MIT", "file:///a/LICENSE", "// SPDX-License-Identifier: MIT"},
+		{"file:///a/run.py", "MIT", "file:///a/LICENSE", "# SPDX-License-Identifier: MIT"},
+		{"file:///a/style.css", "MIT", "file:///a/LICENSE", "/* SPDX-License-Identifier: MIT */"},
+	}
+	if len(got) != len(exp) {
+		for _, x := range got {
+			t.Logf("got: %+v", x)
+		}
+		t.Fatalf("expected %d remediations, got %d", len(exp), len(got))
+	}
+	for i := range exp {
+		if *got[i] != *exp[i] {
+			t.Errorf("exp: %+v", exp[i])
+			t.Errorf("got: %+v", got[i])
+		}
+	}
+}
@@ -47,11 +47,52 @@ type SortedBackends []*AnnotatedBackend
 func (obj SortedBackends) Len() int      { return len(obj) }
 func (obj SortedBackends) Swap(i, j int) { obj[i], obj[j] = obj[j], obj[i] }
 func (obj SortedBackends) Less(i, j int) bool {
+	if obj[i].ScaledConfidence == obj[j].ScaledConfidence {
+		// Ties are broken by name so that the output is stable. It's
+		// reversed so that it reads alphabetically in a reverse sort.
+		return obj[i].Backend.String() > obj[j].Backend.String()
+	}
 	return obj[i].ScaledConfidence < obj[j].ScaledConfidence
 }
 
 //func (obj SortedBackends) Sort() { sort.Sort(obj) }
 
+// SortedUIDs returns the UID's in the result set in sorted order. Use this
+// instead of ranging over the map so that the reports are always identical for
+// identical inputs.
+func SortedUIDs(results interfaces.ResultSet) []string {
+	uids := []string{}
+	for uid := range results {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	return uids
+}
+
+// SortedResultBackends returns the backends that have a result at some path,
+// sorted by name. Use this instead of ranging over the map so that everything
+// that is computed from them happens in the same order every time.
+func SortedResultBackends(m map[interfaces.Backend]*interfaces.Result) []interfaces.Backend {
+	backends := []interfaces.Backend{}
+	for backend := range m {
+		backends = append(backends, backend)
+	}
+	sort.SliceStable(backends, func(i, j int) bool {
+		return backends[i].String() < backends[j].String()
+	})
+	return backends
+}
+
+// SortedLicenses returns a sorted copy of the list of licenses. The backends
+// don't always return them in a consistent order.
+func SortedLicenses(ls []*licenses.License) []*licenses.License {
+	sorted := append([]*licenses.License{}, ls...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].String() < sorted[j].String()
+	})
+	return sorted
+}
+
 // SimpleResults is a simple way to format the results. This is the first
 // display function created and is mostly used for debugging and initial POC.
 func SimpleResults(results interfaces.ResultSet, backendWeights map[interfaces.Backend]float64) (string, error) {
@@ -61,10 +102,11 @@ func SimpleResults(results interfaces.ResultSet, backendWeights map[interfaces.B
 
 	str := ""
 	// XXX: handle dir's in here specially and merge in their weights with child paths!
-	for uri, m := range results { // FIXME: sort and process properly
+	for _, uri := range SortedUIDs(results) {
+		m := results[uri]
 		bs := []*AnnotatedBackend{}
 		ttl := 0.0 // total weight for the set of backends at this uri
-		for backend := range m {
+		for _, backend := range SortedResultBackends(m) {
 			weight, exists := backendWeights[backend]
 			if !exists {
 				return "", fmt.Errorf("no weight found for backend: %s", backend.String())
@@ -76,18 +118,9 @@ func SimpleResults(results interfaces.ResultSet, backendWeights map[interfaces.B
 			bs = append(bs, b)
 			ttl += weight
 		}
-		f := 0.0 // NOTE: confidence *if* the different results agree!
-		//for backend, result := range m {
-		for _, b := range bs { // for backend, result := range m
-			backend := b.Backend
-			weight := b.Weight // backendWeights[backend]
-			result := m[backend]
-			scale := weight / ttl
-			b.ScaledConfidence = result.Confidence * scale
-			f = f + b.ScaledConfidence
-		}
+		f := BlendConfidence(DefaultBlend, bs, m)
 
-		sort.Sort(sort.Reverse(SortedBackends(bs)))
+		sort.Stable(sort.Reverse(SortedBackends(bs)))
 		display := uri // show the URI
 		smartURI := util.SmartURI(uri)
 		hyperlink := util.ShellHyperlinkEncode(display, smartURI)
@@ -96,7 +129,7 @@ func SimpleResults(results interfaces.ResultSet, backendWeights map[interfaces.B
 			backend := b.Backend
 			weight := b.Weight // backendWeights[backend]
 			result := m[backend]
-			l := licenses.Join(result.Licenses)
+			l := licenses.Join(SortedLicenses(result.Licenses))
 			str += fmt.Sprintf("    %s (%.2f/%.2f)  %s (%.2f%%)\n", backend.String(), weight, ttl, l, result.Confidence*100.0)
 			if !debug {
 				continue
@@ -0,0 +1,355 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"fmt"
+	"html"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+const (
+	// ReuseProfileName is the name of the column that the REUSE compliance
+	// verdict is shown under in the verdict matrix.
+	ReuseProfileName = "reuse"
+
+	// ReuseLicensesDir is the name of the directory at the root of the
+	// project which must contain the text of every license that is used.
+	ReuseLicensesDir = "LICENSES"
+
+	// ReuseSidecarSuffix is the suffix of the file which holds the
+	// licensing info for a file that can't contain a comment header.
+	ReuseSidecarSuffix = ".license"
+
+	// ReuseLicenseRefPrefix is the prefix of a custom license identifier.
+	ReuseLicenseRefPrefix = "LicenseRef-"
+
+	// reuseBackendName is the backend whose results are used, since the
+	// REUSE specification only counts explicit SPDX tags.
+	reuseBackendName = "spdx"
+)
+
+// ReuseReport is the result of evaluating a single artifact against the REUSE
+// specification. See: https://reuse.software/spec/
+type ReuseReport struct {
+	// Artifact is the input argument that this report is about.
+	Artifact string `json:"artifact"`
+
+	// Root is the UID of the directory that we consider to be the root of
+	// the project. This is where the LICENSES/ directory should be.
+	Root string `json:"root"`
+
+	// Verdict is one of pass, warn, or fail.
+	Verdict string `json:"verdict"`
+
+	// Unlicensed is the sorted list of files without any licensing info.
+	Unlicensed []string `json:"unlicensed"`
+
+	// Missing is the sorted list of license ID's which are used, but whose
+	// text is not in the LICENSES/ directory.
+	Missing []string `json:"missing"`
+
+	// Unused is the sorted list of files in the LICENSES/ directory that
+	// are for a license which is never used.
+	Unused []string `json:"unused"`
+
+	// Bad is the sorted list of license ID's which are neither known SPDX
+	// ID's nor custom LicenseRef- ones.
+	Bad []string `json:"bad"`
+
+	// Errors is the number of scanning errors seen for this artifact.
+	Errors int `json:"errors"`
+}
+
+// Compliant returns true if nothing was found that breaks the specification.
+func (obj *ReuseReport) Compliant() bool {
+	return len(obj.Unlicensed) == 0 && len(obj.Missing) == 0 && len(obj.Unused) == 0 && len(obj.Bad) == 0
+}
+
+// ToVerdict returns the report as an entry for the verdict matrix. Any missing
+// license texts are listed as the paths where they were expected.
+func (obj *ReuseReport) ToVerdict() *Verdict {
+	violations := []string{}
+	violations = append(violations, obj.Unlicensed...)
+	for _, id := range obj.Missing {
+		violations = append(violations, joinUID(obj.Root, ReuseLicensesDir+"/"+id+".txt"))
+	}
+	violations = append(violations, obj.Unused...)
+	sort.Strings(violations)
+	return &Verdict{
+		Artifact:   obj.Artifact,
+		Profile:    ReuseProfileName,
+		Verdict:    obj.Verdict,
+		Violations: violations,
+		Errors:     obj.Errors,
+	}
+}
+
+// Reuse evaluates each artifact against the REUSE specification. A file has
+// licensing info if the spdx backend found an SPDX-License-Identifier tag in it
+// or in its .license sidecar file. License and copying files, the files in the
+// LICENSES/ directory, and the sidecar files themselves don't need any. Every
+// license which is used must have its text in LICENSES/<id>.<ext> and every
+// license text in there must be used. The .reuse/dep5 and REUSE.toml files are
+// not interpreted, and copyright notices are not checked. The reports are in
+// the order of the input arguments.
+func Reuse(output *Output) []*ReuseReport {
+	artifacts := []string{}
+	artifacts = append(artifacts, output.Args...)
+	if len(artifacts) == 0 {
+		artifacts = append(artifacts, "")
+	}
+
+	files, errors := ArtifactFiles(output, artifacts)
+
+	reports := []*ReuseReport{}
+	for _, a := range artifacts {
+		report := reuseReport(output.Results, files[a])
+		report.Artifact = a
+		report.Errors = errors[a] + len(output.Warnings)
+		report.Verdict = VerdictPass
+		if report.Errors > 0 {
+			report.Verdict = VerdictWarn
+		}
+		if !report.Compliant() {
+			report.Verdict = VerdictFail
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// reuseReport evaluates the sorted list of files from a single artifact.
+func reuseReport(results interfaces.ResultSet, uids []string) *ReuseReport {
+	report := &ReuseReport{
+		Unlicensed: []string{},
+		Missing:    []string{},
+		Unused:     []string{},
+		Bad:        []string{},
+	}
+	if len(uids) == 0 {
+		return report
+	}
+
+	exists := make(map[string]struct{})
+	for _, uid := range uids {
+		exists[uid] = struct{}{}
+	}
+
+	// the root is the shallowest directory with a LICENSES/ directory in
+	// it, or if there isn't one, the closest common parent of every file
+	for _, uid := range uids {
+		dir, ok := ParentUID(uid)
+		if !ok || path.Base(uidPath(dir)) != ReuseLicensesDir {
+			continue
+		}
+		root, ok := ParentUID(dir)
+		if !ok {
+			continue
+		}
+		if report.Root == "" || len(root) < len(report.Root) {
+			report.Root = root
+		}
+	}
+	if report.Root == "" {
+		report.Root = commonParentUID(uids)
+	}
+	licensesDir := joinUID(report.Root, ReuseLicensesDir+"/")
+
+	texts := make(map[string][]string) // license id -> uids
+	used := make(map[string]struct{})
+	for _, uid := range uids {
+		if parent, ok := ParentUID(uid); ok && parent == licensesDir {
+			base := path.Base(uidPath(uid))
+			id := strings.TrimSuffix(base, path.Ext(base))
+			texts[id] = append(texts[id], uid)
+			continue
+		}
+		if IsLicenseFile(uid) {
+			continue
+		}
+		if strings.HasSuffix(uidPath(uid), ReuseSidecarSuffix) {
+			if _, exists := exists[strings.TrimSuffix(uidPath(uid), ReuseSidecarSuffix)+uidQuery(uid)]; exists {
+				continue // the ids in here get counted with the file
+			}
+		}
+
+		ids := reuseIDs(results[uid])
+		sidecar := uidPath(uid) + ReuseSidecarSuffix + uidQuery(uid)
+		ids = append(ids, reuseIDs(results[sidecar])...)
+		if len(ids) == 0 {
+			report.Unlicensed = append(report.Unlicensed, uid)
+			continue
+		}
+		for _, id := range ids {
+			used[id] = struct{}{}
+		}
+	}
+
+	for id := range used {
+		if _, exists := texts[id]; !exists {
+			report.Missing = append(report.Missing, id)
+		}
+		if !reuseValidID(id) {
+			report.Bad = append(report.Bad, id)
+		}
+	}
+	for id, x := range texts {
+		if _, exists := used[id]; !exists {
+			report.Unused = append(report.Unused, x...)
+			if !reuseValidID(id) {
+				report.Bad = append(report.Bad, id)
+			}
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Unused)
+	sort.Strings(report.Bad)
+	return report
+}
+
+// reuseIDs returns the license and exception ID's in the SPDX tags that were
+// found by the spdx backend. License expressions are split into their parts.
+func reuseIDs(m map[interfaces.Backend]*interfaces.Result) []string {
+	ids := []string{}
+	for backend, result := range m {
+		if backend.String() != reuseBackendName || result == nil {
+			continue
+		}
+		if result.Meta != nil && (result.Meta.Inferred || result.Meta.Inherited != "") {
+			continue
+		}
+		for _, license := range result.Licenses {
+			s := license.SPDX
+			if s == "" {
+				s = license.Custom
+			}
+			s = strings.NewReplacer("(", " ", ")", " ").Replace(s)
+			for _, x := range strings.Fields(s) {
+				switch strings.ToUpper(x) {
+				case "AND", "OR", "WITH":
+					continue
+				}
+				ids = append(ids, strings.TrimSuffix(x, "+"))
+			}
+		}
+	}
+	return ids
+}
+
+// reuseValidID returns true if this is a known SPDX license ID or a custom
+// LicenseRef- one. Since we don't load the list of exceptions, anything that
+// looks like one is assumed to be valid.
+func reuseValidID(id string) bool {
+	if strings.HasPrefix(id, ReuseLicenseRefPrefix) {
+		return true
+	}
+	if strings.Contains(strings.ToLower(id), "exception") {
+		return true
+	}
+	license := &licenses.License{SPDX: id}
+	return license.Validate() == nil
+}
+
+// uidQuery returns the query string of the UID including the leading question
+// mark, or the empty string if there isn't one.
+func uidQuery(uid string) string {
+	return strings.TrimPrefix(uid, uidPath(uid))
+}
+
+// joinUID appends a relative path onto a directory UID, keeping the query.
+func joinUID(dir, name string) string {
+	return uidPath(dir) + name + uidQuery(dir)
+}
+
+// commonParentUID returns the deepest directory UID that contains every one of
+// the UID's in the list.
+func commonParentUID(uids []string) string {
+	root, ok := ParentUID(uids[0])
+	for ok {
+		contains := true
+		for _, uid := range uids {
+			if !strings.HasPrefix(uid, uidPath(root)) {
+				contains = false
+				break
+			}
+		}
+		if contains {
+			return root
+		}
+		root, ok = ParentUID(root)
+	}
+	return ""
+}
+
+// ReturnReuse returns the list of REUSE reports as a string. Style can be
+// `ansi`, `html`, or `text`.
+func ReturnReuse(reports []*ReuseReport, style string) (string, error) {
+	if style != "ansi" && style != "html" && style != "text" {
+		return "", fmt.Errorf("invalid style: %s", style)
+	}
+
+	sections := []struct {
+		name string
+		list func(*ReuseReport) []string
+	}{
+		{"files without licensing info", func(x *ReuseReport) []string { return x.Unlicensed }},
+		{"missing license texts", func(x *ReuseReport) []string { return x.Missing }},
+		{"unused license texts", func(x *ReuseReport) []string { return x.Unused }},
+		{"bad licenses", func(x *ReuseReport) []string { return x.Bad }},
+	}
+
+	s := ""
+	for _, x := range reports {
+		if style == "html" {
+			s += fmt.Sprintf("<p>reuse %s: %s</p>\n", html.EscapeString(x.Artifact), x.Verdict)
+		} else {
+			s += fmt.Sprintf("reuse %s: %s\n", x.Artifact, x.Verdict)
+		}
+		for _, section := range sections {
+			list := section.list(x)
+			if len(list) == 0 {
+				continue
+			}
+			if style == "html" {
+				s += fmt.Sprintf("<p>%s:</p>\n<ul>\n", section.name)
+				for _, item := range list {
+					s += fmt.Sprintf("<li>%s</li>\n", html.EscapeString(item))
+				}
+				s += "</ul>\n"
+				continue
+			}
+			s += fmt.Sprintf("    %s:\n", section.name)
+			for _, item := range list {
+				s += fmt.Sprintf("        %s\n", item)
+			}
+		}
+	}
+	return s, nil
+}
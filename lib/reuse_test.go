@@ -0,0 +1,97 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestReuse(t *testing.T) {
+	spdx, other := testBackend("spdx"), testBackend("other")
+	tag := func(s string) map[interfaces.Backend]*interfaces.Result {
+		return map[interfaces.Backend]*interfaces.Result{
+			spdx: {Licenses: []*licenses.License{{SPDX: s}}, Confidence: 1.0},
+		}
+	}
+	output := &lib.Output{
+		Results: interfaces.ResultSet{
+			"file:///p/LICENSE": {
+				other: {Licenses: []*licenses.License{{SPDX: "MIT"}}, Confidence: 1.0},
+			},
+			"file:///p/a.go":             tag("MIT"),
+			"file:///p/b.go":             tag("MIT OR Apache-2.0"),
+			"file:///p/img.png.license":  tag("MIT"),
+			"file:///p/d.go":             tag("LicenseRef-Mine"),
+			"file:///p/e.go":             tag("Bogus"),
+			"file:///p/sub/f.go":         tag("GPL-2.0-only WITH Classpath-exception-2.0"),
+			"file:///p/LICENSES/MIT.txt": tag("MIT"),
+		},
+		Passes: []string{
+			"file:///p/",
+			"file:///p/c.go",
+			"file:///p/img.png",
+			"file:///p/LICENSES/LicenseRef-Mine.txt",
+			"file:///p/LICENSES/GPL-3.0-only.txt",
+			"file:///p/LICENSES/Nope.md",
+		},
+	}
+
+	reports := lib.Reuse(output)
+	if len(reports) != 1 {
+		t.Fatalf("expected one report, got %d", len(reports))
+	}
+	x := reports[0]
+	if x.Root != "file:///p/" {
+		t.Errorf("unexpected root: %s", x.Root)
+	}
+	if x.Verdict != lib.VerdictFail || x.Compliant() {
+		t.Errorf("expected a failure")
+	}
+	if exp := []string{"file:///p/c.go"}; !reflect.DeepEqual(x.Unlicensed, exp) {
+		t.Errorf("unlicensed: %+v", x.Unlicensed)
+	}
+	if exp := []string{"Apache-2.0", "Bogus", "Classpath-exception-2.0", "GPL-2.0-only"}; !reflect.DeepEqual(x.Missing, exp) {
+		t.Errorf("missing: %+v", x.Missing)
+	}
+	if exp := []string{"file:///p/LICENSES/GPL-3.0-only.txt", "file:///p/LICENSES/Nope.md"}; !reflect.DeepEqual(x.Unused, exp) {
+		t.Errorf("unused: %+v", x.Unused)
+	}
+	if exp := []string{"Bogus", "Nope"}; !reflect.DeepEqual(x.Bad, exp) {
+		t.Errorf("bad: %+v", x.Bad)
+	}
+
+	output.Reuse = reports
+	verdicts := lib.Verdicts(output)
+	if n := len(verdicts); n != 1 || verdicts[0].Profile != lib.ReuseProfileName {
+		t.Fatalf("expected only the reuse verdict, got %d", n)
+	}
+	if exp := "file:///p/LICENSES/Apache-2.0.txt"; verdicts[0].Violations[0] != exp {
+		t.Errorf("expected %s, got %s", exp, verdicts[0].Violations[0])
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/awslabs/yesiscan/util/errwrap"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	s3config "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3CacheRemote keeps each cache entry as an object in an S3 bucket, so that
+// every machine which can get at the bucket shares the same cache. This depends
+// on you having the AWS credentials set up on the machine, like for the s3
+// output. It's best to expire the old objects with a lifecycle rule, since the
+// stale entries are never read again.
+type S3CacheRemote struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	// Region is the region of the bucket.
+	Region string
+
+	// Bucket is the name of the bucket. It must already exist.
+	Bucket string
+
+	// Prefix is put in front of the name of each object, for example:
+	// "yesiscan/cache/" so that the bucket can be shared with others.
+	Prefix string
+
+	client *s3.Client
+}
+
+// String returns a human readable name for this remote.
+func (obj *S3CacheRemote) String() string {
+	return fmt.Sprintf("s3: %s/%s", obj.Bucket, obj.Prefix)
+}
+
+// Init builds the client, and checks that we can get at the bucket.
+func (obj *S3CacheRemote) Init(ctx context.Context) error {
+	if obj.Region == "" {
+		return fmt.Errorf("empty region")
+	}
+	if obj.Bucket == "" {
+		return fmt.Errorf("empty bucket")
+	}
+	cfg, err := s3config.LoadDefaultConfig(ctx, s3config.WithRegion(obj.Region))
+	if err != nil {
+		return errwrap.Wrapf(err, "config error")
+	}
+	obj.client = s3.NewFromConfig(cfg)
+
+	if _, err := obj.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(obj.Bucket)}); err != nil {
+		return errwrap.Wrapf(err, "can't use the bucket %s", obj.Bucket)
+	}
+	return nil
+}
+
+// Get downloads the entry object, or returns nil if there isn't one.
+func (obj *S3CacheRemote) Get(ctx context.Context, key string) ([]byte, error) {
+	name := obj.name(key)
+	output, err := obj.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(obj.Bucket),
+		Key:    aws.String(name),
+	})
+	var noSuchKey *s3types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "error getting the object at %s", name)
+	}
+	defer output.Body.Close()
+	return io.ReadAll(output.Body)
+}
+
+// Put uploads the entry object. S3 only ever shows a whole object.
+func (obj *S3CacheRemote) Put(ctx context.Context, key string, data []byte) error {
+	name := obj.name(key)
+	if obj.Debug {
+		obj.Logf("s3: put %s", name)
+	}
+	_, err := obj.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(obj.Bucket),
+		Key:         aws.String(name),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	return errwrap.Wrapf(err, "error putting the object at %s", name)
+}
+
+// name returns the name of the object that the entry with this key is stored
+// at. It uses the same layout as the cache on disk.
+func (obj *S3CacheRemote) name(key string) string {
+	return fmt.Sprintf("%s%s/%s.json", obj.Prefix, key[:2], key)
+}
@@ -0,0 +1,250 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+)
+
+const (
+	// SARIFVersion is the version of the SARIF format that we output.
+	SARIFVersion = "2.1.0"
+
+	// SARIFSchema is the location of the json schema for SARIFVersion.
+	SARIFSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	// SARIFSourceRoot is the base ID that the file locations are relative
+	// to. Code scanning viewers resolve this to the root of the repository.
+	SARIFSourceRoot = "%SRCROOT%"
+
+	// sarifInformationURI is where the tool is described.
+	sarifInformationURI = "https://github.com/awslabs/yesiscan"
+)
+
+// SARIFLog is the top-level structure of a SARIF file. We only fill in the
+// parts that code scanning viewers need to show the results. See:
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type SARIFLog struct {
+	Schema  string      `json:"$schema"`
+	Version string      `json:"version"`
+	Runs    []*SARIFRun `json:"runs"`
+}
+
+// SARIFRun is a single run of a tool.
+type SARIFRun struct {
+	Tool    *SARIFTool     `json:"tool"`
+	Results []*SARIFResult `json:"results"`
+}
+
+// SARIFTool describes the tool which produced the run.
+type SARIFTool struct {
+	Driver *SARIFDriver `json:"driver"`
+}
+
+// SARIFDriver is the main component of the tool. Each backend is a rule.
+type SARIFDriver struct {
+	Name           string       `json:"name"`
+	Version        string       `json:"version,omitempty"`
+	InformationURI string       `json:"informationUri"`
+	Rules          []*SARIFRule `json:"rules"`
+}
+
+// SARIFRule describes one of the backends that the results come from.
+type SARIFRule struct {
+	ID               string        `json:"id"`
+	Name             string        `json:"name"`
+	ShortDescription *SARIFMessage `json:"shortDescription"`
+}
+
+// SARIFMessage is a plain text message.
+type SARIFMessage struct {
+	Text string `json:"text"`
+}
+
+// SARIFResult is a single license determination for a single file.
+type SARIFResult struct {
+	RuleID     string                 `json:"ruleId"`
+	RuleIndex  int                    `json:"ruleIndex"`
+	Level      string                 `json:"level"`
+	Message    *SARIFMessage          `json:"message"`
+	Locations  []*SARIFLocation       `json:"locations"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// SARIFLocation is where a result was found.
+type SARIFLocation struct {
+	PhysicalLocation *SARIFPhysicalLocation `json:"physicalLocation"`
+}
+
+// SARIFPhysicalLocation is the file that a result was found in. We don't have a
+// region since our determinations are for the whole file.
+type SARIFPhysicalLocation struct {
+	ArtifactLocation *SARIFArtifactLocation `json:"artifactLocation"`
+}
+
+// SARIFArtifactLocation is the location of a file. The URI is relative to the
+// base ID if it has one.
+type SARIFArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// NewSARIFLog builds a SARIF log with one result for each backend that found a
+// license in a file. The results that were inferred from another file aren't
+// included, since no backend found those. The paths are relative to the root of
+// the artifact that each file came from, which is the root of the repository
+// when we scan a checkout of one.
+func NewSARIFLog(output *Output) *SARIFLog {
+	artifacts := []string{}
+	artifacts = append(artifacts, output.Args...)
+	if len(artifacts) == 0 {
+		artifacts = append(artifacts, "")
+	}
+	files, _ := ArtifactFiles(output, artifacts)
+
+	rules := []*SARIFRule{}
+	indexes := make(map[string]int) // rule id -> index
+	results := []*SARIFResult{}
+	for _, a := range artifacts {
+		root := sarifRoot(output, files[a])
+		for _, uid := range files[a] {
+			m := output.Results[uid]
+			for _, backend := range SortedResultBackends(m) {
+				result := m[backend]
+				if len(result.Licenses) == 0 || (result.Meta != nil && result.Meta.Inferred) {
+					continue
+				}
+				id := backend.String()
+				index, exists := indexes[id]
+				if !exists {
+					index = len(rules)
+					indexes[id] = index
+					rules = append(rules, &SARIFRule{
+						ID:   id,
+						Name: id,
+						ShortDescription: &SARIFMessage{
+							Text: fmt.Sprintf("Licenses found by the %s backend.", id),
+						},
+					})
+				}
+				results = append(results, newSARIFResult(uid, root, id, index, result))
+			}
+		}
+	}
+
+	return &SARIFLog{
+		Schema:  SARIFSchema,
+		Version: SARIFVersion,
+		Runs: []*SARIFRun{
+			{
+				Tool: &SARIFTool{
+					Driver: &SARIFDriver{
+						Name:           output.Program,
+						Version:        output.Version,
+						InformationURI: sarifInformationURI,
+						Rules:          rules,
+					},
+				},
+				Results: results,
+			},
+		},
+	}
+}
+
+// newSARIFResult builds the result for what a single backend found in a file.
+func newSARIFResult(uid, root, id string, index int, result *interfaces.Result) *SARIFResult {
+	ids := []string{}
+	for _, license := range SortedLicenses(result.Licenses) {
+		ids = append(ids, scancodeSPDX(license))
+	}
+	expression := strings.Join(ids, " AND ")
+
+	location := &SARIFArtifactLocation{
+		URI: ScancodePath(uid), // no scheme
+	}
+	if root != "" {
+		location.URI = strings.TrimPrefix(strings.TrimPrefix(uidPath(uid), uidPath(root)), "/")
+		location.URIBaseID = SARIFSourceRoot
+	}
+	text := fmt.Sprintf("%s found by %s with %.0f%% confidence", expression, id, result.Confidence*100.0)
+	if result.Meta != nil && result.Meta.Inherited != "" {
+		text += fmt.Sprintf(", inherited from %s", ScancodePath(result.Meta.Inherited))
+	}
+	if result.Meta != nil && result.Meta.Symlink != "" {
+		text += fmt.Sprintf(", through a symlink to %s", ScancodePath(result.Meta.Symlink))
+	}
+
+	return &SARIFResult{
+		RuleID:    id,
+		RuleIndex: index,
+		Level:     "note", // a license isn't a problem by itself
+		Message:   &SARIFMessage{Text: text},
+		Locations: []*SARIFLocation{
+			{
+				PhysicalLocation: &SARIFPhysicalLocation{
+					ArtifactLocation: location,
+				},
+			},
+		},
+		Properties: map[string]interface{}{
+			"licenses":   ids,
+			"confidence": result.Confidence,
+		},
+	}
+}
+
+// sarifRoot returns the root directory of an artifact, which is the shortest
+// directory that we walked through which contains all of its files. If we did
+// not walk through any, such as when the artifact is a single file, then it's
+// empty.
+func sarifRoot(output *Output, uids []string) string {
+	if len(uids) == 0 {
+		return ""
+	}
+	parent := uidPath(commonParentUID(uids))
+	root := ""
+	for _, uid := range output.Passes {
+		if !strings.HasSuffix(uid, "/") || !strings.HasPrefix(parent, uidPath(uid)) {
+			continue
+		}
+		if root == "" || len(uid) < len(root) {
+			root = uid
+		}
+	}
+	return root
+}
+
+// ReturnOutputSARIF returns a string of output, formatted as SARIF json which
+// can be uploaded to code scanning viewers.
+func ReturnOutputSARIF(output *Output) (string, error) {
+	b, err := json.MarshalIndent(NewSARIFLog(output), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
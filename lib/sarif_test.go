@@ -0,0 +1,79 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestSARIF(t *testing.T) {
+	b1, b2 := testBackend("b1"), testBackend("b2")
+	output := &lib.Output{
+		Program: "yesiscan",
+		Version: "1.0",
+		Args:    []string{"file:///m/"},
+		Results: interfaces.ResultSet{
+			"file:///m/LICENSE": {
+				b1: {Licenses: []*licenses.License{{SPDX: "MIT"}}, Confidence: 1.0},
+				b2: {Licenses: []*licenses.License{{SPDX: "MIT"}}, Confidence: 0.5},
+			},
+			"file:///m/sub/a.go": {
+				b2: {Licenses: []*licenses.License{{SPDX: "MIT"}}, Confidence: 1.0, Meta: &interfaces.Meta{Inferred: true}},
+			},
+		},
+		Passes: []string{"file:///m/", "file:///m/sub/"},
+	}
+
+	s, err := lib.ReturnOutputSARIF(output)
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	log := &lib.SARIFLog{}
+	if err := json.Unmarshal([]byte(s), log); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if log.Version != lib.SARIFVersion || len(log.Runs) != 1 {
+		t.Fatalf("unexpected log: %s", s)
+	}
+	run := log.Runs[0]
+	if rules := run.Tool.Driver.Rules; len(rules) != 2 || rules[0].ID != "b1" || rules[1].ID != "b2" {
+		t.Errorf("unexpected rules: %s", s)
+	}
+	if len(run.Results) != 2 { // the inferred one is left out
+		t.Fatalf("expected 2 results, got: %s", s)
+	}
+	r := run.Results[1]
+	location := r.Locations[0].PhysicalLocation.ArtifactLocation
+	if r.RuleID != "b2" || r.RuleIndex != 1 || location.URI != "LICENSE" || location.URIBaseID != lib.SARIFSourceRoot {
+		t.Errorf("unexpected result: %+v at %+v", r, location)
+	}
+	if r.Message.Text != "MIT found by b2 with 50% confidence" {
+		t.Errorf("unexpected message: %s", r.Message.Text)
+	}
+}
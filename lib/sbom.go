@@ -0,0 +1,263 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+const (
+	// SBOMFormatCycloneDX is the CycloneDX json format.
+	SBOMFormatCycloneDX = "cyclonedx"
+
+	// SBOMFormatSPDX is the SPDX json format.
+	SBOMFormatSPDX = "spdx"
+
+	// DefaultSBOMFormat is the format used if none is specified.
+	DefaultSBOMFormat = SBOMFormatCycloneDX
+
+	// SBOMIndexName is the name of the index file of a split SBOM.
+	SBOMIndexName = "index.json"
+)
+
+// SBOMFormats are the valid SBOM formats.
+var SBOMFormats = []string{
+	SBOMFormatCycloneDX,
+	SBOMFormatSPDX,
+}
+
+// sbomExtensions are the file extensions of the documents of each format.
+var sbomExtensions = map[string]string{
+	SBOMFormatCycloneDX: ".cdx.json",
+	SBOMFormatSPDX:      ".spdx.json",
+}
+
+// SBOMIndex lists the documents of an SBOM which was split by package.
+type SBOMIndex struct {
+	Program string `json:"program"`
+	Version string `json:"version"`
+	Format  string `json:"format"`
+
+	Documents []*SBOMIndexEntry `json:"documents"`
+}
+
+// SBOMIndexEntry is the document of one package.
+type SBOMIndexEntry struct {
+	// File is the name of the document, relative to the index.
+	File string `json:"file"`
+
+	// Artifact is the input argument that the package is in.
+	Artifact string `json:"artifact"`
+
+	// Path is the directory of the package inside of the artifact.
+	Path string `json:"path"`
+
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+
+	// Licenses are all the licenses found in the package.
+	Licenses []string `json:"licenses"`
+
+	// Verdicts maps each profile to its verdict for the package.
+	Verdicts map[string]string `json:"verdicts"`
+}
+
+// sbomFileReplacer matches the characters we don't want in a file name.
+var sbomFileReplacer = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// SplitSBOM returns one SBOM document for each package, and an index of them.
+// The documents are keyed by file name. If an artifact has no packages, then
+// it gets a single document of its own. Each document describes one package,
+// which is named after the artifact and the path of the package inside of it.
+func SplitSBOM(output *Output, format string) (map[string]string, *SBOMIndex, error) {
+	if format == "" {
+		format = DefaultSBOMFormat
+	}
+	if _, exists := sbomExtensions[format]; !exists {
+		return nil, nil, fmt.Errorf("invalid sbom format: %s", format)
+	}
+
+	packages := output.Packages
+	if packages == nil {
+		packages = Packages(output)
+	}
+	split := make(map[string]struct{}) // artifacts that have packages
+	for _, x := range packages {
+		split[x.Artifact] = struct{}{}
+	}
+	artifacts := []string{}
+	artifacts = append(artifacts, output.Args...)
+	if len(artifacts) == 0 {
+		artifacts = append(artifacts, "")
+	}
+	whole := []string{}
+	for _, a := range artifacts {
+		if _, exists := split[a]; !exists {
+			whole = append(whole, a)
+		}
+	}
+	if len(whole) > 0 {
+		// treat each of the others as a single package at the top
+		files, _ := ArtifactFiles(output, whole)
+		verdicts := output.Verdicts
+		if verdicts == nil {
+			verdicts = Verdicts(output)
+		}
+		for _, a := range whole {
+			p := &Package{
+				Artifact: a,
+				Path:     ".",
+				Files:    files[a],
+			}
+			for _, v := range verdicts {
+				if v.Artifact == a {
+					p.Verdicts = append(p.Verdicts, v)
+				}
+			}
+			packages = append(packages, p)
+		}
+	}
+
+	documents := make(map[string]string)
+	index := &SBOMIndex{
+		Program:   output.Program,
+		Version:   output.Version,
+		Format:    format,
+		Documents: []*SBOMIndexEntry{},
+	}
+	for i, x := range packages {
+		name, version := ArtifactCoordinates(output, x.Artifact)
+		if x.Path != "." && name != "" {
+			name = name + "/" + x.Path
+		} else if x.Path != "." {
+			name = x.Path
+		}
+		ls := packageLicenses(output, nil, x.Files)
+
+		var doc interface{}
+		switch format {
+		case SBOMFormatCycloneDX:
+			component := &CycloneDXComponent{
+				Type:               "library",
+				BomRef:             fmt.Sprintf("%s-%d", output.Program, i),
+				Name:               name,
+				Version:            version,
+				Licenses:           cycloneDXLicenses(ls),
+				ExternalReferences: cycloneDXReferences(x.Artifact),
+				Evidence:           cycloneDXEvidence(output, x.Files),
+			}
+			if x.Path == "." { // the checksum is of the whole artifact
+				component.Hashes = cycloneDXHashes(output, x.Artifact)
+			}
+			bom, err := newCycloneDXBom(output, []*CycloneDXComponent{component})
+			if err != nil {
+				return nil, nil, err
+			}
+			doc = bom
+
+		case SBOMFormatSPDX:
+			pkg := newSPDXPackage(output, 0, name, version, x.Artifact, x.Root, x.Files)
+			if x.Path == "." { // the checksum is of the whole artifact
+				pkg.Checksums = spdxChecksums(output, x.Artifact)
+			}
+			spdx, err := newSPDXDocument(output, pkg.Name, []*SPDXPackage{pkg})
+			if err != nil {
+				return nil, nil, err
+			}
+			doc = spdx
+		}
+		b, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return nil, nil, err
+		}
+
+		file := sbomFileName(name, sbomExtensions[format], documents)
+		documents[file] = string(b) + "\n"
+
+		entry := &SBOMIndexEntry{
+			File:     file,
+			Artifact: x.Artifact,
+			Path:     x.Path,
+			Name:     name,
+			Version:  version,
+			Licenses: []string{},
+			Verdicts: make(map[string]string),
+		}
+		for _, l := range ls {
+			entry.Licenses = append(entry.Licenses, l.String())
+		}
+		for _, v := range x.Verdicts {
+			entry.Verdicts[v.Profile] = v.Verdict
+		}
+		index.Documents = append(index.Documents, entry)
+	}
+	return documents, index, nil
+}
+
+// ReturnSBOMIndex returns the index of a split SBOM as json.
+func ReturnSBOMIndex(index *SBOMIndex) (string, error) {
+	b, err := json.MarshalIndent(index, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+// packageLicenses returns the sorted union of the licenses in the files. If the
+// profile is not nil, then its preferences choose from any dual licenses.
+func packageLicenses(output *Output, profile *ProfileData, uids []string) []*licenses.License {
+	ls := []*licenses.License{}
+	for _, uid := range uids {
+		for _, r := range output.Results[uid] {
+			for _, x := range ElectedLicenses(profile, r) {
+				if !licenses.InList(x, ls) {
+					ls = append(ls, x)
+				}
+			}
+		}
+	}
+	return SortedLicenses(ls)
+}
+
+// sbomFileName returns a unique and safe file name for the document of the
+// named package.
+func sbomFileName(name, ext string, taken map[string]string) string {
+	base := strings.Trim(sbomFileReplacer.ReplaceAllString(path.Clean(name), "_"), "._")
+	if base == "" {
+		base = "package"
+	}
+	file := base + ext
+	for i := 2; ; i++ {
+		if _, exists := taken[file]; !exists && file != SBOMIndexName {
+			return file
+		}
+		file = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestSplitSBOM(t *testing.T) {
+	b := testBackend("spdx")
+	output := &lib.Output{
+		Program: "yesiscan",
+		Args:    []string{"file:///m/"},
+		Results: interfaces.ResultSet{
+			"file:///m/a/x.go": {
+				b: {Licenses: []*licenses.License{{SPDX: "MIT"}}, Confidence: 1.0},
+			},
+		},
+		Passes: []string{
+			"file:///m/a/go.mod",
+			"file:///m/b/setup.py",
+			"file:///m/b/c/setup.py",
+		},
+		Profiles: []string{lib.DefaultProfileName},
+	}
+
+	documents, index, err := lib.SplitSBOM(output, "")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if len(documents) != 3 || len(index.Documents) != 3 {
+		t.Fatalf("expected 3 documents, got %d", len(documents))
+	}
+	names := map[string]string{} // file -> name
+	for _, x := range index.Documents {
+		names[x.File] = x.Name
+	}
+	if names["m_a.cdx.json"] != "m/a" || names["m_b_c.cdx.json"] != "m/b/c" {
+		t.Errorf("unexpected documents: %+v", names)
+	}
+
+	bom := &lib.CycloneDXBom{}
+	if err := json.Unmarshal([]byte(documents["m_a.cdx.json"]), bom); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if len(bom.Components) != 1 || bom.Components[0].Licenses[0].License.ID != "MIT" {
+		t.Errorf("unexpected component: %+v", bom.Components)
+	}
+
+	if _, _, err := lib.SplitSBOM(output, "nope"); err == nil {
+		t.Errorf("expected an invalid format error")
+	}
+}
@@ -0,0 +1,323 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+const (
+	// ScancodeOutputFormatVersion is the version of the scancode json
+	// output format that we mimic.
+	ScancodeOutputFormatVersion = "3.0.0"
+
+	// scancodeLicenseRefPrefix is the prefix of the made up SPDX ID's that
+	// we use for the licenses which aren't in the SPDX list.
+	scancodeLicenseRefPrefix = "LicenseRef-yesiscan-"
+)
+
+var (
+	// scancodeStripRe matches the characters that aren't allowed in a
+	// LicenseRef- identifier.
+	scancodeStripRe = regexp.MustCompile(`[^A-Za-z0-9.\-]+`)
+)
+
+// ScancodeOutput is the top-level structure of the json output of scancode. We
+// only fill in the fields that we have data for, which are the ones that most
+// downstream tooling looks at. See: https://scancode-toolkit.readthedocs.io/
+type ScancodeOutput struct {
+	Headers []*ScancodeHeader `json:"headers"`
+
+	// LicenseDetections is the list of unique license expressions that
+	// were detected along with the number of times that they were seen.
+	LicenseDetections []*ScancodeUniqueDetection `json:"license_detections"`
+
+	Files []*ScancodeFile `json:"files"`
+}
+
+// ScancodeHeader describes the tool that produced the output.
+type ScancodeHeader struct {
+	ToolName            string                 `json:"tool_name"`
+	ToolVersion         string                 `json:"tool_version"`
+	Options             map[string]interface{} `json:"options"`
+	Notice              string                 `json:"notice"`
+	OutputFormatVersion string                 `json:"output_format_version"`
+	Errors              []string               `json:"errors"`
+	Warnings            []string               `json:"warnings"`
+	ExtraData           map[string]interface{} `json:"extra_data"`
+}
+
+// ScancodeUniqueDetection is an entry of the top-level detections list.
+type ScancodeUniqueDetection struct {
+	Identifier            string `json:"identifier"`
+	LicenseExpression     string `json:"license_expression"`
+	LicenseExpressionSPDX string `json:"license_expression_spdx"`
+	DetectionCount        int    `json:"detection_count"`
+}
+
+// ScancodeFile is the entry for a single file or directory. It has both the
+// newer detection fields and the older licenses fields, so that tooling which
+// was written against either of them can read it.
+type ScancodeFile struct {
+	Path      string `json:"path"`
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	BaseName  string `json:"base_name"`
+	Extension string `json:"extension"`
+
+	DetectedLicenseExpression     string               `json:"detected_license_expression"`
+	DetectedLicenseExpressionSPDX string               `json:"detected_license_expression_spdx"`
+	LicenseDetections             []*ScancodeDetection `json:"license_detections"`
+
+	Licenses           []*ScancodeLicense `json:"licenses"`
+	LicenseExpressions []string           `json:"license_expressions"`
+
+	ScanErrors []string `json:"scan_errors"`
+}
+
+// ScancodeDetection is a single license detection in a file. We make one of
+// these for each backend which found something.
+type ScancodeDetection struct {
+	LicenseExpression     string           `json:"license_expression"`
+	LicenseExpressionSPDX string           `json:"license_expression_spdx"`
+	Matches               []*ScancodeMatch `json:"matches"`
+	Identifier            string           `json:"identifier"`
+}
+
+// ScancodeMatch is a single match that is part of a detection.
+type ScancodeMatch struct {
+	Score                 float64 `json:"score"`
+	LicenseExpression     string  `json:"license_expression"`
+	SPDXLicenseExpression string  `json:"spdx_license_expression"`
+	FromFile              string  `json:"from_file"`
+	Matcher               string  `json:"matcher"`
+}
+
+// ScancodeLicense is an entry of the older per file licenses list.
+type ScancodeLicense struct {
+	Key            string  `json:"key"`
+	Score          float64 `json:"score"`
+	Name           string  `json:"name"`
+	SPDXLicenseKey string  `json:"spdx_license_key"`
+	Matcher        string  `json:"matcher"`
+}
+
+// ScancodePath returns the path that scancode would show for this UID. This is
+// the path portion of the UID without the scheme or the leading slash, since
+// scancode paths are always relative.
+func ScancodePath(uid string) string {
+	p := uidPath(uid)
+	if i := strings.Index(p, "://"); i >= 0 {
+		p = p[i+len("://"):]
+	}
+	p = strings.TrimPrefix(p, "/")
+	return strings.TrimSuffix(p, "/")
+}
+
+// scancodeSPDX returns the SPDX ID for a license, making up a LicenseRef- one
+// if it isn't in the SPDX list.
+func scancodeSPDX(license *licenses.License) string {
+	if license.SPDX != "" {
+		return license.SPDX
+	}
+	s := license.Custom
+	if license.Origin != "" {
+		s += "-" + license.Origin
+	}
+	return scancodeLicenseRefPrefix + strings.Trim(scancodeStripRe.ReplaceAllString(s, "-"), "-")
+}
+
+// scancodeKey returns the scancode style license key for an SPDX ID. We don't
+// have the scancode license database, so this is the lower case SPDX ID, which
+// is the same as the scancode key for most of the common licenses.
+func scancodeKey(spdx string) string {
+	return strings.ToLower(spdx)
+}
+
+// NewScancodeOutput builds the scancode compatible form of the output. Results
+// that were inherited from another file are shown as matches from that file.
+func NewScancodeOutput(output *Output) *ScancodeOutput {
+	input := []string{} // show an empty list, not null
+	input = append(input, output.Args...)
+	header := &ScancodeHeader{
+		ToolName:    output.Program,
+		ToolVersion: output.Version,
+		Options: map[string]interface{}{
+			"input": input,
+		},
+		Notice:              "Generated by " + output.Program + " in a scancode compatible format.",
+		OutputFormatVersion: ScancodeOutputFormatVersion,
+		Errors:              []string{},
+		Warnings:            []string{},
+		ExtraData:           map[string]interface{}{},
+	}
+	keys := []string{}
+	for k := range output.Warnings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		header.Warnings = append(header.Warnings, k+": "+output.Warnings[k].Error())
+	}
+
+	uids := []string{}
+	for uid := range output.Results {
+		uids = append(uids, uid)
+	}
+	for _, uid := range output.Passes {
+		if _, exists := output.Results[uid]; !exists {
+			uids = append(uids, uid)
+		}
+	}
+	sort.Strings(uids)
+
+	unique := make(map[string]*ScancodeUniqueDetection)
+	files := []*ScancodeFile{}
+	for _, uid := range uids {
+		file := newScancodeFile(uid, output.Results[uid])
+		for _, x := range file.LicenseDetections {
+			d, exists := unique[x.Identifier]
+			if !exists {
+				d = &ScancodeUniqueDetection{
+					Identifier:            x.Identifier,
+					LicenseExpression:     x.LicenseExpression,
+					LicenseExpressionSPDX: x.LicenseExpressionSPDX,
+				}
+				unique[x.Identifier] = d
+			}
+			d.DetectionCount++
+		}
+		files = append(files, file)
+	}
+	header.ExtraData["files_count"] = len(files)
+
+	detections := []*ScancodeUniqueDetection{}
+	for _, x := range unique {
+		detections = append(detections, x)
+	}
+	sort.Slice(detections, func(i, j int) bool {
+		return detections[i].Identifier < detections[j].Identifier
+	})
+
+	return &ScancodeOutput{
+		Headers:           []*ScancodeHeader{header},
+		LicenseDetections: detections,
+		Files:             files,
+	}
+}
+
+// newScancodeFile builds the scancode file entry for a single UID.
+func newScancodeFile(uid string, m map[interfaces.Backend]*interfaces.Result) *ScancodeFile {
+	p := ScancodePath(uid)
+	name := path.Base(p)
+	ext := path.Ext(name)
+	file := &ScancodeFile{
+		Path:               p,
+		Type:               "file",
+		Name:               name,
+		BaseName:           strings.TrimSuffix(name, ext),
+		Extension:          ext,
+		LicenseDetections:  []*ScancodeDetection{},
+		Licenses:           []*ScancodeLicense{},
+		LicenseExpressions: []string{},
+		ScanErrors:         []string{},
+	}
+	if strings.HasSuffix(uid, "/") {
+		file.Type = "directory"
+		file.BaseName = name
+		file.Extension = ""
+	}
+
+	seen := make(map[string]struct{}) // spdx id's in this file
+	ids := []string{}
+	for _, backend := range SortedResultBackends(m) {
+		result := m[backend]
+		if result.Skip != nil {
+			file.ScanErrors = append(file.ScanErrors, backend.String()+": "+result.Skip.Error())
+		}
+		if len(result.Licenses) == 0 {
+			continue
+		}
+		from := p
+		if result.Meta != nil && result.Meta.Inherited != "" {
+			from = ScancodePath(result.Meta.Inherited)
+		}
+		score := result.Confidence * 100.0
+
+		spdx := []string{}
+		for _, license := range SortedLicenses(result.Licenses) {
+			id := scancodeSPDX(license)
+			spdx = append(spdx, id)
+			file.Licenses = append(file.Licenses, &ScancodeLicense{
+				Key:            scancodeKey(id),
+				Score:          score,
+				Name:           license.String(),
+				SPDXLicenseKey: id,
+				Matcher:        backend.String(),
+			})
+			if _, exists := seen[id]; !exists {
+				seen[id] = struct{}{}
+				ids = append(ids, id)
+			}
+		}
+		expression := strings.Join(spdx, " AND ") // each result is an AND
+		file.LicenseDetections = append(file.LicenseDetections, &ScancodeDetection{
+			LicenseExpression:     scancodeKey(expression),
+			LicenseExpressionSPDX: expression,
+			Matches: []*ScancodeMatch{
+				{
+					Score:                 score,
+					LicenseExpression:     scancodeKey(expression),
+					SPDXLicenseExpression: expression,
+					FromFile:              from,
+					Matcher:               backend.String(),
+				},
+			},
+			Identifier: scancodeKey(expression),
+		})
+		file.LicenseExpressions = append(file.LicenseExpressions, scancodeKey(expression))
+	}
+
+	sort.Strings(ids)
+	file.DetectedLicenseExpressionSPDX = strings.Join(ids, " AND ")
+	file.DetectedLicenseExpression = scancodeKey(file.DetectedLicenseExpressionSPDX)
+	return file
+}
+
+// ReturnOutputScancode returns a string of output, formatted as json which is
+// compatible with the output of scancode.
+func ReturnOutputScancode(output *Output) (string, error) {
+	b, err := json.MarshalIndent(NewScancodeOutput(output), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
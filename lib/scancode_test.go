@@ -0,0 +1,121 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestScancodeOutput(t *testing.T) {
+	b1, b2 := testBackend("b1"), testBackend("b2")
+	output := &lib.Output{
+		Program: "yesiscan",
+		Version: "0.0.1",
+		Results: interfaces.ResultSet{
+			"file:///p/LICENSE": {
+				b1: {Licenses: []*licenses.License{{SPDX: "MIT"}}, Confidence: 1.0},
+			},
+			"file:///p/a.go": {
+				b1: {Licenses: []*licenses.License{{SPDX: "MIT"}, {SPDX: "Apache-2.0"}}, Confidence: 0.5},
+				b2: {Licenses: []*licenses.License{{Custom: "my license", Origin: "example.com"}}, Confidence: 1.0},
+			},
+			"file:///p/b.go": {
+				b1: {
+					Licenses:   []*licenses.License{{SPDX: "MIT"}},
+					Confidence: 1.0,
+					Meta:       &interfaces.Meta{Inherited: "file:///p/LICENSE", Inferred: true},
+				},
+				b2: {Skip: fmt.Errorf("too big")},
+			},
+		},
+		Passes: []string{"file:///p/", "file:///p/c.go"},
+	}
+
+	s, err := lib.ReturnOutputScancode(output)
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	out := &lib.ScancodeOutput{}
+	if err := json.Unmarshal([]byte(s), out); err != nil {
+		t.Fatalf("could not decode: %+v", err)
+	}
+	if len(out.Headers) != 1 || out.Headers[0].ToolName != "yesiscan" {
+		t.Errorf("unexpected headers: %+v", out.Headers)
+	}
+	if n := len(out.Files); n != 5 {
+		t.Fatalf("expected 5 files, got %d", n)
+	}
+	files := make(map[string]*lib.ScancodeFile)
+	for _, x := range out.Files {
+		files[x.Path] = x
+	}
+
+	if x := files["p"]; x == nil || x.Type != "directory" {
+		t.Errorf("expected a directory: %+v", x)
+	}
+
+	a := files["p/a.go"]
+	if a == nil || a.Extension != ".go" || a.BaseName != "a" {
+		t.Fatalf("unexpected file: %+v", a)
+	}
+	if exp := "Apache-2.0 AND LicenseRef-yesiscan-my-license-example.com AND MIT"; a.DetectedLicenseExpressionSPDX != exp {
+		t.Errorf("exp: %s", exp)
+		t.Errorf("got: %s", a.DetectedLicenseExpressionSPDX)
+	}
+	if n := len(a.LicenseDetections); n != 2 {
+		t.Fatalf("expected 2 detections, got %d", n)
+	}
+	if x := a.LicenseDetections[0]; x.LicenseExpression != "apache-2.0 and mit" || x.Matches[0].Score != 50.0 || x.Matches[0].Matcher != "b1" {
+		t.Errorf("unexpected detection: %+v", x)
+	}
+	if n := len(a.Licenses); n != 3 {
+		t.Errorf("expected 3 licenses, got %d", n)
+	}
+
+	b := files["p/b.go"]
+	if b == nil || len(b.LicenseDetections) != 1 || b.LicenseDetections[0].Matches[0].FromFile != "p/LICENSE" {
+		t.Errorf("expected an inherited detection: %+v", b)
+	}
+	if len(b.ScanErrors) != 1 {
+		t.Errorf("expected a scan error: %+v", b.ScanErrors)
+	}
+
+	if x := files["p/c.go"]; x == nil || len(x.LicenseDetections) != 0 || x.DetectedLicenseExpression != "" {
+		t.Errorf("expected no detections: %+v", x)
+	}
+
+	counts := make(map[string]int)
+	for _, x := range out.LicenseDetections {
+		counts[x.Identifier] = x.DetectionCount
+	}
+	if counts["mit"] != 2 {
+		t.Errorf("expected mit to be detected twice: %+v", counts)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/awslabs/yesiscan/interfaces"
+)
+
+// Schedule decides which of the backends run on each file. The cheap ones
+// always run first, and the expensive ones only run afterwards on the files
+// which the cheap ones found nothing in, or disagreed about. The rest already
+// have a clear answer, so those are never sent to the expensive backends. It
+// may be shared between many scanners. A nil schedule runs every backend on
+// every file.
+type Schedule struct {
+	// Expensive is the set of backends which only run when they're needed.
+	Expensive map[interfaces.Backend]struct{}
+
+	// Limit is the most expensive backends that may run at the same time.
+	// If it is zero, then there is no limit.
+	Limit int
+
+	mu        sync.Mutex
+	sem       chan struct{}
+	files     int64
+	escalated int64
+}
+
+// Init initializes the schedule before use.
+func (obj *Schedule) Init() {
+	if obj.Limit > 0 {
+		obj.sem = make(chan struct{}, obj.Limit)
+	}
+}
+
+// Split returns the backends to run first, and the expensive ones to run after
+// them if they're needed. Directories never need the expensive ones, since the
+// backends that are expensive only look at files.
+func (obj *Schedule) Split(backends []interfaces.Backend, info *interfaces.Info) ([]interfaces.Backend, []interfaces.Backend) {
+	if obj == nil || len(obj.Expensive) == 0 {
+		return backends, nil
+	}
+	cheap := []interfaces.Backend{}
+	expensive := []interfaces.Backend{}
+	for _, backend := range backends {
+		if _, exists := obj.Expensive[backend]; !exists {
+			cheap = append(cheap, backend)
+		} else if !info.FileInfo.IsDir() {
+			expensive = append(expensive, backend)
+		}
+	}
+	return cheap, expensive
+}
+
+// Needed looks at what the cheap backends found in a file, and returns true if
+// the expensive backends should run on it too. That's when none of them found
+// a license, or when they found different ones.
+func (obj *Schedule) Needed(results map[interfaces.Backend]*interfaces.Result) bool {
+	answers := make(map[string]struct{})
+	for _, result := range results {
+		if result == nil || result.Skip != nil || len(result.Licenses) == 0 {
+			continue // unknown
+		}
+		xs := []string{}
+		for _, license := range result.Licenses {
+			xs = append(xs, license.String())
+		}
+		sort.Strings(xs)
+		answers[strings.Join(xs, ", ")] = struct{}{}
+	}
+	needed := len(answers) != 1
+
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	obj.files++
+	if needed {
+		obj.escalated++
+	}
+	return needed
+}
+
+// Acquire blocks until this backend may run, or until the context is cancelled,
+// in which case it returns the context error. Only the expensive backends are
+// limited. Every successful Acquire must be followed by a Release of the same
+// backend.
+func (obj *Schedule) Acquire(ctx context.Context, backend interfaces.Backend) error {
+	if obj == nil || obj.sem == nil {
+		return nil
+	}
+	if _, exists := obj.Expensive[backend]; !exists {
+		return nil
+	}
+	select {
+	case obj.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release lets another expensive backend run after this one is done.
+func (obj *Schedule) Release(backend interfaces.Backend) {
+	if obj == nil || obj.sem == nil {
+		return
+	}
+	if _, exists := obj.Expensive[backend]; !exists {
+		return
+	}
+	<-obj.sem
+}
+
+// String returns a summary of how often the expensive backends were needed.
+func (obj *Schedule) String() string {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	return fmt.Sprintf("the expensive backends ran on %d of %d files", obj.escalated, obj.files)
+}
@@ -0,0 +1,104 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+const (
+	// SchemaVersion is the version of the structured json output format. It
+	// must be incremented whenever JSONOutput or JSONResult change in a way
+	// that an older reader wouldn't understand, and a migration from the
+	// previous version must be added to jsonMigrations at the same time.
+	// Adding a new optional field doesn't need a new version.
+	SchemaVersion = 1
+
+	// ErrSchemaTooNew is returned when the json output was made by a newer
+	// version of this program than the one which is reading it.
+	ErrSchemaTooNew = interfaces.Error("the output has a newer schema version")
+)
+
+// jsonMigrations has the migration from each schema version to the next one,
+// indexed by the version that it starts from. Each one changes the generic form
+// of the json output in place. Version zero is everything from before the
+// schema was versioned, which has the same form as version one.
+var jsonMigrations = []func(map[string]interface{}) error{
+	0: func(m map[string]interface{}) error { return nil },
+}
+
+// MigrateJSONOutput returns the json output in the form of the current schema
+// version, by running each of the migrations from the version that it has. The
+// output is returned as-is if it's already current. An error that wraps the
+// ErrSchemaTooNew error is returned if it's from a newer version, since we
+// can't know what changed. Anyone who stores the json output can run it through
+// this when reading it back. Decoding into a JSONOutput already does this.
+func MigrateJSONOutput(b []byte) ([]byte, error) {
+	var header struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(b, &header); err != nil {
+		return nil, errwrap.Wrapf(err, "could not read the schema version")
+	}
+	version := header.SchemaVersion
+	if version > SchemaVersion {
+		return nil, errwrap.Wrapf(ErrSchemaTooNew, "got version %d, but only know up to %d", version, SchemaVersion)
+	}
+	if version < 0 {
+		return nil, fmt.Errorf("invalid schema version: %d", version)
+	}
+	if version == SchemaVersion {
+		return b, nil
+	}
+
+	m := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewBuffer(b))
+	decoder.UseNumber() // don't round the numbers that we don't touch
+	if err := decoder.Decode(&m); err != nil {
+		return nil, err
+	}
+	for ; version < SchemaVersion; version++ {
+		if err := jsonMigrations[version](m); err != nil {
+			return nil, errwrap.Wrapf(err, "could not migrate from schema version %d", version)
+		}
+	}
+	m["schemaVersion"] = SchemaVersion
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON decodes the json output after migrating it to the current
+// schema version, so that stored outputs from older versions can still be read.
+func (obj *JSONOutput) UnmarshalJSON(b []byte) error {
+	b, err := MigrateJSONOutput(b)
+	if err != nil {
+		return err
+	}
+	type plain JSONOutput // without this method, so that it doesn't recurse
+	return json.Unmarshal(b, (*plain)(obj))
+}
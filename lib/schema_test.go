@@ -0,0 +1,63 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/awslabs/yesiscan/lib"
+)
+
+func TestMigrateJSONOutput(t *testing.T) {
+	// from before the schema was versioned
+	old := `{"program": "yesiscan", "args": ["/tmp/repo/"], "results": {"file:///tmp/repo/main.go": {"spdx": {"licenses": [{"SPDX": "MIT"}], "confidence": 1}}}}`
+	output := &lib.JSONOutput{}
+	if err := json.Unmarshal([]byte(old), output); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if output.SchemaVersion != lib.SchemaVersion {
+		t.Errorf("expected schema version %d, got: %d", lib.SchemaVersion, output.SchemaVersion)
+	}
+	if r := output.Results["file:///tmp/repo/main.go"]["spdx"]; r == nil || r.Licenses[0].SPDX != "MIT" {
+		t.Errorf("unexpected result: %+v", r)
+	}
+
+	// the current version is left alone
+	b, err := json.Marshal(lib.NewJSONOutput(lib.NewOutputFromJSON(output)))
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if again, err := lib.MigrateJSONOutput(b); err != nil || string(again) != string(b) {
+		t.Errorf("the current version changed: %+v", err)
+	}
+
+	// a newer version can't be read
+	if err := json.Unmarshal([]byte(`{"schemaVersion": 1000}`), output); !errors.Is(err, lib.ErrSchemaTooNew) {
+		t.Errorf("expected a schema error, got: %+v", err)
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/awslabs/yesiscan/util/errwrap"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+const (
+	// ScopeDateFormat is the short form of a date that Scope.Since can be
+	// parsed from, which is midnight UTC at the start of that day.
+	ScopeDateFormat = "2006-01-02"
+)
+
+// Scope limits a scan to the files which were changed recently in the history
+// of the git repository that they're in. This is useful when the obligations
+// only apply to the code which was added after some point, such as the start
+// of a contract. A file is in scope if any commit after that point changed it,
+// or if it has uncommitted changes, or isn't committed at all. Only whole files
+// are looked at, so a file with one changed line is scanned in full. The files
+// which aren't in a git repository are always in scope, since we can't tell.
+type Scope struct {
+	// Since is the time after which a file must have been changed to be
+	// in scope. It's compared with the committer time of each commit. If
+	// it is zero, then it isn't used.
+	Since time.Time `json:"since"`
+
+	// SinceCommit is the git revision, such as a commit hash or a tag,
+	// after which a file must have been changed to be in scope. It's the
+	// difference between that commit and the HEAD that counts. If it is
+	// empty, then it isn't used.
+	SinceCommit string `json:"since-commit"`
+}
+
+// ParseScopeSince parses the time for Scope.Since. It can either be a date in
+// the ScopeDateFormat, or a full RFC 3339 time.
+func ParseScopeSince(s string) (time.Time, error) {
+	if t, err := time.Parse(ScopeDateFormat, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time, expected %s or RFC 3339: %s", ScopeDateFormat, s)
+	}
+	return t, nil
+}
+
+// Validate returns an error if the scope is not valid.
+func (obj *Scope) Validate() error {
+	if !obj.Since.IsZero() && obj.SinceCommit != "" {
+		return fmt.Errorf("the scope can't be both since a time and since a commit")
+	}
+	return nil
+}
+
+// Empty returns true if the scope doesn't limit anything.
+func (obj *Scope) Empty() bool {
+	return obj == nil || obj.Since.IsZero() && obj.SinceCommit == ""
+}
+
+// String returns a short description of which files are in scope.
+func (obj *Scope) String() string {
+	if obj.SinceCommit != "" {
+		return fmt.Sprintf("changed since commit %s", obj.SinceCommit)
+	}
+	return fmt.Sprintf("changed since %s", obj.Since.Format(time.RFC3339))
+}
+
+// Changed returns the top directory of the git worktree that this directory is
+// in, and the set of the paths relative to it which are in scope. They use the
+// forward slash. It errors if the directory isn't in a git repository.
+func (obj *Scope) Changed(dir string) (string, map[string]struct{}, error) {
+	repository, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{
+		DetectDotGit: true,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	worktree, err := repository.Worktree()
+	if err != nil {
+		return "", nil, err
+	}
+	top := worktree.Filesystem.Root()
+
+	changed := make(map[string]struct{})
+	head, err := repository.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		head = nil // an empty repository, so everything is new
+	} else if err != nil {
+		return "", nil, err
+	}
+	if head != nil {
+		headCommit, err := repository.CommitObject(head.Hash())
+		if err != nil {
+			return "", nil, err
+		}
+		if obj.SinceCommit != "" {
+			err = scopeSinceCommit(repository, headCommit, obj.SinceCommit, changed)
+		} else {
+			err = scopeSinceTime(repository, headCommit, obj.Since, changed)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	// anything that isn't committed yet is newer than all of the commits
+	status, err := worktree.Status()
+	if err != nil {
+		return "", nil, errwrap.Wrapf(err, "could not get the git status")
+	}
+	for p, x := range status {
+		if x.Worktree != git.Unmodified || x.Staging != git.Unmodified {
+			changed[filepath.ToSlash(p)] = struct{}{}
+		}
+	}
+	return top, changed, nil
+}
+
+// scopeSinceCommit adds the files which differ between this revision and the
+// head commit to the set.
+func scopeSinceCommit(repository *git.Repository, head *object.Commit, revision string, changed map[string]struct{}) error {
+	hash, err := repository.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return errwrap.Wrapf(err, "could not find the commit: %s", revision)
+	}
+	commit, err := repository.CommitObject(*hash)
+	if err != nil {
+		return err
+	}
+	return scopeDiff(commit, head, changed)
+}
+
+// scopeSinceTime adds the files which were changed by each of the commits that
+// were made after this time to the set. The commits are visited from the
+// newest, so we stop at the first one that is too old.
+func scopeSinceTime(repository *git.Repository, head *object.Commit, since time.Time, changed map[string]struct{}) error {
+	commits, err := repository.Log(&git.LogOptions{
+		From:  head.Hash,
+		Order: git.LogOrderCommitterTime,
+	})
+	if err != nil {
+		return err
+	}
+	defer commits.Close()
+	return commits.ForEach(func(commit *object.Commit) error {
+		if !commit.Committer.When.After(since) {
+			return storer.ErrStop
+		}
+		var parent *object.Commit // the first commit adds everything
+		if commit.NumParents() > 0 {
+			p, err := commit.Parent(0)
+			if err != nil {
+				return err
+			}
+			parent = p
+		}
+		return scopeDiff(parent, commit, changed)
+	})
+}
+
+// scopeDiff adds the files which were added or modified between the trees of
+// the two commits to the set. A nil commit is the empty tree.
+func scopeDiff(from, to *object.Commit, changed map[string]struct{}) error {
+	fromTree := &object.Tree{}
+	if from != nil {
+		t, err := from.Tree()
+		if err != nil {
+			return err
+		}
+		fromTree = t
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		return err
+	}
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return err
+	}
+	for _, x := range changes {
+		action, err := x.Action()
+		if err != nil {
+			return err
+		}
+		if action == merkletrie.Delete {
+			continue // it's not here anymore
+		}
+		changed[x.To.Name] = struct{}{}
+	}
+	return nil
+}
+
+// scopeFilter decides which files are in the scope, by looking up the git
+// repository that each of them is in. Each repository is only looked at once.
+// It is safe for concurrent use.
+type scopeFilter struct {
+	scope *Scope
+	logf  func(format string, v ...interface{})
+
+	mutex   *sync.Mutex
+	tops    map[string]string              // dir -> top of worktree or ""
+	changed map[string]map[string]struct{} // top -> changed paths or nil
+}
+
+// newScopeFilter returns a filter for this scope.
+func newScopeFilter(scope *Scope, logf func(format string, v ...interface{})) *scopeFilter {
+	return &scopeFilter{
+		scope:   scope,
+		logf:    logf,
+		mutex:   &sync.Mutex{},
+		tops:    make(map[string]string),
+		changed: make(map[string]map[string]struct{}),
+	}
+}
+
+// InScope returns true if the file at this absolute path is in the scope. The
+// files which aren't in a git repository, or whose repository couldn't be read,
+// are always in scope.
+func (obj *scopeFilter) InScope(p string) bool {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+
+	top := obj.top(filepath.Dir(p))
+	if top == "" {
+		return true
+	}
+	changed, exists := obj.changed[top]
+	if !exists {
+		var err error
+		if _, changed, err = obj.scope.Changed(top); err != nil {
+			obj.logf("scope: everything in %s is in scope: %+v", top, err)
+			changed = nil
+		} else {
+			obj.logf("scope: %d files in %s are %s", len(changed), top, obj.scope)
+		}
+		obj.changed[top] = changed
+	}
+	if changed == nil {
+		return true
+	}
+	rel, err := filepath.Rel(top, p)
+	if err != nil {
+		return true
+	}
+	_, exists = changed[filepath.ToSlash(rel)]
+	return exists
+}
+
+// top returns the top directory of the git worktree that this directory is in,
+// or the empty string if it isn't in one. The caller must hold the mutex.
+func (obj *scopeFilter) top(dir string) string {
+	if top, exists := obj.tops[dir]; exists {
+		return top
+	}
+	top := ""
+	if _, err := os.Lstat(filepath.Join(dir, git.GitDirName)); err == nil {
+		top = dir
+	} else if parent := filepath.Dir(dir); parent != dir {
+		top = obj.top(parent)
+	}
+	obj.tops[dir] = top
+	return top
+}
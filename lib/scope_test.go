@@ -0,0 +1,140 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/safepath"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestScope(t *testing.T) {
+	dir := t.TempDir()
+	repository, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	worktree, err := repository.Worktree()
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	commit := func(name, data string, when time.Time) string {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		if _, err := worktree.Add(name); err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		signature := &object.Signature{Name: "test", Email: "test@example.com", When: when}
+		hash, err := worktree.Commit("add "+name, &git.CommitOptions{
+			Author:    signature,
+			Committer: signature,
+		})
+		if err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		return hash.String()
+	}
+	first := commit("old.go", "// MIT\n", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	commit("new.go", "// MIT\n", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err := os.WriteFile(filepath.Join(dir, "extra.go"), []byte("// MIT\n"), 0600); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	since, err := lib.ParseScopeSince("2021-01-01")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	expected := []string{"extra.go", "new.go"}
+	for _, scope := range []*lib.Scope{{Since: since}, {SinceCommit: first}} {
+		_, changed, err := scope.Changed(dir)
+		if err != nil {
+			t.Errorf("error: %+v", err)
+			continue
+		}
+		names := []string{}
+		for k := range changed {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, expected) {
+			t.Errorf("scope %s: got %v, expected %v", scope, names, expected)
+		}
+	}
+
+	absDir, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	logf := func(format string, v ...interface{}) {
+		t.Logf(format, v...)
+	}
+	core := &lib.Core{
+		Logf:     logf,
+		Backends: []interfaces.Backend{&mitBackend{}},
+		Iterators: []interfaces.Iterator{
+			&iterator.Fs{
+				Logf:   logf,
+				Prefix: absDir,
+				Path:   absDir,
+			},
+		},
+		Scope: &lib.Scope{Since: since},
+	}
+	if err := core.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	results, _, _, err := core.Run(context.Background())
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	root := iterator.FileScheme + absDir.String()
+	for _, name := range expected {
+		if _, exists := results[root+name]; !exists {
+			t.Errorf("missing result for: %s", name)
+		}
+	}
+	if outOfScope := core.OutOfScope(); !reflect.DeepEqual(outOfScope, []string{root + "old.go"}) {
+		t.Errorf("unexpected out of scope: %v", outOfScope)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util"
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+const (
+	// selfTestCasesFile is the embedded list of expected results.
+	selfTestCasesFile = "selftest/cases.json"
+
+	// selfTestCorpusDir is the embedded directory of known license files.
+	selfTestCorpusDir = "selftest/corpus"
+)
+
+// selfTestData is the golden corpus of known license files that every release
+// should detect correctly, along with the list of expected results.
+//
+//go:embed selftest
+var selfTestData embed.FS
+
+// SelfTestCase is a single expected result from the golden corpus.
+type SelfTestCase struct {
+	// Path is the path of the file relative to the root of the corpus.
+	Path string `json:"path"`
+
+	// License is the license ID that we expect to find.
+	License string `json:"license"`
+
+	// Backends is the list of backends which must find the license.
+	Backends []string `json:"backends"`
+}
+
+// SelfTestResult is the outcome of checking one case against one backend.
+type SelfTestResult struct {
+	Backend string
+	Path    string
+	License string
+
+	// Found is the list of licenses that the backend actually found.
+	Found []string
+
+	// Err is nil if the backend found the expected license.
+	Err error
+}
+
+// SelfTestCases returns the list of expected results for the golden corpus.
+func SelfTestCases() ([]*SelfTestCase, error) {
+	data, err := selfTestData.ReadFile(selfTestCasesFile)
+	if err != nil {
+		return nil, err
+	}
+	cases := []*SelfTestCase{}
+	decoder := json.NewDecoder(bytes.NewBuffer(data))
+	if err := decoder.Decode(&cases); err != nil {
+		return nil, errwrap.Wrapf(err, "error decoding self test cases")
+	}
+	return cases, nil
+}
+
+// WriteSelfTestCorpus writes out the golden corpus into the directory. We use
+// real files instead of scanning the embedded filesystem directly, because the
+// backends which run external tools need a path on disk to look at.
+func WriteSelfTestCorpus(dir string, perms *interfaces.Perms) error {
+	corpus, err := fs.Sub(selfTestData, selfTestCorpusDir)
+	if err != nil {
+		return err
+	}
+	return fs.WalkDir(corpus, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		p := filepath.Join(dir, filepath.FromSlash(path))
+		if d.IsDir() {
+			return os.MkdirAll(p, perms.DirMode())
+		}
+		data, err := fs.ReadFile(corpus, path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(p, data, perms.FileMode())
+	})
+}
+
+// CheckSelfTest compares the results of a scan of the golden corpus with the
+// expected cases. Only the enabled backends are checked. The uid function turns
+// a path from a case into the UID that the scan used for that file. The list of
+// outcomes is sorted by backend and then by path.
+func CheckSelfTest(cases []*SelfTestCase, results interfaces.ResultSet, backends map[string]bool, uid func(string) string) []*SelfTestResult {
+	found := make(map[string]map[string][]string) // uid -> backend -> licenses
+	for k, m := range results {
+		found[k] = make(map[string][]string)
+		for backend, result := range m {
+			l := []string{}
+			for _, license := range result.Licenses {
+				l = append(l, license.String())
+			}
+			found[k][backend.String()] = l
+		}
+	}
+
+	outcomes := []*SelfTestResult{}
+	for _, x := range cases {
+		for _, backend := range x.Backends {
+			if enabled, _ := backends[backend]; !enabled {
+				continue
+			}
+			l := found[uid(x.Path)][backend]
+			outcome := &SelfTestResult{
+				Backend: backend,
+				Path:    x.Path,
+				License: x.License,
+				Found:   l,
+			}
+			if !util.StrInList(x.License, l) {
+				outcome.Err = fmt.Errorf("expected %s, found: [%s]", x.License, strings.Join(l, ", "))
+			}
+			outcomes = append(outcomes, outcome)
+		}
+	}
+	sort.SliceStable(outcomes, func(i, j int) bool {
+		if outcomes[i].Backend != outcomes[j].Backend {
+			return outcomes[i].Backend < outcomes[j].Backend
+		}
+		return outcomes[i].Path < outcomes[j].Path
+	})
+	return outcomes
+}
@@ -0,0 +1,64 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/yesiscan/lib"
+)
+
+func TestSelfTestCorpus(t *testing.T) {
+	cases, err := lib.SelfTestCases()
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if len(cases) == 0 {
+		t.Errorf("no self test cases")
+		return
+	}
+
+	dir := t.TempDir()
+	if err := lib.WriteSelfTestCorpus(dir, nil); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	for _, x := range cases {
+		if _, err := os.Stat(filepath.Join(dir, x.Path)); err != nil {
+			t.Errorf("missing corpus file for case: %s", x.Path)
+		}
+		for _, backend := range x.Backends {
+			found := false
+			for _, b := range lib.Backends {
+				found = found || b == backend
+			}
+			if !found {
+				t.Errorf("unknown backend %s in case: %s", backend, x.Path)
+			}
+		}
+	}
+}
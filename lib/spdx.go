@@ -0,0 +1,464 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+const (
+	// SPDXVersion is the version of the SPDX specification that we output.
+	SPDXVersion = "SPDX-2.3"
+
+	// SPDXDataLicense is the license of the SPDX document itself. The
+	// specification requires this one.
+	SPDXDataLicense = "CC0-1.0"
+
+	// SPDXNoAssertion is used for a field that we don't know the value of.
+	SPDXNoAssertion = "NOASSERTION"
+
+	// SPDXNone is used for a field that we know has no value.
+	SPDXNone = "NONE"
+
+	// SPDXDocumentID is the identifier of the document element.
+	SPDXDocumentID = "SPDXRef-DOCUMENT"
+
+	// spdxNamespacePrefix is the prefix of the unique namespace of each
+	// document. It doesn't need to resolve to anything.
+	spdxNamespacePrefix = "https://spdx.org/spdxdocs/"
+)
+
+// SPDXDocument is the part of an SPDX 2.3 document that we generate. It can be
+// marshalled directly into the json form of the specification.
+type SPDXDocument struct {
+	SPDXVersion       string                  `json:"spdxVersion"`
+	DataLicense       string                  `json:"dataLicense"`
+	SPDXID            string                  `json:"SPDXID"`
+	Name              string                  `json:"name"`
+	DocumentNamespace string                  `json:"documentNamespace"`
+	CreationInfo      *SPDXCreationInfo       `json:"creationInfo"`
+	Packages          []*SPDXPackage          `json:"packages"`
+	Files             []*SPDXFile             `json:"files"`
+	Relationships     []*SPDXRelationship     `json:"relationships"`
+	ExtractedLicenses []*SPDXExtractedLicense `json:"hasExtractedLicensingInfos,omitempty"`
+}
+
+// SPDXCreationInfo says who made the document and when.
+type SPDXCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+// SPDXPackage is a package element. We make one for each artifact.
+type SPDXPackage struct {
+	Name                 string                       `json:"name"`
+	SPDXID               string                       `json:"SPDXID"`
+	VersionInfo          string                       `json:"versionInfo,omitempty"`
+	DownloadLocation     string                       `json:"downloadLocation"`
+	FilesAnalyzed        bool                         `json:"filesAnalyzed"`
+	VerificationCode     *SPDXVerificationCode        `json:"packageVerificationCode,omitempty"`
+	Checksums            []*SPDXChecksum              `json:"checksums,omitempty"`
+	LicenseConcluded     string                       `json:"licenseConcluded"`
+	LicenseInfoFromFiles []string                     `json:"licenseInfoFromFiles,omitempty"`
+	LicenseDeclared      string                       `json:"licenseDeclared"`
+	CopyrightText        string                       `json:"copyrightText"`
+	files                []*SPDXFile                  // the files in this package
+	extracted            map[string]*licenses.License // LicenseRef- ID -> license
+}
+
+// SPDXVerificationCode is the combined checksum of all the files in a package.
+type SPDXVerificationCode struct {
+	Value string `json:"packageVerificationCodeValue"`
+}
+
+// SPDXChecksum is a checksum of a file or a package.
+type SPDXChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"checksumValue"`
+}
+
+// SPDXFile is a file element. We make one for each file that we read.
+type SPDXFile struct {
+	FileName           string          `json:"fileName"`
+	SPDXID             string          `json:"SPDXID"`
+	Checksums          []*SPDXChecksum `json:"checksums"`
+	LicenseConcluded   string          `json:"licenseConcluded"`
+	LicenseInfoInFiles []string        `json:"licenseInfoInFiles"`
+	CopyrightText      string          `json:"copyrightText"`
+}
+
+// SPDXRelationship connects two elements of the document.
+type SPDXRelationship struct {
+	Element string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	Related string `json:"relatedSpdxElement"`
+}
+
+// SPDXExtractedLicense describes a license which isn't on the SPDX list, and so
+// got a LicenseRef- ID.
+type SPDXExtractedLicense struct {
+	LicenseID     string `json:"licenseId"`
+	ExtractedText string `json:"extractedText"`
+	Name          string `json:"name"`
+}
+
+// NewSPDXDocument builds an SPDX document with one package for each artifact.
+// Each file that was read is a file in its package, with the licenses that the
+// backends found in it, and a concluded license of all of them together, which
+// includes any that were inferred. The copyright text is never known. Files
+// that weren't read, such as directories, are left out, because every file in
+// an SPDX document must have a checksum.
+func NewSPDXDocument(output *Output) (*SPDXDocument, error) {
+	artifacts := []string{}
+	artifacts = append(artifacts, output.Args...)
+	if len(artifacts) == 0 {
+		artifacts = append(artifacts, "")
+	}
+	files, _ := ArtifactFiles(output, artifacts)
+
+	packages := []*SPDXPackage{}
+	for i, a := range artifacts {
+		name, version := ArtifactCoordinates(output, a)
+		pkg := newSPDXPackage(output, i, name, version, a, "", files[a])
+		pkg.Checksums = spdxChecksums(output, a)
+		packages = append(packages, pkg)
+	}
+
+	name := output.Program
+	if len(packages) == 1 {
+		name = packages[0].Name
+	}
+	return newSPDXDocument(output, name, packages)
+}
+
+// newSPDXDocument wraps the packages in a new document.
+func newSPDXDocument(output *Output, name string, packages []*SPDXPackage) (*SPDXDocument, error) {
+	serial, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	doc := &SPDXDocument{
+		SPDXVersion:       SPDXVersion,
+		DataLicense:       SPDXDataLicense,
+		SPDXID:            SPDXDocumentID,
+		Name:              name,
+		DocumentNamespace: spdxNamespacePrefix + output.Program + "-" + serial,
+		CreationInfo: &SPDXCreationInfo{
+			Created:  time.Now().UTC().Format(time.RFC3339),
+			Creators: []string{fmt.Sprintf("Tool: %s-%s", output.Program, output.Version)},
+		},
+		Packages:      packages,
+		Files:         []*SPDXFile{},
+		Relationships: []*SPDXRelationship{},
+	}
+
+	extracted := make(map[string]*licenses.License)
+	for _, pkg := range packages {
+		doc.Relationships = append(doc.Relationships, &SPDXRelationship{
+			Element: SPDXDocumentID,
+			Type:    "DESCRIBES",
+			Related: pkg.SPDXID,
+		})
+		for _, f := range pkg.files {
+			doc.Files = append(doc.Files, f)
+			doc.Relationships = append(doc.Relationships, &SPDXRelationship{
+				Element: pkg.SPDXID,
+				Type:    "CONTAINS",
+				Related: f.SPDXID,
+			})
+		}
+		for k, v := range pkg.extracted {
+			extracted[k] = v
+		}
+	}
+
+	ids := []string{}
+	for id := range extracted {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		doc.ExtractedLicenses = append(doc.ExtractedLicenses, &SPDXExtractedLicense{
+			LicenseID:     id,
+			ExtractedText: extracted[id].String(),
+			Name:          extracted[id].String(),
+		})
+	}
+
+	return doc, nil
+}
+
+// newSPDXPackage builds the package element and the file elements for a list of
+// UID's. The file names are relative to the root UID, or to the deepest common
+// directory of the files if it is empty.
+func newSPDXPackage(output *Output, index int, name, version, artifact, root string, uids []string) *SPDXPackage {
+	if name == "" {
+		name = SPDXNoAssertion
+	}
+	pkg := &SPDXPackage{
+		Name:             name,
+		SPDXID:           fmt.Sprintf("SPDXRef-Package-%d", index),
+		VersionInfo:      version,
+		DownloadLocation: spdxDownloadLocation(output, artifact),
+		LicenseConcluded: SPDXNoAssertion,
+		LicenseDeclared:  SPDXNoAssertion,
+		CopyrightText:    SPDXNoAssertion,
+		files:            []*SPDXFile{},
+		extracted:        make(map[string]*licenses.License),
+	}
+
+	read := []string{} // the files that we have a checksum for
+	for _, uid := range uids {
+		if _, exists := output.FileHashes[uid]; exists {
+			read = append(read, uid)
+		}
+	}
+	sort.Strings(read)
+	if len(read) == 0 {
+		return pkg // FilesAnalyzed is false
+	}
+	if root == "" {
+		root = commonParentUID(read)
+	}
+	prefix := uidPath(root)
+
+	found := []*licenses.License{}
+	sums := []string{}
+	for i, uid := range read {
+		sum := output.FileHashes[uid]
+		sums = append(sums, sum)
+
+		inFile := []*licenses.License{}
+		concluded := []*licenses.License{}
+		for _, r := range output.Results[uid] {
+			for _, x := range r.Licenses {
+				if !licenses.InList(x, concluded) {
+					concluded = append(concluded, x)
+				}
+				if r.Meta != nil && r.Meta.Inferred {
+					continue
+				}
+				if !licenses.InList(x, inFile) {
+					inFile = append(inFile, x)
+				}
+				if !licenses.InList(x, found) {
+					found = append(found, x)
+				}
+			}
+		}
+
+		f := &SPDXFile{
+			FileName:           "./" + strings.TrimPrefix(strings.TrimPrefix(uidPath(uid), prefix), "/"),
+			SPDXID:             fmt.Sprintf("SPDXRef-File-%d-%d", index, i),
+			Checksums:          []*SPDXChecksum{{Algorithm: "SHA1", Value: sum}},
+			LicenseConcluded:   SPDXNoAssertion,
+			LicenseInfoInFiles: []string{SPDXNone},
+			CopyrightText:      SPDXNoAssertion,
+		}
+		if len(concluded) > 0 {
+			f.LicenseConcluded = spdxExpression(SortedLicenses(concluded), pkg.extracted)
+		}
+		if len(inFile) > 0 {
+			f.LicenseInfoInFiles = spdxIDs(SortedLicenses(inFile), pkg.extracted)
+		}
+		pkg.files = append(pkg.files, f)
+	}
+
+	// The verification code is the sha1 of the sorted file checksums.
+	sort.Strings(sums)
+	h := sha1.Sum([]byte(strings.Join(sums, "")))
+	pkg.FilesAnalyzed = true
+	pkg.VerificationCode = &SPDXVerificationCode{Value: fmt.Sprintf("%x", h)}
+	pkg.LicenseInfoFromFiles = []string{SPDXNone}
+	if len(found) > 0 {
+		pkg.LicenseInfoFromFiles = spdxIDs(SortedLicenses(found), pkg.extracted)
+	}
+
+	return pkg
+}
+
+// spdxIDs returns the SPDX ID of each license, and adds the made up ones to the
+// map of extracted licenses. A LicenseRef- that was found as is in a file is
+// kept, so that it matches what the file says.
+func spdxIDs(ls []*licenses.License, extracted map[string]*licenses.License) []string {
+	ids := []string{}
+	for _, x := range ls {
+		id := scancodeSPDX(x)
+		if strings.HasPrefix(x.Custom, "LicenseRef-") && x.Origin == "" && !scancodeStripRe.MatchString(x.Custom) {
+			id = x.Custom
+		}
+		if x.SPDX == "" {
+			extracted[id] = x
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// spdxExpression returns a license expression which requires all of the
+// licenses, since we found each of them in the file.
+func spdxExpression(ls []*licenses.License, extracted map[string]*licenses.License) string {
+	return strings.Join(spdxIDs(ls, extracted), " AND ")
+}
+
+// spdxChecksums returns the checksum of an artifact, if it was downloaded.
+func spdxChecksums(output *Output, artifact string) []*SPDXChecksum {
+	sum, exists := output.Checksums[artifact]
+	if !exists {
+		return nil
+	}
+	return []*SPDXChecksum{{Algorithm: "SHA256", Value: sum}}
+}
+
+// spdxDownloadLocation returns where an artifact came from in the SPDX format,
+// if we know.
+func spdxDownloadLocation(output *Output, artifact string) string {
+	provenance := ortProvenance(artifact)
+	if pkg, exists := output.OrtPackages[artifact]; exists {
+		_, provenance = pkg.source()
+	}
+	if provenance == nil {
+		return SPDXNoAssertion
+	}
+	if a := provenance.SourceArtifact; a != nil {
+		return a.URL
+	}
+	if vcs := provenance.VcsInfo; vcs != nil {
+		u, revision := vcs.URL, provenance.ResolvedRevision
+		if i := strings.LastIndex(u, "/commit/"); i >= 0 && revision == "" {
+			u, revision = u[:i], u[i+len("/commit/"):]
+		}
+		if !strings.HasPrefix(u, "git+") {
+			u = "git+" + u
+		}
+		if revision != "" {
+			u += "@" + revision
+		}
+		return u
+	}
+	return SPDXNoAssertion
+}
+
+// ReturnOutputSPDX returns a string of output, formatted as an SPDX document in
+// the tag-value format.
+func ReturnOutputSPDX(output *Output) (string, error) {
+	doc, err := NewSPDXDocument(output)
+	if err != nil {
+		return "", err
+	}
+	return returnSPDXTagValue(doc), nil
+}
+
+// ReturnOutputSPDXJSON returns a string of output, formatted as an SPDX document
+// in json.
+func ReturnOutputSPDXJSON(output *Output) (string, error) {
+	doc, err := NewSPDXDocument(output)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+// returnSPDXTagValue formats a document in the tag-value format. Each file is
+// listed right after the package that it is in.
+func returnSPDXTagValue(doc *SPDXDocument) string {
+	b := &strings.Builder{}
+	tag := func(k, v string) {
+		fmt.Fprintf(b, "%s: %s\n", k, v)
+	}
+
+	tag("SPDXVersion", doc.SPDXVersion)
+	tag("DataLicense", doc.DataLicense)
+	tag("SPDXID", doc.SPDXID)
+	tag("DocumentName", doc.Name)
+	tag("DocumentNamespace", doc.DocumentNamespace)
+	for _, x := range doc.CreationInfo.Creators {
+		tag("Creator", x)
+	}
+	tag("Created", doc.CreationInfo.Created)
+
+	for _, pkg := range doc.Packages {
+		b.WriteString("\n")
+		tag("PackageName", pkg.Name)
+		tag("SPDXID", pkg.SPDXID)
+		if pkg.VersionInfo != "" {
+			tag("PackageVersion", pkg.VersionInfo)
+		}
+		tag("PackageDownloadLocation", pkg.DownloadLocation)
+		tag("FilesAnalyzed", fmt.Sprintf("%t", pkg.FilesAnalyzed))
+		if pkg.VerificationCode != nil {
+			tag("PackageVerificationCode", pkg.VerificationCode.Value)
+		}
+		for _, x := range pkg.Checksums {
+			tag("PackageChecksum", x.Algorithm+": "+x.Value)
+		}
+		tag("PackageLicenseConcluded", pkg.LicenseConcluded)
+		for _, x := range pkg.LicenseInfoFromFiles {
+			tag("PackageLicenseInfoFromFiles", x)
+		}
+		tag("PackageLicenseDeclared", pkg.LicenseDeclared)
+		tag("PackageCopyrightText", pkg.CopyrightText)
+
+		for _, f := range pkg.files {
+			b.WriteString("\n")
+			tag("FileName", f.FileName)
+			tag("SPDXID", f.SPDXID)
+			for _, x := range f.Checksums {
+				tag("FileChecksum", x.Algorithm+": "+x.Value)
+			}
+			tag("LicenseConcluded", f.LicenseConcluded)
+			for _, x := range f.LicenseInfoInFiles {
+				tag("LicenseInfoInFile", x)
+			}
+			tag("FileCopyrightText", f.CopyrightText)
+		}
+	}
+
+	if len(doc.Relationships) > 0 {
+		b.WriteString("\n")
+	}
+	for _, x := range doc.Relationships {
+		tag("Relationship", x.Element+" "+x.Type+" "+x.Related)
+	}
+
+	for _, x := range doc.ExtractedLicenses {
+		b.WriteString("\n")
+		tag("LicenseID", x.LicenseID)
+		tag("ExtractedText", "<text>"+x.ExtractedText+"</text>")
+		tag("LicenseName", x.Name)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,106 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestSPDX(t *testing.T) {
+	b := testBackend("spdx")
+	aSum := strings.Repeat("a", 40)
+	bSum := strings.Repeat("b", 40)
+	output := &lib.Output{
+		Program: "yesiscan",
+		Version: "1.0",
+		Args:    []string{"file:///m/"},
+		Results: interfaces.ResultSet{
+			"file:///m/a.go": {
+				b: {Licenses: []*licenses.License{{SPDX: "MIT"}, {SPDX: "Apache-2.0"}}, Confidence: 1.0},
+			},
+			"file:///m/sub/b.go": {
+				b: {Licenses: []*licenses.License{{Custom: "LicenseRef-mine"}}, Confidence: 1.0},
+			},
+		},
+		Passes: []string{"file:///m/", "file:///m/sub/"},
+		FileHashes: map[string]string{
+			"file:///m/a.go":     aSum,
+			"file:///m/sub/b.go": bSum,
+		},
+		Profiles: []string{lib.DefaultProfileName},
+	}
+
+	s, err := lib.ReturnOutputSPDXJSON(output)
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	doc := &lib.SPDXDocument{}
+	if err := json.Unmarshal([]byte(s), doc); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if doc.SPDXVersion != lib.SPDXVersion || len(doc.Packages) != 1 || len(doc.Files) != 2 {
+		t.Fatalf("unexpected document: %s", s)
+	}
+	pkg := doc.Packages[0]
+	code := fmt.Sprintf("%x", sha1.Sum([]byte(aSum+bSum)))
+	if !pkg.FilesAnalyzed || pkg.VerificationCode == nil || pkg.VerificationCode.Value != code {
+		t.Errorf("unexpected verification code: %+v", pkg.VerificationCode)
+	}
+	if f := doc.Files[0]; f.FileName != "./a.go" || f.LicenseConcluded != "Apache-2.0 AND MIT" {
+		t.Errorf("unexpected file: %+v", f)
+	}
+	if f := doc.Files[1]; f.FileName != "./sub/b.go" || f.LicenseInfoInFiles[0] != "LicenseRef-mine" {
+		t.Errorf("unexpected file: %+v", f)
+	}
+	if len(doc.ExtractedLicenses) != 1 || doc.ExtractedLicenses[0].LicenseID != "LicenseRef-mine" {
+		t.Errorf("unexpected extracted licenses: %+v", doc.ExtractedLicenses)
+	}
+	if len(doc.Relationships) != 3 {
+		t.Errorf("expected 3 relationships, got: %d", len(doc.Relationships))
+	}
+
+	s, err = lib.ReturnOutputSPDX(output)
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	for _, x := range []string{
+		"SPDXVersion: SPDX-2.3\n",
+		"PackageVerificationCode: " + code + "\n",
+		"FileName: ./sub/b.go\n",
+		"FileChecksum: SHA1: " + bSum + "\n",
+		"Relationship: SPDXRef-Package-0 CONTAINS SPDXRef-File-0-1\n",
+	} {
+		if !strings.Contains(s, x) {
+			t.Errorf("missing %q in:\n%s", x, s)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+)
+
+const (
+	// TimingSetup is the stage name for the setup of all the backends.
+	TimingSetup = "setup"
+
+	// TimingIteration is the stage name for walking the local filesystem.
+	TimingIteration = "iteration"
+
+	// TimingExtraction is the stage name for decompressing and unpacking
+	// any of the archives that we find.
+	TimingExtraction = "extraction"
+
+	// TimingDownload is the stage name for cloning and downloading remote
+	// sources.
+	TimingDownload = "download"
+
+	// TimingRead is the stage name for reading each file into memory before
+	// it gets passed to the backends.
+	TimingRead = "read"
+
+	// TimingBackendPrefix is the prefix of the stage name for each backend.
+	// The backend name follows it.
+	TimingBackendPrefix = "backend: "
+)
+
+// Timing is the accumulated time spent in one stage of a scan.
+type Timing struct {
+	// Stage is the name of the stage.
+	Stage string `json:"stage"`
+
+	// Count is the number of times this stage ran.
+	Count int `json:"count"`
+
+	// Duration is the total time spent in this stage. The backends run in
+	// parallel, so the sum of all the stages can be more than the time the
+	// whole scan took.
+	Duration time.Duration `json:"duration"`
+
+	// Bytes is the total amount of data that this stage processed, if it
+	// is known.
+	Bytes int64 `json:"bytes,omitempty"`
+}
+
+// Timings collects the time spent in each stage of a scan. It is safe for
+// concurrent use. A nil *Timings is valid and discards everything, so that
+// callers don't need to check if timings are enabled.
+type Timings struct {
+	mu     sync.Mutex
+	stages map[string]*Timing
+}
+
+// Add records that the named stage ran once and took duration d.
+func (obj *Timings) Add(stage string, d time.Duration) {
+	if obj == nil {
+		return
+	}
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	if obj.stages == nil {
+		obj.stages = make(map[string]*Timing)
+	}
+	t, exists := obj.stages[stage]
+	if !exists {
+		t = &Timing{Stage: stage}
+		obj.stages[stage] = t
+	}
+	t.Count++
+	t.Duration += d
+}
+
+// AddBytes records that the named stage processed n more bytes of data. It
+// doesn't change the count.
+func (obj *Timings) AddBytes(stage string, n int64) {
+	if obj == nil {
+		return
+	}
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	if obj.stages == nil {
+		obj.stages = make(map[string]*Timing)
+	}
+	t, exists := obj.stages[stage]
+	if !exists {
+		t = &Timing{Stage: stage}
+		obj.stages[stage] = t
+	}
+	t.Bytes += n
+}
+
+// Since is a helper that records the time elapsed since start for the stage.
+// It is meant to be used with defer.
+func (obj *Timings) Since(stage string, start time.Time) {
+	obj.Add(stage, time.Since(start))
+}
+
+// List returns a copy of the timing of each stage, sorted by the stage name.
+func (obj *Timings) List() []*Timing {
+	if obj == nil {
+		return nil
+	}
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	timings := []*Timing{}
+	for _, t := range obj.stages {
+		x := *t // copy
+		timings = append(timings, &x)
+	}
+	sort.Slice(timings, func(i, j int) bool {
+		return timings[i].Stage < timings[j].Stage
+	})
+	return timings
+}
+
+// TimingStage returns the name of the stage that the time spent in this
+// iterator is counted towards.
+func TimingStage(x interfaces.Iterator) string {
+	switch x.(type) {
+	case *iterator.Fs, *iterator.IOFS:
+		return TimingIteration
+	case *iterator.Git, *iterator.Http:
+		return TimingDownload
+	}
+	return TimingExtraction // zip, tar, gzip, bzip2, and friends
+}
@@ -0,0 +1,56 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awslabs/yesiscan/lib"
+)
+
+func TestTimings(t *testing.T) {
+	var disabled *lib.Timings
+	disabled.Add(lib.TimingRead, time.Second) // must not panic
+	if l := disabled.List(); len(l) != 0 {
+		t.Errorf("nil timings returned: %+v", l)
+	}
+
+	timings := &lib.Timings{}
+	timings.Add(lib.TimingRead, 2*time.Second)
+	timings.Add(lib.TimingBackendPrefix+"spdx", time.Second)
+	timings.Add(lib.TimingRead, 3*time.Second)
+
+	l := timings.List()
+	if len(l) != 2 {
+		t.Errorf("expected 2 stages, got: %d", len(l))
+		return
+	}
+	if s := l[0].Stage; s != lib.TimingBackendPrefix+"spdx" {
+		t.Errorf("unexpected first stage: %s", s)
+	}
+	if l[1].Count != 2 || l[1].Duration != 5*time.Second {
+		t.Errorf("unexpected read timing: %+v", l[1])
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/awslabs/yesiscan/interfaces"
+)
+
+const (
+	// TriageSnippetSize is the maximum number of bytes of content that we
+	// store for each triage entry.
+	TriageSnippetSize = 256
+)
+
+// TriageEntry represents a single file for which no backend could determine a
+// license. These are collected so that a reviewer has a worklist of files that
+// need manual classification.
+type TriageEntry struct {
+	// UID is the unique identifier of the file.
+	UID string `json:"uid"`
+
+	// Size is the size of the file in bytes.
+	Size int64 `json:"size"`
+
+	// Type is the detected content (mime) type of the file.
+	Type string `json:"type"`
+
+	// Snippet is the first few bytes of the file if it is text.
+	Snippet string `json:"snippet"`
+
+	// License is always empty when exported. It exists so that a reviewer
+	// can fill it in with their determination.
+	License string `json:"license"`
+}
+
+// NewTriageEntry builds a triage entry from the data that the scanner has.
+func NewTriageEntry(data []byte, info *interfaces.Info) *TriageEntry {
+	entry := &TriageEntry{
+		UID:  info.UID,
+		Size: info.FileInfo.Size(),
+		Type: http.DetectContentType(data), // looks at 512 bytes at most
+	}
+	if strings.HasPrefix(entry.Type, "text/") {
+		snippet := data
+		if len(snippet) > TriageSnippetSize {
+			snippet = snippet[:TriageSnippetSize]
+			// Don't cut a multi-byte character in half. Only the
+			// end is trimmed, since the text might not be utf-8.
+			for i := 1; i < utf8.UTFMax && i <= len(snippet); i++ {
+				if !utf8.RuneStart(snippet[len(snippet)-i]) {
+					continue
+				}
+				if !utf8.FullRune(snippet[len(snippet)-i:]) {
+					snippet = snippet[:len(snippet)-i]
+				}
+				break
+			}
+		}
+		entry.Snippet = string(snippet)
+	}
+	return entry
+}
+
+// TriageList returns the triage entries for the list of passes in a sorted
+// order. Entries for directories or for paths that weren't recorded are skipped.
+func TriageList(triage map[string]*TriageEntry, passes []string) []*TriageEntry {
+	entries := []*TriageEntry{}
+	for _, x := range passes {
+		entry, exists := triage[x]
+		if !exists {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].UID < entries[j].UID
+	})
+	return entries
+}
+
+// ReturnTriageCSV returns the list of unknown files formatted as CSV.
+func ReturnTriageCSV(output *Output) (string, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	if err := w.Write([]string{"uid", "size", "type", "snippet", "license"}); err != nil {
+		return "", err
+	}
+	for _, x := range output.Triage {
+		record := []string{
+			x.UID,
+			fmt.Sprintf("%d", x.Size),
+			x.Type,
+			x.Snippet,
+			x.License,
+		}
+		if err := w.Write(record); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ReturnTriageJSON returns the list of unknown files formatted as JSON.
+func ReturnTriageJSON(output *Output) (string, error) {
+	entries := output.Triage
+	if entries == nil {
+		entries = []*TriageEntry{} // show an empty list, not null
+	}
+	b, err := json.MarshalIndent(entries, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
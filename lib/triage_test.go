@@ -0,0 +1,78 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+package lib_test
+
+import (
+	"bytes"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+)
+
+func TestNewTriageEntry(t *testing.T) {
+	latin1 := []byte{'c', 'a', 'f', 0xe9, ' '} // not utf-8
+	tests := []struct {
+		name string
+		data []byte
+		exp  string
+	}{
+		{
+			name: "short",
+			data: []byte("package main\n"),
+			exp:  "package main\n",
+		},
+		{
+			name: "cut rune",
+			data: []byte(strings.Repeat("a", lib.TriageSnippetSize-1) + "é"),
+			exp:  strings.Repeat("a", lib.TriageSnippetSize-1),
+		},
+		{
+			name: "whole rune",
+			data: []byte(strings.Repeat("a", lib.TriageSnippetSize-2) + "éb"),
+			exp:  strings.Repeat("a", lib.TriageSnippetSize-2) + "é",
+		},
+		{
+			name: "latin-1",
+			data: bytes.Repeat(latin1, lib.TriageSnippetSize), // more
+			exp:  string(bytes.Repeat(latin1, lib.TriageSnippetSize))[:lib.TriageSnippetSize],
+		},
+	}
+	for _, tt := range tests {
+		fsys := fstest.MapFS{"file": {Data: tt.data}}
+		fileInfo, err := fs.Stat(fsys, "file")
+		if err != nil {
+			t.Fatalf("error: %+v", err)
+		}
+		entry := lib.NewTriageEntry(tt.data, &interfaces.Info{FileInfo: fileInfo, UID: "file"})
+		if !strings.HasPrefix(entry.Type, "text/") {
+			t.Errorf("%s: expected text, got: %s", tt.name, entry.Type)
+		}
+		if entry.Snippet != tt.exp {
+			t.Errorf("%s: exp: %q, got: %q", tt.name, tt.exp, entry.Snippet)
+		}
+	}
+}
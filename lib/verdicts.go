@@ -0,0 +1,374 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/parser"
+	"github.com/awslabs/yesiscan/util"
+	"github.com/awslabs/yesiscan/util/licenses"
+
+	colour "github.com/fatih/color"
+)
+
+const (
+	// VerdictPass means that nothing in the artifact matched the profile.
+	VerdictPass = "pass"
+
+	// VerdictWarn means that nothing matched the profile, but that some of
+	// the scanning errored, so we can't be sure.
+	VerdictWarn = "warn"
+
+	// VerdictFail means that something in the artifact matched the profile.
+	VerdictFail = "fail"
+)
+
+// Verdict is the overall determination for a single artifact (input argument)
+// when looked at through a single profile.
+type Verdict struct {
+	// Artifact is the input argument that this verdict is about.
+	Artifact string `json:"artifact"`
+
+	// Profile is the name of the profile that was used.
+	Profile string `json:"profile"`
+
+	// Verdict is one of pass, warn, or fail.
+	Verdict string `json:"verdict"`
+
+	// Violations is the sorted list of UID's that caused a fail verdict.
+	Violations []string `json:"violations"`
+
+	// Errors is the number of scanning errors seen for this artifact.
+	Errors int `json:"errors"`
+}
+
+// ProfileMatch returns true if this license is one that the profile is looking
+// for. These are the licenses that get highlighted in the reports. A nil
+// profile (the default profile) never matches anything.
+func ProfileMatch(profile *ProfileData, license *licenses.License) bool {
+	if profile == nil {
+		return false
+	}
+	inList := licenses.InList(license, profile.Licenses)
+	return inList && !profile.Exclude || !inList && profile.Exclude
+}
+
+// Artifact returns the input argument that a result came from. It does this by
+// walking up the chain of iterators until it finds the originating parser. It
+// returns the empty string if it can't find one.
+func Artifact(result *interfaces.Result) string {
+	if result == nil || result.Meta == nil || result.Meta.Iterator == nil {
+		return ""
+	}
+	return iteratorArtifact(result.Meta.Iterator)
+}
+
+// iteratorArtifact returns the input argument that the root of the chain of
+// iterators which led to this one came from, or the empty string if it didn't
+// come from a parser.
+func iteratorArtifact(it interfaces.Iterator) string {
+	for it.GetIterator() != nil {
+		it = it.GetIterator()
+	}
+	p := it.GetParser()
+	if p == nil {
+		return ""
+	}
+	if x, ok := p.(*parser.TrivialURIParser); ok {
+		return x.Root().Input // the arg, even if a plugin resolved it
+	}
+	return p.String()
+}
+
+// ArtifactFiles attributes every file in the output to the input argument that
+// it came from. Files without any results are attributed to the same artifact
+// as their nearest parent directory which has some. It returns the sorted list
+// of file UID's for each artifact, and the number of scanning errors for each.
+// Files which can't be attributed are listed under the empty string.
+func ArtifactFiles(output *Output, artifacts []string) (map[string][]string, map[string]int) {
+	// find the artifact for every uid and for all of its parents, so that
+	// we can attribute the files which have no results at all, and do it
+	// in order so that a shared parent always gets the same artifact
+	owners := make(map[string]string) // uid -> artifact
+	errors := make(map[string]int)    // artifact -> count
+	for _, uid := range SortedUIDs(output.Results) {
+		m := output.Results[uid]
+		for _, backend := range SortedResultBackends(m) {
+			result := m[backend]
+			a := Artifact(result)
+			if a == "" {
+				continue
+			}
+			if result.Skip != nil {
+				errors[a]++
+			}
+			for p, ok := uid, true; ok; p, ok = ParentUID(p) {
+				if _, exists := owners[p]; exists {
+					break
+				}
+				owners[p] = a
+			}
+		}
+	}
+	owner := func(uid string) string {
+		for p, ok := uid, true; ok; p, ok = ParentUID(p) {
+			if a, exists := owners[p]; exists {
+				return a
+			}
+		}
+		if len(artifacts) == 1 {
+			return artifacts[0]
+		}
+		return "" // can't attribute it
+	}
+
+	files := make(map[string][]string) // artifact -> uids
+	add := func(uid string) {
+		if strings.HasSuffix(uid, "/") {
+			return // skip directories
+		}
+		a := owner(uid)
+		files[a] = append(files[a], uid)
+	}
+	for uid := range output.Results {
+		add(uid)
+	}
+	for _, uid := range output.Passes {
+		if _, exists := output.Results[uid]; !exists {
+			add(uid)
+		}
+	}
+	for _, uids := range files {
+		sort.Strings(uids)
+	}
+	return files, errors
+}
+
+// Verdicts builds the matrix of artifacts and profiles and returns a verdict for
+// each pair. They are returned in the order of the input arguments and then in
+// the order of the profiles. Warnings that can't be attributed to a particular
+// artifact count as errors for every artifact. If there are REUSE reports, then
+// they are added as one more profile column at the end.
+func Verdicts(output *Output) []*Verdict {
+	artifacts := []string{}
+	artifacts = append(artifacts, output.Args...)
+	if len(artifacts) == 0 {
+		artifacts = append(artifacts, "")
+	}
+
+	violations := make(map[string]map[string]map[string]struct{}) // artifact -> profile -> uid
+	errors := make(map[string]int)                                // artifact -> count
+	for _, a := range artifacts {
+		violations[a] = make(map[string]map[string]struct{})
+		for _, p := range output.Profiles {
+			violations[a][p] = make(map[string]struct{})
+		}
+	}
+
+	for uid, m := range output.Results {
+		for _, result := range m {
+			a := Artifact(result)
+			if _, exists := violations[a]; !exists {
+				if len(artifacts) != 1 {
+					continue // can't attribute it
+				}
+				a = artifacts[0]
+			}
+			if result.Skip != nil {
+				errors[a]++
+			}
+			for _, p := range output.Profiles {
+				profile := output.ProfilesData[p]
+				for _, license := range ElectedLicenses(profile, result) {
+					if ProfileMatch(profile, license) {
+						violations[a][p][uid] = struct{}{}
+						break
+					}
+				}
+			}
+		}
+	}
+
+	verdicts := []*Verdict{}
+	for _, a := range artifacts {
+		for _, p := range output.Profiles {
+			uids := []string{}
+			for uid := range violations[a][p] {
+				uids = append(uids, uid)
+			}
+			sort.Strings(uids)
+
+			verdict := &Verdict{
+				Artifact:   a,
+				Profile:    p,
+				Verdict:    VerdictPass,
+				Violations: uids,
+				Errors:     errors[a] + len(output.Warnings),
+			}
+			if verdict.Errors > 0 {
+				verdict.Verdict = VerdictWarn
+			}
+			if len(uids) > 0 {
+				verdict.Verdict = VerdictFail
+			}
+			verdicts = append(verdicts, verdict)
+		}
+		for _, x := range output.Reuse {
+			if x.Artifact == a {
+				verdicts = append(verdicts, x.ToVerdict())
+			}
+		}
+	}
+
+	return verdicts
+}
+
+// ReturnVerdicts returns the compact artifact by profile verdict matrix as a
+// string. Style can be `ansi`, `html`, or `text`.
+func ReturnVerdicts(verdicts []*Verdict, style string) (string, error) {
+	return returnVerdicts(verdicts, "artifact", style)
+}
+
+// returnVerdicts returns the verdict matrix with the header of the first column
+// set to the name of what the rows are.
+func returnVerdicts(verdicts []*Verdict, header, style string) (string, error) {
+	if style != "ansi" && style != "html" && style != "text" {
+		return "", fmt.Errorf("invalid style: %s", style)
+	}
+
+	artifacts := []string{}
+	profiles := []string{}
+	matrix := make(map[string]map[string]*Verdict)
+	for _, x := range verdicts {
+		if _, exists := matrix[x.Artifact]; !exists {
+			matrix[x.Artifact] = make(map[string]*Verdict)
+			artifacts = append(artifacts, x.Artifact)
+		}
+		if !util.StrInList(x.Profile, profiles) {
+			profiles = append(profiles, x.Profile)
+		}
+		matrix[x.Artifact][x.Profile] = x
+	}
+
+	colourString := func(verdict string) string {
+		if style == "text" {
+			return verdict
+		}
+		if style == "html" {
+			c := "green"
+			if verdict == VerdictWarn {
+				c = "orange"
+			}
+			if verdict == VerdictFail {
+				c = "red"
+			}
+			return fmt.Sprintf(`<span style="color: %s;">%s</span>`, c, verdict)
+		}
+		c := colour.New(colour.FgGreen)
+		if verdict == VerdictWarn {
+			c = colour.New(colour.FgYellow)
+		}
+		if verdict == VerdictFail {
+			c = colour.New(colour.FgRed).Add(colour.Bold)
+		}
+		return c.Sprint(verdict)
+	}
+
+	if style == "html" {
+		s := `<table id="summary">`
+		s += fmt.Sprintf("<tr><th>%s</th>", html.EscapeString(header))
+		for _, p := range profiles {
+			s += fmt.Sprintf("<th>%s</th>", html.EscapeString(p))
+		}
+		s += "</tr>"
+		for _, a := range artifacts {
+			s += fmt.Sprintf("<tr><td>%s</td>", html.EscapeString(a))
+			for _, p := range profiles {
+				v := "" // should always exist
+				if x, exists := matrix[a][p]; exists {
+					v = colourString(x.Verdict)
+				}
+				s += fmt.Sprintf("<td>%s</td>", v)
+			}
+			s += "</tr>"
+		}
+		s += "</table>"
+		return s, nil
+	}
+
+	// compute the column widths first, because we colour after padding so
+	// that the escape sequences don't break the alignment
+	widths := []int{len(header)}
+	for _, p := range profiles {
+		n := len(p)
+		if len(VerdictPass) > n { // all the verdicts are this long
+			n = len(VerdictPass)
+		}
+		widths = append(widths, n)
+	}
+	for _, a := range artifacts {
+		if len(a) > widths[0] {
+			widths[0] = len(a)
+		}
+		for i, p := range profiles {
+			if x, exists := matrix[a][p]; exists && len(x.Verdict) > widths[i+1] {
+				widths[i+1] = len(x.Verdict)
+			}
+		}
+	}
+	pad := func(s string, n int) string {
+		if len(s) > n {
+			n = len(s)
+		}
+		return s + strings.Repeat(" ", n-len(s)+2)
+	}
+
+	str := pad(header, widths[0])
+	for i, p := range profiles {
+		str += pad(p, widths[i+1])
+	}
+	str = strings.TrimRight(str, " ") + "\n"
+	for _, a := range artifacts {
+		line := pad(a, widths[0])
+		for i, p := range profiles {
+			v := "" // should always exist
+			if x, exists := matrix[a][p]; exists {
+				v = x.Verdict
+			}
+			cell := pad(v, widths[i+1])
+			if v != "" {
+				cell = strings.Replace(cell, v, colourString(v), 1)
+			}
+			line += cell
+		}
+		str += strings.TrimRight(line, " ") + "\n"
+	}
+
+	return str, nil
+}
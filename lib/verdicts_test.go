@@ -0,0 +1,128 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/parser"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestReturnVerdicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		verdicts []*lib.Verdict
+		expected string
+	}{
+		{
+			name: "short profile names",
+			verdicts: []*lib.Verdict{
+				{Artifact: "x", Profile: "a", Verdict: lib.VerdictPass},
+				{Artifact: "x", Profile: "bb", Verdict: lib.VerdictFail},
+			},
+			expected: "" +
+				"artifact  a     bb\n" +
+				"x         pass  fail\n",
+		},
+		{
+			name: "long artifact name",
+			verdicts: []*lib.Verdict{
+				{Artifact: "https://github.com/awslabs/yesiscan", Profile: "default", Verdict: lib.VerdictWarn},
+				{Artifact: "y", Profile: "default", Verdict: lib.VerdictPass},
+			},
+			expected: "" +
+				"artifact                             default\n" +
+				"https://github.com/awslabs/yesiscan  warn\n" +
+				"y                                    pass\n",
+		},
+		{
+			name: "profile missing from the first artifact",
+			verdicts: []*lib.Verdict{
+				{Artifact: "x", Profile: "a", Verdict: lib.VerdictPass},
+				{Artifact: "y", Profile: "a", Verdict: lib.VerdictPass},
+				{Artifact: "y", Profile: "reuse", Verdict: lib.VerdictWarn},
+			},
+			expected: "" +
+				"artifact  a     reuse\n" +
+				"x         pass\n" +
+				"y         pass  warn\n",
+		},
+	}
+	for i, tc := range tests {
+		s, err := lib.ReturnVerdicts(tc.verdicts, "text")
+		if err != nil {
+			t.Errorf("test #%d (%s): error: %+v", i, tc.name, err)
+			continue
+		}
+		if s != tc.expected {
+			t.Errorf("test #%d (%s): expected:\n%s\ngot:\n%s", i, tc.name, tc.expected, s)
+		}
+		if _, err := lib.ReturnVerdicts(tc.verdicts, "ansi"); err != nil {
+			t.Errorf("test #%d (%s): error: %+v", i, tc.name, err)
+		}
+	}
+
+	if _, err := lib.ReturnVerdicts(nil, "pdf"); err == nil || !strings.Contains(err.Error(), "invalid style") {
+		t.Errorf("expected an invalid style error, got: %v", err)
+	}
+}
+
+func TestArtifactFiles(t *testing.T) {
+	result := func(artifact string) map[interfaces.Backend]*interfaces.Result {
+		it := &iterator.Fs{
+			Parser: &parser.TrivialURIParser{Input: artifact},
+		}
+		return map[interfaces.Backend]*interfaces.Result{
+			&skipDirBackend{}: {
+				Licenses: []*licenses.License{{SPDX: "MIT"}},
+				Meta:     &interfaces.Meta{Iterator: it},
+			},
+		}
+	}
+	output := &lib.Output{
+		Args: []string{"a", "b"},
+		Results: map[string]map[interfaces.Backend]*interfaces.Result{
+			"file:///src/a/x.go": result("a"),
+			"file:///src/b/y.go": result("b"),
+		},
+		// the parent of this is shared by both of the artifacts
+		Passes: []string{"file:///src/z.go"},
+	}
+	expected := map[string][]string{
+		"a": {"file:///src/a/x.go", "file:///src/z.go"},
+		"b": {"file:///src/b/y.go"},
+	}
+	for i := 0; i < 20; i++ { // the maps are in a random order each time
+		files, _ := lib.ArtifactFiles(output, output.Args)
+		if !reflect.DeepEqual(files, expected) {
+			t.Fatalf("run #%d: expected %+v, got: %+v", i, expected, files)
+		}
+	}
+}
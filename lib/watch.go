@@ -0,0 +1,256 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+const (
+	// DefaultWatchInterval is how often the watched tree is checked for
+	// changes if no interval is specified.
+	DefaultWatchInterval = 2 * time.Second
+)
+
+// WatchStat is what we remember about each file to notice that it changed.
+type WatchStat struct {
+	Size    int64
+	ModTime time.Time
+	Mode    fs.FileMode
+}
+
+// WatchSnapshot maps each file path under the watched root to its stat.
+type WatchSnapshot map[string]WatchStat
+
+// TakeWatchSnapshot walks the tree under root and returns a snapshot of it. The
+// version control directories are skipped, since they change on every commit
+// and are never scanned anyway. If root is a file, then only it is included.
+func TakeWatchSnapshot(root string) (WatchSnapshot, error) {
+	snapshot := make(WatchSnapshot)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path != root {
+				return nil // removed while we were walking
+			}
+			return err
+		}
+		if d.IsDir() {
+			for _, x := range iterator.SkipDirPaths {
+				if path != root && d.Name()+"/" == x {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		snapshot[path] = WatchStat{
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Mode:    info.Mode(),
+		}
+		return nil
+	})
+	return snapshot, err
+}
+
+// Changed returns the sorted list of paths that were added, removed, or
+// modified between the old snapshot and this one.
+func (obj WatchSnapshot) Changed(old WatchSnapshot) []string {
+	changed := []string{}
+	for path, stat := range obj {
+		if x, exists := old[path]; !exists || x != stat {
+			changed = append(changed, path)
+		}
+	}
+	for path := range old {
+		if _, exists := obj[path]; !exists {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Watcher scans a local path, and then checks it for changes every interval.
+// Whenever something changed, it runs the scan again and passes the output to
+// the update function along with the list of changed paths. The scan function
+// should have the result cache enabled, so that only the changed files get
+// sent to the backends again. It never scans while the tree is still
+// changing, so a large checkout or a dependency install only causes a single
+// scan once it settles.
+type Watcher struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	// Path is the local file or directory to watch.
+	Path string
+
+	// Interval is how often to check for changes. If it is zero, then
+	// DefaultWatchInterval is used.
+	Interval time.Duration
+
+	// Scan runs a scan of the path.
+	Scan func(ctx context.Context) (*Output, error)
+
+	// Update is called with the output of each scan and the paths that
+	// changed since the previous one. They are nil for the first scan. If
+	// the scan failed, then the output is nil and the error is set.
+	Update func(output *Output, changed []string, err error)
+}
+
+// Run scans and watches until the context is cancelled. It only returns an
+// error if the path can't be read at all.
+func (obj *Watcher) Run(ctx context.Context) error {
+	interval := obj.Interval
+	if interval == 0 {
+		interval = DefaultWatchInterval
+	}
+	if interval < 0 {
+		return fmt.Errorf("invalid interval: %s", interval)
+	}
+
+	snapshot, err := TakeWatchSnapshot(obj.Path)
+	if err != nil {
+		return errwrap.Wrapf(err, "could not read path")
+	}
+	var changed []string // nil on the first scan
+
+	for {
+		output, err := obj.Scan(ctx)
+		if ctx.Err() != nil {
+			return nil // cancelled mid scan, so nobody is waiting
+		}
+		obj.Update(output, changed, err)
+
+		// wait until something changes, and then until it settles...
+		pending := []string{}
+		for {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return nil
+			}
+			next, err := TakeWatchSnapshot(obj.Path)
+			if err != nil {
+				obj.Logf("could not read path: %+v", err)
+				continue
+			}
+			diff := next.Changed(snapshot)
+			snapshot = next
+			if len(diff) > 0 {
+				if obj.Debug {
+					obj.Logf("%d paths changed, waiting for more", len(diff))
+				}
+				pending = append(pending, diff...)
+				continue
+			}
+			if len(pending) > 0 {
+				break
+			}
+		}
+		changed = uniqueSorted(pending)
+	}
+}
+
+// uniqueSorted returns the sorted list without any duplicates.
+func uniqueSorted(list []string) []string {
+	sort.Strings(list)
+	result := []string{}
+	for i, x := range list {
+		if i > 0 && x == list[i-1] {
+			continue
+		}
+		result = append(result, x)
+	}
+	return result
+}
+
+// ReturnWatchSummary returns the live summary of a watched scan. It shows the
+// verdicts, and then the licenses that were found in each of the paths that
+// changed, so that a developer sees what they just added. The style is either
+// ansi, html, or text.
+func ReturnWatchSummary(output *Output, changed []string, style string) (string, error) {
+	verdicts, err := ReturnVerdicts(output.Verdicts, style)
+	if err != nil {
+		return "", err
+	}
+	if len(changed) == 0 {
+		return verdicts, nil
+	}
+
+	br := "\n"
+	if style == "html" {
+		br = "<br />\n"
+	}
+	s := verdicts
+	s += fmt.Sprintf("changed (%d):%s", len(changed), br)
+	for _, path := range changed {
+		text := "removed"
+		if _, err := os.Lstat(path); err == nil {
+			text = "no license found"
+			if ls := watchLicenses(output, path); len(ls) > 0 {
+				text = licenses.Join(ls)
+			}
+		}
+		if style == "html" {
+			path = html.EscapeString(path)
+			text = html.EscapeString(text)
+		}
+		s += fmt.Sprintf("  %s: %s%s", path, text, br)
+	}
+	return s, nil
+}
+
+// watchLicenses returns the licenses of a local path from every backend.
+func watchLicenses(output *Output, path string) []*licenses.License {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	ls := []*licenses.License{}
+	for _, r := range output.Results[iterator.FileScheme+abs] {
+		for _, x := range r.Licenses {
+			if !licenses.InList(x, ls) {
+				ls = append(ls, x)
+			}
+		}
+	}
+	return SortedLicenses(ls)
+}
@@ -0,0 +1,84 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/awslabs/yesiscan/lib"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	if err := os.WriteFile(a, []byte("a"), 0600); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, ".git"), 0700); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	updates := [][]string{}
+	watcher := &lib.Watcher{
+		Logf:     t.Logf,
+		Path:     dir,
+		Interval: 10 * time.Millisecond,
+		Scan: func(ctx context.Context) (*lib.Output, error) {
+			return &lib.Output{}, nil
+		},
+		Update: func(output *lib.Output, changed []string, err error) {
+			updates = append(updates, changed)
+			switch len(updates) {
+			case 1:
+				// the version control directory is never watched
+				os.WriteFile(filepath.Join(dir, ".git", "index"), []byte("x"), 0600)
+				os.WriteFile(b, []byte("b"), 0600)
+				os.Remove(a)
+			default:
+				cancel()
+			}
+		},
+	}
+	if err := watcher.Run(ctx); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if len(updates) != 2 {
+		t.Fatalf("unexpected number of scans: %d", len(updates))
+	}
+	if updates[0] != nil {
+		t.Errorf("unexpected changes on the first scan: %+v", updates[0])
+	}
+	if expected := []string{a, b}; !reflect.DeepEqual(updates[1], expected) {
+		t.Errorf("expected changes %+v, got: %+v", expected, updates[1])
+	}
+}
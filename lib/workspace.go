@@ -0,0 +1,102 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+const (
+	// WorkspaceDir is the directory under the prefix where each scan gets
+	// its own temporary workspace if that option is enabled.
+	WorkspaceDir = "workspace/"
+)
+
+// WorkspaceCacheDirs are the directories under the prefix which are content
+// addressed, and which are therefore safe to share between scans. They are
+// linked into each workspace so that they survive after it is removed.
+var WorkspaceCacheDirs = []string{
+	"git/", // keyed by url, hash, ref and rev
+}
+
+// NewWorkspace builds a new, empty, per-scan workspace under the prefix. Any
+// iterators which are given this workspace as their prefix will download and
+// extract into it, except for the content addressed caches, which are links to
+// the shared directories. The returned cleanup function removes the workspace
+// and must be called once the results have been collected. The perms are used
+// for any directories that get made.
+func NewWorkspace(prefix safepath.AbsDir, perms *interfaces.Perms) (safepath.AbsDir, func() error, error) {
+	relDir := safepath.UnsafeParseIntoRelDir(WorkspaceDir)
+	workspaces := safepath.JoinToAbsDir(prefix, relDir)
+	if err := os.MkdirAll(workspaces.Path(), perms.DirMode()); err != nil {
+		return safepath.AbsDir{}, nil, err
+	}
+
+	dir, err := os.MkdirTemp(workspaces.Path(), "scan-")
+	if err != nil {
+		return safepath.AbsDir{}, nil, errwrap.Wrapf(err, "could not make workspace")
+	}
+	cleanup := func() error {
+		return os.RemoveAll(dir) // this does not follow the links
+	}
+	if err := os.Chmod(dir, perms.DirMode()); err != nil {
+		return safepath.AbsDir{}, nil, errwrap.Append(err, cleanup())
+	}
+	workspace, err := safepath.ParseIntoAbsDir(dir)
+	if err != nil {
+		return safepath.AbsDir{}, nil, errwrap.Append(err, cleanup())
+	}
+
+	for _, x := range WorkspaceCacheDirs {
+		cacheDir := safepath.JoinToAbsDir(prefix, safepath.UnsafeParseIntoRelDir(x))
+		if err := os.MkdirAll(cacheDir.Path(), perms.DirMode()); err != nil {
+			return safepath.AbsDir{}, nil, errwrap.Append(err, cleanup())
+		}
+		link := filepath.Join(workspace.Path(), strings.TrimSuffix(x, "/"))
+		if err := os.Symlink(cacheDir.Path(), link); err != nil {
+			return safepath.AbsDir{}, nil, errwrap.Append(err, cleanup())
+		}
+	}
+
+	return workspace, cleanup, nil
+}
+
+// WorkspaceUID returns the uid of a file inside of the workspace as if it was
+// inside of the prefix that the workspace was made under instead. The name of
+// each workspace is random, so without this the same input would get different
+// UIDs on every scan. Any other uid is returned unchanged.
+func WorkspaceUID(uid string, workspace, prefix safepath.AbsDir) string {
+	s := iterator.FileScheme + workspace.String()
+	if !strings.HasPrefix(uid, s) {
+		return uid
+	}
+	return iterator.FileScheme + prefix.String() + strings.TrimPrefix(uid, s)
+}
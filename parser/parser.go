@@ -44,7 +44,37 @@ type TrivialURIParser struct {
 	Logf   func(format string, v ...interface{})
 	Prefix safepath.AbsDir
 
+	// Perms is the permission policy for everything that we write. If it
+	// is nil, then the default policy is used.
+	Perms *interfaces.Perms
+
+	// Limiter throttles the downloads of the iterators that we return. If
+	// it is nil, then nothing is throttled.
+	Limiter *iterator.Limiter
+
 	Input string
+
+	// Checksum is the lowercase hex sha256 sum that the input must have.
+	// This is only possible for inputs which get downloaded as a single
+	// archive, so any other input with a checksum is an error.
+	Checksum string
+
+	// Offline forbids any network access. Inputs that would have to be
+	// downloaded are an error, and git repositories are only scanned if
+	// they are already in the local cache.
+	Offline bool
+
+	// Plugins maps a lower case URI scheme to the parser plugin which
+	// parses the inputs with that scheme. They take precedence over the
+	// schemes that we know about.
+	Plugins map[string]PluginFunc
+
+	// Parent is the parser which resolved its input into ours with a
+	// plugin. It is nil for the inputs that we were given directly.
+	Parent *TrivialURIParser
+
+	// depth is the number of parents that we have.
+	depth int
 }
 
 func (obj *TrivialURIParser) String() string {
@@ -71,6 +101,13 @@ func (obj *TrivialURIParser) Parse() ([]interfaces.Iterator, error) {
 		obj.Logf("path: %s", u.Path)
 	}
 
+	if fn, exists := obj.Plugins[strings.ToLower(u.Scheme)]; exists && u.Scheme != "" {
+		if obj.Checksum != "" {
+			return nil, fmt.Errorf("a checksum can't be verified for an input which needs a plugin")
+		}
+		return fn(obj, u)
+	}
+
 	// TODO: consider allowing HttpSchemeRaw as well (with a flag)
 	if strings.ToLower(u.Scheme) == iterator.HttpSchemeRaw {
 		return nil, fmt.Errorf("plain http is currently blocked, did you mean https?")
@@ -78,16 +115,23 @@ func (obj *TrivialURIParser) Parse() ([]interfaces.Iterator, error) {
 
 	// this is a bit of a heuristic, but we'll go with it for now
 	// this is because we get https:// urls that are really github git URI's
-	isTar := strings.HasSuffix(strings.ToLower(s), iterator.TarExtension)
-	if strings.ToLower(u.Scheme) == iterator.HttpsSchemeRaw && (isZip(s) || isGzip(s) || isTar || isBzip2(s)) {
+	// Any file that a registered iterator can open, such as an archive, is
+	// downloaded, and then the fs iterator finds that iterator for it.
+	if strings.ToLower(u.Scheme) == iterator.HttpsSchemeRaw && iterator.LookupExtension(s) != nil {
+		if obj.Offline {
+			return nil, fmt.Errorf("offline: can't download: %s", s)
+		}
 		iterator := &iterator.Http{
 			Debug: obj.Debug,
 			Logf: func(format string, v ...interface{}) {
 				obj.Logf("iterator: "+format, v...)
 			},
 			Prefix:    obj.Prefix,
+			Perms:     obj.Perms,
+			Limiter:   obj.Limiter,
 			URL:       s,     // TODO: pass a *net.URL instead?
 			AllowHttp: false, // allow non-https ?
+			Checksum:  obj.Checksum,
 
 			Parser: obj, // store a handle to the originator
 		}
@@ -95,6 +139,10 @@ func (obj *TrivialURIParser) Parse() ([]interfaces.Iterator, error) {
 		return iterators, nil
 	}
 
+	if obj.Checksum != "" {
+		return nil, fmt.Errorf("a checksum can only be verified for a downloaded archive")
+	}
+
 	if isGit(u) {
 		// TODO: for now, just assume it can only be a git iterator...
 		// Checking if commit hash exists at the end of the URL.
@@ -120,15 +168,40 @@ func (obj *TrivialURIParser) Parse() ([]interfaces.Iterator, error) {
 				obj.Logf("iterator: "+format, v...)
 			},
 			Prefix:        obj.Prefix,
+			Perms:         obj.Perms,
+			Limiter:       obj.Limiter,
 			URL:           s, // TODO: pass a *net.URL instead?
 			TrimGitSuffix: true,
 			Hash:          hash,
+			Offline:       obj.Offline,
 			Parser:        obj, // store a handle to the originator
 		}
 		iterators = append(iterators, iterator)
 		return iterators, nil
 	}
 
+	// an iterator that was registered for this scheme, eg: an artifact store
+	if registration := iterator.LookupScheme(u.Scheme); registration != nil && u.Scheme != "" {
+		settings := &iterator.Settings{
+			Debug: obj.Debug,
+			Logf: func(format string, v ...interface{}) {
+				obj.Logf("iterator: "+format, v...)
+			},
+			Prefix:  obj.Prefix,
+			Perms:   obj.Perms,
+			Limiter: obj.Limiter,
+			Offline: obj.Offline,
+
+			Parser: obj, // store a handle to the originator
+		}
+		it, err := registration.URL(settings, u)
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "could not build %s iterator", registration.Name)
+		}
+		iterators = append(iterators, it)
+		return iterators, nil
+	}
+
 	// path component (absolute or relative, file or dir)
 	if u.Scheme == "" {
 		// XXX: we could auto-detect the dir bit
@@ -158,8 +231,11 @@ func (obj *TrivialURIParser) Parse() ([]interfaces.Iterator, error) {
 			Logf: func(format string, v ...interface{}) {
 				obj.Logf("iterator: "+format, v...)
 			},
-			Prefix: obj.Prefix,
-			Path:   path,
+			Prefix:  obj.Prefix,
+			Perms:   obj.Perms,
+			Limiter: obj.Limiter,
+			Offline: obj.Offline,
+			Path:    path,
 
 			Parser: obj, // store a handle to the originator
 		}
@@ -190,37 +266,3 @@ func isGit(u *url.URL) bool {
 
 	return false
 }
-
-// isZip is a helper method to determine whether a string has a Zip extension
-// suffix.
-func isZip(input string) bool {
-	extensions := []string{iterator.ZipExtension, iterator.JarExtension, iterator.WhlExtension}
-	for _, extension := range extensions {
-		if strings.HasSuffix(strings.ToLower(input), extension) {
-			return true
-		}
-	}
-	return false
-}
-
-// isGzip is a helper method to determine whether a string has a Gzip extension
-// suffix.
-func isGzip(input string) bool {
-	for _, extension := range iterator.GzipExtensions {
-		if strings.HasSuffix(strings.ToLower(input), extension) {
-			return true
-		}
-	}
-	return false
-}
-
-// isBzip2 is a helper method to determine whether a string has a Bzip2
-// extension suffix.
-func isBzip2(input string) bool {
-	for _, extension := range iterator.Bzip2Extensions {
-		if strings.HasSuffix(strings.ToLower(input), extension) {
-			return true
-		}
-	}
-	return false
-}
@@ -0,0 +1,136 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+const (
+	// MaxPluginDepth is the maximum number of times that plugins can
+	// resolve an input into another one that needs a plugin. This stops a
+	// misconfigured plugin from looping forever.
+	MaxPluginDepth = 8
+)
+
+// PluginFunc is a parser plugin which knows how to parse the inputs with a
+// custom scheme, such as a company-internal artifact locator or a code review
+// URL. It gets the parser which is running, so that it can use its settings to
+// build the iterators, or call its Resolve method if the input resolves to
+// something that the parser already understands.
+type PluginFunc func(obj *TrivialURIParser, u *url.URL) ([]interfaces.Iterator, error)
+
+// Resolve parses each of these inputs as if they were given to us instead, and
+// returns all of their iterators. The results are still attributed to our own
+// input.
+func (obj *TrivialURIParser) Resolve(inputs []string) ([]interfaces.Iterator, error) {
+	if obj.depth >= MaxPluginDepth {
+		return nil, fmt.Errorf("too many plugins resolving %s", obj.Input)
+	}
+	iterators := []interfaces.Iterator{}
+	for _, input := range inputs {
+		if obj.Debug {
+			obj.Logf("resolved %s to: %s", obj.Input, input)
+		}
+		p := &TrivialURIParser{
+			Debug:   obj.Debug,
+			Logf:    obj.Logf,
+			Prefix:  obj.Prefix,
+			Perms:   obj.Perms,
+			Limiter: obj.Limiter,
+			Input:   input,
+			Offline: obj.Offline,
+			Plugins: obj.Plugins,
+			Parent:  obj,
+			depth:   obj.depth + 1,
+		}
+		ixs, err := p.Parse()
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "could not parse %s", input)
+		}
+		iterators = append(iterators, ixs...)
+	}
+	return iterators, nil
+}
+
+// Root returns the parser that our input came from before any plugin resolved
+// it. This is the parser itself unless a plugin called Resolve to build it.
+func (obj *TrivialURIParser) Root() *TrivialURIParser {
+	root := obj
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	return root
+}
+
+// ExecPlugin returns a parser plugin which runs an external command with the
+// input as its only argument. The command prints the inputs that it resolves
+// to, one per line, and each of them is then parsed as usual. Empty lines, and
+// lines starting with a # are ignored. Anything that it prints on stderr is
+// logged.
+func ExecPlugin(command string) PluginFunc {
+	return func(obj *TrivialURIParser, u *url.URL) ([]interfaces.Iterator, error) {
+		prog := fmt.Sprintf("%s %s", command, u.String())
+		if obj.Debug {
+			obj.Logf("running: %s", prog)
+		}
+		cmd := exec.Command(command, u.String())
+		stderr := &bytes.Buffer{}
+		cmd.Stderr = stderr
+		out, err := cmd.Output()
+		for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
+			if line != "" {
+				obj.Logf("plugin: %s", line)
+			}
+		}
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "error running: %s", prog)
+		}
+
+		inputs := []string{}
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() {
+			s := strings.TrimSpace(scanner.Text())
+			if s == "" || strings.HasPrefix(s, "#") {
+				continue
+			}
+			inputs = append(inputs, s)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, errwrap.Wrapf(err, "could not read plugin output")
+		}
+		if len(inputs) == 0 {
+			return nil, fmt.Errorf("plugin %s did not resolve %s", command, u.String())
+		}
+		return obj.Resolve(inputs)
+	}
+}
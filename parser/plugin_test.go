@@ -0,0 +1,120 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package parser_test
+
+import (
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/parser"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+func TestPlugins(t *testing.T) {
+	dir := t.TempDir()
+	prefix, err := safepath.ParseIntoAbsDir(dir + "/")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	logf := func(format string, v ...interface{}) {
+		t.Logf("parser: "+format, v...)
+	}
+	p := &parser.TrivialURIParser{
+		Logf:   logf,
+		Prefix: prefix,
+		Input:  "internal://service/build/123",
+		Plugins: map[string]parser.PluginFunc{
+			"internal": func(obj *parser.TrivialURIParser, u *url.URL) ([]interfaces.Iterator, error) {
+				if u.Host != "service" {
+					t.Errorf("unexpected host: %s", u.Host)
+				}
+				return obj.Resolve([]string{dir + "/"})
+			},
+		},
+	}
+	iterators, err := p.Parse()
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if len(iterators) != 1 {
+		t.Fatalf("expected 1 iterator, got: %d", len(iterators))
+	}
+	x, ok := iterators[0].GetParser().(*parser.TrivialURIParser)
+	if !ok || x.Input != dir+"/" || x.Root() != p {
+		t.Errorf("unexpected parser: %+v", iterators[0].GetParser())
+	}
+
+	// this resolves to itself forever
+	echo, err := exec.LookPath("echo")
+	if err != nil {
+		t.Skipf("no echo: %+v", err)
+	}
+	p.Plugins = map[string]parser.PluginFunc{
+		"internal": parser.ExecPlugin(echo),
+	}
+	if _, err := p.Parse(); err == nil || !strings.Contains(err.Error(), "too many plugins") {
+		t.Errorf("expected a depth error, got: %+v", err)
+	}
+
+	script := filepath.Join(dir, "resolve")
+	data := "#!/bin/sh\necho '# resolved'\necho " + dir + "/\n"
+	if err := os.WriteFile(script, []byte(data), 0700); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	p.Plugins = map[string]parser.PluginFunc{
+		"internal": parser.ExecPlugin(script),
+	}
+	if iterators, err := p.Parse(); err != nil || len(iterators) != 1 {
+		t.Errorf("expected 1 iterator, got: %d, %+v", len(iterators), err)
+	}
+}
+
+func TestRegisteredScheme(t *testing.T) {
+	iterator.Register(&iterator.Registration{
+		Name:    "parsertest",
+		Schemes: []string{"parsertest"},
+		URL: func(settings *iterator.Settings, u *url.URL) (interfaces.Iterator, error) {
+			return &iterator.Fs{Logf: settings.Logf, Parser: settings.Parser}, nil
+		},
+	})
+	p := &parser.TrivialURIParser{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf("parser: "+format, v...)
+		},
+		Input: "parsertest://store/artifact/1",
+	}
+	iterators, err := p.Parse()
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if len(iterators) != 1 || iterators[0].GetParser() != p {
+		t.Errorf("unexpected iterators: %+v", iterators)
+	}
+}
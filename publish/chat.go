@@ -0,0 +1,332 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package publish
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+const (
+	// ChatKindSlack is a slack incoming webhook.
+	ChatKindSlack = "slack"
+
+	// ChatKindChime is an amazon chime incoming webhook.
+	ChatKindChime = "chime"
+
+	// ChatTopLicenses is the number of licenses shown in the summary.
+	ChatTopLicenses = 5
+
+	// ChatStateDir is the directory under the program cache directory where
+	// the violations of the previous scans are stored.
+	ChatStateDir = "notify/"
+
+	// ChatMaxViolations is the maximum number of new violations that are
+	// listed for each artifact in the summary.
+	ChatMaxViolations = 10
+)
+
+// ChatOptions are the settings for the chat notifier.
+type ChatOptions struct {
+	// Webhook is the incoming webhook URL to post to.
+	Webhook string `json:"webhook"`
+
+	// Kind is either slack or chime. If it is empty, then it is guessed
+	// from the webhook URL, and it defaults to slack, since that format
+	// is understood by many other chat servers too.
+	Kind string `json:"kind"`
+}
+
+// Validate returns an error if the options are not usable.
+func (obj *ChatOptions) Validate() error {
+	if obj == nil {
+		return fmt.Errorf("empty options")
+	}
+	if obj.Webhook == "" {
+		return fmt.Errorf("empty webhook")
+	}
+	if _, err := url.Parse(obj.Webhook); err != nil {
+		return errwrap.Wrapf(err, "invalid webhook")
+	}
+	if k := obj.Kind; k != "" && k != ChatKindSlack && k != ChatKindChime {
+		return fmt.Errorf("invalid kind: %s", k)
+	}
+	return nil
+}
+
+// kind returns the kind of the webhook.
+func (obj *ChatOptions) kind() string {
+	if obj.Kind != "" {
+		return obj.Kind
+	}
+	if u, err := url.Parse(obj.Webhook); err == nil && strings.HasPrefix(strings.ToLower(u.Host), "hooks.chime.") {
+		return ChatKindChime
+	}
+	return ChatKindSlack
+}
+
+// Chat posts a compact summary of each scan to a chat webhook. It shows the
+// verdict of each artifact, the most common licenses, the violations which are
+// new since the last summary that it posted for that artifact, and a link to
+// the report. To know what is new, it stores the violations it last saw for
+// each webhook and artifact in the state directory. If that is empty, then all
+// of the violations are shown as new.
+type Chat struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	Options *ChatOptions
+
+	// StateDir is where the violations of the previous scans are stored.
+	StateDir string
+
+	// Perms is the permission policy for the state files. If it is nil,
+	// then the default policy is used.
+	Perms *interfaces.Perms
+
+	// Client is the http client to use. If it is nil, then the default one
+	// is used.
+	Client *http.Client
+}
+
+func (obj *Chat) String() string {
+	return "chat"
+}
+
+// Publish posts the summary.
+func (obj *Chat) Publish(ctx context.Context, output *lib.Output, reportURL string) error {
+	if err := obj.Options.Validate(); err != nil {
+		return errwrap.Wrapf(err, "invalid chat options")
+	}
+
+	violations := chatViolations(output)
+	previous := make(map[string][]string) // artifact -> violations
+	for artifact := range violations {
+		old, err := obj.load(artifact)
+		if err != nil {
+			obj.Logf("could not load previous violations of %s: %+v", artifact, err)
+		}
+		previous[artifact] = old
+	}
+
+	text := ChatSummary(output, previous, reportURL)
+	var in interface{}
+	if obj.Options.kind() == ChatKindChime {
+		in = map[string]string{"Content": "/md " + text}
+	} else {
+		in = map[string]string{"text": text}
+	}
+	if err := doJSON(ctx, obj.Client, http.MethodPost, obj.Options.Webhook, nil, in, nil); err != nil {
+		return errwrap.Wrapf(err, "could not post summary")
+	}
+
+	for artifact, uids := range violations {
+		if err := obj.store(artifact, uids); err != nil {
+			obj.Logf("could not store violations of %s: %+v", artifact, err)
+		}
+	}
+	return nil
+}
+
+// statePath returns the path of the state file for an artifact.
+func (obj *Chat) statePath(artifact string) string {
+	sum := sha256.Sum256([]byte(obj.Options.Webhook + "\x00" + artifact))
+	return filepath.Join(obj.StateDir, fmt.Sprintf("%x.json", sum))
+}
+
+// load returns the violations that we saw last time, or nil if we don't know.
+func (obj *Chat) load(artifact string) ([]string, error) {
+	if obj.StateDir == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(obj.statePath(artifact))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	uids := []string{}
+	if err := json.Unmarshal(b, &uids); err != nil {
+		return nil, err
+	}
+	return uids, nil
+}
+
+// store saves the violations that we saw this time.
+func (obj *Chat) store(artifact string, uids []string) error {
+	if obj.StateDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(obj.StateDir, obj.Perms.DirMode()); err != nil {
+		return err
+	}
+	b, err := json.Marshal(uids)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(obj.statePath(artifact), b, obj.Perms.FileMode())
+}
+
+// chatViolations returns the sorted list of violations of each artifact in
+// every profile.
+func chatViolations(output *lib.Output) map[string][]string {
+	verdicts := output.Verdicts
+	if verdicts == nil {
+		verdicts = lib.Verdicts(output)
+	}
+	sets := make(map[string]map[string]struct{})
+	for _, x := range verdicts {
+		if _, exists := sets[x.Artifact]; !exists {
+			sets[x.Artifact] = make(map[string]struct{})
+		}
+		for _, uid := range x.Violations {
+			sets[x.Artifact][uid] = struct{}{}
+		}
+	}
+	result := make(map[string][]string)
+	for artifact, set := range sets {
+		uids := []string{}
+		for uid := range set {
+			uids = append(uids, uid)
+		}
+		sort.Strings(uids)
+		result[artifact] = uids
+	}
+	return result
+}
+
+// ChatSummary returns the compact markdown summary of a scan. The previous map
+// holds the violations of each artifact from the last scan. Any violation not
+// in there is listed as new. If an artifact has no previous entry, then all of
+// its violations are new.
+func ChatSummary(output *lib.Output, previous map[string][]string, reportURL string) string {
+	verdicts := output.Verdicts
+	if verdicts == nil {
+		verdicts = lib.Verdicts(output)
+	}
+	violations := chatViolations(output)
+
+	artifacts := []string{}
+	byArtifact := make(map[string][]*lib.Verdict)
+	for _, x := range verdicts {
+		if _, exists := byArtifact[x.Artifact]; !exists {
+			artifacts = append(artifacts, x.Artifact)
+		}
+		byArtifact[x.Artifact] = append(byArtifact[x.Artifact], x)
+	}
+
+	s := fmt.Sprintf("*%s* scan finished\n", output.Program)
+	for _, a := range artifacts {
+		overall := lib.VerdictPass
+		profiles := []string{}
+		for _, x := range byArtifact[a] {
+			if x.Verdict == lib.VerdictFail || (x.Verdict == lib.VerdictWarn && overall == lib.VerdictPass) {
+				overall = x.Verdict
+			}
+			profiles = append(profiles, fmt.Sprintf("%s: %s", x.Profile, x.Verdict))
+		}
+		name := a
+		if name == "" {
+			name = "(unknown)"
+		}
+		s += fmt.Sprintf("• `%s`: *%s* (%s)\n", name, overall, strings.Join(profiles, ", "))
+
+		old := make(map[string]struct{})
+		for _, uid := range previous[a] {
+			old[uid] = struct{}{}
+		}
+		fresh := []string{}
+		for _, uid := range violations[a] {
+			if _, exists := old[uid]; !exists {
+				fresh = append(fresh, uid)
+			}
+		}
+		if len(fresh) == 0 {
+			continue
+		}
+		s += fmt.Sprintf("    new violations (%d):\n", len(fresh))
+		for i, uid := range fresh {
+			if i == ChatMaxViolations {
+				s += fmt.Sprintf("    … and %d more\n", len(fresh)-i)
+				break
+			}
+			s += fmt.Sprintf("    ◦ `%s`\n", uid)
+		}
+	}
+
+	if top := chatTopLicenses(output); len(top) > 0 {
+		s += fmt.Sprintf("top licenses: %s\n", strings.Join(top, ", "))
+	}
+	if reportURL != "" {
+		s += fmt.Sprintf("report: %s\n", reportURL)
+	}
+	return s
+}
+
+// chatTopLicenses returns the most common licenses with the number of files
+// that they were found in.
+func chatTopLicenses(output *lib.Output) []string {
+	counts := make(map[string]int)
+	for _, m := range output.Results {
+		seen := make(map[string]struct{})
+		for _, result := range m {
+			for _, license := range result.Licenses {
+				seen[license.String()] = struct{}{}
+			}
+		}
+		for x := range seen {
+			counts[x]++
+		}
+	}
+	names := []string{}
+	for x := range counts {
+		names = append(names, x)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	top := []string{}
+	for i, x := range names {
+		if i == ChatTopLicenses {
+			break
+		}
+		top = append(top, fmt.Sprintf("%s (%d)", x, counts[x]))
+	}
+	return top
+}
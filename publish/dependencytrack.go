@@ -0,0 +1,123 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package publish
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+// DependencyTrackOptions are the settings for the Dependency-Track publisher.
+type DependencyTrackOptions struct {
+	// URL is the base URL of the Dependency-Track api server.
+	URL string `json:"url"`
+
+	// APIKey is the key of a team with the BOM_UPLOAD permission, and also
+	// PROJECT_CREATION_UPLOAD if the project doesn't exist yet.
+	APIKey string `json:"api-key"`
+
+	// Project is the name of the project to upload to. It gets created if
+	// it doesn't exist.
+	Project string `json:"project"`
+
+	// Version is the version of the project to upload to.
+	Version string `json:"version"`
+}
+
+// Validate returns an error if the options are not usable.
+func (obj *DependencyTrackOptions) Validate() error {
+	if obj == nil {
+		return fmt.Errorf("empty options")
+	}
+	if obj.URL == "" {
+		return fmt.Errorf("empty url")
+	}
+	if obj.APIKey == "" {
+		return fmt.Errorf("empty api key")
+	}
+	if obj.Project == "" {
+		return fmt.Errorf("empty project")
+	}
+	return nil
+}
+
+// DependencyTrack uploads a CycloneDX bom of the scan to a Dependency-Track
+// server. The server processes the bom asynchronously, so a successful upload
+// doesn't mean that it has been processed yet.
+type DependencyTrack struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	Options *DependencyTrackOptions
+
+	// Client is the http client to use. If it is nil, then the default one
+	// is used.
+	Client *http.Client
+}
+
+func (obj *DependencyTrack) String() string {
+	return "dependency-track"
+}
+
+// Publish uploads the bom. The report URL is not used.
+func (obj *DependencyTrack) Publish(ctx context.Context, output *lib.Output, reportURL string) error {
+	if err := obj.Options.Validate(); err != nil {
+		return errwrap.Wrapf(err, "invalid dependency-track options")
+	}
+
+	bom, err := lib.ReturnOutputCycloneDX(output)
+	if err != nil {
+		return errwrap.Wrapf(err, "could not build bom")
+	}
+
+	in := &struct {
+		ProjectName    string `json:"projectName"`
+		ProjectVersion string `json:"projectVersion"`
+		AutoCreate     bool   `json:"autoCreate"`
+		Bom            string `json:"bom"`
+	}{
+		ProjectName:    obj.Options.Project,
+		ProjectVersion: obj.Options.Version,
+		AutoCreate:     true,
+		Bom:            base64.StdEncoding.EncodeToString([]byte(bom)),
+	}
+	out := &struct {
+		Token string `json:"token"`
+	}{}
+	headers := map[string]string{
+		"X-Api-Key": obj.Options.APIKey,
+	}
+	u := strings.TrimSuffix(obj.Options.URL, "/") + "/api/v1/bom"
+	if err := doJSON(ctx, obj.Client, http.MethodPut, u, headers, in, out); err != nil {
+		return errwrap.Wrapf(err, "could not upload bom")
+	}
+	obj.Logf("uploaded bom to project %s, token: %s", obj.Options.Project, out.Token)
+	return nil
+}
@@ -0,0 +1,320 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package publish
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+const (
+	// EmailTLSPort is the smtp port which expects tls from the start. The
+	// other ports start in plain text and upgrade with STARTTLS.
+	EmailTLSPort = "465"
+
+	// EmailReportName is the file name of the attached report.
+	EmailReportName = "report.html"
+)
+
+// EmailOptions are the settings for the email sender.
+type EmailOptions struct {
+	// Server is the host and port of the smtp server, eg:
+	// smtp.example.com:587 or for Amazon SES, something like:
+	// email-smtp.us-east-1.amazonaws.com:587.
+	Server string `json:"server"`
+
+	// Username is the smtp user name. If it is empty, then we don't log
+	// in. For SES, these are the smtp credentials, not the api keys.
+	Username string `json:"username"`
+
+	// Password is the smtp password.
+	Password string `json:"password"`
+
+	// From is the address that the email is sent from.
+	From string `json:"from"`
+
+	// To are the addresses that the email is sent to.
+	To []string `json:"to"`
+
+	// Attach adds the rendered html report to the email. Otherwise only
+	// the summary and the link to the report are sent.
+	Attach bool `json:"attach"`
+}
+
+// Validate returns an error if the options are not usable.
+func (obj *EmailOptions) Validate() error {
+	if obj == nil {
+		return fmt.Errorf("empty options")
+	}
+	if _, _, err := net.SplitHostPort(obj.Server); err != nil {
+		return errwrap.Wrapf(err, "invalid server")
+	}
+	if _, err := mail.ParseAddress(obj.From); err != nil {
+		return errwrap.Wrapf(err, "invalid from address")
+	}
+	if len(obj.To) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+	for _, x := range obj.To {
+		if _, err := mail.ParseAddress(x); err != nil {
+			return errwrap.Wrapf(err, "invalid recipient")
+		}
+	}
+	return nil
+}
+
+// Email sends a summary of each scan to a list of recipients over smtp, along
+// with a link to the report if there is one. It can also attach the rendered
+// report, which is useful when there is nowhere to link to.
+type Email struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	Options *EmailOptions
+
+	// Render returns the html report to attach. It must be set if the
+	// Attach option is used.
+	Render func(output *lib.Output) (string, error)
+
+	// Redact is applied to the subject and to the summary, so that they
+	// don't show what a redacted report hides. If it is nil, then they are
+	// sent as they are.
+	Redact func(s string) string
+}
+
+func (obj *Email) String() string {
+	return "email"
+}
+
+// Publish sends the email.
+func (obj *Email) Publish(ctx context.Context, output *lib.Output, reportURL string) error {
+	if err := obj.Options.Validate(); err != nil {
+		return errwrap.Wrapf(err, "invalid email options")
+	}
+	attachment := ""
+	if obj.Options.Attach {
+		if obj.Render == nil {
+			return fmt.Errorf("no way to render the report")
+		}
+		s, err := obj.Render(output)
+		if err != nil {
+			return errwrap.Wrapf(err, "could not render the report")
+		}
+		attachment = s
+	}
+
+	msg, err := EmailMessage(obj.Options, output, reportURL, attachment, obj.Redact)
+	if err != nil {
+		return err
+	}
+	if err := obj.send(ctx, msg); err != nil {
+		return errwrap.Wrapf(err, "could not send email")
+	}
+	if obj.Debug {
+		obj.Logf("sent to %s", strings.Join(obj.Options.To, ", "))
+	}
+	return nil
+}
+
+// send delivers the message to the smtp server. It honours the deadline of the
+// context, since the smtp package doesn't take one.
+func (obj *Email) send(ctx context.Context, msg []byte) error {
+	host, port, err := net.SplitHostPort(obj.Options.Server)
+	if err != nil {
+		return err
+	}
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", obj.Options.Server)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now()) // unblock everything
+		case <-done:
+		}
+	}()
+	config := &tls.Config{ServerName: host}
+	if port == EmailTLSPort {
+		conn = tls.Client(conn, config)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok && port != EmailTLSPort {
+		if err := client.StartTLS(config); err != nil {
+			return err
+		}
+	}
+	if obj.Options.Username != "" {
+		// This refuses to send the password without tls, except to a
+		// server on localhost.
+		auth := smtp.PlainAuth("", obj.Options.Username, obj.Options.Password, host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	from, _ := mail.ParseAddress(obj.Options.From) // validated
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, x := range obj.Options.To {
+		to, _ := mail.ParseAddress(x)
+		if err := client.Rcpt(to.Address); err != nil {
+			return errwrap.Wrapf(err, "recipient %s was refused", to.Address)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// EmailSubject returns the subject line for the email about a scan, which has
+// the overall verdict so that it can be seen at a glance in an inbox.
+func EmailSubject(output *lib.Output) string {
+	verdicts := output.Verdicts
+	if verdicts == nil {
+		verdicts = lib.Verdicts(output)
+	}
+	overall := lib.VerdictPass
+	artifacts := make(map[string]struct{})
+	for _, x := range verdicts {
+		artifacts[x.Artifact] = struct{}{}
+		if x.Verdict == lib.VerdictFail || (x.Verdict == lib.VerdictWarn && overall == lib.VerdictPass) {
+			overall = x.Verdict
+		}
+	}
+	name := strings.Join(output.Args, ", ")
+	if len(artifacts) > 1 || name == "" {
+		name = fmt.Sprintf("%d artifacts", len(artifacts))
+	}
+	return fmt.Sprintf("%s: %s: %s", output.Program, overall, name)
+}
+
+// EmailMessage returns the whole email about a scan, with the headers. The body
+// is the same summary that goes to a chat room, and since we don't remember the
+// previous scans here, all of the violations are listed as new. If the
+// attachment is not empty, then it's added as the html report. If redact is not
+// nil, then it's applied to the subject and to the summary.
+func EmailMessage(options *EmailOptions, output *lib.Output, reportURL, attachment string, redact func(s string) string) ([]byte, error) {
+	if redact == nil {
+		redact = func(s string) string { return s }
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	domain := "localhost"
+	if from, err := mail.ParseAddress(options.From); err == nil {
+		if i := strings.LastIndex(from.Address, "@"); i >= 0 {
+			domain = from.Address[i+1:]
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	writer := multipart.NewWriter(buf)
+	headers := []string{
+		fmt.Sprintf("From: %s", options.From),
+		fmt.Sprintf("To: %s", strings.Join(options.To, ", ")),
+		fmt.Sprintf("Subject: %s", mime.QEncoding.Encode("utf-8", redact(EmailSubject(output)))),
+		fmt.Sprintf("Date: %s", time.Now().Format(time.RFC1123Z)),
+		fmt.Sprintf("Message-ID: <%x@%s>", b, domain),
+		"MIME-Version: 1.0",
+		fmt.Sprintf("Content-Type: multipart/mixed; boundary=%s", writer.Boundary()),
+	}
+	buf.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	text := redact(ChatSummary(output, nil, reportURL))
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write([]byte(wrap76(base64.StdEncoding.EncodeToString([]byte(text))))); err != nil {
+		return nil, err
+	}
+
+	if attachment != "" {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"text/html; charset=utf-8"},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {fmt.Sprintf("attachment; filename=%s", EmailReportName)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write([]byte(wrap76(base64.StdEncoding.EncodeToString([]byte(attachment))))); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// wrap76 splits base64 data into the lines of at most 76 characters that email
+// requires.
+func wrap76(s string) string {
+	lines := []string{}
+	for len(s) > 76 {
+		lines = append(lines, s[:76])
+		s = s[76:]
+	}
+	lines = append(lines, s)
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
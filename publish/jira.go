@@ -0,0 +1,346 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package publish
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+const (
+	// JiraDefaultIssueType is the type of issue we create if none is set.
+	JiraDefaultIssueType = "Task"
+
+	// JiraDefaultSummaryTemplate is the default template for the summary
+	// of a ticket.
+	JiraDefaultSummaryTemplate = `{{ .Program }}: license policy violation in {{ .Artifact }}`
+
+	// JiraDefaultDescriptionTemplate is the default template for the
+	// description of a ticket. It is in the jira wiki markup format.
+	JiraDefaultDescriptionTemplate = `{{ .Program }} found license policy violations in {{ .Artifact }}.
+{{ range .Profiles }}
+h3. profile {{ .Profile }}
+{{ range .Violations }}* {{ .UID }}{{ if .Licenses }}: {{ .Licenses }}{{ end }}
+{{ end }}{{ end }}{{ if .ReportURL }}
+Full report: {{ .ReportURL }}
+{{ end }}`
+
+	// jiraLabelLength is the number of hex characters of the artifact hash
+	// that are used in the label which identifies a ticket.
+	jiraLabelLength = 16
+)
+
+// JiraOptions are the settings for the Jira publisher.
+type JiraOptions struct {
+	// URL is the base URL of the Jira server.
+	URL string `json:"url"`
+
+	// Project is the key of the project to open tickets in.
+	Project string `json:"project"`
+
+	// IssueType is the name of the type of issue to open. If it is empty,
+	// then JiraDefaultIssueType is used.
+	IssueType string `json:"issue-type"`
+
+	// User is the user to authenticate as with the Token as the password,
+	// which is how Jira cloud api tokens work. If it is empty, then the
+	// Token is used as a bearer token, which is how Jira server and data
+	// center personal access tokens work.
+	User string `json:"user"`
+
+	// Token is the api token or personal access token.
+	Token string `json:"token"`
+
+	// ReportURL is the link to the full report to put in the tickets if
+	// the publisher isn't given one, such as when it wasn't uploaded.
+	ReportURL string `json:"report-url"`
+
+	// SummaryTemplate is a golang text/template for the ticket summary. If
+	// it is empty, then JiraDefaultSummaryTemplate is used.
+	SummaryTemplate string `json:"summary-template"`
+
+	// DescriptionTemplate is a golang text/template for the description.
+	// If it is empty, then JiraDefaultDescriptionTemplate is used.
+	DescriptionTemplate string `json:"description-template"`
+}
+
+// Validate returns an error if the options are not usable.
+func (obj *JiraOptions) Validate() error {
+	if obj == nil {
+		return fmt.Errorf("empty options")
+	}
+	if obj.URL == "" {
+		return fmt.Errorf("empty url")
+	}
+	if obj.Project == "" {
+		return fmt.Errorf("empty project")
+	}
+	if obj.Token == "" {
+		return fmt.Errorf("empty token")
+	}
+	if _, _, err := obj.templates(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// templates parses the summary and description templates.
+func (obj *JiraOptions) templates() (*template.Template, *template.Template, error) {
+	summary := obj.SummaryTemplate
+	if summary == "" {
+		summary = JiraDefaultSummaryTemplate
+	}
+	description := obj.DescriptionTemplate
+	if description == "" {
+		description = JiraDefaultDescriptionTemplate
+	}
+	s, err := template.New("summary").Parse(summary)
+	if err != nil {
+		return nil, nil, errwrap.Wrapf(err, "invalid summary template")
+	}
+	d, err := template.New("description").Parse(description)
+	if err != nil {
+		return nil, nil, errwrap.Wrapf(err, "invalid description template")
+	}
+	return s, d, nil
+}
+
+// JiraTicket is the data that is passed to the ticket templates.
+type JiraTicket struct {
+	Program  string
+	Version  string
+	Artifact string
+
+	// Label is the label that identifies the tickets of this artifact.
+	Label string
+
+	// ReportURL is the link to the full report. It may be empty.
+	ReportURL string
+
+	// Profiles is the list of profiles that had a fail verdict.
+	Profiles []*JiraProfile
+}
+
+// JiraProfile is a profile that failed along with its violations.
+type JiraProfile struct {
+	Profile    string
+	Violations []*JiraViolation
+}
+
+// JiraViolation is a single file that caused a fail verdict.
+type JiraViolation struct {
+	UID string
+
+	// Licenses is the list of licenses that were found in the file, joined
+	// into a string.
+	Licenses string
+}
+
+// JiraLabel returns the label that we use to find the ticket of an artifact.
+func JiraLabel(program, artifact string) string {
+	sum := sha256.Sum256([]byte(artifact))
+	return fmt.Sprintf("%s-%x", program, sum)[:len(program)+1+jiraLabelLength]
+}
+
+// Jira opens a ticket for each artifact which has a fail verdict in any of the
+// profiles. If there is already an unresolved ticket for that artifact, then it
+// gets updated with the new findings instead. The tickets are found by a label
+// which is made from a hash of the artifact. Artifacts which don't fail are
+// left alone, even if they have a ticket.
+type Jira struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	Options *JiraOptions
+
+	// Client is the http client to use. If it is nil, then the default one
+	// is used.
+	Client *http.Client
+}
+
+func (obj *Jira) String() string {
+	return "jira"
+}
+
+// Tickets returns the ticket data for each violating artifact, in the order of
+// the input arguments. If the report URL is empty, then the one from the
+// options is used.
+func (obj *Jira) Tickets(output *lib.Output, reportURL string) []*JiraTicket {
+	if reportURL == "" {
+		reportURL = obj.Options.ReportURL
+	}
+
+	verdicts := output.Verdicts
+	if verdicts == nil {
+		verdicts = lib.Verdicts(output)
+	}
+
+	tickets := []*JiraTicket{}
+	index := make(map[string]*JiraTicket) // artifact -> ticket
+	for _, x := range verdicts {
+		if x.Verdict != lib.VerdictFail {
+			continue
+		}
+		ticket, exists := index[x.Artifact]
+		if !exists {
+			ticket = &JiraTicket{
+				Program:   output.Program,
+				Version:   output.Version,
+				Artifact:  x.Artifact,
+				Label:     JiraLabel(output.Program, x.Artifact),
+				ReportURL: reportURL,
+			}
+			index[x.Artifact] = ticket
+			tickets = append(tickets, ticket)
+		}
+		profile := &JiraProfile{
+			Profile: x.Profile,
+		}
+		for _, uid := range x.Violations {
+			ls := []*licenses.License{}
+			for _, result := range output.Results[uid] {
+				for _, license := range result.Licenses {
+					if !licenses.InList(license, ls) {
+						ls = append(ls, license)
+					}
+				}
+			}
+			profile.Violations = append(profile.Violations, &JiraViolation{
+				UID:      uid,
+				Licenses: licenses.Join(lib.SortedLicenses(ls)),
+			})
+		}
+		ticket.Profiles = append(ticket.Profiles, profile)
+	}
+	return tickets
+}
+
+// Publish opens or updates the tickets.
+func (obj *Jira) Publish(ctx context.Context, output *lib.Output, reportURL string) error {
+	if err := obj.Options.Validate(); err != nil {
+		return errwrap.Wrapf(err, "invalid jira options")
+	}
+	summary, description, err := obj.Options.templates()
+	if err != nil {
+		return err
+	}
+
+	for _, ticket := range obj.Tickets(output, reportURL) {
+		s := &bytes.Buffer{}
+		if err := summary.Execute(s, ticket); err != nil {
+			return errwrap.Wrapf(err, "could not render summary")
+		}
+		d := &bytes.Buffer{}
+		if err := description.Execute(d, ticket); err != nil {
+			return errwrap.Wrapf(err, "could not render description")
+		}
+		// summaries can't contain newlines
+		title := strings.Join(strings.Fields(s.String()), " ")
+
+		key, err := obj.publish(ctx, ticket.Label, title, d.String())
+		if err != nil {
+			return errwrap.Wrapf(err, "could not publish ticket for %s", ticket.Artifact)
+		}
+		obj.Logf("ticket %s for %s", key, ticket.Artifact)
+	}
+	return nil
+}
+
+// headers returns the authentication headers.
+func (obj *Jira) headers() map[string]string {
+	if obj.Options.User == "" {
+		return map[string]string{
+			"Authorization": "Bearer " + obj.Options.Token,
+		}
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte(obj.Options.User + ":" + obj.Options.Token))
+	return map[string]string{
+		"Authorization": "Basic " + auth,
+	}
+}
+
+// publish updates the open ticket with this label, or creates a new one. It
+// returns the key of the ticket.
+func (obj *Jira) publish(ctx context.Context, label, summary, description string) (string, error) {
+	base := strings.TrimSuffix(obj.Options.URL, "/") + "/rest/api/2"
+	headers := obj.headers()
+
+	jql := fmt.Sprintf(`project = "%s" AND labels = "%s" AND statusCategory != Done ORDER BY created DESC`, obj.Options.Project, label)
+	search := &struct {
+		Issues []struct {
+			Key string `json:"key"`
+		} `json:"issues"`
+	}{}
+	u := base + "/search?maxResults=1&fields=key&jql=" + url.QueryEscape(jql)
+	if err := doJSON(ctx, obj.Client, http.MethodGet, u, headers, nil, search); err != nil {
+		return "", errwrap.Wrapf(err, "could not search issues")
+	}
+
+	if len(search.Issues) > 0 {
+		key := search.Issues[0].Key
+		in := map[string]interface{}{
+			"fields": map[string]interface{}{
+				"summary":     summary,
+				"description": description,
+			},
+		}
+		u := base + "/issue/" + url.PathEscape(key)
+		if err := doJSON(ctx, obj.Client, http.MethodPut, u, headers, in, nil); err != nil {
+			return "", errwrap.Wrapf(err, "could not update issue")
+		}
+		return key, nil
+	}
+
+	issueType := obj.Options.IssueType
+	if issueType == "" {
+		issueType = JiraDefaultIssueType
+	}
+	in := map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": obj.Options.Project},
+			"issuetype":   map[string]string{"name": issueType},
+			"summary":     summary,
+			"description": description,
+			"labels":      []string{label},
+		},
+	}
+	out := &struct {
+		Key string `json:"key"`
+	}{}
+	if err := doJSON(ctx, obj.Client, http.MethodPost, base+"/issue", headers, in, out); err != nil {
+		return "", errwrap.Wrapf(err, "could not create issue")
+	}
+	return out.Key, nil
+}
@@ -0,0 +1,104 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package publish contains the publishers which push the results of a scan to
+// an external catalog once it has finished.
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+const (
+	// MaxErrorBody is the maximum number of bytes of a response body that
+	// we include in an error message.
+	MaxErrorBody = 512
+)
+
+// Publisher is something that can push the output of a scan somewhere.
+type Publisher interface {
+	fmt.Stringer
+
+	// Publish sends the output to wherever this publisher sends it. The
+	// report URL is a link to the full report, and it may be empty.
+	Publish(ctx context.Context, output *lib.Output, reportURL string) error
+}
+
+// doJSON sends a request with an optional json body, and decodes the optional
+// json response into out. It errors if the status code is not a success.
+func doJSON(ctx context.Context, client *http.Client, method, url string, headers map[string]string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return errwrap.Wrapf(err, "could not encode request")
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errwrap.Wrapf(err, "could not read response")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		s := strings.TrimSpace(string(b))
+		if len(s) > MaxErrorBody {
+			s = s[:MaxErrorBody] + "..."
+		}
+		return fmt.Errorf("%s %s returned %s: %s", method, url, resp.Status, s)
+	}
+	if out == nil || len(b) == 0 {
+		return nil
+	}
+	return errwrap.Wrapf(json.Unmarshal(b, out), "could not decode response")
+}
@@ -0,0 +1,393 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package publish_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/publish"
+)
+
+func testOutput() *lib.Output {
+	return &lib.Output{
+		Program: "yesiscan",
+		Version: "0.0.1",
+		Args:    []string{"/tmp/project.tar.gz"},
+	}
+}
+
+func TestDependencyTrack(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/api/v1/bom" || r.Header.Get("X-Api-Key") != "secret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"token": "abc"}`)
+	}))
+	defer server.Close()
+
+	p := &publish.DependencyTrack{
+		Logf: t.Logf,
+		Options: &publish.DependencyTrackOptions{
+			URL:     server.URL + "/",
+			APIKey:  "secret",
+			Project: "myproject",
+			Version: "1.0",
+		},
+	}
+	if err := p.Publish(context.Background(), testOutput(), ""); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if got["projectName"] != "myproject" || got["projectVersion"] != "1.0" || got["autoCreate"] != true {
+		t.Errorf("unexpected request: %+v", got)
+	}
+	s, _ := got["bom"].(string)
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("could not decode bom: %+v", err)
+	}
+	bom := &lib.CycloneDXBom{}
+	if err := json.Unmarshal(b, bom); err != nil {
+		t.Fatalf("could not decode bom: %+v", err)
+	}
+	if bom.BomFormat != lib.CycloneDXFormat || len(bom.Components) != 1 || bom.Components[0].Name != "project" {
+		t.Errorf("unexpected bom: %s", b)
+	}
+
+	p.Options.APIKey = "wrong"
+	if err := p.Publish(context.Background(), testOutput(), ""); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestSW360(t *testing.T) {
+	mutex := &sync.Mutex{}
+	calls := []string{}
+	var release map[string]interface{}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if r.Header.Get("Authorization") != "Token secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/components":
+			fmt.Fprint(w, `{"_embedded": {"sw360:components": [{"name": "projects"}]}}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/api/components":
+			fmt.Fprintf(w, `{"name": "project", "_links": {"self": {"href": "%s/api/components/c1"}}}`, server.URL)
+		case r.Method == http.MethodGet && r.URL.Path == "/api/components/c1":
+			fmt.Fprint(w, `{"name": "project"}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/api/releases":
+			json.NewDecoder(r.Body).Decode(&release)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := &publish.SW360{
+		Logf: t.Logf,
+		Options: &publish.SW360Options{
+			URL:   server.URL + "/api",
+			Token: "secret",
+		},
+	}
+	if err := p.Publish(context.Background(), testOutput(), ""); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	exp := "GET /api/components,POST /api/components,GET /api/components/c1,POST /api/releases"
+	if s := strings.Join(calls, ","); s != exp {
+		t.Errorf("exp: %s", exp)
+		t.Errorf("got: %s", s)
+	}
+	if release["componentId"] != "c1" || release["version"] != publish.SW360UnknownVersion {
+		t.Errorf("unexpected release: %+v", release)
+	}
+}
+
+func TestJira(t *testing.T) {
+	output := &lib.Output{
+		Program: "yesiscan",
+		Args:    []string{"a", "b", "c"},
+		Verdicts: []*lib.Verdict{
+			{Artifact: "a", Profile: "default", Verdict: lib.VerdictFail, Violations: []string{"file:///a/x.go"}},
+			{Artifact: "b", Profile: "default", Verdict: lib.VerdictFail, Violations: []string{"file:///b/y.go"}},
+			{Artifact: "c", Profile: "default", Verdict: lib.VerdictPass},
+		},
+	}
+	existing := publish.JiraLabel("yesiscan", "b")
+
+	mutex := &sync.Mutex{}
+	created := []map[string]interface{}{}
+	updated := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/search":
+			if strings.Contains(r.URL.Query().Get("jql"), existing) {
+				fmt.Fprint(w, `{"issues": [{"key": "LIC-1"}]}`)
+				return
+			}
+			fmt.Fprint(w, `{"issues": []}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue":
+			in := map[string]map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&in)
+			created = append(created, in["fields"])
+			fmt.Fprint(w, `{"key": "LIC-2"}`)
+		case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/rest/api/2/issue/"):
+			updated = append(updated, strings.TrimPrefix(r.URL.Path, "/rest/api/2/issue/"))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := &publish.Jira{
+		Logf: t.Logf,
+		Options: &publish.JiraOptions{
+			URL:       server.URL,
+			Project:   "LIC",
+			Token:     "secret",
+			ReportURL: "https://example.com/report.html",
+		},
+	}
+	if err := p.Publish(context.Background(), output, ""); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if len(created) != 1 || len(updated) != 1 || updated[0] != "LIC-1" {
+		t.Fatalf("unexpected calls: created %d, updated %+v", len(created), updated)
+	}
+	fields := created[0]
+	if fields["summary"] != "yesiscan: license policy violation in a" {
+		t.Errorf("unexpected summary: %+v", fields["summary"])
+	}
+	description, _ := fields["description"].(string)
+	for _, x := range []string{"h3. profile default", "* file:///a/x.go", "https://example.com/report.html"} {
+		if !strings.Contains(description, x) {
+			t.Errorf("description is missing: %s", x)
+		}
+	}
+
+	p.Options.SummaryTemplate = "{{ .Nope"
+	if err := p.Options.Validate(); err == nil {
+		t.Errorf("expected an invalid template error")
+	}
+}
+
+func TestChat(t *testing.T) {
+	output := &lib.Output{
+		Program: "yesiscan",
+		Verdicts: []*lib.Verdict{
+			{Artifact: "a", Profile: "default", Verdict: lib.VerdictFail, Violations: []string{"file:///a/x.go"}},
+		},
+	}
+
+	mutex := &sync.Mutex{}
+	posted := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		in := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&in)
+		posted = append(posted, in["Content"])
+	}))
+	defer server.Close()
+
+	p := &publish.Chat{
+		Logf: t.Logf,
+		Options: &publish.ChatOptions{
+			Webhook: server.URL,
+			Kind:    publish.ChatKindChime,
+		},
+		StateDir: t.TempDir(),
+	}
+	if err := p.Publish(context.Background(), output, "https://example.com/r"); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	output.Verdicts[0].Violations = append(output.Verdicts[0].Violations, "file:///a/y.go")
+	if err := p.Publish(context.Background(), output, ""); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if len(posted) != 2 {
+		t.Fatalf("unexpected posts: %d", len(posted))
+	}
+	for _, x := range []string{"*fail*", "new violations (1)", "file:///a/x.go", "report: https://example.com/r"} {
+		if !strings.Contains(posted[0], x) {
+			t.Errorf("first summary is missing: %s", x)
+		}
+	}
+	if strings.Contains(posted[1], "file:///a/x.go") || !strings.Contains(posted[1], "file:///a/y.go") {
+		t.Errorf("second summary should only list the new violation:\n%s", posted[1])
+	}
+}
+
+func TestEmail(t *testing.T) {
+	output := &lib.Output{
+		Program: "yesiscan",
+		Args:    []string{"https://github.com/awslabs/yesiscan"},
+		Verdicts: []*lib.Verdict{
+			{Artifact: "a", Profile: "default", Verdict: lib.VerdictFail, Violations: []string{"file:///a/x.go"}},
+		},
+	}
+
+	// a tiny smtp server which remembers what it was sent
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	defer listener.Close()
+	got := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ready")
+		rcpts := []string{}
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO", "MAIL":
+				tp.PrintfLine("250 ok")
+			case "RCPT":
+				rcpts = append(rcpts, line)
+				tp.PrintfLine("250 ok")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				b, _ := tp.ReadDotBytes()
+				got <- strings.Join(rcpts, "\n") + "\n" + string(b)
+				tp.PrintfLine("250 sent")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				return
+			default:
+				tp.PrintfLine("502 unknown")
+			}
+		}
+	}()
+
+	p := &publish.Email{
+		Logf: t.Logf,
+		Options: &publish.EmailOptions{
+			Server: listener.Addr().String(),
+			From:   "Yesiscan <yesiscan@example.com>",
+			To:     []string{"legal@example.com", "Dev <dev@example.com>"},
+			Attach: true,
+		},
+		Render: func(output *lib.Output) (string, error) {
+			return "<html>the report</html>", nil
+		},
+	}
+	if err := p.Publish(context.Background(), output, "https://example.com/r"); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	s := <-got
+	for _, x := range []string{"<legal@example.com>", "<dev@example.com>", "Subject: yesiscan: fail: https://github.com/awslabs/yesiscan", "filename=report.html"} {
+		if !strings.Contains(s, x) {
+			t.Errorf("email is missing: %s", x)
+		}
+	}
+
+	i := strings.Index(s, "From:")
+	msg, err := mail.ReadMessage(strings.NewReader(s[i:]))
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	_, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	parts := []string{}
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		b, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+		parts = append(parts, string(b))
+	}
+	if len(parts) != 2 || !strings.Contains(parts[0], "file:///a/x.go") || !strings.Contains(parts[0], "report: https://example.com/r") || parts[1] != "<html>the report</html>" {
+		t.Errorf("unexpected parts: %q", parts)
+	}
+
+	redact := strings.NewReplacer("/a/", "/redacted-path-1/", "awslabs", "redacted-host-1").Replace
+	b, err := publish.EmailMessage(p.Options, output, "", "", redact)
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	msg, err = mail.ReadMessage(strings.NewReader(string(b)))
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if s := msg.Header.Get("Subject"); s != "yesiscan: fail: https://github.com/redacted-host-1/yesiscan" {
+		t.Errorf("the subject was not redacted: %s", s)
+	}
+	_, params, _ = mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	part, err := multipart.NewReader(msg.Body, params["boundary"]).NextPart()
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	text, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+	if s := string(text); !strings.Contains(s, "file:///redacted-path-1/x.go") || strings.Contains(s, "file:///a/") {
+		t.Errorf("the summary was not redacted: %s", s)
+	}
+
+	p.Options.To = nil
+	if err := p.Options.Validate(); err == nil {
+		t.Errorf("expected an error without recipients")
+	}
+}
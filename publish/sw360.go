@@ -0,0 +1,212 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package publish
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+const (
+	// SW360UnknownVersion is the release version that we use when we can't
+	// tell what the version of an artifact is and none was specified.
+	SW360UnknownVersion = "unknown"
+)
+
+// SW360Options are the settings for the SW360 publisher.
+type SW360Options struct {
+	// URL is the base URL of the SW360 rest api, which usually ends with
+	// /resource/api.
+	URL string `json:"url"`
+
+	// Token is an SW360 rest api token with read and write access.
+	Token string `json:"token"`
+
+	// Version is the release version to use for the artifacts that we
+	// can't find a version for. If it is empty, then "unknown" is used.
+	Version string `json:"version"`
+}
+
+// Validate returns an error if the options are not usable.
+func (obj *SW360Options) Validate() error {
+	if obj == nil {
+		return fmt.Errorf("empty options")
+	}
+	if obj.URL == "" {
+		return fmt.Errorf("empty url")
+	}
+	if obj.Token == "" {
+		return fmt.Errorf("empty token")
+	}
+	return nil
+}
+
+// SW360 pushes the concluded licenses of each artifact to an SW360 server. Each
+// artifact is a release of a component with the same name. The component and
+// the release get created if they don't exist yet, and otherwise the main
+// licenses of the release are replaced.
+type SW360 struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	Options *SW360Options
+
+	// Client is the http client to use. If it is nil, then the default one
+	// is used.
+	Client *http.Client
+}
+
+func (obj *SW360) String() string {
+	return "sw360"
+}
+
+// sw360Links is the HAL links section of an SW360 resource.
+type sw360Links struct {
+	Self struct {
+		Href string `json:"href"`
+	} `json:"self"`
+}
+
+// sw360Resource is the part of an SW360 component or release that we use.
+type sw360Resource struct {
+	Name    string     `json:"name"`
+	Version string     `json:"version"`
+	Links   sw360Links `json:"_links"`
+}
+
+// id returns the id of the resource which is at the end of its self link.
+func (obj *sw360Resource) id() string {
+	return path.Base(obj.Links.Self.Href)
+}
+
+// Publish pushes the licenses of each artifact. The report URL is not used.
+func (obj *SW360) Publish(ctx context.Context, output *lib.Output, reportURL string) error {
+	if err := obj.Options.Validate(); err != nil {
+		return errwrap.Wrapf(err, "invalid sw360 options")
+	}
+
+	artifacts := []string{}
+	artifacts = append(artifacts, output.Args...)
+	found := lib.ArtifactLicenses(output, artifacts)
+	for _, a := range artifacts {
+		name, version := lib.ArtifactCoordinates(output, a)
+		if version == "" {
+			version = obj.Options.Version
+		}
+		if version == "" {
+			version = SW360UnknownVersion
+		}
+		ids := []string{}
+		for _, x := range found[a] {
+			if x.SPDX == "" {
+				continue // sw360 only knows about the licenses it has
+			}
+			ids = append(ids, x.SPDX)
+		}
+		if err := obj.publish(ctx, output.Program, name, version, ids); err != nil {
+			return errwrap.Wrapf(err, "could not publish %s", a)
+		}
+		obj.Logf("published %s %s with %d licenses", name, version, len(ids))
+	}
+	return nil
+}
+
+// publish creates or updates a single release. The program name is used in
+// the description of any component that we create.
+func (obj *SW360) publish(ctx context.Context, program, name, version string, ids []string) error {
+	base := strings.TrimSuffix(obj.Options.URL, "/")
+	headers := map[string]string{
+		"Authorization": "Token " + obj.Options.Token,
+	}
+
+	// find the component by name, or create it
+	components := &struct {
+		Embedded struct {
+			Components []*sw360Resource `json:"sw360:components"`
+		} `json:"_embedded"`
+	}{}
+	u := base + "/components?name=" + url.QueryEscape(name)
+	if err := doJSON(ctx, obj.Client, http.MethodGet, u, headers, nil, components); err != nil {
+		return errwrap.Wrapf(err, "could not search components")
+	}
+	var component *sw360Resource
+	for _, x := range components.Embedded.Components {
+		if x.Name == name {
+			component = x
+			break
+		}
+	}
+	if component == nil {
+		if obj.Debug {
+			obj.Logf("creating component %s", name)
+		}
+		in := map[string]interface{}{
+			"name":          name,
+			"description":   "Created by " + program,
+			"componentType": "OSS",
+		}
+		component = &sw360Resource{}
+		if err := doJSON(ctx, obj.Client, http.MethodPost, base+"/components", headers, in, component); err != nil {
+			return errwrap.Wrapf(err, "could not create component")
+		}
+	}
+
+	// find the release by version, or create it
+	releases := &struct {
+		Embedded struct {
+			Releases []*sw360Resource `json:"sw360:releases"`
+		} `json:"_embedded"`
+	}{}
+	u = base + "/components/" + url.PathEscape(component.id())
+	if err := doJSON(ctx, obj.Client, http.MethodGet, u, headers, nil, releases); err != nil {
+		return errwrap.Wrapf(err, "could not get component")
+	}
+	for _, x := range releases.Embedded.Releases {
+		if x.Version != version {
+			continue
+		}
+		in := map[string]interface{}{
+			"mainLicenseIds": ids,
+		}
+		u := base + "/releases/" + url.PathEscape(x.id())
+		return errwrap.Wrapf(doJSON(ctx, obj.Client, http.MethodPatch, u, headers, in, nil), "could not update release")
+	}
+	if obj.Debug {
+		obj.Logf("creating release %s %s", name, version)
+	}
+	in := map[string]interface{}{
+		"componentId":    component.id(),
+		"name":           name,
+		"version":        version,
+		"mainLicenseIds": ids,
+	}
+	return errwrap.Wrapf(doJSON(ctx, obj.Client, http.MethodPost, base+"/releases", headers, in, nil), "could not create release")
+}
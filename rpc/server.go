@@ -0,0 +1,522 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package rpc is a grpc service for running scans and getting their reports.
+// It's for other programs which would rather use rpc than scrape the web ui or
+// run the cli. The reports are stored in the same format as the web server, so
+// that one which shares the same report store can show them.
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative yesiscan.proto
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/licenses"
+	"github.com/awslabs/yesiscan/util/safepath"
+	"github.com/awslabs/yesiscan/web"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultListen is the address that we listen on if none is specified.
+	DefaultListen = "127.0.0.1:8443"
+
+	// progressInterval is how often we send the progress of a running scan.
+	progressInterval = 1 * time.Second
+)
+
+// Server is the grpc server.
+type Server struct {
+	UnimplementedYesiscanServer
+
+	Program string
+	Version string
+	Debug   bool
+	Logf    func(format string, v ...interface{})
+
+	// Profiles is the list of profiles that a scan may ask for.
+	Profiles []string
+
+	// Listen is the address to listen on. If it is empty, then we use the
+	// DefaultListen address.
+	Listen string
+
+	// TLSCertFile and TLSKeyFile are the pem certificate and key to serve
+	// with. If they are empty, then we serve plain text.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// Workspace runs each scan in a private workspace which is removed
+	// after the scan.
+	Workspace bool
+
+	// Perms is the permission policy for anything that we write.
+	Perms *interfaces.Perms
+
+	// MaxFiles, MaxBytes, and MaxDuration limit each scan. Zero values
+	// don't limit anything.
+	MaxFiles    int64
+	MaxBytes    int64
+	MaxDuration time.Duration
+
+	// MaxConcurrentScans is the most scans that can run at once. If it is
+	// zero, then there is no limit.
+	MaxConcurrentScans int
+
+	// Tokens maps each bearer token which may use the server to the name
+	// of its user. If it is empty, then anyone can.
+	Tokens map[string]string
+
+	// Reports stores the reports. If it is nil, then the same directory as
+	// the default for the web server is used.
+	Reports web.ReportStore
+
+	// CacheRemote is a store of cache entries that is shared with the other
+	// servers and the CI runners. If it is set, then the results of each
+	// backend are cached. Otherwise nothing is.
+	CacheRemote lib.CacheRemote
+
+	// CacheSigningKey is the secret that the entries of the remote cache
+	// are signed with. It must be set if there is a remote cache.
+	CacheSigningKey []byte
+
+	// store loads and stores the reports the same way as the web server.
+	store *web.Server
+
+	// scans holds a token for each scan that is running.
+	scans chan struct{}
+}
+
+// Run listens on the address and serves until the context is cancelled.
+func (obj *Server) Run(ctx context.Context) error {
+	listen := DefaultListen
+	if obj.Listen != "" {
+		listen = obj.Listen
+	}
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	obj.Logf("listening on: %s", listener.Addr())
+
+	return obj.Serve(ctx, listener)
+}
+
+// Serve serves on the listener until the context is cancelled.
+func (obj *Server) Serve(ctx context.Context, listener net.Listener) error {
+	if obj.Reports == nil {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return err
+		}
+		prefix := filepath.Join(userCacheDir, obj.Program, "report") + "/"
+		absDir, err := safepath.ParseIntoAbsDir(prefix)
+		if err != nil {
+			return err
+		}
+		obj.Reports = &web.DiskReportStore{
+			Prefix: absDir,
+			Perms:  obj.Perms,
+		}
+	}
+	if err := obj.Reports.Init(ctx); err != nil {
+		return errwrap.Wrapf(err, "could not initialize the report store")
+	}
+	obj.Logf("report store: %s", obj.Reports)
+	obj.store = &web.Server{
+		Program: obj.Program,
+		Version: obj.Version,
+		Logf:    obj.Logf,
+		Reports: obj.Reports,
+	}
+	if obj.MaxConcurrentScans > 0 {
+		obj.scans = make(chan struct{}, obj.MaxConcurrentScans)
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(obj.unaryAuth),
+		grpc.StreamInterceptor(obj.streamAuth),
+	}
+	if obj.TLSCertFile != "" || obj.TLSKeyFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(obj.TLSCertFile, obj.TLSKeyFile)
+		if err != nil {
+			return errwrap.Wrapf(err, "could not load the tls certificate")
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	server := grpc.NewServer(opts...)
+	RegisterYesiscanServer(server, obj)
+
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop() // cancels the running scans too
+	}()
+	return server.Serve(listener)
+}
+
+// user returns the name of the user of the bearer token in the request. Each
+// token is compared in constant time so that the timing doesn't give any of them
+// away. If there are no tokens, then everyone is allowed, and it's empty.
+func (obj *Server) user(ctx context.Context) (string, error) {
+	if len(obj.Tokens) == 0 {
+		return "", nil
+	}
+	token := ""
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, s := range md.Get("authorization") {
+		if strings.HasPrefix(s, "Bearer ") {
+			token = strings.TrimSpace(strings.TrimPrefix(s, "Bearer "))
+		}
+	}
+	user := ""
+	for k, v := range obj.Tokens {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(token)) == 1 {
+			user = v
+		}
+	}
+	if token == "" || user == "" {
+		return "", status.Error(codes.Unauthenticated, "a valid bearer token is required")
+	}
+	return user, nil
+}
+
+// userKey is the context key that the name of the user is stored under.
+type userKey struct{}
+
+func (obj *Server) unaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	user, err := obj.user(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, userKey{}, user), req)
+}
+
+func (obj *Server) streamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if _, err := obj.user(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// Scan runs a scan and streams its progress, and then the uid of its report.
+func (obj *Server) Scan(req *ScanRequest, stream Yesiscan_ScanServer) error {
+	ctx := stream.Context()
+	owner, err := obj.user(ctx)
+	if err != nil {
+		return err
+	}
+
+	uri := strings.TrimSpace(req.GetUri())
+	if uri == "" {
+		return status.Error(codes.InvalidArgument, "empty uri")
+	}
+	// make sure we're only scanning public URI's, not local data!
+	isGit := strings.HasPrefix(strings.ToLower(uri), iterator.GitScheme)
+	isHttps := strings.HasPrefix(strings.ToLower(uri), iterator.HttpsScheme)
+	if !isGit && !isHttps {
+		return status.Error(codes.InvalidArgument, "must pass in git or https uri's")
+	}
+
+	backends := make(map[string]bool)
+	for _, x := range lib.Backends {
+		backends[x] = len(req.GetBackends()) == 0 // all if none are chosen
+	}
+	for _, x := range req.GetBackends() {
+		if _, exists := backends[x]; !exists {
+			return status.Errorf(codes.InvalidArgument, "unknown backend: %s", x)
+		}
+		backends[x] = true
+	}
+	profilesMap := make(map[string]bool)
+	for _, x := range obj.Profiles {
+		profilesMap[x] = false // default so it shows up physically
+	}
+	for _, x := range req.GetProfiles() {
+		if !util.StrInList(x, obj.Profiles) {
+			return status.Errorf(codes.InvalidArgument, "unknown profile: %s", x)
+		}
+		profilesMap[x] = true
+	}
+
+	if obj.scans != nil {
+		select {
+		case obj.scans <- struct{}{}:
+			defer func() { <-obj.scans }()
+		default:
+			return status.Error(codes.ResourceExhausted, "too many scans are running, try again later")
+		}
+	}
+
+	obj.Logf("scan: %s", uri)
+
+	// The limiter doesn't limit anything, but it counts the bytes that get
+	// downloaded so that we can show them.
+	limiter := &iterator.Limiter{}
+	progress := &lib.Progress{
+		Limiter:  limiter,
+		MaxFiles: obj.MaxFiles,
+	}
+	m := &lib.Main{
+		Program: obj.Program,
+		Debug:   obj.Debug,
+		Logf:    obj.Logf,
+
+		Args:     []string{uri},
+		Backends: backends,
+
+		Profiles: req.GetProfiles(),
+
+		Workspace: obj.Workspace,
+		Perms:     obj.Perms,
+
+		MaxFiles:    obj.MaxFiles,
+		MaxBytes:    obj.MaxBytes,
+		MaxDuration: obj.MaxDuration,
+
+		CacheRemote:     obj.CacheRemote,
+		CacheSigningKey: obj.CacheSigningKey,
+
+		Limiter:  limiter,
+		Progress: progress,
+	}
+	report := &web.Report{
+		Program:  obj.Program,
+		Version:  obj.Version,
+		Uri:      uri,
+		Backends: backends,
+		Profiles: profilesMap,
+
+		Owner: owner, // empty without any tokens
+	}
+
+	type scanResult struct {
+		output *lib.Output
+		err    error
+	}
+	done := make(chan *scanResult, 1)
+	go func() {
+		output, err := m.Run(ctx) // stops if the client goes away
+		done <- &scanResult{output, err}
+	}()
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	var result *scanResult
+	for result == nil {
+		select {
+		case result = <-done:
+		case <-ticker.C:
+			if err := stream.Send(newProgressResponse(progress.Status())); err != nil {
+				<-done // the context is cancelled, so it's ending
+				return err
+			}
+		}
+	}
+	if result.err != nil {
+		if ctx.Err() != nil {
+			obj.Logf("scan: cancelled: %s", uri)
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return status.Error(codes.Internal, result.err.Error())
+	}
+
+	report.ProfilesData = result.output.ProfilesData
+	report.Hashes = result.output.ContentHashes
+	report.Output = lib.NewJSONOutput(result.output)
+	uid, err := obj.store.Store(report)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return stream.Send(&ScanResponse{
+		Event: &ScanResponse_Report{Report: uid},
+	})
+}
+
+// newProgressResponse converts the progress of a scan into a message.
+func newProgressResponse(s *lib.ProgressStatus) *ScanResponse {
+	return &ScanResponse{
+		Event: &ScanResponse_Progress{
+			Progress: &Progress{
+				Files:      s.Files,
+				Bytes:      s.Bytes,
+				Downloaded: s.Downloaded,
+				MaxFiles:   s.MaxFiles,
+				Iterator:   s.Iterator,
+				Backends:   s.Backends,
+			},
+		},
+	}
+}
+
+// load returns the report with this uid, if the user of the request may see it.
+func (obj *Server) load(ctx context.Context, uid string) (*web.Report, error) {
+	if err := web.ValidUID(uid); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	report, err := obj.store.Load(uid)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "report %s was not found", uid)
+	}
+	user, _ := ctx.Value(userKey{}).(string)
+	if report.Owner != "" && report.Owner != user {
+		// don't give away that it exists
+		return nil, status.Errorf(codes.NotFound, "report %s was not found", uid)
+	}
+	if report.Output == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "report %s has no output", uid)
+	}
+	return report, nil
+}
+
+// GetReport returns a stored report.
+func (obj *Server) GetReport(ctx context.Context, req *GetReportRequest) (*GetReportResponse, error) {
+	report, err := obj.load(ctx, req.GetUid())
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(report.Output)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &GetReportResponse{
+		Uid:    req.GetUid(),
+		Uri:    report.Uri,
+		Time:   report.Time,
+		Passes: report.Output.Passes,
+		Json:   b,
+	}
+
+	uids := []string{}
+	for uid := range report.Output.Results {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	for _, uid := range uids {
+		fileResult := &FileResult{
+			Uid: uid,
+		}
+		names := []string{}
+		for name := range report.Output.Results[uid] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			result := report.Output.Results[uid][name]
+			ids := []string{}
+			for _, license := range result.Licenses {
+				ids = append(ids, license.String())
+			}
+			fileResult.Backends = append(fileResult.Backends, &BackendResult{
+				Backend:    name,
+				Licenses:   ids,
+				Confidence: result.Confidence,
+				Skip:       result.Skip,
+			})
+		}
+		resp.Results = append(resp.Results, fileResult)
+	}
+
+	return resp, nil
+}
+
+// ListLicenses returns every license in the SPDX list that we use, or only the
+// ones that were found in a report, along with how many files each was in.
+func (obj *Server) ListLicenses(ctx context.Context, req *ListLicensesRequest) (*ListLicensesResponse, error) {
+	resp := &ListLicensesResponse{
+		Version: licenses.LicenseList.Version,
+	}
+
+	if req.GetReport() == "" {
+		for _, x := range licenses.LicenseList.Licenses {
+			resp.Licenses = append(resp.Licenses, newLicense(x.LicenseID, x))
+		}
+		return resp, nil
+	}
+
+	report, err := obj.load(ctx, req.GetReport())
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64) // number of files for each license
+	for _, m := range report.Output.Results {
+		found := make(map[string]struct{})
+		for _, result := range m {
+			for _, license := range result.Licenses {
+				found[license.String()] = struct{}{}
+			}
+		}
+		for id := range found {
+			counts[id]++
+		}
+	}
+	ids := []string{}
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		x, _ := licenses.ID(id) // nil if it's not in the list
+		license := newLicense(id, x)
+		license.Files = counts[id]
+		resp.Licenses = append(resp.Licenses, license)
+	}
+
+	return resp, nil
+}
+
+// newLicense converts an entry in the SPDX license list into a message. If the
+// entry is nil, then only the id is set.
+func newLicense(id string, x *licenses.LicenseSPDX) *License {
+	license := &License{
+		Id: id,
+	}
+	if x == nil {
+		return license
+	}
+	license.Name = x.Name
+	license.OsiApproved = x.IsOSIApproved
+	license.FsfLibre = x.IsFSFLibre
+	license.Deprecated = x.IsDeprecated
+	return license
+}
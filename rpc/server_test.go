@@ -0,0 +1,150 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package rpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/rpc"
+	"github.com/awslabs/yesiscan/util/licenses"
+	"github.com/awslabs/yesiscan/util/safepath"
+	"github.com/awslabs/yesiscan/web"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	absDir, err := safepath.ParseIntoAbsDir(t.TempDir() + "/")
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	store := &web.DiskReportStore{Prefix: absDir}
+	uid := strings.Repeat("ab", 32)
+	report := &web.Report{
+		Uri:   "https://example.com/code.git",
+		Owner: "alice",
+		Output: &lib.JSONOutput{
+			Results: map[string]map[string]*lib.JSONResult{
+				"file:///a.go": {
+					"spdx": {Licenses: []*licenses.License{{SPDX: "MIT"}}, Confidence: 1.0},
+				},
+				"file:///b.go": {
+					"spdx": {Licenses: []*licenses.License{{SPDX: "MIT"}}, Confidence: 1.0},
+				},
+			},
+		},
+	}
+	b, err := json.Marshal(report)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if err := store.Init(ctx); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if err := store.Put(ctx, uid, b); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+
+	server := &rpc.Server{
+		Logf: func(format string, v ...interface{}) {
+			t.Logf(format, v...)
+		},
+		Tokens:  map[string]string{"secret1": "alice", "secret2": "bob"},
+		Reports: store,
+	}
+	listener := bufconn.Listen(1024 * 1024)
+	go server.Serve(ctx, listener)
+
+	conn, err := grpc.DialContext(ctx, "bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	defer conn.Close()
+	client := rpc.NewYesiscanClient(conn)
+
+	// no token
+	if _, err := client.ListLicenses(ctx, &rpc.ListLicensesRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected unauthenticated, got: %+v", err)
+	}
+
+	alice := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret1")
+	bob := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret2")
+
+	all, err := client.ListLicenses(alice, &rpc.ListLicensesRequest{})
+	if err != nil || len(all.GetLicenses()) != len(licenses.LicenseList.Licenses) {
+		t.Errorf("unexpected licenses: %+v, error: %+v", all, err)
+	}
+
+	resp, err := client.GetReport(alice, &rpc.GetReportRequest{Uid: uid})
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if resp.GetUri() != report.Uri || len(resp.GetResults()) != 2 || resp.GetResults()[0].GetUid() != "file:///a.go" {
+		t.Errorf("unexpected report: %+v", resp)
+	}
+
+	found, err := client.ListLicenses(alice, &rpc.ListLicensesRequest{Report: uid})
+	if err != nil || len(found.GetLicenses()) != 1 || found.GetLicenses()[0].GetId() != "MIT" || found.GetLicenses()[0].GetFiles() != 2 {
+		t.Errorf("unexpected licenses: %+v, error: %+v", found, err)
+	}
+
+	// only the owner can see it
+	if _, err := client.GetReport(bob, &rpc.GetReportRequest{Uid: uid}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected not found, got: %+v", err)
+	}
+
+	// only public uri's can be scanned
+	stream, err := client.Scan(alice, &rpc.ScanRequest{Uri: "/etc/"})
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected invalid argument, got: %+v", err)
+	}
+}
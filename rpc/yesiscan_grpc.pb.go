@@ -0,0 +1,217 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: yesiscan.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// YesiscanClient is the client API for Yesiscan service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type YesiscanClient interface {
+	// Scan runs a scan and streams its progress while it runs. The last
+	// message has the uid of the stored report. If the client goes away,
+	// then the scan is cancelled.
+	Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (Yesiscan_ScanClient, error)
+	// GetReport returns a stored report.
+	GetReport(ctx context.Context, in *GetReportRequest, opts ...grpc.CallOption) (*GetReportResponse, error)
+	// ListLicenses returns the licenses that we know of, or the ones which
+	// were found in a report.
+	ListLicenses(ctx context.Context, in *ListLicensesRequest, opts ...grpc.CallOption) (*ListLicensesResponse, error)
+}
+
+type yesiscanClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewYesiscanClient(cc grpc.ClientConnInterface) YesiscanClient {
+	return &yesiscanClient{cc}
+}
+
+func (c *yesiscanClient) Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (Yesiscan_ScanClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Yesiscan_ServiceDesc.Streams[0], "/yesiscan.v1.Yesiscan/Scan", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &yesiscanScanClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Yesiscan_ScanClient interface {
+	Recv() (*ScanResponse, error)
+	grpc.ClientStream
+}
+
+type yesiscanScanClient struct {
+	grpc.ClientStream
+}
+
+func (x *yesiscanScanClient) Recv() (*ScanResponse, error) {
+	m := new(ScanResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *yesiscanClient) GetReport(ctx context.Context, in *GetReportRequest, opts ...grpc.CallOption) (*GetReportResponse, error) {
+	out := new(GetReportResponse)
+	err := c.cc.Invoke(ctx, "/yesiscan.v1.Yesiscan/GetReport", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yesiscanClient) ListLicenses(ctx context.Context, in *ListLicensesRequest, opts ...grpc.CallOption) (*ListLicensesResponse, error) {
+	out := new(ListLicensesResponse)
+	err := c.cc.Invoke(ctx, "/yesiscan.v1.Yesiscan/ListLicenses", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// YesiscanServer is the server API for Yesiscan service.
+// All implementations must embed UnimplementedYesiscanServer
+// for forward compatibility
+type YesiscanServer interface {
+	// Scan runs a scan and streams its progress while it runs. The last
+	// message has the uid of the stored report. If the client goes away,
+	// then the scan is cancelled.
+	Scan(*ScanRequest, Yesiscan_ScanServer) error
+	// GetReport returns a stored report.
+	GetReport(context.Context, *GetReportRequest) (*GetReportResponse, error)
+	// ListLicenses returns the licenses that we know of, or the ones which
+	// were found in a report.
+	ListLicenses(context.Context, *ListLicensesRequest) (*ListLicensesResponse, error)
+	mustEmbedUnimplementedYesiscanServer()
+}
+
+// UnimplementedYesiscanServer must be embedded to have forward compatible implementations.
+type UnimplementedYesiscanServer struct {
+}
+
+func (UnimplementedYesiscanServer) Scan(*ScanRequest, Yesiscan_ScanServer) error {
+	return status.Errorf(codes.Unimplemented, "method Scan not implemented")
+}
+func (UnimplementedYesiscanServer) GetReport(context.Context, *GetReportRequest) (*GetReportResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetReport not implemented")
+}
+func (UnimplementedYesiscanServer) ListLicenses(context.Context, *ListLicensesRequest) (*ListLicensesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLicenses not implemented")
+}
+func (UnimplementedYesiscanServer) mustEmbedUnimplementedYesiscanServer() {}
+
+// UnsafeYesiscanServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to YesiscanServer will
+// result in compilation errors.
+type UnsafeYesiscanServer interface {
+	mustEmbedUnimplementedYesiscanServer()
+}
+
+func RegisterYesiscanServer(s grpc.ServiceRegistrar, srv YesiscanServer) {
+	s.RegisterService(&Yesiscan_ServiceDesc, srv)
+}
+
+func _Yesiscan_Scan_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScanRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(YesiscanServer).Scan(m, &yesiscanScanServer{stream})
+}
+
+type Yesiscan_ScanServer interface {
+	Send(*ScanResponse) error
+	grpc.ServerStream
+}
+
+type yesiscanScanServer struct {
+	grpc.ServerStream
+}
+
+func (x *yesiscanScanServer) Send(m *ScanResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Yesiscan_GetReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YesiscanServer).GetReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/yesiscan.v1.Yesiscan/GetReport",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YesiscanServer).GetReport(ctx, req.(*GetReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Yesiscan_ListLicenses_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListLicensesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YesiscanServer).ListLicenses(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/yesiscan.v1.Yesiscan/ListLicenses",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YesiscanServer).ListLicenses(ctx, req.(*ListLicensesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Yesiscan_ServiceDesc is the grpc.ServiceDesc for Yesiscan service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Yesiscan_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "yesiscan.v1.Yesiscan",
+	HandlerType: (*YesiscanServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetReport",
+			Handler:    _Yesiscan_GetReport_Handler,
+		},
+		{
+			MethodName: "ListLicenses",
+			Handler:    _Yesiscan_ListLicenses_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Scan",
+			Handler:       _Yesiscan_Scan_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "yesiscan.proto",
+}
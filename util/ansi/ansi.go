@@ -28,8 +28,6 @@ import (
 	"os"
 	"strings"
 	"sync"
-
-	"golang.org/x/term"
 )
 
 // Logf is a complex printing thing to do some ansi terminal escape sequence
@@ -62,12 +60,9 @@ type Logf struct {
 func (obj *Logf) Init() func(format string, v ...interface{}) {
 	obj.mutex = &sync.Mutex{}
 	//obj.previous = ""
-	obj.isTerminal = term.IsTerminal(0)
-	var err error
-	obj.width, _, err = term.GetSize(0)
-	if err != nil {
-		obj.isTerminal = false // keep it simple, who cares
-	}
+	// we print to stderr, so that's the one that should be a terminal
+	obj.width = Width(int(os.Stderr.Fd()))
+	obj.isTerminal = obj.width > 0
 
 	return obj.Logf
 }
@@ -77,11 +72,8 @@ func (obj *Logf) Init() func(format string, v ...interface{}) {
 func (obj *Logf) Logf(format string, v ...interface{}) {
 	s := fmt.Sprintf(format, v...)
 
-	if obj.isTerminal {
-		// TODO: what about multi-char width UTF-8 stuff?
-		if len(s) > obj.width-len(obj.Prefix) { // truncate/ellipsize
-			s = s[0:obj.width-len(obj.Prefix)-len(obj.Ellipsis)] + obj.Ellipsis
-		}
+	if obj.isTerminal { // truncate/ellipsize
+		s = Truncate(s, obj.width-Len(obj.Prefix), obj.Ellipsis)
 	}
 	s = s + "\n" // add the newline in
 
@@ -92,7 +84,8 @@ func (obj *Logf) Logf(format string, v ...interface{}) {
 		validPrefix = validPrefix || b
 	}
 
-	if obj.Enable && obj.previous != "" && validPrefix {
+	// the escape sequences are only garbage if this is going to a file
+	if obj.Enable && obj.isTerminal && obj.previous != "" && validPrefix {
 		// move up 1 line, clear to left
 		fmt.Fprint(os.Stderr, "\033[1A\033[K") // not 1K as you'd think
 	}
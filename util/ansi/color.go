@@ -0,0 +1,196 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ansi
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const (
+	// ColorAuto colours the output only if it's going to a terminal, and
+	// if the NO_COLOR environment variable isn't set.
+	ColorAuto = "auto"
+
+	// ColorAlways colours the output, even if it's going to a file.
+	ColorAlways = "always"
+
+	// ColorNever never colours the output.
+	ColorNever = "never"
+)
+
+// UseColor returns whether the output which is written to this file descriptor
+// should contain colours and other escape sequences. The mode is one of auto,
+// always, or never, and the empty string is the same as auto. In auto mode, we
+// follow the https://no-color.org/ convention.
+func UseColor(mode string, fd int) (bool, error) {
+	switch mode {
+	case ColorAlways:
+		return true, nil
+	case ColorNever:
+		return false, nil
+	case ColorAuto, "":
+	default:
+		return false, fmt.Errorf("invalid color mode: %s", mode)
+	}
+
+	if os.Getenv("NO_COLOR") != "" {
+		return false, nil
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false, nil
+	}
+	return term.IsTerminal(fd), nil
+}
+
+// Width returns the width of the terminal on this file descriptor, or zero if
+// it isn't a terminal.
+func Width(fd int) int {
+	if !term.IsTerminal(fd) {
+		return 0
+	}
+	width, _, err := term.GetSize(fd)
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
+// escapeLen returns the length of the escape sequence at the start of s, or
+// zero if there isn't one. It knows about the CSI sequences that are used for
+// colours and cursor movement, and the OSC sequences that are used for
+// hyperlinks.
+func escapeLen(s string) int {
+	if len(s) < 2 || s[0] != '\033' {
+		return 0
+	}
+	switch s[1] {
+	case '[': // CSI, ends with a byte in the range @ to ~
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return len(s)
+	case ']': // OSC, ends with BEL or with ESC \
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1
+			}
+			if s[i] == '\033' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return len(s)
+	}
+	return 2
+}
+
+// Len returns the number of columns that a string takes up on the terminal,
+// ignoring any escape sequences in it.
+// TODO: what about wide characters which take up two columns?
+func Len(s string) int {
+	n := 0
+	for i := 0; i < len(s); {
+		if l := escapeLen(s[i:]); l > 0 {
+			i += l
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		n++
+	}
+	return n
+}
+
+// Truncate shortens a string to this many columns, ending it with the ellipsis
+// if it was too long. It doesn't cut inside of an escape sequence, or of a
+// multibyte character.
+func Truncate(s string, width int, ellipsis string) string {
+	if Len(s) <= width {
+		return s
+	}
+	max := width - Len(ellipsis)
+	n := 0
+	for i := 0; i < len(s); {
+		if l := escapeLen(s[i:]); l > 0 {
+			i += l
+			continue
+		}
+		if n >= max {
+			return s[0:i] + ellipsis
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		n++
+	}
+	return s
+}
+
+// Wrap wraps each line of the string at the spaces so that it fits in this many
+// columns. The wrapped part of a line is indented by four spaces more than the
+// line itself. Words which are longer than the width, such as long paths, are
+// never broken. If the width is zero or less, then the string is unchanged.
+func Wrap(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if Len(line) <= width {
+			continue
+		}
+		trimmed := strings.TrimLeft(line, " \t")
+		indent := line[0 : len(line)-len(trimmed)]
+		hanging := indent + "    "
+
+		out := []string{}
+		cur := indent
+		curLen := Len(indent)
+		empty := true // is cur only the indent?
+		for _, word := range strings.Split(trimmed, " ") {
+			wordLen := Len(word)
+			if !empty && curLen+1+wordLen > width {
+				out = append(out, cur)
+				cur = hanging
+				curLen = Len(hanging)
+				empty = true
+			}
+			if !empty {
+				cur += " "
+				curLen++
+			}
+			cur += word
+			curLen += wordLen
+			empty = false
+		}
+		out = append(out, cur)
+		lines[i] = strings.Join(out, "\n")
+	}
+	return strings.Join(lines, "\n")
+}
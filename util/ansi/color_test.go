@@ -0,0 +1,144 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ansi_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/awslabs/yesiscan/util/ansi"
+)
+
+func TestUseColor(t *testing.T) {
+	defer os.Setenv("NO_COLOR", os.Getenv("NO_COLOR"))
+	os.Setenv("NO_COLOR", "1")
+
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	defer f.Close()
+	fd := int(f.Fd()) // a file, not a terminal
+
+	tests := []struct {
+		mode string
+		exp  bool
+	}{
+		{ansi.ColorAlways, true}, // even with NO_COLOR
+		{ansi.ColorNever, false},
+		{ansi.ColorAuto, false},
+		{"", false},
+	}
+	for _, x := range tests {
+		b, err := ansi.UseColor(x.mode, fd)
+		if err != nil {
+			t.Errorf("mode %q: error: %+v", x.mode, err)
+			continue
+		}
+		if b != x.exp {
+			t.Errorf("mode %q: expected %t, got %t", x.mode, x.exp, b)
+		}
+	}
+	if _, err := ansi.UseColor("sometimes", fd); err == nil {
+		t.Errorf("expected an error for an invalid mode")
+	}
+	if ansi.Width(fd) != 0 {
+		t.Errorf("expected no width for a file")
+	}
+}
+
+func TestLen(t *testing.T) {
+	red := "\033[31;1mred\033[0m"
+	link := "\033]8;;https://example.com/\033\\link\033]8;;\033\\"
+	tests := map[string]int{
+		"":               0,
+		"hello":          5,
+		"héllo":          5,
+		red:              3,
+		link:             4,
+		red + " " + link: 8,
+	}
+	for s, exp := range tests {
+		if n := ansi.Len(s); n != exp {
+			t.Errorf("%q: expected %d, got %d", s, exp, n)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	red := "\033[31mabcdef\033[0m"
+	if s := ansi.Truncate("abcdef", 10, "..."); s != "abcdef" {
+		t.Errorf("expected it unchanged, got %q", s)
+	}
+	if s := ansi.Truncate("abcdef", 5, "..."); s != "ab..." {
+		t.Errorf("expected it truncated, got %q", s)
+	}
+	if s := ansi.Truncate("héllo wörld", 5, "."); s != "héll." {
+		t.Errorf("expected it truncated on a character, got %q", s)
+	}
+	if s := ansi.Truncate(red, 4, "."); s != "\033[31mabc." {
+		t.Errorf("expected the escape sequence to be kept whole, got %q", s)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		exp   string
+	}{
+		{"short line", 20, "short line"},
+		{"no width at all", 0, "no width at all"},
+		{
+			"one two three four five",
+			10,
+			"one two\n    three\n    four\n    five",
+		},
+		{
+			"  indented words wrap here",
+			16,
+			"  indented words\n      wrap here",
+		},
+		{
+			"a /very/long/path/that/does/not/fit b",
+			10,
+			"a\n    /very/long/path/that/does/not/fit\n    b",
+		},
+		{
+			"first line is long\nsecond",
+			11,
+			"first line\n    is long\nsecond",
+		},
+		{
+			"\033[31mred\033[0m words fit", // the escapes take no room
+			14,
+			"\033[31mred\033[0m words fit",
+		},
+	}
+	for _, x := range tests {
+		if s := ansi.Wrap(x.s, x.width); s != x.exp {
+			t.Errorf("%q at %d: expected:\n%s\ngot:\n%s", x.s, x.width, x.exp, s)
+		}
+	}
+}
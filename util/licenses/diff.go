@@ -0,0 +1,124 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package licenses
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const (
+	// DiffContext is the number of unchanged words that are shown on each
+	// side of a change in a word diff.
+	DiffContext = 8
+
+	// diffEllipsis marks the unchanged words that were left out.
+	diffEllipsis = "..."
+)
+
+var (
+	// diffCommentRegexp matches the comment markers at the start and at the
+	// end of a line, so that a license header in a source file can be
+	// compared with the plain text of the license.
+	diffCommentRegexp = regexp.MustCompile(`(?m)^[ \t]*(?://+|/\*+|\*+/?|#+|--+|;+|%+|!|')?|\*+/[ \t]*$`)
+)
+
+// WordDiff returns the differences between the canonical text of a license and
+// the text that was found, one word at a time, in the style of the git diff
+// --word-diff option. Removed words are shown as [-like this-] and added words
+// as {+like this+}. Since the line breaks, the indentation, and the comment
+// markers of a source file are different almost every time, they're ignored,
+// so only the changes to the words themselves are shown. Each change is on its
+// own line, with some of the unchanged words around it. It returns the empty
+// string if there are no changes.
+func WordDiff(canonical, found string) string {
+	a := diffWords(canonical)
+	b := diffWords(found)
+
+	dmp := diffmatchpatch.New()
+	// each word goes on its own "line" so that the diff is of whole words
+	r1, r2, lines := dmp.DiffLinesToRunes(strings.Join(a, "\n")+"\n", strings.Join(b, "\n")+"\n")
+	diffs := dmp.DiffCharsToLines(dmp.DiffMainRunes(r1, r2, false), lines)
+
+	changed := false
+	for _, x := range diffs {
+		changed = changed || x.Type != diffmatchpatch.DiffEqual
+	}
+	if !changed {
+		return ""
+	}
+
+	hunks := []string{}
+	hunk := []string{}
+	for i, x := range diffs {
+		words := strings.Fields(x.Text) // the "\n" between each of them
+		switch x.Type {
+		case diffmatchpatch.DiffDelete:
+			hunk = append(hunk, "[-"+strings.Join(words, " ")+"-]")
+			continue
+		case diffmatchpatch.DiffInsert:
+			hunk = append(hunk, "{+"+strings.Join(words, " ")+"+}")
+			continue
+		}
+
+		first, last := i == 0, i == len(diffs)-1
+		if !first && !last && len(words) <= 2*DiffContext {
+			hunk = append(hunk, words...) // too short to split the hunk
+			continue
+		}
+		if !first { // the end of the current hunk
+			n := DiffContext
+			if n > len(words) {
+				n = len(words)
+			}
+			hunk = append(hunk, words[:n]...)
+			if n < len(words) {
+				hunk = append(hunk, diffEllipsis)
+			}
+			hunks = append(hunks, strings.Join(hunk, " "))
+			hunk = []string{}
+		}
+		if !last { // the start of the next hunk
+			n := DiffContext
+			if n > len(words) {
+				n = len(words)
+			}
+			if n < len(words) {
+				hunk = append(hunk, diffEllipsis)
+			}
+			hunk = append(hunk, words[len(words)-n:]...)
+		}
+	}
+	if len(hunk) > 0 {
+		hunks = append(hunks, strings.Join(hunk, " "))
+	}
+	return strings.Join(hunks, "\n")
+}
+
+// diffWords returns the words of a text without any of its comment markers.
+func diffWords(s string) []string {
+	return strings.Fields(diffCommentRegexp.ReplaceAllString(s, ""))
+}
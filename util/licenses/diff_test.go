@@ -0,0 +1,69 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package licenses_test
+
+import (
+	"testing"
+
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+func TestWordDiff(t *testing.T) {
+	canonical := `Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:`
+
+	// the same words, wrapped differently, inside of a comment
+	reflowed := `/*
+ * Permission is hereby granted, free of charge, to any person obtaining a
+ * copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to permit
+ * persons to whom the Software is furnished to do so, subject to the
+ * following conditions:
+ */`
+	if s := licenses.WordDiff(canonical, reflowed); s != "" {
+		t.Errorf("expected no diff, got: %s", s)
+	}
+
+	restricted := `// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software for non-commercial purposes only, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:`
+	expected := `... files (the "Software"), to deal in the Software [-without restriction,-] {+for non-commercial purposes only,+} including without limitation the rights to use, copy, modify, merge, publish, distribute, [-sublicense,-] and/or sell copies of the Software, and to ...`
+	if s := licenses.WordDiff(canonical, restricted); s != expected {
+		t.Errorf("unexpected diff:\n%s\nexpected:\n%s", s, expected)
+	}
+
+	changed := canonical + "\nThe above copyright notice shall be included."
+	if s := licenses.WordDiff(canonical, "extra words at the top. "+changed); s != "{+extra words at the top.+} Permission is hereby granted, free of charge, to ...\n... to do so, subject to the following conditions: {+The above copyright notice shall be included.+}" {
+		t.Errorf("unexpected diff: %s", s)
+	}
+}
@@ -139,14 +139,14 @@ type LicenseSPDX struct {
 // we want to have associated here.
 type License struct {
 	// SPDX is the well-known SPDX ID for the license.
-	SPDX string
+	SPDX string `json:"spdx,omitempty"`
 
 	// Origin shows a different license provenance, and associated custom
 	// name. It should probably be a "reverse-dns" style unique identifier.
-	Origin string
+	Origin string `json:"origin,omitempty"`
 	// Custom is a custom string that is a unique identifier for the license
 	// in the aforementioned Origin namespace.
-	Custom string
+	Custom string `json:"custom,omitempty"`
 }
 
 // String returns a string representation of whatever license is specified.
@@ -0,0 +1,141 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package util
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/awslabs/yesiscan/util/errwrap"
+)
+
+var (
+	// smartURITemplateRegexp matches the named args in a template.
+	smartURITemplateRegexp = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// SmartURIFunc returns the link to show for a UID, and true, or false if it
+// doesn't know about this kind of UID.
+type SmartURIFunc func(uid string) (string, bool)
+
+// SmartURIFuncs is a list of handlers which are tried in order before the
+// built-in ones, so that internal hosts can link to their own code browsers.
+// They're passed to each report that is rendered, so that the handlers of one
+// scan never leak into another. A nil list only uses the built-in ones.
+type SmartURIFuncs []SmartURIFunc
+
+// NewSmartURIFuncs returns the handlers for these templates, in the same order.
+// It errors if any of the templates aren't valid.
+func NewSmartURIFuncs(templates []*SmartURITemplate) (SmartURIFuncs, error) {
+	fns := SmartURIFuncs{}
+	for _, x := range templates {
+		if x == nil {
+			continue
+		}
+		fn, err := x.Func()
+		if err != nil {
+			return nil, err
+		}
+		fns = append(fns, fn)
+	}
+	return fns, nil
+}
+
+// SmartURI returns the link from the first handler which knows about this UID,
+// or the one from the built-in SmartURI function otherwise.
+func (obj SmartURIFuncs) SmartURI(uid string) string {
+	for _, fn := range obj {
+		if s, ok := fn(uid); ok {
+			return s
+		}
+	}
+	return SmartURI(uid)
+}
+
+// SmartURITemplate builds links to an internal code browser, such as
+// Sourcegraph, CodeCatalyst, or cgit, from the UID's that match it. The UID is
+// matched without its scheme and query, so a git UID such as
+// git://git.example.com/team/repo/src/main.go?sha1=abc is matched as
+// git.example.com/team/repo/src/main.go and a local file is matched as its
+// absolute path.
+type SmartURITemplate struct {
+	// Match is the regular expression that the UID must match. The names
+	// of its named groups, such as (?P<repo>[^/]+/[^/]+), can be used in
+	// the template.
+	Match string `json:"match"`
+
+	// Template is the link, with the named groups of the match, and the
+	// {host}, {path} and {sha1} of the UID, in curly brackets. If a named
+	// group is called path, then it replaces the full path.
+	Template string `json:"template"`
+}
+
+// Func returns the handler for this template. It errors if the template isn't
+// valid.
+func (obj *SmartURITemplate) Func() (SmartURIFunc, error) {
+	if obj.Match == "" || obj.Template == "" {
+		return nil, fmt.Errorf("a smart uri needs a match and a template")
+	}
+	re, err := regexp.Compile(obj.Match)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "invalid smart uri match")
+	}
+	names := map[string]struct{}{"host": {}, "path": {}, "sha1": {}}
+	for _, x := range re.SubexpNames() {
+		names[x] = struct{}{}
+	}
+	for _, m := range smartURITemplateRegexp.FindAllStringSubmatch(obj.Template, -1) {
+		if _, exists := names[m[1]]; !exists {
+			return nil, fmt.Errorf("unknown name in smart uri template: %s", m[0])
+		}
+	}
+
+	template := obj.Template
+	return func(uid string) (string, bool) {
+		u, err := url.Parse(uid)
+		if err != nil {
+			return "", false
+		}
+		s := u.Host + u.Path
+		m := re.FindStringSubmatch(s)
+		if m == nil {
+			return "", false
+		}
+		args := map[string]string{
+			"host": u.Host,
+			"path": strings.TrimPrefix(u.Path, "/"),
+			"sha1": u.Query().Get("sha1"),
+		}
+		for i, name := range re.SubexpNames() {
+			if name != "" {
+				args[name] = m[i]
+			}
+		}
+		return smartURITemplateRegexp.ReplaceAllStringFunc(template, func(x string) string {
+			return args[x[1:len(x)-1]]
+		}), true
+	}, nil
+}
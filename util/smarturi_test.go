@@ -0,0 +1,149 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package util_test
+
+import (
+	"testing"
+
+	"github.com/awslabs/yesiscan/util"
+)
+
+func TestSmartURITemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template *util.SmartURITemplate
+		uid      string
+		link     string
+		ok       bool
+	}{
+		{
+			name: "match",
+			template: &util.SmartURITemplate{
+				Match:    `^git\.example\.com/(?P<repo>[^/]+/[^/]+)/(?P<file>.*)$`,
+				Template: "https://code.example.com/{repo}/blob/{sha1}/{file}",
+			},
+			uid:  "git://git.example.com/team/repo/src/main.go?sha1=abc123",
+			link: "https://code.example.com/team/repo/blob/abc123/src/main.go",
+			ok:   true,
+		},
+		{
+			name: "match a local file",
+			template: &util.SmartURITemplate{
+				Match:    `^/srv/code/`,
+				Template: "https://browse.example.com/{path}",
+			},
+			uid:  "file:///srv/code/main.go",
+			link: "https://browse.example.com/srv/code/main.go",
+			ok:   true,
+		},
+		{
+			name: "no match",
+			template: &util.SmartURITemplate{
+				Match:    `^git\.example\.com/`,
+				Template: "https://code.example.com/{path}",
+			},
+			uid: "git://github.com/awslabs/yesiscan/main.go?sha1=abc123",
+			ok:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, err := tt.template.Func()
+			if err != nil {
+				t.Fatalf("error building the func: %+v", err)
+			}
+			link, ok := fn(tt.uid)
+			if ok != tt.ok {
+				t.Fatalf("expected ok to be %t, got %t", tt.ok, ok)
+			}
+			if link != tt.link {
+				t.Errorf("expected link %q, got %q", tt.link, link)
+			}
+		})
+	}
+}
+
+func TestSmartURITemplateInvalid(t *testing.T) {
+	tests := map[string]*util.SmartURITemplate{
+		"unknown name": {
+			Match:    `^git\.example\.com/(?P<repo>[^/]+)/`,
+			Template: "https://code.example.com/{repository}/{path}",
+		},
+		"invalid match": {
+			Match:    `^git\.example\.com/(`,
+			Template: "https://code.example.com/{path}",
+		},
+		"empty template": {
+			Match: `^git\.example\.com/`,
+		},
+	}
+
+	for name, template := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := template.Func(); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestSmartURIFuncs(t *testing.T) {
+	smartURIs, err := util.NewSmartURIFuncs([]*util.SmartURITemplate{
+		{
+			Match:    `^github\.com/awslabs/`,
+			Template: "https://first.example.com/{path}",
+		},
+		nil, // skipped
+		{
+			Match:    `^github\.com/`,
+			Template: "https://second.example.com/{path}",
+		},
+	})
+	if err != nil {
+		t.Fatalf("error building the funcs: %+v", err)
+	}
+
+	// the first one that matches wins
+	if s, exp := smartURIs.SmartURI("git://github.com/awslabs/yesiscan/main.go"), "https://first.example.com/awslabs/yesiscan/main.go"; s != exp {
+		t.Errorf("expected %q, got %q", exp, s)
+	}
+	if s, exp := smartURIs.SmartURI("git://github.com/purpleidea/mgmt/main.go"), "https://second.example.com/purpleidea/mgmt/main.go"; s != exp {
+		t.Errorf("expected %q, got %q", exp, s)
+	}
+
+	// without any, the built-in ones are used
+	var none util.SmartURIFuncs
+	uid := "git://github.com/awslabs/yesiscan/main.go?sha1=abc123"
+	if s, exp := none.SmartURI(uid), util.SmartURI(uid); s != exp {
+		t.Errorf("expected %q, got %q", exp, s)
+	}
+	if s := none.SmartURI("file:///tmp/main.go"); s != "file:///tmp/main.go" {
+		t.Errorf("expected the uid unchanged, got %q", s)
+	}
+
+	if _, err := util.NewSmartURIFuncs([]*util.SmartURITemplate{{Match: "x", Template: "{nope}"}}); err == nil {
+		t.Errorf("expected an error for an unknown name")
+	}
+}
@@ -59,7 +59,8 @@ func HtmlHyperlinkEncode(display string, uri string) string {
 // SmartURI returns a "smart" URI given an internal UID that we have. The UID is
 // the special string that's the unique identifier that's returned from each
 // backend. We convert this into a "better" URI if we can. If we can't, we just
-// return the uid unchanged.
+// return the uid unchanged. To try some handlers for internal hosts first, use
+// the SmartURI method of SmartURIFuncs instead.
 // TODO: the different helper functions that are called within could be provided
 // by each backend, instead of us writing them here and assuming how they work.
 func SmartURI(uid string) string {
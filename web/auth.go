@@ -0,0 +1,196 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/awslabs/yesiscan/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// AuthPrefix is the path that the auth gets all of the requests under,
+	// so that it can handle the steps of logging in. These don't need a
+	// logged in user.
+	AuthPrefix = "/auth/"
+)
+
+// Auth decides who is making each request to the web server. When the server
+// has one, every page except the static files, the ping, and the ones under
+// AuthPrefix needs a logged in user, and each report can only be seen by the
+// user who ran the scan, and by the admins.
+type Auth interface {
+	fmt.Stringer
+
+	// Init runs once before the server starts. The context is cancelled
+	// when the server shuts down.
+	Init(ctx context.Context) error
+
+	// Handle gets each request under AuthPrefix, and it's where any of the
+	// redirects from an identity provider come back to.
+	Handle(c *gin.Context)
+
+	// User returns the name of the user who made this request, or an empty
+	// string if they're not logged in. It errors if they tried to log in
+	// and failed.
+	User(c *gin.Context) (string, error)
+
+	// Login responds to a request which is not logged in. It should lead a
+	// browser through logging in, and then back to the page it was on.
+	Login(c *gin.Context)
+}
+
+// TokenAuth is an Auth with a fixed set of secret tokens, each of which belongs
+// to one user. Programs send the token as a bearer token in the Authorization
+// header. Browsers use http basic auth, with the user name and the token as the
+// password.
+type TokenAuth struct {
+	// Tokens is a map of token to the name of the user that it belongs to.
+	Tokens map[string]string
+}
+
+// String returns a human readable name for this auth.
+func (obj *TokenAuth) String() string {
+	return "token"
+}
+
+// Init checks that there are some tokens.
+func (obj *TokenAuth) Init(ctx context.Context) error {
+	if len(obj.Tokens) == 0 {
+		return fmt.Errorf("there are no tokens")
+	}
+	for token, user := range obj.Tokens {
+		if token == "" || user == "" {
+			return fmt.Errorf("empty token or user")
+		}
+	}
+	return nil
+}
+
+// Handle has nothing to do, since there are no steps to logging in.
+func (obj *TokenAuth) Handle(c *gin.Context) {
+	c.Status(http.StatusNotFound)
+}
+
+// User returns the user of the token in the request. Each token is compared in
+// constant time so that the timing doesn't give any of them away.
+func (obj *TokenAuth) User(c *gin.Context) (string, error) {
+	token := ""
+	name, password, basic := c.Request.BasicAuth()
+	if basic {
+		token = password
+	} else if s := c.GetHeader("Authorization"); strings.HasPrefix(s, "Bearer ") {
+		token = strings.TrimSpace(strings.TrimPrefix(s, "Bearer "))
+	} else {
+		return "", nil // not logged in
+	}
+
+	user := ""
+	for k, v := range obj.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(k)) == 1 {
+			user = v
+		}
+	}
+	if user == "" || (basic && name != user) {
+		return "", fmt.Errorf("invalid token")
+	}
+	return user, nil
+}
+
+// Login asks a browser for the user name and token.
+func (obj *TokenAuth) Login(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Basic realm="yesiscan", charset="UTF-8"`)
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"message": "a token is required",
+	})
+}
+
+// authenticate is the middleware that finds the user of each request when we
+// have an Auth. The user is stored under gin.AuthUserKey, and requests without
+// one don't get any further.
+func (obj *Server) authenticate(c *gin.Context) {
+	if obj.Auth == nil {
+		return
+	}
+	user, err := obj.Auth.User(c)
+	if err != nil {
+		obj.Logf("auth: %s: %+v", c.Request.URL.Path, err)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"message": "could not log in",
+		})
+		return
+	}
+	if user == "" {
+		obj.Auth.Login(c)
+		c.Abort()
+		return
+	}
+	c.Set(gin.AuthUserKey, user)
+}
+
+// allowed returns true if the user of this request may see something that was
+// made by the owner. Anything without an owner is from before the auth was
+// turned on, so every user may see it.
+func (obj *Server) allowed(c *gin.Context, owner string) bool {
+	if obj.Auth == nil || owner == "" {
+		return true
+	}
+	user := c.GetString(gin.AuthUserKey)
+	return user == owner || util.StrInList(user, obj.Admins)
+}
+
+// loadReport loads the report with this uid, if the user of this request may
+// see it. Otherwise it errors as if there was no such report, so that nobody
+// can find out which ones exist.
+func (obj *Server) loadReport(c *gin.Context, uid string) (*Report, error) {
+	report, err := obj.Load(uid)
+	if err != nil && obj.Auth != nil {
+		obj.Logf("auth: %s could not load report %s: %+v", c.GetString(gin.AuthUserKey), uid, err)
+		return nil, fmt.Errorf("no such report")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !obj.allowed(c, report.Owner) {
+		obj.Logf("auth: %s may not see report %s", c.GetString(gin.AuthUserKey), uid)
+		return nil, fmt.Errorf("no such report")
+	}
+	return report, nil
+}
+
+// loadJob returns the scan with this uid, if there is one, and if the user of
+// this request may see it.
+func (obj *Server) loadJob(c *gin.Context, uid string) *job {
+	j := obj.getJob(uid)
+	if j == nil || !obj.allowed(c, j.owner) {
+		return nil
+	}
+	return j
+}
@@ -0,0 +1,198 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awslabs/yesiscan/web"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authRouter returns a router with one page which shows who is logged in.
+func authRouter(auth web.Auth) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Any(web.AuthPrefix+"*path", auth.Handle)
+	router.GET("/private", func(c *gin.Context) {
+		user, err := auth.User(c)
+		if err != nil {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		if user == "" {
+			auth.Login(c)
+			return
+		}
+		c.String(http.StatusOK, user)
+	})
+	return router
+}
+
+func TestTokenAuth(t *testing.T) {
+	auth := &web.TokenAuth{
+		Tokens: map[string]string{
+			"s3cret": "alice",
+		},
+	}
+	if err := auth.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	router := authRouter(auth)
+
+	tests := []struct {
+		header string
+		code   int
+		body   string
+	}{
+		{"", http.StatusUnauthorized, ""},
+		{"Bearer s3cret", http.StatusOK, "alice"},
+		{"Bearer wrong", http.StatusUnauthorized, ""},
+		{"Basic " + base64.StdEncoding.EncodeToString([]byte("alice:s3cret")), http.StatusOK, "alice"},
+		{"Basic " + base64.StdEncoding.EncodeToString([]byte("bob:s3cret")), http.StatusUnauthorized, ""},
+	}
+	for i, x := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/private", nil)
+		if x.header != "" {
+			req.Header.Set("Authorization", x.header)
+		}
+		router.ServeHTTP(w, req)
+		if w.Code != x.code || (x.body != "" && w.Body.String() != x.body) {
+			t.Errorf("test %d: got: %d %s", i, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestOIDCAuth(t *testing.T) {
+	nonce := "" // what the provider puts in the next id token
+	var provider *httptest.Server
+	provider = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 provider.URL,
+				"authorization_endpoint": provider.URL + "/authorize",
+				"token_endpoint":         provider.URL + "/token",
+			})
+		case "/token":
+			if id, secret, _ := req.BasicAuth(); id != "yesiscan" || secret != "hunter2" || req.FormValue("code") != "abc" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			claims, _ := json.Marshal(map[string]interface{}{
+				"iss":   provider.URL,
+				"aud":   "yesiscan",
+				"exp":   time.Now().Add(time.Hour).Unix(),
+				"nonce": nonce,
+				"email": "alice@example.com",
+			})
+			token := "e30." + base64.RawURLEncoding.EncodeToString(claims) + ".sig"
+			json.NewEncoder(w).Encode(map[string]string{"id_token": token})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer provider.Close()
+
+	auth := &web.OIDCAuth{
+		Logf:         t.Logf,
+		Issuer:       provider.URL,
+		ClientID:     "yesiscan",
+		ClientSecret: "hunter2",
+		RedirectURL:  "http://example.com/auth/callback",
+	}
+	if err := auth.Init(context.Background()); err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	router := authRouter(auth)
+
+	// we get sent to the provider
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/private?x=1", nil))
+	u, err := url.Parse(w.Header().Get("Location"))
+	if w.Code != http.StatusFound || err != nil || !strings.HasPrefix(u.String(), provider.URL+"/authorize?") {
+		t.Errorf("expected a redirect to the provider, got: %d %s", w.Code, u)
+		return
+	}
+	login := w.Result().Cookies()
+
+	// and it sends us back to the callback
+	callback := func(state string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/auth/callback?code=abc&state=%s", state), nil)
+		for _, x := range cookies {
+			req.AddCookie(x)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+	nonce = "wrong"
+	if w := callback(u.Query().Get("state"), login); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the wrong nonce to fail, got: %d", w.Code)
+	}
+	nonce = u.Query().Get("nonce")
+	if w := callback("forged", login); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the wrong state to fail, got: %d", w.Code)
+	}
+	w = callback(u.Query().Get("state"), login)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/private?x=1" {
+		t.Errorf("expected a redirect back, got: %d %s", w.Code, w.Header().Get("Location"))
+		return
+	}
+
+	// now we're logged in
+	w2 := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/private", nil)
+	for _, x := range w.Result().Cookies() {
+		if x.Name == web.YesiscanCookieNameSession {
+			req.AddCookie(x)
+		}
+	}
+	router.ServeHTTP(w2, req)
+	if w2.Code != http.StatusOK || w2.Body.String() != "alice@example.com" {
+		t.Errorf("expected to be logged in, got: %d %s", w2.Code, w2.Body.String())
+	}
+
+	// a made up session doesn't work
+	w3 := httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/private", nil)
+	req.AddCookie(&http.Cookie{Name: web.YesiscanCookieNameSession, Value: "YWxpY2U.OTk5OTk5OTk5OQ.c2ln"})
+	router.ServeHTTP(w3, req)
+	if w3.Code != http.StatusFound {
+		t.Errorf("expected a forged session to need a login, got: %d", w3.Code)
+	}
+}
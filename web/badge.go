@@ -0,0 +1,130 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/awslabs/yesiscan/lib"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// BadgeColorOk is the color of a badge for a passing scan.
+	BadgeColorOk = "#4c1"
+
+	// BadgeColorWarn is the color of a badge for a scan with warnings.
+	BadgeColorWarn = "#dfb317"
+
+	// BadgeColorFail is the color of a badge for a scan with violations.
+	BadgeColorFail = "#e05d44"
+
+	// BadgeColorUnknown is the color of a badge when there's no report.
+	BadgeColorUnknown = "#9f9f9f"
+)
+
+// Badge returns the label, the message, and the color of the status badge for
+// this report. A nil summary means that there's no report to show.
+func (obj *ReportSummary) Badge() (string, string, string) {
+	if obj == nil {
+		return "licenses", "unknown", BadgeColorUnknown
+	}
+	if obj.Violations > 0 {
+		return "violations", strconv.Itoa(obj.Violations), BadgeColorFail
+	}
+	switch obj.Verdict {
+	case lib.VerdictPass:
+		return "licenses", "ok", BadgeColorOk
+	case lib.VerdictWarn:
+		return "licenses", "warn", BadgeColorWarn
+	case lib.VerdictFail:
+		return "licenses", "fail", BadgeColorFail
+	}
+	return "licenses", "unknown", BadgeColorUnknown // older reports
+}
+
+// BadgeSVG returns a flat badge in the common style with the label on the left
+// in grey, and the message on the right in this color.
+func BadgeSVG(label, message, color string) string {
+	// There's no font metrics here, so this is an estimate for the 11px
+	// sans-serif font, which is good enough for short strings.
+	width := func(s string) int { return 10 + 7*len([]rune(s)) }
+	lw, mw := width(label), width(message)
+	label, message = html.EscapeString(label), html.EscapeString(message)
+
+	s := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`, lw+mw, label, message)
+	s += fmt.Sprintf(`<title>%s: %s</title>`, label, message)
+	s += `<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`
+	s += fmt.Sprintf(`<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`, lw+mw)
+	s += `<g clip-path="url(#r)">`
+	s += fmt.Sprintf(`<rect width="%d" height="20" fill="#555"/>`, lw)
+	s += fmt.Sprintf(`<rect x="%d" width="%d" height="20" fill="%s"/>`, lw, mw, html.EscapeString(color))
+	s += fmt.Sprintf(`<rect width="%d" height="20" fill="url(#s)"/>`, lw+mw)
+	s += `</g>`
+	s += `<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`
+	s += fmt.Sprintf(`<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`, lw/2, label, lw/2, label)
+	s += fmt.Sprintf(`<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`, lw+mw/2, message, lw+mw/2, message)
+	s += `</g></svg>`
+	return s
+}
+
+// badge is the handler for the status badge of the most recent stored report
+// of a uri. For example: /badge/?uri=https://github.com/purpleidea/mgmt/ and
+// it is always an svg, even if there's no such report, so that the image in a
+// README isn't broken.
+func (obj *Server) badge(c *gin.Context) {
+	uri := strings.TrimSpace(c.Query("uri"))
+	if uri == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "missing uri",
+		})
+		return
+	}
+
+	var latest *ReportSummary // newest first, so the first match wins
+	summaries, err := obj.listReports(c.Request.Context())
+	if err != nil {
+		obj.Logf("badge: %+v", err)
+	}
+	for _, x := range summaries {
+		if x.Uri != uri {
+			continue
+		}
+		if !obj.PublicBadges && !obj.allowed(c, x.Owner) {
+			continue
+		}
+		latest = x
+		break
+	}
+
+	// Badges get embedded elsewhere, so ask the caches to check back.
+	c.Header("Cache-Control", "no-cache, max-age=0")
+	label, message, color := latest.Badge()
+	c.Data(http.StatusOK, "image/svg+xml; charset=utf-8", []byte(BadgeSVG(label, message, color)))
+}
@@ -0,0 +1,68 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web_test
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/web"
+)
+
+func TestBadge(t *testing.T) {
+	tests := []struct {
+		summary *web.ReportSummary
+		label   string
+		message string
+		color   string
+	}{
+		{nil, "licenses", "unknown", web.BadgeColorUnknown},
+		{&web.ReportSummary{}, "licenses", "unknown", web.BadgeColorUnknown},
+		{&web.ReportSummary{Verdict: lib.VerdictPass}, "licenses", "ok", web.BadgeColorOk},
+		{&web.ReportSummary{Verdict: lib.VerdictWarn}, "licenses", "warn", web.BadgeColorWarn},
+		{&web.ReportSummary{Verdict: lib.VerdictFail, Violations: 3}, "violations", "3", web.BadgeColorFail},
+	}
+	for i, tc := range tests {
+		label, message, color := tc.summary.Badge()
+		if label != tc.label || message != tc.message || color != tc.color {
+			t.Errorf("test %d: expected: %s %s %s, got: %s %s %s", i, tc.label, tc.message, tc.color, label, message, color)
+		}
+	}
+
+	s := web.BadgeSVG("licenses", "<ok & fine>", web.BadgeColorOk)
+	d := xml.NewDecoder(strings.NewReader(s))
+	for {
+		if _, err := d.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("invalid svg: %+v: %s", err, s)
+		}
+	}
+	if !strings.Contains(s, "&lt;ok &amp; fine&gt;") {
+		t.Errorf("expected an escaped message: %s", s)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// curationsFile returns the path of the curations file that the reviewers save
+// their decisions to, and that each scan applies.
+func (obj *Server) curationsFile() (string, error) {
+	if obj.CurationsPath != "" {
+		return obj.CurationsPath, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config/", obj.Program+"/", lib.CurationsFileName), nil
+}
+
+// loadCurations returns the current curations, or none if the file doesn't
+// exist yet.
+func (obj *Server) loadCurations() (map[string]*lib.Curation, error) {
+	filename, err := obj.curationsFile()
+	if err != nil {
+		return nil, err
+	}
+	curations, err := lib.LoadCurations(filename)
+	if os.IsNotExist(err) {
+		return map[string]*lib.Curation{}, nil
+	}
+	return curations, err
+}
+
+// curate saves the decision of a reviewer about a file in a stored report as a
+// curation of its content hash. It returns the report uid to go back to.
+func (obj *Server) curate(c *gin.Context) (string, error) {
+	r := c.PostForm("r")
+	report, err := obj.loadReport(c, r)
+	if err != nil {
+		return "", err
+	}
+	uid := c.PostForm("uid")
+	hash, exists := report.Hashes[uid]
+	if !exists {
+		return "", fmt.Errorf("no file with content in this report: %s", uid)
+	}
+
+	ls := []string{}
+	for _, x := range strings.Split(c.PostForm("licenses"), ",") {
+		if x = strings.TrimSpace(x); x != "" {
+			ls = append(ls, x)
+		}
+	}
+	curation := &lib.Curation{
+		Decision: c.PostForm("decision"),
+		Licenses: ls,
+		Comment:  strings.TrimSpace(c.PostForm("comment")),
+		Reviewer: c.GetString(gin.AuthUserKey),
+		Time:     time.Now().UTC().Format(time.RFC3339),
+		UID:      uid,
+	}
+	if err := curation.Validate(); err != nil {
+		return "", err
+	}
+
+	filename, err := obj.curationsFile()
+	if err != nil {
+		return "", err
+	}
+	obj.curationsMutex.Lock()
+	defer obj.curationsMutex.Unlock()
+	if err := lib.SaveCuration(filename, hash, curation, obj.Perms); err != nil {
+		return "", err
+	}
+	obj.Logf("curate: %s: %s by %s", uid, curation.Decision, curation.Reviewer)
+
+	return r, nil
+}
+
+// returnCurationsHtml returns the decisions that apply to the files in this
+// report, and the form that reviewers use to add one. The form is only shown
+// when there are reviewers who could submit it.
+func returnCurationsHtml(report *Report, curations map[string]*lib.Curation, uuid string, reviewers bool) string {
+	uids := []string{}
+	for uid := range report.Hashes {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+
+	rows := ""
+	for _, uid := range uids {
+		x, exists := curations[report.Hashes[uid]]
+		if !exists {
+			continue
+		}
+		rows += "<tr><td>"
+		rows += util.HtmlHyperlinkEncode(template.HTMLEscapeString(uid), template.HTMLEscapeString(report.smartURIs.SmartURI(uid)))
+		rows += fmt.Sprintf(" <i>%s</i>", template.HTMLEscapeString(x.Decision))
+		if len(x.Licenses) > 0 {
+			rows += fmt.Sprintf(" %s", template.HTMLEscapeString(strings.Join(x.Licenses, ", ")))
+		}
+		if x.Reviewer != "" {
+			rows += fmt.Sprintf(" by %s", template.HTMLEscapeString(x.Reviewer))
+		}
+		if x.Time != "" {
+			rows += fmt.Sprintf(" at %s", template.HTMLEscapeString(x.Time))
+		}
+		if x.Comment != "" {
+			rows += fmt.Sprintf(": %s", template.HTMLEscapeString(x.Comment))
+		}
+		rows += "</td></tr>"
+	}
+
+	if rows == "" && !reviewers {
+		return ""
+	}
+
+	s := `<table id="report">`
+	s += `<tr><th style="text-align: left">curations:</th></tr>`
+	if rows == "" {
+		rows = "<tr><td><i>no decisions yet</i></td></tr>"
+	}
+	s += rows
+	if reviewers && len(uids) > 0 {
+		s += "<tr><td>"
+		s += `<form action="/curate/" method="post">`
+		s += fmt.Sprintf(`<input type="hidden" name="r" value="%s" />`, template.HTMLEscapeString(uuid))
+		s += `<input type="text" name="uid" list="curation-uids" placeholder="file" style="width: 40%" />`
+		s += `<datalist id="curation-uids">`
+		for _, uid := range uids {
+			s += fmt.Sprintf(`<option value="%s"></option>`, template.HTMLEscapeString(uid))
+		}
+		s += `</datalist> `
+		s += `<select name="decision">`
+		for _, x := range lib.CurationDecisions {
+			s += fmt.Sprintf(`<option value="%s">%s</option>`, x, x)
+		}
+		s += `</select> `
+		s += `<input type="text" name="licenses" placeholder="concluded licenses, eg: MIT, Apache-2.0" style="width: 20%" /> `
+		s += `<input type="text" name="comment" placeholder="comment" style="width: 20%" /> `
+		s += `<input type="submit" value="save" />`
+		s += `</form>`
+		s += "</td></tr>"
+	}
+	s += "</table>"
+	return s + "<br />"
+}
@@ -0,0 +1,448 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awslabs/yesiscan/util/errwrap"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// YesiscanCookieNameSession is the name of the cookie used to store
+	// the signed session of a logged in user.
+	YesiscanCookieNameSession = "yesiscan_session"
+
+	// YesiscanCookieNameLogin is the name of the cookie used to store the
+	// state of a login while the browser is away at the identity provider.
+	YesiscanCookieNameLogin = "yesiscan_login"
+
+	// DefaultOIDCClaim is the claim of the id token that is used as the
+	// user name if none is specified.
+	DefaultOIDCClaim = "email"
+
+	// DefaultSessionDuration is how long a login lasts if no duration is
+	// specified.
+	DefaultSessionDuration = 12 * time.Hour
+
+	// oidcLoginDuration is how long someone has to finish logging in at
+	// the identity provider.
+	oidcLoginDuration = 10 * time.Minute
+
+	// oidcScopes are the scopes that we ask the identity provider for.
+	oidcScopes = "openid email profile"
+)
+
+// OIDCAuth is an Auth which logs users in with an OpenID Connect identity
+// provider, using the authorization code flow. Once they're logged in, the
+// browser gets a signed session cookie which lasts until it expires, or until
+// the server restarts. The id token comes straight from the token endpoint of
+// the provider over https, so as the spec allows, we trust it without checking
+// its signature, but we still check who it was issued by and for.
+type OIDCAuth struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	// Issuer is the url of the identity provider, eg:
+	// https://accounts.example.com and its configuration is discovered
+	// from there.
+	Issuer string
+
+	// ClientID is the id that this server is registered with at the
+	// identity provider.
+	ClientID string
+
+	// ClientSecret is the secret that goes with the ClientID.
+	ClientSecret string
+
+	// RedirectURL is the public url that the identity provider sends the
+	// browser back to, and it must be registered there. It is the callback
+	// under AuthPrefix, eg: https://example.com/auth/callback
+	RedirectURL string
+
+	// Claim is the claim of the id token that is used as the user name. If
+	// it is empty, then DefaultOIDCClaim is used.
+	Claim string
+
+	// SessionDuration is how long a login lasts. If it is zero, then
+	// DefaultSessionDuration is used.
+	SessionDuration time.Duration
+
+	// Client is the http client to talk to the identity provider with. If
+	// it is nil, then the default client is used.
+	Client *http.Client
+
+	authorizationEndpoint string
+	tokenEndpoint         string
+	secret                []byte // signs our cookies
+}
+
+// String returns a human readable name for this auth.
+func (obj *OIDCAuth) String() string {
+	return "oidc"
+}
+
+// Init discovers the configuration of the identity provider, and makes a new
+// secret to sign the cookies with.
+func (obj *OIDCAuth) Init(ctx context.Context) error {
+	if obj.Issuer == "" || obj.ClientID == "" || obj.ClientSecret == "" {
+		return fmt.Errorf("the issuer, client id, and client secret must be set")
+	}
+	if !strings.HasSuffix(obj.RedirectURL, AuthPrefix+"callback") {
+		return fmt.Errorf("the redirect url must end with %scallback", AuthPrefix)
+	}
+
+	u := strings.TrimRight(obj.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	var config struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+	}
+	if err := obj.do(req, &config); err != nil {
+		return errwrap.Wrapf(err, "could not discover the configuration of %s", obj.Issuer)
+	}
+	if strings.TrimRight(config.Issuer, "/") != strings.TrimRight(obj.Issuer, "/") {
+		return fmt.Errorf("the provider says its issuer is %s", config.Issuer)
+	}
+	if config.AuthorizationEndpoint == "" || config.TokenEndpoint == "" {
+		return fmt.Errorf("the provider is missing an endpoint")
+	}
+	obj.Issuer = config.Issuer // the exact one that goes in the tokens
+	obj.authorizationEndpoint = config.AuthorizationEndpoint
+	obj.tokenEndpoint = config.TokenEndpoint
+
+	obj.secret = make([]byte, 32)
+	if _, err := rand.Read(obj.secret); err != nil {
+		return err
+	}
+	if obj.Debug {
+		obj.Logf("oidc: authorization endpoint: %s", obj.authorizationEndpoint)
+		obj.Logf("oidc: token endpoint: %s", obj.tokenEndpoint)
+	}
+	return nil
+}
+
+// Handle finishes a login when the identity provider sends the browser back to
+// the callback, and it also logs the browser out.
+func (obj *OIDCAuth) Handle(c *gin.Context) {
+	switch strings.TrimPrefix(c.Request.URL.Path, AuthPrefix) {
+	case "callback":
+		user, next, err := obj.callback(c)
+		if err != nil {
+			obj.Logf("oidc: login failed: %+v", err)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message": "could not log in",
+			})
+			return
+		}
+		obj.Logf("oidc: logged in: %s", user)
+		expiry := time.Now().Add(obj.sessionDuration())
+		obj.setCookie(c, YesiscanCookieNameSession, obj.sign(YesiscanCookieNameSession, user, strconv.FormatInt(expiry.Unix(), 10)), obj.sessionDuration())
+		c.Redirect(http.StatusFound, next)
+
+	case "logout":
+		obj.setCookie(c, YesiscanCookieNameSession, "", -1)
+		c.Redirect(http.StatusFound, "/")
+
+	default:
+		c.Status(http.StatusNotFound)
+	}
+}
+
+// User returns the user from the session cookie. A session which is invalid or
+// expired is not an error, since they all become invalid when we restart, so
+// those users are asked to log in again.
+func (obj *OIDCAuth) User(c *gin.Context) (string, error) {
+	cookie, err := c.Cookie(YesiscanCookieNameSession)
+	if err != nil {
+		return "", nil // no cookie
+	}
+	fields, ok := obj.verify(cookie)
+	if !ok || len(fields) != 3 || fields[0] != YesiscanCookieNameSession {
+		return "", nil
+	}
+	expiry, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil || time.Now().Unix() > expiry {
+		return "", nil
+	}
+	return fields[1], nil
+}
+
+// Login sends a browser to the identity provider, and remembers where to send
+// it back to afterwards. Other requests are told that they need to log in.
+func (obj *OIDCAuth) Login(c *gin.Context) {
+	if c.Request.Method != http.MethodGet {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "you must log in",
+		})
+		return
+	}
+	state, err := randomHex()
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	nonce, err := randomHex()
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	expiry := time.Now().Add(oidcLoginDuration)
+	login := obj.sign(YesiscanCookieNameLogin, state, nonce, c.Request.URL.RequestURI(), strconv.FormatInt(expiry.Unix(), 10))
+	obj.setCookie(c, YesiscanCookieNameLogin, login, oidcLoginDuration)
+
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", obj.ClientID)
+	values.Set("redirect_uri", obj.RedirectURL)
+	values.Set("scope", oidcScopes)
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	sep := "?"
+	if strings.Contains(obj.authorizationEndpoint, "?") {
+		sep = "&"
+	}
+	c.Redirect(http.StatusFound, obj.authorizationEndpoint+sep+values.Encode())
+}
+
+// callback checks the response from the identity provider, and exchanges the
+// code that it sent for an id token. It returns the user from that token, and
+// the page to send them back to.
+func (obj *OIDCAuth) callback(c *gin.Context) (string, string, error) {
+	if e := c.Query("error"); e != "" {
+		return "", "", fmt.Errorf("the provider returned: %s: %s", e, c.Query("error_description"))
+	}
+	cookie, err := c.Cookie(YesiscanCookieNameLogin)
+	if err != nil {
+		return "", "", fmt.Errorf("no login is in progress")
+	}
+	obj.setCookie(c, YesiscanCookieNameLogin, "", -1) // it's single use
+	fields, ok := obj.verify(cookie)
+	if !ok || len(fields) != 5 || fields[0] != YesiscanCookieNameLogin {
+		return "", "", fmt.Errorf("invalid login cookie")
+	}
+	state, nonce, next := fields[1], fields[2], fields[3]
+	if expiry, err := strconv.ParseInt(fields[4], 10, 64); err != nil || time.Now().Unix() > expiry {
+		return "", "", fmt.Errorf("the login expired")
+	}
+	if c.Query("state") != state {
+		return "", "", fmt.Errorf("the state doesn't match")
+	}
+	// only send them back to one of our own pages
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		next = "/"
+	}
+
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", c.Query("code"))
+	values.Set("redirect_uri", obj.RedirectURL)
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, obj.tokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(obj.ClientID), url.QueryEscape(obj.ClientSecret))
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := obj.do(req, &token); err != nil {
+		return "", "", errwrap.Wrapf(err, "could not exchange the code")
+	}
+
+	user, err := obj.parseIDToken(token.IDToken, nonce)
+	if err != nil {
+		return "", "", errwrap.Wrapf(err, "invalid id token")
+	}
+	return user, next, nil
+}
+
+// parseIDToken checks the claims of an id token, and returns the user name.
+func (obj *OIDCAuth) parseIDToken(token, nonce string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("not a jwt")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", err
+	}
+	claims := make(map[string]interface{})
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return "", err
+	}
+
+	if iss, _ := claims["iss"].(string); iss != obj.Issuer {
+		return "", fmt.Errorf("issued by %s", iss)
+	}
+	audience := false
+	switch aud := claims["aud"].(type) {
+	case string:
+		audience = aud == obj.ClientID
+	case []interface{}:
+		for _, x := range aud {
+			if s, _ := x.(string); s == obj.ClientID {
+				audience = true
+			}
+		}
+	}
+	if !audience {
+		return "", fmt.Errorf("issued for someone else")
+	}
+	if exp, _ := claims["exp"].(float64); time.Now().Unix() > int64(exp) {
+		return "", fmt.Errorf("expired")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return "", fmt.Errorf("the nonce doesn't match")
+	}
+
+	claim := obj.Claim
+	if claim == "" {
+		claim = DefaultOIDCClaim
+	}
+	user, _ := claims[claim].(string)
+	if user == "" {
+		return "", fmt.Errorf("no %s claim", claim)
+	}
+	if verified, exists := claims["email_verified"].(bool); claim == "email" && exists && !verified {
+		return "", fmt.Errorf("the email is not verified")
+	}
+	return user, nil
+}
+
+// do runs the request and decodes the json response into out.
+func (obj *OIDCAuth) do(req *http.Request, out interface{}) error {
+	client := obj.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", req.URL, resp.Status)
+	}
+	return json.Unmarshal(b, out)
+}
+
+// sessionDuration returns how long a login lasts.
+func (obj *OIDCAuth) sessionDuration() time.Duration {
+	if obj.SessionDuration > 0 {
+		return obj.SessionDuration
+	}
+	return DefaultSessionDuration
+}
+
+// setCookie sets one of our cookies, or removes it if maxAge is negative. They
+// are only sent over https if that's what we're served with.
+func (obj *OIDCAuth) setCookie(c *gin.Context, name, value string, maxAge time.Duration) {
+	seconds := int(maxAge.Seconds())
+	if maxAge < 0 {
+		seconds = -1
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		MaxAge:   seconds,
+		Path:     "/",
+		Secure:   strings.HasPrefix(obj.RedirectURL, "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode, // sent back from the provider
+	})
+}
+
+// sign encodes the fields and signs them, so that we can trust them when we get
+// them back in a cookie. The first field says what it's for, so that one kind
+// of cookie can't be used as another.
+func (obj *OIDCAuth) sign(fields ...string) string {
+	parts := []string{}
+	for _, x := range fields {
+		parts = append(parts, base64.RawURLEncoding.EncodeToString([]byte(x)))
+	}
+	s := strings.Join(parts, ".")
+	mac := hmac.New(sha256.New, obj.secret)
+	mac.Write([]byte(s))
+	return s + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks the signature of something from sign, and returns its fields.
+func (obj *OIDCAuth) verify(s string) ([]string, bool) {
+	i := strings.LastIndex(s, ".")
+	if i < 0 || len(obj.secret) == 0 {
+		return nil, false
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(s[i+1:])
+	if err != nil {
+		return nil, false
+	}
+	mac := hmac.New(sha256.New, obj.secret)
+	mac.Write([]byte(s[:i]))
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return nil, false
+	}
+	fields := []string{}
+	for _, x := range strings.Split(s[:i], ".") {
+		b, err := base64.RawURLEncoding.DecodeString(x)
+		if err != nil {
+			return nil, false
+		}
+		fields = append(fields, string(b))
+	}
+	return fields, true
+}
+
+// randomHex returns 16 random bytes in hex.
+func randomHex() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
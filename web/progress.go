@@ -0,0 +1,258 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/lib"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// progressInterval is how often we send the progress of a running scan
+	// to the pages that are waiting for it.
+	progressInterval = 1 * time.Second
+
+	// jobRetention is how long we remember a finished scan, so that a page
+	// which was waiting for it can still find its report.
+	jobRetention = 1 * time.Hour
+
+	// ErrCancelled is the error of a scan that was cancelled before it
+	// finished.
+	ErrCancelled = interfaces.Error("the scan was cancelled")
+)
+
+// job is a scan which runs in the background while the user waits for it on
+// the pending report page.
+type job struct {
+	uri      string
+	owner    string
+	progress *lib.Progress
+	cancel   func() // stops the scan
+
+	// done is closed once the scan has finished. The fields below it are
+	// only valid after that.
+	done     chan struct{}
+	finished time.Time
+	report   string // the uid of the stored report
+	err      error
+}
+
+// startJob runs the scan in the background and returns the uid that it can be
+// found at while it runs. Only the owner can see it, if there is an auth. The
+// scan is stopped if the server shuts down, or if it gets cancelled.
+func (obj *Server) startJob(uri, owner string, progress *lib.Progress, scan func(context.Context) (string, error)) (string, error) {
+	b := make([]byte, 32) // same length as the report uids
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	uid := hex.EncodeToString(b)
+	ctx := obj.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	j := &job{
+		uri:      uri,
+		owner:    owner,
+		progress: progress,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	obj.jobsMutex.Lock()
+	if obj.jobs == nil {
+		obj.jobs = make(map[string]*job)
+	}
+	if obj.MaxConcurrentScans > 0 && obj.runningJobs() >= obj.MaxConcurrentScans {
+		obj.jobsMutex.Unlock()
+		cancel()
+		obj.Logf("limit: too many scans are running")
+		return "", &limitError{
+			status:     http.StatusServiceUnavailable,
+			retryAfter: busyRetryAfter,
+			msg:        "too many scans are running, try again later",
+		}
+	}
+	for k, x := range obj.jobs { // forget the old ones
+		select {
+		case <-x.done:
+			if time.Since(x.finished) > jobRetention {
+				delete(obj.jobs, k)
+			}
+		default:
+		}
+	}
+	obj.jobs[uid] = j
+	obj.jobsMutex.Unlock()
+
+	go func() {
+		defer cancel() // release the context
+		report, err := scan(ctx)
+		if err != nil && ctx.Err() == context.Canceled {
+			err = ErrCancelled // the real error is just noise
+		}
+		if err != nil {
+			obj.Logf("scan: %s: %+v", uri, err)
+		}
+		j.report, j.err = report, err
+		j.finished = time.Now()
+		close(j.done)
+	}()
+
+	return uid, nil
+}
+
+// cancelJob stops the scan if it's still running. It returns false if it had
+// already finished.
+func (obj *Server) cancelJob(j *job) bool {
+	select {
+	case <-j.done:
+		return false
+	default:
+	}
+	j.cancel()
+	return true
+}
+
+// getJob returns the scan with this uid, or nil if there isn't one.
+func (obj *Server) getJob(uid string) *job {
+	obj.jobsMutex.Lock()
+	defer obj.jobsMutex.Unlock()
+	return obj.jobs[uid]
+}
+
+// streamProgress sends the progress of a scan as server-sent events until it
+// finishes. Each "progress" event is a lib.ProgressStatus in json, and the last
+// event is either "done" with the url of the report, or "failed" with an error
+// message.
+func (obj *Server) streamProgress(c *gin.Context, j *job) {
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // don't let a proxy hold them back
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	first := true
+	c.Stream(func(w io.Writer) bool {
+		if first { // don't make them wait for the first one
+			first = false
+			c.SSEvent("progress", j.progress.Status())
+			return true
+		}
+		select {
+		case <-j.done:
+			if j.err != nil {
+				c.SSEvent("failed", j.err.Error())
+				return false
+			}
+			c.SSEvent("progress", j.progress.Status())
+			c.SSEvent("done", fmt.Sprintf("/report/?r=%s", j.report))
+			return false
+		case <-ticker.C:
+			c.SSEvent("progress", j.progress.Status())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// returnJobHtml returns the body of the report page for a scan that is running
+// or that failed. If it has finished successfully, it redirects to the report
+// instead, and returns false.
+func (obj *Server) returnJobHtml(c *gin.Context, uid string, j *job) (string, bool) {
+	select {
+	case <-j.done:
+		if j.err == nil {
+			c.Redirect(http.StatusFound, fmt.Sprintf("/report/?r=%s", j.report))
+			return "", false
+		}
+		e := `<table id="error">`
+		e += fmt.Sprintf(`<tr><th style="text-align: center"><i>%s</i></th></tr>`, template.HTMLEscapeString(j.err.Error()))
+		e += "</table>"
+		return e, true
+	default:
+	}
+
+	s := `<table id="report">`
+	s += fmt.Sprintf(`<tr><th style="text-align: left">scanning: %s</th></tr>`, template.HTMLEscapeString(j.uri))
+	s += `<tr><td><progress id="progress" style="width: 100%"></progress></td></tr>`
+	s += `<tr><td><i id="progress-status">starting...</i></td></tr>`
+	s += fmt.Sprintf(`<tr><td><form action="/cancel/?r=%s" method="post"><input type="submit" value="cancel"></form></td></tr>`, uid)
+	s += "</table>"
+	s += fmt.Sprintf(`<script>var progressURL = "/progress/?r=%s";</script>`, uid)
+	s += progressScript
+	return s, true
+}
+
+// progressScript follows the progress events of a running scan, and shows the
+// report once it is done. If the scan has a file limit, then the bar fills up
+// towards it, otherwise it stays indeterminate.
+var progressScript = `<script>
+(function() {
+	var bar = document.getElementById("progress");
+	var status = document.getElementById("progress-status");
+	function mib(n) {
+		return (n / 1048576).toFixed(1) + " MiB";
+	}
+	var source = new EventSource(progressURL);
+	source.addEventListener("progress", function(e) {
+		var p = JSON.parse(e.data);
+		if (p.max_files > 0) {
+			bar.max = p.max_files;
+			bar.value = p.files;
+		}
+		var s = p.files + " files, " + mib(p.bytes) + " read";
+		if (p.downloaded > 0) {
+			s += ", " + mib(p.downloaded) + " downloaded";
+		}
+		if (p.iterator) {
+			s += ", in: " + p.iterator;
+		}
+		if (p.backends.length > 0) {
+			s += ", running: " + p.backends.join(", ");
+		}
+		status.textContent = s;
+	});
+	source.addEventListener("done", function(e) {
+		source.close();
+		window.location = e.data;
+	});
+	source.addEventListener("failed", function(e) {
+		source.close();
+		window.location.reload(); // shows the error
+	});
+})();
+</script>
+`
@@ -0,0 +1,172 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultRateWindow is how far back the scans are counted for the rate
+	// limits, if no other window is set.
+	DefaultRateWindow = 1 * time.Hour
+
+	// busyRetryAfter is how long we ask a client to wait before it tries
+	// again when too many scans are already running.
+	busyRetryAfter = 1 * time.Minute
+)
+
+// limitError is the error when a scan is refused because of one of the limits.
+// It knows what http status to send, and how long the client should wait for.
+type limitError struct {
+	status     int
+	retryAfter time.Duration
+	msg        string
+}
+
+// Error returns the message of the error.
+func (obj *limitError) Error() string {
+	return obj.msg
+}
+
+// rateLimiter counts the scans that each key, such as an ip address or a user,
+// started in the last window, and refuses any more than the max of them.
+type rateLimiter struct {
+	max    int
+	window time.Duration
+
+	mutex  sync.Mutex
+	starts map[string][]time.Time // oldest first
+	pruned time.Time
+}
+
+// allow records a scan by this key and returns zero if it's allowed. If it's
+// not, then nothing is recorded, and it returns how long until it would be.
+func (obj *rateLimiter) allow(key string, now time.Time) time.Duration {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	if obj.starts == nil {
+		obj.starts = make(map[string][]time.Time)
+	}
+	since := now.Add(-obj.window)
+
+	// Forget about everyone who stopped scanning now and then, so that
+	// this doesn't grow forever.
+	if now.Sub(obj.pruned) > obj.window {
+		for k, v := range obj.starts {
+			if len(v) == 0 || !v[len(v)-1].After(since) {
+				delete(obj.starts, k)
+			}
+		}
+		obj.pruned = now
+	}
+
+	starts := obj.starts[key]
+	i := 0
+	for i < len(starts) && !starts[i].After(since) {
+		i++
+	}
+	starts = starts[i:]
+	if len(starts) >= obj.max {
+		obj.starts[key] = starts
+		return starts[len(starts)-obj.max].Sub(since)
+	}
+	obj.starts[key] = append(starts, now)
+	return 0
+}
+
+// limitScans refuses a scan if the client ip address, or the user, already
+// started as many as they may in the rate window. It runs before the upload is
+// read, so that a refused scan costs us as little as possible.
+func (obj *Server) limitScans(c *gin.Context) error {
+	obj.limitersOnce.Do(func() {
+		window := obj.RateWindow
+		if window <= 0 {
+			window = DefaultRateWindow
+		}
+		if obj.MaxScansPerIP > 0 {
+			obj.ipLimiter = &rateLimiter{max: obj.MaxScansPerIP, window: window}
+		}
+		if obj.MaxScansPerUser > 0 {
+			obj.userLimiter = &rateLimiter{max: obj.MaxScansPerUser, window: window}
+		}
+	})
+
+	now := time.Now()
+	if obj.ipLimiter != nil {
+		ip := c.ClientIP()
+		if d := obj.ipLimiter.allow(ip, now); d > 0 {
+			obj.Logf("limit: %s started too many scans", ip)
+			return &limitError{
+				status:     http.StatusTooManyRequests,
+				retryAfter: d,
+				msg:        fmt.Sprintf("too many scans from this address, try again in %s", d.Round(time.Second)),
+			}
+		}
+	}
+	if user := c.GetString(gin.AuthUserKey); obj.userLimiter != nil && user != "" {
+		if d := obj.userLimiter.allow(user, now); d > 0 {
+			obj.Logf("limit: %s started too many scans", user)
+			return &limitError{
+				status:     http.StatusTooManyRequests,
+				retryAfter: d,
+				msg:        fmt.Sprintf("too many scans by this user, try again in %s", d.Round(time.Second)),
+			}
+		}
+	}
+	return nil
+}
+
+// runningJobs returns the number of scans that haven't finished yet. The jobs
+// mutex must be held by the caller.
+func (obj *Server) runningJobs() int {
+	n := 0
+	for _, x := range obj.jobs {
+		select {
+		case <-x.done:
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+// setRetryAfter tells the client how long to wait, if the error says so, and
+// returns the http status to send with it.
+func setRetryAfter(c *gin.Context, err error) int {
+	e, ok := err.(*limitError)
+	if !ok {
+		return http.StatusOK // the error goes back to the form
+	}
+	secs := int(math.Ceil(e.retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(secs))
+	return e.status
+}
@@ -0,0 +1,81 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/web"
+)
+
+func TestRateLimits(t *testing.T) {
+	obj := &web.Server{
+		Program: "yesiscan",
+		Logf:    t.Logf,
+		Auth: &web.TokenAuth{
+			Tokens: map[string]string{
+				"s3cret": "alice",
+			},
+		},
+		MaxScansPerIP:   2,
+		MaxScansPerUser: 3,
+	}
+	router := obj.Router()
+
+	tests := []struct {
+		addr   string
+		header string // the X-Forwarded-For
+		code   int
+	}{
+		{"192.0.2.1:1234", "", http.StatusOK},
+		{"192.0.2.1:1234", "", http.StatusOK},
+		{"192.0.2.1:1234", "", http.StatusTooManyRequests},
+		{"192.0.2.1:1234", "198.51.100.1", http.StatusTooManyRequests}, // not trusted
+		{"192.0.2.2:1234", "", http.StatusOK},
+		{"192.0.2.3:1234", "", http.StatusTooManyRequests}, // the user is out
+	}
+	for i, x := range tests {
+		// an empty uri is an error, but it still counts as a scan
+		form := url.Values{"uri": {""}}
+		req := httptest.NewRequest(http.MethodPost, "/scan/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer s3cret")
+		req.RemoteAddr = x.addr
+		if x.header != "" {
+			req.Header.Set("X-Forwarded-For", x.header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != x.code {
+			t.Errorf("test #%d: expected code %d, got: %d", i, x.code, w.Code)
+		}
+		if retry := w.Header().Get("Retry-After"); (x.code == http.StatusTooManyRequests) != (retry != "") {
+			t.Errorf("test #%d: unexpected retry after: %q", i, retry)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awslabs/yesiscan/lib"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ReportsPageSize is the number of reports on each page of the list,
+	// unless a different size is asked for.
+	ReportsPageSize = 25
+
+	// ReportsMaxPageSize is the largest page of reports that we return.
+	ReportsMaxPageSize = 100
+)
+
+// ReportSummary is the short version of a stored report that we show in the
+// list of reports.
+type ReportSummary struct {
+	// UID is the unique id of the stored report.
+	UID string `json:"uid"`
+
+	// Uri is the input URI used for the scan.
+	Uri string `json:"uri"`
+
+	// Time is when the scan finished, in RFC 3339 format.
+	Time string `json:"time,omitempty"`
+
+	// Owner is the user who ran the scan, if there was an auth.
+	Owner string `json:"owner,omitempty"`
+
+	// Profiles are the names of the profiles that were used, sorted.
+	Profiles []string `json:"profiles"`
+
+	// Verdict is the worst verdict of all the artifacts in all of the
+	// profiles. It is empty for older reports that didn't store one.
+	Verdict string `json:"verdict,omitempty"`
+
+	// State is the review state of the report.
+	State string `json:"state"`
+
+	// Files is the number of files that have results.
+	Files int `json:"files"`
+
+	// Licenses is the number of different licenses that were found.
+	Licenses int `json:"licenses"`
+
+	// Violations is the number of files that matched one of the profiles.
+	Violations int `json:"violations"`
+
+	// Errors is the number of scanning errors.
+	Errors int `json:"errors"`
+}
+
+// Matches returns true if the search string is found in the uri, owner, uid,
+// profiles, verdict, or review state of the report. The search ignores case,
+// and an empty search matches everything.
+func (obj *ReportSummary) Matches(search string) bool {
+	search = strings.ToLower(strings.TrimSpace(search))
+	if search == "" {
+		return true
+	}
+	fields := []string{obj.Uri, obj.Owner, obj.UID, obj.Verdict, obj.State}
+	fields = append(fields, obj.Profiles...)
+	for _, x := range fields {
+		if strings.Contains(strings.ToLower(x), search) {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary returns the short version of the report for the list of reports.
+func (obj *Report) Summary(uid string) *ReportSummary {
+	summary := &ReportSummary{
+		UID:      uid,
+		Uri:      obj.Uri,
+		Time:     obj.Time,
+		Owner:    obj.Owner,
+		Profiles: []string{},
+		State:    obj.ReviewState(),
+	}
+	for name, enabled := range obj.Profiles {
+		if enabled {
+			summary.Profiles = append(summary.Profiles, name)
+		}
+	}
+	sort.Strings(summary.Profiles)
+
+	if obj.Output == nil { // older reports only have the html
+		return summary
+	}
+	summary.Files = len(obj.Output.Results)
+	summary.Errors = len(obj.Output.Warnings)
+
+	licenses := make(map[string]struct{})
+	for _, m := range obj.Output.Results {
+		for _, result := range m {
+			for _, license := range result.Licenses {
+				licenses[license.String()] = struct{}{}
+			}
+		}
+	}
+	summary.Licenses = len(licenses)
+
+	violations := make(map[string]struct{})
+	for _, x := range obj.Output.Verdicts {
+		if verdictRank(x.Verdict) > verdictRank(summary.Verdict) {
+			summary.Verdict = x.Verdict
+		}
+		for _, uid := range x.Violations {
+			violations[uid] = struct{}{}
+		}
+	}
+	summary.Violations = len(violations)
+
+	return summary
+}
+
+// verdictRank orders the verdicts from best to worst.
+func verdictRank(verdict string) int {
+	switch verdict {
+	case lib.VerdictPass:
+		return 1
+	case lib.VerdictWarn:
+		return 2
+	case lib.VerdictFail:
+		return 3
+	}
+	return 0
+}
+
+// reportsEntry is a cached summary of a stored report. It is used for as long
+// as the file hasn't changed since.
+type reportsEntry struct {
+	modTime time.Time
+	summary *ReportSummary
+}
+
+// listReports returns the summary of every stored report, newest first. The
+// summaries are cached, since the reports are big and rarely change.
+func (obj *Server) listReports(ctx context.Context) ([]*ReportSummary, error) {
+	stored, err := obj.Reports.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	obj.summariesMutex.Lock()
+	defer obj.summariesMutex.Unlock()
+	cache := make(map[string]*reportsEntry) // drops the deleted reports
+
+	summaries := []*ReportSummary{}
+	for _, x := range stored {
+		uid := x.UID
+		entry, exists := obj.summaries[uid]
+		if !exists || !entry.modTime.Equal(x.ModTime) {
+			report, err := obj.Load(uid)
+			if err != nil {
+				obj.Logf("reports: skipping %s: %+v", uid, err)
+				continue
+			}
+			entry = &reportsEntry{
+				modTime: x.ModTime,
+				summary: report.Summary(uid),
+			}
+			if entry.summary.Time == "" { // older reports
+				entry.summary.Time = x.ModTime.UTC().Format(time.RFC3339)
+			}
+		}
+		cache[uid] = entry
+		summaries = append(summaries, entry.summary)
+	}
+	obj.summaries = cache
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Time != summaries[j].Time { // RFC 3339 sorts
+			return summaries[i].Time > summaries[j].Time
+		}
+		return summaries[i].UID < summaries[j].UID
+	})
+	return summaries, nil
+}
+
+// reports returns one page of the reports that this user may see, which match
+// the search. It also returns the total number of matching reports.
+func (obj *Server) reports(c *gin.Context, search string, page, size int) ([]*ReportSummary, int, error) {
+	summaries, err := obj.listReports(c.Request.Context())
+	if err != nil {
+		return nil, 0, err
+	}
+	matches := []*ReportSummary{}
+	for _, x := range summaries {
+		if !obj.allowed(c, x.Owner) || !x.Matches(search) {
+			continue
+		}
+		matches = append(matches, x)
+	}
+
+	// Clamp the page before we multiply, so that a huge one can't overflow.
+	if size < 1 {
+		size = ReportsPageSize
+	}
+	if pages := len(matches)/size + 1; page > pages {
+		page = pages
+	}
+	if page < 1 {
+		page = 1
+	}
+	start := (page - 1) * size
+	end := len(matches)
+	if end-start > size {
+		end = start + size
+	}
+	return matches[start:end], len(matches), nil
+}
+
+// reportsPage returns the page number and the page size from the request. They
+// are clamped to sensible values.
+func reportsPage(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	size, err := strconv.Atoi(c.Query("n"))
+	if err != nil || size < 1 {
+		size = ReportsPageSize
+	}
+	if size > ReportsMaxPageSize {
+		size = ReportsMaxPageSize
+	}
+	return page, size
+}
+
+// returnReportsHtml returns the search form, the table of reports, and the links
+// to the other pages.
+func returnReportsHtml(summaries []*ReportSummary, search string, page, size, total int) string {
+	s := `<div style="text-align: center;">`
+	s += `<form action="/reports/" method="get">`
+	s += fmt.Sprintf(`<input type="text" name="q" placeholder="search the reports" value="%s" />`, template.HTMLEscapeString(search))
+	s += `</form>`
+	s += `</div><br />`
+
+	s += `<table id="report">`
+	s += `<tr><th>time</th><th>uri</th><th>profiles</th><th>verdict</th><th>review</th><th>files</th><th>licenses</th><th>violations</th><th>errors</th></tr>`
+	if len(summaries) == 0 {
+		s += `<tr><td colspan="9" style="text-align: center"><i>no reports</i></td></tr>`
+	}
+	for _, x := range summaries {
+		s += "<tr>"
+		s += fmt.Sprintf("<td>%s</td>", template.HTMLEscapeString(x.Time))
+		s += fmt.Sprintf(`<td><a href="/report/?r=%s">%s</a></td>`, x.UID, template.HTMLEscapeString(x.Uri))
+		s += fmt.Sprintf("<td>%s</td>", template.HTMLEscapeString(strings.Join(x.Profiles, ", ")))
+		s += fmt.Sprintf("<td>%s</td>", template.HTMLEscapeString(x.Verdict))
+		s += fmt.Sprintf("<td>%s</td>", template.HTMLEscapeString(x.State))
+		s += fmt.Sprintf("<td>%d</td><td>%d</td><td>%d</td><td>%d</td>", x.Files, x.Licenses, x.Violations, x.Errors)
+		s += "</tr>"
+	}
+	s += "</table>"
+
+	pages := (total + size - 1) / size
+	if pages <= 1 {
+		return s + "<br />"
+	}
+	link := func(p int) string {
+		values := url.Values{}
+		if search != "" {
+			values.Set("q", search)
+		}
+		if size != ReportsPageSize {
+			values.Set("n", strconv.Itoa(size))
+		}
+		values.Set("page", strconv.Itoa(p))
+		return "/reports/?" + values.Encode()
+	}
+	s += `<div style="text-align: center;">`
+	if page > 1 {
+		s += fmt.Sprintf(`<a href="%s">&laquo; newer</a> `, template.HTMLEscapeString(link(page-1)))
+	}
+	s += fmt.Sprintf("page %d of %d", page, pages)
+	if page < pages {
+		s += fmt.Sprintf(` <a href="%s">older &raquo;</a>`, template.HTMLEscapeString(link(page+1)))
+	}
+	s += "</div>"
+	return s + "<br />"
+}
@@ -0,0 +1,158 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+	"github.com/awslabs/yesiscan/util/safepath"
+	"github.com/awslabs/yesiscan/web"
+)
+
+func TestReportSummary(t *testing.T) {
+	mit := &licenses.License{SPDX: "MIT"}
+	gpl := &licenses.License{SPDX: "GPL-3.0-only"}
+	report := &web.Report{
+		Uri:      "https://github.com/example/repo",
+		Time:     "2022-01-02T03:04:05Z",
+		Owner:    "alice",
+		Profiles: map[string]bool{"strict": true, "default": true, "off": false},
+		Reviews: []*web.Review{
+			{State: web.ReviewApproved, Reviewer: "bob"},
+		},
+		Output: &lib.JSONOutput{
+			Results: map[string]map[string]*lib.JSONResult{
+				"file:///a": {"licenseclassifier": {Licenses: []*licenses.License{mit}}},
+				"file:///b": {"licenseclassifier": {Licenses: []*licenses.License{mit, gpl}}},
+				"file:///c": {"cran": {Licenses: []*licenses.License{gpl}}},
+			},
+			Warnings: map[string]string{"file:///d": "oops"},
+			Verdicts: []*lib.Verdict{
+				{Profile: "default", Verdict: lib.VerdictWarn},
+				{Profile: "strict", Verdict: lib.VerdictFail, Violations: []string{"file:///b", "file:///c"}},
+			},
+		},
+	}
+
+	summary := report.Summary("abc")
+	expected := &web.ReportSummary{
+		UID:        "abc",
+		Uri:        "https://github.com/example/repo",
+		Time:       "2022-01-02T03:04:05Z",
+		Owner:      "alice",
+		Profiles:   []string{"default", "strict"},
+		Verdict:    lib.VerdictFail,
+		State:      web.ReviewApproved,
+		Files:      3,
+		Licenses:   2,
+		Violations: 2,
+		Errors:     1,
+	}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, summary)
+	}
+
+	for _, x := range []string{"", "GITHUB.com/example", "alice", "strict", "approved", "fail", "ab"} {
+		if !summary.Matches(x) {
+			t.Errorf("expected %q to match", x)
+		}
+	}
+	for _, x := range []string{"gitlab", "bob", "pass"} {
+		if summary.Matches(x) {
+			t.Errorf("expected %q not to match", x)
+		}
+	}
+
+	old := (&web.Report{Uri: "https://example.com/old.tar.gz", Html: "<p>old</p>"}).Summary("def")
+	if old.Verdict != "" || old.Files != 0 || old.State != web.ReviewNew || len(old.Profiles) != 0 {
+		t.Errorf("unexpected summary of an old report: %+v", old)
+	}
+}
+
+func TestReportsPages(t *testing.T) {
+	dir := safepath.UnsafeParseIntoAbsDir(t.TempDir() + "/reports/")
+	store := &web.DiskReportStore{Prefix: dir}
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatalf("could not init: %+v", err)
+	}
+	obj := &web.Server{
+		Program: "yesiscan",
+		Logf:    t.Logf,
+		Reports: store,
+	}
+	for i := 0; i < 3; i++ {
+		report := &web.Report{
+			Uri:  fmt.Sprintf("https://example.com/%d", i),
+			Time: fmt.Sprintf("2022-01-02T03:04:0%dZ", i),
+		}
+		if _, err := obj.Store(report); err != nil {
+			t.Fatalf("could not store: %+v", err)
+		}
+	}
+	router := obj.Router()
+
+	tests := []struct {
+		query string
+		count int
+	}{
+		{"", 3},
+		{"page=1&n=2", 2},
+		{"page=2&n=2", 1},
+		{"page=3&n=2", 1}, // past the end is the last page
+		{"page=0&n=0", 3},
+		{"page=-1&n=-1", 3},
+		{"page=461168601842738792&n=20", 3},
+		{"page=9223372036854775807&n=9223372036854775807", 3},
+		{"page=-9223372036854775808&n=1", 1},
+		{"page=99999999999999999999&n=2", 2}, // not an int
+	}
+	for i, x := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/reports/?format=json&"+x.query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("test #%d (%s): expected code %d, got: %d", i, x.query, http.StatusOK, w.Code)
+			continue
+		}
+		var body struct {
+			Reports []*web.ReportSummary `json:"reports"`
+			Total   int                  `json:"total"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Errorf("test #%d (%s): invalid json: %+v", i, x.query, err)
+			continue
+		}
+		if len(body.Reports) != x.count || body.Total != 3 {
+			t.Errorf("test #%d (%s): expected %d of 3 reports, got: %d of %d", i, x.query, x.count, len(body.Reports), body.Total)
+		}
+	}
+}
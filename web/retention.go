@@ -0,0 +1,92 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// janitorInterval is how often the janitor looks for reports to remove.
+const janitorInterval = 10 * time.Minute
+
+// janitor removes the reports that are past the retention policy, right away
+// and then periodically, until the context is cancelled.
+func (obj *Server) janitor(ctx context.Context) {
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := obj.cleanup(ctx, time.Now()); err != nil {
+			obj.Logf("janitor: %+v", err)
+		} else if n > 0 {
+			obj.Logf("janitor: removed %d report(s)", n)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// cleanup removes the stored reports which are older than the RetentionAge, and
+// then the oldest of the rest until there are no more than RetentionCount, and
+// they take up no more than RetentionBytes. The age of a report is from when it
+// was last written, so a review keeps it around for longer. It returns how many
+// reports were removed.
+func (obj *Server) cleanup(ctx context.Context, now time.Time) (int, error) {
+	// don't race with a report that is being updated
+	obj.reportsMutex.Lock()
+	defer obj.reportsMutex.Unlock()
+
+	reports, err := obj.Reports.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(reports, func(i, j int) bool { // newest first
+		return reports[i].ModTime.After(reports[j].ModTime)
+	})
+
+	removed := 0
+	total := int64(0)
+	for i, x := range reports {
+		total += x.Size
+		expired := obj.RetentionAge > 0 && now.Sub(x.ModTime) > obj.RetentionAge
+		tooMany := obj.RetentionCount > 0 && i >= obj.RetentionCount
+		tooBig := obj.RetentionBytes > 0 && total > obj.RetentionBytes
+		if !expired && !tooMany && !tooBig {
+			continue
+		}
+		if err := obj.Reports.Delete(ctx, x.UID); err != nil {
+			return removed, err
+		}
+		total -= x.Size // it's not taking up any space anymore
+		removed++
+		if obj.Debug {
+			obj.Logf("janitor: removed report %s", x.UID)
+		}
+	}
+	return removed, nil
+}
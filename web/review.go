@@ -0,0 +1,175 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/awslabs/yesiscan/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ReviewNew is the state of a report that nobody has looked at yet.
+	ReviewNew = "new"
+
+	// ReviewInReview is the state of a report that a reviewer is working
+	// on.
+	ReviewInReview = "in-review"
+
+	// ReviewApproved is the state of a report that a reviewer signed off
+	// on.
+	ReviewApproved = "approved"
+
+	// ReviewRejected is the state of a report that a reviewer turned down.
+	ReviewRejected = "rejected"
+)
+
+// ReviewStates is the list of all the review states, in the order that a report
+// usually goes through them.
+var ReviewStates = []string{
+	ReviewNew,
+	ReviewInReview,
+	ReviewApproved,
+	ReviewRejected,
+}
+
+// Review is one change to the review state of a report.
+type Review struct {
+	// State is the state that the report was moved to.
+	State string `json:"state"`
+
+	// Reviewer is who moved it.
+	Reviewer string `json:"reviewer,omitempty"`
+
+	// Time is when it was moved, in RFC 3339 format.
+	Time string `json:"time,omitempty"`
+
+	// Comment is the reason that the reviewer gave.
+	Comment string `json:"comment,omitempty"`
+}
+
+// ReviewState returns the current review state of the report.
+func (obj *Report) ReviewState() string {
+	if len(obj.Reviews) == 0 {
+		return ReviewNew
+	}
+	return obj.Reviews[len(obj.Reviews)-1].State
+}
+
+// onlyReviewers is the middleware that turns away everyone who isn't one of the
+// reviewers.
+func (obj *Server) onlyReviewers(c *gin.Context) {
+	if len(obj.Reviewers) == 0 {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"message": "there are no reviewers",
+		})
+		return
+	}
+	if _, exists := obj.Reviewers[c.GetString(gin.AuthUserKey)]; !exists {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"message": "you are not a reviewer",
+		})
+		return
+	}
+}
+
+// review moves a stored report to the review state from the form, and records
+// who did it. It returns the report uid to go back to, and the change.
+func (obj *Server) review(c *gin.Context) (string, *Review, error) {
+	r := c.PostForm("r")
+	if _, err := obj.loadReport(c, r); err != nil { // may they see it?
+		return "", nil, err
+	}
+	review := &Review{
+		State:    c.PostForm("state"),
+		Reviewer: c.GetString(gin.AuthUserKey),
+		Time:     time.Now().UTC().Format(time.RFC3339),
+		Comment:  strings.TrimSpace(c.PostForm("comment")),
+	}
+	if !util.StrInList(review.State, ReviewStates) {
+		return "", nil, fmt.Errorf("invalid state: %s", review.State)
+	}
+
+	err := obj.Update(r, func(report *Report) error {
+		if report.ReviewState() == review.State && review.Comment == "" {
+			return fmt.Errorf("the report is already %s", review.State)
+		}
+		report.Reviews = append(report.Reviews, review)
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	obj.Logf("review: %s: %s by %s", r, review.State, review.Reviewer)
+
+	return r, review, nil
+}
+
+// returnReviewHtml returns the review state of this report and its history, and
+// the form that reviewers use to change it. The form is only shown when there
+// are reviewers who could submit it.
+func returnReviewHtml(report *Report, uuid string, reviewers bool) string {
+	s := `<table id="report">`
+	s += fmt.Sprintf(`<tr><th style="text-align: left">review: <i>%s</i></th></tr>`, template.HTMLEscapeString(report.ReviewState()))
+	for _, x := range report.Reviews {
+		s += "<tr><td>"
+		s += fmt.Sprintf("<i>%s</i>", template.HTMLEscapeString(x.State))
+		if x.Reviewer != "" {
+			s += fmt.Sprintf(" by %s", template.HTMLEscapeString(x.Reviewer))
+		}
+		if x.Time != "" {
+			s += fmt.Sprintf(" at %s", template.HTMLEscapeString(x.Time))
+		}
+		if x.Comment != "" {
+			s += fmt.Sprintf(": %s", template.HTMLEscapeString(x.Comment))
+		}
+		s += "</td></tr>"
+	}
+	if reviewers {
+		s += "<tr><td>"
+		s += `<form action="/review/" method="post">`
+		s += fmt.Sprintf(`<input type="hidden" name="r" value="%s" />`, template.HTMLEscapeString(uuid))
+		s += `<select name="state">`
+		for _, x := range ReviewStates {
+			selected := ""
+			if x == report.ReviewState() {
+				selected = ` selected="selected"`
+			}
+			s += fmt.Sprintf(`<option value="%s"%s>%s</option>`, x, selected, x)
+		}
+		s += `</select> `
+		s += `<input type="text" name="comment" placeholder="comment" style="width: 60%" /> `
+		s += `<input type="submit" value="save" />`
+		s += `</form>`
+		s += "</td></tr>"
+	}
+	s += "</table>"
+	return s + "<br />"
+}
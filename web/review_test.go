@@ -0,0 +1,44 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web_test
+
+import (
+	"testing"
+
+	"github.com/awslabs/yesiscan/web"
+)
+
+func TestReviewState(t *testing.T) {
+	report := &web.Report{}
+	if s := report.ReviewState(); s != web.ReviewNew {
+		t.Errorf("expected a report without reviews to be new, got: %s", s)
+	}
+	report.Reviews = []*web.Review{
+		{State: web.ReviewInReview, Reviewer: "alice"},
+		{State: web.ReviewRejected, Reviewer: "bob"},
+	}
+	if s := report.ReviewState(); s != web.ReviewRejected {
+		t.Errorf("expected the newest review to win, got: %s", s)
+	}
+}
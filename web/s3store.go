@@ -0,0 +1,175 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/awslabs/yesiscan/util/errwrap"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	s3config "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3ReportStore keeps each report as a json object in an S3 bucket, so that a
+// number of servers behind a load balancer can all share them. This depends on
+// you having the AWS credentials set up on the machine, like for the s3 output.
+type S3ReportStore struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	// Region is the region of the bucket.
+	Region string
+
+	// Bucket is the name of the bucket. It must already exist.
+	Bucket string
+
+	// Prefix is put in front of the name of each object, for example:
+	// "yesiscan/reports/" so that the bucket can be shared with others.
+	Prefix string
+
+	client *s3.Client
+}
+
+// String returns a human readable name for this store.
+func (obj *S3ReportStore) String() string {
+	return fmt.Sprintf("s3: %s/%s", obj.Bucket, obj.Prefix)
+}
+
+// Init builds the client, and checks that we can get at the bucket.
+func (obj *S3ReportStore) Init(ctx context.Context) error {
+	if obj.Region == "" {
+		return fmt.Errorf("empty region")
+	}
+	if obj.Bucket == "" {
+		return fmt.Errorf("empty bucket")
+	}
+	cfg, err := s3config.LoadDefaultConfig(ctx, s3config.WithRegion(obj.Region))
+	if err != nil {
+		return errwrap.Wrapf(err, "config error")
+	}
+	obj.client = s3.NewFromConfig(cfg)
+
+	if _, err := obj.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(obj.Bucket)}); err != nil {
+		return errwrap.Wrapf(err, "can't use the bucket %s", obj.Bucket)
+	}
+	return nil
+}
+
+// Get downloads the report object.
+func (obj *S3ReportStore) Get(ctx context.Context, uid string) ([]byte, error) {
+	key, err := obj.key(uid)
+	if err != nil {
+		return nil, err
+	}
+	output, err := obj.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(obj.Bucket),
+		Key:    aws.String(key),
+	})
+	var noSuchKey *s3types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, fmt.Errorf("no report at %s", key)
+	}
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "error getting the object at %s", key)
+	}
+	defer output.Body.Close()
+	return io.ReadAll(output.Body)
+}
+
+// Put uploads the report object. S3 only ever shows a whole object.
+func (obj *S3ReportStore) Put(ctx context.Context, uid string, data []byte) error {
+	key, err := obj.key(uid)
+	if err != nil {
+		return err
+	}
+	if obj.Debug {
+		obj.Logf("s3: put %s", key)
+	}
+	_, err = obj.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(obj.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	return errwrap.Wrapf(err, "error putting the object at %s", key)
+}
+
+// Delete removes the report object.
+func (obj *S3ReportStore) Delete(ctx context.Context, uid string) error {
+	key, err := obj.key(uid)
+	if err != nil {
+		return err
+	}
+	_, err = obj.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(obj.Bucket),
+		Key:    aws.String(key),
+	})
+	return errwrap.Wrapf(err, "error deleting the object at %s", key)
+}
+
+// List returns each of the report objects under the prefix, and skips anything
+// else that's in there.
+func (obj *S3ReportStore) List(ctx context.Context) ([]*StoredReport, error) {
+	reports := []*StoredReport{}
+	paginator := s3.NewListObjectsV2Paginator(obj.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(obj.Bucket),
+		Prefix: aws.String(obj.Prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "error listing the bucket %s", obj.Bucket)
+		}
+		for _, x := range page.Contents {
+			name := strings.TrimPrefix(aws.ToString(x.Key), obj.Prefix)
+			uid := strings.TrimSuffix(name, ".json")
+			if uid == name || ValidUID(uid) != nil || x.LastModified == nil {
+				continue // not one of our reports
+			}
+			reports = append(reports, &StoredReport{
+				UID:     uid,
+				Size:    x.Size,
+				ModTime: *x.LastModified,
+			})
+		}
+	}
+	return reports, nil
+}
+
+// key returns the name of the object that the report with this uid is stored
+// at. It errors if the uid isn't valid.
+func (obj *S3ReportStore) key(uid string) (string, error) {
+	if err := ValidUID(uid); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%s.json", obj.Prefix, uid), nil
+}
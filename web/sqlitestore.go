@@ -0,0 +1,178 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+package web
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/safepath"
+
+	_ "modernc.org/sqlite" // registers the pure go sqlite driver
+)
+
+const (
+	// SQLiteDriver is the name of the database/sql driver that we use.
+	SQLiteDriver = "sqlite"
+
+	// SQLiteBusyTimeout is how long we wait for a lock that another server
+	// has on the database before we give up.
+	SQLiteBusyTimeout = 10 * time.Second
+)
+
+// SQLiteReportStore keeps each report as a row in an SQLite database file. Many
+// servers on the same machine, or with the file on a shared filesystem which
+// has working locks, can all share it. The database is made if it doesn't exist.
+type SQLiteReportStore struct {
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	// Path is the database file.
+	Path safepath.AbsFile
+
+	// Perms is the permission policy for the database file. If it is nil,
+	// then the default policy is used.
+	Perms *interfaces.Perms
+
+	db *sql.DB
+}
+
+// String returns a human readable name for this store.
+func (obj *SQLiteReportStore) String() string {
+	return fmt.Sprintf("sqlite: %s", obj.Path)
+}
+
+// Init opens the database, and makes the table if it's not there yet.
+func (obj *SQLiteReportStore) Init(ctx context.Context) error {
+	p := obj.Path.Path()
+	if err := os.MkdirAll(filepath.Dir(p), obj.Perms.DirMode()); err != nil {
+		return err
+	}
+	// Make the file ourselves so that it has the right mode, since the
+	// driver would use the default one.
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, obj.Perms.FileMode())
+	if err != nil {
+		return errwrap.Wrapf(err, "can't open the database at %s", p)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// Each connection runs these, so that every one of them waits for the
+	// locks of the other servers, and so that readers don't block writers.
+	pragmas := url.Values{}
+	pragmas.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", SQLiteBusyTimeout.Milliseconds()))
+	pragmas.Add("_pragma", "journal_mode(wal)")
+	dsn := (&url.URL{Scheme: "file", Path: p, RawQuery: pragmas.Encode()}).String()
+	db, err := sql.Open(SQLiteDriver, dsn)
+	if err != nil {
+		return errwrap.Wrapf(err, "can't open the database at %s", p)
+	}
+	query := `CREATE TABLE IF NOT EXISTS reports (
+		uid TEXT PRIMARY KEY NOT NULL,
+		data BLOB NOT NULL,
+		modtime INTEGER NOT NULL
+	)`
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		db.Close()
+		return errwrap.Wrapf(err, "can't make the table in the database at %s", p)
+	}
+	obj.db = db
+	return nil
+}
+
+// Get reads the report row.
+func (obj *SQLiteReportStore) Get(ctx context.Context, uid string) ([]byte, error) {
+	if err := ValidUID(uid); err != nil {
+		return nil, err
+	}
+	var data []byte
+	err := obj.db.QueryRowContext(ctx, "SELECT data FROM reports WHERE uid = ?", uid).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no report with uid %s", uid)
+	}
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "error reading the report with uid %s", uid)
+	}
+	return data, nil
+}
+
+// Put writes the report row in a single statement, so that nobody ever sees half
+// of it.
+func (obj *SQLiteReportStore) Put(ctx context.Context, uid string, data []byte) error {
+	if err := ValidUID(uid); err != nil {
+		return err
+	}
+	if obj.Debug {
+		obj.Logf("sqlite: put %s", uid)
+	}
+	if data == nil {
+		data = []byte{} // not null
+	}
+	query := `INSERT INTO reports (uid, data, modtime) VALUES (?, ?, ?)
+		ON CONFLICT (uid) DO UPDATE SET data = excluded.data, modtime = excluded.modtime`
+	_, err := obj.db.ExecContext(ctx, query, uid, data, time.Now().UnixNano())
+	return errwrap.Wrapf(err, "error writing the report with uid %s", uid)
+}
+
+// Delete removes the report row.
+func (obj *SQLiteReportStore) Delete(ctx context.Context, uid string) error {
+	if err := ValidUID(uid); err != nil {
+		return err
+	}
+	_, err := obj.db.ExecContext(ctx, "DELETE FROM reports WHERE uid = ?", uid)
+	return errwrap.Wrapf(err, "error deleting the report with uid %s", uid)
+}
+
+// List returns each of the report rows, without reading the reports.
+func (obj *SQLiteReportStore) List(ctx context.Context) ([]*StoredReport, error) {
+	rows, err := obj.db.QueryContext(ctx, "SELECT uid, length(data), modtime FROM reports")
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "error listing the reports")
+	}
+	defer rows.Close()
+	reports := []*StoredReport{}
+	for rows.Next() {
+		var uid string
+		var size, modtime int64
+		if err := rows.Scan(&uid, &size, &modtime); err != nil {
+			return nil, errwrap.Wrapf(err, "error reading the list of reports")
+		}
+		if ValidUID(uid) != nil {
+			continue // not one of our reports
+		}
+		reports = append(reports, &StoredReport{
+			UID:     uid,
+			Size:    size,
+			ModTime: time.Unix(0, modtime),
+		})
+	}
+	return reports, errwrap.Wrapf(rows.Err(), "error listing the reports")
+}
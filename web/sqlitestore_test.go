@@ -0,0 +1,87 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+package web_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/util/safepath"
+	"github.com/awslabs/yesiscan/web"
+)
+
+func TestSQLiteReportStore(t *testing.T) {
+	ctx := context.Background()
+	p := filepath.Join(t.TempDir(), "db", "reports.sqlite")
+	store := &web.SQLiteReportStore{Path: safepath.UnsafeParseIntoAbsFile(p)}
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("could not init: %+v", err)
+	}
+	if info, err := os.Stat(p); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("unexpected database file: %+v, %+v", info, err)
+	}
+
+	uid := strings.Repeat("0f", 32)
+	if _, err := store.Get(ctx, uid); err == nil {
+		t.Errorf("expected an error for a missing report")
+	}
+	if err := store.Put(ctx, uid, []byte(`{"uri": "a"}`)); err != nil {
+		t.Fatalf("could not put: %+v", err)
+	}
+
+	// a second server that shares the database sees the same reports
+	other := &web.SQLiteReportStore{Path: safepath.UnsafeParseIntoAbsFile(p)}
+	if err := other.Init(ctx); err != nil {
+		t.Fatalf("could not init: %+v", err)
+	}
+	if err := other.Put(ctx, uid, []byte(`{"uri": "b"}`)); err != nil {
+		t.Fatalf("could not replace: %+v", err)
+	}
+	if b, err := store.Get(ctx, uid); err != nil || string(b) != `{"uri": "b"}` {
+		t.Errorf("unexpected report: %s, %+v", b, err)
+	}
+	if err := store.Put(ctx, "../../etc/passwd", nil); err == nil {
+		t.Errorf("expected an invalid uid to be refused")
+	}
+
+	reports, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("could not list: %+v", err)
+	}
+	if len(reports) != 1 || reports[0].UID != uid || reports[0].Size != 12 || reports[0].ModTime.IsZero() {
+		t.Errorf("unexpected list: %+v", reports)
+	}
+
+	if err := other.Delete(ctx, uid); err != nil {
+		t.Errorf("could not delete: %+v", err)
+	}
+	if err := store.Delete(ctx, uid); err != nil {
+		t.Errorf("expected deleting twice to be fine: %+v", err)
+	}
+	if reports, err := store.List(ctx); err != nil || len(reports) != 0 {
+		t.Errorf("expected no reports: %+v, %+v", reports, err)
+	}
+}
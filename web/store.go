@@ -0,0 +1,198 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/safepath"
+)
+
+// ReportStore is where the web server keeps its reports. The server encodes
+// them, so a store only needs to keep some bytes under each uid. If more than
+// one server shares a store, then they all see the same reports.
+type ReportStore interface {
+	fmt.Stringer
+
+	// Init prepares the store, and checks that it can be used.
+	Init(ctx context.Context) error
+
+	// Get returns the stored report with this uid.
+	Get(ctx context.Context, uid string) ([]byte, error)
+
+	// Put stores the report with this uid, replacing any previous one. It
+	// should never leave half of a report behind.
+	Put(ctx context.Context, uid string, data []byte) error
+
+	// Delete removes the stored report with this uid. It isn't an error if
+	// it's already gone, since another server might have removed it.
+	Delete(ctx context.Context, uid string) error
+
+	// List returns every stored report, in any order.
+	List(ctx context.Context) ([]*StoredReport, error)
+}
+
+// StoredReport is what a ReportStore knows about a report without reading it.
+type StoredReport struct {
+	// UID is the unique id of the report.
+	UID string
+
+	// Size is the number of bytes that the report takes up.
+	Size int64
+
+	// ModTime is when the report was last written.
+	ModTime time.Time
+}
+
+// ValidUID returns an error if this isn't the uid of a report. Since it is only
+// ever lower case hex, it is safe to use in a path.
+func ValidUID(uid string) error {
+	if len(uid) != 64 { // length of a sha256sum
+		return fmt.Errorf("invalid uid length")
+	}
+
+	// remove all the valid characters, it should be empty!
+	// NOTE: this importantly also blocks path traversal hacks like ../ too!
+	if cut := strings.Trim(uid, "0123456789abcdef"); len(cut) != 0 {
+		return fmt.Errorf("invalid uid characters")
+	}
+	return nil
+}
+
+// DiskReportStore keeps each report in a json file in a local directory. This
+// is the default store.
+type DiskReportStore struct {
+	// Prefix is the directory that the reports are stored in.
+	Prefix safepath.AbsDir
+
+	// Perms is the permission policy for the files. If it is nil, then
+	// the default policy is used.
+	Perms *interfaces.Perms
+}
+
+// String returns a human readable name for this store.
+func (obj *DiskReportStore) String() string {
+	return fmt.Sprintf("disk: %s", obj.Prefix)
+}
+
+// Init makes the directory if it doesn't exist yet.
+func (obj *DiskReportStore) Init(ctx context.Context) error {
+	return os.MkdirAll(obj.Prefix.Path(), obj.Perms.DirMode())
+}
+
+// Get reads the report file.
+func (obj *DiskReportStore) Get(ctx context.Context, uid string) ([]byte, error) {
+	absFile, err := obj.file(uid)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(absFile.Path())
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "error reading our file from disk at %s", absFile)
+	}
+	return b, nil
+}
+
+// Put writes the report to a temporary file, and then renames it into place,
+// so that nobody ever sees half of it.
+func (obj *DiskReportStore) Put(ctx context.Context, uid string, data []byte) error {
+	absFile, err := obj.file(uid)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(obj.Prefix.Path(), "."+uid+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // nothing left over if we fail
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return errwrap.Wrapf(err, "error writing our file to disk at %s", f.Name())
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), obj.Perms.FileMode()); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), absFile.Path())
+}
+
+// Delete removes the report file.
+func (obj *DiskReportStore) Delete(ctx context.Context, uid string) error {
+	absFile, err := obj.file(uid)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(absFile.Path()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List returns each of the report files in the directory, and skips anything
+// else that's in there.
+func (obj *DiskReportStore) List(ctx context.Context) ([]*StoredReport, error) {
+	files, err := os.ReadDir(obj.Prefix.Path())
+	if err != nil {
+		return nil, err
+	}
+	reports := []*StoredReport{}
+	for _, f := range files {
+		uid := strings.TrimSuffix(f.Name(), ".json")
+		if f.IsDir() || uid == f.Name() || ValidUID(uid) != nil {
+			continue // not one of our reports
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue // it was removed since we looked
+		}
+		reports = append(reports, &StoredReport{
+			UID:     uid,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+	return reports, nil
+}
+
+// file returns the path that the report with this uid is stored at. It errors
+// if the uid isn't valid.
+func (obj *DiskReportStore) file(uid string) (safepath.AbsFile, error) {
+	if err := ValidUID(uid); err != nil {
+		return safepath.AbsFile{}, err
+	}
+	hashRelFile, err := safepath.ParseIntoRelFile(fmt.Sprintf("%s.json", uid))
+	if err != nil {
+		return safepath.AbsFile{}, err
+	}
+	// TODO: split into subfolders when we have very large numbers of files
+	return safepath.JoinToAbsFile(obj.Prefix, hashRelFile), nil
+}
@@ -0,0 +1,196 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awslabs/yesiscan/util/safepath"
+	"github.com/awslabs/yesiscan/web"
+)
+
+func TestValidUID(t *testing.T) {
+	if err := web.ValidUID(strings.Repeat("ab", 32)); err != nil {
+		t.Errorf("expected a valid uid, got: %+v", err)
+	}
+	for _, x := range []string{"", "abc", strings.Repeat("AB", 32), "../" + strings.Repeat("a", 61)} {
+		if err := web.ValidUID(x); err == nil {
+			t.Errorf("expected %q to be invalid", x)
+		}
+	}
+}
+
+func TestDiskReportStore(t *testing.T) {
+	ctx := context.Background()
+	dir := safepath.UnsafeParseIntoAbsDir(t.TempDir() + "/reports/")
+	store := &web.DiskReportStore{Prefix: dir}
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("could not init: %+v", err)
+	}
+
+	uid := strings.Repeat("0f", 32)
+	if _, err := store.Get(ctx, uid); err == nil {
+		t.Errorf("expected an error for a missing report")
+	}
+	if err := store.Put(ctx, uid, []byte(`{"uri": "a"}`)); err != nil {
+		t.Fatalf("could not put: %+v", err)
+	}
+	if err := store.Put(ctx, uid, []byte(`{"uri": "b"}`)); err != nil {
+		t.Fatalf("could not replace: %+v", err)
+	}
+	if b, err := store.Get(ctx, uid); err != nil || string(b) != `{"uri": "b"}` {
+		t.Errorf("unexpected report: %s, %+v", b, err)
+	}
+	if err := store.Put(ctx, "../../etc/passwd", nil); err == nil {
+		t.Errorf("expected an invalid uid to be refused")
+	}
+
+	reports, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("could not list: %+v", err)
+	}
+	if len(reports) != 1 || reports[0].UID != uid || reports[0].Size != 12 {
+		t.Errorf("unexpected list: %+v", reports)
+	}
+
+	if err := store.Delete(ctx, uid); err != nil {
+		t.Errorf("could not delete: %+v", err)
+	}
+	if err := store.Delete(ctx, uid); err != nil {
+		t.Errorf("expected deleting twice to be fine: %+v", err)
+	}
+	if reports, err := store.List(ctx); err != nil || len(reports) != 0 {
+		t.Errorf("expected no reports: %+v, %+v", reports, err)
+	}
+}
+
+// memReportStore keeps the reports in memory, with the times that they were
+// written set by the test.
+type memReportStore struct {
+	mu      sync.Mutex
+	reports map[string]*web.StoredReport
+}
+
+func (obj *memReportStore) String() string { return "memory" }
+
+func (obj *memReportStore) Init(ctx context.Context) error { return nil }
+
+func (obj *memReportStore) Get(ctx context.Context, uid string) ([]byte, error) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	if _, exists := obj.reports[uid]; !exists {
+		return nil, fmt.Errorf("no report: %s", uid)
+	}
+	return []byte("{}"), nil
+}
+
+func (obj *memReportStore) Put(ctx context.Context, uid string, data []byte) error {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	obj.reports[uid] = &web.StoredReport{UID: uid, Size: int64(len(data)), ModTime: time.Now()}
+	return nil
+}
+
+func (obj *memReportStore) Delete(ctx context.Context, uid string) error {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	delete(obj.reports, uid)
+	return nil
+}
+
+func (obj *memReportStore) List(ctx context.Context) ([]*web.StoredReport, error) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	reports := []*web.StoredReport{}
+	for _, x := range obj.reports {
+		reports = append(reports, x)
+	}
+	return reports, nil
+}
+
+func TestRetention(t *testing.T) {
+	dir := t.TempDir()
+	for _, k := range []string{"HOME", "XDG_CACHE_HOME"} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Setenv(k, dir)
+	}
+
+	now := time.Now()
+	uid := func(i int) string { return strings.Repeat(fmt.Sprintf("%02x", i), 32) }
+	store := &memReportStore{reports: make(map[string]*web.StoredReport)}
+	for i, age := range []time.Duration{1 * time.Hour, 2 * time.Hour, 3 * time.Hour, 48 * time.Hour} {
+		store.reports[uid(i)] = &web.StoredReport{UID: uid(i), Size: 100, ModTime: now.Add(-age)}
+	}
+
+	removed := make(chan string, 10)
+	obj := &web.Server{
+		Program: "yesiscan",
+		Logf: func(format string, v ...interface{}) {
+			if s := fmt.Sprintf(format, v...); strings.HasPrefix(s, "janitor: ") {
+				removed <- s
+			}
+		},
+		Listen:  freeListen(t),
+		Reports: store,
+
+		// The oldest one is too old, and only the two newest ones fit.
+		RetentionAge:   24 * time.Hour,
+		RetentionCount: 3,
+		RetentionBytes: 250,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan error)
+	go func() {
+		ch <- obj.Run(ctx)
+	}()
+	select {
+	case s := <-removed: // it runs right away when it starts
+		if s != "janitor: removed 2 report(s)" {
+			t.Errorf("unexpected log: %s", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("the janitor didn't run")
+	}
+	cancel()
+	if err := <-ch; err != nil {
+		t.Errorf("error from the server: %+v", err)
+	}
+
+	reports, _ := store.List(ctx)
+	if len(reports) != 2 {
+		t.Errorf("expected two reports, got: %d", len(reports))
+	}
+	for _, i := range []int{0, 1} { // the newest ones
+		if _, exists := store.reports[uid(i)]; !exists {
+			t.Errorf("expected report %d to be kept", i)
+		}
+	}
+}
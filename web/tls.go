@@ -0,0 +1,146 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/safepath"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// CertFiles serves a certificate which is loaded from a pair of PEM files. The
+// files are loaded again when either of them changes, so that a certificate
+// which gets renewed on disk is picked up without a restart.
+type CertFiles struct {
+	// CertFile is the path to the certificate, followed by any of the
+	// intermediate certificates that the clients need.
+	CertFile string
+
+	// KeyFile is the path to the private key of the certificate.
+	KeyFile string
+
+	mutex   sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// GetCertificate returns the current certificate. It has the signature of the
+// tls.Config GetCertificate field. If the files can't be loaded again, then
+// the last good certificate is kept.
+func (obj *CertFiles) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+
+	modTime, err := obj.latest()
+	if err == nil && (obj.cert == nil || !modTime.Equal(obj.modTime)) {
+		cert, e := tls.LoadX509KeyPair(obj.CertFile, obj.KeyFile)
+		if e == nil {
+			obj.cert = &cert
+			obj.modTime = modTime
+		}
+		err = e
+	}
+	if obj.cert == nil {
+		return nil, errwrap.Wrapf(err, "could not load the certificate")
+	}
+	return obj.cert, nil
+}
+
+// latest returns the newest modification time of the two files.
+func (obj *CertFiles) latest() (time.Time, error) {
+	t := time.Time{}
+	for _, x := range []string{obj.CertFile, obj.KeyFile} {
+		fi, err := os.Stat(x)
+		if err != nil {
+			return t, err
+		}
+		if fi.ModTime().After(t) {
+			t = fi.ModTime()
+		}
+	}
+	return t, nil
+}
+
+// tlsConfig returns the config to serve https with, or nil if we should serve
+// plain http. The prefix is the cache directory of the server, which is where
+// the ACME certificates are kept if there's no AutocertCache.
+func (obj *Server) tlsConfig(prefix safepath.AbsDir) (*tls.Config, error) {
+	if (obj.TLSCertFile == "") != (obj.TLSKeyFile == "") {
+		return nil, fmt.Errorf("the tls cert and key must be used together")
+	}
+	if obj.TLSCertFile != "" && len(obj.AutocertHosts) > 0 {
+		return nil, fmt.Errorf("the tls cert and key can't be used with autocert")
+	}
+
+	if obj.TLSCertFile != "" {
+		certFiles := &CertFiles{
+			CertFile: obj.TLSCertFile,
+			KeyFile:  obj.TLSKeyFile,
+		}
+		// Fail now instead of on the first request.
+		if _, err := certFiles.GetCertificate(nil); err != nil {
+			return nil, err
+		}
+		return &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certFiles.GetCertificate,
+		}, nil
+	}
+
+	if len(obj.AutocertHosts) == 0 {
+		return nil, nil // plain http
+	}
+
+	cache := obj.AutocertCache
+	if cache == "" {
+		cache = filepath.Join(prefix.Path(), "autocert")
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cache),
+		HostPolicy: autocert.HostWhitelist(obj.AutocertHosts...),
+		Email:      obj.AutocertEmail,
+	}
+	if obj.AutocertDirectoryURL != "" {
+		manager.Client = &acme.Client{
+			DirectoryURL: obj.AutocertDirectoryURL,
+		}
+	}
+	obj.Logf("autocert: %v in %s", obj.AutocertHosts, cache)
+
+	// This answers the tls-alpn-01 challenges on the same port, so the
+	// authority must be able to reach us on 443.
+	config := manager.TLSConfig()
+	config.MinVersion = tls.VersionTLS12
+	return config, nil
+}
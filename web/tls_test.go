@@ -0,0 +1,115 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/awslabs/yesiscan/web"
+)
+
+// writeCert writes a new self-signed certificate for this name and its key.
+func writeCert(t *testing.T, certFile, keyFile, name string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate key: %+v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("could not create cert: %+v", err)
+	}
+	b, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("could not marshal key: %+v", err)
+	}
+	for file, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: b},
+	} {
+		if err := os.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatalf("could not write: %+v", err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatalf("could not chtimes: %+v", err)
+		}
+	}
+}
+
+func TestCertFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFiles := &web.CertFiles{
+		CertFile: filepath.Join(dir, "cert.pem"),
+		KeyFile:  filepath.Join(dir, "key.pem"),
+	}
+	if _, err := certFiles.GetCertificate(nil); err == nil {
+		t.Errorf("expected an error for missing files")
+	}
+
+	name := func() string {
+		cert, err := certFiles.GetCertificate(nil)
+		if err != nil {
+			t.Fatalf("could not get cert: %+v", err)
+		}
+		c, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatalf("could not parse cert: %+v", err)
+		}
+		return c.Subject.CommonName
+	}
+
+	now := time.Now()
+	writeCert(t, certFiles.CertFile, certFiles.KeyFile, "one", now.Add(-time.Minute))
+	if got := name(); got != "one" {
+		t.Errorf("expected the first cert, got: %s", got)
+	}
+
+	writeCert(t, certFiles.CertFile, certFiles.KeyFile, "two", now)
+	if got := name(); got != "two" {
+		t.Errorf("expected the renewed cert, got: %s", got)
+	}
+
+	// A broken renewal keeps the last good one.
+	if err := os.WriteFile(certFiles.KeyFile, []byte("garbage"), 0600); err != nil {
+		t.Fatalf("could not write: %+v", err)
+	}
+	if got := name(); got != "two" {
+		t.Errorf("expected the last good cert, got: %s", got)
+	}
+}
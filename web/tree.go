@@ -0,0 +1,271 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/util/licenses"
+)
+
+// treeData is what gets embedded in the html report as json, and then rendered
+// into a collapsible tree in the browser. Short json keys are used everywhere,
+// since a scan can have millions of files, and this is sent to the browser.
+type treeData struct {
+	// Matched is the list of licenses that the profile matches. They are
+	// shown in red.
+	Matched []string `json:"m"`
+
+	// Obligations is the obligation of each license in the tree that has a
+	// known one. They are shown next to the licenses of each file.
+	Obligations map[string]string `json:"o,omitempty"`
+
+	// Root is the top of the tree.
+	Root *treeNode `json:"t"`
+}
+
+// treeNode is a file or a directory in the tree.
+type treeNode struct {
+	Name string `json:"n"`
+
+	// Link is where the name should link to. It is only set if there are
+	// results for this node.
+	Link string `json:"h,omitempty"`
+
+	// Confidence is the blended confidence in percent.
+	Confidence float64 `json:"c,omitempty"`
+
+	// Backends are the results of each backend, in the order to show them.
+	Backends []*treeBackend `json:"b,omitempty"`
+
+	// Errors are the scanning errors of this node, if there were any.
+	Errors []string `json:"e,omitempty"`
+
+	// Licenses is the rollup of how many files in this node have each of
+	// the licenses.
+	Licenses map[string]int64 `json:"l,omitempty"`
+
+	// Children are sorted by name, with the directories first.
+	Children []*treeNode `json:"k,omitempty"`
+
+	uid      string
+	children map[string]*treeNode
+}
+
+// treeBackend is the result of one backend for one file.
+type treeBackend struct {
+	Name       string  `json:"n"`
+	Weight     float64 `json:"w"`
+	Total      float64 `json:"t"`
+	Licenses   string  `json:"l"`
+	Confidence float64 `json:"c"`
+
+	// Inherited is the UID that the licenses came from, if they were not
+	// determined for this file directly.
+	Inherited string `json:"i,omitempty"`
+
+	// Inferred is true if the inherited licenses were inferred.
+	Inferred bool `json:"f,omitempty"`
+
+	// Symlink is the UID that this file is a symlink to, if it was one.
+	Symlink string `json:"s,omitempty"`
+
+	// Diff is the word diff against the canonical license text, for a near
+	// match.
+	Diff string `json:"d,omitempty"`
+}
+
+// buildTree builds the tree of all the results which the profile includes. A
+// nil profile includes all of them. It returns nil if there is nothing to show.
+func buildTree(output *lib.Output, profile *lib.ProfileData) (*treeData, error) {
+	nodes := make(map[string]*treeNode) // uid -> node
+	var node func(uid string) *treeNode
+	node = func(uid string) *treeNode { // get or create, with all parents
+		if n, exists := nodes[uid]; exists {
+			return n
+		}
+		n := &treeNode{
+			Name:     uid,
+			uid:      uid,
+			children: make(map[string]*treeNode),
+		}
+		nodes[uid] = n
+		if parent, ok := lib.ParentUID(uid); ok {
+			n.Name = strings.TrimPrefix(uid, parent)
+			if i := strings.Index(parent, "?"); i >= 0 { // query string
+				n.Name = strings.TrimPrefix(uid, parent[:i])
+			}
+			node(parent).children[uid] = n
+		}
+		return n
+	}
+
+	seen := make(map[string]*licenses.License)
+	for _, uid := range lib.SortedUIDs(output.Results) {
+		m := output.Results[uid]
+		if !lib.ProfileIncludes(profile, m) {
+			continue
+		}
+		n := node(uid)
+		n.Link = output.SmartURIs.SmartURI(uid)
+		n.Licenses = make(map[string]int64)
+		bs := []*lib.AnnotatedBackend{}
+		ttl := 0.0
+		for _, backend := range lib.SortedResultBackends(m) {
+			weight, exists := output.BackendWeights[backend]
+			if !exists {
+				return nil, fmt.Errorf("no weight found for backend: %s", backend.String())
+			}
+			bs = append(bs, &lib.AnnotatedBackend{
+				Backend: backend,
+				Weight:  weight,
+			})
+			ttl += weight
+		}
+		n.Confidence = lib.BlendConfidence(output.Blend, bs, m) * 100.0
+		sort.Stable(sort.Reverse(lib.SortedBackends(bs)))
+		for _, b := range bs {
+			result := m[b.Backend]
+			if result.Skip != nil {
+				n.Errors = append(n.Errors, fmt.Sprintf("%s (%s)", result.Skip.Error(), b.Backend.String()))
+			}
+			ls := lib.SortedLicenses(lib.ElectedLicenses(profile, result))
+			for _, x := range ls {
+				n.Licenses[x.String()] = 1 // count each file once
+				seen[x.String()] = x
+			}
+			tb := &treeBackend{
+				Name:       b.Backend.String(),
+				Weight:     b.Weight,
+				Total:      ttl,
+				Licenses:   licenses.Join(ls),
+				Confidence: result.Confidence * 100.0,
+				Diff:       result.Diff,
+			}
+			if result.Meta != nil && result.Meta.Inherited != "" {
+				tb.Inherited = result.Meta.Inherited
+				tb.Inferred = result.Meta.Inferred
+			}
+			if result.Meta != nil {
+				tb.Symlink = result.Meta.Symlink
+			}
+			n.Backends = append(n.Backends, tb)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	// find the top, there might be more than one if the schemes differ
+	tops := []*treeNode{}
+	for _, n := range nodes {
+		if _, ok := lib.ParentUID(n.uid); !ok {
+			tops = append(tops, n)
+		}
+	}
+	root := &treeNode{
+		children: make(map[string]*treeNode),
+	}
+	for _, n := range tops {
+		root.children[n.uid] = n
+	}
+	finishTree(root)
+
+	// skip down past the directories that only have one directory in them
+	for root.Link == "" && len(root.Children) == 1 && len(root.Children[0].Children) > 0 {
+		child := root.Children[0]
+		child.Name = root.Name + child.Name
+		root = child
+	}
+
+	matched := []string{}
+	for name, x := range seen {
+		if lib.ProfileMatch(profile, x) {
+			matched = append(matched, name)
+		}
+	}
+	sort.Strings(matched)
+
+	obligations := make(map[string]string)
+	for name := range seen {
+		if obligation := output.Obligations.Lookup(name); obligation != nil {
+			obligations[name] = obligation.String()
+		}
+	}
+
+	return &treeData{
+		Matched:     matched,
+		Obligations: obligations,
+		Root:        root,
+	}, nil
+}
+
+// finishTree sorts the children of each node and adds up the license rollup of
+// each directory from all of its children.
+func finishTree(n *treeNode) {
+	for _, child := range n.children {
+		finishTree(child)
+		n.Children = append(n.Children, child)
+		if len(child.Licenses) == 0 {
+			continue
+		}
+		if n.Licenses == nil {
+			n.Licenses = make(map[string]int64)
+		}
+		for k, v := range child.Licenses {
+			n.Licenses[k] += v
+		}
+	}
+	sort.Slice(n.Children, func(i, j int) bool {
+		a, b := n.Children[i], n.Children[j]
+		if da, db := len(a.Children) > 0, len(b.Children) > 0; da != db {
+			return da // directories first
+		}
+		return a.Name < b.Name
+	})
+}
+
+// returnTreeHtml returns the html for the tree of results. The tree itself is
+// embedded as json and then rendered in the browser by the script that is in
+// the page template, because a big scan would be far too slow to display as a
+// flat table. It returns the empty string if there is nothing to show.
+func returnTreeHtml(output *lib.Output, profile *lib.ProfileData) (string, error) {
+	data, err := buildTree(output, profile)
+	if err != nil || data == nil {
+		return "", err
+	}
+	// this escapes <, >, and & so it's safe to put inside of a script tag
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	s := `<tr><td><div class="tree"></div>`
+	s += fmt.Sprintf(`<script type="application/json" class="treedata">%s</script>`, b)
+	s += "</td></tr>"
+	return s, nil
+}
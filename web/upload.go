@@ -0,0 +1,152 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/awslabs/yesiscan/util/errwrap"
+	"github.com/awslabs/yesiscan/util/safepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// UploadScheme is the prefix of the uri that the reports of uploaded
+	// files show, since they don't have a real one.
+	UploadScheme = "upload://"
+
+	// DefaultMaxUploadBytes is the largest file that can be uploaded, if
+	// the server doesn't say otherwise.
+	DefaultMaxUploadBytes = 100 * 1024 * 1024 // 100 MiB
+
+	// uploadFormBytes is how much room we leave in the request for the rest
+	// of the form, which has the backends and the profiles in it.
+	uploadFormBytes = 1024 * 1024 // 1 MiB
+)
+
+// maxUploadBytes returns the largest file that can be uploaded.
+func (obj *Server) maxUploadBytes() int64 {
+	if obj.MaxUploadBytes > 0 {
+		return obj.MaxUploadBytes
+	}
+	return DefaultMaxUploadBytes
+}
+
+// saveUpload saves the file from the upload form into a new directory of its
+// own. It returns the path of the file, and a func which removes it again.
+func (obj *Server) saveUpload(c *gin.Context) (safepath.AbsFile, func(), error) {
+	max := obj.maxUploadBytes()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max+uploadFormBytes)
+
+	tooBig := fmt.Errorf("the upload is bigger than the limit of %d MiB", max/1024/1024)
+
+	header, err := c.FormFile("file")
+	if form := c.Request.MultipartForm; form != nil {
+		// The parts that didn't fit in memory were spooled to disk. By
+		// the time we return, they've been copied or they're refused.
+		defer form.RemoveAll()
+	}
+	if err != nil && strings.Contains(err.Error(), "request body too large") {
+		return safepath.AbsFile{}, nil, tooBig
+	}
+	if err != nil {
+		return safepath.AbsFile{}, nil, fmt.Errorf("no file was uploaded")
+	}
+	if header.Size > max {
+		return safepath.AbsFile{}, nil, tooBig
+	}
+
+	src, err := header.Open()
+	if err != nil {
+		return safepath.AbsFile{}, nil, err
+	}
+	defer src.Close()
+
+	dir, err := os.MkdirTemp(obj.uploadPrefix.Path(), "upload-")
+	if err != nil {
+		return safepath.AbsFile{}, nil, err
+	}
+	cleanup := func() {
+		if err := os.RemoveAll(dir); err != nil {
+			obj.Logf("upload: could not remove %s: %+v", dir, err)
+		}
+	}
+	if err := os.Chmod(dir, obj.Perms.DirMode()); err != nil {
+		cleanup()
+		return safepath.AbsFile{}, nil, err
+	}
+
+	// The name is kept so that the archives are recognized by extension.
+	absFile, err := safepath.ParseIntoAbsFile(filepath.Join(dir, UploadName(header.Filename)))
+	if err != nil {
+		cleanup()
+		return safepath.AbsFile{}, nil, err
+	}
+	dst, err := os.OpenFile(absFile.Path(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, obj.Perms.FileMode())
+	if err != nil {
+		cleanup()
+		return safepath.AbsFile{}, nil, err
+	}
+	// Don't trust the size in the header, in case the file was changed.
+	n, err := io.Copy(dst, io.LimitReader(src, max+1))
+	if err != nil {
+		dst.Close()
+		cleanup()
+		return safepath.AbsFile{}, nil, errwrap.Wrapf(err, "could not save the upload")
+	}
+	if n > max {
+		dst.Close()
+		cleanup() // don't leave the partial file behind
+		return safepath.AbsFile{}, nil, tooBig
+	}
+	if err := dst.Close(); err != nil {
+		cleanup()
+		return safepath.AbsFile{}, nil, err
+	}
+
+	return absFile, cleanup, nil
+}
+
+// UploadName returns a safe file name to save an upload as. It only keeps the
+// last element of the name that the browser sent, with any unusual characters
+// replaced. It never returns a name which is empty or which is only dots.
+func UploadName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/")) // windows too
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+	if strings.Trim(name, ".") == "" {
+		return "upload"
+	}
+	return name
+}
@@ -0,0 +1,119 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/yesiscan/web"
+)
+
+func TestUploadName(t *testing.T) {
+	tests := []struct {
+		name string
+		exp  string
+	}{
+		{"code.tar.gz", "code.tar.gz"},
+		{"my code (1).zip", "my_code__1_.zip"},
+		{"/etc/passwd", "passwd"},
+		{"../../etc/passwd", "passwd"},
+		{`..\..\windows\system32`, "system32"},
+		{"dir/", "dir"},
+		{"..", "upload"},
+		{"../..", "upload"},
+		{".", "upload"},
+		{"...", "upload"},
+		{"/", "_"},
+		{"", "upload"},
+		{"nul\x00byte", "nul_byte"},
+		{"ünïcode.txt", "_n_code.txt"},
+	}
+	for _, x := range tests {
+		if s := web.UploadName(x.name); s != x.exp {
+			t.Errorf("name %q: expected %q, got %q", x.name, x.exp, s)
+		}
+		if s := web.UploadName(x.name); strings.ContainsAny(s, `/\`) || strings.Trim(s, ".") == "" {
+			t.Errorf("name %q: unsafe result %q", x.name, s)
+		}
+	}
+}
+
+func TestUploadTooBig(t *testing.T) {
+	// Both the spooled parts of the form and the uploads go in here.
+	dir := t.TempDir()
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", dir)
+
+	obj := &web.Server{
+		Program:        "yesiscan",
+		Logf:           t.Logf,
+		MaxUploadBytes: 1024,
+	}
+	router := obj.Router()
+	router.MaxMultipartMemory = 1 // spool it to disk like a big one
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	fw, err := mw.CreateFormFile("file", "../../big.tar")
+	if err != nil {
+		t.Fatalf("error creating the form: %+v", err)
+	}
+	fw.Write(bytes.Repeat([]byte("x"), 1024+1)) // just over the limit
+	if err := mw.Close(); err != nil {
+		t.Fatalf("error closing the form: %+v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/upload/", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if loc := w.Header().Get("Location"); loc != "" {
+		t.Errorf("expected the upload to be rejected, got sent to: %s", loc)
+	}
+	if !strings.Contains(w.Body.String(), "bigger than the limit") {
+		t.Errorf("expected the limit error, got: %s", w.Body.String())
+	}
+
+	// nothing of the upload may be left behind
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			t.Errorf("the upload left a file behind: %s", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("error walking: %+v", err)
+	}
+}
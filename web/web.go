@@ -33,6 +33,7 @@ import (
 	"fmt"
 	"html/template"
 	"io/fs"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -40,12 +41,14 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/awslabs/yesiscan/art"
 	"github.com/awslabs/yesiscan/interfaces"
 	"github.com/awslabs/yesiscan/iterator"
 	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/publish"
 	"github.com/awslabs/yesiscan/util"
 	"github.com/awslabs/yesiscan/util/errwrap"
 	"github.com/awslabs/yesiscan/util/safepath"
@@ -252,6 +255,50 @@ option:checked {
 
 #summary tr:hover {background-color: unset;}
 
+.tree ul {
+	list-style-type: none;
+	margin: 0;
+	padding-left: 20px;
+}
+
+.tree > ul {
+	padding-left: 0;
+}
+
+.tree .toggle {
+	cursor: pointer;
+	user-select: none;
+}
+
+.tree .rollup {
+	color: grey;
+	font-size: smaller;
+}
+
+.tree .matched {
+	color: red;
+}
+
+.tree .error {
+	color: red;
+}
+
+.tree .obligations {
+	color: grey;
+}
+
+.tree .diff {
+	white-space: pre-wrap;
+	font-size: smaller;
+}
+
+.tree .diff del {
+	color: red;
+}
+
+.tree .diff ins {
+	color: green;
+}
 
 </style>
 </head>
@@ -260,12 +307,16 @@ option:checked {
 
 {{ if not .save }}
 <h1 style="color:#042ea9; text-align: center;">welcome to <a href="/"><img alt="yesiscan logo" height="100px" style="vertical-align: middle;" src="data:image/svg+xml;base64,{{ .image }}" /></a></h1>
+<p><a href="/reports/">previous reports</a></p>
 {{ else }}
 <h3 style="color:#042ea9; text-align: center;">welcome to <a href="https://github.com/awslabs/yesiscan/"><img alt="yesiscan logo" height="40px" style="vertical-align: middle;" src="data:image/svg+xml;base64,{{ .image }}" /></a></h3>
 {{ end }}
-<form action="/scan/" method="POST">
+<form action="/scan/" method="POST" enctype="multipart/form-data">
 <div id="forminput" style="text-align: center;">
 	<input type="text" name="uri" placeholder="enter any uri" value="{{ .uri }}"></input>
+	{{ if not .save }}
+	<div>or upload a file or an archive to scan: <input type="file" name="file" onchange="this.form.action = '/upload/'; this.form.submit();" /></div>
+	{{ end }}
 	<!-- XXX: how do I add this submit button, but keep it all centred?
 	<div>&nbsp;</div>
 	<input type="image" src="/static/icons8-checkmark.svg"></input>
@@ -325,6 +376,7 @@ option:checked {
 <table id="profilestable"><tr><td style="width: 0px;">save:</td><td>
 <div id="profiles">
 <a href="/save/?r={{ .uuid }}"><img alt="save" height="40px" style="vertical-align: middle;" src="data:image/svg+xml;base64,{{ index .base64Files "icons8-download-from-the-cloud.svg" }}" /></a>
+<a href="/save/?r={{ .uuid }}&format=json" style="vertical-align: middle;">json</a>
 </div>
 </td></tr></table>
 
@@ -365,6 +417,144 @@ SPDX-License-Identifier: Apache-2.0
 </pre>
 <a href="https://github.com/awslabs/yesiscan/">https://github.com/awslabs/yesiscan/</a>
 </div>
+<script>
+// Render each results tree from its embedded json. The children of a directory
+// are only built the first time that it is opened, so that big scans are fast.
+(function() {
+	function text(tag, s, cls) {
+		var e = document.createElement(tag);
+		e.textContent = s;
+		if (cls) {
+			e.className = cls;
+		}
+		return e;
+	}
+	function percent(x) {
+		return (x || 0).toFixed(2) + "%";
+	}
+	function rollup(node, matched) {
+		var span = text("span", "", "rollup");
+		var names = Object.keys(node.l || {}).sort();
+		if (names.length == 0) {
+			return span;
+		}
+		span.appendChild(document.createTextNode(" ("));
+		names.forEach(function(name, i) {
+			if (i > 0) {
+				span.appendChild(document.createTextNode(", "));
+			}
+			var s = name + ": " + node.l[name];
+			span.appendChild(matched[name] ? text("span", s, "matched") : document.createTextNode(s));
+		});
+		span.appendChild(document.createTextNode(")"));
+		return span;
+	}
+	function name(node) {
+		if (!node.h || !/^(https?|file):/.test(node.h)) {
+			return text("span", node.n);
+		}
+		var a = text("a", node.n);
+		a.href = node.h;
+		return a;
+	}
+	function details(node, matched, obligations) {
+		var ul = document.createElement("ul");
+		(node.e || []).forEach(function(x) {
+			ul.appendChild(text("li", x, "error"));
+		});
+		(node.b || []).forEach(function(b) {
+			var s = b.n + " (" + b.w.toFixed(2) + "/" + b.t.toFixed(2) + ") " + b.l;
+			if (b.i) {
+				s += (b.f ? " [inferred from " : " [inherited from ") + b.i + "]";
+			}
+			if (b.s) {
+				s += " [symlink to " + b.s + "]";
+			}
+			s += " (" + percent(b.c) + ")";
+			var li = text("li", s);
+			if (b.d) {
+				li.appendChild(diff(b.d));
+			}
+			ul.appendChild(li);
+		});
+		Object.keys(node.l || {}).sort().forEach(function(x) {
+			if (obligations[x]) {
+				ul.appendChild(text("li", "obligations of " + x + ": " + obligations[x], "obligations"));
+			}
+		});
+		return ul;
+	}
+	function diff(s) {
+		var d = document.createElement("details");
+		d.appendChild(text("summary", "differences from the license text"));
+		var pre = text("pre", "", "diff");
+		// removed words are [-like this-] and added words are {+like this+}
+		s.split(/(\[-.*?-\]|\{\+.*?\+\})/).forEach(function(x) {
+			if (/^\[-.*-\]$/.test(x)) {
+				pre.appendChild(text("del", x.slice(2, -2)));
+			} else if (/^\{\+.*\+\}$/.test(x)) {
+				pre.appendChild(text("ins", x.slice(2, -2)));
+			} else {
+				pre.appendChild(document.createTextNode(x));
+			}
+		});
+		d.appendChild(pre);
+		return d;
+	}
+	function render(node, matched, obligations, open) {
+		var li = document.createElement("li");
+		var kids = node.k || [];
+		var toggle = text("span", kids.length > 0 ? "\u25b8 " : "\u2022 ", "toggle");
+		li.appendChild(toggle);
+		li.appendChild(name(node));
+		if (node.h) {
+			li.appendChild(document.createTextNode(" (" + percent(node.c) + ")"));
+		}
+		if (kids.length > 0) {
+			li.appendChild(rollup(node, matched));
+		}
+		var ul = null;
+		var flip = function() {
+			if (ul == null) { // build it lazily
+				ul = node.h ? details(node, matched, obligations) : document.createElement("ul");
+				kids.forEach(function(x) {
+					ul.appendChild(render(x, matched, obligations, false));
+				});
+				li.appendChild(ul);
+			} else {
+				ul.style.display = ul.style.display == "none" ? "" : "none";
+			}
+			var closed = ul.style.display == "none";
+			if (kids.length > 0) {
+				toggle.textContent = closed ? "\u25b8 " : "\u25be ";
+			}
+		};
+		toggle.onclick = flip;
+		if (open || (node.h && kids.length == 0)) {
+			flip(); // files always show their backends
+		}
+		return li;
+	}
+	var scripts = document.querySelectorAll("script.treedata");
+	for (var i = 0; i < scripts.length; i++) {
+		var data = JSON.parse(scripts[i].textContent);
+		var matched = {};
+		(data.m || []).forEach(function(x) {
+			matched[x] = true;
+		});
+		var ul = document.createElement("ul");
+		var obligations = data.o || {};
+		if (data.t.n) {
+			ul.appendChild(render(data.t, matched, obligations, true));
+		} else {
+			(data.t.k || []).forEach(function(x) {
+				ul.appendChild(render(x, matched, obligations, true));
+			});
+		}
+		scripts[i].previousSibling.appendChild(ul);
+	}
+})();
+</script>
 </body>
 </html>
 `
@@ -445,8 +635,171 @@ type Server struct {
 	// "127.0.0.1:8000" or just ":8000".
 	Listen string
 
-	// reportPrefix is the path where we store and load the reports from.
-	reportPrefix safepath.AbsDir
+	// TLSCertFile and TLSKeyFile are the paths to a PEM certificate and its
+	// private key. If they're set, then we serve https instead of http. The
+	// files are loaded again when they change.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// AutocertHosts are the host names to get certificates for from an ACME
+	// certificate authority such as Let's Encrypt. If any are set, then we
+	// serve https with them. It can't be used with TLSCertFile.
+	AutocertHosts []string
+
+	// AutocertEmail is the optional contact address for the ACME account.
+	AutocertEmail string
+
+	// AutocertDirectoryURL is the ACME directory of the certificate
+	// authority. If it is empty, then Let's Encrypt is used.
+	AutocertDirectoryURL string
+
+	// AutocertCache is the directory where the ACME account key and the
+	// certificates are kept. If it is empty, then a directory in the cache
+	// directory of the user is used.
+	AutocertCache string
+
+	// Workspace isolates the downloads and extractions of each scan in a
+	// temporary directory which is removed once the scan is done.
+	Workspace bool
+
+	// Perms is the permission policy for everything that we write. If it
+	// is nil, then the default policy is used.
+	Perms *interfaces.Perms
+
+	// DisplayNames maps the prefixes of UID's to friendlier prefixes which
+	// are shown in the stored reports instead.
+	DisplayNames lib.DisplayNames
+
+	// URL is the public base url of this server, eg: https://example.com
+	// and it is used to link to the reports from the publishers.
+	URL string
+
+	// Publishers are run in the background after each scan is stored.
+	Publishers []publish.Publisher
+
+	// MaxFiles is the most files that each scan will read. If it is zero,
+	// then there is no limit.
+	MaxFiles int64
+
+	// MaxBytes is the most bytes of file data that each scan will read. If
+	// it is zero, then there is no limit.
+	MaxBytes int64
+
+	// MaxDuration is the longest time that each scan will run for. If it
+	// is zero, then there is no limit.
+	MaxDuration time.Duration
+
+	// MaxUploadBytes is the largest file that can be uploaded to scan. If
+	// it is zero, then the DefaultMaxUploadBytes is used.
+	MaxUploadBytes int64
+
+	// MaxConcurrentScans is the most scans that may run at the same time.
+	// Any more are refused until one of them finishes. If it is zero, then
+	// there is no limit.
+	MaxConcurrentScans int
+
+	// MaxScansPerIP is the most scans that each client ip address may start
+	// in the RateWindow. If it is zero, then there is no limit.
+	MaxScansPerIP int
+
+	// MaxScansPerUser is the most scans that each logged in user may start
+	// in the RateWindow. With a TokenAuth, this is each token. If it is
+	// zero, then there is no limit.
+	MaxScansPerUser int
+
+	// RateWindow is how far back the scans are counted for the rate limits.
+	// If it is zero, then the DefaultRateWindow is used.
+	RateWindow time.Duration
+
+	// TrustedProxies are the addresses or cidr ranges of the reverse
+	// proxies in front of us, whose X-Forwarded-For header tells us the ip
+	// address of the client. If it is empty, then the header is ignored,
+	// so that nobody can pretend to be someone else to skip the limits.
+	TrustedProxies []string
+
+	// CurationsPath is the curations file that the reviewers save their
+	// decisions to, and that each scan applies. If it is empty, then the
+	// default location in the users config directory is used.
+	CurationsPath string
+
+	// Reviewers is a map of user name to password of the people who may
+	// save curations from the reports. They log in with http basic auth,
+	// unless there is an Auth, in which case they're already logged in, so
+	// the passwords aren't used. If it is empty, then nobody can.
+	Reviewers map[string]string
+
+	// Auth decides who is making each request. If it is nil, then anyone
+	// can run a scan and see any of the reports.
+	Auth Auth
+
+	// Admins are the users who may see every report, not only their own.
+	Admins []string
+
+	// PublicBadges lets anyone see the status badge of any uri, without
+	// logging in, so that they can be embedded in a README. Otherwise the
+	// badges follow the same rules as the reports.
+	PublicBadges bool
+
+	// Reports is where the reports are stored. If it is nil, then they're
+	// stored on disk in the cache directory of the user.
+	Reports ReportStore
+
+	// CacheRemote is a store of cache entries that is shared with the other
+	// servers and the CI runners. If it is set, then the results of each
+	// backend are cached. Otherwise nothing is.
+	CacheRemote lib.CacheRemote
+
+	// CacheSigningKey is the secret that the entries of the remote cache
+	// are signed with. It must be set if there is a remote cache.
+	CacheSigningKey []byte
+
+	// RetentionAge is how long a stored report is kept for after it was
+	// last written. If it is zero, then they're kept forever.
+	RetentionAge time.Duration
+
+	// RetentionCount is the most stored reports that are kept. The oldest
+	// ones are removed first. If it is zero, then there is no limit.
+	RetentionCount int
+
+	// RetentionBytes is the most disk space that the stored reports may
+	// take up. The oldest ones are removed first. If it is zero, then there
+	// is no limit.
+	RetentionBytes int64
+
+	// SmartURIs are the handlers that turn the UID's in the reports into
+	// links, before the built-in ones are tried. If it is nil, then only
+	// the built-in ones are used.
+	SmartURIs util.SmartURIFuncs
+
+	// uploadPrefix is the path where the uploaded files are kept until
+	// they've been scanned.
+	uploadPrefix safepath.AbsDir
+
+	// curationsMutex guards the read, modify, and write of the curations.
+	curationsMutex sync.Mutex
+
+	// reportsMutex guards the read, modify, and write of stored reports.
+	reportsMutex sync.Mutex
+
+	// summaries is the cache of the short versions of the stored reports,
+	// keyed by their uid.
+	summaries      map[string]*reportsEntry
+	summariesMutex sync.Mutex
+
+	// jobs are the scans that are running in the background, and the ones
+	// that finished recently, keyed by their uid.
+	jobs      map[string]*job
+	jobsMutex sync.Mutex
+
+	// ipLimiter and userLimiter count the scans for the rate limits. They
+	// are nil if there is no limit.
+	ipLimiter    *rateLimiter
+	userLimiter  *rateLimiter
+	limitersOnce sync.Once
+
+	// ctx is the context of the running server. The scans run under it so
+	// that they get cancelled when the server is shut down.
+	ctx context.Context
 
 	// ginEngine is where we store a reference to the current gin engine.
 	ginEngine *gin.Engine
@@ -457,11 +810,11 @@ func (obj *Server) Run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	if err := os.MkdirAll(userCacheDir, interfaces.Umask); err != nil {
+	if err := os.MkdirAll(userCacheDir, obj.Perms.DirMode()); err != nil {
 		return err
 	}
 	prefix := filepath.Join(userCacheDir, obj.Program)
-	if err := os.MkdirAll(prefix, interfaces.Umask); err != nil {
+	if err := os.MkdirAll(prefix, obj.Perms.DirMode()); err != nil {
 		return err
 	}
 	safePrefixAbsDir, err := safepath.ParseIntoAbsDir(prefix)
@@ -475,12 +828,26 @@ func (obj *Server) Run(ctx context.Context) error {
 	//	obj.Logf("error finding home directory: %+v", err)
 	//}
 
-	relDir := safepath.UnsafeParseIntoRelDir("report/")
-	obj.reportPrefix = safepath.JoinToAbsDir(safePrefixAbsDir, relDir)
-	if err := os.MkdirAll(obj.reportPrefix.Path(), interfaces.Umask); err != nil {
+	if obj.Reports == nil {
+		relDir := safepath.UnsafeParseIntoRelDir("report/")
+		obj.Reports = &DiskReportStore{
+			Prefix: safepath.JoinToAbsDir(safePrefixAbsDir, relDir),
+			Perms:  obj.Perms,
+		}
+	}
+	if err := obj.Reports.Init(ctx); err != nil {
+		return errwrap.Wrapf(err, "could not initialize the report store")
+	}
+	obj.Logf("report store: %s", obj.Reports)
+
+	// Anything left in here is from a server that didn't shut down cleanly.
+	obj.uploadPrefix = safepath.JoinToAbsDir(safePrefixAbsDir, safepath.UnsafeParseIntoRelDir("upload/"))
+	if err := os.RemoveAll(obj.uploadPrefix.Path()); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(obj.uploadPrefix.Path(), obj.Perms.DirMode()); err != nil {
 		return err
 	}
-	obj.Logf("report prefix: %s", obj.reportPrefix)
 	listen := serverAddr
 	if obj.Listen != "" {
 		listen = obj.Listen
@@ -494,6 +861,27 @@ func (obj *Server) Run(ctx context.Context) error {
 	//if err := server.Serve(conn); err != nil {
 	//	return err
 	//}
+	if obj.Auth != nil {
+		if err := obj.Auth.Init(ctx); err != nil {
+			return errwrap.Wrapf(err, "could not initialize the %s auth", obj.Auth)
+		}
+		obj.Logf("auth: %s", obj.Auth)
+	}
+
+	if obj.RetentionAge > 0 || obj.RetentionCount > 0 || obj.RetentionBytes > 0 {
+		go obj.janitor(ctx)
+	}
+
+	tlsConfig, err := obj.tlsConfig(safePrefixAbsDir)
+	if err != nil {
+		return errwrap.Wrapf(err, "could not configure tls")
+	}
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+
+	obj.ctx = ctx
 	router := obj.Router()
 	obj.ginEngine = router
 
@@ -503,9 +891,9 @@ func (obj *Server) Run(ctx context.Context) error {
 		if err != nil { // invalid port
 			return err
 		}
-		obj.Logf("server: startup on port %d, test at: http://localhost%s/", port, listen)
+		obj.Logf("server: startup on port %d, test at: %s://localhost%s/", port, scheme, listen)
 	} else {
-		obj.Logf("server: startup on http://%s/", listen)
+		obj.Logf("server: startup on %s://%s/", scheme, listen)
 	}
 
 	//router.Run(listen)
@@ -514,8 +902,15 @@ func (obj *Server) Run(ctx context.Context) error {
 	server := &http.Server{
 		//ReadTimeout: time.Duration(readTimeout) * time.Second,
 		//WriteTimeout: time.Duration(writeTimeout) * time.Second,
-		Addr:    listen,
-		Handler: router,
+		Addr:      listen,
+		Handler:   router,
+		TLSConfig: tlsConfig,
+
+		// Every request context is a child of ours, so that any running
+		// scans get cancelled when the server is shut down.
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
 	}
 
 	go func() {
@@ -525,7 +920,13 @@ func (obj *Server) Run(ctx context.Context) error {
 		}
 	}()
 
-	reterr := server.ListenAndServe()
+	var reterr error
+	if tlsConfig != nil {
+		// The certificates come from the config, not from files.
+		reterr = server.ListenAndServeTLS("", "")
+	} else {
+		reterr = server.ListenAndServe()
+	}
 	if reterr == http.ErrServerClosed {
 		return nil
 	}
@@ -539,7 +940,15 @@ func (obj *Server) Router() *gin.Engine {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	router := gin.Default()
+	// Not gin.Default(), because its logger writes to stdout, and our own one
+	// below already sends everything through Logf.
+	router := gin.New()
+	router.Use(gin.Recovery())
+	// Without this, gin believes any X-Forwarded-For header that it gets.
+	if err := router.SetTrustedProxies(obj.TrustedProxies); err != nil {
+		obj.Logf("invalid trusted proxies: %+v", err)
+		router.SetTrustedProxies(nil) // ignore the header
+	}
 
 	logWriter := &LogWriter{
 		Logf: obj.Logf,
@@ -565,6 +974,27 @@ func (obj *Server) Router() *gin.Engine {
 		c.Redirect(http.StatusFound, "/index.html")
 	})
 
+	// add a ping endpoint for load balancers/etc
+	router.GET("/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "we're alive!",
+		})
+	})
+
+	// Everything after this needs a logged in user if we have an Auth.
+	if obj.Auth != nil {
+		router.Any(AuthPrefix+"*path", obj.Auth.Handle)
+	}
+	// The badges get embedded in places that can't log in, so they can be
+	// registered before the middleware, which then doesn't apply to them.
+	if obj.PublicBadges {
+		router.GET("/badge/", obj.badge)
+	}
+	router.Use(obj.authenticate)
+	if !obj.PublicBadges {
+		router.GET("/badge/", obj.badge)
+	}
+
 	router.GET("/index.html", func(c *gin.Context) {
 
 		c.HTML(http.StatusOK, templateName, gin.H{
@@ -579,34 +1009,11 @@ func (obj *Server) Router() *gin.Engine {
 		})
 	})
 
-	// add a ping endpoint for load balancers/etc
-	router.GET("/ping", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"message": "we're alive!",
-		})
-	})
-
-	scan := func(c *gin.Context) (string, error) {
-
-		uri := c.PostForm("uri")
-		uri = strings.TrimSpace(uri)
-		if uri == "" {
-			return "", fmt.Errorf("empty request")
-		}
-
-		obj.Logf("scan: %s", uri)
-
-		// make sure we're only scanning public URI's, not local data!
-		isGit := strings.HasPrefix(strings.ToLower(uri), iterator.GitScheme)
-		isHttps := strings.HasPrefix(strings.ToLower(uri), iterator.HttpsScheme)
-		// TODO: do we want to allow local use?
-		if !isGit && !isHttps {
-			return "", fmt.Errorf("must pass in git or https uri's")
-		}
-		// TODO: what other sort of uri sanitation do we need to do?
-
-		args := []string{uri}
-
+	// scan starts a scan of the args with the backends and the profiles
+	// from the form, and returns the uid of the job. The uri is what the
+	// report shows was scanned. The cleanup func, if any, runs once the
+	// scan is done, even if it couldn't start.
+	scan := func(c *gin.Context, uri string, args []string, cleanup func()) (string, error) {
 		backends := make(map[string]bool)
 		values := url.Values{}
 		for _, b := range lib.Backends {
@@ -664,8 +1071,15 @@ func (obj *Server) Router() *gin.Engine {
 			HttpOnly: true,
 		})
 
-		// XXX: run in a goroutine (and queue up the jobs...)
-		// XXX: handle cancellation for server shutdown...
+		// The limiter doesn't limit anything, but it counts the bytes
+		// that get downloaded so that we can show them.
+		limiter := &iterator.Limiter{}
+		progress := &lib.Progress{
+			Limiter:  limiter,
+			MaxFiles: obj.MaxFiles,
+		}
+
+		// XXX: queue up the jobs instead of running them all at once
 		m := &lib.Main{
 			Program: obj.Program,
 			Debug:   obj.Debug,
@@ -677,15 +1091,23 @@ func (obj *Server) Router() *gin.Engine {
 			Profiles: profiles,
 
 			//RegexpPath: "", // XXX: add me?
-		}
-		output, err := m.Run(context.TODO())
-		if err != nil {
-			return "", err
-		}
 
-		s, err := ReturnOutputHtmlBody(output)
-		if err != nil {
-			return "", err
+			Workspace: obj.Workspace,
+			Perms:     obj.Perms,
+
+			MaxFiles:    obj.MaxFiles,
+			MaxBytes:    obj.MaxBytes,
+			MaxDuration: obj.MaxDuration,
+
+			CurationsPath: obj.CurationsPath,
+
+			CacheRemote:     obj.CacheRemote,
+			CacheSigningKey: obj.CacheSigningKey,
+
+			Limiter:  limiter,
+			Progress: progress,
+
+			SmartURIs: obj.SmartURIs,
 		}
 
 		report := &Report{
@@ -694,29 +1116,121 @@ func (obj *Server) Router() *gin.Engine {
 			Uri:      uri,
 			Backends: backends,
 			Profiles: profilesMap,
-			// XXX: consider storing full datastructure of profiles
-			Html: s,
-			// XXX: consider storing output instead of HTML
+
+			Owner: c.GetString(gin.AuthUserKey), // empty without an auth
 		}
 
-		//store and get a URL...
-		u, err := obj.Store(report)
+		// The scan runs in the background, and the user waits for it on
+		// the report page, which follows along with the progress.
+		uid, err := obj.startJob(uri, report.Owner, progress, func(ctx context.Context) (string, error) {
+			if cleanup != nil {
+				defer cleanup()
+			}
+			return obj.runScan(ctx, m, report)
+		})
+		if err != nil && cleanup != nil {
+			cleanup()
+		}
+		return uid, err
+	}
+
+	scanURI := func(c *gin.Context) (string, error) {
+
+		uri := c.PostForm("uri")
+		uri = strings.TrimSpace(uri)
+		if uri == "" {
+			return "", fmt.Errorf("empty request")
+		}
+
+		obj.Logf("scan: %s", uri)
+
+		// make sure we're only scanning public URI's, not local data!
+		isGit := strings.HasPrefix(strings.ToLower(uri), iterator.GitScheme)
+		isHttps := strings.HasPrefix(strings.ToLower(uri), iterator.HttpsScheme)
+		// TODO: do we want to allow local use?
+		if !isGit && !isHttps {
+			return "", fmt.Errorf("must pass in git or https uri's")
+		}
+		// TODO: what other sort of uri sanitation do we need to do?
+
+		return scan(c, uri, []string{uri}, nil)
+	}
+
+	// scanUpload saves the uploaded file and scans it. Archives go through
+	// the same iterators as they would if they were downloaded.
+	scanUpload := func(c *gin.Context) (string, error) {
+		absFile, cleanup, err := obj.saveUpload(c)
 		if err != nil {
 			return "", err
 		}
+		uri := UploadScheme + filepath.Base(absFile.Path())
+		obj.Logf("scan: %s", uri)
+
+		return scan(c, uri, []string{absFile.Path()}, cleanup)
+	}
 
-		return u, nil
+	// scanError shows the form again with the error, so it can be fixed.
+	scanError := func(c *gin.Context, err error) {
+		//c.JSON(http.StatusBadRequest, gin.H{
+		//	"message": err.Error(),
+		//})
+		e := `<table id="error">`
+		x := template.HTMLEscapeString(err.Error())
+		e += fmt.Sprintf(`<tr><th style="text-align: center"><i>%s</i></th></tr>`, x)
+		e += "</table>"
+
+		c.HTML(setRetryAfter(c, err), templateName, gin.H{
+			"program":     obj.Program,
+			"version":     obj.Version,
+			"image":       base64Yesiscan,
+			"base64Files": base64Files,
+			"status":      "success",
+			"body":        template.HTML(e), // avoid escaping the html!
+			"uri":         c.PostForm("uri"),
+			"backends":    obj.getCookieBackends(c),
+			"profiles":    obj.getCookieProfiles(c),
+			"fancy":       fancyRendering,
+			"uuid":        "",
+		})
 	}
 
-	// XXX: add to a queue and stick us on the processing page (report)
-	router.POST("/scan/", func(c *gin.Context) {
-		u, err := scan(c) // XXX: run in a goroutine and wait for result
+	// limitScans refuses a scan before anything is read, if whoever is
+	// asking for it already started too many.
+	limitScans := func(c *gin.Context) {
+		if err := obj.limitScans(c); err != nil {
+			scanError(c, err)
+			c.Abort()
+		}
+	}
+
+	router.POST("/upload/", limitScans, func(c *gin.Context) {
+		u, err := scanUpload(c) // starts it, and sends us to the pending report
+		if err != nil {
+			scanError(c, err)
+			return
+		}
+
+		c.Redirect(http.StatusFound, fmt.Sprintf("/report/?r=%s", u))
+	})
+
+	router.POST("/scan/", limitScans, func(c *gin.Context) {
+		u, err := scanURI(c) // starts it, and sends us to the pending report
 		if err != nil {
+			scanError(c, err)
+			return
+		}
+
+		c.Redirect(http.StatusFound, fmt.Sprintf("/report/?r=%s", u))
+	})
+
+	router.GET("/report/", func(c *gin.Context) {
+		r := c.Query("r") // shortcut for c.Request.URL.Query().Get("r")
+		if r == "" {
 			//c.JSON(http.StatusBadRequest, gin.H{
-			//	"message": err.Error(),
+			//	"message": fmt.Errorf("empty request"),
 			//})
 			e := `<table id="error">`
-			x := err.Error()
+			x := fmt.Errorf("empty request").Error()
 			e += fmt.Sprintf(`<tr><th style="text-align: center"><i>%s</i></th></tr>`, x)
 			e += "</table>"
 
@@ -735,29 +1249,21 @@ func (obj *Server) Router() *gin.Engine {
 			})
 			return
 		}
+		obj.Logf("report: %s", r)
 
-		c.Redirect(http.StatusFound, fmt.Sprintf("/report/?r=%s", u))
-	})
-
-	router.GET("/report/", func(c *gin.Context) {
-		r := c.Query("r") // shortcut for c.Request.URL.Query().Get("r")
-		if r == "" {
-			//c.JSON(http.StatusBadRequest, gin.H{
-			//	"message": fmt.Errorf("empty request"),
-			//})
-			e := `<table id="error">`
-			x := fmt.Errorf("empty request").Error()
-			e += fmt.Sprintf(`<tr><th style="text-align: center"><i>%s</i></th></tr>`, x)
-			e += "</table>"
-
+		if j := obj.loadJob(c, r); j != nil {
+			body, ok := obj.returnJobHtml(c, r, j)
+			if !ok { // it's done, so we were sent to the report
+				return
+			}
 			c.HTML(http.StatusOK, templateName, gin.H{
 				"program":     obj.Program,
 				"version":     obj.Version,
 				"image":       base64Yesiscan,
 				"base64Files": base64Files,
 				"status":      "success",
-				"body":        template.HTML(e), // avoid escaping the html!
-				"uri":         c.PostForm("uri"),
+				"body":        template.HTML(body), // avoid escaping the html!
+				"uri":         j.uri,
 				"backends":    obj.getCookieBackends(c),
 				"profiles":    obj.getCookieProfiles(c),
 				"fancy":       fancyRendering,
@@ -765,10 +1271,8 @@ func (obj *Server) Router() *gin.Engine {
 			})
 			return
 		}
-		obj.Logf("report: %s", r)
 
-		// XXX: return a report in progress message if a job exists
-		report, err := obj.Load(r)
+		report, err := obj.loadReport(c, r)
 		if err != nil {
 			//c.JSON(http.StatusBadRequest, gin.H{
 			//	"message": err.Error(),
@@ -794,13 +1298,32 @@ func (obj *Server) Router() *gin.Engine {
 			return
 		}
 
+		body, err := report.RenderHtml()
+		if err != nil {
+			obj.Logf("error during render: %+v", err)
+			e := `<table id="error">`
+			e += fmt.Sprintf(`<tr><th style="text-align: center"><i>%s</i></th></tr>`, template.HTMLEscapeString(err.Error()))
+			e += "</table>"
+			body = e
+		}
+		reviewer := len(obj.Reviewers) > 0 // they log in when they save
+		if obj.Auth != nil {
+			_, reviewer = obj.Reviewers[c.GetString(gin.AuthUserKey)]
+		}
+		body += returnReviewHtml(report, r, reviewer)
+		if curations, err := obj.loadCurations(); err != nil {
+			obj.Logf("error loading curations: %+v", err)
+		} else {
+			body += returnCurationsHtml(report, curations, r, reviewer)
+		}
+
 		c.HTML(http.StatusOK, templateName, gin.H{
 			"program":     report.Program,
 			"version":     report.Version,
 			"image":       base64Yesiscan,
 			"base64Files": base64Files,
 			"status":      "success",
-			"body":        template.HTML(report.Html), // avoid escaping the html!
+			"body":        template.HTML(body), // avoid escaping the html!
 			"uri":         report.Uri,
 			"backends":    report.Backends,
 			"profiles":    report.Profiles,
@@ -809,6 +1332,45 @@ func (obj *Server) Router() *gin.Engine {
 		})
 	})
 
+	// This stops a running scan. A browser gets sent back to the report
+	// page, which shows that it was cancelled, and other clients get json.
+	router.POST("/cancel/", func(c *gin.Context) {
+		r := c.Query("r")
+		j := obj.loadJob(c, r)
+		if j == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "no such scan",
+			})
+			return
+		}
+		if !obj.cancelJob(j) {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "the scan already finished",
+			})
+			return
+		}
+		obj.Logf("scan: cancel: %s", j.uri)
+		if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+			c.JSON(http.StatusOK, gin.H{
+				"message": ErrCancelled.Error(),
+			})
+			return
+		}
+		c.Redirect(http.StatusFound, fmt.Sprintf("/report/?r=%s", r))
+	})
+
+	// This streams the progress of a running scan as server-sent events.
+	router.GET("/progress/", func(c *gin.Context) {
+		j := obj.loadJob(c, c.Query("r"))
+		if j == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "no such scan",
+			})
+			return
+		}
+		obj.streamProgress(c, j)
+	})
+
 	router.GET("/save/", func(c *gin.Context) {
 		r := c.Query("r")
 		if r == "" {
@@ -838,7 +1400,7 @@ func (obj *Server) Router() *gin.Engine {
 		obj.Logf("report: %s", r)
 
 		// XXX: return a report in progress message if a job exists
-		report, err := obj.Load(r)
+		report, err := obj.loadReport(c, r)
 		if err != nil {
 			//c.JSON(http.StatusBadRequest, gin.H{
 			//	"message": err.Error(),
@@ -864,6 +1426,26 @@ func (obj *Server) Router() *gin.Engine {
 			return
 		}
 
+		if c.Query("format") == "json" {
+			if report.Output == nil {
+				c.JSON(http.StatusNotFound, gin.H{
+					"message": "this report has no structured results to save",
+				})
+				return
+			}
+			c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.json", r))
+			c.JSON(http.StatusOK, report.Output)
+			return
+		}
+
+		body, err := report.RenderHtml()
+		if err != nil {
+			// nothing we can do for the client afaict
+			obj.Logf("error during save: %+v", err)
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+
 		filename := fmt.Sprintf("%s.html", r)
 		h := gin.H{
 			"program":     report.Program,
@@ -871,7 +1453,7 @@ func (obj *Server) Router() *gin.Engine {
 			"image":       base64Yesiscan,
 			"base64Files": base64Files,
 			"status":      "success",
-			"body":        template.HTML(report.Html), // avoid escaping the html!
+			"body":        template.HTML(body), // avoid escaping the html!
 			"uri":         report.Uri,
 			"backends":    report.Backends,
 			"profiles":    report.Profiles,
@@ -892,65 +1474,220 @@ func (obj *Server) Router() *gin.Engine {
 		}
 	})
 
+	// Reviewers save their decisions about the files in a report here,
+	// and they're applied to every future scan of the same content.
+	curate := func(c *gin.Context) {
+		r, err := obj.curate(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		c.Redirect(http.StatusFound, fmt.Sprintf("/report/?r=%s", r))
+	}
+
+	// Reviewers move a report through the review states here. A browser
+	// gets sent back to the report, and other clients get the new state.
+	review := func(c *gin.Context) {
+		r, x, err := obj.review(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+			c.JSON(http.StatusOK, x)
+			return
+		}
+		c.Redirect(http.StatusFound, fmt.Sprintf("/report/?r=%s", r))
+	}
+
+	// The reviewers log in with http basic auth, unless they're already
+	// logged in with the auth of the server.
+	reviewerAuth := func(c *gin.Context) {}
+	if len(obj.Reviewers) > 0 && obj.Auth == nil {
+		reviewerAuth = gin.BasicAuth(gin.Accounts(obj.Reviewers))
+	}
+	router.POST("/curate/", reviewerAuth, obj.onlyReviewers, curate)
+	router.POST("/review/", reviewerAuth, obj.onlyReviewers, review)
+
+	// This is the api for the review state of a stored report. It returns
+	// the current state, and the history of how it got there.
+	router.GET("/review/", func(c *gin.Context) {
+		report, err := obj.loadReport(c, c.Query("r"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"state":   report.ReviewState(),
+			"reviews": report.Reviews,
+		})
+	})
+
+	// This lists the stored reports that this user may see, newest first.
+	// For example: /reports/?q=github.com&page=2 and browsers get a page,
+	// while other clients get the json.
+	router.GET("/reports/", func(c *gin.Context) {
+		search := c.Query("q")
+		page, size := reportsPage(c)
+		summaries, total, err := obj.reports(c, search, page, size)
+		if err != nil {
+			obj.Logf("reports: %+v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "could not list the reports",
+			})
+			return
+		}
+
+		if c.Query("format") == "json" || c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+			c.JSON(http.StatusOK, gin.H{
+				"reports": summaries,
+				"total":   total,
+				"page":    page,
+				"size":    size,
+			})
+			return
+		}
+
+		c.HTML(http.StatusOK, templateName, gin.H{
+			"program":     obj.Program,
+			"version":     obj.Version,
+			"image":       base64Yesiscan,
+			"base64Files": base64Files,
+			"status":      "success",
+			"body":        template.HTML(returnReportsHtml(summaries, search, page, size, total)), // avoid escaping the html!
+			"uri":         "",
+			"backends":    obj.getCookieBackends(c),
+			"profiles":    obj.getCookieProfiles(c),
+			"fancy":       fancyRendering,
+			"uuid":        "",
+		})
+	})
+
+	// This is the api for running a query over the results of a stored
+	// report. For example: /query/?r=<uid>&q=confidence+>+0.8
+	router.GET("/query/", func(c *gin.Context) {
+		query, err := lib.ParseQuery(c.Query("q"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": fmt.Sprintf("invalid query: %s", err.Error()),
+			})
+			return
+		}
+		report, err := obj.loadReport(c, c.Query("r"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		if report.Output == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "this report has no structured results to query",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"matches": query.Filter(lib.QueryRows("", report.Output)),
+		})
+	})
+
 	//router.ServeHTTP(w, req) // pass through
 
 	return router
 }
 
 // TODO: consider adding a context.Context
-func (obj *Server) Store(report *Report) (string, error) {
-	if report == nil {
-		return "", fmt.Errorf("got nil report")
+// publish runs each of the publishers on the output of a scan and logs any of
+// the errors, since there is nobody waiting on the result.
+func (obj *Server) publish(ctx context.Context, output *lib.Output, reportURL string) {
+	for _, x := range obj.Publishers {
+		if err := x.Publish(ctx, output, reportURL); err != nil {
+			obj.Logf("publish: %s: %+v", x, err)
+		}
 	}
-	// make a unique ID for the file
-	// XXX: we can consider different algorithms or methods here later...
-	now := strconv.FormatInt(time.Now().UnixMilli(), 10) // itoa but int64
-	sum := sha256.Sum256([]byte(report.Html + now))      // XXX: for now
-	uid := fmt.Sprintf("%x", sum)
-	hashRelFile, err := safepath.ParseIntoRelFile(fmt.Sprintf("%s.json", uid))
+}
+
+// runScan runs the scan and stores the report, which already has the details
+// of the request filled in. It returns the uid of the stored report.
+func (obj *Server) runScan(ctx context.Context, m *lib.Main, report *Report) (string, error) {
+	output, err := m.Run(ctx)
 	if err != nil {
+		if ctx.Err() != nil {
+			obj.Logf("scan: cancelled: %s", report.Uri)
+		}
 		return "", err
 	}
-	// TODO: split into subfolders when we have very large numbers of files
-	absFile := safepath.JoinToAbsFile(obj.reportPrefix, hashRelFile)
-	obj.Logf("report: %s", absFile)
 
-	b, err := json.Marshal(report)
+	report.ProfilesData = output.ProfilesData
+	report.Hashes = output.ContentHashes
+	report.Output = lib.NewJSONOutput(output)
+
+	//store and get a URL...
+	u, err := obj.Store(report)
 	if err != nil {
 		return "", err
 	}
 
-	if err := os.WriteFile(absFile.Path(), b, os.ModePerm); err != nil {
-		return "", errwrap.Wrapf(err, "error writing our file to disk at %s", absFile)
+	if len(obj.Publishers) > 0 {
+		reportURL := ""
+		if obj.URL != "" {
+			reportURL = fmt.Sprintf("%s/report/?r=%s", strings.TrimRight(obj.URL, "/"), u)
+		}
+		// Don't hold up the report while we wait on the publishers,
+		// and don't stop them if the server shuts down.
+		go obj.publish(context.Background(), output, reportURL)
 	}
 
-	return uid, nil
+	return u, nil
 }
 
-// TODO: consider adding a context.Context
-// TODO: we have no auth on this at the moment, anyone can lookup a report
-func (obj *Server) Load(uid string) (*Report, error) {
-	if len(uid) != 64 { // length of a sha256sum
-		return nil, fmt.Errorf("invalid uid length")
+func (obj *Server) Store(report *Report) (string, error) {
+	if report == nil {
+		return "", fmt.Errorf("got nil report")
 	}
+	if report.Time == "" {
+		report.Time = time.Now().UTC().Format(time.RFC3339)
+	}
+	b, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	if len(obj.DisplayNames) > 0 {
+		b = []byte(obj.DisplayNames.Replace(string(b)))
+	}
+
+	// make a unique ID for the file
+	// XXX: we can consider different algorithms or methods here later...
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10) // itoa but int64
+	sum := sha256.Sum256(append(b, []byte(now)...))      // XXX: for now
+	uid := fmt.Sprintf("%x", sum)
+	obj.Logf("report: %s", uid)
 
-	// remove all the valid characters, it should be empty!
-	// NOTE: this importantly also blocks path traversal hacks like ../ too!
-	if cut := strings.Trim(uid, "0123456789abcdef"); len(cut) != 0 {
-		return nil, fmt.Errorf("invalid uid characters")
+	// TODO: consider adding a context.Context
+	if err := obj.Reports.Put(context.TODO(), uid, b); err != nil {
+		return "", errwrap.Wrapf(err, "error storing report %s", uid)
 	}
 
-	hashRelFile, err := safepath.ParseIntoRelFile(fmt.Sprintf("%s.json", uid))
-	if err != nil {
+	return uid, nil
+}
+
+// TODO: consider adding a context.Context
+func (obj *Server) Load(uid string) (*Report, error) {
+	if err := ValidUID(uid); err != nil {
 		return nil, err
 	}
-	// TODO: lookup from subfolders when we have very large numbers of files
-	absFile := safepath.JoinToAbsFile(obj.reportPrefix, hashRelFile)
-	obj.Logf("report: %s", absFile)
+	obj.Logf("report: %s", uid)
 
-	b, err := os.ReadFile(absFile.Path())
+	b, err := obj.Reports.Get(context.TODO(), uid)
 	if err != nil {
-		return nil, errwrap.Wrapf(err, "error reading our file from disk at %s", absFile)
+		return nil, err
 	}
 
 	buf := bytes.NewBuffer(b)
@@ -958,15 +1695,39 @@ func (obj *Server) Load(uid string) (*Report, error) {
 
 	var report Report // this gets populated during decode
 	if err := decoder.Decode(&report); err != nil {
-		return nil, errwrap.Wrapf(err, "error decoding the json from disk at %s", absFile)
+		return nil, errwrap.Wrapf(err, "error decoding the json of report %s", uid)
 	}
 	if &report == nil {
 		return nil, fmt.Errorf("empty report")
 	}
+	report.smartURIs = obj.SmartURIs
 
 	return &report, nil
 }
 
+// Update changes a stored report. The change is saved only if fn doesn't error,
+// and it replaces the report in one step, so that nobody sees half of it. Other
+// servers which share the store may still overwrite the change with their own.
+func (obj *Server) Update(uid string, fn func(*Report) error) error {
+	obj.reportsMutex.Lock()
+	defer obj.reportsMutex.Unlock()
+
+	report, err := obj.Load(uid)
+	if err != nil {
+		return err
+	}
+	if err := fn(report); err != nil {
+		return err
+	}
+	b, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	// TODO: consider adding a context.Context
+	return obj.Reports.Put(context.TODO(), uid, b)
+}
+
 func (obj *Server) getCookieBackends(c *gin.Context) map[string]bool {
 	// build the default set of backends to display on a new page
 	backends := make(map[string]bool)
@@ -1038,38 +1799,290 @@ type Report struct {
 	// Profiles are a set of specified profile names that users may specify.
 	Profiles map[string]bool `json:"profiles"`
 
-	// Html is a rendered version of the core report content.
-	// XXX: we might choose to store the data itself in the future...
-	Html string `json:"html"`
+	// Owner is the user who ran the scan, if there was an auth. Only they
+	// and the admins may see the report.
+	Owner string `json:"owner,omitempty"`
+
+	// Time is when the scan finished, in RFC 3339 format. Older reports
+	// don't have it.
+	Time string `json:"time,omitempty"`
+
+	// Reviews is the history of the review state of the report, with the
+	// newest last. If it is empty, then the report is new.
+	Reviews []*Review `json:"reviews,omitempty"`
+
+	// ProfilesData is the content of each of the profiles that were used,
+	// so that the report can be displayed the same way later on.
+	ProfilesData map[string]*lib.ProfileData `json:"profiles-data,omitempty"`
+
+	// Hashes is the sha256 content hash of each file, keyed by UID. This is
+	// what the curations of the reviewers are saved against.
+	Hashes map[string]string `json:"hashes,omitempty"`
+
+	// Html is a rendered version of the core report content. New reports
+	// don't store this, since they're rendered from the Output each time
+	// they are viewed, but reports from older versions only have this.
+	Html string `json:"html,omitempty"`
+
+	// Output is the structured version of the report content, which is
+	// what queries run against and what gets rendered when it's viewed.
+	// Reports from before this was added don't have it.
+	Output *lib.JSONOutput `json:"output,omitempty"`
+
+	// smartURIs are the handlers that turn the UID's into links when this
+	// is displayed. They come from the server that loaded the report.
+	smartURIs util.SmartURIFuncs
+}
+
+// RenderHtml returns the core report content formatted in html. This is built
+// from the structured output each time, so that old reports get displayed with
+// the current templates. Older reports which only stored the rendered html
+// return that instead.
+func (obj *Report) RenderHtml() (string, error) {
+	if obj.Output == nil {
+		return obj.Html, nil
+	}
+	output := lib.NewOutputFromJSON(obj.Output)
+	for k, v := range obj.ProfilesData {
+		output.ProfilesData[k] = v
+	}
+	obligations, err := lib.DefaultObligations()
+	if err != nil {
+		return "", err
+	}
+	output.Obligations = obligations
+	output.SmartURIs = obj.smartURIs
+	return ReturnOutputHtmlBody(output)
 }
 
 // ReturnOutputHtmlBody returns a string of output, formatted in html. It is
 // the body portion of the larger full html output that comes from
 // ReturnOutputHtml.
 func ReturnOutputHtmlBody(output *lib.Output) (string, error) {
+	str := ""
+	if output.Incomplete != "" {
+		s := `<table id="error">`
+		s += fmt.Sprintf(`<tr><th style="text-align: left">incomplete: %s</th></tr>`, template.HTMLEscapeString(output.Incomplete))
+		s += "</table>"
+		str += s + "<br />"
+	}
+	if output.Quick {
+		s := `<table id="error">`
+		s += fmt.Sprintf(`<tr><th style="text-align: left">quick: %s</th></tr>`, template.HTMLEscapeString(lib.QuickWarning))
+		s += "</table>"
+		str += s + "<br />"
+	}
+	if output.Scoped != "" {
+		s := `<table id="error">`
+		s += fmt.Sprintf(`<tr><th style="text-align: left">scope: %s</th></tr>`, template.HTMLEscapeString(output.Scoped))
+		s += "</table>"
+		str += s + "<br />"
+	}
+
 	if len(output.Results) == 0 {
 		// handle this here, otherwise we'll get an error below...
 		s := `<table id="report">`
 		x := "no results obtained"
 		s += fmt.Sprintf(`<tr><th style="text-align: center"><i>%s</i></th></tr>`, x)
 		s += "</table>"
-		return s, nil
+		return str + s, nil
 	}
 
-	str := ""
-	for _, x := range output.Profiles {
-		pro, err := lib.SimpleProfiles(output.Results, output.Passes, output.Warnings, output.ProfilesData[x], displaySummary, output.BackendWeights, "html")
+	if len(output.Profiles) <= 1 {
+		for _, x := range output.Profiles {
+			pro, err := lib.SimpleProfilesSummary(output.Results, output.SimpleProfilesOptions(output.ProfilesData[x], displaySummary, "html"))
+			if err != nil {
+				return "", err
+			}
+			tree, err := returnTreeHtml(output, output.ProfilesData[x])
+			if err != nil {
+				return "", err
+			}
+			s := `<table id="report">`
+			s += fmt.Sprintf(`<tr><th style="text-align: left">profile <i>%s</i>:</th></tr>`, x)
+			s += fmt.Sprintf("%s", pro)
+			s += tree
+			s += "</table>"
+			str += s + "<br />"
+		}
+
+		r, err := returnReuseHtml(output)
 		if err != nil {
 			return "", err
 		}
-		s := `<table id="report">`
-		s += fmt.Sprintf(`<tr><th style="text-align: left">profile <i>%s</i>:</th></tr>`, x)
-		s += fmt.Sprintf("%s", pro)
-		s += "</table>"
-		str += s + "<br />"
+		p, err := returnPackagesHtml(output)
+		if err != nil {
+			return "", err
+		}
+		l, err := returnLanguagesHtml(output)
+		if err != nil {
+			return "", err
+		}
+		t, err := returnProvenanceHtml(output)
+		if err != nil {
+			return "", err
+		}
+		e, err := returnElectionsHtml(output)
+		if err != nil {
+			return "", err
+		}
+		return str + r + p + e + l + t, nil
 	}
 
-	return str, nil
+	// With more than one profile, show the verdict matrix at the top and
+	// then the full report once, instead of repeating it for each profile.
+	verdicts := output.Verdicts
+	if verdicts == nil {
+		verdicts = lib.Verdicts(output)
+	}
+	matrix, err := lib.ReturnVerdicts(verdicts, "html")
+	if err != nil {
+		return "", err
+	}
+	s := `<table id="report">`
+	s += `<tr><th style="text-align: left">verdicts:</th></tr>`
+	s += fmt.Sprintf("<tr><td>%s</td></tr>", matrix)
+	if output.Packages != nil {
+		p, err := lib.ReturnPackages(output.Packages, "html")
+		if err != nil {
+			return "", err
+		}
+		s += `<tr><th style="text-align: left">packages:</th></tr>`
+		s += fmt.Sprintf("<tr><td>%s</td></tr>", p)
+	}
+	for _, x := range verdicts {
+		if len(x.Violations) == 0 || x.Profile == lib.ReuseProfileName {
+			continue // the reuse report is shown separately
+		}
+		s += fmt.Sprintf(`<tr><th style="text-align: left">profile <i>%s</i> violations in <i>%s</i>:</th></tr>`, template.HTMLEscapeString(x.Profile), template.HTMLEscapeString(x.Artifact))
+		s += "<tr><td><ul>"
+		for _, uid := range x.Violations {
+			s += fmt.Sprintf("<li>%s</li>", util.HtmlHyperlinkEncode(uid, output.SmartURIs.SmartURI(uid)))
+		}
+		s += "</ul></td></tr>"
+	}
+	s += "</table>"
+	str += s + "<br />"
+
+	r, err := returnReuseHtml(output)
+	if err != nil {
+		return "", err
+	}
+	str += r
+
+	pro, err := lib.SimpleProfilesSummary(output.Results, output.SimpleProfilesOptions(nil, displaySummary, "html"))
+	if err != nil {
+		return "", err
+	}
+	tree, err := returnTreeHtml(output, nil)
+	if err != nil {
+		return "", err
+	}
+	s = `<table id="report">`
+	s += `<tr><th style="text-align: left">all results:</th></tr>`
+	s += fmt.Sprintf("%s", pro)
+	s += tree
+	s += "</table>"
+	str += s + "<br />"
+
+	e, err := returnElectionsHtml(output)
+	if err != nil {
+		return "", err
+	}
+	l, err := returnLanguagesHtml(output)
+	if err != nil {
+		return "", err
+	}
+	t, err := returnProvenanceHtml(output)
+	if err != nil {
+		return "", err
+	}
+	return str + e + l + t, nil
+}
+
+// returnReuseHtml returns the REUSE compliance reports as an html table, or the
+// empty string if the check was not enabled.
+func returnReuseHtml(output *lib.Output) (string, error) {
+	if output.Reuse == nil {
+		return "", nil
+	}
+	r, err := lib.ReturnReuse(output.Reuse, "html")
+	if err != nil {
+		return "", err
+	}
+	s := `<table id="report">`
+	s += `<tr><th style="text-align: left">reuse:</th></tr>`
+	s += fmt.Sprintf("<tr><td>%s</td></tr>", r)
+	s += "</table>"
+	return s + "<br />", nil
+}
+
+// returnPackagesHtml returns the verdicts of each package as an html table, or
+// the empty string if no packages were found.
+func returnPackagesHtml(output *lib.Output) (string, error) {
+	if output.Packages == nil {
+		return "", nil
+	}
+	p, err := lib.ReturnPackages(output.Packages, "html")
+	if err != nil {
+		return "", err
+	}
+	s := `<table id="report">`
+	s += `<tr><th style="text-align: left">packages:</th></tr>`
+	s += fmt.Sprintf("<tr><td>%s</td></tr>", p)
+	s += "</table>"
+	return s + "<br />", nil
+}
+
+// returnElectionsHtml returns the choices of license that the profiles made as
+// an html table, or the empty string if none were made.
+func returnElectionsHtml(output *lib.Output) (string, error) {
+	if len(output.Elections) == 0 {
+		return "", nil
+	}
+	e, err := lib.ReturnElections(output.Elections, "html")
+	if err != nil {
+		return "", err
+	}
+	s := `<table id="report">`
+	s += `<tr><th style="text-align: left">elections:</th></tr>`
+	s += fmt.Sprintf("<tr><td>%s</td></tr>", e)
+	s += "</table>"
+	return s + "<br />", nil
+}
+
+// returnLanguagesHtml returns the statistics of each language as an html table,
+// or the empty string if there are none.
+func returnLanguagesHtml(output *lib.Output) (string, error) {
+	if len(output.Languages) == 0 {
+		return "", nil
+	}
+	l, err := lib.ReturnLanguages(output.Languages, "html")
+	if err != nil {
+		return "", err
+	}
+	s := `<table id="report">`
+	s += `<tr><th style="text-align: left">languages:</th></tr>`
+	s += fmt.Sprintf("<tr><td>%s</td></tr>", l)
+	s += "</table>"
+	return s + "<br />", nil
+}
+
+// returnProvenanceHtml returns the provenance tree as an html table, or the
+// empty string if none of the iterators were nested.
+func returnProvenanceHtml(output *lib.Output) (string, error) {
+	if lib.ProvenanceDepth(output.Provenance) <= 1 {
+		return "", nil
+	}
+	p, err := lib.ReturnProvenance(output.Provenance, "html")
+	if err != nil {
+		return "", err
+	}
+	s := `<table id="report">`
+	s += `<tr><th style="text-align: left">provenance:</th></tr>`
+	s += fmt.Sprintf("<tr><td>%s</td></tr>", p)
+	s += "</table>"
+	return s + "<br />", nil
 }
 
 // ReturnOutputHtml returns a string of output, formatted in html.
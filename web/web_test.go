@@ -0,0 +1,146 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package web_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awslabs/yesiscan/web"
+)
+
+// freeListen returns an address on localhost for a server to listen on.
+func freeListen(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	defer l.Close() // the server listens on it itself
+	return l.Addr().String()
+}
+
+func TestShutdownCancelsScans(t *testing.T) {
+	dir := t.TempDir()
+	for _, k := range []string{"HOME", "XDG_CACHE_HOME"} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Setenv(k, dir)
+	}
+
+	// This accepts the download, but never answers it, so the scan hangs
+	// until something stops it.
+	hang, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	defer hang.Close()
+	go func() {
+		for {
+			conn, err := hang.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	listen := freeListen(t)
+	mu := &sync.Mutex{}
+	logs := []string{}
+	obj := &web.Server{
+		Program: "yesiscan",
+		Logf: func(format string, v ...interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+			logs = append(logs, fmt.Sprintf(format, v...))
+		},
+		Listen: listen,
+	}
+	logged := func(s string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, x := range logs {
+			if strings.Contains(x, s) {
+				return true
+			}
+		}
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan error)
+	go func() {
+		ch <- obj.Run(ctx)
+	}()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	uri := fmt.Sprintf("https://%s/code.tar.gz", hang.Addr().String())
+	var resp *http.Response
+	for i := 0; ; i++ { // wait for the server to start
+		resp, err = client.PostForm("http://"+listen+"/scan/", url.Values{"uri": {uri}})
+		if err == nil || i == 100 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected the scan to start, got: %d", resp.StatusCode)
+	}
+
+	// give the scan a moment to get to the download, before we shut down
+	for i := 0; i < 100 && !logged("downloading "+uri); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	cancel()
+	if err := <-ch; err != nil {
+		t.Errorf("error from the server: %+v", err)
+	}
+
+	// the download would hang for far longer than this if not cancelled
+	exp := fmt.Sprintf("scan: %s: %s", uri, web.ErrCancelled)
+	for i := 0; i < 100 && !logged(exp); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !logged(exp) {
+		mu.Lock()
+		defer mu.Unlock()
+		t.Errorf("expected the scan to be cancelled, got the logs:\n%s", strings.Join(logs, "\n"))
+	}
+}
@@ -0,0 +1,424 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package yesiscan is the embedding API for this project. Other golang
+// programs that want to run a scan should use this package instead of reaching
+// into the internals. There is no package level mutable state, so many scanners
+// may be built and used concurrently.
+//
+//	y, err := yesiscan.New(&yesiscan.Options{Logf: log.Printf})
+//	if err != nil {
+//		// handle error
+//	}
+//	output, err := y.Scan(ctx, "https://github.com/awslabs/yesiscan/")
+package yesiscan
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/awslabs/yesiscan/backend"
+	"github.com/awslabs/yesiscan/interfaces"
+	"github.com/awslabs/yesiscan/iterator"
+	"github.com/awslabs/yesiscan/lib"
+	"github.com/awslabs/yesiscan/parser"
+	"github.com/awslabs/yesiscan/util"
+)
+
+// DefaultProgram is the program name used when none is specified. It decides
+// where we look for config files and where things get cached.
+const DefaultProgram = "yesiscan"
+
+// Options are the settings used to build a new scanner. The zero value of each
+// field is a sensible default.
+type Options struct {
+	// Program is the name of the program. It is used to pick the cache and
+	// config directories. If it is empty, then DefaultProgram is used.
+	Program string
+
+	// Version is the version of the calling program. It is only used for
+	// display purposes in the output.
+	Version string
+
+	// Debug enables debug logging.
+	Debug bool
+
+	// Logf is where all the log messages go. If it is nil, then they are
+	// discarded.
+	Logf func(format string, v ...interface{})
+
+	// Backends is the set of backends to use. The keys are names from
+	// lib.Backends. If it is nil, then all of them are enabled.
+	Backends map[string]bool
+
+	// Profiles is the list of profiles to use. Either the names from the
+	// program config directory or full paths.
+	Profiles []string
+
+	// RegexpPath specifies a path the regular expressions to use.
+	RegexpPath string
+
+	// OrtAnalyzerPath is the path to the json output of the ORT analyzer.
+	// The source of each package in there is also scanned by every Scan.
+	OrtAnalyzerPath string
+
+	// ChecksumsPath is the path to a manifest of the expected checksums of
+	// the inputs which get downloaded.
+	ChecksumsPath string
+
+	// Checksums maps input arguments to the hex sha256 sum that the
+	// downloaded artifact must have. An artifact that doesn't match is
+	// never scanned.
+	Checksums map[string]string
+
+	// Offline forbids any network access. Inputs must be local paths, or
+	// git repositories which are already in the local cache.
+	Offline bool
+
+	// BandwidthLimit is the maximum number of bytes per second that all
+	// the downloads of this scanner share. Zero means there is no limit.
+	BandwidthLimit int64
+
+	// HostBandwidthLimit is the maximum number of bytes per second that
+	// this scanner downloads from any one host. Zero means there is no
+	// limit.
+	HostBandwidthLimit int64
+
+	// MaxDownloads is the maximum number of downloads that this scanner
+	// runs at the same time. Zero means there is no limit.
+	MaxDownloads int
+
+	// MaxFiles is the most files that a scan will read. When it goes over,
+	// the scan stops and the partial results are returned, with the reason
+	// in the Incomplete field of the output. Zero means there is no limit.
+	MaxFiles int64
+
+	// MaxBytes is the most bytes of file data that a scan will read. When
+	// it goes over, the scan stops and the partial results are returned.
+	// Zero means there is no limit.
+	MaxBytes int64
+
+	// MaxDuration is the longest time that a scan will run for. When it
+	// goes over, the scan stops and the partial results are returned. Zero
+	// means there is no limit.
+	MaxDuration time.Duration
+
+	// ParserPlugins maps a lower case URI scheme to the parser plugin that
+	// parses the inputs with that scheme, such as a company-internal
+	// artifact locator. See parser.ExecPlugin for running a command.
+	ParserPlugins map[string]parser.PluginFunc
+
+	// InferLicenses enables the license inference pass.
+	InferLicenses bool
+
+	// Reuse enables the REUSE specification compliance check.
+	Reuse bool
+
+	// ExtractCopyrights collects the copyright statements in each file for
+	// the NOTICE output.
+	ExtractCopyrights bool
+
+	// IgnorePath specifies a path to the ignore list of content hashes.
+	IgnorePath string
+
+	// CurationsPath specifies a path to the curations file of the decisions
+	// that reviewers made about the findings, keyed by content hash.
+	CurationsPath string
+
+	// ObligationsPath specifies a path to a file of license obligations.
+	ObligationsPath string
+
+	// Ownership is the set of rules that classify each scanned file as
+	// first-party or third-party code.
+	Ownership *lib.Ownership
+
+	// Scope limits the scan to the files which were changed in git since a
+	// date or a commit.
+	Scope *lib.Scope
+
+	// SummaryFirstParty includes the first-party code in the summaries.
+	SummaryFirstParty bool
+
+	// Blend is the method used to combine the confidence values of the
+	// different backends. If it is empty, then lib.DefaultBlend is used.
+	Blend string
+
+	// SmartURIs are the handlers that turn the UID's of the results into
+	// links, before the built-in ones are tried. Build them from templates
+	// with util.NewSmartURIFuncs. If it is nil, only the built-in are used.
+	SmartURIs util.SmartURIFuncs
+
+	// Duplicates is the policy for what to do when a backend gives us two
+	// different results for the same path. If it is empty, then
+	// lib.DefaultDuplicates is used.
+	Duplicates string
+
+	// MemoryBudget is the maximum number of bytes of file data to hold in
+	// memory at once while scanning. If it is zero, then there is no limit.
+	MemoryBudget int64
+
+	// MmapThreshold is the file size in bytes at or above which files are
+	// memory mapped instead of read. If it is zero, then we never use mmap.
+	MmapThreshold int64
+
+	// Workspace isolates the downloads and extractions of each scan in a
+	// temporary directory which is removed once the scan is done.
+	Workspace bool
+
+	// Perms is the permission policy for everything that we write. If it
+	// is nil, then the default policy is used.
+	Perms *interfaces.Perms
+
+	// Timings enables collecting the time spent in each stage of the scan.
+	// They are returned in the Timings field of the output.
+	Timings bool
+
+	// Cache enables the on-disk cache of the results of each backend for
+	// each file. Upgrading a backend or the license database invalidates
+	// the cached results automatically.
+	Cache bool
+
+	// CacheRemote is a store of cache entries that is shared with other
+	// machines, such as an S3 bucket. It enables the cache.
+	CacheRemote lib.CacheRemote
+
+	// CacheSigningKey is the secret that the entries of the remote cache
+	// are signed with. It must be set if there is a remote cache.
+	CacheSigningKey []byte
+
+	// Estimate predicts how long each backend will take before the scan,
+	// from the throughput of previous scans.
+	Estimate bool
+
+	// RawOutput keeps the compressed json output of the scancode and the
+	// askalono backends in each of their results, so that a determination
+	// can be reviewed without scanning again. It is off by default, since
+	// it makes the output much larger.
+	RawOutput bool
+
+	// Quick only runs the fast built-in backends, which gives a lower
+	// assurance result in a fraction of the time. The output is marked.
+	Quick bool
+
+	// Deep extracts the text from PDF, DOCX and RTF documents so that it
+	// gets scanned instead of the raw file data.
+	Deep bool
+
+	// Schedule only runs the expensive backends, such as scancode, on the
+	// files where the cheap ones found nothing, or disagreed.
+	Schedule bool
+
+	// OnResult is called with each result as soon as it's found, while the
+	// scan is still running. It may be called concurrently. If it is nil,
+	// then nothing is called.
+	OnResult func(uid string, backend interfaces.Backend, result *interfaces.Result)
+
+	// Scancode are the options that get passed through to the scancode
+	// backend. If it is nil, then the defaults are used.
+	Scancode *backend.ScancodeOptions
+
+	// Askalono are the options for the askalono backend. If it is nil,
+	// then the defaults are used.
+	Askalono *backend.AskalonoOptions
+
+	// GomodProxy is the url of the module proxy that the gomod backend
+	// downloads the modules which aren't in the module cache from.
+	GomodProxy string
+
+	// Stdin is read from when one of the inputs is "-" or when there are no
+	// inputs at all. If it is nil, then either of those is an error.
+	Stdin io.Reader
+}
+
+// Yesiscan is a scanner built from a set of options. It is safe to call Scan
+// from multiple goroutines at the same time.
+type Yesiscan struct {
+	options *Options
+
+	// limiter is shared by all of the scans, so that the limits apply to
+	// the scanner as a whole.
+	limiter *iterator.Limiter
+}
+
+// New validates the options and returns a new scanner. The options are copied,
+// so the caller may modify them afterwards without affecting this scanner.
+func New(options *Options) (*Yesiscan, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	if err := lib.ValidateBlend(options.Blend); err != nil {
+		return nil, err
+	}
+	if err := lib.ValidateDuplicates(options.Duplicates); err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]struct{})
+	for _, name := range lib.Backends {
+		known[name] = struct{}{}
+	}
+	backends := make(map[string]bool)
+	for name, enabled := range options.Backends {
+		if _, exists := known[name]; !exists {
+			return nil, fmt.Errorf("unknown backend: %s", name)
+		}
+		backends[name] = enabled
+	}
+	if options.Backends == nil {
+		for _, name := range lib.Backends {
+			backends[name] = true
+		}
+	}
+
+	limiter := &iterator.Limiter{
+		Rate:      options.BandwidthLimit,
+		HostRate:  options.HostBandwidthLimit,
+		Downloads: options.MaxDownloads,
+	}
+	if err := limiter.Validate(); err != nil {
+		return nil, err
+	}
+
+	o := *options // copy
+	o.Backends = backends
+	o.Profiles = append([]string{}, options.Profiles...)
+	o.ParserPlugins = make(map[string]parser.PluginFunc)
+	for k, v := range options.ParserPlugins {
+		o.ParserPlugins[strings.ToLower(k)] = v
+	}
+	if o.Program == "" {
+		o.Program = DefaultProgram
+	}
+	if o.Logf == nil {
+		o.Logf = func(format string, v ...interface{}) {}
+	}
+
+	return &Yesiscan{
+		options: &o,
+		limiter: limiter,
+	}, nil
+}
+
+// Scan runs a scan over each of the inputs and returns the combined output.
+// Each input is anything that the URI parser can understand, such as a local
+// path, a git URL, or an http URL to an archive.
+func (obj *Yesiscan) Scan(ctx context.Context, inputs ...string) (*lib.Output, error) {
+	return obj.scan(ctx, inputs, nil)
+}
+
+// scan runs the actual scan over the inputs and any additional iterators.
+func (obj *Yesiscan) scan(ctx context.Context, inputs []string, iterators []interfaces.Iterator) (*lib.Output, error) {
+	backends := make(map[string]bool)
+	for k, v := range obj.options.Backends {
+		backends[k] = v // copy so that each scan owns its own map
+	}
+
+	m := &lib.Main{
+		Program: obj.options.Program,
+		Version: obj.options.Version,
+		Debug:   obj.options.Debug,
+		Logf:    obj.options.Logf,
+
+		Args:      append([]string{}, inputs...),
+		Stdin:     obj.options.Stdin,
+		Iterators: iterators,
+
+		Backends: backends,
+
+		Profiles: append([]string{}, obj.options.Profiles...),
+
+		RegexpPath: obj.options.RegexpPath,
+
+		OrtAnalyzerPath: obj.options.OrtAnalyzerPath,
+
+		ChecksumsPath: obj.options.ChecksumsPath,
+		Checksums:     obj.options.Checksums,
+		Offline:       obj.options.Offline,
+		Limiter:       obj.limiter,
+		ParserPlugins: obj.options.ParserPlugins,
+
+		InferLicenses:     obj.options.InferLicenses,
+		Reuse:             obj.options.Reuse,
+		ExtractCopyrights: obj.options.ExtractCopyrights,
+		IgnorePath:        obj.options.IgnorePath,
+		CurationsPath:     obj.options.CurationsPath,
+		SummaryFirstParty: obj.options.SummaryFirstParty,
+
+		ObligationsPath: obj.options.ObligationsPath,
+		Ownership:       obj.options.Ownership,
+		Scope:           obj.options.Scope,
+		Blend:           obj.options.Blend,
+		SmartURIs:       obj.options.SmartURIs,
+		Duplicates:      obj.options.Duplicates,
+		MemoryBudget:    obj.options.MemoryBudget,
+		MmapThreshold:   obj.options.MmapThreshold,
+		Workspace:       obj.options.Workspace,
+		Perms:           obj.options.Perms,
+		Timings:         obj.options.Timings,
+		Cache:           obj.options.Cache,
+		CacheRemote:     obj.options.CacheRemote,
+		CacheSigningKey: obj.options.CacheSigningKey,
+		Estimate:        obj.options.Estimate,
+		RawOutput:       obj.options.RawOutput,
+		Quick:           obj.options.Quick,
+		Deep:            obj.options.Deep,
+		Schedule:        obj.options.Schedule,
+		OnResult:        obj.options.OnResult,
+		MaxFiles:        obj.options.MaxFiles,
+		MaxBytes:        obj.options.MaxBytes,
+		MaxDuration:     obj.options.MaxDuration,
+		Scancode:        obj.options.Scancode,
+		Askalono:        obj.options.Askalono,
+		GomodProxy:      obj.options.GomodProxy,
+	}
+
+	return m.Run(ctx)
+}
+
+// ScanFS runs a scan over an io/fs.FS filesystem and returns the output. This
+// can be an in-memory filesystem, an embedded one, or a remote implementation,
+// and nothing is read from the local disk. The name is used to build the UID's
+// of the results. Only the backends which can scan data are used, since there
+// are no real paths for the other backends to look at.
+func (obj *Yesiscan) ScanFS(ctx context.Context, name string, fsys fs.FS) (*lib.Output, error) {
+	if fsys == nil {
+		return nil, fmt.Errorf("the fs is nil")
+	}
+	if name == "" {
+		return nil, fmt.Errorf("the name is empty")
+	}
+	return obj.scan(ctx, nil, []interfaces.Iterator{
+		&iterator.IOFS{
+			Debug: obj.options.Debug,
+			Logf: func(format string, v ...interface{}) {
+				obj.options.Logf(format, v...)
+			},
+			FS:   fsys,
+			Name: name,
+		},
+	})
+}
@@ -0,0 +1,57 @@
+// Copyright Amazon.com Inc or its affiliates and the project contributors
+// Written by James Shubin <purple@amazon.com> and the project contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+//
+// We will never require a CLA to submit a patch. All contributions follow the
+// `inbound == outbound` rule.
+//
+// This is not an official Amazon product. Amazon does not offer support for
+// this project.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package yesiscan_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/awslabs/yesiscan"
+)
+
+func TestNew(t *testing.T) {
+	if _, err := yesiscan.New(nil); err != nil {
+		t.Errorf("error: %+v", err)
+	}
+	if _, err := yesiscan.New(&yesiscan.Options{Backends: map[string]bool{"spdx": true}}); err != nil {
+		t.Errorf("error: %+v", err)
+	}
+	if _, err := yesiscan.New(&yesiscan.Options{Backends: map[string]bool{"nope": true}}); err == nil {
+		t.Errorf("expected an unknown backend error")
+	}
+	if _, err := yesiscan.New(&yesiscan.Options{Blend: "nope"}); err == nil {
+		t.Errorf("expected an invalid blend error")
+	}
+}
+
+func TestScanNoStdin(t *testing.T) {
+	y, err := yesiscan.New(&yesiscan.Options{Backends: map[string]bool{}})
+	if err != nil {
+		t.Errorf("error: %+v", err)
+		return
+	}
+	if _, err := y.Scan(context.Background(), "-"); err == nil {
+		t.Errorf("expected an error when reading stdin without a reader")
+	}
+}